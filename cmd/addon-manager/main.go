@@ -50,5 +50,6 @@ func newWorkCommand() *cobra.Command {
 	}
 
 	cmd.AddCommand(hub.NewHubManager())
+	cmd.AddCommand(hub.NewRemovalReport())
 	return cmd
 }
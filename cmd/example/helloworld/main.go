@@ -22,10 +22,16 @@ import (
 	"open-cluster-management.io/addon-framework/pkg/addonfactory"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager"
 	addonagent "open-cluster-management.io/addon-framework/pkg/agent"
+	cloudeventswork "open-cluster-management.io/addon-framework/pkg/cloudevents/work"
 	cmdfactory "open-cluster-management.io/addon-framework/pkg/cmd/factory"
 	"open-cluster-management.io/addon-framework/pkg/version"
 )
 
+const (
+	workDriverKube = "kube"
+	workDriverMQTT = "mqtt"
+)
+
 func main() {
 	rand.Seed(time.Now().UTC().UnixNano())
 
@@ -66,22 +72,49 @@ func newCommand() *cobra.Command {
 }
 
 func newControllerCommand() *cobra.Command {
+	workDriver := workDriverKube
+	mqttOptions := cloudeventswork.NewMQTTOptions()
 	cmd := cmdfactory.
-		NewControllerCommandConfig("helloworld-addon-controller", version.Get(), runController).
+		NewControllerCommandConfig("helloworld-addon-controller", version.Get(),
+			func(ctx context.Context, kubeConfig *rest.Config) error {
+				return runController(ctx, kubeConfig, workDriver, mqttOptions)
+			}).
+		WithRenderCommand(func(addonClient addonv1alpha1client.Interface) ([]addonagent.AgentAddon, error) {
+			agentAddon, err := newAgentAddon(addonClient, nil)
+			return []addonagent.AgentAddon{agentAddon}, err
+		}).
 		NewCommand()
 	cmd.Use = "controller"
 	cmd.Short = "Start the addon controller"
 
+	flags := cmd.Flags()
+	flags.StringVar(&workDriver, "work-driver", workDriver, fmt.Sprintf(
+		"The driver to deliver the ManifestWorks of the addon through, %s or %s.", workDriverKube, workDriverMQTT))
+	mqttOptions.AddFlags(flags)
+
 	return cmd
 }
 
-func runController(ctx context.Context, kubeConfig *rest.Config) error {
+func runController(ctx context.Context, kubeConfig *rest.Config,
+	workDriver string, mqttOptions *cloudeventswork.MQTTOptions) error {
 	addonClient, err := addonv1alpha1client.NewForConfig(kubeConfig)
 	if err != nil {
 		return err
 	}
 
-	mgr, err := addonmanager.New(kubeConfig)
+	var opts []addonmanager.Option
+	switch workDriver {
+	case workDriverKube:
+	case workDriverMQTT:
+		if err := mqttOptions.Validate(); err != nil {
+			return err
+		}
+		opts = append(opts, addonmanager.WithWorkDriver(addonmanager.MQTTWorkDriver(mqttOptions)))
+	default:
+		return fmt.Errorf("unsupported work driver %q", workDriver)
+	}
+
+	mgr, err := addonmanager.New(kubeConfig, opts...)
 	if err != nil {
 		return err
 	}
@@ -92,19 +125,7 @@ func runController(ctx context.Context, kubeConfig *rest.Config) error {
 		utilrand.String(5),
 	)
 
-	agentAddon, err := addonfactory.NewAgentAddonFactory(helloworld.AddonName, helloworld.FS, "manifests/templates").
-		WithConfigGVRs(addonfactory.AddOnDeploymentConfigGVR).
-		WithGetValuesFuncs(
-			helloworld.GetDefaultValues,
-			addonfactory.GetAddOnDeploymentConfigValues(
-				addonfactory.NewAddOnDeploymentConfigGetter(addonClient),
-				addonfactory.ToAddOnDeploymentConfigValues,
-			),
-		).
-		WithAgentRegistrationOption(registrationOption).
-		WithInstallStrategy(addonagent.InstallAllStrategy(helloworld.InstallationNamespace)).
-		WithAgentHealthProber(helloworld.AgentHealthProber()).
-		BuildTemplateAgentAddon()
+	agentAddon, err := newAgentAddon(addonClient, registrationOption)
 	if err != nil {
 		klog.Errorf("failed to build agent %v", err)
 		return err
@@ -120,6 +141,26 @@ func runController(ctx context.Context, kubeConfig *rest.Config) error {
 		klog.Fatal(err)
 	}
 	<-ctx.Done()
+	// wait for the in-flight syncs to be drained before the leader election is released
+	<-mgr.Stopped()
 
 	return nil
 }
+
+// newAgentAddon builds the agent of the helloworld addon, it is also rendered by the render command.
+func newAgentAddon(addonClient addonv1alpha1client.Interface,
+	registrationOption *addonagent.RegistrationOption) (addonagent.AgentAddon, error) {
+	return addonfactory.NewAgentAddonFactory(helloworld.AddonName, helloworld.FS, "manifests/templates").
+		WithConfigGVRs(addonfactory.AddOnDeploymentConfigGVR).
+		WithGetValuesFuncs(
+			helloworld.GetDefaultValues,
+			addonfactory.GetAddOnDeploymentConfigValues(
+				addonfactory.NewAddOnDeploymentConfigGetter(addonClient),
+				addonfactory.ToAddOnDeploymentConfigValues,
+			),
+		).
+		WithAgentRegistrationOption(registrationOption).
+		WithInstallStrategy(addonagent.InstallAllStrategy(helloworld.InstallationNamespace)).
+		WithAgentHealthProber(helloworld.AgentHealthProber()).
+		BuildTemplateAgentAddon()
+}
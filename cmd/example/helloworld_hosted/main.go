@@ -106,6 +106,8 @@ func runController(ctx context.Context, kubeConfig *rest.Config) error {
 		klog.Fatal(err)
 	}
 	<-ctx.Done()
+	// wait for the in-flight syncs to be drained before the leader election is released
+	<-mgr.Stopped()
 
 	return nil
 }
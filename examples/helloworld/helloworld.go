@@ -47,12 +47,10 @@ func GetDefaultValues(cluster *clusterv1.ManagedCluster,
 	}
 
 	manifestConfig := struct {
-		KubeConfigSecret      string
 		ClusterName           string
 		AddonInstallNamespace string
 		Image                 string
 	}{
-		KubeConfigSecret:      fmt.Sprintf("%s-hub-kubeconfig", addon.Name),
 		AddonInstallNamespace: installNamespace,
 		ClusterName:           cluster.Name,
 		Image:                 image,
@@ -1,15 +1,14 @@
 package rbac
 
 import (
-	"context"
 	"fmt"
 
 	rbacv1 "k8s.io/api/rbac/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 )
@@ -21,12 +20,9 @@ func AddonRBAC(kubeConfig *rest.Config) agent.PermissionConfigFunc {
 			return err
 		}
 
-		groups := agent.DefaultGroups(cluster.Name, addon.Name)
-
 		role := &rbacv1.Role{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      fmt.Sprintf("open-cluster-management:%s:agent", addon.Name),
-				Namespace: cluster.Name,
+				Name: fmt.Sprintf("open-cluster-management:%s:agent", addon.Name),
 			},
 			Rules: []rbacv1.PolicyRule{
 				{Verbs: []string{"get", "list", "watch"}, Resources: []string{"configmaps"}, APIGroups: []string{""}},
@@ -34,43 +30,8 @@ func AddonRBAC(kubeConfig *rest.Config) agent.PermissionConfigFunc {
 			},
 		}
 
-		binding := &rbacv1.RoleBinding{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      fmt.Sprintf("open-cluster-management:%s:agent", addon.Name),
-				Namespace: cluster.Name,
-			},
-			RoleRef: rbacv1.RoleRef{
-				APIGroup: "rbac.authorization.k8s.io",
-				Kind:     "Role",
-				Name:     fmt.Sprintf("open-cluster-management:%s:agent", addon.Name),
-			},
-			Subjects: []rbacv1.Subject{
-				{Kind: "Group", APIGroup: "rbac.authorization.k8s.io", Name: groups[0]},
-			},
-		}
-
-		_, err = kubeclient.RbacV1().Roles(cluster.Name).Get(context.TODO(), role.Name, metav1.GetOptions{})
-		switch {
-		case errors.IsNotFound(err):
-			_, createErr := kubeclient.RbacV1().Roles(cluster.Name).Create(context.TODO(), role, metav1.CreateOptions{})
-			if createErr != nil {
-				return createErr
-			}
-		case err != nil:
-			return err
-		}
-
-		_, err = kubeclient.RbacV1().RoleBindings(cluster.Name).Get(context.TODO(), binding.Name, metav1.GetOptions{})
-		switch {
-		case errors.IsNotFound(err):
-			_, createErr := kubeclient.RbacV1().RoleBindings(cluster.Name).Create(context.TODO(), binding, metav1.CreateOptions{})
-			if createErr != nil {
-				return createErr
-			}
-		case err != nil:
-			return err
-		}
-
-		return nil
+		return utils.NewRBACPermissionConfigBuilder(kubeclient).
+			BindRoleToAgent(role).
+			Build()(cluster, addon)
 	}
 }
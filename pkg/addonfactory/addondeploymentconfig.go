@@ -2,20 +2,19 @@ package addonfactory
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 )
 
-var AddOnDeploymentConfigGVR = schema.GroupVersionResource{
-	Group:    "addon.open-cluster-management.io",
-	Version:  "v1alpha1",
-	Resource: "addondeploymentconfigs",
-}
+var AddOnDeploymentConfigGVR = utils.AddOnDeploymentConfigGVR
 
 // AddOnDeloymentConfigToValuesFunc transform the AddOnDeploymentConfig object into Values object
 // The transformation logic depends on the definition of the addon template
@@ -28,19 +27,10 @@ type AddOnDeloymentConfigGetter interface {
 	Get(ctx context.Context, namespace, name string) (*addonapiv1alpha1.AddOnDeploymentConfig, error)
 }
 
-type defaultAddOnDeploymentConfigGetter struct {
-	addonClient addonv1alpha1client.Interface
-}
-
-func (g *defaultAddOnDeploymentConfigGetter) Get(
-	ctx context.Context, namespace, name string) (*addonapiv1alpha1.AddOnDeploymentConfig, error) {
-	return g.addonClient.AddonV1alpha1().AddOnDeploymentConfigs(namespace).Get(ctx, name, metav1.GetOptions{})
-}
-
 // NewAddOnDeloymentConfigGetter returns a AddOnDeloymentConfigGetter with addon client
 // Deprecated: use NewAddOnDeploymentConfigGetter instead.
 func NewAddOnDeloymentConfigGetter(addonClient addonv1alpha1client.Interface) AddOnDeloymentConfigGetter {
-	return &defaultAddOnDeploymentConfigGetter{addonClient: addonClient}
+	return utils.NewAddOnDeploymentConfigGetter(addonClient)
 }
 
 // GetAddOnDeloymentConfigValues uses AddOnDeloymentConfigGetter to get the AddOnDeploymentConfig object, then
@@ -52,12 +42,7 @@ func GetAddOnDeloymentConfigValues(
 	getter AddOnDeloymentConfigGetter, toValuesFuncs ...AddOnDeloymentConfigToValuesFunc) GetValuesFunc {
 	return func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) (Values, error) {
 		var lastValues = Values{}
-		for _, config := range addon.Status.ConfigReferences {
-			if config.ConfigGroupResource.Group != AddOnDeploymentConfigGVR.Group ||
-				config.ConfigGroupResource.Resource != AddOnDeploymentConfigGVR.Resource {
-				continue
-			}
-
+		for _, config := range utils.GetDesiredConfigsOf(addon, utils.AddOnDeploymentConfigGroupResource) {
 			addOnDeploymentConfig, err := getter.Get(context.Background(), config.Namespace, config.Name)
 			if err != nil {
 				return nil, err
@@ -155,18 +140,94 @@ func ToAddOnCustomizedVariableValues(config addonapiv1alpha1.AddOnDeploymentConf
 	return values, nil
 }
 
+// ProxyConfig is the proxy of the addon agents set by the ProxyConfigAnnotationKey annotation of the
+// AddOnDeploymentConfig.
+type ProxyConfig struct {
+	// HTTPProxy is the URL of the proxy for HTTP requests.
+	HTTPProxy string `json:"httpProxy,omitempty"`
+	// HTTPSProxy is the URL of the proxy for HTTPS requests.
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+	// NoProxy is a comma-separated list of hostnames and/or CIDRs and/or IPs for which the proxy should not be used.
+	NoProxy string `json:"noProxy,omitempty"`
+	// CABundle is a CA certificate bundle to verify the proxy server.
+	CABundle []byte `json:"caBundle,omitempty"`
+}
+
+// GetProxyConfig returns the proxy config of the AddOnDeploymentConfig, it returns nil if the proxy is not set.
+func GetProxyConfig(config addonapiv1alpha1.AddOnDeploymentConfig) (*ProxyConfig, error) {
+	value, ok := config.Annotations[constants.ProxyConfigAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	proxyConfig := &ProxyConfig{}
+	if err := json.Unmarshal([]byte(value), proxyConfig); err != nil {
+		return nil, fmt.Errorf("invalid proxy config of addondeploymentconfig %s/%s: %v", config.Namespace, config.Name, err)
+	}
+	return proxyConfig, nil
+}
+
+// ToAddOnProxyConfigValues only transform the proxy config of AddOnDeploymentConfig into Values object.
+// for example: the proxy config of one AddOnDeploymentConfig is:
+//
+//	{
+//	 httpProxy: "http://proxy:3128", httpsProxy: "https://proxy:3129", noProxy: "localhost", caBundle: "<ca>"
+//	}
+//
+// after transformed, the Values will be:
+// map[ProxyConfig:map[HTTP_PROXY:http://proxy:3128 HTTPS_PROXY:https://proxy:3129 NO_PROXY:localhost PROXY_CA_BUNDLE:<ca>]]
+func ToAddOnProxyConfigValues(config addonapiv1alpha1.AddOnDeploymentConfig) (Values, error) {
+	proxyConfig, err := GetProxyConfig(config)
+	if err != nil || proxyConfig == nil {
+		return nil, err
+	}
+
+	return Values{
+		"ProxyConfig": map[string]interface{}{
+			"HTTP_PROXY":      proxyConfig.HTTPProxy,
+			"HTTPS_PROXY":     proxyConfig.HTTPSProxy,
+			"NO_PROXY":        proxyConfig.NoProxy,
+			"PROXY_CA_BUNDLE": string(proxyConfig.CABundle),
+		},
+	}, nil
+}
+
+// ContainerResourceRequirements is the resource requirements override of the addon agent containers set by the
+// ResourceRequirementsAnnotationKey annotation of the AddOnDeploymentConfig.
+type ContainerResourceRequirements struct {
+	// ContainerID identifies the containers to override, it is in the format of
+	// "<kind>:<workload name>:<container name>", each part is a regular expression matching the whole value,
+	// the kind is the lowercase plural kind of the workload, e.g. "deployments" or "daemonsets".
+	ContainerID string `json:"containerID"`
+	// Resources is the resource requests and limits to set on the matched containers.
+	Resources corev1.ResourceRequirements `json:"resources"`
+}
+
+// GetResourceRequirements returns the resource requirements overrides of the AddOnDeploymentConfig.
+func GetResourceRequirements(config addonapiv1alpha1.AddOnDeploymentConfig) ([]ContainerResourceRequirements, error) {
+	value, ok := config.Annotations[constants.ResourceRequirementsAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	requirements := []ContainerResourceRequirements{}
+	if err := json.Unmarshal([]byte(value), &requirements); err != nil {
+		return nil, fmt.Errorf("invalid resource requirements of addondeploymentconfig %s/%s: %v",
+			config.Namespace, config.Name, err)
+	}
+	return requirements, nil
+}
+
 // AddOnDeploymentConfigToValuesFunc transform the AddOnDeploymentConfig object into Values object
 // The transformation logic depends on the definition of the addon template
 type AddOnDeploymentConfigToValuesFunc func(config addonapiv1alpha1.AddOnDeploymentConfig) (Values, error)
 
 // AddOnDeploymentConfigGetter has a method to return a AddOnDeploymentConfig object
-type AddOnDeploymentConfigGetter interface {
-	Get(ctx context.Context, namespace, name string) (*addonapiv1alpha1.AddOnDeploymentConfig, error)
-}
+type AddOnDeploymentConfigGetter = utils.AddOnDeploymentConfigGetter
 
 // NewAddOnDeploymentConfigGetter returns a AddOnDeploymentConfigGetter with addon client
 func NewAddOnDeploymentConfigGetter(addonClient addonv1alpha1client.Interface) AddOnDeploymentConfigGetter {
-	return &defaultAddOnDeploymentConfigGetter{addonClient: addonClient}
+	return utils.NewAddOnDeploymentConfigGetter(addonClient)
 }
 
 // GetAddOnDeploymentConfigValues uses AddOnDeploymentConfigGetter to get the AddOnDeploymentConfig object, then
@@ -177,12 +238,7 @@ func GetAddOnDeploymentConfigValues(
 	getter AddOnDeploymentConfigGetter, toValuesFuncs ...AddOnDeploymentConfigToValuesFunc) GetValuesFunc {
 	return func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) (Values, error) {
 		var lastValues = Values{}
-		for _, config := range addon.Status.ConfigReferences {
-			if config.ConfigGroupResource.Group != AddOnDeploymentConfigGVR.Group ||
-				config.ConfigGroupResource.Resource != AddOnDeploymentConfigGVR.Resource {
-				continue
-			}
-
+		for _, config := range utils.GetDesiredConfigsOf(addon, utils.AddOnDeploymentConfigGroupResource) {
 			addOnDeploymentConfig, err := getter.Get(context.Background(), config.Namespace, config.Name)
 			if err != nil {
 				return nil, err
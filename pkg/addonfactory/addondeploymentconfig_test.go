@@ -8,6 +8,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
 )
@@ -195,6 +196,45 @@ func TestGetAddOnDeploymentConfigValues(t *testing.T) {
 				"managedKubeConfigSecret": "external-managed-kubeconfig",
 			},
 		},
+		{
+			name:          "to addon proxy config",
+			toValuesFuncs: []AddOnDeploymentConfigToValuesFunc{ToAddOnProxyConfigValues},
+			addOnObjs: []runtime.Object{
+				func() *addonapiv1alpha1.ManagedClusterAddOn {
+					addon := addontesting.NewAddon("test", "cluster1")
+					addon.Status.ConfigReferences = []addonapiv1alpha1.ConfigReference{
+						{
+							ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+								Group:    "addon.open-cluster-management.io",
+								Resource: "addondeploymentconfigs",
+							},
+							ConfigReferent: addonapiv1alpha1.ConfigReferent{
+								Namespace: "cluster1",
+								Name:      "config",
+							},
+						},
+					}
+					return addon
+				}(),
+				&addonapiv1alpha1.AddOnDeploymentConfig{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "config",
+						Namespace: "cluster1",
+						Annotations: map[string]string{
+							constants.ProxyConfigAnnotationKey: `{"httpProxy":"http://proxy","httpsProxy":"https://proxy","noProxy":"localhost","caBundle":"dGVzdA=="}`,
+						},
+					},
+				},
+			},
+			expectedValues: Values{
+				"ProxyConfig": map[string]interface{}{
+					"HTTP_PROXY":      "http://proxy",
+					"HTTPS_PROXY":     "https://proxy",
+					"NO_PROXY":        "localhost",
+					"PROXY_CA_BUNDLE": "test",
+				},
+			},
+		},
 	}
 
 	for _, c := range cases {
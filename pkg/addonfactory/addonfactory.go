@@ -12,6 +12,7 @@ import (
 	"k8s.io/klog/v2"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
 
 	"open-cluster-management.io/addon-framework/pkg/agent"
 )
@@ -26,16 +27,27 @@ type Values map[string]interface{}
 type GetValuesFunc func(cluster *clusterv1.ManagedCluster,
 	addon *addonapiv1alpha1.ManagedClusterAddOn) (Values, error)
 
+// ManifestMutatorFunc mutates the manifests rendered by the agentAddon, ex: NewProxyConfigMutator.
+type ManifestMutatorFunc func(cluster *clusterv1.ManagedCluster,
+	addon *addonapiv1alpha1.ManagedClusterAddOn, objects []runtime.Object) ([]runtime.Object, error)
+
 // AgentAddonFactory includes the common fields for building different agentAddon instances.
 type AgentAddonFactory struct {
 	scheme            *runtime.Scheme
 	fs                embed.FS
 	dir               string
 	getValuesFuncs    []GetValuesFunc
+	manifestMutators  []ManifestMutatorFunc
+	manifestFilters   []ManifestFilterFunc
 	agentAddonOptions agent.AgentAddonOptions
 	// trimCRDDescription flag is used to trim the description of CRDs in manifestWork. disabled by default.
 	trimCRDDescription bool
 	hostingCluster     *clusterv1.ManagedCluster
+	// valuesMergePolicy and valuesListMergeKeys are how the values of the getValuesFuncs are merged.
+	valuesMergePolicy   ValuesMergePolicy
+	valuesListMergeKeys []string
+	// valuesSchema is the JSON schema the values of the agent are validated against before rendering.
+	valuesSchema []byte
 }
 
 // NewAgentAddonFactory builds an addonAgentFactory instance with addon name and fs.
@@ -56,8 +68,10 @@ func NewAgentAddonFactory(addonName string, fs embed.FS, dir string) *AgentAddon
 			HealthProber:        nil,
 			SupportedConfigGVRs: []schema.GroupVersionResource{},
 		},
-		trimCRDDescription: false,
-		scheme:             s,
+		trimCRDDescription:  false,
+		scheme:              s,
+		valuesMergePolicy:   ValuesMergePolicyOverride,
+		valuesListMergeKeys: DefaultValuesListMergeKeys,
 	}
 }
 
@@ -77,6 +91,65 @@ func (f *AgentAddonFactory) WithGetValuesFuncs(getValuesFuncs ...GetValuesFunc)
 	return f
 }
 
+// WithValuesMergePolicy sets the policy to merge the values of the getValuesFuncs, it is
+// ValuesMergePolicyOverride by default. The values of the getValuesFuncs always override the default values of the
+// addon, and the built-in values always override the values of the getValuesFuncs.
+func (f *AgentAddonFactory) WithValuesMergePolicy(policy ValuesMergePolicy) *AgentAddonFactory {
+	f.valuesMergePolicy = policy
+	return f
+}
+
+// WithValuesListMergeKeys sets the keys of the items of the lists merged by ValuesMergePolicyDeepMerge and
+// ValuesMergePolicyErrorOnConflict, it is DefaultValuesListMergeKeys by default.
+func (f *AgentAddonFactory) WithValuesListMergeKeys(keys ...string) *AgentAddonFactory {
+	f.valuesListMergeKeys = keys
+	return f
+}
+
+// WithValuesSchema sets the JSON schema, e.g. the values.schema.json of a helm chart, the values of the agent are
+// validated against before rendering the manifests. The Manifests returns an agent.ValuesInvalidError if the
+// values are invalid, and the addon manager sets the ValuesInvalid condition of the addon with the errors. The
+// values include the built-in values, so the schema should allow the additional properties. The helm agentAddon
+// validates the values against the values.schema.json of the chart if the schema is not set.
+func (f *AgentAddonFactory) WithValuesSchema(schema []byte) *AgentAddonFactory {
+	f.valuesSchema = schema
+	return f
+}
+
+// WithManifestMutators adds a list of the manifest mutators, they are called in order on the rendered manifests.
+func (f *AgentAddonFactory) WithManifestMutators(mutators ...ManifestMutatorFunc) *AgentAddonFactory {
+	f.manifestMutators = append(f.manifestMutators, mutators...)
+	return f
+}
+
+// WithManifestFilter adds a manifest filter, the manifests of the files returned by the filter are not rendered
+// for the managed cluster, ex: the SecurityContextConstraints only rendered on OpenShift:
+//
+//	WithManifestFilter(func(cluster *clusterv1.ManagedCluster) []string {
+//		if !GetClusterCapabilities(cluster).OpenShift {
+//			return []string{"templates/scc.yaml"}
+//		}
+//		return nil
+//	})
+func (f *AgentAddonFactory) WithManifestFilter(filter ManifestFilterFunc) *AgentAddonFactory {
+	f.manifestFilters = append(f.manifestFilters, filter)
+	return f
+}
+
+// WithClusterImageRegistries pulls the images of the rendered workloads from the mirror registries of the managed
+// cluster, so the addon works on the disconnected clusters without overriding the images itself.
+func (f *AgentAddonFactory) WithClusterImageRegistries() *AgentAddonFactory {
+	f.manifestMutators = append(f.manifestMutators, NewImageRegistriesMutator())
+	return f
+}
+
+// WithHubDependencies declares the Secrets/ConfigMaps on the hub that the getValues funcs read. The
+// manifests of the addon are re-rendered when any of these objects changes.
+func (f *AgentAddonFactory) WithHubDependencies(dependencies ...agent.HubDependency) *AgentAddonFactory {
+	f.agentAddonOptions.HubDependencies = append(f.agentAddonOptions.HubDependencies, dependencies...)
+	return f
+}
+
 // WithInstallStrategy defines the installation strategy of the manifests prescribed by Manifests(..).
 func (f *AgentAddonFactory) WithInstallStrategy(strategy *agent.InstallStrategy) *AgentAddonFactory {
 	if strategy.InstallNamespace == "" {
@@ -87,6 +160,14 @@ func (f *AgentAddonFactory) WithInstallStrategy(strategy *agent.InstallStrategy)
 	return f
 }
 
+// WithAgentInstallNamespace defines the namespace the addon agent is installed in on each managed cluster,
+// which overrides the spec.installNamespace of the addon, ex: utils.AgentInstallNamespaceFromDeploymentConfigFunc.
+func (f *AgentAddonFactory) WithAgentInstallNamespace(
+	nsFunc func(addon *addonapiv1alpha1.ManagedClusterAddOn) (string, error)) *AgentAddonFactory {
+	f.agentAddonOptions.AgentInstallNamespace = nsFunc
+	return f
+}
+
 // WithAgentRegistrationOption defines how agent is registered to the hub cluster.
 func (f *AgentAddonFactory) WithAgentRegistrationOption(option *agent.RegistrationOption) *AgentAddonFactory {
 	f.agentAddonOptions.Registration = option
@@ -99,6 +180,45 @@ func (f *AgentAddonFactory) WithAgentHealthProber(prober *agent.HealthProber) *A
 	return f
 }
 
+// WithManifestConfigs defines the per-resource configurations of the ManifestWorks, e.g. the update strategy.
+func (f *AgentAddonFactory) WithManifestConfigs(configs ...workapiv1.ManifestConfigOption) *AgentAddonFactory {
+	f.agentAddonOptions.ManifestConfigs = append(f.agentAddonOptions.ManifestConfigs, configs...)
+	return f
+}
+
+// WithWorkConfiguration defines the delete option and the executor of the ManifestWorks.
+func (f *AgentAddonFactory) WithWorkConfiguration(config *agent.WorkConfiguration) *AgentAddonFactory {
+	f.agentAddonOptions.WorkConfiguration = config
+	return f
+}
+
+// WithDeletionPolicy defines whether the resources of the addon agent are orphaned when the addon is deleted.
+func (f *AgentAddonFactory) WithDeletionPolicy(policy agent.DeletionPolicy) *AgentAddonFactory {
+	f.agentAddonOptions.DeletionPolicy = policy
+	return f
+}
+
+// WithFeatureGates defines the feature gates supported by the addon agent and their default states, see
+// GetFeatureGatesValues and NewFeatureGatesMutator to consume them in the manifests.
+func (f *AgentAddonFactory) WithFeatureGates(gates agent.FeatureGates) *AgentAddonFactory {
+	f.agentAddonOptions.FeatureGates = gates
+	return f
+}
+
+// WithAgentVersion sets the desired version of the addon agent, e.g. the image tag of the agent, the version
+// applied on each managed cluster is reported by the AgentVersionUpToDate condition of the ManagedClusterAddOn.
+func (f *AgentAddonFactory) WithAgentVersion(version string) *AgentAddonFactory {
+	f.agentAddonOptions.Version = version
+	return f
+}
+
+// WithManifestDriftDetection detects the ManifestWorks of the addon agent modified out-of-band on the hub, and
+// restores them to the rendered manifests if autoRestore is true.
+func (f *AgentAddonFactory) WithManifestDriftDetection(autoRestore bool) *AgentAddonFactory {
+	f.agentAddonOptions.ManifestDriftDetection = &agent.ManifestDriftDetection{AutoRestore: autoRestore}
+	return f
+}
+
 // WithAgentHostedModeEnabledOption will enable the agent hosted deploying mode.
 func (f *AgentAddonFactory) WithAgentHostedModeEnabledOption() *AgentAddonFactory {
 	f.agentAddonOptions.HostedModeEnabled = true
@@ -129,6 +249,9 @@ func (f *AgentAddonFactory) BuildHelmAgentAddon() (agent.AgentAddon, error) {
 	if err := validateSupportedConfigGVRs(f.agentAddonOptions.SupportedConfigGVRs); err != nil {
 		return nil, err
 	}
+	if err := validateValuesSchema(f.valuesSchema); err != nil {
+		return nil, err
+	}
 
 	userChart, err := loadChart(f.fs, f.dir)
 	if err != nil {
@@ -145,6 +268,9 @@ func (f *AgentAddonFactory) BuildTemplateAgentAddon() (agent.AgentAddon, error)
 	if err := validateSupportedConfigGVRs(f.agentAddonOptions.SupportedConfigGVRs); err != nil {
 		return nil, err
 	}
+	if err := validateValuesSchema(f.valuesSchema); err != nil {
+		return nil, err
+	}
 
 	templateFiles, err := getTemplateFiles(f.fs, f.dir)
 	if err != nil {
@@ -0,0 +1,107 @@
+package addonfactory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+// the labels and cluster claims of the ManagedCluster the capabilities are detected from. They are reported
+// by the klusterlet and the cluster-claims controller.
+const (
+	clusterLabelVendor           = "vendor"
+	clusterLabelOpenShiftVersion = "openshiftVersion"
+	clusterLabelArch             = "kubernetes.io/arch"
+
+	clusterClaimKubeVersion = "kubeversion.open-cluster-management.io"
+	clusterClaimPlatform    = "platform.open-cluster-management.io"
+	clusterClaimProduct     = "product.open-cluster-management.io"
+	clusterClaimOCPVersion  = "version.openshift.io"
+	clusterClaimArch        = "arch.open-cluster-management.io"
+
+	vendorOpenShift = "OpenShift"
+)
+
+// ClusterCapabilities are the capabilities of a managed cluster detected from the ManagedCluster, they are
+// in the built-in values of the template and helm agentAddons as ClusterCapabilities and clusterCapabilities.
+type ClusterCapabilities struct {
+	// KubeVersion is the kubernetes version of the cluster, ex: v1.28.3.
+	KubeVersion string `json:"kubeVersion"`
+	// KubeVersionMajor and KubeVersionMinor are the major and minor kubernetes version of the cluster, they are
+	// 0 if the kubernetes version is not reported or invalid.
+	KubeVersionMajor int `json:"kubeVersionMajor"`
+	KubeVersionMinor int `json:"kubeVersionMinor"`
+	// Vendor is the vendor of the cluster, ex: OpenShift, EKS, AKS.
+	Vendor string `json:"vendor"`
+	// Product and Platform are the product and the infrastructure platform of the cluster, ex: OpenShift on AWS.
+	Product  string `json:"product"`
+	Platform string `json:"platform"`
+	// OpenShift is true if the cluster is an OpenShift cluster, OpenShiftVersion is its version, ex: 4.14.0.
+	OpenShift        bool   `json:"openShift"`
+	OpenShiftVersion string `json:"openShiftVersion"`
+	// Arch is the cpu architecture of the nodes of the cluster, ex: arm64.
+	Arch string `json:"arch"`
+	// Claims are all the cluster claims of the cluster.
+	Claims map[string]string `json:"claims"`
+}
+
+// GetClusterCapabilities returns the capabilities of the managed cluster.
+func GetClusterCapabilities(cluster *clusterv1.ManagedCluster) ClusterCapabilities {
+	capabilities := ClusterCapabilities{Claims: map[string]string{}}
+	if cluster == nil {
+		return capabilities
+	}
+
+	for _, claim := range cluster.Status.ClusterClaims {
+		capabilities.Claims[claim.Name] = claim.Value
+	}
+	labels := cluster.GetLabels()
+
+	capabilities.KubeVersion = cluster.Status.Version.Kubernetes
+	if len(capabilities.KubeVersion) == 0 {
+		capabilities.KubeVersion = capabilities.Claims[clusterClaimKubeVersion]
+	}
+	if version, err := semver.NewVersion(capabilities.KubeVersion); err == nil {
+		capabilities.KubeVersionMajor = int(version.Major())
+		capabilities.KubeVersionMinor = int(version.Minor())
+	}
+
+	capabilities.Vendor = labels[clusterLabelVendor]
+	capabilities.Product = capabilities.Claims[clusterClaimProduct]
+	capabilities.Platform = capabilities.Claims[clusterClaimPlatform]
+	capabilities.OpenShift = capabilities.Vendor == vendorOpenShift || capabilities.Product == vendorOpenShift
+	capabilities.OpenShiftVersion = firstNonEmpty(capabilities.Claims[clusterClaimOCPVersion], labels[clusterLabelOpenShiftVersion])
+	capabilities.Arch = firstNonEmpty(capabilities.Claims[clusterClaimArch], labels[clusterLabelArch])
+	return capabilities
+}
+
+// KubeVersionAtLeast returns true if the kubernetes version of the cluster is the minVersion or newer, ex:
+// KubeVersionAtLeast("1.25"). The pre-release and the build metadata of the cluster version are ignored, and a
+// cluster whose version is not reported is considered as older than any version.
+func (c ClusterCapabilities) KubeVersionAtLeast(minVersion string) (bool, error) {
+	min, err := semver.NewVersion(minVersion)
+	if err != nil {
+		return false, fmt.Errorf("invalid min kubernetes version %q: %v", minVersion, err)
+	}
+
+	version, err := semver.NewVersion(c.KubeVersion)
+	if err != nil {
+		return false, nil
+	}
+	release, err := semver.NewVersion(fmt.Sprintf("%d.%d.%d", version.Major(), version.Minor(), version.Patch()))
+	if err != nil {
+		return false, nil
+	}
+	return !release.LessThan(min), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if len(strings.TrimSpace(value)) > 0 {
+			return value
+		}
+	}
+	return ""
+}
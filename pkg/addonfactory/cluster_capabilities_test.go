@@ -0,0 +1,218 @@
+package addonfactory
+
+import (
+	"reflect"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1apha1 "open-cluster-management.io/api/cluster/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+)
+
+func TestGetClusterCapabilities(t *testing.T) {
+	profiles := addontesting.NewClusterProfiles()
+
+	cases := []struct {
+		name                 string
+		cluster              *clusterv1.ManagedCluster
+		expectedCapabilities ClusterCapabilities
+	}{
+		{
+			name:                 "no cluster",
+			expectedCapabilities: ClusterCapabilities{Claims: map[string]string{}},
+		},
+		{
+			name:    "openshift",
+			cluster: profiles["openshift"],
+			expectedCapabilities: ClusterCapabilities{
+				KubeVersion:      "v1.27.6+f67aeb3",
+				KubeVersionMajor: 1,
+				KubeVersionMinor: 27,
+				Vendor:           "OpenShift",
+				Product:          "OpenShift",
+				Platform:         "AWS",
+				OpenShift:        true,
+				OpenShiftVersion: "4.14.0",
+				Claims: map[string]string{
+					addontesting.ClusterClaimID:          "openshift",
+					addontesting.ClusterClaimKubeVersion: "v1.27.6+f67aeb3",
+					addontesting.ClusterClaimPlatform:    "AWS",
+					addontesting.ClusterClaimProduct:     "OpenShift",
+					addontesting.ClusterClaimOCPVersion:  "4.14.0",
+				},
+			},
+		},
+		{
+			name:    "eks",
+			cluster: profiles["eks"],
+			expectedCapabilities: ClusterCapabilities{
+				KubeVersion:      "v1.28.3-eks-4f4795d",
+				KubeVersionMajor: 1,
+				KubeVersionMinor: 28,
+				Vendor:           "EKS",
+				Product:          "EKS",
+				Platform:         "AWS",
+				Claims: map[string]string{
+					addontesting.ClusterClaimID:          "eks",
+					addontesting.ClusterClaimKubeVersion: "v1.28.3-eks-4f4795d",
+					addontesting.ClusterClaimPlatform:    "AWS",
+					addontesting.ClusterClaimProduct:     "EKS",
+				},
+			},
+		},
+		{
+			name:    "arm64",
+			cluster: profiles["arm64"],
+			expectedCapabilities: ClusterCapabilities{
+				KubeVersion:      "v1.28.2",
+				KubeVersionMajor: 1,
+				KubeVersionMinor: 28,
+				Vendor:           "Other",
+				Arch:             "arm64",
+				Claims: map[string]string{
+					addontesting.ClusterClaimID:          "arm64",
+					addontesting.ClusterClaimKubeVersion: "v1.28.2",
+					addontesting.ClusterClaimArch:        "arm64",
+				},
+			},
+		},
+		{
+			name: "kube version from the cluster claim",
+			cluster: &clusterv1.ManagedCluster{Status: clusterv1.ManagedClusterStatus{
+				ClusterClaims: []clusterv1.ManagedClusterClaim{{Name: addontesting.ClusterClaimKubeVersion, Value: "v1.24.1"}},
+			}},
+			expectedCapabilities: ClusterCapabilities{
+				KubeVersion:      "v1.24.1",
+				KubeVersionMajor: 1,
+				KubeVersionMinor: 24,
+				Claims:           map[string]string{addontesting.ClusterClaimKubeVersion: "v1.24.1"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			capabilities := GetClusterCapabilities(c.cluster)
+			if !reflect.DeepEqual(capabilities, c.expectedCapabilities) {
+				t.Errorf("expected capabilities %+v, but got %+v", c.expectedCapabilities, capabilities)
+			}
+		})
+	}
+}
+
+func TestKubeVersionAtLeast(t *testing.T) {
+	cases := []struct {
+		name        string
+		kubeVersion string
+		minVersion  string
+		expected    bool
+		expectErr   bool
+	}{
+		{name: "newer", kubeVersion: "v1.28.3", minVersion: "1.25", expected: true},
+		{name: "same", kubeVersion: "v1.25.0", minVersion: "1.25", expected: true},
+		{name: "older", kubeVersion: "v1.24.9", minVersion: "1.25"},
+		{name: "vendor pre-release", kubeVersion: "v1.25.0-eks-4f4795d", minVersion: "1.25", expected: true},
+		{name: "build metadata", kubeVersion: "v1.27.6+f67aeb3", minVersion: "v1.27.6", expected: true},
+		{name: "not reported", minVersion: "1.25"},
+		{name: "invalid min version", kubeVersion: "v1.28.3", minVersion: "invalid", expectErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := ClusterCapabilities{KubeVersion: c.kubeVersion}.KubeVersionAtLeast(c.minVersion)
+			if (err != nil) != c.expectErr {
+				t.Fatalf("expected error %v, but got %v", c.expectErr, err)
+			}
+			if actual != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestManifestFilter(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clusterv1apha1.Install(scheme)
+	_ = apiextensionsv1.AddToScheme(scheme)
+
+	// the cluster claims and the CRDs are only rendered on OpenShift
+	filter := func(cluster *clusterv1.ManagedCluster) []string {
+		if GetClusterCapabilities(cluster).OpenShift {
+			return nil
+		}
+		return []string{"clusterclaim.yaml", "templates/clusterclaim*.yaml", "crds/*"}
+	}
+	imageValues := func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) (Values, error) {
+		return Values{"Image": "quay.io/helloworld:latest"}, nil
+	}
+
+	templateAddon, err := NewAgentAddonFactory("helloworld", templateFS, "testmanifests/template").
+		WithScheme(scheme).
+		WithGetValuesFuncs(imageValues).
+		WithManifestFilter(filter).
+		BuildTemplateAgentAddon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	helmAddon, err := NewAgentAddonFactory("helloworld", chartFS, "testmanifests/chart").
+		WithScheme(scheme).
+		WithGetValuesFuncs(getValues).
+		WithManifestFilter(filter).
+		BuildHelmAgentAddon()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profiles := addontesting.NewClusterProfiles()
+	for name, agentAddon := range map[string]interface {
+		Manifests(*clusterv1.ManagedCluster, *addonapiv1alpha1.ManagedClusterAddOn) ([]runtime.Object, error)
+	}{"template": templateAddon, "helm": helmAddon} {
+		t.Run(name, func(t *testing.T) {
+			kinds := func(cluster *clusterv1.ManagedCluster) map[string]bool {
+				addon := NewFakeManagedClusterAddon("helloworld", cluster.Name, "myNs", "")
+				objects, err := agentAddon.Manifests(cluster, addon)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				kinds := map[string]bool{}
+				for _, obj := range objects {
+					kinds[obj.GetObjectKind().GroupVersionKind().Kind] = true
+				}
+				return kinds
+			}
+
+			openshift := kinds(profiles["openshift"])
+			if !openshift["ClusterClaim"] || !openshift["Deployment"] {
+				t.Errorf("expected cluster claim and deployment on openshift, but got %v", openshift)
+			}
+			eks := kinds(profiles["eks"])
+			if eks["ClusterClaim"] || eks["CustomResourceDefinition"] || !eks["Deployment"] {
+				t.Errorf("expected only deployment on eks, but got %v", eks)
+			}
+		})
+	}
+}
+
+func TestClusterCapabilitiesValues(t *testing.T) {
+	agentAddon, err := NewAgentAddonFactory("helloworld", templateFS, "testmanifests/template").BuildTemplateAgentAddon()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := addontesting.NewOpenShiftCluster("cluster1", "4.14.0")
+	values, err := agentAddon.(*TemplateAgentAddon).getValues(cluster, NewFakeManagedClusterAddon("helloworld", "cluster1", "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	capabilities, ok := values["ClusterCapabilities"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected cluster capabilities in values, but got %v", values)
+	}
+	if capabilities["OpenShift"] != true || capabilities["KubeVersionMinor"] != 27 {
+		t.Errorf("unexpected cluster capabilities %v", capabilities)
+	}
+}
@@ -0,0 +1,65 @@
+package addonfactory
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+)
+
+// manifestDecoder decodes the yaml documents of the rendered files into objects. The decode errors of all the
+// documents are collected with the file name and the document index, so a single bad document does not hide
+// the others and all of them can be fixed at once.
+type manifestDecoder struct {
+	decoder runtime.Decoder
+	objects []runtime.Object
+	errs    []error
+}
+
+func newManifestDecoder(decoder runtime.Decoder) *manifestDecoder {
+	return &manifestDecoder{decoder: decoder}
+}
+
+// decode decodes all the yaml documents of the file. The documents without kind are skipped, since the
+// resources may be provided by other hub-side components.
+// Example case: https://github.com/open-cluster-management-io/addon-framework/pull/72
+func (d *manifestDecoder) decode(file string, data []byte) {
+	yamlReader := yaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for index := 0; ; index++ {
+		doc, err := yamlReader.Read()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			d.errs = append(d.errs, fmt.Errorf("%s (document %d): %v", file, index, err))
+			return
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		object, _, err := d.decoder.Decode(doc, nil, nil)
+		switch {
+		case runtime.IsMissingKind(err):
+			klog.V(4).Infof("Skipping document %d of template %v, reason: %v", index, file, err)
+		case err != nil:
+			d.errs = append(d.errs, fmt.Errorf("%s (document %d): %v", file, index, err))
+		case len(d.errs) == 0:
+			// once a document fails, the remaining documents are only decoded to validate them.
+			d.objects = append(d.objects, object)
+		}
+	}
+}
+
+// result returns the decoded objects, or the aggregated error of all the documents failed to decode.
+func (d *manifestDecoder) result() ([]runtime.Object, error) {
+	if len(d.errs) > 0 {
+		return nil, fmt.Errorf("failed to decode %d manifests: %v", len(d.errs), utilerrors.NewAggregate(d.errs))
+	}
+	return d.objects, nil
+}
@@ -0,0 +1,87 @@
+package addonfactory
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func TestManifestDecoder(t *testing.T) {
+	configMap := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+  namespace: default
+`
+	noKind := `apiVersion: v1
+metadata:
+  name: test
+`
+	invalidYaml := `apiVersion: v1
+kind: ConfigMap
+metadata: [name
+`
+	unknownKind := `apiVersion: test.io/v1
+kind: Foo
+metadata:
+  name: test
+`
+
+	cases := []struct {
+		name            string
+		files           map[string]string
+		expectedObjects int
+		expectedErrs    []string
+	}{
+		{
+			name: "multiple documents",
+			files: map[string]string{
+				"a.yaml": "---\n" + configMap + "---\n" + configMap,
+				"b.yaml": noKind,
+			},
+			expectedObjects: 2,
+		},
+		{
+			name: "all the bad documents are reported",
+			files: map[string]string{
+				"a.yaml": configMap + "---\n" + invalidYaml + "---\n" + configMap,
+				"b.yaml": unknownKind,
+			},
+			expectedErrs: []string{"failed to decode 2 manifests", "a.yaml (document 1)", "b.yaml (document 0)"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			decoder := newManifestDecoder(serializer.NewCodecFactory(scheme.Scheme).UniversalDeserializer())
+			for _, file := range []string{"a.yaml", "b.yaml"} {
+				decoder.decode(file, []byte(c.files[file]))
+			}
+
+			objects, err := decoder.result()
+			if len(c.expectedErrs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(objects) != c.expectedObjects {
+					t.Errorf("expected %d objects, but got %d", c.expectedObjects, len(objects))
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected error, but got nil")
+			}
+			if objects != nil {
+				t.Errorf("expected no objects, but got %v", objects)
+			}
+			for _, expected := range c.expectedErrs {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected error %q to contain %q", err.Error(), expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package addonfactory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+const (
+	// FeatureGatesVariableName is the name of the customized variable of the AddOnDeploymentConfig overriding the
+	// states of the feature gates of the addon agent, in the format of "Name1=true,Name2=false".
+	FeatureGatesVariableName = "featureGates"
+
+	// featureGatesArg is the argument of the addon agent containers to set the feature gates.
+	featureGatesArg = "--feature-gates"
+)
+
+// GetFeatureGates returns the states of the feature gates overridden by the featureGates customized variable of
+// the AddOnDeploymentConfig. Only the gates in the defaults can be overridden, an unknown gate is an error.
+func GetFeatureGates(defaults agent.FeatureGates, config addonapiv1alpha1.AddOnDeploymentConfig) (agent.FeatureGates, error) {
+	gates := agent.FeatureGates{}
+	for name, enabled := range defaults {
+		gates[name] = enabled
+	}
+
+	for _, variable := range config.Spec.CustomizedVariables {
+		if variable.Name != FeatureGatesVariableName {
+			continue
+		}
+		overrides, err := agent.ParseFeatureGates(variable.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid feature gates of addondeploymentconfig %s/%s: %v", config.Namespace, config.Name, err)
+		}
+		for name, enabled := range overrides {
+			if _, ok := defaults[name]; !ok {
+				return nil, fmt.Errorf("unknown feature gate %q of addondeploymentconfig %s/%s", name, config.Namespace, config.Name)
+			}
+			gates[name] = enabled
+		}
+	}
+	return gates, nil
+}
+
+// getAddonFeatureGates returns the states of the feature gates of the addon, overridden by the
+// AddOnDeploymentConfigs in the addon status. If there are multiple AddOnDeploymentConfigs, the big index one
+// overrides the one from small index. The configs are ignored if the addon has no feature gates.
+func getAddonFeatureGates(getter AddOnDeploymentConfigGetter, defaults agent.FeatureGates,
+	addon *addonapiv1alpha1.ManagedClusterAddOn) (agent.FeatureGates, error) {
+	if len(defaults) == 0 {
+		return nil, nil
+	}
+
+	gates := defaults
+	for _, config := range utils.GetDesiredConfigsOf(addon, utils.AddOnDeploymentConfigGroupResource) {
+		addOnDeploymentConfig, err := getter.Get(context.Background(), config.Namespace, config.Name)
+		if err != nil {
+			return nil, err
+		}
+		gates, err = GetFeatureGates(gates, *addOnDeploymentConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return gates, nil
+}
+
+// GetFeatureGatesValues returns a GetValuesFunc exposing the states of the feature gates of the addon as the
+// template values, the defaults are usually the FeatureGates of the AgentAddonOptions. For example, the feature
+// gates {Foo: true, Bar: false} are transformed into the Values:
+// map[FeatureGates:map[Bar:false Foo:true] FeatureGatesArg:Bar=false,Foo=true]
+func GetFeatureGatesValues(getter AddOnDeploymentConfigGetter, defaults agent.FeatureGates) GetValuesFunc {
+	return func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) (Values, error) {
+		gates, err := getAddonFeatureGates(getter, defaults, addon)
+		if err != nil || len(gates) == 0 {
+			return nil, err
+		}
+
+		states := map[string]interface{}{}
+		for name, enabled := range gates {
+			states[name] = enabled
+		}
+		return Values{
+			"FeatureGates":    states,
+			"FeatureGatesArg": gates.String(),
+		}, nil
+	}
+}
+
+// NewFeatureGatesMutator returns a manifest mutator to set the --feature-gates argument of the containers of the
+// Deployments in the manifests to the states of the feature gates of the addon. If the container names are
+// given, the argument is set on the containers with the names, otherwise it is only replaced on the containers
+// which have the argument already.
+func NewFeatureGatesMutator(getter AddOnDeploymentConfigGetter, defaults agent.FeatureGates,
+	containerNames ...string) ManifestMutatorFunc {
+	return func(cluster *clusterv1.ManagedCluster,
+		addon *addonapiv1alpha1.ManagedClusterAddOn, objects []runtime.Object) ([]runtime.Object, error) {
+		gates, err := getAddonFeatureGates(getter, defaults, addon)
+		if err != nil || len(gates) == 0 {
+			return objects, err
+		}
+
+		arg := fmt.Sprintf("%s=%s", featureGatesArg, gates.String())
+		mutated := make([]runtime.Object, 0, len(objects))
+		for _, obj := range objects {
+			mutatedObj, err := utils.MutateWorkload(obj, func(kind string, _ metav1.Object, podSpec *corev1.PodSpec) {
+				if kind != "deployments" {
+					return
+				}
+				for i := range podSpec.Containers {
+					container := &podSpec.Containers[i]
+					if len(containerNames) > 0 && !contains(containerNames, container.Name) {
+						continue
+					}
+					container.Args = setFeatureGatesArg(container.Args, arg, len(containerNames) > 0)
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+			mutated = append(mutated, mutatedObj)
+		}
+		return mutated, nil
+	}
+}
+
+// setFeatureGatesArg replaces the --feature-gates argument in the args, the argument is appended if it is not
+// found and add is true.
+func setFeatureGatesArg(args []string, arg string, add bool) []string {
+	for i, existing := range args {
+		if existing == featureGatesArg || strings.HasPrefix(existing, featureGatesArg+"=") {
+			args[i] = arg
+			// the value of the argument in the form of "--feature-gates value" is dropped
+			if existing == featureGatesArg && i+1 < len(args) {
+				args = append(args[:i+1], args[i+2:]...)
+			}
+			return args
+		}
+	}
+	if add {
+		args = append(args, arg)
+	}
+	return args
+}
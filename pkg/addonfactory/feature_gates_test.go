@@ -0,0 +1,189 @@
+package addonfactory
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+)
+
+func newFeatureGatesAddon(configNames ...string) *addonapiv1alpha1.ManagedClusterAddOn {
+	addon := addontesting.NewAddon("test", "cluster1")
+	for _, name := range configNames {
+		addon.Status.ConfigReferences = append(addon.Status.ConfigReferences, addonapiv1alpha1.ConfigReference{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Group:    AddOnDeploymentConfigGVR.Group,
+				Resource: AddOnDeploymentConfigGVR.Resource,
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: name},
+		})
+	}
+	return addon
+}
+
+func TestGetFeatureGatesValues(t *testing.T) {
+	defaults := agent.FeatureGates{"Foo": false, "Bar": true}
+
+	cases := []struct {
+		name           string
+		configs        []runtime.Object
+		configNames    []string
+		defaults       agent.FeatureGates
+		expectedValues Values
+		expectErr      bool
+	}{
+		{
+			name: "no feature gates",
+		},
+		{
+			name:     "default feature gates",
+			defaults: defaults,
+			expectedValues: Values{
+				"FeatureGates":    map[string]interface{}{"Foo": false, "Bar": true},
+				"FeatureGatesArg": "Bar=true,Foo=false",
+			},
+		},
+		{
+			name: "feature gates overridden by the configs",
+			configs: []runtime.Object{
+				addontesting.NewAddOnDeploymentConfig("config1", "cluster1").
+					WithCustomizedVariable(FeatureGatesVariableName, "Foo=true,Bar=false").Build(),
+				addontesting.NewAddOnDeploymentConfig("config2", "cluster1").
+					WithCustomizedVariable(FeatureGatesVariableName, "Bar=true").Build(),
+			},
+			configNames: []string{"config1", "config2"},
+			defaults:    defaults,
+			expectedValues: Values{
+				"FeatureGates":    map[string]interface{}{"Foo": true, "Bar": true},
+				"FeatureGatesArg": "Bar=true,Foo=true",
+			},
+		},
+		{
+			name: "unknown feature gate",
+			configs: []runtime.Object{
+				addontesting.NewAddOnDeploymentConfig("config1", "cluster1").
+					WithCustomizedVariable(FeatureGatesVariableName, "Baz=true").Build(),
+			},
+			configNames: []string{"config1"},
+			defaults:    defaults,
+			expectErr:   true,
+		},
+		{
+			name: "invalid feature gates",
+			configs: []runtime.Object{
+				addontesting.NewAddOnDeploymentConfig("config1", "cluster1").
+					WithCustomizedVariable(FeatureGatesVariableName, "Foo").Build(),
+			},
+			configNames: []string{"config1"},
+			defaults:    defaults,
+			expectErr:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			getter := NewAddOnDeploymentConfigGetter(fakeaddon.NewSimpleClientset(c.configs...))
+			values, err := GetFeatureGatesValues(getter, c.defaults)(
+				addontesting.NewManagedCluster("cluster1"), newFeatureGatesAddon(c.configNames...))
+			if c.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectErr, err)
+			}
+			if !reflect.DeepEqual(values, c.expectedValues) {
+				t.Errorf("expected values %v, but got %v", c.expectedValues, values)
+			}
+		})
+	}
+}
+
+func TestFeatureGatesMutator(t *testing.T) {
+	newDeployment := func(containers ...corev1.Container) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			TypeMeta:   metav1.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "addon-ns"},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: containers}},
+			},
+		}
+	}
+	config := addontesting.NewAddOnDeploymentConfig("config", "cluster1").
+		WithCustomizedVariable(FeatureGatesVariableName, "Foo=true").Build()
+
+	cases := []struct {
+		name           string
+		defaults       agent.FeatureGates
+		containerNames []string
+		containers     []corev1.Container
+		expectedArgs   map[string][]string
+	}{
+		{
+			name:       "no feature gates",
+			containers: []corev1.Container{{Name: "agent", Args: []string{"--feature-gates=Foo=false"}}},
+			expectedArgs: map[string][]string{
+				"agent": {"--feature-gates=Foo=false"},
+			},
+		},
+		{
+			name:     "replace the existing argument",
+			defaults: agent.FeatureGates{"Foo": false},
+			containers: []corev1.Container{
+				{Name: "agent", Args: []string{"agent", "--feature-gates=Foo=false", "--v=2"}},
+				{Name: "sidecar", Args: []string{"sidecar"}},
+			},
+			expectedArgs: map[string][]string{
+				"agent":   {"agent", "--feature-gates=Foo=true", "--v=2"},
+				"sidecar": {"sidecar"},
+			},
+		},
+		{
+			name:     "replace the existing separated argument",
+			defaults: agent.FeatureGates{"Foo": false},
+			containers: []corev1.Container{
+				{Name: "agent", Args: []string{"--feature-gates", "Foo=false", "--v=2"}},
+			},
+			expectedArgs: map[string][]string{
+				"agent": {"--feature-gates=Foo=true", "--v=2"},
+			},
+		},
+		{
+			name:           "add the argument to the named containers",
+			defaults:       agent.FeatureGates{"Foo": false, "Bar": false},
+			containerNames: []string{"agent"},
+			containers: []corev1.Container{
+				{Name: "agent", Args: []string{"agent"}},
+				{Name: "sidecar", Args: []string{"sidecar", "--feature-gates=Other=true"}},
+			},
+			expectedArgs: map[string][]string{
+				"agent":   {"agent", "--feature-gates=Bar=false,Foo=true"},
+				"sidecar": {"sidecar", "--feature-gates=Other=true"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			getter := NewAddOnDeploymentConfigGetter(fakeaddon.NewSimpleClientset(config))
+			mutator := NewFeatureGatesMutator(getter, c.defaults, c.containerNames...)
+			objects, err := mutator(addontesting.NewManagedCluster("cluster1"), newFeatureGatesAddon("config"),
+				[]runtime.Object{newDeployment(c.containers...)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			deployment := objects[0].(*appsv1.Deployment)
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				if !reflect.DeepEqual(container.Args, c.expectedArgs[container.Name]) {
+					t.Errorf("expected args %v of container %s, but got %v",
+						c.expectedArgs[container.Name], container.Name, container.Args)
+				}
+			}
+		})
+	}
+}
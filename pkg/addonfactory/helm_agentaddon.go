@@ -1,9 +1,7 @@
 package addonfactory
 
 import (
-	"bufio"
 	"fmt"
-	"io"
 	"sort"
 	"strings"
 
@@ -12,7 +10,6 @@ import (
 	"helm.sh/helm/v3/pkg/engine"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
-	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/klog/v2"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
@@ -27,9 +24,12 @@ import (
 type helmBuiltinValues struct {
 	ClusterName             string `json:"clusterName"`
 	AddonInstallNamespace   string `json:"addonInstallNamespace"`
+	AgentInstallNamespace   string `json:"agentInstallNamespace"`
 	HubKubeConfigSecret     string `json:"hubKubeConfigSecret,omitempty"`
 	ManagedKubeConfigSecret string `json:"managedKubeConfigSecret,omitempty"`
 	InstallMode             string `json:"installMode"`
+	// ClusterCapabilities are the capabilities of the managed cluster.
+	ClusterCapabilities ClusterCapabilities `json:"clusterCapabilities"`
 }
 
 // helmDefaultValues includes the default values for helm agentAddon.
@@ -45,19 +45,30 @@ type HelmAgentAddon struct {
 	decoder            runtime.Decoder
 	chart              *chart.Chart
 	getValuesFuncs     []GetValuesFunc
+	manifestMutators   []ManifestMutatorFunc
+	manifestFilters    []ManifestFilterFunc
 	agentAddonOptions  agent.AgentAddonOptions
 	trimCRDDescription bool
 	hostingCluster     *clusterv1.ManagedCluster
+	// valuesMergePolicy and valuesListMergeKeys are how the values of the getValuesFuncs are merged.
+	valuesMergePolicy   ValuesMergePolicy
+	valuesListMergeKeys []string
+	valuesSchema        []byte
 }
 
 func newHelmAgentAddon(factory *AgentAddonFactory, chart *chart.Chart) *HelmAgentAddon {
 	return &HelmAgentAddon{
-		decoder:            serializer.NewCodecFactory(factory.scheme).UniversalDeserializer(),
-		chart:              chart,
-		getValuesFuncs:     factory.getValuesFuncs,
-		agentAddonOptions:  factory.agentAddonOptions,
-		trimCRDDescription: factory.trimCRDDescription,
-		hostingCluster:     factory.hostingCluster,
+		decoder:             serializer.NewCodecFactory(factory.scheme).UniversalDeserializer(),
+		chart:               chart,
+		getValuesFuncs:      factory.getValuesFuncs,
+		manifestMutators:    factory.manifestMutators,
+		manifestFilters:     factory.manifestFilters,
+		agentAddonOptions:   factory.agentAddonOptions,
+		trimCRDDescription:  factory.trimCRDDescription,
+		hostingCluster:      factory.hostingCluster,
+		valuesMergePolicy:   factory.valuesMergePolicy,
+		valuesListMergeKeys: factory.valuesListMergeKeys,
+		valuesSchema:        factory.valuesSchema,
 	}
 }
 
@@ -76,14 +87,16 @@ func (a *HelmAgentAddon) Manifests(
 		LintMode: false,
 	}
 
+	excluded := excludedFiles(a.manifestFilters, cluster)
+	decoder := newManifestDecoder(a.decoder)
 	crds := a.chart.CRDObjects()
 	for _, crd := range crds {
-		klog.V(4).Infof("%v/n", crd.File.Data)
-		object, _, err := a.decoder.Decode(crd.File.Data, nil, nil)
-		if err != nil {
-			return nil, err
+		if fileExcluded(excluded, chartRelativePath(crd.Filename)) {
+			klog.V(4).Infof("crd %s is excluded on cluster %s", crd.Filename, cluster.Name)
+			continue
 		}
-		objects = append(objects, object)
+		klog.V(4).Infof("%v/n", crd.File.Data)
+		decoder.decode(crd.Filename, crd.File.Data)
 	}
 
 	templates, err := helmEngine.Render(a.chart, values)
@@ -104,38 +117,22 @@ func (a *HelmAgentAddon) Manifests(
 		if len(data) == 0 {
 			continue
 		}
-		klog.V(4).Infof("rendered template: %v", data)
-
-		yamlReader := yaml.NewYAMLReader(bufio.NewReader(strings.NewReader(data)))
-		for {
-			b, err := yamlReader.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				return nil, err
-			}
-			if len(b) != 0 {
-				object, _, err := a.decoder.Decode(b, nil, nil)
-				if err != nil {
-					// In some conditions, resources will be provide by other hub-side components.
-					// Example case: https://github.com/open-cluster-management-io/addon-framework/pull/72
-					if runtime.IsMissingKind(err) {
-						klog.V(4).Infof("Skipping template %v, reason: %v", k, err)
-						continue
-					}
-					return nil, err
-				}
-				objects = append(objects, object)
-			}
+		if fileExcluded(excluded, chartRelativePath(k)) {
+			klog.V(4).Infof("template %s is excluded on cluster %s", k, cluster.Name)
+			continue
 		}
-
+		klog.V(4).Infof("rendered template: %v", data)
+		decoder.decode(k, []byte(data))
+	}
+	objects, err = decoder.result()
+	if err != nil {
+		return nil, err
 	}
 
 	if a.trimCRDDescription {
 		objects = trimCRDDescription(objects)
 	}
-	return objects, nil
+	return mutateManifests(a.manifestMutators, cluster, addon, objects)
 }
 
 func (a *HelmAgentAddon) GetAgentAddonOptions() agent.AgentAddonOptions {
@@ -154,17 +151,10 @@ func (a *HelmAgentAddon) getValues(
 	}
 	overrideValues = MergeValues(overrideValues, defaultValues)
 
-	for i := 0; i < len(a.getValuesFuncs); i++ {
-		if a.getValuesFuncs[i] != nil {
-			userValues, err := a.getValuesFuncs[i](cluster, addon)
-			if err != nil {
-				return overrideValues, err
-			}
-
-			klog.V(4).Infof("index=%d, user values: %v", i, userValues)
-			overrideValues = MergeValues(overrideValues, userValues)
-			klog.V(4).Infof("index=%d, override values: %v", i, overrideValues)
-		}
+	overrideValues, err = mergeGetValues(overrideValues, cluster, addon,
+		a.getValuesFuncs, a.valuesMergePolicy, a.valuesListMergeKeys)
+	if err != nil {
+		return nil, err
 	}
 
 	builtinValues, err := a.getBuiltinValues(cluster, addon)
@@ -175,8 +165,26 @@ func (a *HelmAgentAddon) getValues(
 
 	overrideValues = MergeValues(overrideValues, builtinValues)
 
+	releaseOptions, err := a.releaseOptions(cluster, addon)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate the values coalesced with the default values of the chart, as helm does in rendering.
+	schema := a.valuesSchema
+	if len(schema) == 0 {
+		schema = a.chart.Schema
+	}
+	coalescedValues, err := chartutil.CoalesceValues(a.chart, overrideValues)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateValues(schema, Values(coalescedValues)); err != nil {
+		return nil, err
+	}
+
 	values, err := chartutil.ToRenderValues(a.chart, overrideValues,
-		a.releaseOptions(cluster, addon), a.capabilities(cluster, addon))
+		releaseOptions, a.capabilities(cluster, addon))
 	if err != nil {
 		klog.Errorf("failed to render helm chart with values %v. err:%v", overrideValues, err)
 		return values, err
@@ -191,13 +199,15 @@ func (a *HelmAgentAddon) getBuiltinValues(
 	builtinValues := helmBuiltinValues{}
 	builtinValues.ClusterName = cluster.GetName()
 
-	installNamespace := addon.Spec.InstallNamespace
-	if len(installNamespace) == 0 {
-		installNamespace = AddonDefaultInstallNamespace
+	installNamespace, err := agentInstallNamespace(a.agentAddonOptions, addon)
+	if err != nil {
+		return nil, err
 	}
 	builtinValues.AddonInstallNamespace = installNamespace
+	builtinValues.AgentInstallNamespace = installNamespace
 
 	builtinValues.InstallMode, _ = constants.GetHostedModeInfo(addon.GetAnnotations())
+	builtinValues.ClusterCapabilities = GetClusterCapabilities(cluster)
 
 	helmBuiltinValues, err := JsonStructToValues(builtinValues)
 	if err != nil {
@@ -212,9 +222,8 @@ func (a *HelmAgentAddon) getDefaultValues(
 	addon *addonapiv1alpha1.ManagedClusterAddOn) (Values, error) {
 	defaultValues := helmDefaultValues{}
 
-	// TODO: hubKubeConfigSecret depends on the signer configuration in registration, and the registration is an array.
 	if a.agentAddonOptions.Registration != nil {
-		defaultValues.HubKubeConfigSecret = fmt.Sprintf("%s-hub-kubeconfig", a.agentAddonOptions.AddonName)
+		defaultValues.HubKubeConfigSecret = constants.HubKubeConfigSecretName(a.agentAddonOptions.AddonName)
 	}
 
 	defaultValues.ManagedKubeConfigSecret = fmt.Sprintf("%s-managed-kubeconfig", addon.Name)
@@ -243,10 +252,19 @@ func (a *HelmAgentAddon) capabilities(
 // only support Release.Name, Release.Namespace
 func (a *HelmAgentAddon) releaseOptions(
 	cluster *clusterv1.ManagedCluster,
-	addon *addonapiv1alpha1.ManagedClusterAddOn) chartutil.ReleaseOptions {
-	installNamespace := addon.Spec.InstallNamespace
-	if len(installNamespace) == 0 {
-		installNamespace = AddonDefaultInstallNamespace
+	addon *addonapiv1alpha1.ManagedClusterAddOn) (chartutil.ReleaseOptions, error) {
+	installNamespace, err := agentInstallNamespace(a.agentAddonOptions, addon)
+	if err != nil {
+		return chartutil.ReleaseOptions{}, err
+	}
+	return chartutil.ReleaseOptions{Name: a.agentAddonOptions.AddonName, Namespace: installNamespace}, nil
+}
+
+// chartRelativePath returns the path of the rendered file relative to the chart, the rendered files are prefixed
+// by the name of the chart.
+func chartRelativePath(file string) string {
+	if i := strings.Index(file, "/"); i >= 0 {
+		return file[i+1:]
 	}
-	return chartutil.ReleaseOptions{Name: a.agentAddonOptions.AddonName, Namespace: installNamespace}
+	return file
 }
@@ -12,10 +12,13 @@ import (
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/klog/v2"
 
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/agent"
 )
 
 // GetValuesFromAddonAnnotation get the values in the annotation of addon cr.
@@ -172,3 +175,35 @@ func mergeInterfaceMaps(a, b map[string]interface{}) map[string]interface{} {
 
 	return out
 }
+
+// agentInstallNamespace returns the namespace the agent of the addon is installed in. The AgentInstallNamespace
+// of the agent addon options overrides the spec.installNamespace of the addon.
+func agentInstallNamespace(options agent.AgentAddonOptions, addon *addonapiv1alpha1.ManagedClusterAddOn) (string, error) {
+	if options.AgentInstallNamespace != nil {
+		namespace, err := options.AgentInstallNamespace(addon)
+		if err != nil {
+			return "", err
+		}
+		if len(namespace) > 0 {
+			return namespace, nil
+		}
+	}
+
+	if len(addon.Spec.InstallNamespace) > 0 {
+		return addon.Spec.InstallNamespace, nil
+	}
+	return AddonDefaultInstallNamespace, nil
+}
+
+// mutateManifests calls the manifest mutators on the objects in order.
+func mutateManifests(mutators []ManifestMutatorFunc, cluster *clusterv1.ManagedCluster,
+	addon *addonapiv1alpha1.ManagedClusterAddOn, objects []runtime.Object) ([]runtime.Object, error) {
+	var err error
+	for _, mutator := range mutators {
+		objects, err = mutator(cluster, addon, objects)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
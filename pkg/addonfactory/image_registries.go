@@ -0,0 +1,40 @@
+package addonfactory
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+// NewImageRegistriesMutator returns a manifest mutator to pull the images of the containers of the workloads in
+// the manifests from the mirror registries set by the open-cluster-management.io/image-registries annotation of
+// the ManagedCluster, see utils.OverrideImage.
+func NewImageRegistriesMutator() ManifestMutatorFunc {
+	return func(cluster *clusterv1.ManagedCluster,
+		addon *addonapiv1alpha1.ManagedClusterAddOn, objects []runtime.Object) ([]runtime.Object, error) {
+		registries, err := utils.GetImageRegistries(cluster)
+		if err != nil || registries == nil || len(registries.Registries) == 0 {
+			return objects, err
+		}
+
+		mutated := make([]runtime.Object, 0, len(objects))
+		for _, obj := range objects {
+			mutatedObj, err := utils.MutateWorkload(obj, func(_ string, _ metav1.Object, podSpec *corev1.PodSpec) {
+				for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+					for i := range containers {
+						containers[i].Image = utils.OverrideImage(registries.Registries, containers[i].Image)
+					}
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+			mutated = append(mutated, mutatedObj)
+		}
+		return mutated, nil
+	}
+}
@@ -0,0 +1,91 @@
+package addonfactory
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func newImageRegistriesDeployment() *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "open-cluster-management-agent-addon"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: "init", Image: "quay.io/ocm/init:v1"}},
+					Containers: []corev1.Container{
+						{Name: "agent", Image: "quay.io/ocm/agent:v1"},
+						{Name: "sidecar", Image: "docker.io/library/busybox"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestImageRegistriesMutator(t *testing.T) {
+	cases := []struct {
+		name           string
+		annotations    map[string]string
+		expectedImages []string
+		expectErr      bool
+	}{
+		{
+			name:           "no image registries",
+			expectedImages: []string{"quay.io/ocm/init:v1", "quay.io/ocm/agent:v1", "docker.io/library/busybox"},
+		},
+		{
+			name: "invalid image registries",
+			annotations: map[string]string{
+				constants.ClusterImageRegistriesAnnotationKey: "invalid",
+			},
+			expectErr: true,
+		},
+		{
+			name: "images overridden",
+			annotations: map[string]string{
+				constants.ClusterImageRegistriesAnnotationKey: `{"registries":[{"mirror":"mirror.io/ocm","source":"quay.io/ocm"}]}`,
+			},
+			expectedImages: []string{"mirror.io/ocm/init:v1", "mirror.io/ocm/agent:v1", "docker.io/library/busybox"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cluster := addontesting.SetManagedClusterAnnotation(addontesting.NewManagedCluster("cluster1"), c.annotations)
+			addon := addontesting.NewAddon("test", "cluster1")
+			objects := []runtime.Object{newImageRegistriesDeployment(), &corev1.ConfigMap{}}
+
+			mutated, err := NewImageRegistriesMutator()(cluster, addon, objects)
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("expected error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(mutated) != len(objects) {
+				t.Fatalf("expected %d objects, but got %d", len(objects), len(mutated))
+			}
+
+			podSpec := mutated[0].(*appsv1.Deployment).Spec.Template.Spec
+			var images []string
+			for _, container := range append(podSpec.InitContainers, podSpec.Containers...) {
+				images = append(images, container.Image)
+			}
+			for i := range c.expectedImages {
+				if images[i] != c.expectedImages[i] {
+					t.Errorf("expected image %q, but got %q", c.expectedImages[i], images[i])
+				}
+			}
+		})
+	}
+}
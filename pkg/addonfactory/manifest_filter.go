@@ -0,0 +1,39 @@
+package addonfactory
+
+import (
+	"path"
+	"strings"
+
+	"k8s.io/klog/v2"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+// ManifestFilterFunc returns the files of the manifests which are not rendered for the managed cluster, ex: a
+// PodSecurityPolicy on the clusters whose kubernetes version is 1.25 or newer. The files are the paths relative to
+// the dir of the factory for the template agentAddon, and relative to the chart for the helm agentAddon, ex:
+// templates/psp.yaml. They are path.Match patterns, ex: templates/openshift/*.
+type ManifestFilterFunc func(cluster *clusterv1.ManagedCluster) []string
+
+// excludedFiles returns the files excluded by the manifest filters for the cluster.
+func excludedFiles(filters []ManifestFilterFunc, cluster *clusterv1.ManagedCluster) []string {
+	var excluded []string
+	for _, filter := range filters {
+		excluded = append(excluded, filter(cluster)...)
+	}
+	return excluded
+}
+
+// fileExcluded returns true if the file matches any of the excluded files.
+func fileExcluded(excluded []string, file string) bool {
+	for _, pattern := range excluded {
+		matched, err := path.Match(strings.TrimPrefix(pattern, "/"), file)
+		if err != nil {
+			klog.Warningf("Invalid pattern %q of the excluded manifests: %v", pattern, err)
+			continue
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package addonfactory
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	worklisterv1 "open-cluster-management.io/api/client/work/listers/work/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+// NewMinAgentVersionMutator returns a manifest mutator to hold the manifests selected by the gated func until the
+// agent reports the minVersion or a newer version. It enables two-phase rollouts: the agent is upgraded first
+// with the manifests which are not gated, then the gated manifests (e.g. the CRDs of a new feature) are rendered
+// automatically once the agent reports the required version. The manifests are re-rendered when the status
+// of the addon ManifestWorks changes, so the agentVersion func is usually utils.AgentVersionFromWorkFeedbackFunc.
+//
+// While the version is not reported yet, e.g. the agent is restarted or the status feedback of a new work is not
+// synced, the gated manifests already in the ManifestWorks of the addon, read from the workLister, are kept, so
+// they are not deleted from the managed cluster, e.g. the CRDs with the custom resources of the users.
+func NewMinAgentVersionMutator(workLister worklisterv1.ManifestWorkLister, agentVersion utils.AgentVersionFunc,
+	minVersion string, gated func(obj runtime.Object) bool) ManifestMutatorFunc {
+	return func(cluster *clusterv1.ManagedCluster,
+		addon *addonapiv1alpha1.ManagedClusterAddOn, objects []runtime.Object) ([]runtime.Object, error) {
+		version, err := agentVersion(addon)
+		if err != nil {
+			return nil, err
+		}
+		ready, err := utils.AgentVersionAtLeast(version, minVersion)
+		if err != nil {
+			return nil, err
+		}
+		if ready {
+			return objects, nil
+		}
+
+		applied := map[manifestKey]bool{}
+		if len(version) == 0 {
+			if applied, err = appliedManifests(workLister, addon); err != nil {
+				return nil, err
+			}
+		}
+
+		allowed := make([]runtime.Object, 0, len(objects))
+		for _, obj := range objects {
+			if gated(obj) && !applied[objectManifestKey(obj)] {
+				continue
+			}
+			allowed = append(allowed, obj)
+		}
+		if len(allowed) < len(objects) {
+			klog.V(4).Infof("%d manifests of addon %s/%s are held until the agent version %q is at least %q",
+				len(objects)-len(allowed), addon.Namespace, addon.Name, version, minVersion)
+		}
+		return allowed, nil
+	}
+}
+
+// manifestKey identifies a manifest by its group, kind, namespace and name.
+type manifestKey struct {
+	schema.GroupKind
+	namespace, name string
+}
+
+func objectManifestKey(obj runtime.Object) manifestKey {
+	key := manifestKey{GroupKind: obj.GetObjectKind().GroupVersionKind().GroupKind()}
+	if accessor, err := meta.Accessor(obj); err == nil {
+		key.namespace, key.name = accessor.GetNamespace(), accessor.GetName()
+	}
+	return key
+}
+
+// appliedManifests returns the keys of the manifests in the ManifestWorks of the addon.
+func appliedManifests(workLister worklisterv1.ManifestWorkLister,
+	addon *addonapiv1alpha1.ManagedClusterAddOn) (map[manifestKey]bool, error) {
+	works, err := utils.AddonManifestWorks(workLister, addon)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[manifestKey]bool{}
+	for _, work := range works {
+		for _, manifest := range work.Spec.Workload.Manifests {
+			obj := &metav1.PartialObjectMetadata{}
+			if err := json.Unmarshal(manifest.Raw, obj); err != nil {
+				klog.Warningf("failed to decode a manifest of work %s/%s: %v", work.Namespace, work.Name, err)
+				continue
+			}
+			applied[manifestKey{
+				GroupKind: obj.GroupVersionKind().GroupKind(),
+				namespace: obj.Namespace,
+				name:      obj.Name,
+			}] = true
+		}
+	}
+	return applied, nil
+}
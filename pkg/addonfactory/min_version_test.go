@@ -0,0 +1,122 @@
+package addonfactory
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakework "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+)
+
+func TestMinAgentVersionMutator(t *testing.T) {
+	gated := func(obj runtime.Object) bool {
+		return obj.GetObjectKind().GroupVersionKind().Kind == "CustomResourceDefinition"
+	}
+	newWork := func(addonName string) *workapiv1.ManifestWork {
+		work := addontesting.NewManifestWork("addon-"+addonName+"-deploy-0", "cluster1",
+			addontesting.NewUnstructured("apps/v1", "Deployment", "addon-ns", "agent"),
+			addontesting.NewUnstructured("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "foos.test"))
+		work.Labels = map[string]string{addonapiv1alpha1.AddonLabelKey: addonName}
+		return work
+	}
+
+	cases := []struct {
+		name          string
+		version       string
+		versionErr    error
+		minVersion    string
+		works         []*workapiv1.ManifestWork
+		expectErr     bool
+		expectedKinds []string
+	}{
+		{
+			name:       "failed to get version",
+			versionErr: fmt.Errorf("failed"),
+			minVersion: "v1.0.0",
+			expectErr:  true,
+		},
+		{
+			name:       "invalid min version",
+			version:    "v1.0.0",
+			minVersion: "foo",
+			expectErr:  true,
+		},
+		{
+			name:          "version is not reported",
+			minVersion:    "v1.0.0",
+			expectedKinds: []string{"Deployment"},
+		},
+		{
+			name:          "version is not reported and the gated manifests are applied",
+			minVersion:    "v1.0.0",
+			works:         []*workapiv1.ManifestWork{newWork("test")},
+			expectedKinds: []string{"Deployment", "CustomResourceDefinition"},
+		},
+		{
+			name:          "version is not reported and the gated manifests are applied by another addon",
+			minVersion:    "v1.0.0",
+			works:         []*workapiv1.ManifestWork{newWork("other")},
+			expectedKinds: []string{"Deployment"},
+		},
+		{
+			name:          "agent is older than the applied gated manifests",
+			version:       "v0.9.0",
+			minVersion:    "v1.0.0",
+			works:         []*workapiv1.ManifestWork{newWork("test")},
+			expectedKinds: []string{"Deployment"},
+		},
+		{
+			name:          "agent is older",
+			version:       "v0.9.0",
+			minVersion:    "v1.0.0",
+			expectedKinds: []string{"Deployment"},
+		},
+		{
+			name:          "agent is at the min version",
+			version:       "v1.0.0",
+			minVersion:    "v1.0.0",
+			expectedKinds: []string{"Deployment", "CustomResourceDefinition"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			workInformers := workinformers.NewSharedInformerFactory(fakework.NewSimpleClientset(), 10*time.Minute)
+			for _, work := range c.works {
+				if err := workInformers.Work().V1().ManifestWorks().Informer().GetStore().Add(work); err != nil {
+					t.Fatal(err)
+				}
+			}
+			mutator := NewMinAgentVersionMutator(workInformers.Work().V1().ManifestWorks().Lister(), func(addon *addonapiv1alpha1.ManagedClusterAddOn) (string, error) {
+				return c.version, c.versionErr
+			}, c.minVersion, gated)
+
+			objects, err := mutator(addontesting.NewManagedCluster("cluster1"), addontesting.NewAddon("test", "cluster1"),
+				[]runtime.Object{
+					addontesting.NewUnstructured("apps/v1", "Deployment", "addon-ns", "agent"),
+					addontesting.NewUnstructured("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "foos.test"),
+				})
+			if c.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectErr, err)
+			}
+			if c.expectErr {
+				return
+			}
+
+			if len(objects) != len(c.expectedKinds) {
+				t.Fatalf("expected %d objects, but got %d", len(c.expectedKinds), len(objects))
+			}
+			for i, obj := range objects {
+				if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != c.expectedKinds[i] {
+					t.Errorf("expected kind %s, but got %s", c.expectedKinds[i], kind)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package addonfactory
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+const (
+	// ProxyCABundleVolumeName is the name of the volume of the proxy CA bundle ConfigMap mounted by the
+	// proxy config mutator.
+	ProxyCABundleVolumeName = "proxy-ca-bundle"
+
+	// ProxyCABundleMountPath is the path the proxy CA bundle is mounted at, the CA bundle file is
+	// "/etc/proxy-ca-bundle/ca-bundle.crt".
+	ProxyCABundleMountPath = "/etc/proxy-ca-bundle"
+
+	// ProxyCABundleKey is the key of the CA bundle in the proxy CA bundle ConfigMap.
+	ProxyCABundleKey = "ca-bundle.crt"
+)
+
+// ProxyCABundleConfigMapName returns the name of the ConfigMap of the proxy CA bundle of the addon.
+func ProxyCABundleConfigMapName(addonName string) string {
+	return fmt.Sprintf("%s-proxy-ca-bundle", addonName)
+}
+
+// NewProxyConfigMutator returns a manifest mutator to inject the proxy config of the AddOnDeploymentConfigs
+// in the addon status into the workloads of the manifests. The HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY env vars are set on all the containers. If the CA bundle is set, a ConfigMap with the CA bundle is
+// added in the namespace of each workload, and mounted at ProxyCABundleMountPath in all the containers.
+// If there are multiple AddOnDeploymentConfigs, the big index one overrides the one from small index.
+func NewProxyConfigMutator(getter AddOnDeploymentConfigGetter) ManifestMutatorFunc {
+	return func(cluster *clusterv1.ManagedCluster,
+		addon *addonapiv1alpha1.ManagedClusterAddOn, objects []runtime.Object) ([]runtime.Object, error) {
+		var proxyConfig *ProxyConfig
+		for _, config := range utils.GetDesiredConfigsOf(addon, utils.AddOnDeploymentConfigGroupResource) {
+			addOnDeploymentConfig, err := getter.Get(context.Background(), config.Namespace, config.Name)
+			if err != nil {
+				return nil, err
+			}
+			current, err := GetProxyConfig(*addOnDeploymentConfig)
+			if err != nil {
+				return nil, err
+			}
+			if current != nil {
+				proxyConfig = current
+			}
+		}
+		if proxyConfig == nil {
+			return objects, nil
+		}
+
+		configMapName := ProxyCABundleConfigMapName(addon.Name)
+		caBundleNamespaces := []string{}
+		mutated := make([]runtime.Object, 0, len(objects))
+		for _, obj := range objects {
+			mutatedObj, err := utils.MutateWorkload(obj, func(_ string, workload metav1.Object, podSpec *corev1.PodSpec) {
+				injectPodProxyConfig(podSpec, proxyConfig, configMapName)
+				namespace := workload.GetNamespace()
+				if len(proxyConfig.CABundle) > 0 && !contains(caBundleNamespaces, namespace) {
+					caBundleNamespaces = append(caBundleNamespaces, namespace)
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+			mutated = append(mutated, mutatedObj)
+		}
+
+		for _, namespace := range caBundleNamespaces {
+			mutated = append(mutated, &corev1.ConfigMap{
+				TypeMeta: metav1.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      configMapName,
+					Namespace: namespace,
+				},
+				Data: map[string]string{ProxyCABundleKey: string(proxyConfig.CABundle)},
+			})
+		}
+		return mutated, nil
+	}
+}
+
+func injectPodProxyConfig(podSpec *corev1.PodSpec, proxyConfig *ProxyConfig, configMapName string) {
+	envs := []corev1.EnvVar{}
+	if len(proxyConfig.HTTPProxy) > 0 {
+		envs = append(envs, corev1.EnvVar{Name: "HTTP_PROXY", Value: proxyConfig.HTTPProxy})
+	}
+	if len(proxyConfig.HTTPSProxy) > 0 {
+		envs = append(envs, corev1.EnvVar{Name: "HTTPS_PROXY", Value: proxyConfig.HTTPSProxy})
+	}
+	if len(proxyConfig.NoProxy) > 0 {
+		envs = append(envs, corev1.EnvVar{Name: "NO_PROXY", Value: proxyConfig.NoProxy})
+	}
+
+	mountCABundle := len(proxyConfig.CABundle) > 0
+	if mountCABundle {
+		podSpec.Volumes = setVolume(podSpec.Volumes, corev1.Volume{
+			Name: ProxyCABundleVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: configMapName},
+				},
+			},
+		})
+	}
+
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			for _, env := range envs {
+				containers[i].Env = setEnv(containers[i].Env, env)
+			}
+			if mountCABundle {
+				containers[i].VolumeMounts = setVolumeMount(containers[i].VolumeMounts, corev1.VolumeMount{
+					Name:      ProxyCABundleVolumeName,
+					MountPath: ProxyCABundleMountPath,
+					ReadOnly:  true,
+				})
+			}
+		}
+	}
+}
+
+func setEnv(envs []corev1.EnvVar, env corev1.EnvVar) []corev1.EnvVar {
+	for i := range envs {
+		if envs[i].Name == env.Name {
+			envs[i] = env
+			return envs
+		}
+	}
+	return append(envs, env)
+}
+
+func setVolume(volumes []corev1.Volume, volume corev1.Volume) []corev1.Volume {
+	for i := range volumes {
+		if volumes[i].Name == volume.Name {
+			volumes[i] = volume
+			return volumes
+		}
+	}
+	return append(volumes, volume)
+}
+
+func setVolumeMount(mounts []corev1.VolumeMount, mount corev1.VolumeMount) []corev1.VolumeMount {
+	for i := range mounts {
+		if mounts[i].Name == mount.Name {
+			mounts[i] = mount
+			return mounts
+		}
+	}
+	return append(mounts, mount)
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package addonfactory
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func TestProxyConfigMutator(t *testing.T) {
+	newDeployment := func() *appsv1.Deployment {
+		return &appsv1.Deployment{
+			TypeMeta:   metav1.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "addon-ns"},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: "agent",
+							Env:  []corev1.EnvVar{{Name: "HTTP_PROXY", Value: "http://old"}},
+						}},
+					},
+				},
+			},
+		}
+	}
+	newDaemonSet := func() *appsv1.DaemonSet {
+		return &appsv1.DaemonSet{
+			TypeMeta:   metav1.TypeMeta{Kind: "DaemonSet", APIVersion: "apps/v1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "addon-ns"},
+			Spec: appsv1.DaemonSetSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						InitContainers: []corev1.Container{{Name: "init"}},
+						Containers:     []corev1.Container{{Name: "agent"}},
+					},
+				},
+			},
+		}
+	}
+	newUnstructuredDeployment := func() *unstructured.Unstructured {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(newDeployment())
+		if err != nil {
+			t.Fatal(err)
+		}
+		deployment := &unstructured.Unstructured{Object: content}
+		deployment.SetNamespace("other-ns")
+		return deployment
+	}
+	newConfig := func(proxyConfig string) *addonapiv1alpha1.AddOnDeploymentConfig {
+		config := &addonapiv1alpha1.AddOnDeploymentConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "cluster1"},
+		}
+		if len(proxyConfig) > 0 {
+			config.Annotations = map[string]string{constants.ProxyConfigAnnotationKey: proxyConfig}
+		}
+		return config
+	}
+
+	cases := []struct {
+		name                 string
+		config               *addonapiv1alpha1.AddOnDeploymentConfig
+		objects              []runtime.Object
+		expectErr            bool
+		expectedEnvs         []corev1.EnvVar
+		expectedCABundleNSes []string
+	}{
+		{
+			name:    "no proxy config",
+			config:  newConfig(""),
+			objects: []runtime.Object{newDeployment()},
+			expectedEnvs: []corev1.EnvVar{
+				{Name: "HTTP_PROXY", Value: "http://old"},
+			},
+		},
+		{
+			name:      "invalid proxy config",
+			config:    newConfig("{"),
+			objects:   []runtime.Object{newDeployment()},
+			expectErr: true,
+		},
+		{
+			name:    "proxy without ca bundle",
+			config:  newConfig(`{"httpProxy":"http://proxy","noProxy":"localhost"}`),
+			objects: []runtime.Object{newDeployment(), newDaemonSet(), addontesting.NewUnstructured("v1", "ConfigMap", "addon-ns", "test")},
+			expectedEnvs: []corev1.EnvVar{
+				{Name: "HTTP_PROXY", Value: "http://proxy"},
+				{Name: "NO_PROXY", Value: "localhost"},
+			},
+		},
+		{
+			name:    "proxy with ca bundle",
+			config:  newConfig(`{"httpProxy":"http://proxy","httpsProxy":"https://proxy","caBundle":"dGVzdA=="}`),
+			objects: []runtime.Object{newDeployment(), newDaemonSet(), newUnstructuredDeployment()},
+			expectedEnvs: []corev1.EnvVar{
+				{Name: "HTTP_PROXY", Value: "http://proxy"},
+				{Name: "HTTPS_PROXY", Value: "https://proxy"},
+			},
+			expectedCABundleNSes: []string{"addon-ns", "other-ns"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addon := addontesting.NewAddon("test", "cluster1")
+			addon.Status.ConfigReferences = []addonapiv1alpha1.ConfigReference{{
+				ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+					Group:    AddOnDeploymentConfigGVR.Group,
+					Resource: AddOnDeploymentConfigGVR.Resource,
+				},
+				ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config"},
+			}}
+
+			mutator := NewProxyConfigMutator(NewAddOnDeploymentConfigGetter(fakeaddon.NewSimpleClientset(c.config)))
+			objects, err := mutator(addontesting.NewManagedCluster("cluster1"), addon, c.objects)
+			if c.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectErr, err)
+			}
+			if c.expectErr {
+				return
+			}
+
+			var caBundleNSes []string
+			for _, obj := range objects {
+				var podSpec *corev1.PodSpec
+				switch o := obj.(type) {
+				case *appsv1.Deployment:
+					podSpec = &o.Spec.Template.Spec
+				case *appsv1.DaemonSet:
+					podSpec = &o.Spec.Template.Spec
+				case *unstructured.Unstructured:
+					if o.GetKind() != "Deployment" {
+						continue
+					}
+					deployment := &appsv1.Deployment{}
+					if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, deployment); err != nil {
+						t.Fatal(err)
+					}
+					podSpec = &deployment.Spec.Template.Spec
+				case *corev1.ConfigMap:
+					if o.Name != ProxyCABundleConfigMapName("test") || o.Data[ProxyCABundleKey] != "test" {
+						t.Errorf("unexpected ca bundle configmap %v", o)
+					}
+					caBundleNSes = append(caBundleNSes, o.Namespace)
+					continue
+				default:
+					continue
+				}
+
+				containers := append(podSpec.InitContainers, podSpec.Containers...)
+				for _, container := range containers {
+					if !equalEnvs(container.Env, c.expectedEnvs) {
+						t.Errorf("expected envs %v of container %s, but got %v", c.expectedEnvs, container.Name, container.Env)
+					}
+					mounted := len(container.VolumeMounts) == 1 && container.VolumeMounts[0].MountPath == ProxyCABundleMountPath
+					if mounted != (len(c.expectedCABundleNSes) > 0) {
+						t.Errorf("unexpected volume mounts of container %s: %v", container.Name, container.VolumeMounts)
+					}
+				}
+			}
+
+			if len(caBundleNSes) != len(c.expectedCABundleNSes) {
+				t.Fatalf("expected ca bundle in namespaces %v, but got %v", c.expectedCABundleNSes, caBundleNSes)
+			}
+			for i := range caBundleNSes {
+				if caBundleNSes[i] != c.expectedCABundleNSes[i] {
+					t.Errorf("expected ca bundle in namespaces %v, but got %v", c.expectedCABundleNSes, caBundleNSes)
+				}
+			}
+		})
+	}
+}
+
+func equalEnvs(actual, expected []corev1.EnvVar) bool {
+	if len(actual) != len(expected) {
+		return false
+	}
+	for i := range actual {
+		if actual[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
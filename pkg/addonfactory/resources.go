@@ -0,0 +1,122 @@
+package addonfactory
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+// containerMatcher matches the containers by the regular expressions of the containerID.
+type containerMatcher struct {
+	kind      *regexp.Regexp
+	workload  *regexp.Regexp
+	container *regexp.Regexp
+	resources corev1.ResourceRequirements
+}
+
+func newContainerMatcher(requirements ContainerResourceRequirements) (*containerMatcher, error) {
+	parts := strings.Split(requirements.ContainerID, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid containerID %q, it should be in the format of <kind>:<workload name>:<container name>",
+			requirements.ContainerID)
+	}
+
+	regexps := make([]*regexp.Regexp, 0, len(parts))
+	for _, part := range parts {
+		// match the whole value instead of a substring
+		re, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid containerID %q: %v", requirements.ContainerID, err)
+		}
+		regexps = append(regexps, re)
+	}
+
+	return &containerMatcher{
+		kind:      regexps[0],
+		workload:  regexps[1],
+		container: regexps[2],
+		resources: requirements.Resources,
+	}, nil
+}
+
+func (m *containerMatcher) match(kind, workload, container string) bool {
+	return m.kind.MatchString(kind) && m.workload.MatchString(workload) && m.container.MatchString(container)
+}
+
+// NewResourceRequirementsMutator returns a manifest mutator to override the resource requests and limits of the
+// containers of the workloads in the manifests with the resource requirements of the
+// AddOnDeploymentConfigs in the addon status. Only the resources set in the matched requirements are
+// overridden, the others of the containers are kept. If a container matches multiple requirements, they are
+// applied in order, so the big index one overrides the one from small index, and the requirements of the big
+// index AddOnDeploymentConfig override the ones from small index.
+func NewResourceRequirementsMutator(getter AddOnDeploymentConfigGetter) ManifestMutatorFunc {
+	return func(cluster *clusterv1.ManagedCluster,
+		addon *addonapiv1alpha1.ManagedClusterAddOn, objects []runtime.Object) ([]runtime.Object, error) {
+		var matchers []*containerMatcher
+		for _, config := range utils.GetDesiredConfigsOf(addon, utils.AddOnDeploymentConfigGroupResource) {
+			addOnDeploymentConfig, err := getter.Get(context.Background(), config.Namespace, config.Name)
+			if err != nil {
+				return nil, err
+			}
+			requirements, err := GetResourceRequirements(*addOnDeploymentConfig)
+			if err != nil {
+				return nil, err
+			}
+			for _, requirement := range requirements {
+				matcher, err := newContainerMatcher(requirement)
+				if err != nil {
+					return nil, fmt.Errorf("invalid resource requirements of addondeploymentconfig %s/%s: %v",
+						addOnDeploymentConfig.Namespace, addOnDeploymentConfig.Name, err)
+				}
+				matchers = append(matchers, matcher)
+			}
+		}
+		if len(matchers) == 0 {
+			return objects, nil
+		}
+
+		mutated := make([]runtime.Object, 0, len(objects))
+		for _, obj := range objects {
+			mutatedObj, err := utils.MutateWorkload(obj, func(kind string, workload metav1.Object, podSpec *corev1.PodSpec) {
+				for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+					for i := range containers {
+						for _, matcher := range matchers {
+							if matcher.match(kind, workload.GetName(), containers[i].Name) {
+								setResources(&containers[i].Resources, matcher.resources)
+							}
+						}
+					}
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+			mutated = append(mutated, mutatedObj)
+		}
+		return mutated, nil
+	}
+}
+
+func setResources(resources *corev1.ResourceRequirements, override corev1.ResourceRequirements) {
+	for name, quantity := range override.Requests {
+		if resources.Requests == nil {
+			resources.Requests = corev1.ResourceList{}
+		}
+		resources.Requests[name] = quantity
+	}
+	for name, quantity := range override.Limits {
+		if resources.Limits == nil {
+			resources.Limits = corev1.ResourceList{}
+		}
+		resources.Limits[name] = quantity
+	}
+}
@@ -0,0 +1,183 @@
+package addonfactory
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func TestResourceRequirementsMutator(t *testing.T) {
+	newDeployment := func() *appsv1.Deployment {
+		return &appsv1.Deployment{
+			TypeMeta:   metav1.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "addon-ns"},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Name: "agent",
+								Resources: corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10m")},
+								},
+							},
+							{Name: "sidecar"},
+						},
+					},
+				},
+			},
+		}
+	}
+	newUnstructuredDaemonSet := func() *unstructured.Unstructured {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&appsv1.DaemonSet{
+			TypeMeta:   metav1.TypeMeta{Kind: "DaemonSet", APIVersion: "apps/v1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "node-agent", Namespace: "addon-ns"},
+			Spec: appsv1.DaemonSetSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "agent"}}},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &unstructured.Unstructured{Object: content}
+	}
+	newConfig := func(name, requirements string) *addonapiv1alpha1.AddOnDeploymentConfig {
+		config := &addonapiv1alpha1.AddOnDeploymentConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "cluster1"},
+		}
+		if len(requirements) > 0 {
+			config.Annotations = map[string]string{constants.ResourceRequirementsAnnotationKey: requirements}
+		}
+		return config
+	}
+	// resources returns the "requests/limits" memory of the containers keyed by "<workload>/<container>"
+	resources := func(t *testing.T, objects []runtime.Object) map[string]string {
+		result := map[string]string{}
+		for _, obj := range objects {
+			var name string
+			var podSpec *corev1.PodSpec
+			switch o := obj.(type) {
+			case *appsv1.Deployment:
+				name, podSpec = o.Name, &o.Spec.Template.Spec
+			case *unstructured.Unstructured:
+				daemonSet := &appsv1.DaemonSet{}
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, daemonSet); err != nil {
+					t.Fatal(err)
+				}
+				name, podSpec = daemonSet.Name, &daemonSet.Spec.Template.Spec
+			default:
+				continue
+			}
+			for _, container := range podSpec.Containers {
+				requests, limits := container.Resources.Requests[corev1.ResourceMemory], container.Resources.Limits[corev1.ResourceMemory]
+				result[name+"/"+container.Name] = requests.String() + "/" + limits.String()
+			}
+		}
+		return result
+	}
+
+	cases := []struct {
+		name              string
+		configs           []*addonapiv1alpha1.AddOnDeploymentConfig
+		expectErr         bool
+		expectedResources map[string]string
+	}{
+		{
+			name:    "no resource requirements",
+			configs: []*addonapiv1alpha1.AddOnDeploymentConfig{newConfig("config1", "")},
+			expectedResources: map[string]string{
+				"agent/agent": "0/0", "agent/sidecar": "0/0", "node-agent/agent": "0/0",
+			},
+		},
+		{
+			name:      "invalid resource requirements",
+			configs:   []*addonapiv1alpha1.AddOnDeploymentConfig{newConfig("config1", "{")},
+			expectErr: true,
+		},
+		{
+			name:      "invalid container id",
+			configs:   []*addonapiv1alpha1.AddOnDeploymentConfig{newConfig("config1", `[{"containerID":"deployments:agent"}]`)},
+			expectErr: true,
+		},
+		{
+			name:      "invalid regular expression",
+			configs:   []*addonapiv1alpha1.AddOnDeploymentConfig{newConfig("config1", `[{"containerID":"deployments:(:agent"}]`)},
+			expectErr: true,
+		},
+		{
+			name: "override matched containers",
+			configs: []*addonapiv1alpha1.AddOnDeploymentConfig{newConfig("config1",
+				`[{"containerID":".*:.*:agent","resources":{"requests":{"memory":"64Mi"},"limits":{"memory":"256Mi"}}},
+				  {"containerID":"deployments:agent:agent","resources":{"limits":{"memory":"512Mi"}}}]`)},
+			expectedResources: map[string]string{
+				"agent/agent": "64Mi/512Mi", "agent/sidecar": "0/0", "node-agent/agent": "64Mi/256Mi",
+			},
+		},
+		{
+			name: "the big index config overrides",
+			configs: []*addonapiv1alpha1.AddOnDeploymentConfig{
+				newConfig("config1", `[{"containerID":"deployments:.*:.*","resources":{"requests":{"memory":"64Mi"}}}]`),
+				newConfig("config2", `[{"containerID":"deployments:agent:side.*","resources":{"requests":{"memory":"32Mi"}}}]`),
+			},
+			expectedResources: map[string]string{
+				"agent/agent": "64Mi/0", "agent/sidecar": "32Mi/0", "node-agent/agent": "0/0",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addon := addontesting.NewAddon("test", "cluster1")
+			configs := []runtime.Object{}
+			for _, config := range c.configs {
+				configs = append(configs, config)
+				addon.Status.ConfigReferences = append(addon.Status.ConfigReferences, addonapiv1alpha1.ConfigReference{
+					ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+						Group:    AddOnDeploymentConfigGVR.Group,
+						Resource: AddOnDeploymentConfigGVR.Resource,
+					},
+					ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: config.Namespace, Name: config.Name},
+				})
+			}
+
+			deployment := newDeployment()
+			mutator := NewResourceRequirementsMutator(NewAddOnDeploymentConfigGetter(fakeaddon.NewSimpleClientset(configs...)))
+			objects, err := mutator(addontesting.NewManagedCluster("cluster1"), addon,
+				[]runtime.Object{deployment, newUnstructuredDaemonSet()})
+			if c.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectErr, err)
+			}
+			if c.expectErr {
+				return
+			}
+
+			actual := resources(t, objects)
+			for name, expected := range c.expectedResources {
+				if actual[name] != expected {
+					t.Errorf("expected resources %q of container %s, but got %q", expected, name, actual[name])
+				}
+			}
+
+			// the existing resources are kept and the original objects are not changed
+			mutatedDeployment := objects[0].(*appsv1.Deployment)
+			if cpu := mutatedDeployment.Spec.Template.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU]; cpu.String() != "10m" {
+				t.Errorf("expected cpu request is kept, but got %s", cpu.String())
+			}
+			if _, ok := deployment.Spec.Template.Spec.Containers[0].Resources.Requests[corev1.ResourceMemory]; ok {
+				t.Errorf("expected the original deployment is not changed")
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package addonfactory
 
 import (
 	"fmt"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
@@ -20,7 +21,11 @@ import (
 type templateBuiltinValues struct {
 	ClusterName           string
 	AddonInstallNamespace string
+	// AgentInstallNamespace is the namespace the addon agent is installed in, it is the same as
+	// AddonInstallNamespace, and is overridden by the AgentInstallNamespace of the agent addon options.
+	AgentInstallNamespace string
 	InstallMode           string
+	ClusterCapabilities   ClusterCapabilities
 }
 
 // templateDefaultValues includes the default values for template agentAddon.
@@ -38,18 +43,31 @@ type templateFile struct {
 
 type TemplateAgentAddon struct {
 	decoder            runtime.Decoder
+	dir                string
 	templateFiles      []templateFile
+	manifestFilters    []ManifestFilterFunc
 	getValuesFuncs     []GetValuesFunc
+	manifestMutators   []ManifestMutatorFunc
 	agentAddonOptions  agent.AgentAddonOptions
 	trimCRDDescription bool
+	// valuesMergePolicy and valuesListMergeKeys are how the values of the getValuesFuncs are merged.
+	valuesMergePolicy   ValuesMergePolicy
+	valuesListMergeKeys []string
+	valuesSchema        []byte
 }
 
 func newTemplateAgentAddon(factory *AgentAddonFactory) *TemplateAgentAddon {
 	return &TemplateAgentAddon{
-		decoder:            serializer.NewCodecFactory(factory.scheme).UniversalDeserializer(),
-		getValuesFuncs:     factory.getValuesFuncs,
-		agentAddonOptions:  factory.agentAddonOptions,
-		trimCRDDescription: factory.trimCRDDescription,
+		decoder:             serializer.NewCodecFactory(factory.scheme).UniversalDeserializer(),
+		dir:                 factory.dir,
+		manifestFilters:     factory.manifestFilters,
+		getValuesFuncs:      factory.getValuesFuncs,
+		manifestMutators:    factory.manifestMutators,
+		agentAddonOptions:   factory.agentAddonOptions,
+		trimCRDDescription:  factory.trimCRDDescription,
+		valuesMergePolicy:   factory.valuesMergePolicy,
+		valuesListMergeKeys: factory.valuesListMergeKeys,
+		valuesSchema:        factory.valuesSchema,
 	}
 }
 
@@ -62,28 +80,33 @@ func (a *TemplateAgentAddon) Manifests(
 	if err != nil {
 		return objects, err
 	}
+	if err := validateValues(a.valuesSchema, configValues); err != nil {
+		return objects, err
+	}
 
+	excluded := excludedFiles(a.manifestFilters, cluster)
+	decoder := newManifestDecoder(a.decoder)
 	for _, file := range a.templateFiles {
 		if len(file.content) == 0 {
 			continue
 		}
+		if fileExcluded(excluded, a.relativePath(file.name)) {
+			klog.V(4).Infof("template %s is excluded on cluster %s", file.name, cluster.Name)
+			continue
+		}
 		klog.V(4).Infof("rendered template: %v", file.content)
 		raw := assets.MustCreateAssetFromTemplate(file.name, file.content, configValues).Data
-		object, _, err := a.decoder.Decode(raw, nil, nil)
-		if err != nil {
-			if runtime.IsMissingKind(err) {
-				klog.V(4).Infof("Skipping template %v, reason: %v", file.name, err)
-				continue
-			}
-			return nil, err
-		}
-		objects = append(objects, object)
+		decoder.decode(file.name, raw)
+	}
+	objects, err = decoder.result()
+	if err != nil {
+		return nil, err
 	}
 
 	if a.trimCRDDescription {
 		objects = trimCRDDescription(objects)
 	}
-	return objects, nil
+	return mutateManifests(a.manifestMutators, cluster, addon, objects)
 }
 
 func (a *TemplateAgentAddon) GetAgentAddonOptions() agent.AgentAddonOptions {
@@ -98,16 +121,15 @@ func (a *TemplateAgentAddon) getValues(
 	defaultValues := a.getDefaultValues(cluster, addon)
 	overrideValues = MergeValues(overrideValues, defaultValues)
 
-	for i := 0; i < len(a.getValuesFuncs); i++ {
-		if a.getValuesFuncs[i] != nil {
-			userValues, err := a.getValuesFuncs[i](cluster, addon)
-			if err != nil {
-				return overrideValues, err
-			}
-			overrideValues = MergeValues(overrideValues, userValues)
-		}
+	overrideValues, err := mergeGetValues(overrideValues, cluster, addon,
+		a.getValuesFuncs, a.valuesMergePolicy, a.valuesListMergeKeys)
+	if err != nil {
+		return overrideValues, err
+	}
+	builtinValues, err := a.getBuiltinValues(cluster, addon)
+	if err != nil {
+		return overrideValues, err
 	}
-	builtinValues := a.getBuiltinValues(cluster, addon)
 	overrideValues = MergeValues(overrideValues, builtinValues)
 
 	return overrideValues, nil
@@ -115,19 +137,29 @@ func (a *TemplateAgentAddon) getValues(
 
 func (a *TemplateAgentAddon) getBuiltinValues(
 	cluster *clusterv1.ManagedCluster,
-	addon *addonapiv1alpha1.ManagedClusterAddOn) Values {
+	addon *addonapiv1alpha1.ManagedClusterAddOn) (Values, error) {
 	builtinValues := templateBuiltinValues{}
 	builtinValues.ClusterName = cluster.GetName()
 
-	installNamespace := addon.Spec.InstallNamespace
-	if len(installNamespace) == 0 {
-		installNamespace = AddonDefaultInstallNamespace
+	installNamespace, err := agentInstallNamespace(a.agentAddonOptions, addon)
+	if err != nil {
+		return nil, err
 	}
 	builtinValues.AddonInstallNamespace = installNamespace
+	builtinValues.AgentInstallNamespace = installNamespace
 
 	builtinValues.InstallMode, _ = constants.GetHostedModeInfo(addon.GetAnnotations())
+	builtinValues.ClusterCapabilities = GetClusterCapabilities(cluster)
+
+	return StructToValues(builtinValues), nil
+}
 
-	return StructToValues(builtinValues)
+// relativePath returns the path of the template file relative to the dir of the factory.
+func (a *TemplateAgentAddon) relativePath(file string) string {
+	if a.dir == "." || len(a.dir) == 0 {
+		return file
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(file, strings.TrimSuffix(a.dir, "/")), "/")
 }
 
 func (a *TemplateAgentAddon) getDefaultValues(
@@ -135,9 +167,8 @@ func (a *TemplateAgentAddon) getDefaultValues(
 	addon *addonapiv1alpha1.ManagedClusterAddOn) Values {
 	defaultValues := templateDefaultValues{}
 
-	// TODO: hubKubeConfigSecret depends on the signer configuration in registration, and the registration is an array.
 	if a.agentAddonOptions.Registration != nil {
-		defaultValues.HubKubeConfigSecret = fmt.Sprintf("%s-hub-kubeconfig", a.agentAddonOptions.AddonName)
+		defaultValues.HubKubeConfigSecret = constants.HubKubeConfigSecretName(a.agentAddonOptions.AddonName)
 	}
 
 	defaultValues.ManagedKubeConfigSecret = fmt.Sprintf("%s-managed-kubeconfig", addon.Name)
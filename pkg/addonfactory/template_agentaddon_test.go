@@ -37,6 +37,7 @@ func TestTemplateAddon_Manifests(t *testing.T) {
 		clusterName                     string
 		addonName                       string
 		installNamespace                string
+		agentInstallNamespace           string
 		getValuesFunc                   GetValuesFunc
 		annotationConfig                string
 		expectedInstallNamespace        string
@@ -138,6 +139,20 @@ func TestTemplateAddon_Manifests(t *testing.T) {
 			expectedHubKubeConfigSecret:     "external-hub-kubeconfig",
 			expectedManagedKubeConfigSecret: "external-managed-kubeconfig",
 		},
+		{
+			name:                     "template render ok with agent install namespace",
+			dir:                      "testmanifests/template",
+			clusterName:              "cluster1",
+			addonName:                "helloworld",
+			installNamespace:         "myNs",
+			agentInstallNamespace:    "agentNs",
+			scheme:                   scheme,
+			annotationConfig:         `{"Image":"quay.io/helloworld:2.4"}`,
+			expectedInstallNamespace: "agentNs",
+			expectedNodeSelector:     map[string]string{},
+			expectedImage:            "quay.io/helloworld:2.4",
+			expectedObjectCnt:        2,
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -153,11 +168,17 @@ func TestTemplateAddon_Manifests(t *testing.T) {
 			clusterAddon := NewFakeManagedClusterAddon(c.addonName, c.clusterName, c.installNamespace,
 				c.annotationConfig)
 
-			agentAddon, err := NewAgentAddonFactory(c.addonName, templateFS, c.dir).
+			agentAddonFactory := NewAgentAddonFactory(c.addonName, templateFS, c.dir).
 				WithScheme(c.scheme).
 				WithGetValuesFuncs(c.getValuesFunc, GetValuesFromAddonAnnotation).
-				WithAgentRegistrationOption(&agent.RegistrationOption{}).
-				BuildTemplateAgentAddon()
+				WithAgentRegistrationOption(&agent.RegistrationOption{})
+			if len(c.agentInstallNamespace) > 0 {
+				agentAddonFactory = agentAddonFactory.WithAgentInstallNamespace(
+					func(addon *addonapiv1alpha1.ManagedClusterAddOn) (string, error) {
+						return c.agentInstallNamespace, nil
+					})
+			}
+			agentAddon, err := agentAddonFactory.BuildTemplateAgentAddon()
 			if err != nil {
 				t.Errorf("expected no error, got err %v", err)
 			}
@@ -0,0 +1,197 @@
+package addonfactory
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/klog/v2"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+// ValuesMergePolicy is the policy to merge the values of the GetValuesFuncs, the values of a later
+// GetValuesFunc are merged onto the values of the earlier ones.
+type ValuesMergePolicy string
+
+const (
+	// ValuesMergePolicyOverride merges the nested maps, and the other fields, including the lists, of the later
+	// values override the same fields of the earlier values. It is the default policy.
+	ValuesMergePolicyOverride ValuesMergePolicy = "Override"
+
+	// ValuesMergePolicyDeepMerge merges the nested maps as ValuesMergePolicyOverride, and also merges the lists of
+	// maps which have a list merge key, e.g. the env of a container, the items with the same key are merged and
+	// the other items are appended. The other lists are overridden.
+	ValuesMergePolicyDeepMerge ValuesMergePolicy = "DeepMerge"
+
+	// ValuesMergePolicyErrorOnConflict merges the values as ValuesMergePolicyDeepMerge, but returns an error if the
+	// GetValuesFuncs set a field to different values.
+	ValuesMergePolicyErrorOnConflict ValuesMergePolicy = "ErrorOnConflict"
+)
+
+// DefaultValuesListMergeKeys are the keys of the items of the lists merged by ValuesMergePolicyDeepMerge and
+// ValuesMergePolicyErrorOnConflict, as the strategic merge patch merges the containers, env and volumes by name.
+var DefaultValuesListMergeKeys = []string{"name"}
+
+// MergeValuesWithPolicy merges the values b onto the values a by the policy. The lists of maps are merged by the
+// first of the listMergeKeys all their items have.
+func MergeValuesWithPolicy(a, b Values, policy ValuesMergePolicy, listMergeKeys ...string) (Values, error) {
+	switch policy {
+	case "", ValuesMergePolicyOverride:
+		return MergeValues(a, b), nil
+	case ValuesMergePolicyDeepMerge, ValuesMergePolicyErrorOnConflict:
+		merger := &valuesMerger{
+			errorOnConflict: policy == ValuesMergePolicyErrorOnConflict,
+			listMergeKeys:   listMergeKeys,
+		}
+		return merger.mergeMaps(a, b, nil)
+	default:
+		return nil, fmt.Errorf("unsupported values merge policy %q", policy)
+	}
+}
+
+// mergeGetValues merges the values of the getValuesFuncs in order onto the default values by the policy. The
+// conflicts are only checked between the getValuesFuncs, they always override the default values.
+func mergeGetValues(defaultValues Values, cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn,
+	getValuesFuncs []GetValuesFunc, policy ValuesMergePolicy, listMergeKeys []string) (Values, error) {
+	if len(policy) == 0 || policy == ValuesMergePolicyOverride {
+		overrideValues := defaultValues
+		for i, getValuesFunc := range getValuesFuncs {
+			if getValuesFunc == nil {
+				continue
+			}
+			userValues, err := getValuesFunc(cluster, addon)
+			if err != nil {
+				return overrideValues, err
+			}
+			klog.V(4).Infof("index=%d, user values: %v", i, userValues)
+			overrideValues = MergeValues(overrideValues, userValues)
+			klog.V(4).Infof("index=%d, override values: %v", i, overrideValues)
+		}
+		return overrideValues, nil
+	}
+
+	mergedValues := Values{}
+	for i, getValuesFunc := range getValuesFuncs {
+		if getValuesFunc == nil {
+			continue
+		}
+		userValues, err := getValuesFunc(cluster, addon)
+		if err != nil {
+			return defaultValues, err
+		}
+		klog.V(4).Infof("index=%d, user values: %v", i, userValues)
+		mergedValues, err = MergeValuesWithPolicy(mergedValues, userValues, policy, listMergeKeys...)
+		if err != nil {
+			return defaultValues, fmt.Errorf("failed to merge the values of getValuesFunc %d: %v", i, err)
+		}
+	}
+	return MergeValuesWithPolicy(defaultValues, mergedValues, ValuesMergePolicyDeepMerge, listMergeKeys...)
+}
+
+type valuesMerger struct {
+	errorOnConflict bool
+	listMergeKeys   []string
+}
+
+func (m *valuesMerger) mergeMaps(a, b map[string]interface{}, path []string) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(a)+len(b))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, bv := range b {
+		av, ok := out[k]
+		if !ok {
+			out[k] = bv
+			continue
+		}
+		merged, err := m.merge(av, bv, append(path, k))
+		if err != nil {
+			return nil, err
+		}
+		out[k] = merged
+	}
+	return out, nil
+}
+
+func (m *valuesMerger) merge(a, b interface{}, path []string) (interface{}, error) {
+	if bv, ok := toMap(b); ok {
+		if av, ok := toMap(a); ok {
+			return m.mergeMaps(av, bv, path)
+		}
+	}
+	if bv, ok := b.([]interface{}); ok {
+		if av, ok := a.([]interface{}); ok {
+			if key := m.listMergeKey(av, bv); len(key) > 0 {
+				return m.mergeLists(av, bv, key, path)
+			}
+		}
+	}
+
+	if m.errorOnConflict && !equality.Semantic.DeepEqual(a, b) {
+		return nil, fmt.Errorf("conflicting values of %s: %v and %v", strings.Join(path, "."), a, b)
+	}
+	return b, nil
+}
+
+// mergeLists merges the items of the lists with the same key, and appends the other items of b.
+func (m *valuesMerger) mergeLists(a, b []interface{}, key string, path []string) ([]interface{}, error) {
+	out := make([]interface{}, len(a))
+	copy(out, a)
+	indexes := map[interface{}]int{}
+	for i, item := range out {
+		indexes[item.(map[string]interface{})[key]] = i
+	}
+
+	for _, item := range b {
+		bv := item.(map[string]interface{})
+		i, ok := indexes[bv[key]]
+		if !ok {
+			indexes[bv[key]] = len(out)
+			out = append(out, bv)
+			continue
+		}
+		merged, err := m.mergeMaps(out[i].(map[string]interface{}), bv,
+			append(path, fmt.Sprintf("[%s=%v]", key, bv[key])))
+		if err != nil {
+			return nil, err
+		}
+		out[i] = merged
+	}
+	return out, nil
+}
+
+// listMergeKey returns the first list merge key all the items of the lists have with a comparable value.
+func (m *valuesMerger) listMergeKey(a, b []interface{}) string {
+	for _, key := range m.listMergeKeys {
+		if hasMergeKey(a, key) && hasMergeKey(b, key) {
+			return key
+		}
+	}
+	return ""
+}
+
+func hasMergeKey(list []interface{}, key string) bool {
+	for _, item := range list {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		switch obj[key].(type) {
+		case string, bool, int, int64, float64:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func toMap(v interface{}) (map[string]interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return v, true
+	case Values:
+		return v, true
+	}
+	return nil, false
+}
@@ -0,0 +1,160 @@
+package addonfactory
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+func TestMergeValuesWithPolicy(t *testing.T) {
+	env := func(items ...map[string]interface{}) []interface{} {
+		var list []interface{}
+		for _, item := range items {
+			list = append(list, item)
+		}
+		return list
+	}
+
+	cases := []struct {
+		name           string
+		policy         ValuesMergePolicy
+		a              Values
+		b              Values
+		expectedValues Values
+		expectedErr    bool
+	}{
+		{
+			name:           "override merges maps and replaces lists",
+			policy:         ValuesMergePolicyOverride,
+			a:              Values{"global": map[string]interface{}{"image": "a", "tag": "v1"}, "env": env(map[string]interface{}{"name": "A", "value": "1"})},
+			b:              Values{"global": map[string]interface{}{"image": "b"}, "env": env(map[string]interface{}{"name": "B", "value": "2"})},
+			expectedValues: Values{"global": map[string]interface{}{"image": "b", "tag": "v1"}, "env": env(map[string]interface{}{"name": "B", "value": "2"})},
+		},
+		{
+			name:   "deep merge merges the lists by key",
+			policy: ValuesMergePolicyDeepMerge,
+			a: Values{"env": env(
+				map[string]interface{}{"name": "A", "value": "1"},
+				map[string]interface{}{"name": "B", "value": "2"},
+			)},
+			b: Values{"env": env(
+				map[string]interface{}{"name": "B", "value": "3"},
+				map[string]interface{}{"name": "C", "value": "4"},
+			)},
+			expectedValues: Values{"env": env(
+				map[string]interface{}{"name": "A", "value": "1"},
+				map[string]interface{}{"name": "B", "value": "3"},
+				map[string]interface{}{"name": "C", "value": "4"},
+			)},
+		},
+		{
+			name:           "deep merge replaces the lists without key",
+			policy:         ValuesMergePolicyDeepMerge,
+			a:              Values{"args": []interface{}{"--a"}, "nested": Values{"x": int64(1)}},
+			b:              Values{"args": []interface{}{"--b"}, "nested": map[string]interface{}{"y": int64(2)}},
+			expectedValues: Values{"args": []interface{}{"--b"}, "nested": map[string]interface{}{"x": int64(1), "y": int64(2)}},
+		},
+		{
+			name:           "error on conflict allows the same values",
+			policy:         ValuesMergePolicyErrorOnConflict,
+			a:              Values{"global": map[string]interface{}{"image": "a"}, "env": env(map[string]interface{}{"name": "A", "value": "1"})},
+			b:              Values{"global": map[string]interface{}{"image": "a", "tag": "v1"}, "env": env(map[string]interface{}{"name": "B", "value": "2"})},
+			expectedValues: Values{"global": map[string]interface{}{"image": "a", "tag": "v1"}, "env": env(map[string]interface{}{"name": "A", "value": "1"}, map[string]interface{}{"name": "B", "value": "2"})},
+		},
+		{
+			name:        "error on conflict of a nested field",
+			policy:      ValuesMergePolicyErrorOnConflict,
+			a:           Values{"global": map[string]interface{}{"image": "a"}},
+			b:           Values{"global": map[string]interface{}{"image": "b"}},
+			expectedErr: true,
+		},
+		{
+			name:        "error on conflict of a list item",
+			policy:      ValuesMergePolicyErrorOnConflict,
+			a:           Values{"env": env(map[string]interface{}{"name": "A", "value": "1"})},
+			b:           Values{"env": env(map[string]interface{}{"name": "A", "value": "2"})},
+			expectedErr: true,
+		},
+		{
+			name:        "unsupported policy",
+			policy:      "Unknown",
+			a:           Values{},
+			b:           Values{},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			values, err := MergeValuesWithPolicy(c.a, c.b, c.policy, DefaultValuesListMergeKeys...)
+			if c.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, but got values %v", values)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !equality.Semantic.DeepEqual(map[string]interface{}(values), map[string]interface{}(c.expectedValues)) {
+				t.Errorf("expected values %v, but got values %v", c.expectedValues, values)
+			}
+		})
+	}
+}
+
+func TestMergeGetValues(t *testing.T) {
+	image := func(image string) GetValuesFunc {
+		return func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) (Values, error) {
+			return Values{"image": image}, nil
+		}
+	}
+
+	cases := []struct {
+		name           string
+		policy         ValuesMergePolicy
+		getValuesFuncs []GetValuesFunc
+		expectedImage  string
+		expectedErr    bool
+	}{
+		{
+			name:           "override by the last func",
+			policy:         ValuesMergePolicyOverride,
+			getValuesFuncs: []GetValuesFunc{image("a"), nil, image("b")},
+			expectedImage:  "b",
+		},
+		{
+			name:           "the funcs override the default values without conflict",
+			policy:         ValuesMergePolicyErrorOnConflict,
+			getValuesFuncs: []GetValuesFunc{image("a"), image("a")},
+			expectedImage:  "a",
+		},
+		{
+			name:           "conflict between the funcs",
+			policy:         ValuesMergePolicyErrorOnConflict,
+			getValuesFuncs: []GetValuesFunc{image("a"), image("b")},
+			expectedErr:    true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			values, err := mergeGetValues(Values{"image": "default"}, nil, nil,
+				c.getValuesFuncs, c.policy, DefaultValuesListMergeKeys)
+			if c.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, but got values %v", values)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if values["image"] != c.expectedImage {
+				t.Errorf("expected image %s, but got %v", c.expectedImage, values["image"])
+			}
+		})
+	}
+}
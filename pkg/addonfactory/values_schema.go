@@ -0,0 +1,30 @@
+package addonfactory
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"open-cluster-management.io/addon-framework/pkg/agent"
+)
+
+// validateValuesSchema checks the values schema is a JSON document.
+func validateValuesSchema(schema []byte) error {
+	if len(schema) > 0 && !json.Valid(schema) {
+		return fmt.Errorf("the values schema is not a valid JSON")
+	}
+	return nil
+}
+
+// validateValues validates the values against the JSON schema, and returns a ValuesInvalidError with the
+// validation errors if the values are invalid.
+func validateValues(schema []byte, values Values) error {
+	if len(schema) == 0 {
+		return nil
+	}
+	if err := chartutil.ValidateAgainstSingleSchema(chartutil.Values(values), schema); err != nil {
+		return &agent.ValuesInvalidError{Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package addonfactory
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1apha1 "open-cluster-management.io/api/cluster/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/agent"
+)
+
+func TestValuesSchema(t *testing.T) {
+	schema := []byte(`{
+  "type": "object",
+  "properties": {
+    "Image": {"type": "string", "pattern": "^quay.io/"}
+  },
+  "required": ["Image"]
+}`)
+
+	cases := []struct {
+		name               string
+		schema             []byte
+		values             Values
+		expectedBuildErr   bool
+		expectedInvalidErr bool
+	}{
+		{
+			name:   "valid values",
+			schema: schema,
+			values: Values{"Image": "quay.io/helloworld:latest"},
+		},
+		{
+			name:               "values of invalid type",
+			schema:             schema,
+			values:             Values{"Image": 1},
+			expectedInvalidErr: true,
+		},
+		{
+			name:               "missing required values",
+			schema:             schema,
+			values:             Values{},
+			expectedInvalidErr: true,
+		},
+		{
+			name:   "no schema",
+			values: Values{"Image": "docker.io/helloworld:latest"},
+		},
+		{
+			name:             "invalid schema",
+			schema:           []byte(`{"type":`),
+			expectedBuildErr: true,
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	_ = clusterv1apha1.Install(scheme)
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			agentAddon, err := NewAgentAddonFactory("helloworld", templateFS, "testmanifests/template").
+				WithScheme(scheme).
+				WithGetValuesFuncs(func(cluster *clusterv1.ManagedCluster,
+					addon *addonapiv1alpha1.ManagedClusterAddOn) (Values, error) {
+					return c.values, nil
+				}).
+				WithValuesSchema(c.schema).
+				BuildTemplateAgentAddon()
+			if c.expectedBuildErr {
+				if err == nil {
+					t.Fatalf("expected build error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected build error: %v", err)
+			}
+
+			cluster := NewFakeManagedCluster("cluster1", "1.10.1")
+			addon := NewFakeManagedClusterAddon("helloworld", "cluster1", "", "")
+			_, err = agentAddon.Manifests(cluster, addon)
+			if c.expectedInvalidErr != agent.IsValuesInvalid(err) {
+				t.Errorf("expected values invalid %v, but got error %v", c.expectedInvalidErr, err)
+			}
+			if !c.expectedInvalidErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package addontesting
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
+)
+
+func SetManagedClusterLabels(c *clusterv1.ManagedCluster, labels map[string]string) *clusterv1.ManagedCluster {
+	c.Labels = labels
+	return c
+}
+
+type addOnDeploymentConfigBuilder struct {
+	addOnDeploymentConfig *addonapiv1alpha1.AddOnDeploymentConfig
+}
+
+func NewAddOnDeploymentConfig(name, namespace string) *addOnDeploymentConfigBuilder {
+	return &addOnDeploymentConfigBuilder{
+		&addonapiv1alpha1.AddOnDeploymentConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+		},
+	}
+}
+
+func (b *addOnDeploymentConfigBuilder) WithCustomizedVariable(name, value string) *addOnDeploymentConfigBuilder {
+	b.addOnDeploymentConfig.Spec.CustomizedVariables = append(b.addOnDeploymentConfig.Spec.CustomizedVariables,
+		addonapiv1alpha1.CustomizedVariable{Name: name, Value: value})
+	return b
+}
+
+func (b *addOnDeploymentConfigBuilder) WithNodePlacement(
+	nodeSelector map[string]string, tolerations ...corev1.Toleration) *addOnDeploymentConfigBuilder {
+	b.addOnDeploymentConfig.Spec.NodePlacement = &addonapiv1alpha1.NodePlacement{
+		NodeSelector: nodeSelector,
+		Tolerations:  tolerations,
+	}
+	return b
+}
+
+func (b *addOnDeploymentConfigBuilder) Build() *addonapiv1alpha1.AddOnDeploymentConfig {
+	return b.addOnDeploymentConfig
+}
+
+// NewAddOnDeploymentConfigReference returns the config reference of the addon to the AddOnDeploymentConfig, which
+// is the desired config of the addon, so the agent renders its manifests with the config.
+func NewAddOnDeploymentConfigReference(namespace, name, specHash string) addonapiv1alpha1.ConfigReference {
+	referent := addonapiv1alpha1.ConfigReferent{Namespace: namespace, Name: name}
+	return addonapiv1alpha1.ConfigReference{
+		ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+			Group:    "addon.open-cluster-management.io",
+			Resource: "addondeploymentconfigs",
+		},
+		ConfigReferent: referent,
+		DesiredConfig: &addonapiv1alpha1.ConfigSpecHash{
+			ConfigReferent: referent,
+			SpecHash:       specHash,
+		},
+	}
+}
+
+func NewPlacement(name, namespace string) *clusterv1beta1.Placement {
+	return &clusterv1beta1.Placement{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+// NewPlacementDecision returns the PlacementDecision of the placement selecting the clusters.
+func NewPlacementDecision(name, namespace, placement string, clusterNames ...string) *clusterv1beta1.PlacementDecision {
+	decision := &clusterv1beta1.PlacementDecision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				clusterv1beta1.PlacementLabel: placement,
+			},
+		},
+	}
+	for _, clusterName := range clusterNames {
+		decision.Status.Decisions = append(decision.Status.Decisions, clusterv1beta1.ClusterDecision{
+			ClusterName: clusterName,
+		})
+	}
+	return decision
+}
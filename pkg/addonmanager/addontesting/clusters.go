@@ -0,0 +1,168 @@
+package addontesting
+
+import (
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+// The labels and cluster claims set on the ManagedClusters by the cluster profile fixtures. They follow the
+// labels and claims reported by the klusterlet and the cluster-claims controller on real clusters.
+const (
+	ClusterLabelVendor           = "vendor"
+	ClusterLabelCloud            = "cloud"
+	ClusterLabelOpenShiftVersion = "openshiftVersion"
+	ClusterLabelArch             = "kubernetes.io/arch"
+
+	ClusterClaimID          = "id.k8s.io"
+	ClusterClaimKubeVersion = "kubeversion.open-cluster-management.io"
+	ClusterClaimPlatform    = "platform.open-cluster-management.io"
+	ClusterClaimProduct     = "product.open-cluster-management.io"
+	ClusterClaimOCPVersion  = "version.openshift.io"
+	ClusterClaimArch        = "arch.open-cluster-management.io"
+	ClusterClaimIPFamily    = "ipfamily.open-cluster-management.io"
+
+	// HostedClusterDeployModeAnnotationKey and HostingClusterNameAnnotationKey are set on the ManagedClusters
+	// whose klusterlet runs in Hosted mode on a hosting cluster.
+	HostedClusterDeployModeAnnotationKey = "import.open-cluster-management.io/klusterlet-deploy-mode"
+	HostingClusterNameAnnotationKey      = "import.open-cluster-management.io/hosting-cluster-name"
+)
+
+// NewOpenShiftCluster returns an accepted and available OpenShift 4.x cluster on AWS.
+func NewOpenShiftCluster(name, ocpVersion string) *clusterv1.ManagedCluster {
+	cluster := newProfileCluster(name, "v1.27.6+f67aeb3", "https://api."+name+".example.com:6443")
+	cluster.Labels[ClusterLabelVendor] = "OpenShift"
+	cluster.Labels[ClusterLabelCloud] = "Amazon"
+	cluster.Labels[ClusterLabelOpenShiftVersion] = ocpVersion
+	setClusterClaims(cluster, map[string]string{
+		ClusterClaimPlatform:   "AWS",
+		ClusterClaimProduct:    "OpenShift",
+		ClusterClaimOCPVersion: ocpVersion,
+	})
+	return cluster
+}
+
+// NewEKSCluster returns an accepted and available Amazon EKS cluster.
+func NewEKSCluster(name, kubeVersion string) *clusterv1.ManagedCluster {
+	cluster := newProfileCluster(name, kubeVersion,
+		fmt.Sprintf("https://%s.gr7.us-east-1.eks.amazonaws.com", name))
+	cluster.Labels[ClusterLabelVendor] = "EKS"
+	cluster.Labels[ClusterLabelCloud] = "Amazon"
+	setClusterClaims(cluster, map[string]string{
+		ClusterClaimPlatform: "AWS",
+		ClusterClaimProduct:  "EKS",
+	})
+	return cluster
+}
+
+// NewAKSCluster returns an accepted and available Azure AKS cluster.
+func NewAKSCluster(name, kubeVersion string) *clusterv1.ManagedCluster {
+	cluster := newProfileCluster(name, kubeVersion,
+		fmt.Sprintf("https://%s-dns.hcp.eastus.azmk8s.io:443", name))
+	cluster.Labels[ClusterLabelVendor] = "AKS"
+	cluster.Labels[ClusterLabelCloud] = "Azure"
+	setClusterClaims(cluster, map[string]string{
+		ClusterClaimPlatform: "Azure",
+		ClusterClaimProduct:  "AKS",
+	})
+	return cluster
+}
+
+// NewIPv6OnlyCluster returns an accepted and available bare metal cluster with an IPv6 only network.
+func NewIPv6OnlyCluster(name string) *clusterv1.ManagedCluster {
+	cluster := newProfileCluster(name, "v1.28.2", "https://[fd00:10:96::1]:6443")
+	cluster.Labels[ClusterLabelVendor] = "Other"
+	cluster.Labels[ClusterLabelCloud] = "BareMetal"
+	setClusterClaims(cluster, map[string]string{
+		ClusterClaimPlatform: "BareMetal",
+		ClusterClaimIPFamily: "IPv6",
+	})
+	return cluster
+}
+
+// NewARM64Cluster returns an accepted and available cluster whose nodes are arm64.
+func NewARM64Cluster(name string) *clusterv1.ManagedCluster {
+	cluster := newProfileCluster(name, "v1.28.2", "https://"+name+".example.com:6443")
+	cluster.Labels[ClusterLabelVendor] = "Other"
+	cluster.Labels[ClusterLabelArch] = "arm64"
+	setClusterClaims(cluster, map[string]string{
+		ClusterClaimArch: "arm64",
+	})
+	return cluster
+}
+
+// NewHostedCluster returns an accepted and available OpenShift cluster whose klusterlet runs in Hosted
+// mode on the hosting cluster.
+func NewHostedCluster(name, hostingCluster string) *clusterv1.ManagedCluster {
+	cluster := NewOpenShiftCluster(name, "4.14.0")
+	cluster.Annotations = map[string]string{
+		HostedClusterDeployModeAnnotationKey: "Hosted",
+		HostingClusterNameAnnotationKey:      hostingCluster,
+	}
+	return cluster
+}
+
+// NewClusterProfiles returns one cluster of each profile, named by the profile, for the table driven tests
+// checking the rendering of an addon across the platforms.
+func NewClusterProfiles() map[string]*clusterv1.ManagedCluster {
+	return map[string]*clusterv1.ManagedCluster{
+		"openshift": NewOpenShiftCluster("openshift", "4.14.0"),
+		"eks":       NewEKSCluster("eks", "v1.28.3-eks-4f4795d"),
+		"aks":       NewAKSCluster("aks", "v1.28.3"),
+		"ipv6":      NewIPv6OnlyCluster("ipv6"),
+		"arm64":     NewARM64Cluster("arm64"),
+		"hosted":    NewHostedCluster("hosted", "openshift"),
+	}
+}
+
+func newProfileCluster(name, kubeVersion, apiServerURL string) *clusterv1.ManagedCluster {
+	cluster := NewManagedCluster(name)
+	cluster.Labels = map[string]string{
+		"name": name,
+	}
+	cluster.Spec.HubAcceptsClient = true
+	cluster.Spec.ManagedClusterClientConfigs = []clusterv1.ClientConfig{
+		{URL: apiServerURL},
+	}
+	cluster.Status.Version.Kubernetes = kubeVersion
+	cluster.Status.Conditions = []metav1.Condition{
+		{
+			Type:   clusterv1.ManagedClusterConditionHubAccepted,
+			Status: metav1.ConditionTrue,
+			Reason: "HubClusterAdminAccepted",
+		},
+		{
+			Type:   clusterv1.ManagedClusterConditionJoined,
+			Status: metav1.ConditionTrue,
+			Reason: "ManagedClusterJoined",
+		},
+		{
+			Type:   clusterv1.ManagedClusterConditionAvailable,
+			Status: metav1.ConditionTrue,
+			Reason: "ManagedClusterAvailable",
+		},
+	}
+	cluster.Status.ClusterClaims = []clusterv1.ManagedClusterClaim{
+		{Name: ClusterClaimID, Value: name},
+		{Name: ClusterClaimKubeVersion, Value: kubeVersion},
+	}
+	return cluster
+}
+
+// setClusterClaims appends the claims to the cluster status, sorted by name to keep the fixtures deterministic.
+func setClusterClaims(cluster *clusterv1.ManagedCluster, claims map[string]string) {
+	names := make([]string, 0, len(claims))
+	for name := range claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cluster.Status.ClusterClaims = append(cluster.Status.ClusterClaims, clusterv1.ManagedClusterClaim{
+			Name:  name,
+			Value: claims[name],
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package addontesting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeinformers "k8s.io/client-go/informers"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addonscheme "open-cluster-management.io/api/client/addon/clientset/versioned/scheme"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	fakecluster "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterscheme "open-cluster-management.io/api/client/cluster/clientset/versioned/scheme"
+	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	fakework "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workscheme "open-cluster-management.io/api/client/work/clientset/versioned/scheme"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+)
+
+// FakeHub is a hub of fake clients and informers, so the framework controllers can be created on it and synced
+// in the unit tests of the addons. The objects of the hub are served by the clients of their API groups, and the
+// objects of the other groups, e.g. the CSRs and the Secrets, are served by the KubeClient.
+type FakeHub struct {
+	KubeClient    *fakekube.Clientset
+	AddonClient   *fakeaddon.Clientset
+	ClusterClient *fakecluster.Clientset
+	WorkClient    *fakework.Clientset
+
+	KubeInformers    kubeinformers.SharedInformerFactory
+	AddonInformers   addoninformers.SharedInformerFactory
+	ClusterInformers clusterinformers.SharedInformerFactory
+	WorkInformers    workinformers.SharedInformerFactory
+
+	stopCh chan struct{}
+}
+
+// NewFakeHub returns a FakeHub serving the objects. The informers of the hub are stopped once the test finishes.
+func NewFakeHub(t *testing.T, objects ...runtime.Object) *FakeHub {
+	var kubeObjects, addonObjects, clusterObjects, workObjects []runtime.Object
+	for _, obj := range objects {
+		switch {
+		case isRegistered(addonscheme.Scheme, obj):
+			addonObjects = append(addonObjects, obj)
+		case isRegistered(clusterscheme.Scheme, obj):
+			clusterObjects = append(clusterObjects, obj)
+		case isRegistered(workscheme.Scheme, obj):
+			workObjects = append(workObjects, obj)
+		default:
+			kubeObjects = append(kubeObjects, obj)
+		}
+	}
+
+	hub := &FakeHub{
+		KubeClient:    fakekube.NewSimpleClientset(kubeObjects...),
+		AddonClient:   fakeaddon.NewSimpleClientset(addonObjects...),
+		ClusterClient: fakecluster.NewSimpleClientset(clusterObjects...),
+		WorkClient:    fakework.NewSimpleClientset(workObjects...),
+		stopCh:        make(chan struct{}),
+	}
+	hub.KubeInformers = kubeinformers.NewSharedInformerFactory(hub.KubeClient, 10*time.Minute)
+	hub.AddonInformers = addoninformers.NewSharedInformerFactory(hub.AddonClient, 10*time.Minute)
+	hub.ClusterInformers = clusterinformers.NewSharedInformerFactory(hub.ClusterClient, 10*time.Minute)
+	hub.WorkInformers = workinformers.NewSharedInformerFactory(hub.WorkClient, 10*time.Minute)
+	t.Cleanup(func() { close(hub.stopCh) })
+	return hub
+}
+
+// RunSync syncs the controller created on the hub for each of the keys in order, and returns the first error.
+// The informers requested by the controllers are started and synced before, and the actions of the clients are
+// cleared, so the actions of the clients are the ones of the syncs.
+func (h *FakeHub) RunSync(t *testing.T, controller factory.Controller, keys ...string) error {
+	h.KubeInformers.Start(h.stopCh)
+	h.AddonInformers.Start(h.stopCh)
+	h.ClusterInformers.Start(h.stopCh)
+	h.WorkInformers.Start(h.stopCh)
+	h.KubeInformers.WaitForCacheSync(h.stopCh)
+	h.AddonInformers.WaitForCacheSync(h.stopCh)
+	h.ClusterInformers.WaitForCacheSync(h.stopCh)
+	h.WorkInformers.WaitForCacheSync(h.stopCh)
+
+	h.ClearActions()
+
+	syncContext := NewFakeSyncContext(t)
+	for _, key := range keys {
+		if err := controller.Sync(context.TODO(), syncContext, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClearActions clears the actions recorded by the clients of the hub.
+func (h *FakeHub) ClearActions() {
+	h.KubeClient.ClearActions()
+	h.AddonClient.ClearActions()
+	h.ClusterClient.ClearActions()
+	h.WorkClient.ClearActions()
+}
+
+func isRegistered(scheme *runtime.Scheme, obj runtime.Object) bool {
+	_, _, err := scheme.ObjectKinds(obj)
+	return err == nil
+}
@@ -0,0 +1,138 @@
+package addontesting
+
+import (
+	"testing"
+
+	certv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clienttesting "k8s.io/client-go/testing"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/agentdeploy"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+)
+
+type testAgent struct {
+	name string
+}
+
+func (a *testAgent) Manifests(cluster *clusterv1.ManagedCluster,
+	addon *addonapiv1alpha1.ManagedClusterAddOn) ([]runtime.Object, error) {
+	deployment := NewUnstructured("apps/v1", "Deployment", "default", a.name)
+	deployment.Object["spec"] = map[string]interface{}{
+		"replicas": int64(1),
+	}
+	return []runtime.Object{deployment}, nil
+}
+
+func (a *testAgent) GetAgentAddonOptions() agent.AgentAddonOptions {
+	return agent.AgentAddonOptions{AddonName: a.name}
+}
+
+func TestFakeHubRunSync(t *testing.T) {
+	hub := NewFakeHub(t, NewManagedCluster("cluster1"), NewAddon("test", "cluster1"))
+	controller := agentdeploy.NewAddonDeployController(
+		hub.WorkClient,
+		hub.AddonClient,
+		nil,
+		hub.ClusterInformers.Cluster().V1().ManagedClusters(),
+		hub.AddonInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+		hub.AddonInformers.Addon().V1alpha1().ClusterManagementAddOns(),
+		hub.AddonInformers.Addon().V1alpha1().AddOnDeploymentConfigs(),
+		hub.WorkInformers.Work().V1().ManifestWorks(),
+		map[string]agent.AgentAddon{"test": &testAgent{name: "test"}},
+		agentdeploy.Options{},
+	)
+
+	if err := hub.RunSync(t, controller, "cluster1/test"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	AssertActions(t, hub.WorkClient.Actions(), "create")
+
+	works := hub.ListManifestWorks(t, "cluster1")
+	if len(works) != 1 {
+		t.Fatalf("expected 1 manifestwork, but got %d", len(works))
+	}
+	deployment := AssertManifestWorkContains(t, &works[0], "apps/v1", "Deployment", "default", "test")
+	AssertManifestValue(t, deployment, int64(1), "spec", "replicas")
+	AssertManifestWorkNotContains(t, &works[0], "v1", "ServiceAccount", "default", "test")
+}
+
+func TestNewFakeHub(t *testing.T) {
+	hub := NewFakeHub(t,
+		NewManagedCluster("cluster1"),
+		NewAddon("test", "cluster1"),
+		NewAddOnDeploymentConfig("config", "default").WithCustomizedVariable("key", "value").Build(),
+		NewPlacement("placement", "default"),
+		NewPlacementDecision("placement-decision", "default", "placement", "cluster1"),
+		NewManifestWork("work", "cluster1"),
+		NewCSR("test", "cluster1"),
+	)
+
+	cases := []struct {
+		name      string
+		tracker   clienttesting.ObjectTracker
+		gvr       schema.GroupVersionResource
+		namespace string
+		objName   string
+	}{
+		{
+			name:    "managed cluster",
+			tracker: hub.ClusterClient.Tracker(),
+			gvr:     clusterv1.SchemeGroupVersion.WithResource("managedclusters"),
+			objName: "cluster1",
+		},
+		{
+			name:      "addon",
+			tracker:   hub.AddonClient.Tracker(),
+			gvr:       addonapiv1alpha1.SchemeGroupVersion.WithResource("managedclusteraddons"),
+			namespace: "cluster1",
+			objName:   "test",
+		},
+		{
+			name:      "addon deployment config",
+			tracker:   hub.AddonClient.Tracker(),
+			gvr:       addonapiv1alpha1.SchemeGroupVersion.WithResource("addondeploymentconfigs"),
+			namespace: "default",
+			objName:   "config",
+		},
+		{
+			name:      "placement",
+			tracker:   hub.ClusterClient.Tracker(),
+			gvr:       clusterv1beta1.SchemeGroupVersion.WithResource("placements"),
+			namespace: "default",
+			objName:   "placement",
+		},
+		{
+			name:      "placement decision",
+			tracker:   hub.ClusterClient.Tracker(),
+			gvr:       clusterv1beta1.SchemeGroupVersion.WithResource("placementdecisions"),
+			namespace: "default",
+			objName:   "placement-decision",
+		},
+		{
+			name:      "manifest work",
+			tracker:   hub.WorkClient.Tracker(),
+			gvr:       workapiv1.SchemeGroupVersion.WithResource("manifestworks"),
+			namespace: "cluster1",
+			objName:   "work",
+		},
+		{
+			name:    "csr",
+			tracker: hub.KubeClient.Tracker(),
+			gvr:     certv1.SchemeGroupVersion.WithResource("certificatesigningrequests"),
+			objName: "addon-test",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := c.tracker.Get(c.gvr, c.namespace, c.objName); err != nil {
+				t.Errorf("expected %s %s/%s served by the hub: %v", c.gvr.Resource, c.namespace, c.objName, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package addontesting
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+// ListManifestWorks returns the ManifestWorks of the addons in the cluster namespace on the hub.
+func (h *FakeHub) ListManifestWorks(t *testing.T, clusterName string) []workapiv1.ManifestWork {
+	works, err := h.WorkClient.WorkV1().ManifestWorks(clusterName).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list the manifestworks of cluster %s: %v", clusterName, err)
+	}
+	return works.Items
+}
+
+// GetManifest returns the manifest of the ManifestWork with the apiVersion, kind, namespace and name, the
+// namespace is empty for the cluster scoped manifests. It returns nil if the manifest is not found.
+func GetManifest(t *testing.T, work *workapiv1.ManifestWork,
+	apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	for i, manifest := range work.Spec.Workload.Manifests {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+			t.Fatalf("failed to decode the manifest %d of manifestwork %s/%s: %v", i, work.Namespace, work.Name, err)
+		}
+		if obj.GetAPIVersion() == apiVersion && obj.GetKind() == kind &&
+			obj.GetNamespace() == namespace && obj.GetName() == name {
+			return obj
+		}
+	}
+	return nil
+}
+
+// AssertManifestWorkContains asserts the ManifestWork has the manifest, and returns the manifest so its values
+// can be asserted by AssertManifestValue.
+func AssertManifestWorkContains(t *testing.T, work *workapiv1.ManifestWork,
+	apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	obj := GetManifest(t, work, apiVersion, kind, namespace, name)
+	if obj == nil {
+		t.Fatalf("expected manifest %s %s %s/%s in manifestwork %s/%s, but not found",
+			apiVersion, kind, namespace, name, work.Namespace, work.Name)
+	}
+	return obj
+}
+
+// AssertManifestWorkNotContains asserts the ManifestWork does not have the manifest.
+func AssertManifestWorkNotContains(t *testing.T, work *workapiv1.ManifestWork,
+	apiVersion, kind, namespace, name string) {
+	if obj := GetManifest(t, work, apiVersion, kind, namespace, name); obj != nil {
+		t.Errorf("expected no manifest %s %s %s/%s in manifestwork %s/%s, but found",
+			apiVersion, kind, namespace, name, work.Namespace, work.Name)
+	}
+}
+
+// AssertManifestValue asserts the value of the field of the manifest, e.g. the fields "spec", "replicas" of a
+// Deployment. The numbers of the manifests are int64 or float64.
+func AssertManifestValue(t *testing.T, obj *unstructured.Unstructured, expected interface{}, fields ...string) {
+	actual, found, err := unstructured.NestedFieldNoCopy(obj.Object, fields...)
+	if err != nil {
+		t.Fatalf("failed to get field %v of %s %s/%s: %v", fields, obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+	}
+	if !found {
+		t.Errorf("expected field %v of %s %s/%s, but not found", fields, obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		return
+	}
+	if !equality.Semantic.DeepEqual(expected, actual) {
+		t.Errorf("expected field %v of %s %s/%s to be %v, but got %v",
+			fields, obj.GetKind(), obj.GetNamespace(), obj.GetName(), expected, actual)
+	}
+}
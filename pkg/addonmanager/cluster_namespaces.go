@@ -0,0 +1,561 @@
+package addonmanager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	clusterv1client "open-cluster-management.io/api/client/cluster/clientset/versioned"
+	workv1client "open-cluster-management.io/api/client/work/clientset/versioned"
+	workv1informers "open-cluster-management.io/api/client/work/informers/externalversions"
+	clusterv1beta2 "open-cluster-management.io/api/cluster/v1beta2"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+// WithClusterSets restricts the manager to the managed clusters in the cluster sets, so a tenant team can run an
+// addon manager on a shared hub touching only its own clusters. The ManagedClusterAddOns, ManifestWorks and
+// Secrets are watched only in the namespaces of these clusters, which follow the clusters joining and leaving the
+// cluster sets. The cluster-scoped resources and the addon configs are still watched in the whole hub. It only
+// applies to Start, the informers passed to StartWithInformers are used as they are.
+func WithClusterSets(clusterSets ...string) Option {
+	return func(manager *addonManager) {
+		manager.clusterSets = append(manager.clusterSets, clusterSets...)
+	}
+}
+
+// clusterSetListOptions selects the managed clusters in the cluster sets.
+func clusterSetListOptions(clusterSets []string) func(listOptions *metav1.ListOptions) {
+	return func(listOptions *metav1.ListOptions) {
+		selector := &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{
+					Key:      clusterv1beta2.ClusterSetLabel,
+					Operator: metav1.LabelSelectorOpIn,
+					Values:   clusterSets,
+				},
+			},
+		}
+		listOptions.LabelSelector = metav1.FormatLabelSelector(selector)
+	}
+}
+
+// clusterSetNamespaces returns the sorted namespaces of the managed clusters in the cluster sets.
+func clusterSetNamespaces(ctx context.Context, clusterClient clusterv1client.Interface, clusterSets []string) ([]string, error) {
+	listOptions := metav1.ListOptions{}
+	clusterSetListOptions(clusterSets)(&listOptions)
+	clusters, err := clusterClient.ClusterV1().ManagedClusters().List(ctx, listOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the managed clusters of the cluster sets %v: %w", clusterSets, err)
+	}
+
+	namespaces := make([]string, 0, len(clusters.Items))
+	for _, cluster := range clusters.Items {
+		namespaces = append(namespaces, cluster.Name)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// clusterNamespaces is the set of the cluster namespaces that the scoped informers list and watch, the namespaces
+// are added and removed with the managed clusters joining and leaving the cluster sets.
+type clusterNamespaces struct {
+	listWatches []*namespacesListWatch
+}
+
+// add starts to list and watch the namespace of a managed cluster joining the cluster sets.
+func (c *clusterNamespaces) add(namespace string) {
+	for _, lw := range c.listWatches {
+		lw.addNamespace(namespace)
+	}
+}
+
+// remove stops to list and watch the namespace of a managed cluster leaving the cluster sets, the objects of the
+// namespace are deleted from the informers.
+func (c *clusterNamespaces) remove(namespace string) {
+	for _, lw := range c.listWatches {
+		lw.removeNamespace(namespace)
+	}
+}
+
+// clusterEventHandler adds and removes the namespaces by the events of the informer of the managed clusters in the
+// cluster sets. A cluster is deleted from the informer when it is deleted or its cluster set label is changed.
+func (c *clusterNamespaces) clusterEventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				utilruntime.HandleError(err)
+				return
+			}
+			c.add(accessor.GetName())
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				utilruntime.HandleError(err)
+				return
+			}
+			c.remove(accessor.GetName())
+		},
+	}
+}
+
+// scopeInformersToNamespaces replaces the informers of the namespaced resources in the cluster namespaces with the
+// informers listing and watching the namespaces only, it must be called before the informers are used. The
+// returned clusterNamespaces changes the namespaces of the informers.
+func scopeInformersToNamespaces(stopCh <-chan struct{}, namespaces []string,
+	managedClusterAddOnInformers addoninformers.SharedInformerFactory, managedClusterAddOnTweak func(*metav1.ListOptions),
+	workInformers workv1informers.SharedInformerFactory, kubeInformers kubeinformers.SharedInformerFactory,
+	tweak func(*metav1.ListOptions)) *clusterNamespaces {
+	addonListWatch := newNamespacesListWatch(stopCh, namespaces, &addonv1alpha1.ManagedClusterAddOn{},
+		func() runtime.Object { return &addonv1alpha1.ManagedClusterAddOnList{} })
+	managedClusterAddOnInformers.InformerFor(&addonv1alpha1.ManagedClusterAddOn{},
+		func(client addonv1alpha1client.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+			return addonListWatch.informer(func(namespace string) cache.ListerWatcher {
+				return &cache.ListWatch{
+					ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+						if managedClusterAddOnTweak != nil {
+							managedClusterAddOnTweak(&options)
+						}
+						return client.AddonV1alpha1().ManagedClusterAddOns(namespace).List(context.TODO(), options)
+					},
+					WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+						if managedClusterAddOnTweak != nil {
+							managedClusterAddOnTweak(&options)
+						}
+						return client.AddonV1alpha1().ManagedClusterAddOns(namespace).Watch(context.TODO(), options)
+					},
+				}
+			}, resyncPeriod)
+		})
+
+	workListWatch := newNamespacesListWatch(stopCh, namespaces, &workapiv1.ManifestWork{},
+		func() runtime.Object { return &workapiv1.ManifestWorkList{} })
+	workInformers.InformerFor(&workapiv1.ManifestWork{},
+		func(client workv1client.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+			return workListWatch.informer(func(namespace string) cache.ListerWatcher {
+				return &cache.ListWatch{
+					ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+						tweak(&options)
+						return client.WorkV1().ManifestWorks(namespace).List(context.TODO(), options)
+					},
+					WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+						tweak(&options)
+						return client.WorkV1().ManifestWorks(namespace).Watch(context.TODO(), options)
+					},
+				}
+			}, resyncPeriod)
+		})
+
+	secretListWatch := newNamespacesListWatch(stopCh, namespaces, &corev1.Secret{},
+		func() runtime.Object { return &corev1.SecretList{} })
+	kubeInformers.InformerFor(&corev1.Secret{},
+		func(client kubernetes.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+			return secretListWatch.informer(func(namespace string) cache.ListerWatcher {
+				return &cache.ListWatch{
+					ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+						tweak(&options)
+						return client.CoreV1().Secrets(namespace).List(context.TODO(), options)
+					},
+					WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+						tweak(&options)
+						return client.CoreV1().Secrets(namespace).Watch(context.TODO(), options)
+					},
+				}
+			}, resyncPeriod)
+		})
+
+	return &clusterNamespaces{listWatches: []*namespacesListWatch{addonListWatch, workListWatch, secretListWatch}}
+}
+
+// namespacesListWatch lists and watches the objects of a resource in a dynamic set of namespaces. Each namespace
+// is listed and watched by a reflector of its own, so a closed watch of a namespace only relists the namespace,
+// and the changes of the objects in the namespaces, including the objects of the added and removed namespaces, are
+// queued as the events of one watch. Since the objects of different namespaces are not ordered by their resource
+// versions, the list has a resource version of its own and the watch ignores the resource version.
+type namespacesListWatch struct {
+	stopCh       <-chan struct{}
+	expectedType runtime.Object
+	newList      func() runtime.Object
+	newListWatch func(namespace string) cache.ListerWatcher
+
+	lock    sync.Mutex
+	changed *sync.Cond
+	// started is true once the reflectors of the namespaces are started by the first list, and stopped is true
+	// once the stopCh is closed.
+	started    bool
+	stopped    bool
+	lists      int
+	namespaces map[string]*namespaceStore
+	events     []watch.Event
+}
+
+var _ cache.ListerWatcher = &namespacesListWatch{}
+
+func newNamespacesListWatch(stopCh <-chan struct{}, namespaces []string, expectedType runtime.Object,
+	newList func() runtime.Object) *namespacesListWatch {
+	lw := &namespacesListWatch{
+		stopCh:       stopCh,
+		expectedType: expectedType,
+		newList:      newList,
+		namespaces:   map[string]*namespaceStore{},
+	}
+	lw.changed = sync.NewCond(&lw.lock)
+	for _, namespace := range namespaces {
+		lw.namespaces[namespace] = newNamespaceStore(lw, namespace)
+	}
+	return lw
+}
+
+func (lw *namespacesListWatch) informer(newListWatch func(namespace string) cache.ListerWatcher,
+	resyncPeriod time.Duration) cache.SharedIndexInformer {
+	lw.lock.Lock()
+	lw.newListWatch = newListWatch
+	lw.lock.Unlock()
+	return cache.NewSharedIndexInformer(lw, lw.expectedType, resyncPeriod,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
+
+func (lw *namespacesListWatch) addNamespace(namespace string) {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	if _, ok := lw.namespaces[namespace]; ok || lw.stopped {
+		return
+	}
+	store := newNamespaceStore(lw, namespace)
+	lw.namespaces[namespace] = store
+	if lw.started {
+		lw.runLocked(store)
+	}
+}
+
+func (lw *namespacesListWatch) removeNamespace(namespace string) {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	store, ok := lw.namespaces[namespace]
+	if !ok {
+		return
+	}
+	delete(lw.namespaces, namespace)
+	if lw.started {
+		close(store.stopCh)
+	}
+	for _, obj := range store.objects {
+		lw.queueLocked(watch.Deleted, obj)
+	}
+}
+
+func (lw *namespacesListWatch) runLocked(store *namespaceStore) {
+	reflector := cache.NewNamedReflector(fmt.Sprintf("%T of namespace %s", lw.expectedType, store.namespace),
+		lw.newListWatch(store.namespace), lw.expectedType, store, 0)
+	go reflector.Run(store.stopCh)
+}
+
+func (lw *namespacesListWatch) startLocked() {
+	lw.started = true
+	for _, store := range lw.namespaces {
+		lw.runLocked(store)
+	}
+	go func() {
+		<-lw.stopCh
+		lw.lock.Lock()
+		defer lw.lock.Unlock()
+		lw.stopped = true
+		for namespace, store := range lw.namespaces {
+			delete(lw.namespaces, namespace)
+			close(store.stopCh)
+		}
+		lw.changed.Broadcast()
+	}()
+}
+
+func (lw *namespacesListWatch) queueLocked(eventType watch.EventType, obj runtime.Object) {
+	lw.events = append(lw.events, watch.Event{Type: eventType, Object: obj})
+	lw.changed.Broadcast()
+}
+
+// List returns the objects of all the namespaces. The first list waits until the namespaces are listed, the
+// namespaces added later are merged by the events of the watch once they are listed.
+func (lw *namespacesListWatch) List(_ metav1.ListOptions) (runtime.Object, error) {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	if !lw.started {
+		lw.startLocked()
+		for !lw.stopped && !lw.syncedLocked() {
+			lw.changed.Wait()
+		}
+	}
+	if lw.stopped {
+		return nil, fmt.Errorf("the list of %T in namespaces is stopped", lw.expectedType)
+	}
+
+	var items []runtime.Object
+	for _, store := range lw.namespaces {
+		for _, obj := range store.objects {
+			items = append(items, obj)
+		}
+	}
+	// the events before the list are included in the list.
+	lw.events = nil
+
+	list := lw.newList()
+	if err := meta.SetList(list, items); err != nil {
+		return nil, err
+	}
+	listMeta, err := meta.ListAccessor(list)
+	if err != nil {
+		return nil, err
+	}
+	lw.lists++
+	listMeta.SetResourceVersion(fmt.Sprintf("namespaces-%d", lw.lists))
+	return list, nil
+}
+
+func (lw *namespacesListWatch) syncedLocked() bool {
+	for _, store := range lw.namespaces {
+		if !store.synced {
+			return false
+		}
+	}
+	return true
+}
+
+// Watch returns the watch of the events queued since the last list.
+func (lw *namespacesListWatch) Watch(_ metav1.ListOptions) (watch.Interface, error) {
+	w := &namespacesWatch{
+		lw:     lw,
+		result: make(chan watch.Event),
+		stopCh: make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// namespacesWatch sends the queued events of a namespacesListWatch, the event not received before the watch is
+// stopped is queued back.
+type namespacesWatch struct {
+	lw       *namespacesListWatch
+	result   chan watch.Event
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	// stopped is guarded by the lock of the namespacesListWatch.
+	stopped bool
+}
+
+func (w *namespacesWatch) run() {
+	defer close(w.result)
+	for {
+		w.lw.lock.Lock()
+		for len(w.lw.events) == 0 && !w.stopped && !w.lw.stopped {
+			w.lw.changed.Wait()
+		}
+		if w.stopped || w.lw.stopped {
+			w.lw.lock.Unlock()
+			return
+		}
+		event := w.lw.events[0]
+		w.lw.events = w.lw.events[1:]
+		w.lw.lock.Unlock()
+
+		select {
+		case w.result <- event:
+		case <-w.stopCh:
+			w.lw.lock.Lock()
+			w.lw.events = append([]watch.Event{event}, w.lw.events...)
+			w.lw.lock.Unlock()
+			return
+		}
+	}
+}
+
+func (w *namespacesWatch) Stop() {
+	w.stopOnce.Do(func() {
+		w.lw.lock.Lock()
+		defer w.lw.lock.Unlock()
+		w.stopped = true
+		close(w.stopCh)
+		w.lw.changed.Broadcast()
+	})
+}
+
+func (w *namespacesWatch) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+// namespaceStore is the store of the reflector of a namespace, it keeps the objects of the namespace and queues
+// their changes to the namespacesListWatch. The changes after the namespace is removed are dropped.
+type namespaceStore struct {
+	lw        *namespacesListWatch
+	namespace string
+	stopCh    chan struct{}
+	// synced and objects are guarded by the lock of the namespacesListWatch.
+	synced  bool
+	objects map[string]runtime.Object
+}
+
+var _ cache.Store = &namespaceStore{}
+
+func newNamespaceStore(lw *namespacesListWatch, namespace string) *namespaceStore {
+	return &namespaceStore{
+		lw:        lw,
+		namespace: namespace,
+		stopCh:    make(chan struct{}),
+		objects:   map[string]runtime.Object{},
+	}
+}
+
+func (s *namespaceStore) removedLocked() bool {
+	return s.lw.namespaces[s.namespace] != s
+}
+
+func (s *namespaceStore) Add(obj interface{}) error {
+	return s.Update(obj)
+}
+
+func (s *namespaceStore) Update(obj interface{}) error {
+	object, key, err := objectKey(obj)
+	if err != nil {
+		return err
+	}
+	s.lw.lock.Lock()
+	defer s.lw.lock.Unlock()
+	if s.removedLocked() {
+		return nil
+	}
+	eventType := watch.Added
+	if _, ok := s.objects[key]; ok {
+		eventType = watch.Modified
+	}
+	s.objects[key] = object
+	s.lw.queueLocked(eventType, object)
+	return nil
+}
+
+func (s *namespaceStore) Delete(obj interface{}) error {
+	_, key, err := objectKey(obj)
+	if err != nil {
+		return err
+	}
+	s.lw.lock.Lock()
+	defer s.lw.lock.Unlock()
+	if s.removedLocked() {
+		return nil
+	}
+	if existing, ok := s.objects[key]; ok {
+		delete(s.objects, key)
+		s.lw.queueLocked(watch.Deleted, existing)
+	}
+	return nil
+}
+
+// Replace is called by the list of the reflector, the differences with the known objects are queued.
+func (s *namespaceStore) Replace(list []interface{}, _ string) error {
+	objects := make(map[string]runtime.Object, len(list))
+	for _, obj := range list {
+		object, key, err := objectKey(obj)
+		if err != nil {
+			return err
+		}
+		objects[key] = object
+	}
+
+	s.lw.lock.Lock()
+	defer s.lw.lock.Unlock()
+	if s.removedLocked() {
+		return nil
+	}
+	for key, existing := range s.objects {
+		if _, ok := objects[key]; !ok {
+			s.lw.queueLocked(watch.Deleted, existing)
+		}
+	}
+	for key, object := range objects {
+		existing, ok := s.objects[key]
+		switch {
+		case !ok:
+			s.lw.queueLocked(watch.Added, object)
+		case resourceVersion(existing) != resourceVersion(object):
+			s.lw.queueLocked(watch.Modified, object)
+		}
+	}
+	s.objects = objects
+	s.synced = true
+	s.lw.changed.Broadcast()
+	return nil
+}
+
+func (s *namespaceStore) List() []interface{} {
+	s.lw.lock.Lock()
+	defer s.lw.lock.Unlock()
+	list := make([]interface{}, 0, len(s.objects))
+	for _, obj := range s.objects {
+		list = append(list, obj)
+	}
+	return list
+}
+
+func (s *namespaceStore) ListKeys() []string {
+	s.lw.lock.Lock()
+	defer s.lw.lock.Unlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (s *namespaceStore) Get(obj interface{}) (interface{}, bool, error) {
+	_, key, err := objectKey(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	return s.GetByKey(key)
+}
+
+func (s *namespaceStore) GetByKey(key string) (interface{}, bool, error) {
+	s.lw.lock.Lock()
+	defer s.lw.lock.Unlock()
+	obj, ok := s.objects[key]
+	return obj, ok, nil
+}
+
+func (s *namespaceStore) Resync() error {
+	return nil
+}
+
+func objectKey(obj interface{}) (runtime.Object, string, error) {
+	object, ok := obj.(runtime.Object)
+	if !ok {
+		return nil, "", fmt.Errorf("unexpected object %T", obj)
+	}
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return nil, "", err
+	}
+	return object, key, nil
+}
+
+func resourceVersion(obj runtime.Object) string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return accessor.GetResourceVersion()
+}
@@ -0,0 +1,233 @@
+package addonmanager
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	kubeinformers "k8s.io/client-go/informers"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	fakecluster "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	fakework "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workv1informers "open-cluster-management.io/api/client/work/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1beta2 "open-cluster-management.io/api/cluster/v1beta2"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+func newClusterInSet(name, clusterSet string) *clusterv1.ManagedCluster {
+	cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if len(clusterSet) > 0 {
+		cluster.Labels = map[string]string{clusterv1beta2.ClusterSetLabel: clusterSet}
+	}
+	return cluster
+}
+
+func newAddonWork(namespace, name string) *workapiv1.ManifestWork {
+	return &workapiv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    map[string]string{addonapiv1alpha1.AddonLabelKey: "test"},
+		},
+	}
+}
+
+func TestClusterSetNamespaces(t *testing.T) {
+	cases := []struct {
+		name               string
+		clusterSets        []string
+		clusters           []runtime.Object
+		expectedNamespaces []string
+	}{
+		{
+			name:               "no clusters",
+			clusterSets:        []string{"set1"},
+			expectedNamespaces: []string{},
+		},
+		{
+			name:        "clusters of one cluster set",
+			clusterSets: []string{"set1"},
+			clusters: []runtime.Object{
+				newClusterInSet("cluster2", "set1"),
+				newClusterInSet("cluster1", "set1"),
+				newClusterInSet("cluster3", "set2"),
+				newClusterInSet("cluster4", ""),
+			},
+			expectedNamespaces: []string{"cluster1", "cluster2"},
+		},
+		{
+			name:        "clusters of multiple cluster sets",
+			clusterSets: []string{"set1", "set2"},
+			clusters: []runtime.Object{
+				newClusterInSet("cluster1", "set1"),
+				newClusterInSet("cluster2", "set2"),
+				newClusterInSet("cluster3", "set3"),
+			},
+			expectedNamespaces: []string{"cluster1", "cluster2"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clusterClient := fakecluster.NewSimpleClientset(c.clusters...)
+			namespaces, err := clusterSetNamespaces(context.TODO(), clusterClient, c.clusterSets)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(namespaces, c.expectedNamespaces) {
+				t.Errorf("expected namespaces %v, but got %v", c.expectedNamespaces, namespaces)
+			}
+		})
+	}
+}
+
+func TestScopeInformersToNamespaces(t *testing.T) {
+	workClient := fakework.NewSimpleClientset(
+		newAddonWork("cluster1", "work1"),
+		newAddonWork("cluster2", "work2"),
+		newAddonWork("cluster3", "work3"),
+	)
+	workInformers := workv1informers.NewSharedInformerFactory(workClient, 10*time.Minute)
+	addonInformers := addoninformers.NewSharedInformerFactory(fakeaddon.NewSimpleClientset(), 10*time.Minute)
+	kubeInformers := kubeinformers.NewSharedInformerFactory(fakekube.NewSimpleClientset(), 10*time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clusterNamespaces := scopeInformersToNamespaces(ctx.Done(), []string{"cluster1", "cluster2"}, addonInformers, nil,
+		workInformers, kubeInformers, addonLabelListOptions([]string{"test"}))
+
+	workInformer := workInformers.Work().V1().ManifestWorks()
+	workLister := workInformer.Lister()
+	workInformer.Informer()
+
+	workInformers.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), workInformer.Informer().HasSynced) {
+		t.Fatalf("failed to sync the work informer")
+	}
+
+	works, err := workLister.List(labels.Everything())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(works) != 2 {
+		t.Errorf("expected 2 works in the cluster namespaces, but got %d", len(works))
+	}
+	if _, err := workLister.ManifestWorks("cluster3").Get("work3"); err == nil {
+		t.Errorf("expected the work out of the cluster namespaces not to be cached")
+	}
+
+	// the events of the cluster namespaces are watched, and the events of the other namespaces are not.
+	if _, err := workClient.WorkV1().ManifestWorks("cluster3").Create(
+		ctx, newAddonWork("cluster3", "work4"), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := workClient.WorkV1().ManifestWorks("cluster2").Create(
+		ctx, newAddonWork("cluster2", "work5"), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := wait.Poll(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		_, err := workLister.ManifestWorks("cluster2").Get("work5")
+		return err == nil, nil
+	}); err != nil {
+		t.Fatalf("expected the work created in the cluster namespace to be cached: %v", err)
+	}
+	if _, err := workLister.ManifestWorks("cluster3").Get("work4"); err == nil {
+		t.Errorf("expected the work created out of the cluster namespaces not to be cached")
+	}
+
+	// the objects of the namespace of a cluster joining the cluster sets are added, and the objects of the
+	// namespace of a cluster leaving the cluster sets are deleted.
+	clusterNamespaces.add("cluster3")
+	clusterNamespaces.remove("cluster1")
+	if err := wait.Poll(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		_, err := workLister.ManifestWorks("cluster3").Get("work4")
+		return err == nil, nil
+	}); err != nil {
+		t.Fatalf("expected the works of the added namespace to be cached: %v", err)
+	}
+	if err := wait.Poll(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		_, err := workLister.ManifestWorks("cluster1").Get("work1")
+		return err != nil, nil
+	}); err != nil {
+		t.Fatalf("expected the works of the removed namespace to be deleted: %v", err)
+	}
+	works, err = workLister.List(labels.Everything())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(works) != 4 {
+		t.Errorf("expected 4 works in the cluster namespaces, but got %d", len(works))
+	}
+}
+
+func TestScopeInformersRelistNamespace(t *testing.T) {
+	workClient := fakework.NewSimpleClientset(
+		newAddonWork("cluster1", "work1"),
+		newAddonWork("cluster2", "work2"),
+	)
+	var lock sync.Mutex
+	watchers := map[string]*watch.FakeWatcher{}
+	workClient.PrependWatchReactor("manifestworks", func(action clienttesting.Action) (bool, watch.Interface, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		watcher := watch.NewFake()
+		watchers[action.GetNamespace()] = watcher
+		return true, watcher, nil
+	})
+	workInformers := workv1informers.NewSharedInformerFactory(workClient, 10*time.Minute)
+	addonInformers := addoninformers.NewSharedInformerFactory(fakeaddon.NewSimpleClientset(), 10*time.Minute)
+	kubeInformers := kubeinformers.NewSharedInformerFactory(fakekube.NewSimpleClientset(), 10*time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	scopeInformersToNamespaces(ctx.Done(), []string{"cluster1", "cluster2"}, addonInformers, nil,
+		workInformers, kubeInformers, addonLabelListOptions([]string{"test"}))
+	workInformer := workInformers.Work().V1().ManifestWorks().Informer()
+	workInformers.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), workInformer.HasSynced) {
+		t.Fatalf("failed to sync the work informer")
+	}
+
+	lists := func(namespace string) int {
+		count := 0
+		for _, action := range workClient.Actions() {
+			if action.GetVerb() == "list" && action.GetNamespace() == namespace {
+				count++
+			}
+		}
+		return count
+	}
+
+	// the closed watch of a namespace only relists the namespace.
+	if err := wait.Poll(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		return watchers["cluster1"] != nil, nil
+	}); err != nil {
+		t.Fatalf("expected the namespace to be watched: %v", err)
+	}
+	lock.Lock()
+	watchers["cluster1"].Stop()
+	lock.Unlock()
+	if err := wait.Poll(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return lists("cluster1") == 2, nil
+	}); err != nil {
+		t.Fatalf("expected the namespace of the closed watch to be relisted, but got %d lists", lists("cluster1"))
+	}
+	if count := lists("cluster2"); count != 1 {
+		t.Errorf("expected the other namespace not to be relisted, but got %d lists", count)
+	}
+}
@@ -2,6 +2,7 @@ package constants
 
 import (
 	"fmt"
+	"strings"
 
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 )
@@ -14,6 +15,146 @@ const (
 	InstallModeDefault         = "Default"
 )
 
+const (
+	// RollbackFailureThresholdAnnotationKey is the annotation key of ClusterManagementAddOn to opt in the automatic
+	// rollback of failed rollouts. The value is an absolute number (ex: 5) or a percentage (ex: 20%) of the addons
+	// in a placement. When more addons than the threshold fail the health check after adopting the new configs,
+	// the addons of the placement are rolled back to the lastKnownGoodConfig.
+	RollbackFailureThresholdAnnotationKey = "addon.open-cluster-management.io/rollback-failure-threshold"
+
+	// RollbackTimeoutAnnotationKey is the annotation key of ClusterManagementAddOn to set how long an addon can be
+	// unavailable after adopting the new configs before it is counted as failed, in the format of time.Duration.
+	// Defaults to 10m.
+	RollbackTimeoutAnnotationKey = "addon.open-cluster-management.io/rollback-timeout"
+)
+
+const (
+	// RolloutSegmentLabelAnnotationKey is the annotation key of ClusterManagementAddOn to set a label key of the
+	// ManagedClusters (ex: topology.kubernetes.io/region) to segment the rolling update. The clusters of a
+	// placement are grouped by the label value, and the groups are rolled out one by one in the order of the
+	// values, with the maxConcurrency scaled by the number of clusters in each group.
+	RolloutSegmentLabelAnnotationKey = "addon.open-cluster-management.io/rollout-segment-label"
+
+	// RolloutMaintenanceWindowAnnotationKey is the annotation key of ClusterManagementAddOn to set a daily
+	// maintenance window in UTC, in the format of "HH:MM-HH:MM" (ex: 22:00-04:00). Outside the window, the
+	// addons that have not started to apply the desired configs are held, while the addons being updated
+	// and the rollbacks continue.
+	RolloutMaintenanceWindowAnnotationKey = "addon.open-cluster-management.io/rollout-maintenance-window"
+
+	// RolloutSegmentSoakTimeAnnotationKey is the annotation key of ClusterManagementAddOn to set how long a
+	// completed rollout segment soaks before the next segment starts, in the format of time.Duration.
+	RolloutSegmentSoakTimeAnnotationKey = "addon.open-cluster-management.io/rollout-segment-soak-time"
+)
+
+const (
+	// AddonDisabledAnnotationKey is the annotation key of ManagedClusterAddOn to disable the addon without deleting
+	// it. If it is set to "true", the manifestworks of the addon agent are removed from the managed cluster, while
+	// the registration of the addon, including the approved client certificates and the hub kubeconfig secret, is
+	// preserved, so the addon is re-enabled quickly by removing the annotation.
+	AddonDisabledAnnotationKey = "addon.open-cluster-management.io/disabled"
+
+	// AddonPausedAnnotationKey is the annotation key of ManagedClusterAddOn, or of ClusterManagementAddOn to pause
+	// all its addons, to freeze the addon, e.g. during incident response. If it is set to "true", the manifestworks
+	// and the config references of the addon are not updated, while the health of the addon is still reported.
+	AddonPausedAnnotationKey = "addon.open-cluster-management.io/paused"
+
+	// AddonDeletionPolicyAnnotationKey is the annotation key of ManagedClusterAddOn to override the deletion
+	// policy of the addon agent. If it is set to "Orphan", the resources of the addon agent are kept on the
+	// managed cluster when the addon is deleted, if it is set to "Cascade", they are deleted. It should be set
+	// before deleting the addon, so the delete option is updated on the manifestworks first.
+	AddonDeletionPolicyAnnotationKey = "addon.open-cluster-management.io/deletion-policy"
+
+	// AddonAutoInstalledAnnotationKey is the annotation key set to "true" on the ManagedClusterAddOns created by the
+	// InstallStrategy of the addon, so only these addons are removed from the clusters excluded from the automatic
+	// installation.
+	AddonAutoInstalledAnnotationKey = "addon.open-cluster-management.io/auto-installed"
+
+	// AddonRegistrationNamespaceLabelKey is the label key set to "true" on the namespace of the registration secret
+	// and the lease of the addon agent, which is added to the deploy and the pre-delete hook manifestworks by the
+	// addon manager if the manifests of the addon agent do not create it.
+	AddonRegistrationNamespaceLabelKey = "addon.open-cluster-management.io/registration-namespace"
+
+	// AgentInstallNamespaceAnnotationKey is the annotation key of AddOnDeploymentConfig to set the namespace
+	// the addon agent is installed in on the managed clusters using the config. It is read by
+	// utils.AgentInstallNamespaceFromDeploymentConfigFunc.
+	AgentInstallNamespaceAnnotationKey = "addon.open-cluster-management.io/agent-install-namespace"
+
+	// ProxyConfigAnnotationKey is the annotation key of AddOnDeploymentConfig to set the proxy of the addon agents
+	// on the managed clusters using the config. The value is a json object, ex:
+	// {"httpProxy":"http://proxy:3128","httpsProxy":"https://proxy:3129","noProxy":"localhost","caBundle":"<base64>"}.
+	ProxyConfigAnnotationKey = "addon.open-cluster-management.io/proxy-config"
+
+	// ClusterImageRegistriesAnnotationKey is the annotation key of ManagedCluster to pull the images of the addon
+	// agents on the cluster from the mirror registries, ex: for the disconnected clusters. The value is a json
+	// object, a registry without source replaces the registries of all the images, ex:
+	// {"pullSecret":"open-cluster-management.pull-secret","registries":[{"mirror":"mirror.io/ocm","source":"quay.io/ocm"}]}.
+	ClusterImageRegistriesAnnotationKey = "open-cluster-management.io/image-registries"
+
+	// ResourceRequirementsAnnotationKey is the annotation key of AddOnDeploymentConfig to override the resource
+	// requirements of the addon agent containers on the managed clusters using the config. The value is a json
+	// list, the containerID is "<kind>:<workload name>:<container name>" and each part is a regular expression, ex:
+	// [{"containerID":"deployments:.*:agent","resources":{"requests":{"memory":"64Mi"},"limits":{"memory":"256Mi"}}}].
+	ResourceRequirementsAnnotationKey = "addon.open-cluster-management.io/resource-requirements"
+
+	// WorkLabelsAnnotationKey is the annotation key of a rendered manifest of the addon agent to set labels on the
+	// deploy ManifestWorks of the addon, so external tooling can classify the works, ex: ownership or cost-center
+	// labels. The value is a json map, ex: {"cost-center":"1234"}. The labels and annotations in the
+	// open-cluster-management.io domain are reserved by the framework and ignored.
+	WorkLabelsAnnotationKey = "addon.open-cluster-management.io/work-labels"
+
+	// WorkAnnotationsAnnotationKey is the annotation key of a rendered manifest of the addon agent to set
+	// annotations on the deploy ManifestWorks of the addon. The value is a json map, ex: {"ticket":"OPS-1234"}.
+	WorkAnnotationsAnnotationKey = "addon.open-cluster-management.io/work-annotations"
+
+	// ManifestDigestAnnotationKey is the annotation key of the deploy and pre-delete hook ManifestWorks of the
+	// addons with the manifest drift detection, the value is the content digest of the rendered manifests in the
+	// format of "sha256:<hex>", see utils.ManifestWorkDigest. The manifests of a work are modified out-of-band
+	// on the hub if their digest differs from the annotation.
+	ManifestDigestAnnotationKey = "addon.open-cluster-management.io/manifest-digest"
+
+	// AgentVersionAnnotationKey is the annotation key of the deploy ManifestWorks of the addon, the value is the
+	// desired version of the addon agent set by the Version of the agent addon options when the manifests are
+	// rendered.
+	AgentVersionAnnotationKey = "addon.open-cluster-management.io/agent-version"
+
+	// TokenExpirationAnnotationKey is the annotation key of the token kubeconfig secret of the addons with the
+	// token registration, the value is the time the ServiceAccount token in the kubeconfig expires at, in the
+	// format of RFC3339.
+	TokenExpirationAnnotationKey = "addon.open-cluster-management.io/token-expiration"
+
+	// TokenIssuedAnnotationKey is the annotation key of the token kubeconfig secret of the addons with the token
+	// registration, the value is the time the ServiceAccount token in the kubeconfig is issued at, in the format
+	// of RFC3339.
+	TokenIssuedAnnotationKey = "addon.open-cluster-management.io/token-issued"
+
+	// ServerSideApplyFieldManager is the field manager the work agent uses to apply the manifests of the addon
+	// agents with server side apply. The field manager of the work agent is required to have the prefix work-agent.
+	ServerSideApplyFieldManager = "work-agent-addon-framework"
+
+	// RetainWhenUnselectedAnnotationKey is the annotation key to opt out of deleting the ManagedClusterAddOn when
+	// its cluster is no longer selected by the placements of the install strategy. Setting it to "true" on the
+	// ClusterManagementAddOn applies to all the addons, and on a ManagedClusterAddOn applies to that addon only.
+	RetainWhenUnselectedAnnotationKey = "addon.open-cluster-management.io/retain-when-unselected"
+)
+
+const (
+	// FleetStatusRefreshIntervalAnnotationKey is the annotation key of ClusterManagementAddOn to set how often
+	// the fleet status of the addon is refreshed, in the format of time.Duration. Defaults to 30s, and the
+	// minimum is 10s.
+	FleetStatusRefreshIntervalAnnotationKey = "addon.open-cluster-management.io/fleet-status-refresh-interval"
+
+	// FleetStatusDataKey is the data key of the fleet status ConfigMap holding the fleet status in json.
+	FleetStatusDataKey = "status"
+)
+
+const (
+	// AddonRegistrationCleanupFinalizer is the finalizer of ManagedClusterAddOn to remove the registration of the
+	// addon agent on the hub, i.e. the Roles and RoleBindings of the permission config, the CSRs and the client
+	// certificates, only after the deploy manifestworks of the addon are deleted and the pre-delete hook is
+	// completed, so the addon agent keeps its hub access during the uninstall.
+	AddonRegistrationCleanupFinalizer = "addon.open-cluster-management.io/registration-cleanup"
+)
+
 // DeployWorkNamePrefix returns the prefix of the work name for the addon
 func DeployWorkNamePrefix(addonName string) string {
 	return fmt.Sprintf("addon-%s-deploy", addonName)
@@ -24,6 +165,53 @@ func DeployHostingWorkNamePrefix(addonNamespace, addonName string) string {
 	return fmt.Sprintf("%s-hosting-%s", DeployWorkNamePrefix(addonName), addonNamespace)
 }
 
+// FleetStatusConfigMapName returns the name of the ConfigMap publishing the fleet status of the addon
+func FleetStatusConfigMapName(addonName string) string {
+	return fmt.Sprintf("addon-%s-fleet-status", addonName)
+}
+
+// RolloutStateConfigMapName returns the name of the ConfigMap persisting the states of the segmented rollouts
+// of the addon
+func RolloutStateConfigMapName(addonName string) string {
+	return fmt.Sprintf("addon-%s-rollout-state", addonName)
+}
+
+// HubKubeConfigSecretName returns the name of the secret created by the klusterlet in the install namespace of
+// the addon agent, holding the hub kubeconfig of the registration with the kube-apiserver-client signer
+func HubKubeConfigSecretName(addonName string) string {
+	return fmt.Sprintf("%s-hub-kubeconfig", addonName)
+}
+
+// SignedCertSecretName returns the name of the secret in the managed cluster namespace holding the client
+// certificate of the addon agent issued by the hub for the signer
+func SignedCertSecretName(addonName, signerName string) string {
+	signer := strings.NewReplacer("/", "-", ".", "-").Replace(strings.ToLower(signerName))
+	return fmt.Sprintf("addon-%s-%s-client-cert", addonName, signer)
+}
+
+// TokenServiceAccountName returns the name of the ServiceAccount in the managed cluster namespace the addon
+// agent is registered as with the token registration
+func TokenServiceAccountName(addonName string) string {
+	return fmt.Sprintf("addon-%s-agent", addonName)
+}
+
+// TokenKubeConfigSecretName returns the name of the secret in the managed cluster namespace holding the hub
+// kubeconfig with the ServiceAccount token of the addon agent
+func TokenKubeConfigSecretName(addonName string) string {
+	return fmt.Sprintf("addon-%s-token-kubeconfig", addonName)
+}
+
+// TokenKubeConfigReaderName returns the name of the role and the role binding in the managed cluster namespace
+// granting the klusterlet to read the token kubeconfig secret of the addon
+func TokenKubeConfigReaderName(addonName string) string {
+	return fmt.Sprintf("open-cluster-management:%s:token-kubeconfig-reader", addonName)
+}
+
+// KlusterletGroup returns the group of the klusterlet of the managed cluster on the hub
+func KlusterletGroup(clusterName string) string {
+	return fmt.Sprintf("system:open-cluster-management:%s", clusterName)
+}
+
 // PreDeleteHookWorkName return the name of pre-delete work for the addon
 func PreDeleteHookWorkName(addonName string) string {
 	return fmt.Sprintf("addon-%s-pre-delete", addonName)
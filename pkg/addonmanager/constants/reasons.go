@@ -0,0 +1,258 @@
+package constants
+
+// ConditionReasonsVersion is the version of the condition reasons defined in this file.
+//
+// The condition reasons are machine-readable codes that external automation can rely on. Within a
+// version, a reason is never renamed, removed or reused for a different meaning, and it is always
+// set on the same condition type; new reasons may be added. Any incompatible change bumps the version.
+// The condition messages are human-readable and may change between releases.
+const ConditionReasonsVersion = "v1"
+
+// the condition types set by the framework in addition to the ones defined in the addon API
+const (
+	// AddonRegistrationApplied is a condition type representing whether the registration of the addon
+	// agent is configured on the hub.
+	AddonRegistrationApplied = "RegistrationApplied"
+
+	// AddonManifestApplyConflict is a condition type representing whether the server side apply of the
+	// manifests of the addon agent conflicts with the fields owned by another field manager on the managed
+	// cluster.
+	AddonManifestApplyConflict = "ManifestApplyConflict"
+
+	// AddonValuesInvalid is a condition type representing whether the values of the addon agent are invalid
+	// against the values schema of the addon, the manifests of the agent are not rendered with the invalid values.
+	AddonValuesInvalid = "ValuesInvalid"
+
+	// AddonManifestDrifted is a condition type representing whether the ManifestWorks of the addon are modified
+	// out-of-band on the hub, e.g. by kubectl edit, and differ from the rendered manifests of the addon agent.
+	AddonManifestDrifted = "ManifestDrifted"
+
+	// AddonAgentVersionUpToDate is a condition type representing whether the desired version of the addon agent
+	// is applied on the managed cluster.
+	AddonAgentVersionUpToDate = "AgentVersionUpToDate"
+)
+
+// the reasons of condition ManagedClusterAddOnManifestApplied and ManagedClusterAddOnHostingManifestApplied
+const (
+	// ManifestAppliedReasonAddonDisabled is the reason of condition ManifestApplied indicating the addon is
+	// disabled by the AddonDisabledAnnotationKey annotation and the manifestworks of the addon are removed.
+	ManifestAppliedReasonAddonDisabled = "AddonDisabled"
+
+	// ManifestAppliedReasonInstallThrottled is the reason of condition ManifestApplied indicating the first
+	// manifestworks of the addon are not created yet because the initial installs are throttled on the hub.
+	ManifestAppliedReasonInstallThrottled = "InstallThrottled"
+)
+
+// the reasons of condition AddonManifestApplyConflict
+const (
+	// ManifestApplyConflictReasonConflict is the reason of condition ManifestApplyConflict indicating some
+	// manifests of the addon fail to be applied since their fields are owned by another field manager.
+	ManifestApplyConflictReasonConflict = "ApplyConflict"
+
+	// ManifestApplyConflictReasonNoConflict is the reason of condition ManifestApplyConflict indicating the
+	// conflicts of the manifests of the addon are resolved.
+	ManifestApplyConflictReasonNoConflict = "NoConflict"
+)
+
+// the reasons of condition AddonValuesInvalid
+const (
+	// ValuesInvalidReasonSchemaValidationFailed is the reason of condition ValuesInvalid indicating the values
+	// of the addon agent fail the validation against the values schema of the addon.
+	ValuesInvalidReasonSchemaValidationFailed = "SchemaValidationFailed"
+
+	// ValuesInvalidReasonValuesValid is the reason of condition ValuesInvalid indicating the values of the addon
+	// agent are valid again.
+	ValuesInvalidReasonValuesValid = "ValuesValid"
+)
+
+// the reasons of condition AddonManifestDrifted
+const (
+	// ManifestDriftedReasonDrifted is the reason of condition ManifestDrifted indicating some ManifestWorks of
+	// the addon are modified out-of-band and the modifications are kept.
+	ManifestDriftedReasonDrifted = "Drifted"
+
+	// ManifestDriftedReasonNoDrift is the reason of condition ManifestDrifted indicating the ManifestWorks of the
+	// addon match the rendered manifests, or the drifted ones are restored.
+	ManifestDriftedReasonNoDrift = "NoDrift"
+)
+
+// the reasons of condition AddonAgentVersionUpToDate
+const (
+	// AgentVersionReasonUpToDate is the reason of condition AgentVersionUpToDate indicating the deploy
+	// ManifestWorks of the desired agent version are applied on the managed cluster.
+	AgentVersionReasonUpToDate = "UpToDate"
+
+	// AgentVersionReasonUpgrading is the reason of condition AgentVersionUpToDate indicating the managed cluster
+	// still runs another agent version, or the desired version is not applied yet.
+	AgentVersionReasonUpgrading = "Upgrading"
+
+	// AgentVersionReasonVersionUnknown is the reason of condition AgentVersionUpToDate indicating the deploy
+	// ManifestWorks of the addon are not found or not stamped with an agent version.
+	AgentVersionReasonVersionUnknown = "VersionUnknown"
+)
+
+// the reasons of condition AddonRegistrationApplied
+const (
+	// RegistrationReasonNilRegistration is the reason of condition RegistrationApplied indicating the addon
+	// does not require registration, or does not require a client certificate.
+	RegistrationReasonNilRegistration = "NilRegistration"
+
+	// RegistrationReasonSetPermissionFailed is the reason of condition RegistrationApplied indicating the
+	// permission of the addon agent failed to be set on the hub.
+	RegistrationReasonSetPermissionFailed = "SetPermissionFailed"
+
+	// RegistrationReasonGetInstallNamespaceFailed is the reason of condition RegistrationApplied indicating
+	// the agent install namespace of the addon can not be resolved.
+	RegistrationReasonGetInstallNamespaceFailed = "GetInstallNamespaceFailed"
+)
+
+// the reasons of condition ManagedClusterAddOnHookManifestCompleted
+const (
+	// HookManifestReasonCompleted is the reason of condition HookManifestCompleted indicating the pre-delete
+	// hook manifestWork of the addon is completed.
+	HookManifestReasonCompleted = "HookManifestIsCompleted"
+
+	// HookManifestReasonNotCompleted is the reason of condition HookManifestCompleted indicating the pre-delete
+	// hook manifestWork of the addon is not completed.
+	HookManifestReasonNotCompleted = "HookManifestIsNotCompleted"
+
+	// HookManifestReasonWaitingForManifestsCleanup is the reason of condition HookManifestCompleted indicating the
+	// pre-delete hook of the deleting addon waits for the deploy manifestworks to be removed.
+	HookManifestReasonWaitingForManifestsCleanup = "WaitingForManifestsCleanup"
+)
+
+// the reasons of condition ManagedClusterAddOnConditionAvailable in addition to the ones defined in the addon API
+const (
+	// AddonAvailableReasonProxyUnavailable is the reason of condition Available indicating the addon agent can
+	// not be probed by the proxy health prober, since the managed cluster is not reachable through the proxy.
+	AddonAvailableReasonProxyUnavailable = "ProxyUnavailable"
+)
+
+// the reasons of condition ManagedClusterAddOnConditionProgressing of ManagedClusterAddOn
+const (
+	// AddonProgressingReasonApplying is the reason of condition Progressing indicating the deploy manifestworks
+	// of the addon, or the desired configs of the addon, are being applied on the managed cluster.
+	AddonProgressingReasonApplying = "Applying"
+
+	// AddonProgressingReasonFailed is the reason of condition Progressing indicating some deploy manifestworks of
+	// the addon, or some of their resources, fail to be applied or are degraded on the managed cluster.
+	AddonProgressingReasonFailed = "Failed"
+
+	// AddonProgressingReasonCompleted is the reason of condition Progressing indicating all the deploy
+	// manifestworks of the addon are applied with the desired configs.
+	AddonProgressingReasonCompleted = "Completed"
+)
+
+// the reasons of condition Progressing of the install progressions in ClusterManagementAddOn
+const (
+	// ProgressingReasonUpgrading is the reason of the Progressing condition of an install progression
+	// indicating the desired configs are rolling out to the addons of the placement.
+	ProgressingReasonUpgrading = "Upgrading"
+
+	// ProgressingReasonUpgradeSucceed is the reason of the Progressing condition of an install progression
+	// indicating all the addons of the placement have applied the desired configs and are available.
+	ProgressingReasonUpgradeSucceed = "UpgradeSucceed"
+
+	// ProgressingReasonInvalidRolloutStrategy is the reason of the Progressing condition of an install
+	// progression indicating the rollout strategy of the placement is invalid.
+	ProgressingReasonInvalidRolloutStrategy = "InvalidRolloutStrategy"
+
+	// ProgressingReasonWaitingForCanary is the reason of the Progressing condition of an install progression
+	// indicating the desired configs have not passed the canary placement of the RollingUpdateWithCanary rollout.
+	ProgressingReasonWaitingForCanary = "WaitingForCanary"
+
+	// ProgressingReasonCanaryRegressed is the reason of the Progressing condition of an install progression
+	// indicating the addons on the canary placement are not available, and the rollout is held.
+	ProgressingReasonCanaryRegressed = "CanaryRegressed"
+
+	// ProgressingReasonWaitingForMaintenanceWindow is the reason of the Progressing condition of an install
+	// progression indicating the rollout is held until the maintenance window of the addon opens.
+	ProgressingReasonWaitingForMaintenanceWindow = "WaitingForMaintenanceWindow"
+
+	// ProgressingReasonRollingBack is the reason of the Progressing condition of an install progression
+	// indicating the rollout failed and the addons of the placement are rolling back to the lastKnownGoodConfig.
+	ProgressingReasonRollingBack = "RollingBack"
+
+	// ProgressingReasonRolledBack is the reason of the Progressing condition of an install progression
+	// indicating the rollout failed and all the addons of the placement are rolled back to the
+	// lastKnownGoodConfig.
+	ProgressingReasonRolledBack = "RolledBack"
+)
+
+// the condition types of the install progressions in ClusterManagementAddOn
+const (
+	// InstallProgressionConditionRolloutFailed is a condition type representing the rollout of the desired
+	// configs failed and the addons of the placement are rolled back to the lastKnownGoodConfig.
+	InstallProgressionConditionRolloutFailed = "RolloutFailed"
+
+	// InstallProgressionConditionRolloutSegment is a condition type surfacing the current segment of the
+	// segmented rolling update of the placement. The state of the rollout is persisted in the ConfigMap
+	// RolloutStateConfigMapName(addonName) instead.
+	InstallProgressionConditionRolloutSegment = "RolloutSegment"
+)
+
+// the reasons of condition RolloutSegment of the install progressions in ClusterManagementAddOn
+const (
+	// RolloutSegmentReasonRollingOut is the reason of condition RolloutSegment indicating the addons of the
+	// current segment are rolling out.
+	RolloutSegmentReasonRollingOut = "SegmentRollingOut"
+
+	// RolloutSegmentReasonSoaking is the reason of condition RolloutSegment indicating the addons of the current
+	// segment are held until the previous segment has soaked for the soak time.
+	RolloutSegmentReasonSoaking = "PreviousSegmentSoaking"
+)
+
+// the reasons of condition RolloutFailed of the install progressions in ClusterManagementAddOn
+const (
+	// RolloutFailedReasonFailureThresholdExceeded is the reason of condition RolloutFailed indicating more addons
+	// than the rollback failure threshold failed the health check after adopting the desired configs.
+	RolloutFailedReasonFailureThresholdExceeded = "FailureThresholdExceeded"
+)
+
+// the reasons of the CSR approved condition
+const (
+	// CSRReasonAutoApproved is the reason of the Approved condition of a CSR indicating the CSR is
+	// approved by the addon manager.
+	CSRReasonAutoApproved = "AutoApprovedByHubCSRApprovingController"
+)
+
+// the reasons of the events of the addon lifecycle recorded on the ManagedClusterAddOn
+const (
+	// EventReasonManifestsRendered is the reason of the event indicating the rendered manifests of the addon
+	// created or updated a deploy ManifestWork.
+	EventReasonManifestsRendered = "ManifestsRendered"
+
+	// EventReasonManifestWorkApplied is the reason of the event indicating the manifests of the addon are applied.
+	EventReasonManifestWorkApplied = "ManifestWorkApplied"
+
+	// EventReasonManifestWorkApplyFailed is the reason of the event indicating the manifests of the addon fail to
+	// be applied.
+	EventReasonManifestWorkApplyFailed = "ManifestWorkApplyFailed"
+
+	// EventReasonManifestWorkDrifted is the reason of the event indicating a ManifestWork of the addon is modified
+	// out-of-band on the hub.
+	EventReasonManifestWorkDrifted = "ManifestWorkDrifted"
+
+	// EventReasonRegistrationApproved is the reason of the event indicating a CSR of the addon agent is approved.
+	EventReasonRegistrationApproved = "RegistrationApproved"
+
+	// EventReasonHealthStateChanged is the reason of the event indicating the Available condition of the addon
+	// is changed.
+	EventReasonHealthStateChanged = "HealthStateChanged"
+
+	// EventReasonConfigRolloutStarted is the reason of the event indicating a new desired config of the addon
+	// starts to roll out.
+	EventReasonConfigRolloutStarted = "ConfigRolloutStarted"
+
+	// EventReasonConfigRolloutCompleted is the reason of the event indicating the desired config of the addon
+	// is applied.
+	EventReasonConfigRolloutCompleted = "ConfigRolloutCompleted"
+
+	// EventReasonManifestsCleanupTimeout is the reason of the event indicating the deploy manifestworks of the
+	// deleting addon are not removed within the manifests cleanup timeout, and the uninstall proceeds.
+	EventReasonManifestsCleanupTimeout = "ManifestsCleanupTimeout"
+
+	// EventReasonRegistrationRemoved is the reason of the event indicating the registration of the deleting addon
+	// is removed from the hub.
+	EventReasonRegistrationRemoved = "RegistrationRemoved"
+)
@@ -14,18 +14,18 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/dynamic/dynamiclister"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
-	"k8s.io/klog/v2"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
 	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
 	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
 
-	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/managementaddonconfig"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
+	"open-cluster-management.io/addon-framework/pkg/agent"
 	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 )
 
 const (
@@ -41,14 +41,16 @@ type addonConfigController struct {
 	addonLister   addonlisterv1alpha1.ManagedClusterAddOnLister
 	addonIndexer  cache.Indexer
 	configListers map[schema.GroupResource]dynamiclister.Lister
+	specHashFuncs map[schema.GroupResource]agent.ConfigSpecHashFunc
 	queue         workqueue.RateLimitingInterface
 }
 
 func NewAddonConfigController(
 	addonClient addonv1alpha1client.Interface,
 	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
-	configInformerFactory dynamicinformer.DynamicSharedInformerFactory,
+	configInformers *utils.SharedConfigInformers,
 	configGVRs map[schema.GroupVersionResource]bool,
+	specHashFuncs map[schema.GroupVersionResource]agent.ConfigSpecHashFunc,
 ) factory.Controller {
 	syncCtx := factory.NewSyncContext(controllerName)
 
@@ -57,10 +59,15 @@ func NewAddonConfigController(
 		addonLister:   addonInformers.Lister(),
 		addonIndexer:  addonInformers.Informer().GetIndexer(),
 		configListers: map[schema.GroupResource]dynamiclister.Lister{},
+		specHashFuncs: map[schema.GroupResource]agent.ConfigSpecHashFunc{},
 		queue:         syncCtx.Queue(),
 	}
 
-	configInformers := c.buildConfigInformers(configInformerFactory, configGVRs)
+	for gvr, specHashFunc := range specHashFuncs {
+		c.specHashFuncs[gvr.GroupResource()] = specHashFunc
+	}
+
+	informers := c.buildConfigInformers(configInformers, configGVRs)
 
 	if err := addonInformers.Informer().AddIndexers(cache.Indexers{byAddOnConfig: c.indexByConfig}); err != nil {
 		utilruntime.HandleError(err)
@@ -72,19 +79,22 @@ func NewAddonConfigController(
 			key, _ := cache.MetaNamespaceKeyFunc(obj)
 			return []string{key}
 		}, addonInformers.Informer()).
-		WithBareInformers(configInformers...).
+		WithBareInformers(informers...).
 		WithSync(c.sync).ToController(controllerName)
 }
 
 func (c *addonConfigController) buildConfigInformers(
-	configInformerFactory dynamicinformer.DynamicSharedInformerFactory,
+	configInformers *utils.SharedConfigInformers,
 	configGVRs map[schema.GroupVersionResource]bool,
 ) []factory.Informer {
-	configInformers := []factory.Informer{}
+	informers := []factory.Informer{}
 	for gvr := range configGVRs {
-		genericInformer := configInformerFactory.ForResource(gvr)
-		indexInformer := genericInformer.Informer()
-		_, err := indexInformer.AddEventHandler(
+		indexInformer, err := configInformers.Acquire(gvr, nil)
+		if err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+		_, err = indexInformer.AddEventHandler(
 			cache.ResourceEventHandlerFuncs{
 				AddFunc: c.enqueueAddOnsByConfig(gvr),
 				UpdateFunc: func(oldObj, newObj interface{}) {
@@ -96,10 +106,10 @@ func (c *addonConfigController) buildConfigInformers(
 		if err != nil {
 			utilruntime.HandleError(err)
 		}
-		configInformers = append(configInformers, indexInformer)
+		informers = append(informers, indexInformer)
 		c.configListers[schema.GroupResource{Group: gvr.Group, Resource: gvr.Resource}] = dynamiclister.New(indexInformer.GetIndexer(), gvr)
 	}
-	return configInformers
+	return informers
 }
 
 func (c *addonConfigController) enqueueAddOnsByConfig(gvr schema.GroupVersionResource) enqueueFunc {
@@ -151,6 +161,7 @@ func (c *addonConfigController) sync(ctx context.Context, syncCtx factory.SyncCo
 		// ignore addon whose key is invalid
 		return nil
 	}
+	ctx, _ = logging.WithAddon(ctx, addonNamespace, addonName)
 
 	addon, err := c.addonLister.ManagedClusterAddOns(addonNamespace).Get(addonName)
 	if errors.IsNotFound(err) {
@@ -176,7 +187,8 @@ func (c *addonConfigController) updateConfigSpecHashAndGenerations(addon *addona
 		supportedConfigSet[config] = true
 	}
 	for index, configReference := range addon.Status.ConfigReferences {
-		lister, ok := c.configListers[schema.GroupResource{Group: configReference.ConfigGroupResource.Group, Resource: configReference.ConfigGroupResource.Resource}]
+		groupResource := schema.GroupResource{Group: configReference.ConfigGroupResource.Group, Resource: configReference.ConfigGroupResource.Resource}
+		lister, ok := c.configListers[groupResource]
 		if !ok {
 			continue
 		}
@@ -210,7 +222,7 @@ func (c *addonConfigController) updateConfigSpecHashAndGenerations(addon *addona
 				continue
 			}
 			if configReference.ConfigGroupResource == addonconfig.ConfigGroupResource && configReference.DesiredConfig.ConfigReferent == addonconfig.ConfigReferent {
-				specHash, err := managementaddonconfig.GetSpecHash(config)
+				specHash, err := utils.ConfigSpecHash(c.specHashFuncs, groupResource, config)
 				if err != nil {
 					return err
 				}
@@ -254,7 +266,7 @@ func (c *addonConfigController) patchConfigReferences(ctx context.Context, old,
 		return fmt.Errorf("failed to create patch for addon %s: %w", new.Name, err)
 	}
 
-	klog.V(4).Infof("Patching addon %s/%s config reference with %s", new.Namespace, new.Name, string(patchBytes))
+	logging.FromContext(ctx).V(4).Info("Patching the config references", "patch", string(patchBytes))
 	_, err = c.addonClient.AddonV1alpha1().ManagedClusterAddOns(new.Namespace).Patch(
 		ctx,
 		new.Name,
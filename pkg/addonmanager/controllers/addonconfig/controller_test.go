@@ -15,6 +15,7 @@ import (
 	clienttesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
 	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
@@ -381,7 +382,7 @@ func TestSync(t *testing.T) {
 				configListers: map[schema.GroupResource]dynamiclister.Lister{},
 			}
 
-			ctrl.buildConfigInformers(configInformerFactory, map[schema.GroupVersionResource]bool{fakeGVR: true})
+			ctrl.buildConfigInformers(utils.NewSharedConfigInformers(configInformerFactory), map[schema.GroupVersionResource]bool{fakeGVR: true})
 
 			err := ctrl.sync(context.TODO(), syncContext, c.syncKey)
 			if err != nil {
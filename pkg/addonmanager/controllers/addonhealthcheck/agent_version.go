@@ -0,0 +1,82 @@
+package addonhealthcheck
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+// setAgentVersionCondition sets the AgentVersionUpToDate condition of the addon by comparing the desired version
+// of the agent with the versions stamped on the deploy works of the addon. A version is applied when the work
+// stamped with it is applied by the work agent at the current generation.
+func setAgentVersionCondition(addon *addonapiv1alpha1.ManagedClusterAddOn, desiredVersion string,
+	deployWorks []*workapiv1.ManifestWork) {
+	if len(desiredVersion) == 0 {
+		return
+	}
+
+	if len(deployWorks) == 0 {
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:    constants.AddonAgentVersionUpToDate,
+			Status:  metav1.ConditionUnknown,
+			Reason:  constants.AgentVersionReasonVersionUnknown,
+			Message: fmt.Sprintf("the desired agent version is %s, the deploy works of the addon are not found", desiredVersion),
+		})
+		return
+	}
+
+	versions, pending := sets.New[string](), false
+	for _, work := range deployWorks {
+		version, ok := work.Annotations[constants.AgentVersionAnnotationKey]
+		if !ok {
+			meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+				Type:   constants.AddonAgentVersionUpToDate,
+				Status: metav1.ConditionUnknown,
+				Reason: constants.AgentVersionReasonVersionUnknown,
+				Message: fmt.Sprintf("the desired agent version is %s, the work %s is not stamped with a version",
+					desiredVersion, work.Name),
+			})
+			return
+		}
+
+		applied := meta.FindStatusCondition(work.Status.Conditions, workapiv1.WorkApplied)
+		if applied == nil || applied.Status != metav1.ConditionTrue || applied.ObservedGeneration != work.Generation {
+			pending = true
+			continue
+		}
+		versions.Insert(version)
+	}
+
+	otherVersions := versions.Clone().Delete(desiredVersion)
+	switch {
+	case !pending && otherVersions.Len() == 0:
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:    constants.AddonAgentVersionUpToDate,
+			Status:  metav1.ConditionTrue,
+			Reason:  constants.AgentVersionReasonUpToDate,
+			Message: fmt.Sprintf("the desired agent version %s is applied", desiredVersion),
+		})
+	case otherVersions.Len() == 0:
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:    constants.AddonAgentVersionUpToDate,
+			Status:  metav1.ConditionFalse,
+			Reason:  constants.AgentVersionReasonUpgrading,
+			Message: fmt.Sprintf("the desired agent version %s is being applied", desiredVersion),
+		})
+	default:
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:   constants.AddonAgentVersionUpToDate,
+			Status: metav1.ConditionFalse,
+			Reason: constants.AgentVersionReasonUpgrading,
+			Message: fmt.Sprintf("the applied agent version is %s, the desired version is %s",
+				strings.Join(sets.List(otherVersions), ","), desiredVersion),
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package addonhealthcheck
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func TestSetAgentVersionCondition(t *testing.T) {
+	newWork := func(name, version string, applied bool) *workapiv1.ManifestWork {
+		work := addontesting.NewManifestWork(name, "cluster1")
+		work.Generation = 2
+		if len(version) > 0 {
+			work.Annotations = map[string]string{constants.AgentVersionAnnotationKey: version}
+		}
+		observedGeneration := int64(1)
+		if applied {
+			observedGeneration = 2
+		}
+		work.Status.Conditions = []metav1.Condition{{
+			Type:               workapiv1.WorkApplied,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: observedGeneration,
+		}}
+		return work
+	}
+
+	cases := []struct {
+		name           string
+		desiredVersion string
+		works          []*workapiv1.ManifestWork
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name:  "no desired version",
+			works: []*workapiv1.ManifestWork{newWork("addon-test-deploy-0", "v1", true)},
+		},
+		{
+			name:           "no deploy works",
+			desiredVersion: "v2",
+			expectedStatus: metav1.ConditionUnknown,
+			expectedReason: constants.AgentVersionReasonVersionUnknown,
+		},
+		{
+			name:           "work is not stamped",
+			desiredVersion: "v2",
+			works:          []*workapiv1.ManifestWork{newWork("addon-test-deploy-0", "", true)},
+			expectedStatus: metav1.ConditionUnknown,
+			expectedReason: constants.AgentVersionReasonVersionUnknown,
+		},
+		{
+			name:           "desired version is applied",
+			desiredVersion: "v2",
+			works: []*workapiv1.ManifestWork{
+				newWork("addon-test-deploy-0", "v2", true),
+				newWork("addon-test-deploy-1", "v2", true),
+			},
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: constants.AgentVersionReasonUpToDate,
+		},
+		{
+			name:           "desired version is being applied",
+			desiredVersion: "v2",
+			works: []*workapiv1.ManifestWork{
+				newWork("addon-test-deploy-0", "v2", true),
+				newWork("addon-test-deploy-1", "v2", false),
+			},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: constants.AgentVersionReasonUpgrading,
+		},
+		{
+			name:           "old version is applied",
+			desiredVersion: "v2",
+			works: []*workapiv1.ManifestWork{
+				newWork("addon-test-deploy-0", "v1", true),
+				newWork("addon-test-deploy-1", "v2", true),
+			},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: constants.AgentVersionReasonUpgrading,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addon := addontesting.NewAddon("test", "cluster1")
+			setAgentVersionCondition(addon, c.desiredVersion, c.works)
+
+			cond := meta.FindStatusCondition(addon.Status.Conditions, constants.AddonAgentVersionUpToDate)
+			if len(c.expectedStatus) == 0 {
+				if cond != nil {
+					t.Errorf("expected no condition, but got %v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != c.expectedStatus || cond.Reason != c.expectedReason {
+				t.Errorf("expected condition %s/%s, but got %v", c.expectedStatus, c.expectedReason, cond)
+			}
+		})
+	}
+}
@@ -3,16 +3,19 @@ package addonhealthcheck
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/klog/v2"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
 	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
@@ -22,6 +25,7 @@ import (
 	workapiv1 "open-cluster-management.io/api/work/v1"
 
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
 	"open-cluster-management.io/addon-framework/pkg/utils"
@@ -31,6 +35,7 @@ import (
 // TODO: consider health check in Hosted mode.
 type addonHealthCheckController struct {
 	addonClient               addonv1alpha1client.Interface
+	eventRecorder             utils.AddonEventRecorder
 	managedClusterAddonLister addonlisterv1alpha1.ManagedClusterAddOnLister
 	workLister                worklister.ManifestWorkLister
 	agentAddons               map[string]agent.AgentAddon
@@ -38,12 +43,14 @@ type addonHealthCheckController struct {
 
 func NewAddonHealthCheckController(
 	addonClient addonv1alpha1client.Interface,
+	eventRecorder utils.AddonEventRecorder,
 	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
 	workInformers workinformers.ManifestWorkInformer,
 	agentAddons map[string]agent.AgentAddon,
 ) factory.Controller {
 	c := &addonHealthCheckController{
 		addonClient:               addonClient,
+		eventRecorder:             eventRecorder,
 		managedClusterAddonLister: addonInformers.Lister(),
 		workLister:                workInformers.Lister(),
 		agentAddons:               agentAddons,
@@ -88,6 +95,8 @@ func NewAddonHealthCheckController(
 			},
 			workInformers.Informer(),
 		).
+		WithQueuePartitionFunc(factory.NamePartitionFunc).
+		WithPriorityFunc(utils.AddonHealthDegraded).
 		WithSync(c.sync).
 		ToController("addon-healthcheck-controller")
 }
@@ -99,7 +108,8 @@ func (c *addonHealthCheckController) sync(ctx context.Context, syncCtx factory.S
 		return nil
 	}
 
-	klog.V(4).Infof("Reconciling addon health checker on cluster %q", clusterName)
+	ctx, logger := logging.WithAddon(ctx, clusterName, addonName)
+	logger.V(4).Info("Reconciling addon health checker")
 	managedClusterAddon, err := c.managedClusterAddonLister.ManagedClusterAddOns(clusterName).Get(addonName)
 	if errors.IsNotFound(err) {
 		return nil
@@ -113,22 +123,42 @@ func (c *addonHealthCheckController) sync(ctx context.Context, syncCtx factory.S
 		return nil
 	}
 
-	return c.syncAddonHealthChecker(ctx, managedClusterAddon, agentAddon)
+	if err := c.syncAddonHealthChecker(ctx, managedClusterAddon, agentAddon); err != nil {
+		return err
+	}
+
+	// the proxy prober probes the addon periodically since no event is triggered by the health of the agent.
+	if prober := agentAddon.GetAgentAddonOptions().HealthProber; prober != nil &&
+		prober.Type == agent.HealthProberTypeProxy && prober.ProxyProber != nil {
+		syncCtx.Queue().AddAfter(key, proxyProbePeriod(prober.ProxyProber))
+	}
+	return nil
 }
 
+// syncAddonHealthChecker reconciles the health check mode, the available condition and the agent version condition
+// of the addon, the changes are patched together at the end of the sync.
 func (c *addonHealthCheckController) syncAddonHealthChecker(ctx context.Context,
 	addon *addonapiv1alpha1.ManagedClusterAddOn, agentAddon agent.AgentAddon) error {
 	// for in-place edit
+	oldAddon := addon
 	addon = addon.DeepCopy()
-	// reconcile health check mode
-	var expectedHealthCheckMode addonapiv1alpha1.HealthCheckMode
+
+	if version := agentAddon.GetAgentAddonOptions().Version; len(version) > 0 {
+		deployWorks, err := c.deployWorks(addon)
+		if err != nil {
+			return err
+		}
+		setAgentVersionCondition(addon, version, deployWorks)
+	}
 
 	if agentAddon.GetAgentAddonOptions().HealthProber == nil {
-		return nil
+		return utils.PatchAddonStatus(ctx, c.addonClient, c.eventRecorder, addon, oldAddon)
 	}
 
+	// reconcile health check mode
+	var expectedHealthCheckMode addonapiv1alpha1.HealthCheckMode
 	switch agentAddon.GetAgentAddonOptions().HealthProber.Type {
-	case agent.HealthProberTypeWork, agent.HealthProberTypeNone:
+	case agent.HealthProberTypeWork, agent.HealthProberTypeProxy, agent.HealthProberTypeNone:
 		expectedHealthCheckMode = addonapiv1alpha1.HealthCheckModeCustomized
 	case agent.HealthProberTypeLease:
 		expectedHealthCheckMode = addonapiv1alpha1.HealthCheckModeLease
@@ -136,21 +166,37 @@ func (c *addonHealthCheckController) syncAddonHealthChecker(ctx context.Context,
 		expectedHealthCheckMode = addonapiv1alpha1.HealthCheckModeLease
 	}
 
-	if expectedHealthCheckMode != addon.Status.HealthCheck.Mode {
-		addon.Status.HealthCheck.Mode = expectedHealthCheckMode
-		_, err := c.addonClient.AddonV1alpha1().ManagedClusterAddOns(addon.Namespace).
-			UpdateStatus(ctx, addon, metav1.UpdateOptions{})
-		if err != nil {
-			return err
-		}
-	}
+	addon.Status.HealthCheck.Mode = expectedHealthCheckMode
 
-	return c.probeAddonStatus(ctx, addon, agentAddon)
+	if err := c.probeAddonStatus(addon, agentAddon); err != nil {
+		return err
+	}
+	if prober := agentAddon.GetAgentAddonOptions().HealthProber; prober.Type == agent.HealthProberTypeProxy &&
+		prober.ProxyProber != nil {
+		probeProxyAddonStatus(ctx, addon, prober.ProxyProber)
+	}
+	return utils.PatchAddonStatus(ctx, c.addonClient, c.eventRecorder, addon, oldAddon)
 }
 
-func (c *addonHealthCheckController) probeAddonStatus(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn, agentAddon agent.AgentAddon) error {
-	addonCopy := addon.DeepCopy()
+// deployWorks returns the deploy works of the addon in the cluster namespace.
+func (c *addonHealthCheckController) deployWorks(addon *addonapiv1alpha1.ManagedClusterAddOn) ([]*workapiv1.ManifestWork, error) {
+	requirement, _ := labels.NewRequirement(addonapiv1alpha1.AddonLabelKey, selection.Equals, []string{addon.Name})
+	works, err := c.workLister.ManifestWorks(addon.Namespace).List(labels.NewSelector().Add(*requirement))
+	if err != nil {
+		return nil, err
+	}
+
+	var deployWorks []*workapiv1.ManifestWork
+	for _, work := range works {
+		if strings.HasPrefix(work.Name, constants.DeployWorkNamePrefix(addon.Name)) {
+			deployWorks = append(deployWorks, work)
+		}
+	}
+	return deployWorks, nil
+}
 
+// probeAddonStatus sets the available condition of the addon by the work prober.
+func (c *addonHealthCheckController) probeAddonStatus(addon *addonapiv1alpha1.ManagedClusterAddOn, agentAddon agent.AgentAddon) error {
 	if agentAddon.GetAgentAddonOptions().HealthProber == nil {
 		return nil
 	}
@@ -164,89 +210,219 @@ func (c *addonHealthCheckController) probeAddonStatus(ctx context.Context, addon
 
 	addonWorks, err := c.workLister.ManifestWorks(addon.Namespace).List(selector)
 	if err != nil || len(addonWorks) == 0 {
-		meta.SetStatusCondition(&addonCopy.Status.Conditions, metav1.Condition{
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
 			Type:    "Available",
 			Status:  metav1.ConditionUnknown,
-			Reason:  "WorkNotFound",
+			Reason:  addonapiv1alpha1.AddonAvailableReasonWorkNotFound,
 			Message: "Work for addon is not found",
 		})
-		return utils.PatchAddonCondition(ctx, c.addonClient, addonCopy, addon)
+		return nil
 	}
 
-	manifestConditions := []workapiv1.ManifestCondition{}
+	var deployWorks []*workapiv1.ManifestWork
 	for _, work := range addonWorks {
-		if !strings.HasPrefix(work.Name, constants.DeployWorkNamePrefix(addon.Name)) {
-			continue
+		if strings.HasPrefix(work.Name, constants.DeployWorkNamePrefix(addon.Name)) {
+			deployWorks = append(deployWorks, work)
 		}
+	}
+
+	// the manifests may be split into multiple works, all of them are required to probe the addon.
+	if missing := missingDeployWorks(deployWorks); len(missing) > 0 {
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:    addonapiv1alpha1.ManagedClusterAddOnConditionAvailable,
+			Status:  metav1.ConditionUnknown,
+			Reason:  addonapiv1alpha1.AddonAvailableReasonWorkNotFound,
+			Message: fmt.Sprintf("Work %s for addon is not found", strings.Join(missing, ",")),
+		})
+		return nil
+	}
+
+	manifestConditions := []workapiv1.ManifestCondition{}
+	var manifests []probedManifest
+	for _, work := range deployWorks {
 		// Check the overall work available condition at first.
 		workCond := meta.FindStatusCondition(work.Status.Conditions, workapiv1.WorkAvailable)
 		switch {
 		case workCond == nil:
-			meta.SetStatusCondition(&addonCopy.Status.Conditions, metav1.Condition{
+			meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
 				Type:    addonapiv1alpha1.ManagedClusterAddOnConditionAvailable,
 				Status:  metav1.ConditionUnknown,
 				Reason:  addonapiv1alpha1.AddonAvailableReasonWorkNotApply,
 				Message: "Work is not applied yet",
 			})
-			return utils.PatchAddonCondition(ctx, c.addonClient, addonCopy, addon)
+			return nil
 		case workCond.Status == metav1.ConditionFalse:
-			meta.SetStatusCondition(&addonCopy.Status.Conditions, metav1.Condition{
+			meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
 				Type:    addonapiv1alpha1.ManagedClusterAddOnConditionAvailable,
 				Status:  metav1.ConditionFalse,
 				Reason:  addonapiv1alpha1.AddonAvailableReasonWorkApplyFailed,
 				Message: workCond.Message,
 			})
-			return utils.PatchAddonCondition(ctx, c.addonClient, addonCopy, addon)
+			return nil
 		}
 
 		manifestConditions = append(manifestConditions, work.Status.ResourceStatus.Manifests...)
+		for _, condition := range work.Status.ResourceStatus.Manifests {
+			manifests = append(manifests, probedManifest{work: work, condition: condition})
+		}
 	}
 
 	if agentAddon.GetAgentAddonOptions().HealthProber.WorkProber == nil {
-		meta.SetStatusCondition(&addonCopy.Status.Conditions, metav1.Condition{
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
 			Type:    addonapiv1alpha1.ManagedClusterAddOnConditionAvailable,
 			Status:  metav1.ConditionTrue,
 			Reason:  addonapiv1alpha1.AddonAvailableReasonWorkApply,
 			Message: "Addon work is applied",
 		})
-		return utils.PatchAddonCondition(ctx, c.addonClient, addonCopy, addon)
+		return nil
 	}
 
 	probeFields := agentAddon.GetAgentAddonOptions().HealthProber.WorkProber.ProbeFields
 
 	for _, field := range probeFields {
-		result := findResultByIdentifier(field.ResourceIdentifier, manifestConditions)
-		// if no results are returned. it is possible that work agent has not returned the feedback value.
-		// mark condition to unknown
-		if result == nil {
-			meta.SetStatusCondition(&addonCopy.Status.Conditions, metav1.Condition{
-				Type:    addonapiv1alpha1.ManagedClusterAddOnConditionAvailable,
-				Status:  metav1.ConditionUnknown,
-				Reason:  addonapiv1alpha1.AddonAvailableReasonNoProbeResult,
-				Message: "Probe results are not returned",
-			})
-			return utils.PatchAddonCondition(ctx, c.addonClient, addonCopy, addon)
+		targets, err := probeTargets(field, manifestConditions, manifests)
+		if err != nil {
+			return err
 		}
 
-		err := agentAddon.GetAgentAddonOptions().HealthProber.WorkProber.HealthCheck(field.ResourceIdentifier, *result)
-		if err != nil {
-			meta.SetStatusCondition(&addonCopy.Status.Conditions, metav1.Condition{
-				Type:    addonapiv1alpha1.ManagedClusterAddOnConditionAvailable,
-				Status:  metav1.ConditionFalse,
-				Reason:  addonapiv1alpha1.AddonAvailableReasonProbeUnavailable,
-				Message: fmt.Sprintf("Probe addon unavailable with err %v", err),
-			})
-			return utils.PatchAddonCondition(ctx, c.addonClient, addonCopy, addon)
+		for _, target := range targets {
+			// if no results are returned. it is possible that work agent has not returned the feedback value.
+			// mark condition to unknown
+			if target.result == nil {
+				meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+					Type:    addonapiv1alpha1.ManagedClusterAddOnConditionAvailable,
+					Status:  metav1.ConditionUnknown,
+					Reason:  addonapiv1alpha1.AddonAvailableReasonNoProbeResult,
+					Message: "Probe results are not returned",
+				})
+				return nil
+			}
+
+			err := agentAddon.GetAgentAddonOptions().HealthProber.WorkProber.HealthCheck(target.identifier, *target.result)
+			if err != nil {
+				meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+					Type:    addonapiv1alpha1.ManagedClusterAddOnConditionAvailable,
+					Status:  metav1.ConditionFalse,
+					Reason:  addonapiv1alpha1.AddonAvailableReasonProbeUnavailable,
+					Message: fmt.Sprintf("Probe addon unavailable with err %v", err),
+				})
+				return nil
+			}
 		}
 	}
 
-	meta.SetStatusCondition(&addonCopy.Status.Conditions, metav1.Condition{
+	meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
 		Type:    addonapiv1alpha1.ManagedClusterAddOnConditionAvailable,
 		Status:  metav1.ConditionTrue,
 		Reason:  addonapiv1alpha1.AddonAvailableReasonProbeAvailable,
 		Message: "Addon is available",
 	})
-	return utils.PatchAddonCondition(ctx, c.addonClient, addonCopy, addon)
+	return nil
+}
+
+// missingDeployWorks returns the names of the missing works in the split deploy works. The deploy works are
+// named <prefix>-<index> and the indexes are contiguous from 0.
+func missingDeployWorks(works []*workapiv1.ManifestWork) []string {
+	indexes := map[string]sets.Set[int]{}
+	for _, work := range works {
+		i := strings.LastIndex(work.Name, "-")
+		if i < 0 {
+			continue
+		}
+		index, err := strconv.Atoi(work.Name[i+1:])
+		if err != nil || index < 0 {
+			continue
+		}
+		prefix := work.Name[:i]
+		if _, ok := indexes[prefix]; !ok {
+			indexes[prefix] = sets.New[int]()
+		}
+		indexes[prefix].Insert(index)
+	}
+
+	var missing []string
+	for prefix, set := range indexes {
+		sorted := sets.List(set)
+		for index := 0; index < sorted[len(sorted)-1]; index++ {
+			if !set.Has(index) {
+				missing = append(missing, fmt.Sprintf("%s-%d", prefix, index))
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// probedManifest is a manifest of the deploy works with its status.
+type probedManifest struct {
+	work      *workapiv1.ManifestWork
+	condition workapiv1.ManifestCondition
+}
+
+func (m probedManifest) identifier() workapiv1.ResourceIdentifier {
+	return workapiv1.ResourceIdentifier{
+		Group:     m.condition.ResourceMeta.Group,
+		Resource:  m.condition.ResourceMeta.Resource,
+		Name:      m.condition.ResourceMeta.Name,
+		Namespace: m.condition.ResourceMeta.Namespace,
+	}
+}
+
+// labels returns the labels of the manifest in the spec of the work.
+func (m probedManifest) labels() (map[string]string, error) {
+	ordinal := int(m.condition.ResourceMeta.Ordinal)
+	if ordinal < 0 || ordinal >= len(m.work.Spec.Workload.Manifests) {
+		return nil, nil
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(m.work.Spec.Workload.Manifests[ordinal].Raw); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest %d of work %s/%s: %w", ordinal, m.work.Namespace, m.work.Name, err)
+	}
+	return obj.GetLabels(), nil
+}
+
+// probeTarget is a resource probed by a probe field with its probe result.
+type probeTarget struct {
+	identifier workapiv1.ResourceIdentifier
+	result     *workapiv1.StatusFeedbackResult
+}
+
+// probeTargets returns the resources probed by the probe field. The probe field matching the resources by
+// patterns is expanded against the manifests of the works, and it probes nothing if no manifest matches.
+func probeTargets(field agent.ProbeField, manifestConditions []workapiv1.ManifestCondition,
+	manifests []probedManifest) ([]probeTarget, error) {
+	if !field.IsPattern() {
+		return []probeTarget{{
+			identifier: field.ResourceIdentifier,
+			result:     findResultByIdentifier(field.ResourceIdentifier, manifestConditions),
+		}}, nil
+	}
+
+	var targets []probeTarget
+	for _, manifest := range manifests {
+		var labels map[string]string
+		if field.LabelSelector != nil {
+			var err error
+			if labels, err = manifest.labels(); err != nil {
+				return nil, err
+			}
+		}
+		identifier := manifest.identifier()
+		matched, err := field.Matches(identifier, labels)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
+
+		target := probeTarget{identifier: identifier}
+		if len(manifest.condition.StatusFeedbacks.Values) > 0 {
+			target.result = manifest.condition.StatusFeedbacks.DeepCopy()
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
 }
 
 func findResultByIdentifier(identifier workapiv1.ResourceIdentifier, manifestConditions []workapiv1.ManifestCondition) *workapiv1.StatusFeedbackResult {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -62,9 +63,12 @@ func TestReconcile(t *testing.T) {
 			name:  "update addon health check mode",
 			addon: []runtime.Object{addontesting.NewAddon("test", "cluster1")},
 			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
-				addontesting.AssertActions(t, actions, "update")
-				actual := actions[0].(clienttesting.UpdateActionImpl).Object
-				addOn := actual.(*addonapiv1alpha1.ManagedClusterAddOn)
+				addontesting.AssertActions(t, actions, "patch")
+				patch := actions[0].(clienttesting.PatchActionImpl).Patch
+				addOn := &addonapiv1alpha1.ManagedClusterAddOn{}
+				if err := json.Unmarshal(patch, addOn); err != nil {
+					t.Fatal(err)
+				}
 				if addOn.Status.HealthCheck.Mode != addonapiv1alpha1.HealthCheckModeCustomized {
 					t.Errorf("Health check mode is not correct, expected %s but got %s",
 						addonapiv1alpha1.HealthCheckModeCustomized, addOn.Status.HealthCheck.Mode)
@@ -526,3 +530,132 @@ func TestReconcileWithProbe(t *testing.T) {
 		t.Errorf("addon condition should be available: %v", addOn.Status.Conditions)
 	}
 }
+
+func TestMissingDeployWorks(t *testing.T) {
+	newWorks := func(names ...string) []*workapiv1.ManifestWork {
+		works := []*workapiv1.ManifestWork{}
+		for _, name := range names {
+			works = append(works, addontesting.NewManifestWork(name, "cluster1"))
+		}
+		return works
+	}
+
+	cases := []struct {
+		name            string
+		works           []*workapiv1.ManifestWork
+		expectedMissing []string
+	}{
+		{
+			name: "no works",
+		},
+		{
+			name:  "all works exist",
+			works: newWorks("addon-test-deploy-1", "addon-test-deploy-0", "addon-test-deploy-hosting-cluster2-0"),
+		},
+		{
+			name:            "works are missing",
+			works:           newWorks("addon-test-deploy-0", "addon-test-deploy-3", "addon-test-deploy-hosting-cluster2-1"),
+			expectedMissing: []string{"addon-test-deploy-1", "addon-test-deploy-2", "addon-test-deploy-hosting-cluster2-0"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			missing := missingDeployWorks(c.works)
+			if !reflect.DeepEqual(missing, c.expectedMissing) {
+				t.Errorf("expected missing works %v, but got %v", c.expectedMissing, missing)
+			}
+		})
+	}
+}
+
+func TestProbeTargets(t *testing.T) {
+	newManifest := func(namespace string, labels map[string]string) workapiv1.Manifest {
+		obj := addontesting.NewUnstructured("apps/v1", "Deployment", namespace, "agent")
+		obj.SetLabels(labels)
+		raw, _ := obj.MarshalJSON()
+		return workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: raw}}
+	}
+	newCondition := func(ordinal int32, namespace string, values ...workapiv1.FeedbackValue) workapiv1.ManifestCondition {
+		return workapiv1.ManifestCondition{
+			ResourceMeta: workapiv1.ManifestResourceMeta{
+				Ordinal: ordinal, Group: "apps", Resource: "deployments", Name: "agent", Namespace: namespace,
+			},
+			StatusFeedbacks: workapiv1.StatusFeedbackResult{Values: values},
+		}
+	}
+	identifier := func(namespace string) workapiv1.ResourceIdentifier {
+		return workapiv1.ResourceIdentifier{Group: "apps", Resource: "deployments", Name: "agent", Namespace: namespace}
+	}
+
+	work := &workapiv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cluster1", Name: "addon-test-deploy-0"},
+		Spec: workapiv1.ManifestWorkSpec{
+			Workload: workapiv1.ManifestsTemplate{Manifests: []workapiv1.Manifest{
+				newManifest("tenant-a", map[string]string{"tenant": "a"}),
+				newManifest("tenant-b", map[string]string{"tenant": "b"}),
+				newManifest("addon-ns", nil),
+			}},
+		},
+	}
+	conditions := []workapiv1.ManifestCondition{
+		newCondition(0, "tenant-a", workapiv1.FeedbackValue{Name: "replicas"}),
+		newCondition(1, "tenant-b"),
+		newCondition(2, "addon-ns", workapiv1.FeedbackValue{Name: "replicas"}),
+	}
+	var manifests []probedManifest
+	for _, condition := range conditions {
+		manifests = append(manifests, probedManifest{work: work, condition: condition})
+	}
+
+	cases := []struct {
+		name            string
+		field           agent.ProbeField
+		expectedTargets map[string]bool
+	}{
+		{
+			name:            "single resource",
+			field:           agent.ProbeField{ResourceIdentifier: identifier("addon-ns")},
+			expectedTargets: map[string]bool{"addon-ns": true},
+		},
+		{
+			name:            "single resource without result",
+			field:           agent.ProbeField{ResourceIdentifier: identifier("tenant-b")},
+			expectedTargets: map[string]bool{"tenant-b": false},
+		},
+		{
+			name:            "wildcard namespace",
+			field:           agent.ProbeField{ResourceIdentifier: identifier("tenant-*")},
+			expectedTargets: map[string]bool{"tenant-a": true, "tenant-b": false},
+		},
+		{
+			name: "label selector",
+			field: agent.ProbeField{
+				ResourceIdentifier: identifier("*"),
+				LabelSelector:      &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "a"}},
+			},
+			expectedTargets: map[string]bool{"tenant-a": true},
+		},
+		{
+			name:            "no resource matched",
+			field:           agent.ProbeField{ResourceIdentifier: identifier("other-*")},
+			expectedTargets: map[string]bool{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			targets, err := probeTargets(c.field, conditions, manifests)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			actual := map[string]bool{}
+			for _, target := range targets {
+				actual[target.identifier.Namespace] = target.result != nil
+			}
+			if !reflect.DeepEqual(actual, c.expectedTargets) {
+				t.Errorf("expected targets %v, but got %v", c.expectedTargets, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package addonhealthcheck
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+)
+
+const (
+	defaultProxyProbePeriod  = 30 * time.Second
+	defaultProxyProbeTimeout = 10 * time.Second
+)
+
+// proxyProbePeriod returns how often the addon is probed by the proxy prober.
+func proxyProbePeriod(prober *agent.ProxyHealthProber) time.Duration {
+	if prober.Period > 0 {
+		return prober.Period
+	}
+	return defaultProxyProbePeriod
+}
+
+// probeProxyAddonStatus sets the available condition of the addon by probing the agent on the managed cluster
+// through the proxy.
+func probeProxyAddonStatus(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn, prober *agent.ProxyHealthProber) {
+	kubeClient, err := proxyKubeClient(prober, addon.Namespace)
+	if err != nil {
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:    addonapiv1alpha1.ManagedClusterAddOnConditionAvailable,
+			Status:  metav1.ConditionUnknown,
+			Reason:  constants.AddonAvailableReasonProxyUnavailable,
+			Message: fmt.Sprintf("Failed to access the cluster through the proxy: %v", err),
+		})
+		return
+	}
+
+	timeout := prober.Timeout
+	if timeout <= 0 {
+		timeout = defaultProxyProbeTimeout
+	}
+
+	for _, probe := range prober.Probes {
+		path := proxyProbePath(probe)
+		statusCode, body, err := doProxyProbe(ctx, kubeClient, path, timeout)
+		if err != nil {
+			// the request does not reach the managed cluster, the health of the addon is unknown.
+			meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+				Type:    addonapiv1alpha1.ManagedClusterAddOnConditionAvailable,
+				Status:  metav1.ConditionUnknown,
+				Reason:  constants.AddonAvailableReasonProxyUnavailable,
+				Message: fmt.Sprintf("Failed to probe %s through the proxy: %v", path, err),
+			})
+			return
+		}
+
+		if err := checkProxyProbeResponse(probe, statusCode, body); err != nil {
+			meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+				Type:    addonapiv1alpha1.ManagedClusterAddOnConditionAvailable,
+				Status:  metav1.ConditionFalse,
+				Reason:  addonapiv1alpha1.AddonAvailableReasonProbeUnavailable,
+				Message: fmt.Sprintf("Probe %s unavailable with err %v", path, err),
+			})
+			return
+		}
+	}
+
+	meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+		Type:    addonapiv1alpha1.ManagedClusterAddOnConditionAvailable,
+		Status:  metav1.ConditionTrue,
+		Reason:  addonapiv1alpha1.AddonAvailableReasonProbeAvailable,
+		Message: "Addon is available",
+	})
+}
+
+func proxyKubeClient(prober *agent.ProxyHealthProber, clusterName string) (kubernetes.Interface, error) {
+	config, err := prober.ClusterConfig(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// proxyProbePath returns the kube api path of the probe, the path of a service is probed through the service
+// proxy of the kube-apiserver.
+func proxyProbePath(probe agent.ProxyProbe) string {
+	path := probe.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if probe.Service == nil {
+		return path
+	}
+
+	service := fmt.Sprintf("%s:%s", probe.Service.Name, probe.Service.Port)
+	if len(probe.Service.Scheme) > 0 {
+		service = fmt.Sprintf("%s:%s", probe.Service.Scheme, service)
+	}
+	return fmt.Sprintf("/api/v1/namespaces/%s/services/%s/proxy%s", probe.Service.Namespace, service, path)
+}
+
+// doProxyProbe gets the path, it returns an error only if no response is received.
+func doProxyProbe(ctx context.Context, kubeClient kubernetes.Interface, path string, timeout time.Duration) (int, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var statusCode int
+	result := kubeClient.Discovery().RESTClient().Get().AbsPath(path).Do(ctx).StatusCode(&statusCode)
+	body, err := result.Raw()
+	if statusCode == 0 {
+		return 0, nil, err
+	}
+	return statusCode, body, nil
+}
+
+func checkProxyProbeResponse(probe agent.ProxyProbe, statusCode int, body []byte) error {
+	if probe.HealthCheck != nil {
+		return probe.HealthCheck(statusCode, body)
+	}
+	if statusCode < 200 || statusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", statusCode)
+	}
+	return nil
+}
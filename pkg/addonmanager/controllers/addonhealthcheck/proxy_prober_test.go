@@ -0,0 +1,179 @@
+package addonhealthcheck
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+)
+
+func TestProbeProxyAddonStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/namespaces/agent/services/https:agent:8443/proxy/healthz":
+			_, _ = w.Write([]byte("ok"))
+		case "/apis/apps/v1/namespaces/agent/deployments/agent":
+			_, _ = w.Write([]byte("{}"))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("unhealthy"))
+		}
+	}))
+	defer server.Close()
+
+	closedServer := httptest.NewServer(http.NotFoundHandler())
+	closedServer.Close()
+
+	clusterConfig := func(host string) func(string) (*rest.Config, error) {
+		return func(clusterName string) (*rest.Config, error) {
+			if clusterName != "cluster1" {
+				return nil, fmt.Errorf("unexpected cluster %s", clusterName)
+			}
+			return &rest.Config{Host: host}, nil
+		}
+	}
+	serviceProbe := agent.ProxyProbe{
+		Path:    "/healthz",
+		Service: &agent.ProxyProbeService{Namespace: "agent", Name: "agent", Port: "8443", Scheme: "https"},
+	}
+
+	cases := []struct {
+		name           string
+		prober         *agent.ProxyHealthProber
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name: "available",
+			prober: &agent.ProxyHealthProber{
+				ClusterConfig: clusterConfig(server.URL),
+				Probes:        []agent.ProxyProbe{serviceProbe, {Path: "apis/apps/v1/namespaces/agent/deployments/agent"}},
+			},
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: addonapiv1alpha1.AddonAvailableReasonProbeAvailable,
+		},
+		{
+			name: "unavailable",
+			prober: &agent.ProxyHealthProber{
+				ClusterConfig: clusterConfig(server.URL),
+				Probes:        []agent.ProxyProbe{serviceProbe, {Path: "/readyz"}},
+			},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: addonapiv1alpha1.AddonAvailableReasonProbeUnavailable,
+		},
+		{
+			name: "unavailable by health check",
+			prober: &agent.ProxyHealthProber{
+				ClusterConfig: clusterConfig(server.URL),
+				Probes: []agent.ProxyProbe{{
+					Path:    serviceProbe.Path,
+					Service: serviceProbe.Service,
+					HealthCheck: func(statusCode int, body []byte) error {
+						if string(body) != "ready" {
+							return fmt.Errorf("agent is not ready")
+						}
+						return nil
+					},
+				}},
+			},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: addonapiv1alpha1.AddonAvailableReasonProbeUnavailable,
+		},
+		{
+			name: "available by health check",
+			prober: &agent.ProxyHealthProber{
+				ClusterConfig: clusterConfig(server.URL),
+				Probes: []agent.ProxyProbe{{
+					Path: "/readyz",
+					HealthCheck: func(statusCode int, body []byte) error {
+						return nil
+					},
+				}},
+			},
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: addonapiv1alpha1.AddonAvailableReasonProbeAvailable,
+		},
+		{
+			name: "cluster config error",
+			prober: &agent.ProxyHealthProber{
+				ClusterConfig: func(string) (*rest.Config, error) { return nil, fmt.Errorf("no proxy") },
+				Probes:        []agent.ProxyProbe{serviceProbe},
+			},
+			expectedStatus: metav1.ConditionUnknown,
+			expectedReason: constants.AddonAvailableReasonProxyUnavailable,
+		},
+		{
+			name: "proxy unreachable",
+			prober: &agent.ProxyHealthProber{
+				ClusterConfig: clusterConfig(closedServer.URL),
+				Probes:        []agent.ProxyProbe{serviceProbe},
+			},
+			expectedStatus: metav1.ConditionUnknown,
+			expectedReason: constants.AddonAvailableReasonProxyUnavailable,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addon := addontesting.NewAddon("test", "cluster1")
+			probeProxyAddonStatus(context.TODO(), addon, c.prober)
+
+			cond := meta.FindStatusCondition(addon.Status.Conditions, addonapiv1alpha1.ManagedClusterAddOnConditionAvailable)
+			if cond == nil {
+				t.Fatalf("expected available condition, but got nil")
+			}
+			if cond.Status != c.expectedStatus || cond.Reason != c.expectedReason {
+				t.Errorf("expected condition %s/%s, but got %s/%s: %s",
+					c.expectedStatus, c.expectedReason, cond.Status, cond.Reason, cond.Message)
+			}
+		})
+	}
+}
+
+func TestProxyProbePath(t *testing.T) {
+	cases := []struct {
+		name         string
+		probe        agent.ProxyProbe
+		expectedPath string
+	}{
+		{
+			name:         "kube api",
+			probe:        agent.ProxyProbe{Path: "/readyz"},
+			expectedPath: "/readyz",
+		},
+		{
+			name: "http service",
+			probe: agent.ProxyProbe{
+				Path:    "healthz",
+				Service: &agent.ProxyProbeService{Namespace: "agent", Name: "agent", Port: "metrics"},
+			},
+			expectedPath: "/api/v1/namespaces/agent/services/agent:metrics/proxy/healthz",
+		},
+		{
+			name: "https service",
+			probe: agent.ProxyProbe{
+				Path:    "/healthz",
+				Service: &agent.ProxyProbeService{Namespace: "agent", Name: "agent", Port: "8443", Scheme: "https"},
+			},
+			expectedPath: "/api/v1/namespaces/agent/services/https:agent:8443/proxy/healthz",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if path := proxyProbePath(c.probe); path != c.expectedPath {
+				t.Errorf("expected path %q, but got %q", c.expectedPath, path)
+			}
+		})
+	}
+}
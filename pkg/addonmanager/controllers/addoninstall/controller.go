@@ -2,31 +2,48 @@ package addoninstall
 
 import (
 	"context"
+	"encoding/json"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	errorsutil "k8s.io/apimachinery/pkg/util/errors"
-	"k8s.io/klog/v2"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
 	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
 	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
 	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1"
 	clusterlister "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
 
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
 )
 
+// ClusterExclusion excludes managed clusters from the automatic installation of the addons, in addition to the
+// clusters with the DisableAddonAutomaticInstallationAnnotationKey annotation.
+type ClusterExclusion struct {
+	// Selector selects the excluded managed clusters by their labels. No cluster is selected if it is nil.
+	Selector labels.Selector
+
+	// RemoveInstalled deletes the ManagedClusterAddOns automatically installed on the excluded clusters,
+	// otherwise they are kept and only the new installations are skipped.
+	RemoveInstalled bool
+}
+
 // managedClusterController reconciles instances of ManagedCluster on the hub.
 type addonInstallController struct {
 	addonClient               addonv1alpha1client.Interface
 	managedClusterLister      clusterlister.ManagedClusterLister
 	managedClusterAddonLister addonlisterv1alpha1.ManagedClusterAddOnLister
 	agentAddons               map[string]agent.AgentAddon
+	exclusion                 ClusterExclusion
 }
 
 func NewAddonInstallController(
@@ -34,12 +51,14 @@ func NewAddonInstallController(
 	clusterInformers clusterinformers.ManagedClusterInformer,
 	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
 	agentAddons map[string]agent.AgentAddon,
+	exclusion ClusterExclusion,
 ) factory.Controller {
 	c := &addonInstallController{
 		addonClient:               addonClient,
 		managedClusterLister:      clusterInformers.Lister(),
 		managedClusterAddonLister: addonInformers.Lister(),
 		agentAddons:               agentAddons,
+		exclusion:                 exclusion,
 	}
 
 	return factory.New().WithFilteredEventsInformersQueueKeysFunc(
@@ -67,7 +86,8 @@ func NewAddonInstallController(
 }
 
 func (c *addonInstallController) sync(ctx context.Context, syncCtx factory.SyncContext, clusterName string) error {
-	klog.V(4).Infof("Reconciling addon deploy on cluster %q", clusterName)
+	ctx, logger := logging.WithValues(ctx, logging.ClusterKey, clusterName)
+	logger.V(4).Info("Reconciling addon deploy on the cluster")
 
 	cluster, err := c.managedClusterLister.Get(clusterName)
 	if errors.IsNotFound(err) {
@@ -79,15 +99,15 @@ func (c *addonInstallController) sync(ctx context.Context, syncCtx factory.SyncC
 
 	// if cluster is deleting, do not install addon
 	if !cluster.DeletionTimestamp.IsZero() {
-		klog.V(4).Infof("Cluster %q is deleting, skip addon deploy", clusterName)
+		logger.V(4).Info("The cluster is deleting, skip addon deploy")
 		return nil
 	}
 
-	if value, ok := cluster.Annotations[addonapiv1alpha1.DisableAddonAutomaticInstallationAnnotationKey]; ok &&
-		strings.EqualFold(value, "true") {
-
-		klog.V(4).Infof("Cluster %q has annotation %q, skip addon deploy",
-			clusterName, addonapiv1alpha1.DisableAddonAutomaticInstallationAnnotationKey)
+	if c.clusterExcluded(cluster) {
+		logger.V(4).Info("The cluster is excluded from the automatic installation, skip addon deploy")
+		if c.exclusion.RemoveInstalled {
+			return c.removeAutoInstalledAddons(ctx, clusterName)
+		}
 		return nil
 	}
 
@@ -103,7 +123,7 @@ func (c *addonInstallController) sync(ctx context.Context, syncCtx factory.SyncC
 			continue
 		}
 		if !managedClusterFilter(cluster) {
-			klog.V(4).Infof("managed cluster filter is not match for addon %s on %s", addonName, clusterName)
+			logger.V(4).Info("The managed cluster filter is not matched", logging.AddonKey, addonName)
 			continue
 		}
 
@@ -116,6 +136,47 @@ func (c *addonInstallController) sync(ctx context.Context, syncCtx factory.SyncC
 	return errorsutil.NewAggregate(errs)
 }
 
+// clusterExcluded returns true if the cluster has the disable automatic installation annotation, or is selected
+// by the selector of the cluster exclusion.
+func (c *addonInstallController) clusterExcluded(cluster *clusterv1.ManagedCluster) bool {
+	if value, ok := cluster.Annotations[addonapiv1alpha1.DisableAddonAutomaticInstallationAnnotationKey]; ok &&
+		strings.EqualFold(value, "true") {
+		return true
+	}
+	return c.exclusion.Selector != nil && c.exclusion.Selector.Matches(labels.Set(cluster.Labels))
+}
+
+// removeAutoInstalledAddons deletes the addons installed by their InstallStrategy on the cluster, the addons
+// created by the users are kept.
+func (c *addonInstallController) removeAutoInstalledAddons(ctx context.Context, clusterName string) error {
+	var errs []error
+	for addonName, agentAddon := range c.agentAddons {
+		if agentAddon.GetAgentAddonOptions().InstallStrategy == nil {
+			continue
+		}
+
+		addon, err := c.managedClusterAddonLister.ManagedClusterAddOns(clusterName).Get(addonName)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if addon.Annotations[constants.AddonAutoInstalledAnnotationKey] != "true" || !addon.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		logging.FromContext(ctx).V(2).Info("Removing the automatically installed addon from the excluded cluster",
+			logging.AddonKey, addonName)
+		err = c.addonClient.AddonV1alpha1().ManagedClusterAddOns(clusterName).Delete(ctx, addonName, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
 func (c *addonInstallController) applyAddon(ctx context.Context, addonName, clusterName, installNamespace string) error {
 	_, err := c.managedClusterAddonLister.ManagedClusterAddOns(clusterName).Get(addonName)
 
@@ -125,14 +186,41 @@ func (c *addonInstallController) applyAddon(ctx context.Context, addonName, clus
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      addonName,
 				Namespace: clusterName,
+				Labels: map[string]string{
+					addonapiv1alpha1.AddonLabelKey: addonName,
+				},
+				Annotations: map[string]string{
+					constants.AddonAutoInstalledAnnotationKey: "true",
+				},
 			},
 			Spec: addonapiv1alpha1.ManagedClusterAddOnSpec{
 				InstallNamespace: installNamespace,
 			},
 		}
 		_, err = c.addonClient.AddonV1alpha1().ManagedClusterAddOns(clusterName).Create(ctx, addon, metav1.CreateOptions{})
+		if errors.IsAlreadyExists(err) {
+			// the addon is not in the informer scoped by the addon label, it is created by others without the
+			// label, adopt it by the label so it is managed.
+			return AdoptAddon(ctx, c.addonClient, clusterName, addonName)
+		}
 		return err
 	}
 
 	return err
 }
+
+// AdoptAddon labels the addon with its name, so it is selected by the ManagedClusterAddOn informer scoped by the
+// addon label.
+func AdoptAddon(ctx context.Context, addonClient addonv1alpha1client.Interface, clusterName, addonName string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{addonapiv1alpha1.AddonLabelKey: addonName},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = addonClient.AddonV1alpha1().ManagedClusterAddOns(clusterName).Patch(
+		ctx, addonName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
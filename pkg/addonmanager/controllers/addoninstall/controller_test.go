@@ -2,13 +2,16 @@ package addoninstall
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	clienttesting "k8s.io/client-go/testing"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
@@ -47,12 +50,20 @@ func newManagedClusterWithAnnotation(name, key, value string) *clusterv1.Managed
 	return cluster
 }
 
+func newAutoInstalledAddon(name, namespace string) *addonapiv1alpha1.ManagedClusterAddOn {
+	addon := addontesting.NewAddon(name, namespace)
+	addon.Annotations = map[string]string{constants.AddonAutoInstalledAnnotationKey: "true"}
+	return addon
+}
+
 func TestReconcile(t *testing.T) {
 	cases := []struct {
 		name                 string
 		addon                []runtime.Object
+		unlabeledAddon       []runtime.Object
 		testaddons           map[string]agent.AgentAddon
 		cluster              []runtime.Object
+		exclusion            ClusterExclusion
 		validateAddonActions func(t *testing.T, actions []clienttesting.Action)
 	}{
 		{
@@ -75,6 +86,29 @@ func TestReconcile(t *testing.T) {
 				if addOn.Spec.InstallNamespace != "test" {
 					t.Errorf("Install namespace is not correct, expected test but got %s", addOn.Spec.InstallNamespace)
 				}
+				if addOn.Annotations[constants.AddonAutoInstalledAnnotationKey] != "true" {
+					t.Errorf("expected the addon annotated as automatically installed, but got %v", addOn.Annotations)
+				}
+			},
+			testaddons: map[string]agent.AgentAddon{
+				"test": &testAgent{name: "test", strategy: agent.InstallAllStrategy("test")},
+			},
+		},
+		{
+			name:           "adopt the addon not selected by the informer",
+			addon:          []runtime.Object{},
+			unlabeledAddon: []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			cluster:        []runtime.Object{addontesting.NewManagedCluster("cluster1")},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "create", "patch")
+				patch := actions[1].(clienttesting.PatchActionImpl).Patch
+				addOn := &addonapiv1alpha1.ManagedClusterAddOn{}
+				if err := json.Unmarshal(patch, addOn); err != nil {
+					t.Fatal(err)
+				}
+				if addOn.Labels[addonapiv1alpha1.AddonLabelKey] != "test" {
+					t.Errorf("expected the addon labeled, but got %v", addOn.Labels)
+				}
 			},
 			testaddons: map[string]agent.AgentAddon{
 				"test": &testAgent{name: "test", strategy: agent.InstallAllStrategy("test")},
@@ -108,6 +142,44 @@ func TestReconcile(t *testing.T) {
 				"test": &testAgent{name: "test", strategy: agent.InstallAllStrategy("test")},
 			},
 		},
+		{
+			name:                 "cluster excluded by the selector",
+			addon:                []runtime.Object{},
+			cluster:              []runtime.Object{newManagedClusterWithLabel("cluster1", "addon-install", "disabled")},
+			exclusion:            ClusterExclusion{Selector: labels.SelectorFromSet(labels.Set{"addon-install": "disabled"})},
+			validateAddonActions: addontesting.AssertNoActions,
+			testaddons: map[string]agent.AgentAddon{
+				"test": &testAgent{name: "test", strategy: agent.InstallAllStrategy("test")},
+			},
+		},
+		{
+			name:                 "keep the installed addon on the excluded cluster",
+			addon:                []runtime.Object{newAutoInstalledAddon("test", "cluster1")},
+			cluster:              []runtime.Object{newManagedClusterWithLabel("cluster1", "addon-install", "disabled")},
+			exclusion:            ClusterExclusion{Selector: labels.SelectorFromSet(labels.Set{"addon-install": "disabled"})},
+			validateAddonActions: addontesting.AssertNoActions,
+			testaddons: map[string]agent.AgentAddon{
+				"test": &testAgent{name: "test", strategy: agent.InstallAllStrategy("test")},
+			},
+		},
+		{
+			name:  "remove the automatically installed addons from the excluded cluster",
+			addon: []runtime.Object{newAutoInstalledAddon("test1", "cluster1"), addontesting.NewAddon("test2", "cluster1")},
+			cluster: []runtime.Object{addontesting.SetManagedClusterAnnotation(
+				addontesting.NewManagedCluster("cluster1"),
+				map[string]string{addonapiv1alpha1.DisableAddonAutomaticInstallationAnnotationKey: "true"})},
+			exclusion: ClusterExclusion{RemoveInstalled: true},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "delete")
+				if name := actions[0].(clienttesting.DeleteActionImpl).Name; name != "test1" {
+					t.Errorf("expected the automatically installed addon test1 deleted, but got %s", name)
+				}
+			},
+			testaddons: map[string]agent.AgentAddon{
+				"test1": &testAgent{name: "test1", strategy: agent.InstallAllStrategy("test1")},
+				"test2": &testAgent{name: "test2", strategy: agent.InstallAllStrategy("test2")},
+			},
+		},
 		{
 			name:                 "selector install strategy with unmatched cluster",
 			addon:                []runtime.Object{},
@@ -198,7 +270,7 @@ func TestReconcile(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			fakeClusterClient := fakecluster.NewSimpleClientset(c.cluster...)
-			fakeAddonClient := fakeaddon.NewSimpleClientset(c.addon...)
+			fakeAddonClient := fakeaddon.NewSimpleClientset(append(c.addon, c.unlabeledAddon...)...)
 
 			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
 			clusterInformers := clusterv1informers.NewSharedInformerFactory(fakeClusterClient, 10*time.Minute)
@@ -219,6 +291,7 @@ func TestReconcile(t *testing.T) {
 				managedClusterLister:      clusterInformers.Cluster().V1().ManagedClusters().Lister(),
 				managedClusterAddonLister: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
 				agentAddons:               c.testaddons,
+				exclusion:                 c.exclusion,
 			}
 
 			for _, obj := range c.cluster {
@@ -0,0 +1,398 @@
+package addonprogressing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
+	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+const controllerName = "addon-progressing-controller"
+
+// addonProgressingController reconciles instances of ManagedClusterAddon on the hub to track
+// the configurations applied by the addon agent. The lastAppliedConfig of each config reference
+// is set to the desiredConfig once all the deploy manifestWorks of the addon are applied with the
+// desired config spec hash. The conditions of the deploy manifestWorks and their resources are
+// aggregated into the Progressing condition of the addon.
+type addonProgressingController struct {
+	addonClient               addonv1alpha1client.Interface
+	eventRecorder             utils.AddonEventRecorder
+	managedClusterAddonLister addonlisterv1alpha1.ManagedClusterAddOnLister
+	workLister                worklister.ManifestWorkLister
+	agentAddons               map[string]agent.AgentAddon
+}
+
+func NewAddonProgressingController(
+	addonClient addonv1alpha1client.Interface,
+	eventRecorder utils.AddonEventRecorder,
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	workInformers workinformers.ManifestWorkInformer,
+	agentAddons map[string]agent.AgentAddon,
+) factory.Controller {
+	c := &addonProgressingController{
+		addonClient:               addonClient,
+		eventRecorder:             eventRecorder,
+		managedClusterAddonLister: addonInformers.Lister(),
+		workLister:                workInformers.Lister(),
+		agentAddons:               agentAddons,
+	}
+
+	return factory.New().WithFilteredEventsInformersQueueKeysFunc(
+		func(obj runtime.Object) []string {
+			key, _ := cache.MetaNamespaceKeyFunc(obj)
+			return []string{key}
+		},
+		func(obj interface{}) bool {
+			accessor, _ := meta.Accessor(obj)
+			if _, ok := c.agentAddons[accessor.GetName()]; !ok {
+				return false
+			}
+			return true
+		},
+		addonInformers.Informer()).
+		WithFilteredEventsInformersQueueKeysFunc(
+			func(obj runtime.Object) []string {
+				accessor, _ := meta.Accessor(obj)
+				// in hosted mode, the addon namespace is in the AddonNamespaceLabel of the manifestWork.
+				if addonNamespace, ok := accessor.GetLabels()[addonapiv1alpha1.AddonNamespaceLabelKey]; ok {
+					return []string{fmt.Sprintf("%s/%s", addonNamespace, accessor.GetLabels()[addonapiv1alpha1.AddonLabelKey])}
+				}
+				return []string{fmt.Sprintf("%s/%s", accessor.GetNamespace(), accessor.GetLabels()[addonapiv1alpha1.AddonLabelKey])}
+			},
+			func(obj interface{}) bool {
+				accessor, _ := meta.Accessor(obj)
+				if accessor.GetLabels() == nil {
+					return false
+				}
+
+				addonName, ok := accessor.GetLabels()[addonapiv1alpha1.AddonLabelKey]
+				if !ok {
+					return false
+				}
+
+				if _, ok := c.agentAddons[addonName]; !ok {
+					return false
+				}
+
+				return strings.HasPrefix(accessor.GetName(), constants.DeployWorkNamePrefix(addonName))
+			},
+			workInformers.Informer(),
+		).
+		WithQueuePartitionFunc(factory.NamePartitionFunc).
+		WithSync(c.sync).
+		ToController(controllerName)
+}
+
+func (c *addonProgressingController) sync(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+	addonNamespace, addonName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		// ignore addon whose key is not in format: namespace/name
+		return nil
+	}
+
+	if _, ok := c.agentAddons[addonName]; !ok {
+		return nil
+	}
+	ctx, logger := logging.WithAddon(ctx, addonNamespace, addonName)
+
+	addon, err := c.managedClusterAddonLister.ManagedClusterAddOns(addonNamespace).Get(addonName)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !addon.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	works, err := c.getDeployWorks(addon)
+	if err != nil {
+		return err
+	}
+
+	// the addon has no deploy works yet, or has no manifests at all.
+	if len(works) == 0 {
+		return nil
+	}
+
+	addonCopy := addon.DeepCopy()
+	meta.SetStatusCondition(&addonCopy.Status.Conditions, progressingCondition(addonCopy, works))
+
+	if deployWorksApplied(addonCopy, works) {
+		for i, configReference := range addonCopy.Status.ConfigReferences {
+			if configReference.DesiredConfig == nil || configReference.DesiredConfig.SpecHash == "" {
+				continue
+			}
+			if !equality.Semantic.DeepEqual(configReference.LastAppliedConfig, configReference.DesiredConfig) {
+				_, configLogger := logging.WithConfigHash(ctx, configReference.DesiredConfig.SpecHash)
+				configLogger.V(4).Info("The desired config is applied", "config", configResourceString(configReference))
+			}
+			addonCopy.Status.ConfigReferences[i].LastAppliedConfig = configReference.DesiredConfig.DeepCopy()
+		}
+	} else {
+		logger.V(4).Info("Waiting for the deploy works to be applied")
+	}
+
+	return c.patchStatus(ctx, addon, addonCopy)
+}
+
+// progressingCondition aggregates the Applied, Progressing and Degraded conditions of the deploy works, and
+// the conditions of their resources, into the Progressing condition of the addon. The failures take
+// precedence over the works and configs being applied.
+func progressingCondition(addon *addonapiv1alpha1.ManagedClusterAddOn, works []*workapiv1.ManifestWork) metav1.Condition {
+	var failures, applying []string
+	for _, work := range works {
+		key := fmt.Sprintf("%s/%s", work.Namespace, work.Name)
+
+		applied := meta.FindStatusCondition(work.Status.Conditions, workapiv1.WorkApplied)
+		switch {
+		case applied == nil || applied.ObservedGeneration != work.Generation:
+			applying = append(applying, fmt.Sprintf("manifestwork %s is being applied", key))
+		case applied.Status != metav1.ConditionTrue:
+			failures = append(failures, fmt.Sprintf("manifestwork %s failed to apply: %s", key, applied.Message))
+		}
+
+		if meta.IsStatusConditionTrue(work.Status.Conditions, workapiv1.WorkProgressing) {
+			applying = append(applying, fmt.Sprintf("manifestwork %s is progressing", key))
+		}
+		if degraded := meta.FindStatusCondition(work.Status.Conditions, workapiv1.WorkDegraded); degraded != nil &&
+			degraded.Status == metav1.ConditionTrue {
+			failures = append(failures, fmt.Sprintf("manifestwork %s is degraded: %s", key, degraded.Message))
+		}
+
+		for _, manifest := range work.Status.ResourceStatus.Manifests {
+			resource := fmt.Sprintf("%s %s", manifest.ResourceMeta.Kind, manifest.ResourceMeta.Name)
+			if len(manifest.ResourceMeta.Namespace) > 0 {
+				resource = fmt.Sprintf("%s %s/%s", manifest.ResourceMeta.Kind, manifest.ResourceMeta.Namespace,
+					manifest.ResourceMeta.Name)
+			}
+			if cond := meta.FindStatusCondition(manifest.Conditions, string(workapiv1.ManifestApplied)); cond != nil &&
+				cond.Status == metav1.ConditionFalse {
+				failures = append(failures, fmt.Sprintf("%s of manifestwork %s failed to apply: %s", resource, key, cond.Message))
+			}
+			if meta.IsStatusConditionTrue(manifest.Conditions, string(workapiv1.ManifestDegraded)) {
+				cond := meta.FindStatusCondition(manifest.Conditions, string(workapiv1.ManifestDegraded))
+				failures = append(failures, fmt.Sprintf("%s of manifestwork %s is degraded: %s", resource, key, cond.Message))
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return metav1.Condition{
+			Type:    addonapiv1alpha1.ManagedClusterAddOnConditionProgressing,
+			Status:  metav1.ConditionFalse,
+			Reason:  constants.AddonProgressingReasonFailed,
+			Message: strings.Join(failures, "; "),
+		}
+	}
+
+	if configs := pendingConfigs(addon, works); len(configs) > 0 {
+		applying = append(applying, fmt.Sprintf("applying config %s", strings.Join(configs, ", ")))
+	}
+	if len(applying) > 0 {
+		return metav1.Condition{
+			Type:    addonapiv1alpha1.ManagedClusterAddOnConditionProgressing,
+			Status:  metav1.ConditionTrue,
+			Reason:  constants.AddonProgressingReasonApplying,
+			Message: strings.Join(applying, "; "),
+		}
+	}
+
+	return metav1.Condition{
+		Type:    addonapiv1alpha1.ManagedClusterAddOnConditionProgressing,
+		Status:  metav1.ConditionFalse,
+		Reason:  constants.AddonProgressingReasonCompleted,
+		Message: "the deploy manifestworks of the addon are applied with the desired configs",
+	}
+}
+
+// pendingConfigs returns the desired configs of the addon with their spec hashes, which are not applied by
+// all the deploy works yet.
+func pendingConfigs(addon *addonapiv1alpha1.ManagedClusterAddOn, works []*workapiv1.ManifestWork) []string {
+	var configs []string
+	for _, configReference := range addon.Status.ConfigReferences {
+		if configReference.DesiredConfig == nil {
+			continue
+		}
+		resourceStr := configResourceString(configReference)
+		for _, work := range works {
+			if workSpecHashes(work)[resourceStr] != configReference.DesiredConfig.SpecHash {
+				configs = append(configs, fmt.Sprintf("%s hash %s", resourceStr, configReference.DesiredConfig.SpecHash))
+				break
+			}
+		}
+	}
+	return configs
+}
+
+// getDeployWorks returns the deploy manifestWorks of the addon on the managed cluster, and the deploy
+// manifestWorks on the hosting cluster if the addon is in Hosted mode.
+func (c *addonProgressingController) getDeployWorks(addon *addonapiv1alpha1.ManagedClusterAddOn) ([]*workapiv1.ManifestWork, error) {
+	selector := labels.SelectorFromSet(labels.Set{addonapiv1alpha1.AddonLabelKey: addon.Name})
+
+	var deployWorks []*workapiv1.ManifestWork
+	works, err := c.workLister.ManifestWorks(addon.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, work := range works {
+		if _, ok := work.Labels[addonapiv1alpha1.AddonNamespaceLabelKey]; ok {
+			continue
+		}
+		if strings.HasPrefix(work.Name, constants.DeployWorkNamePrefix(addon.Name)) {
+			deployWorks = append(deployWorks, work)
+		}
+	}
+
+	installMode, hostingClusterName := constants.GetHostedModeInfo(addon.GetAnnotations())
+	if installMode != constants.InstallModeHosted || len(hostingClusterName) == 0 {
+		return deployWorks, nil
+	}
+
+	works, err = c.workLister.ManifestWorks(hostingClusterName).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, work := range works {
+		if work.Labels[addonapiv1alpha1.AddonNamespaceLabelKey] != addon.Namespace {
+			continue
+		}
+		if strings.HasPrefix(work.Name, constants.DeployHostingWorkNamePrefix(addon.Namespace, addon.Name)) {
+			deployWorks = append(deployWorks, work)
+		}
+	}
+
+	return deployWorks, nil
+}
+
+// deployWorksApplied returns true if there is at least one deploy work, and all the deploy works
+// are applied on the current generation with the desired config spec hashes of the addon.
+func deployWorksApplied(addon *addonapiv1alpha1.ManagedClusterAddOn, works []*workapiv1.ManifestWork) bool {
+	if len(works) == 0 {
+		return false
+	}
+
+	for _, work := range works {
+		applied := meta.FindStatusCondition(work.Status.Conditions, workapiv1.WorkApplied)
+		if applied == nil || applied.Status != metav1.ConditionTrue || applied.ObservedGeneration != work.Generation {
+			return false
+		}
+
+		if !workConfigsMatchAddon(work, addon) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// workConfigsMatchAddon checks the config spec hashes recorded in the work annotation are the same
+// as the desired config spec hashes of the addon.
+func workConfigsMatchAddon(work *workapiv1.ManifestWork, addon *addonapiv1alpha1.ManagedClusterAddOn) bool {
+	specHashes := workSpecHashes(work)
+	for _, configReference := range addon.Status.ConfigReferences {
+		if configReference.DesiredConfig == nil {
+			continue
+		}
+
+		if specHashes[configResourceString(configReference)] != configReference.DesiredConfig.SpecHash {
+			return false
+		}
+	}
+
+	return true
+}
+
+// workSpecHashes returns the config spec hashes recorded in the annotation of the work, keyed by the
+// resource, namespace and name of the configs.
+func workSpecHashes(work *workapiv1.ManifestWork) map[string]string {
+	specHashes := map[string]string{}
+	if value, ok := work.Annotations[workapiv1.ManifestConfigSpecHashAnnotationKey]; ok {
+		if err := json.Unmarshal([]byte(value), &specHashes); err != nil {
+			klog.Warningf("failed to parse the config spec hash annotation of work %s/%s: %v", work.Namespace, work.Name, err)
+			return map[string]string{}
+		}
+	}
+	return specHashes
+}
+
+// configResourceString returns the key of the desired config in the config spec hash annotation of the works.
+func configResourceString(configReference addonapiv1alpha1.ConfigReference) string {
+	resourceStr := configReference.Resource
+	if len(configReference.Group) > 0 {
+		resourceStr += fmt.Sprintf(".%s", configReference.Group)
+	}
+	return resourceStr + fmt.Sprintf("/%s/%s", configReference.DesiredConfig.Namespace, configReference.DesiredConfig.Name)
+}
+
+// patchStatus patches the config references and the conditions of the addon status.
+func (c *addonProgressingController) patchStatus(ctx context.Context, old, new *addonapiv1alpha1.ManagedClusterAddOn) error {
+	if equality.Semantic.DeepEqual(new.Status.ConfigReferences, old.Status.ConfigReferences) &&
+		equality.Semantic.DeepEqual(new.Status.Conditions, old.Status.Conditions) {
+		return nil
+	}
+
+	oldData, err := json.Marshal(&addonapiv1alpha1.ManagedClusterAddOn{
+		Status: addonapiv1alpha1.ManagedClusterAddOnStatus{
+			ConfigReferences: old.Status.ConfigReferences,
+			Conditions:       old.Status.Conditions,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	newData, err := json.Marshal(&addonapiv1alpha1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:             new.UID,
+			ResourceVersion: new.ResourceVersion,
+		},
+		Status: addonapiv1alpha1.ManagedClusterAddOnStatus{
+			ConfigReferences: new.Status.ConfigReferences,
+			Conditions:       new.Status.Conditions,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	patchBytes, err := jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return fmt.Errorf("failed to create patch for addon %s: %w", new.Name, err)
+	}
+
+	logging.FromContext(ctx).V(2).Info("Patching the addon progressing status", "patch", string(patchBytes))
+	_, err = c.addonClient.AddonV1alpha1().ManagedClusterAddOns(new.Namespace).Patch(
+		ctx, new.Name, types.MergePatchType, patchBytes, metav1.PatchOptions{}, "status")
+	if err != nil {
+		return err
+	}
+	utils.RecordAddonStatusEvents(c.eventRecorder, old, new)
+	return nil
+}
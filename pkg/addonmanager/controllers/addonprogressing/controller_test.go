@@ -0,0 +1,361 @@
+package addonprogressing
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	fakework "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+type testAgent struct {
+	name string
+}
+
+func (t *testAgent) Manifests(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) ([]runtime.Object, error) {
+	return nil, nil
+}
+
+func (t *testAgent) GetAgentAddonOptions() agent.AgentAddonOptions {
+	return agent.AgentAddonOptions{
+		AddonName: t.name,
+	}
+}
+
+func newAddonWithConfig(name, namespace, specHash string, lastApplied *addonapiv1alpha1.ConfigSpecHash) *addonapiv1alpha1.ManagedClusterAddOn {
+	addon := addontesting.NewAddon(name, namespace)
+	addon.Status.ConfigReferences = []addonapiv1alpha1.ConfigReference{
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{Group: "core", Resource: "foo"},
+			ConfigReferent:      addonapiv1alpha1.ConfigReferent{Name: "test"},
+			DesiredConfig: &addonapiv1alpha1.ConfigSpecHash{
+				ConfigReferent: addonapiv1alpha1.ConfigReferent{Name: "test"},
+				SpecHash:       specHash,
+			},
+			LastAppliedConfig: lastApplied,
+		},
+	}
+	return addon
+}
+
+func newDeployWork(name, namespace, specHash string, applied bool, generation, observedGeneration int64) *workapiv1.ManifestWork {
+	work := addontesting.NewManifestWork(name, namespace)
+	work.Generation = generation
+	work.Labels = map[string]string{addonapiv1alpha1.AddonLabelKey: "test"}
+	work.Annotations = map[string]string{
+		workapiv1.ManifestConfigSpecHashAnnotationKey: `{"foo.core//test":"` + specHash + `"}`,
+	}
+	status := metav1.ConditionFalse
+	if applied {
+		status = metav1.ConditionTrue
+	}
+	work.Status.Conditions = []metav1.Condition{
+		{
+			Type:               workapiv1.WorkApplied,
+			Status:             status,
+			ObservedGeneration: observedGeneration,
+		},
+	}
+	return work
+}
+
+// assertProgressing asserts the addon is patched with the Progressing condition of the reason, and whether the
+// last applied config is recorded.
+func assertProgressing(reason string, lastAppliedRecorded bool) func(t *testing.T, actions []clienttesting.Action) {
+	return func(t *testing.T, actions []clienttesting.Action) {
+		addontesting.AssertActions(t, actions, "patch")
+		patch := actions[0].(clienttesting.PatchActionImpl).Patch
+		addon := &addonapiv1alpha1.ManagedClusterAddOn{}
+		if err := json.Unmarshal(patch, addon); err != nil {
+			t.Fatal(err)
+		}
+		cond := meta.FindStatusCondition(addon.Status.Conditions, addonapiv1alpha1.ManagedClusterAddOnConditionProgressing)
+		if cond == nil || cond.Reason != reason {
+			t.Errorf("expected progressing condition with reason %s, but got %v", reason, cond)
+		}
+		if recorded := len(addon.Status.ConfigReferences) > 0; recorded != lastAppliedRecorded {
+			t.Errorf("expected last applied config recorded %v, but got %v", lastAppliedRecorded, addon.Status.ConfigReferences)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	cases := []struct {
+		name                 string
+		syncKey              string
+		addon                []runtime.Object
+		works                []runtime.Object
+		validateAddonActions func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:                 "no addon",
+			syncKey:              "cluster1/test",
+			addon:                []runtime.Object{},
+			works:                []runtime.Object{},
+			validateAddonActions: addontesting.AssertNoActions,
+		},
+		{
+			name:                 "not a registered addon",
+			syncKey:              "cluster1/other",
+			addon:                []runtime.Object{newAddonWithConfig("other", "cluster1", "hash1", nil)},
+			works:                []runtime.Object{},
+			validateAddonActions: addontesting.AssertNoActions,
+		},
+		{
+			name:                 "no deploy works",
+			syncKey:              "cluster1/test",
+			addon:                []runtime.Object{newAddonWithConfig("test", "cluster1", "hash1", nil)},
+			works:                []runtime.Object{},
+			validateAddonActions: addontesting.AssertNoActions,
+		},
+		{
+			name:    "work is not applied",
+			syncKey: "cluster1/test",
+			addon:   []runtime.Object{newAddonWithConfig("test", "cluster1", "hash1", nil)},
+			works: []runtime.Object{
+				newDeployWork("addon-test-deploy-0", "cluster1", "hash1", false, 1, 1),
+			},
+			validateAddonActions: assertProgressing(constants.AddonProgressingReasonFailed, false),
+		},
+		{
+			name:    "work is applied on an old generation",
+			syncKey: "cluster1/test",
+			addon:   []runtime.Object{newAddonWithConfig("test", "cluster1", "hash1", nil)},
+			works: []runtime.Object{
+				newDeployWork("addon-test-deploy-0", "cluster1", "hash1", true, 2, 1),
+			},
+			validateAddonActions: assertProgressing(constants.AddonProgressingReasonApplying, false),
+		},
+		{
+			name:    "work config spec hash mismatch",
+			syncKey: "cluster1/test",
+			addon:   []runtime.Object{newAddonWithConfig("test", "cluster1", "hash2", nil)},
+			works: []runtime.Object{
+				newDeployWork("addon-test-deploy-0", "cluster1", "hash1", true, 1, 1),
+			},
+			validateAddonActions: assertProgressing(constants.AddonProgressingReasonApplying, false),
+		},
+		{
+			name:    "one of the works is not applied",
+			syncKey: "cluster1/test",
+			addon:   []runtime.Object{newAddonWithConfig("test", "cluster1", "hash1", nil)},
+			works: []runtime.Object{
+				newDeployWork("addon-test-deploy-0", "cluster1", "hash1", true, 1, 1),
+				newDeployWork("addon-test-deploy-1", "cluster1", "hash1", false, 1, 1),
+			},
+			validateAddonActions: assertProgressing(constants.AddonProgressingReasonFailed, false),
+		},
+		{
+			name:    "record last applied config",
+			syncKey: "cluster1/test",
+			addon:   []runtime.Object{newAddonWithConfig("test", "cluster1", "hash1", nil)},
+			works: []runtime.Object{
+				newDeployWork("addon-test-deploy-0", "cluster1", "hash1", true, 1, 1),
+				newDeployWork("addon-test-deploy-1", "cluster1", "hash1", true, 2, 2),
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				patch := actions[0].(clienttesting.PatchActionImpl).Patch
+				addon := &addonapiv1alpha1.ManagedClusterAddOn{}
+				if err := json.Unmarshal(patch, addon); err != nil {
+					t.Fatal(err)
+				}
+				expected := &addonapiv1alpha1.ConfigSpecHash{
+					ConfigReferent: addonapiv1alpha1.ConfigReferent{Name: "test"},
+					SpecHash:       "hash1",
+				}
+				if !equality.Semantic.DeepEqual(addon.Status.ConfigReferences[0].LastAppliedConfig, expected) {
+					t.Errorf("expected last applied config %v, but got %v", expected, addon.Status.ConfigReferences[0].LastAppliedConfig)
+				}
+				assertProgressing(constants.AddonProgressingReasonCompleted, true)(t, actions)
+			},
+		},
+		{
+			name:    "last applied config is up to date",
+			syncKey: "cluster1/test",
+			addon: []runtime.Object{func() *addonapiv1alpha1.ManagedClusterAddOn {
+				addon := newAddonWithConfig("test", "cluster1", "hash1", &addonapiv1alpha1.ConfigSpecHash{
+					ConfigReferent: addonapiv1alpha1.ConfigReferent{Name: "test"},
+					SpecHash:       "hash1",
+				})
+				addon.Status.Conditions = []metav1.Condition{
+					{
+						Type:    addonapiv1alpha1.ManagedClusterAddOnConditionProgressing,
+						Status:  metav1.ConditionFalse,
+						Reason:  constants.AddonProgressingReasonCompleted,
+						Message: "the deploy manifestworks of the addon are applied with the desired configs",
+					},
+				}
+				return addon
+			}()},
+			works: []runtime.Object{
+				newDeployWork("addon-test-deploy-0", "cluster1", "hash1", true, 1, 1),
+			},
+			validateAddonActions: addontesting.AssertNoActions,
+		},
+		{
+			name:    "work is progressing",
+			syncKey: "cluster1/test",
+			addon:   []runtime.Object{newAddonWithConfig("test", "cluster1", "hash1", nil)},
+			works: []runtime.Object{func() *workapiv1.ManifestWork {
+				work := newDeployWork("addon-test-deploy-0", "cluster1", "hash1", true, 1, 1)
+				meta.SetStatusCondition(&work.Status.Conditions, metav1.Condition{
+					Type:   workapiv1.WorkProgressing,
+					Status: metav1.ConditionTrue,
+				})
+				return work
+			}()},
+			validateAddonActions: assertProgressing(constants.AddonProgressingReasonApplying, true),
+		},
+		{
+			name:    "work is degraded",
+			syncKey: "cluster1/test",
+			addon:   []runtime.Object{newAddonWithConfig("test", "cluster1", "hash1", nil)},
+			works: []runtime.Object{func() *workapiv1.ManifestWork {
+				work := newDeployWork("addon-test-deploy-0", "cluster1", "hash1", true, 1, 1)
+				meta.SetStatusCondition(&work.Status.Conditions, metav1.Condition{
+					Type:   workapiv1.WorkDegraded,
+					Status: metav1.ConditionTrue,
+				})
+				return work
+			}()},
+			validateAddonActions: assertProgressing(constants.AddonProgressingReasonFailed, true),
+		},
+		{
+			name:    "resource of the work is degraded",
+			syncKey: "cluster1/test",
+			addon:   []runtime.Object{newAddonWithConfig("test", "cluster1", "hash1", nil)},
+			works: []runtime.Object{func() *workapiv1.ManifestWork {
+				work := newDeployWork("addon-test-deploy-0", "cluster1", "hash1", true, 1, 1)
+				work.Status.ResourceStatus.Manifests = []workapiv1.ManifestCondition{
+					{
+						ResourceMeta: workapiv1.ManifestResourceMeta{Kind: "Deployment", Namespace: "default", Name: "agent"},
+						Conditions: []metav1.Condition{
+							{Type: string(workapiv1.ManifestDegraded), Status: metav1.ConditionTrue, Message: "unavailable"},
+						},
+					},
+				}
+				return work
+			}()},
+			validateAddonActions: assertProgressing(constants.AddonProgressingReasonFailed, true),
+		},
+		{
+			name:    "record last applied config in hosted mode",
+			syncKey: "cluster1/test",
+			addon: []runtime.Object{func() *addonapiv1alpha1.ManagedClusterAddOn {
+				addon := newAddonWithConfig("test", "cluster1", "hash1", nil)
+				addon.Annotations = map[string]string{addonapiv1alpha1.HostingClusterNameAnnotationKey: "cluster2"}
+				return addon
+			}()},
+			works: []runtime.Object{
+				newDeployWork("addon-test-deploy-0", "cluster1", "hash1", true, 1, 1),
+				func() *workapiv1.ManifestWork {
+					work := newDeployWork("addon-test-deploy-hosting-cluster1-0", "cluster2", "hash1", true, 1, 1)
+					work.Labels[addonapiv1alpha1.AddonNamespaceLabelKey] = "cluster1"
+					return work
+				}(),
+			},
+			validateAddonActions: assertProgressing(constants.AddonProgressingReasonCompleted, true),
+		},
+		{
+			name:    "hosting work is not applied in hosted mode",
+			syncKey: "cluster1/test",
+			addon: []runtime.Object{func() *addonapiv1alpha1.ManagedClusterAddOn {
+				addon := newAddonWithConfig("test", "cluster1", "hash1", nil)
+				addon.Annotations = map[string]string{addonapiv1alpha1.HostingClusterNameAnnotationKey: "cluster2"}
+				return addon
+			}()},
+			works: []runtime.Object{
+				newDeployWork("addon-test-deploy-0", "cluster1", "hash1", true, 1, 1),
+				func() *workapiv1.ManifestWork {
+					work := newDeployWork("addon-test-deploy-hosting-cluster1-0", "cluster2", "hash1", false, 1, 1)
+					work.Labels[addonapiv1alpha1.AddonNamespaceLabelKey] = "cluster1"
+					return work
+				}(),
+			},
+			validateAddonActions: assertProgressing(constants.AddonProgressingReasonFailed, false),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeAddonClient := fakeaddon.NewSimpleClientset(c.addon...)
+			fakeWorkClient := fakework.NewSimpleClientset(c.works...)
+
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+			workInformers := workinformers.NewSharedInformerFactory(fakeWorkClient, 10*time.Minute)
+
+			for _, obj := range c.addon {
+				if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, obj := range c.works {
+				if err := workInformers.Work().V1().ManifestWorks().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			controller := &addonProgressingController{
+				addonClient:               fakeAddonClient,
+				managedClusterAddonLister: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				workLister:                workInformers.Work().V1().ManifestWorks().Lister(),
+				agentAddons:               map[string]agent.AgentAddon{"test": &testAgent{name: "test"}},
+			}
+
+			syncContext := addontesting.NewFakeSyncContext(t)
+			err := controller.sync(context.TODO(), syncContext, c.syncKey)
+			if err != nil {
+				t.Errorf("expected no error when sync: %v", err)
+			}
+
+			c.validateAddonActions(t, fakeAddonClient.Actions())
+		})
+	}
+}
+
+func TestProgressingConditionMessage(t *testing.T) {
+	cases := []struct {
+		name            string
+		addon           *addonapiv1alpha1.ManagedClusterAddOn
+		works           []*workapiv1.ManifestWork
+		expectedMessage string
+	}{
+		{
+			name:            "applying the desired config",
+			addon:           newAddonWithConfig("test", "cluster1", "hash2", nil),
+			works:           []*workapiv1.ManifestWork{newDeployWork("addon-test-deploy-0", "cluster1", "hash1", true, 1, 1)},
+			expectedMessage: "applying config foo.core//test hash hash2",
+		},
+		{
+			name:            "work failed to apply",
+			addon:           newAddonWithConfig("test", "cluster1", "hash1", nil),
+			works:           []*workapiv1.ManifestWork{newDeployWork("addon-test-deploy-0", "cluster1", "hash1", false, 1, 1)},
+			expectedMessage: "manifestwork cluster1/addon-test-deploy-0 failed to apply: ",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cond := progressingCondition(c.addon, c.works)
+			if cond.Message != c.expectedMessage {
+				t.Errorf("expected message %q, but got %q", c.expectedMessage, cond.Message)
+			}
+		})
+	}
+}
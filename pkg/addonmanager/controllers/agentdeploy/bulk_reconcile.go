@@ -0,0 +1,59 @@
+package agentdeploy
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/labels"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/index"
+)
+
+// syncAddons fans the keys of all the addons of the ClusterManagementAddOn back into the queue, e.g. when the
+// ClusterManagementAddOn or its default configs are changed on a large fleet. The addons are then synced by the
+// workers of the controller like any other key, so the keys of the single addons are not starved by the fleet,
+// and an addon failing to sync is retried separately.
+func (c *addonDeployController) syncAddons(ctx context.Context, syncCtx factory.SyncContext, addonName string) error {
+	if _, ok := c.agentAddons[addonName]; !ok {
+		return nil
+	}
+
+	keys, err := c.addonKeys(addonName)
+	if err != nil {
+		return err
+	}
+	_, logger := logging.WithValues(ctx, logging.AddonKey, addonName)
+	logger.V(4).Info("Queue the addons of the ClusterManagementAddOn", "addons", len(keys))
+	for _, key := range keys {
+		syncCtx.Queue().Add(key)
+	}
+	return nil
+}
+
+// addonKeys returns the queue keys of the managedclusteraddons of the addon.
+func (c *addonDeployController) addonKeys(addonName string) ([]string, error) {
+	addons, err := c.managedClusterAddonIndexer.ByIndex(index.ManagedClusterAddonByName, addonName)
+	if err != nil {
+		// the index is not added, list all the addons
+		addonList, err := c.managedClusterAddonLister.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, addon := range addonList {
+			addons = append(addons, addon)
+		}
+	}
+
+	var keys []string
+	for _, obj := range addons {
+		addon, ok := obj.(*addonapiv1alpha1.ManagedClusterAddOn)
+		if !ok || addon.Name != addonName {
+			continue
+		}
+		keys = append(keys, fmt.Sprintf("%s/%s", addon.Namespace, addon.Name))
+	}
+	return keys, nil
+}
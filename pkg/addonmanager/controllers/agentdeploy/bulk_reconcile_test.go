@@ -0,0 +1,188 @@
+package agentdeploy
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	fakecluster "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterv1informers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	fakework "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"open-cluster-management.io/api/utils/work/v1/workapplier"
+	"open-cluster-management.io/api/utils/work/v1/workbuilder"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/index"
+)
+
+// bulkAgent renders a ConfigMap for each cluster after the render delay, and fails on the failing clusters.
+type bulkAgent struct {
+	delay           time.Duration
+	failingClusters sets.Set[string]
+	renders         int32
+}
+
+func (a *bulkAgent) Manifests(cluster *clusterv1.ManagedCluster,
+	addon *addonapiv1alpha1.ManagedClusterAddOn) ([]runtime.Object, error) {
+	atomic.AddInt32(&a.renders, 1)
+	time.Sleep(a.delay)
+	if a.failingClusters.Has(cluster.Name) {
+		return nil, fmt.Errorf("failed to render the agent on cluster %s", cluster.Name)
+	}
+	return []runtime.Object{addontesting.NewUnstructured("v1", "ConfigMap", "default", "test")}, nil
+}
+
+func (a *bulkAgent) GetAgentAddonOptions() agent.AgentAddonOptions {
+	return agent.AgentAddonOptions{AddonName: "test"}
+}
+
+func newBulkController(t testing.TB, testAgent agent.AgentAddon, clusters int) (*addonDeployController, *fakework.Clientset) {
+	var clusterObjs, addonObjs []runtime.Object
+	for i := 0; i < clusters; i++ {
+		clusterName := fmt.Sprintf("cluster%d", i)
+		clusterObjs = append(clusterObjs, addontesting.NewManagedCluster(clusterName))
+		addonObjs = append(addonObjs, addontesting.NewAddon("test", clusterName))
+	}
+	// an addon of another clustermanagementaddon is not reconciled
+	addonObjs = append(addonObjs, addontesting.NewAddon("other", "cluster0"))
+
+	fakeWorkClient := fakework.NewSimpleClientset()
+	fakeClusterClient := fakecluster.NewSimpleClientset(clusterObjs...)
+	fakeAddonClient := fakeaddon.NewSimpleClientset(addonObjs...)
+
+	workInformerFactory := workinformers.NewSharedInformerFactory(fakeWorkClient, 10*time.Minute)
+	addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+	clusterInformers := clusterv1informers.NewSharedInformerFactory(fakeClusterClient, 10*time.Minute)
+
+	if err := workInformerFactory.Work().V1().ManifestWorks().Informer().AddIndexers(cache.Indexers{
+		byAddon:           indexByAddon,
+		byHostedAddon:     indexByHostedAddon,
+		hookByHostedAddon: indexHookByHostedAddon,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().AddIndexers(cache.Indexers{
+		index.ManagedClusterAddonByName: index.IndexManagedClusterAddonByName,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range clusterObjs {
+		if err := clusterInformers.Cluster().V1().ManagedClusters().Informer().GetStore().Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, obj := range addonObjs {
+		if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &addonDeployController{
+		workApplier:                  workapplier.NewWorkApplierWithTypedClient(fakeWorkClient, workInformerFactory.Work().V1().ManifestWorks().Lister()),
+		workBuilder:                  workbuilder.NewWorkBuilder(),
+		addonClient:                  fakeAddonClient,
+		managedClusterLister:         clusterInformers.Cluster().V1().ManagedClusters().Lister(),
+		managedClusterAddonLister:    addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+		managedClusterAddonIndexer:   addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetIndexer(),
+		clusterManagementAddonLister: addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Lister(),
+		workIndexer:                  workInformerFactory.Work().V1().ManifestWorks().Informer().GetIndexer(),
+		agentAddons:                  map[string]agent.AgentAddon{"test": testAgent},
+	}, fakeWorkClient
+}
+
+func TestSyncAddonsInBulk(t *testing.T) {
+	testAgent := &bulkAgent{failingClusters: sets.New[string]("cluster1", "cluster4")}
+	controller, fakeWorkClient := newBulkController(t, testAgent, 6)
+
+	syncContext := addontesting.NewFakeSyncContext(t)
+	if err := controller.sync(context.TODO(), syncContext, "test"); err != nil {
+		t.Errorf("expected no error when sync: %v", err)
+	}
+
+	// the addons are not rendered in the sync of the clustermanagementaddon, their keys are queued instead
+	if testAgent.renders != 0 {
+		t.Errorf("expected no renders in the sync of the clustermanagementaddon, but got %d", testAgent.renders)
+	}
+	queued := sets.New[string]()
+	for syncContext.Queue().Len() > 0 {
+		item, _ := syncContext.Queue().Get()
+		queued.Insert(item.(string))
+		syncContext.Queue().Done(item)
+	}
+	expectedQueued := sets.New[string]()
+	for i := 0; i < 6; i++ {
+		expectedQueued.Insert(fmt.Sprintf("cluster%d/test", i))
+	}
+	if !queued.Equal(expectedQueued) {
+		t.Errorf("expected the keys %v queued, but got %v", sets.List(expectedQueued), sets.List(queued))
+	}
+
+	// each queued addon is synced separately, a failing addon does not fail the others
+	for _, key := range sets.List(queued) {
+		err := controller.sync(context.TODO(), syncContext, key)
+		clusterName, _, _ := cache.SplitMetaNamespaceKey(key)
+		if testAgent.failingClusters.Has(clusterName) != (err != nil) {
+			t.Errorf("unexpected error when sync the addon %s: %v", key, err)
+		}
+	}
+	works := 0
+	for _, action := range fakeWorkClient.Actions() {
+		if action.GetVerb() == "create" {
+			works++
+		}
+	}
+	if works != 4 {
+		t.Errorf("expected 4 works created, but got %d", works)
+	}
+}
+
+func BenchmarkSyncAddonsInBulk(b *testing.B) {
+	for _, workers := range []int{1, 10, 50} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				// the render delay simulates the templates of an addon agent rendered with its configs
+				testAgent := &bulkAgent{delay: time.Millisecond, failingClusters: sets.New[string]()}
+				controller, _ := newBulkController(b, testAgent, 500)
+				syncContext := addontesting.NewFakeSyncContext(nil)
+				b.StartTimer()
+
+				if err := controller.sync(context.TODO(), syncContext, "test"); err != nil {
+					b.Fatal(err)
+				}
+				// the queued addons are synced by the workers of the controller until the queue is empty
+				syncContext.Queue().ShutDown()
+				done := make(chan struct{})
+				for w := 0; w < workers; w++ {
+					go func() {
+						defer func() { done <- struct{}{} }()
+						for {
+							item, shutdown := syncContext.Queue().Get()
+							if shutdown {
+								return
+							}
+							if err := controller.sync(context.TODO(), syncContext, item.(string)); err != nil {
+								b.Error(err)
+							}
+							syncContext.Queue().Done(item)
+						}
+					}()
+				}
+				for w := 0; w < workers; w++ {
+					<-done
+				}
+			}
+		})
+	}
+}
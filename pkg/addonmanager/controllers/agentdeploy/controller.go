@@ -4,14 +4,18 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	errorsutil "k8s.io/apimachinery/pkg/util/errors"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/tools/cache"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
@@ -27,6 +31,8 @@ import (
 	workapiv1 "open-cluster-management.io/api/work/v1"
 
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/metrics"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
 	"open-cluster-management.io/addon-framework/pkg/utils"
@@ -34,22 +40,64 @@ import (
 
 // addonDeployController deploy addon agent resources on the managed cluster.
 type addonDeployController struct {
-	workApplier               *workapplier.WorkApplier
-	workBuilder               *workbuilder.WorkBuilder
-	addonClient               addonv1alpha1client.Interface
-	managedClusterLister      clusterlister.ManagedClusterLister
-	managedClusterAddonLister addonlisterv1alpha1.ManagedClusterAddOnLister
-	workIndexer               cache.Indexer
-	agentAddons               map[string]agent.AgentAddon
+	workApplier                  workApplier
+	workClient                   workv1client.Interface
+	workBuilder                  *workbuilder.WorkBuilder
+	addonClient                  addonv1alpha1client.Interface
+	eventRecorder                utils.AddonEventRecorder
+	managedClusterLister         clusterlister.ManagedClusterLister
+	managedClusterAddonLister    addonlisterv1alpha1.ManagedClusterAddOnLister
+	managedClusterAddonIndexer   cache.Indexer
+	clusterManagementAddonLister addonlisterv1alpha1.ClusterManagementAddOnLister
+	workIndexer                  cache.Indexer
+	agentAddons                  map[string]agent.AgentAddon
+	// addOnDeploymentConfigGetter reads the AddOnDeploymentConfigs of the node placement from the cache
+	addOnDeploymentConfigGetter utils.AddOnDeploymentConfigGetter
+	// installThrottle limits the first-time installs of the addons
+	installThrottle *installThrottle
+	// staleWorkPruneDryRun only logs the stale deploy works instead of deleting them
+	staleWorkPruneDryRun bool
+	// manifestsCleanupTimeout is how long the pre-delete hook waits for the deploy works to be removed
+	manifestsCleanupTimeout time.Duration
+}
+
+// Options tunes the addon deploy controller.
+type Options struct {
+	// InitialInstallsPerMinute limits the number of the addons receiving their first manifestworks per minute,
+	// to protect the hub when a large number of clusters are registered at the same time, e.g. when a whole
+	// fleet is re-registered. The updates of the existing manifestworks are not limited. It is unlimited if it
+	// is not positive.
+	InitialInstallsPerMinute int
+
+	// WorkApplyQPS and WorkApplyBurst limit the creates and updates of the manifestworks in each cluster
+	// namespace, so a large fleet of addons does not hammer the hub API server. The applies that change nothing
+	// are skipped and not limited, and the throttled addons are requeued when the limit allows. It is unlimited
+	// if the qps is not positive.
+	WorkApplyQPS   float64
+	WorkApplyBurst int
+
+	// StaleWorkPruneDryRun only logs the stale deploy works of the addons, which are the works not built from the
+	// manifests of the addon agent anymore, e.g. when a resource or a whole shard of the manifests is dropped,
+	// instead of deleting them. The works of the disabled or deleted addons are still deleted.
+	StaleWorkPruneDryRun bool
+
+	// ManifestsCleanupTimeout is how long the pre-delete hook of a deleting addon waits for its deploy
+	// manifestworks to be removed from the managed cluster, e.g. when the cluster is offline. The
+	// utils.DefaultManifestsCleanupTimeout is used if it is not positive.
+	ManifestsCleanupTimeout time.Duration
 }
 
 func NewAddonDeployController(
 	workClient workv1client.Interface,
 	addonClient addonv1alpha1client.Interface,
+	eventRecorder utils.AddonEventRecorder,
 	clusterInformers clusterinformers.ManagedClusterInformer,
 	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	clusterManagementAddonInformers addoninformerv1alpha1.ClusterManagementAddOnInformer,
+	addOnDeploymentConfigInformers addoninformerv1alpha1.AddOnDeploymentConfigInformer,
 	workInformers workinformers.ManifestWorkInformer,
 	agentAddons map[string]agent.AgentAddon,
+	options Options,
 ) factory.Controller {
 	err := workInformers.Informer().AddIndexers(
 		cache.Indexers{
@@ -64,15 +112,25 @@ func NewAddonDeployController(
 	}
 
 	c := &addonDeployController{
-		workApplier: workapplier.NewWorkApplierWithTypedClient(workClient, workInformers.Lister()),
+		workApplier: newCachedWorkApplier(
+			workapplier.NewWorkApplierWithTypedClient(workClient, workInformers.Lister()),
+			workInformers.Lister(), options.WorkApplyQPS, options.WorkApplyBurst),
+		workClient: workClient,
 		// the default manifest limit in a work is 500k
 		// TODO: make the limit configurable
-		workBuilder:               workbuilder.NewWorkBuilder().WithManifestsLimit(500 * 1024),
-		addonClient:               addonClient,
-		managedClusterLister:      clusterInformers.Lister(),
-		managedClusterAddonLister: addonInformers.Lister(),
-		workIndexer:               workInformers.Informer().GetIndexer(),
-		agentAddons:               agentAddons,
+		workBuilder:                  workbuilder.NewWorkBuilder().WithManifestsLimit(manifestWorkSizeLimit),
+		addonClient:                  addonClient,
+		eventRecorder:                eventRecorder,
+		managedClusterLister:         clusterInformers.Lister(),
+		managedClusterAddonLister:    addonInformers.Lister(),
+		managedClusterAddonIndexer:   addonInformers.Informer().GetIndexer(),
+		clusterManagementAddonLister: clusterManagementAddonInformers.Lister(),
+		workIndexer:                  workInformers.Informer().GetIndexer(),
+		agentAddons:                  agentAddons,
+		addOnDeploymentConfigGetter:  utils.NewAddOnDeploymentConfigGetterFromLister(addOnDeploymentConfigInformers.Lister()),
+		installThrottle:              newInstallThrottle(options.InitialInstallsPerMinute),
+		staleWorkPruneDryRun:         options.StaleWorkPruneDryRun,
+		manifestsCleanupTimeout:      options.ManifestsCleanupTimeout,
 	}
 
 	return factory.New().WithFilteredEventsInformersQueueKeysFunc(
@@ -124,9 +182,29 @@ func NewAddonDeployController(
 			},
 			workInformers.Informer(),
 		).
+		WithFilteredEventsInformersQueueKeysFunc(
+			c.clusterManagementAddonQueueKeysFunc,
+			func(obj interface{}) bool {
+				accessor, _ := meta.Accessor(obj)
+				_, ok := c.agentAddons[accessor.GetName()]
+				return ok
+			},
+			clusterManagementAddonInformers.Informer(),
+		).
+		// the changes of the AddOnDeploymentConfigs re-render the addons by the spec hashes in the addon status
+		WithBareInformers(addOnDeploymentConfigInformers.Informer()).
+		WithQueuePartitionFunc(factory.NamePartitionFunc).
+		WithPriorityFunc(utils.AddonHealthDegraded).
 		WithSync(c.sync).ToController("addon-deploy-controller")
 }
 
+// clusterManagementAddonQueueKeysFunc queues the clustermanagementaddon by its name, so all the addons of the
+// clustermanagementaddon are reconciled in bulk once it is paused or resumed, or its configs are changed.
+func (c *addonDeployController) clusterManagementAddonQueueKeysFunc(obj runtime.Object) []string {
+	accessor, _ := meta.Accessor(obj)
+	return []string{accessor.GetName()}
+}
+
 type addonDeploySyncer interface {
 	sync(ctx context.Context, syncCtx factory.SyncContext,
 		cluster *clusterv1.ManagedCluster,
@@ -155,6 +233,20 @@ func (c *addonDeployController) sync(ctx context.Context, syncCtx factory.SyncCo
 		return nil
 	}
 
+	// the key of the clustermanagementaddon has no namespace
+	if len(clusterName) == 0 {
+		return c.syncAddons(ctx, syncCtx, addonName)
+	}
+	return c.syncAddon(ctx, syncCtx, key)
+}
+
+func (c *addonDeployController) syncAddon(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+	clusterName, addonName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil
+	}
+	ctx, logger := logging.WithAddon(ctx, clusterName, addonName)
+
 	agentAddon, ok := c.agentAddons[addonName]
 	if !ok {
 		return nil
@@ -163,6 +255,9 @@ func (c *addonDeployController) sync(ctx context.Context, syncCtx factory.SyncCo
 	addon, err := c.managedClusterAddonLister.ManagedClusterAddOns(clusterName).Get(addonName)
 	if errors.IsNotFound(err) {
 		// need to find a way to clean up cache by addon
+		if c.installThrottle != nil {
+			c.installThrottle.forget(key)
+		}
 		return nil
 	}
 	if err != nil {
@@ -179,28 +274,63 @@ func (c *addonDeployController) sync(ctx context.Context, syncCtx factory.SyncCo
 		return err
 	}
 
+	// do not update the manifestworks of the paused addon, the deployed works are kept and the health of the
+	// addon is still reported until it is resumed.
+	if addon.DeletionTimestamp.IsZero() {
+		cma, err := c.clusterManagementAddonLister.Get(addonName)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if utils.IsAddonPaused(cma, addon) {
+			logger.V(4).Info("The addon is paused, skip updating its manifestworks")
+			return nil
+		}
+	}
+
+	// do not render the agent with the configs unsupported by the addon, the deployed works are kept until
+	// the configs are fixed.
+	if addon.DeletionTimestamp.IsZero() && meta.IsStatusConditionTrue(addon.Status.Conditions,
+		addonapiv1alpha1.ManagedClusterAddOnUnsupportedConfigurationType) {
+		logger.V(4).Info("The configs of the addon are unsupported, skip rendering the agent")
+		return nil
+	}
+
+	driftDetection := agentAddon.GetAgentAddonOptions().ManifestDriftDetection
+	drifted := sets.New[string]()
+	applyWork := func(ctx context.Context, appliedType string, work *workapiv1.ManifestWork,
+		addon *addonapiv1alpha1.ManagedClusterAddOn) (*workapiv1.ManifestWork, error) {
+		return c.applyWorkWithDriftDetection(ctx, appliedType, work, addon, driftDetection, drifted)
+	}
+
 	syncers := []addonDeploySyncer{
 		&defaultSyncer{
-			buildWorks:     c.buildDeployManifestWorks,
-			applyWork:      c.applyWork,
-			getWorkByAddon: c.getWorksByAddonFn(byAddon),
-			deleteWork:     c.workApplier.Delete,
-			agentAddon:     agentAddon,
+			buildWorks:      c.buildDeployManifestWorks,
+			applyWork:       applyWork,
+			getWorkByAddon:  c.getWorksByAddonFn(byAddon),
+			deleteWork:      c.workApplier.Delete,
+			agentAddon:      agentAddon,
+			installThrottle: c.installThrottle,
+			pruneDryRun:     c.staleWorkPruneDryRun,
 		},
 		&hostedSyncer{
-			buildWorks:     c.buildDeployManifestWorks,
-			applyWork:      c.applyWork,
-			deleteWork:     c.workApplier.Delete,
-			getCluster:     c.managedClusterLister.Get,
-			getWorkByAddon: c.getWorksByAddonFn(byHostedAddon),
-			agentAddon:     agentAddon},
+			buildWorks:      c.buildDeployManifestWorks,
+			applyWork:       applyWork,
+			deleteWork:      c.workApplier.Delete,
+			getCluster:      c.managedClusterLister.Get,
+			getWorkByAddon:  c.getWorksByAddonFn(byHostedAddon),
+			agentAddon:      agentAddon,
+			installThrottle: c.installThrottle,
+			pruneDryRun:     c.staleWorkPruneDryRun},
 		&defaultHookSyncer{
-			buildWorks: c.buildHookManifestWork,
-			applyWork:  c.applyWork,
-			agentAddon: agentAddon},
+			buildWorks:              c.buildHookManifestWork,
+			applyWork:               applyWork,
+			getWorkByAddon:          c.getWorksByAddonFn(byAddon),
+			agentAddon:              agentAddon,
+			eventRecorder:           c.eventRecorder,
+			manifestsCleanupTimeout: c.manifestsCleanupTimeout},
 		&hostedHookSyncer{
 			buildWorks:     c.buildHookManifestWork,
-			applyWork:      c.applyWork,
+			applyWork:      applyWork,
 			deleteWork:     c.workApplier.Delete,
 			getCluster:     c.managedClusterLister.Get,
 			getWorkByAddon: c.getWorksByAddonFn(hookByHostedAddon),
@@ -218,28 +348,89 @@ func (c *addonDeployController) sync(ctx context.Context, syncCtx factory.SyncCo
 		}
 	}
 
+	var works []*workapiv1.ManifestWork
+	for _, index := range []string{byAddon, byHostedAddon} {
+		indexedWorks, err := c.getWorksByAddonFn(index)(addonName, clusterName)
+		if err != nil {
+			return err
+		}
+		works = append(works, indexedWorks...)
+	}
+	setManifestApplyConflictCondition(addon, works)
+	if driftDetection != nil && setManifestDriftedCondition(addon, drifted) {
+		metrics.RecordManifestWorkDrift(addonName)
+		utils.RecordAddonEvent(c.eventRecorder, addon, corev1.EventTypeWarning, constants.EventReasonManifestWorkDrifted,
+			"the ManifestWorks %s are modified out-of-band", strings.Join(sets.List(drifted), ", "))
+	}
+
 	if err = c.updateAddon(ctx, addon, oldAddon); err != nil {
 		return err
 	}
-	return errorsutil.NewAggregate(errs)
+
+	// the throttled applies are retried when the rate limit of the cluster allows, instead of the backoff.
+	err = errorsutil.NewAggregate(errs)
+	if delay, ok := workApplyThrottled(err); ok {
+		logger.V(4).Info("The manifestwork applies of the addon are throttled", "retryAfter", delay)
+		syncCtx.Queue().AddAfter(key, delay)
+		return nil
+	}
+	return err
 }
 
-// updateAddon updates finalizers and conditions of addon.
-// to avoid conflict updateAddon updates finalizers firstly if finalizers has change.
+// updateAddon updates finalizers and status of addon.
+// to avoid conflict updateAddon updates finalizers firstly if finalizers has change, the status changes of the
+// sync are patched together otherwise.
 func (c *addonDeployController) updateAddon(ctx context.Context, new, old *addonapiv1alpha1.ManagedClusterAddOn) error {
 	if !equality.Semantic.DeepEqual(new.GetFinalizers(), old.GetFinalizers()) {
 		_, err := c.addonClient.AddonV1alpha1().ManagedClusterAddOns(new.Namespace).Update(ctx, new, metav1.UpdateOptions{})
 		return err
 	}
 
-	return utils.PatchAddonCondition(ctx, c.addonClient, new, old)
+	return utils.PatchAddonStatus(ctx, c.addonClient, c.eventRecorder, new, old)
 }
 
 func (c *addonDeployController) applyWork(ctx context.Context, appliedType string,
 	work *workapiv1.ManifestWork, addon *addonapiv1alpha1.ManagedClusterAddOn) (*workapiv1.ManifestWork, error) {
+	return c.applyWorkWithDriftDetection(ctx, appliedType, work, addon, nil, nil)
+}
+
+// applyWorkWithDriftDetection applies the required work and sets the applied condition of the addon by the
+// status of the work. If the drift detection is enabled, the work is stamped with the digest of its manifests,
+// and the drifted work is kept unless it is restored automatically.
+func (c *addonDeployController) applyWorkWithDriftDetection(ctx context.Context, appliedType string,
+	work *workapiv1.ManifestWork, addon *addonapiv1alpha1.ManagedClusterAddOn,
+	driftDetection *agent.ManifestDriftDetection, drifted sets.Set[string]) (*workapiv1.ManifestWork, error) {
+
+	required := work
+	var existing *workapiv1.ManifestWork
+	if obj, exists, _ := c.workIndexer.GetByKey(fmt.Sprintf("%s/%s", work.Namespace, work.Name)); exists {
+		existing = obj.(*workapiv1.ManifestWork)
+	}
 
-	work, err := c.workApplier.Apply(ctx, work)
+	var keep bool
+	var err error
+	if driftDetection != nil {
+		keep, err = detectManifestDrift(c.eventRecorder, required, existing, addon, driftDetection, drifted)
+	}
+	switch {
+	case err != nil:
+	case keep:
+		work = existing.DeepCopy()
+	default:
+		work, err = c.workApplier.Apply(ctx, work)
+		if err == nil && (existing == nil || existing.ResourceVersion != work.ResourceVersion) {
+			utils.RecordAddonEvent(c.eventRecorder, addon, corev1.EventTypeNormal, constants.EventReasonManifestsRendered,
+				"the rendered manifests of the addon are applied to ManifestWork %s/%s", required.Namespace, required.Name)
+		}
+		if err == nil {
+			work, err = c.patchWorkMetadata(ctx, required, work)
+		}
+	}
+	if _, throttled := workApplyThrottled(err); throttled {
+		return work, err
+	}
 	if err != nil {
+		metrics.RecordManifestWorkApplyError(addon.Name)
 		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
 			Type:    appliedType,
 			Status:  metav1.ConditionFalse,
@@ -268,7 +459,22 @@ func (c *addonDeployController) applyWork(ctx context.Context, appliedType strin
 	return work, nil
 }
 
-func (c *addonDeployController) buildDeployManifestWorks(installMode, workNamespace string,
+// patchWorkMetadata patches the labels and annotations from the render output onto the existing work, since the
+// work applier only updates the spec of the existing work.
+func (c *addonDeployController) patchWorkMetadata(ctx context.Context,
+	required, existing *workapiv1.ManifestWork) (*workapiv1.ManifestWork, error) {
+	patch, err := workMetadataPatch(required, existing)
+	if err != nil || patch == nil {
+		return existing, err
+	}
+
+	_, logger := logging.WithWork(ctx, existing)
+	logger.V(2).Info("Patching the metadata of the work", "patch", string(patch))
+	return c.workClient.WorkV1().ManifestWorks(existing.Namespace).Patch(
+		ctx, existing.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+}
+
+func (c *addonDeployController) buildDeployManifestWorks(ctx context.Context, installMode, workNamespace string,
 	cluster *clusterv1.ManagedCluster, existingWorks []*workapiv1.ManifestWork,
 	addon *addonapiv1alpha1.ManagedClusterAddOn) (appliedWorks, deleteWorks []*workapiv1.ManifestWork, err error) {
 	var appliedType string
@@ -290,7 +496,41 @@ func (c *addonDeployController) buildDeployManifestWorks(installMode, workNamesp
 		return nil, nil, fmt.Errorf("invalid install mode %v", installMode)
 	}
 
+	registrationNamespace, err := resolveRegistrationNamespace(agentAddon, addon, appliedType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if override, ok := agentAddon.(agent.WorksBuilderOverride); ok {
+		if installMode != constants.InstallModeDefault {
+			return nil, nil, nil
+		}
+
+		works, err := override.BuildWorks(cluster, addon)
+		if err == nil {
+			appliedWorks, deleteWorks, err = buildOverrideWorks(workNamespace, addon, existingWorks, works)
+		}
+		if err != nil {
+			meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+				Type:    appliedType,
+				Status:  metav1.ConditionFalse,
+				Reason:  addonapiv1alpha1.AddonManifestAppliedReasonWorkApplyFailed,
+				Message: fmt.Sprintf("failed to build manifestwork: %v", err),
+			})
+			return nil, nil, err
+		}
+		setAgentVersion(appliedWorks, agentAddon.GetAgentAddonOptions().Version)
+		return appliedWorks, deleteWorks, nil
+	}
+
 	objects, err := agentAddon.Manifests(cluster, addon)
+	setValuesInvalidCondition(addon, err)
+	if err == nil {
+		objects, err = injectNodePlacement(ctx, c.addOnDeploymentConfigGetter, addon, objects)
+	}
+	if err == nil {
+		objects = injectRegistrationNamespace(addon, registrationNamespace, objects)
+	}
 	if err != nil {
 		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
 			Type:    appliedType,
@@ -301,15 +541,18 @@ func (c *addonDeployController) buildDeployManifestWorks(installMode, workNamesp
 		return nil, nil, err
 	}
 	if len(objects) == 0 {
+		// an empty render keeps the existing works, it could be caused by a transient failure of the addon agent,
+		// and the works are deleted when the addon is disabled or deleted.
 		return nil, nil, nil
 	}
 
-	manifestOptions := getManifestConfigOption(agentAddon)
+	manifestOptions := getManifestConfigOption(agentAddon, objects)
 	existingWorksCopy := []workapiv1.ManifestWork{}
 	for _, work := range existingWorks {
 		existingWorksCopy = append(existingWorksCopy, *work)
 	}
-	appliedWorks, deleteWorks, err = addonWorkBuilder.BuildDeployWorks(workNamespace, addon, existingWorksCopy, objects, manifestOptions)
+	appliedWorks, deleteWorks, err = addonWorkBuilder.BuildDeployWorks(workNamespace, addon, existingWorksCopy, objects, manifestOptions,
+		deployWorkConfiguration(agentAddon.GetAgentAddonOptions(), addon))
 	if err != nil {
 		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
 			Type:    appliedType,
@@ -319,9 +562,10 @@ func (c *addonDeployController) buildDeployManifestWorks(installMode, workNamesp
 		})
 		return nil, nil, err
 	}
+	setAgentVersion(appliedWorks, agentAddon.GetAgentAddonOptions().Version)
 	return appliedWorks, deleteWorks, nil
 }
-func (c *addonDeployController) buildHookManifestWork(installMode, workNamespace string,
+func (c *addonDeployController) buildHookManifestWork(ctx context.Context, installMode, workNamespace string,
 	cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) (*workapiv1.ManifestWork, error) {
 	var appliedType string
 	var addonWorkBuilder *addonWorksBuilder
@@ -342,7 +586,19 @@ func (c *addonDeployController) buildHookManifestWork(installMode, workNamespace
 		return nil, fmt.Errorf("invalid install mode %v", installMode)
 	}
 
+	registrationNamespace, err := resolveRegistrationNamespace(agentAddon, addon, appliedType)
+	if err != nil {
+		return nil, err
+	}
+
 	objects, err := agentAddon.Manifests(cluster, addon)
+	setValuesInvalidCondition(addon, err)
+	if err == nil {
+		objects, err = injectNodePlacement(ctx, c.addOnDeploymentConfigGetter, addon, objects)
+	}
+	if err == nil {
+		objects = injectRegistrationNamespace(addon, registrationNamespace, objects)
+	}
 	if err != nil {
 		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
 			Type:    appliedType,
@@ -356,7 +612,7 @@ func (c *addonDeployController) buildHookManifestWork(installMode, workNamespace
 		return nil, nil
 	}
 
-	hookWork, err := addonWorkBuilder.BuildHookWork(workNamespace, addon, objects)
+	hookWork, err := addonWorkBuilder.BuildHookWork(workNamespace, addon, objects, agentAddon.GetAgentAddonOptions().WorkConfiguration)
 	if err != nil {
 		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
 			Type:    appliedType,
@@ -368,3 +624,31 @@ func (c *addonDeployController) buildHookManifestWork(installMode, workNamespace
 	}
 	return hookWork, nil
 }
+
+// resolveRegistrationNamespace returns the registration namespace of the manifests of the addon, which is the
+// agent install namespace resolved by the AgentInstallNamespace of the agent, or the namespace in the status of
+// the addon if it is not resolved. The deploy and the hook works wait until the agent install namespace is
+// resolved, so the manifests are not deployed in the spec.installNamespace and then moved, and the status of the
+// addon may not be updated with it by the registration controller yet.
+func resolveRegistrationNamespace(agentAddon agent.AgentAddon, addon *addonapiv1alpha1.ManagedClusterAddOn,
+	appliedType string) (string, error) {
+	nsFunc := agentAddon.GetAgentAddonOptions().AgentInstallNamespace
+	if nsFunc == nil {
+		return addon.Status.Namespace, nil
+	}
+
+	namespace, err := nsFunc(addon)
+	if err != nil {
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:    appliedType,
+			Status:  metav1.ConditionFalse,
+			Reason:  addonapiv1alpha1.AddonManifestAppliedReasonWorkApplyFailed,
+			Message: fmt.Sprintf("failed to get the agent install namespace: %v", err),
+		})
+		return "", err
+	}
+	if len(namespace) == 0 {
+		return addon.Status.Namespace, nil
+	}
+	return namespace, nil
+}
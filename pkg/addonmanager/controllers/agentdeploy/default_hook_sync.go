@@ -3,7 +3,9 @@ package agentdeploy
 import (
 	"context"
 	"fmt"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
@@ -13,14 +15,19 @@ import (
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 )
 
 type defaultHookSyncer struct {
-	buildWorks func(installMode, workNamespace string, cluster *clusterv1.ManagedCluster,
+	buildWorks func(ctx context.Context, installMode, workNamespace string, cluster *clusterv1.ManagedCluster,
 		addon *addonapiv1alpha1.ManagedClusterAddOn) (*workapiv1.ManifestWork, error)
 	applyWork func(ctx context.Context, appliedType string,
 		work *workapiv1.ManifestWork, addon *addonapiv1alpha1.ManagedClusterAddOn) (*workapiv1.ManifestWork, error)
-	agentAddon agent.AgentAddon
+	getWorkByAddon func(addonName, addonNamespace string) ([]*workapiv1.ManifestWork, error)
+	agentAddon     agent.AgentAddon
+	eventRecorder  utils.AddonEventRecorder
+	// manifestsCleanupTimeout is how long the pre-delete hook waits for the deploy works to be removed
+	manifestsCleanupTimeout time.Duration
 }
 
 func (s *defaultHookSyncer) sync(ctx context.Context,
@@ -29,7 +36,7 @@ func (s *defaultHookSyncer) sync(ctx context.Context,
 	addon *addonapiv1alpha1.ManagedClusterAddOn) (*addonapiv1alpha1.ManagedClusterAddOn, error) {
 	deployWorkNamespace := addon.Namespace
 
-	hookWork, err := s.buildWorks(constants.InstallModeDefault, deployWorkNamespace, cluster, addon)
+	hookWork, err := s.buildWorks(ctx, constants.InstallModeDefault, deployWorkNamespace, cluster, addon)
 	if err != nil {
 		return addon, err
 	}
@@ -47,6 +54,26 @@ func (s *defaultHookSyncer) sync(ctx context.Context,
 		return addon, nil
 	}
 
+	// the pre-delete hook runs after the deploy works are removed, or the manifests cleanup timeout is exceeded.
+	deployWorks, err := s.getWorkByAddon(addon.Name, addon.Namespace)
+	if err != nil {
+		return addon, err
+	}
+	if wait := utils.ManifestsCleanupWait(addon, deployWorks, s.manifestsCleanupTimeout); wait > 0 {
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:    addonapiv1alpha1.ManagedClusterAddOnHookManifestCompleted,
+			Status:  metav1.ConditionFalse,
+			Reason:  constants.HookManifestReasonWaitingForManifestsCleanup,
+			Message: "waiting for the deploy manifestWorks to be removed before running the pre-delete hook.",
+		})
+		syncCtx.Queue().AddAfter(fmt.Sprintf("%s/%s", addon.Namespace, addon.Name), wait)
+		return addon, nil
+	}
+	if len(utils.DeployWorks(addon.Name, deployWorks)) > 0 {
+		utils.RecordAddonEvent(s.eventRecorder, addon, corev1.EventTypeWarning, constants.EventReasonManifestsCleanupTimeout,
+			"the deploy manifestWorks are not removed in time, running the pre-delete hook")
+	}
+
 	// will deploy the pre-delete hook manifestWork when the addon is deleting
 	hookWork, err = s.applyWork(ctx, addonapiv1alpha1.ManagedClusterAddOnManifestApplied, hookWork, addon)
 	if err != nil {
@@ -58,7 +85,7 @@ func (s *defaultHookSyncer) sync(ctx context.Context,
 		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
 			Type:    addonapiv1alpha1.ManagedClusterAddOnHookManifestCompleted,
 			Status:  metav1.ConditionTrue,
-			Reason:  "HookManifestIsCompleted",
+			Reason:  constants.HookManifestReasonCompleted,
 			Message: fmt.Sprintf("hook manifestWork %v is completed.", hookWork.Name),
 		})
 
@@ -69,7 +96,7 @@ func (s *defaultHookSyncer) sync(ctx context.Context,
 	meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
 		Type:    addonapiv1alpha1.ManagedClusterAddOnHookManifestCompleted,
 		Status:  metav1.ConditionFalse,
-		Reason:  "HookManifestIsNotCompleted",
+		Reason:  constants.HookManifestReasonNotCompleted,
 		Message: fmt.Sprintf("hook manifestWork %v is not completed.", hookWork.Name),
 	})
 
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -16,6 +17,7 @@ import (
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
 	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
 	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
@@ -34,6 +36,7 @@ func TestDefaultHookReconcile(t *testing.T) {
 		key                  string
 		existingWork         []runtime.Object
 		addon                []runtime.Object
+		configs              []runtime.Object
 		testaddon            *testAgent
 		cluster              []runtime.Object
 		validateAddonActions func(t *testing.T, actions []clienttesting.Action)
@@ -101,6 +104,99 @@ func TestDefaultHookReconcile(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "deploy hook manifest with the agent install namespace of the addon deployment config",
+			key:  "cluster1/test",
+			addon: []runtime.Object{func() *addonapiv1alpha1.ManagedClusterAddOn {
+				addon := addontesting.SetAddonFinalizers(
+					addontesting.SetAddonDeletionTimestamp(addontesting.NewAddon("test", "cluster1"), time.Now()),
+					addonapiv1alpha1.AddonPreDeleteHookFinalizer)
+				addon.Status.Namespace = "open-cluster-management-agent-addon"
+				addon.Status.ConfigReferences = []addonapiv1alpha1.ConfigReference{{
+					ConfigGroupResource: utils.AddOnDeploymentConfigGroupResource,
+					ConfigReferent:      addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config"},
+					DesiredConfig: &addonapiv1alpha1.ConfigSpecHash{
+						ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config"},
+						SpecHash:       "hash",
+					},
+				}}
+				return addon
+			}()},
+			configs: []runtime.Object{&addonapiv1alpha1.AddOnDeploymentConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "config",
+					Namespace:   "cluster1",
+					Annotations: map[string]string{constants.AgentInstallNamespaceAnnotationKey: "agent-ns"},
+				},
+			}},
+			cluster: []runtime.Object{addontesting.NewManagedCluster("cluster1")},
+			testaddon: &testAgent{name: "test", objects: []runtime.Object{
+				addontesting.NewUnstructured("v1", "ConfigMap", "agent-ns", "test"),
+				addontesting.NewHookJob("test", "agent-ns")}},
+			validateWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "create")
+				hookWork := actions[0].(clienttesting.CreateActionImpl).Object.(*workapiv1.ManifestWork)
+				if hookWork.Name != constants.PreDeleteHookWorkName("test") || len(hookWork.Spec.Workload.Manifests) != 2 {
+					t.Fatalf("expected the hook work with the registration namespace and the hook job, but got %v", hookWork)
+				}
+				ns := &corev1.Namespace{}
+				if err := json.Unmarshal(hookWork.Spec.Workload.Manifests[0].Raw, ns); err != nil {
+					t.Fatal(err)
+				}
+				if ns.Kind != "Namespace" || ns.Name != "agent-ns" {
+					t.Errorf("expected the registration namespace agent-ns, but got %s %s", ns.Kind, ns.Name)
+				}
+				deleteOption := hookWork.Spec.DeleteOption
+				if deleteOption == nil || deleteOption.SelectivelyOrphan == nil ||
+					len(deleteOption.SelectivelyOrphan.OrphaningRules) != 1 ||
+					deleteOption.SelectivelyOrphan.OrphaningRules[0].Name != "agent-ns" {
+					t.Errorf("expected the registration namespace orphaned, but got %v", deleteOption)
+				}
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				// the addon deployment config is read from the cache
+				addontesting.AssertActions(t, actions, "patch")
+			},
+		},
+		{
+			name: "delete the deploy works of a deleting addon before the hook",
+			key:  "cluster1/test",
+			addon: []runtime.Object{
+				addontesting.SetAddonFinalizers(
+					addontesting.SetAddonDeletionTimestamp(addontesting.NewAddon("test", "cluster1"), time.Now()),
+					addonapiv1alpha1.AddonPreDeleteHookFinalizer),
+			},
+			cluster: []runtime.Object{addontesting.NewManagedCluster("cluster1")},
+			testaddon: &testAgent{name: "test", objects: []runtime.Object{
+				addontesting.NewUnstructured("v1", "ConfigMap", "default", "test"),
+				addontesting.NewHookJob("default", "test")}},
+			existingWork: []runtime.Object{func() *workapiv1.ManifestWork {
+				work := addontesting.NewManifestWork("addon-test-deploy-0", "cluster1",
+					addontesting.NewUnstructured("v1", "ConfigMap", "default", "test"))
+				work.SetLabels(map[string]string{addonapiv1alpha1.AddonLabelKey: "test"})
+				return work
+			}()},
+			validateWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "delete")
+				deleteAction := actions[0].(clienttesting.DeleteActionImpl)
+				if deleteAction.Name != "addon-test-deploy-0" {
+					t.Errorf("expected the deploy work addon-test-deploy-0 deleted, but got %s", deleteAction.Name)
+				}
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				patch := actions[0].(clienttesting.PatchActionImpl).Patch
+				addOn := &addonapiv1alpha1.ManagedClusterAddOn{}
+				err := json.Unmarshal(patch, addOn)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cond := meta.FindStatusCondition(addOn.Status.Conditions, addonapiv1alpha1.ManagedClusterAddOnHookManifestCompleted)
+				if cond == nil || cond.Reason != constants.HookManifestReasonWaitingForManifestsCleanup {
+					t.Errorf("expected the hook waiting for the deploy works, but got %v", cond)
+				}
+			},
+		},
 		{
 			name: "deploy hook manifest for a deleting addon with finalizer, completed",
 			key:  "cluster1/test",
@@ -296,15 +392,25 @@ func TestDefaultHookReconcile(t *testing.T) {
 					t.Errorf("failed to add work object to informer: %v", err)
 				}
 			}
+			for _, obj := range c.configs {
+				if err := addonInformers.Addon().V1alpha1().AddOnDeploymentConfigs().Informer().GetStore().Add(obj); err != nil {
+					t.Errorf("failed to add config object to informer: %v", err)
+				}
+			}
+			c.testaddon.installNamespaceFunc = utils.AgentInstallNamespaceFromDeploymentConfigFunc(
+				utils.NewAddOnDeploymentConfigGetterFromLister(addonInformers.Addon().V1alpha1().AddOnDeploymentConfigs().Lister()))
 
 			controller := addonDeployController{
-				workApplier:               workapplier.NewWorkApplierWithTypedClient(fakeWorkClient, workInformerFactory.Work().V1().ManifestWorks().Lister()),
-				workBuilder:               workbuilder.NewWorkBuilder(),
-				addonClient:               fakeAddonClient,
-				managedClusterLister:      clusterInformers.Cluster().V1().ManagedClusters().Lister(),
-				managedClusterAddonLister: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
-				workIndexer:               workInformerFactory.Work().V1().ManifestWorks().Informer().GetIndexer(),
-				agentAddons:               map[string]agent.AgentAddon{c.testaddon.name: c.testaddon},
+				workApplier:                  workapplier.NewWorkApplierWithTypedClient(fakeWorkClient, workInformerFactory.Work().V1().ManifestWorks().Lister()),
+				workBuilder:                  workbuilder.NewWorkBuilder(),
+				addonClient:                  fakeAddonClient,
+				managedClusterLister:         clusterInformers.Cluster().V1().ManagedClusters().Lister(),
+				managedClusterAddonLister:    addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				clusterManagementAddonLister: addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Lister(),
+				workIndexer:                  workInformerFactory.Work().V1().ManifestWorks().Informer().GetIndexer(),
+				agentAddons:                  map[string]agent.AgentAddon{c.testaddon.name: c.testaddon},
+				addOnDeploymentConfigGetter: utils.NewAddOnDeploymentConfigGetterFromLister(
+					addonInformers.Addon().V1alpha1().AddOnDeploymentConfigs().Lister()),
 			}
 
 			syncContext := addontesting.NewFakeSyncContext(t)
@@ -11,10 +11,11 @@ import (
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 )
 
 type defaultSyncer struct {
-	buildWorks func(installMode, workNamespace string, cluster *clusterv1.ManagedCluster, existingWorks []*workapiv1.ManifestWork,
+	buildWorks func(ctx context.Context, installMode, workNamespace string, cluster *clusterv1.ManagedCluster, existingWorks []*workapiv1.ManifestWork,
 		addon *addonapiv1alpha1.ManagedClusterAddOn) (appliedWorks, deleteWorks []*workapiv1.ManifestWork, err error)
 
 	applyWork func(ctx context.Context, appliedType string,
@@ -25,6 +26,11 @@ type defaultSyncer struct {
 	deleteWork func(ctx context.Context, workNamespace, workName string) error
 
 	agentAddon agent.AgentAddon
+
+	installThrottle *installThrottle
+
+	// pruneDryRun only logs the stale works instead of deleting them
+	pruneDryRun bool
 }
 
 func (s *defaultSyncer) sync(ctx context.Context,
@@ -35,8 +41,22 @@ func (s *defaultSyncer) sync(ctx context.Context,
 
 	var errs []error
 
+	// the deploy works of the deleting addon are removed first, the pre-delete hook runs and the registration
+	// of the addon is removed from the hub only after they are gone.
 	if !addon.DeletionTimestamp.IsZero() {
-		return addon, nil
+		currentWorks, err := s.getWorkByAddon(addon.Name, addon.Namespace)
+		if err != nil {
+			return addon, err
+		}
+		for _, work := range currentWorks {
+			if !work.DeletionTimestamp.IsZero() {
+				continue
+			}
+			if err = s.deleteWork(ctx, deployWorkNamespace, work.Name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return addon, utilerrors.NewAggregate(errs)
 	}
 
 	// waiting for the addon to be deleted when cluster is deleting.
@@ -50,18 +70,28 @@ func (s *defaultSyncer) sync(ctx context.Context,
 		return addon, err
 	}
 
-	deployWorks, deleteWorks, err := s.buildWorks(constants.InstallModeDefault, deployWorkNamespace, cluster, currentWorks, addon)
+	// remove the works of the disabled addon, the registration of the addon is preserved.
+	if utils.IsAddonDisabled(addon) {
+		for _, work := range currentWorks {
+			if err = s.deleteWork(ctx, deployWorkNamespace, work.Name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		setAddonDisabledCondition(addon, addonapiv1alpha1.ManagedClusterAddOnManifestApplied)
+		return addon, utilerrors.NewAggregate(errs)
+	}
+
+	deployWorks, deleteWorks, err := s.buildWorks(ctx, constants.InstallModeDefault, deployWorkNamespace, cluster, currentWorks, addon)
 	if err != nil {
 		return addon, err
 	}
 
-	for _, deleteWork := range deleteWorks {
-		err = s.deleteWork(ctx, deployWorkNamespace, deleteWork.Name)
-		if err != nil {
-			errs = append(errs, err)
-		}
+	if throttleInitialInstall(s.installThrottle, syncCtx, addonapiv1alpha1.ManagedClusterAddOnManifestApplied, addon, currentWorks, deployWorks) {
+		return addon, nil
 	}
 
+	errs = append(errs, pruneStaleWorks(ctx, s.deleteWork, addon, deleteWorks, s.pruneDryRun)...)
+
 	for _, deployWork := range deployWorks {
 		_, err = s.applyWork(ctx, addonapiv1alpha1.ManagedClusterAddOnManifestApplied, deployWork, addon)
 		if err != nil {
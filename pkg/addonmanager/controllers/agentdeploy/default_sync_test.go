@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -25,6 +26,7 @@ import (
 	workapiv1 "open-cluster-management.io/api/work/v1"
 
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 )
 
@@ -32,6 +34,10 @@ type testAgent struct {
 	name    string
 	objects []runtime.Object
 	err     error
+	// installNamespace is returned by the AgentInstallNamespace of the agent if it is set.
+	installNamespace string
+	// installNamespaceFunc is the AgentInstallNamespace of the agent if it is set.
+	installNamespaceFunc func(addon *addonapiv1alpha1.ManagedClusterAddOn) (string, error)
 }
 
 func (t *testAgent) Manifests(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) ([]runtime.Object, error) {
@@ -39,9 +45,18 @@ func (t *testAgent) Manifests(cluster *clusterv1.ManagedCluster, addon *addonapi
 }
 
 func (t *testAgent) GetAgentAddonOptions() agent.AgentAddonOptions {
-	return agent.AgentAddonOptions{
+	options := agent.AgentAddonOptions{
 		AddonName: t.name,
 	}
+	if len(t.installNamespace) > 0 {
+		options.AgentInstallNamespace = func(addon *addonapiv1alpha1.ManagedClusterAddOn) (string, error) {
+			return t.installNamespace, nil
+		}
+	}
+	if t.installNamespaceFunc != nil {
+		options.AgentInstallNamespace = t.installNamespaceFunc
+	}
+	return options
 }
 
 func TestDefaultReconcile(t *testing.T) {
@@ -50,6 +65,7 @@ func TestDefaultReconcile(t *testing.T) {
 		key                  string
 		existingWork         []runtime.Object
 		addon                []runtime.Object
+		cma                  []runtime.Object
 		testaddon            *testAgent
 		cluster              []runtime.Object
 		validateAddonActions func(t *testing.T, actions []clienttesting.Action)
@@ -106,6 +122,106 @@ func TestDefaultReconcile(t *testing.T) {
 				addontesting.AssertActions(t, actions, "create")
 			},
 		},
+		{
+			name: "deploy manifests with the registration namespace of the agent install namespace",
+			key:  "cluster1/test",
+			addon: []runtime.Object{func() *addonapiv1alpha1.ManagedClusterAddOn {
+				addon := addontesting.NewAddon("test", "cluster1")
+				addon.Status.Namespace = "open-cluster-management-agent-addon"
+				return addon
+			}()},
+			cluster: []runtime.Object{addontesting.NewManagedCluster("cluster1")},
+			testaddon: &testAgent{name: "test", installNamespace: "agent-ns", objects: []runtime.Object{
+				addontesting.NewUnstructured("v1", "ConfigMap", "agent-ns", "test"),
+			}},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+			},
+			validateWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "create")
+				work := actions[0].(clienttesting.CreateActionImpl).Object.(*workapiv1.ManifestWork)
+				ns := &corev1.Namespace{}
+				if err := json.Unmarshal(work.Spec.Workload.Manifests[0].Raw, ns); err != nil {
+					t.Fatal(err)
+				}
+				if ns.Kind != "Namespace" || ns.Name != "agent-ns" {
+					t.Errorf("expected the registration namespace agent-ns, but got %s %s", ns.Kind, ns.Name)
+				}
+			},
+		},
+		{
+			name: "skip rendering the addon with unsupported configs",
+			key:  "cluster1/test",
+			addon: []runtime.Object{func() *addonapiv1alpha1.ManagedClusterAddOn {
+				addon := addontesting.NewAddon("test", "cluster1")
+				meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+					Type:   addonapiv1alpha1.ManagedClusterAddOnUnsupportedConfigurationType,
+					Status: metav1.ConditionTrue,
+					Reason: addonapiv1alpha1.AddonReasonConfigurationUnsupported,
+				})
+				return addon
+			}()},
+			cluster: []runtime.Object{addontesting.NewManagedCluster("cluster1")},
+			testaddon: &testAgent{name: "test", objects: []runtime.Object{
+				addontesting.NewUnstructured("v1", "ConfigMap", "default", "test"),
+			}},
+			validateAddonActions: addontesting.AssertNoActions,
+			validateWorkActions:  addontesting.AssertNoActions,
+		},
+		{
+			name:    "set values invalid condition of an addon with invalid values",
+			key:     "cluster1/test",
+			addon:   []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			cluster: []runtime.Object{addontesting.NewManagedCluster("cluster1")},
+			testaddon: &testAgent{name: "test",
+				err: &agent.ValuesInvalidError{Err: fmt.Errorf("image: Invalid type. Expected: string, given: integer")}},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				patch := actions[0].(clienttesting.PatchActionImpl).Patch
+				addOn := &addonapiv1alpha1.ManagedClusterAddOn{}
+				err := json.Unmarshal(patch, addOn)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cond := meta.FindStatusCondition(addOn.Status.Conditions, constants.AddonValuesInvalid)
+				if cond == nil || cond.Status != metav1.ConditionTrue ||
+					cond.Reason != constants.ValuesInvalidReasonSchemaValidationFailed {
+					t.Errorf("expected values invalid condition, but got %v", addOn.Status.Conditions)
+				}
+			},
+			validateWorkActions: addontesting.AssertNoActions,
+		},
+		{
+			name: "skip updating the manifests of a paused addon",
+			key:  "cluster1/test",
+			addon: []runtime.Object{func() *addonapiv1alpha1.ManagedClusterAddOn {
+				addon := addontesting.NewAddon("test", "cluster1")
+				addon.Annotations = map[string]string{constants.AddonPausedAnnotationKey: "true"}
+				return addon
+			}()},
+			cluster: []runtime.Object{addontesting.NewManagedCluster("cluster1")},
+			testaddon: &testAgent{name: "test", objects: []runtime.Object{
+				addontesting.NewUnstructured("v1", "ConfigMap", "default", "test"),
+			}},
+			validateAddonActions: addontesting.AssertNoActions,
+			validateWorkActions:  addontesting.AssertNoActions,
+		},
+		{
+			name:    "skip updating the manifests of an addon paused by the clustermanagementaddon",
+			key:     "cluster1/test",
+			addon:   []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			cluster: []runtime.Object{addontesting.NewManagedCluster("cluster1")},
+			cma: []runtime.Object{func() *addonapiv1alpha1.ClusterManagementAddOn {
+				cma := addontesting.NewClusterManagementAddon("test", "", "").Build()
+				cma.Annotations = map[string]string{constants.AddonPausedAnnotationKey: "true"}
+				return cma
+			}()},
+			testaddon: &testAgent{name: "test", objects: []runtime.Object{
+				addontesting.NewUnstructured("v1", "ConfigMap", "default", "test"),
+			}},
+			validateAddonActions: addontesting.AssertNoActions,
+			validateWorkActions:  addontesting.AssertNoActions,
+		},
 		{
 			name:    "update manifest for an addon",
 			key:     "cluster1/test",
@@ -149,6 +265,113 @@ func TestDefaultReconcile(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "remove manifests of a disabled addon",
+			key:  "cluster1/test",
+			addon: []runtime.Object{func() *addonapiv1alpha1.ManagedClusterAddOn {
+				addon := addontesting.NewAddon("test", "cluster1")
+				addon.Annotations = map[string]string{constants.AddonDisabledAnnotationKey: "true"}
+				return addon
+			}()},
+			cluster: []runtime.Object{addontesting.NewManagedCluster("cluster1")},
+			testaddon: &testAgent{name: "test", objects: []runtime.Object{
+				addontesting.NewUnstructured("v1", "ConfigMap", "default", "test"),
+			}},
+			existingWork: []runtime.Object{func() *workapiv1.ManifestWork {
+				work := addontesting.NewManifestWork(
+					"addon-test-deploy",
+					"cluster1",
+					addontesting.NewUnstructured("v1", "ConfigMap", "default", "test"),
+				)
+				work.SetLabels(map[string]string{
+					addonapiv1alpha1.AddonLabelKey: "test",
+				})
+				return work
+			}()},
+			validateWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "delete")
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				patch := actions[0].(clienttesting.PatchActionImpl).Patch
+				addOn := &addonapiv1alpha1.ManagedClusterAddOn{}
+				err := json.Unmarshal(patch, addOn)
+				if err != nil {
+					t.Fatal(err)
+				}
+				addOnCond := meta.FindStatusCondition(addOn.Status.Conditions, addonapiv1alpha1.ManagedClusterAddOnManifestApplied)
+				if addOnCond == nil || addOnCond.Reason != constants.ManifestAppliedReasonAddonDisabled {
+					t.Errorf("Condition is not correct: %v", addOnCond)
+				}
+			},
+		},
+		{
+			name:      "keep the works of an addon without manifests",
+			key:       "cluster1/test",
+			addon:     []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			cluster:   []runtime.Object{addontesting.NewManagedCluster("cluster1")},
+			testaddon: &testAgent{name: "test"},
+			existingWork: []runtime.Object{func() *workapiv1.ManifestWork {
+				work := addontesting.NewManifestWork(
+					"addon-test-deploy-0",
+					"cluster1",
+					addontesting.NewUnstructured("v1", "ConfigMap", "default", "test"),
+				)
+				work.SetLabels(map[string]string{
+					addonapiv1alpha1.AddonLabelKey: "test",
+				})
+				return work
+			}()},
+			validateWorkActions:  addontesting.AssertNoActions,
+			validateAddonActions: addontesting.AssertNoActions,
+		},
+		{
+			name:    "delete the stale work of a dropped shard",
+			key:     "cluster1/test",
+			addon:   []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			cluster: []runtime.Object{addontesting.NewManagedCluster("cluster1")},
+			testaddon: &testAgent{name: "test", objects: []runtime.Object{
+				addontesting.NewUnstructured("v1", "ConfigMap", "default", "test"),
+			}},
+			existingWork: []runtime.Object{
+				func() *workapiv1.ManifestWork {
+					work := addontesting.NewManifestWork(
+						"addon-test-deploy-0",
+						"cluster1",
+						addontesting.NewUnstructured("v1", "ConfigMap", "default", "test"),
+					)
+					work.SetLabels(map[string]string{
+						addonapiv1alpha1.AddonLabelKey: "test",
+					})
+					return work
+				}(),
+				func() *workapiv1.ManifestWork {
+					work := addontesting.NewManifestWork(
+						"addon-test-deploy-1",
+						"cluster1",
+						addontesting.NewUnstructured("v1", "Secret", "default", "test"),
+					)
+					work.SetLabels(map[string]string{
+						addonapiv1alpha1.AddonLabelKey: "test",
+					})
+					return work
+				}(),
+			},
+			validateWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				var deleted []string
+				for _, action := range actions {
+					if deleteAction, ok := action.(clienttesting.DeleteActionImpl); ok {
+						deleted = append(deleted, deleteAction.Name)
+					}
+				}
+				if len(deleted) != 1 || deleted[0] != "addon-test-deploy-1" {
+					t.Errorf("expected the stale work addon-test-deploy-1 deleted, but got %v", deleted)
+				}
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+			},
+		},
 		{
 			name:    "do not update manifest for an addon",
 			key:     "cluster1/test",
@@ -260,6 +483,11 @@ func TestDefaultReconcile(t *testing.T) {
 					t.Fatal(err)
 				}
 			}
+			for _, obj := range c.cma {
+				if err := addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
 			for _, obj := range c.addon {
 				if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(obj); err != nil {
 					t.Fatal(err)
@@ -272,13 +500,14 @@ func TestDefaultReconcile(t *testing.T) {
 			}
 
 			controller := addonDeployController{
-				workApplier:               workapplier.NewWorkApplierWithTypedClient(fakeWorkClient, workInformerFactory.Work().V1().ManifestWorks().Lister()),
-				workBuilder:               workbuilder.NewWorkBuilder(),
-				addonClient:               fakeAddonClient,
-				managedClusterLister:      clusterInformers.Cluster().V1().ManagedClusters().Lister(),
-				managedClusterAddonLister: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
-				workIndexer:               workInformerFactory.Work().V1().ManifestWorks().Informer().GetIndexer(),
-				agentAddons:               map[string]agent.AgentAddon{c.testaddon.name: c.testaddon},
+				workApplier:                  workapplier.NewWorkApplierWithTypedClient(fakeWorkClient, workInformerFactory.Work().V1().ManifestWorks().Lister()),
+				workBuilder:                  workbuilder.NewWorkBuilder(),
+				addonClient:                  fakeAddonClient,
+				managedClusterLister:         clusterInformers.Cluster().V1().ManagedClusters().Lister(),
+				managedClusterAddonLister:    addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				clusterManagementAddonLister: addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Lister(),
+				workIndexer:                  workInformerFactory.Work().V1().ManifestWorks().Informer().GetIndexer(),
+				agentAddons:                  map[string]agent.AgentAddon{c.testaddon.name: c.testaddon},
 			}
 
 			syncContext := addontesting.NewFakeSyncContext(t)
@@ -294,3 +523,129 @@ func TestDefaultReconcile(t *testing.T) {
 		})
 	}
 }
+
+type testWorksAgent struct {
+	testAgent
+	works []*workapiv1.ManifestWork
+}
+
+func (t *testWorksAgent) BuildWorks(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) ([]*workapiv1.ManifestWork, error) {
+	return t.works, t.err
+}
+
+func TestDefaultReconcileWithWorksBuilderOverride(t *testing.T) {
+	cases := []struct {
+		name                string
+		existingWork        []runtime.Object
+		testaddon           *testWorksAgent
+		expectErr           bool
+		validateWorkActions func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name: "create works",
+			testaddon: &testWorksAgent{
+				testAgent: testAgent{name: "test"},
+				works: []*workapiv1.ManifestWork{
+					func() *workapiv1.ManifestWork {
+						work := addontesting.NewManifestWork("work1", "",
+							addontesting.NewUnstructured("v1", "ConfigMap", "default", "test"))
+						work.Spec.Executor = &workapiv1.ManifestWorkExecutor{
+							Subject: workapiv1.ManifestWorkExecutorSubject{Type: workapiv1.ExecutorSubjectTypeServiceAccount},
+						}
+						return work
+					}(),
+				},
+			},
+			validateWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "create")
+				work := actions[0].(clienttesting.CreateActionImpl).Object.(*workapiv1.ManifestWork)
+				if work.Namespace != "cluster1" || work.Name != "work1" {
+					t.Errorf("unexpected work %s/%s", work.Namespace, work.Name)
+				}
+				if work.Labels[addonapiv1alpha1.AddonLabelKey] != "test" {
+					t.Errorf("expected addon label, but got %v", work.Labels)
+				}
+				if len(work.OwnerReferences) != 1 || work.OwnerReferences[0].Name != "test" {
+					t.Errorf("expected addon owner, but got %v", work.OwnerReferences)
+				}
+				if work.Spec.Executor == nil {
+					t.Errorf("expected executor is kept")
+				}
+			},
+		},
+		{
+			name: "delete stale works",
+			testaddon: &testWorksAgent{
+				testAgent: testAgent{name: "test"},
+			},
+			existingWork: []runtime.Object{func() *workapiv1.ManifestWork {
+				work := addontesting.NewManifestWork("work1", "cluster1",
+					addontesting.NewUnstructured("v1", "ConfigMap", "default", "test"))
+				work.SetLabels(map[string]string{
+					addonapiv1alpha1.AddonLabelKey: "test",
+				})
+				return work
+			}()},
+			validateWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "delete")
+			},
+		},
+		{
+			name: "duplicated work names",
+			testaddon: &testWorksAgent{
+				testAgent: testAgent{name: "test"},
+				works: []*workapiv1.ManifestWork{
+					addontesting.NewManifestWork("work1", ""),
+					addontesting.NewManifestWork("work1", ""),
+				},
+			},
+			expectErr:           true,
+			validateWorkActions: addontesting.AssertNoActions,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addon := addontesting.NewAddon("test", "cluster1")
+			cluster := addontesting.NewManagedCluster("cluster1")
+			fakeWorkClient := fakework.NewSimpleClientset(c.existingWork...)
+			fakeAddonClient := fakeaddon.NewSimpleClientset(addon)
+
+			workInformerFactory := workinformers.NewSharedInformerFactory(fakeWorkClient, 10*time.Minute)
+			err := workInformerFactory.Work().V1().ManifestWorks().Informer().AddIndexers(
+				cache.Indexers{
+					byAddon: indexByAddon,
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, obj := range c.existingWork {
+				if err := workInformerFactory.Work().V1().ManifestWorks().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			controller := addonDeployController{
+				workApplier: workapplier.NewWorkApplierWithTypedClient(fakeWorkClient, workInformerFactory.Work().V1().ManifestWorks().Lister()),
+				workBuilder: workbuilder.NewWorkBuilder(),
+				addonClient: fakeAddonClient,
+				workIndexer: workInformerFactory.Work().V1().ManifestWorks().Informer().GetIndexer(),
+				agentAddons: map[string]agent.AgentAddon{c.testaddon.name: c.testaddon},
+			}
+
+			syncer := &defaultSyncer{
+				buildWorks:     controller.buildDeployManifestWorks,
+				applyWork:      controller.applyWork,
+				getWorkByAddon: controller.getWorksByAddonFn(byAddon),
+				deleteWork:     controller.workApplier.Delete,
+				agentAddon:     c.testaddon,
+			}
+			_, err = syncer.sync(context.TODO(), addontesting.NewFakeSyncContext(t), cluster, addon)
+			if c.expectErr != (err != nil) {
+				t.Errorf("expected error %v, but got %v", c.expectErr, err)
+			}
+			c.validateWorkActions(t, fakeWorkClient.Actions())
+		})
+	}
+}
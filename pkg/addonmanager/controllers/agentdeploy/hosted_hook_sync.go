@@ -18,7 +18,7 @@ import (
 )
 
 type hostedHookSyncer struct {
-	buildWorks func(installMode, workNamespace string, cluster *clusterv1.ManagedCluster,
+	buildWorks func(ctx context.Context, installMode, workNamespace string, cluster *clusterv1.ManagedCluster,
 		addon *addonapiv1alpha1.ManagedClusterAddOn) (*workapiv1.ManifestWork, error)
 
 	applyWork func(ctx context.Context, appliedType string,
@@ -70,7 +70,7 @@ func (s *hostedHookSyncer) sync(ctx context.Context,
 		addonRemoveFinalizer(addon, addonapiv1alpha1.AddonHostingPreDeleteHookFinalizer)
 		return addon, nil
 	}
-	hookWork, err := s.buildWorks(constants.InstallModeHosted, hostingClusterName, cluster, addon)
+	hookWork, err := s.buildWorks(ctx, constants.InstallModeHosted, hostingClusterName, cluster, addon)
 	if err != nil {
 		return addon, err
 	}
@@ -101,7 +101,7 @@ func (s *hostedHookSyncer) sync(ctx context.Context,
 		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
 			Type:    addonapiv1alpha1.ManagedClusterAddOnHookManifestCompleted,
 			Status:  metav1.ConditionTrue,
-			Reason:  "HookManifestIsCompleted",
+			Reason:  constants.HookManifestReasonCompleted,
 			Message: fmt.Sprintf("hook manifestWork %v is completed.", hookWork.Name),
 		})
 
@@ -117,7 +117,7 @@ func (s *hostedHookSyncer) sync(ctx context.Context,
 	meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
 		Type:    addonapiv1alpha1.ManagedClusterAddOnHookManifestCompleted,
 		Status:  metav1.ConditionFalse,
-		Reason:  "HookManifestIsNotCompleted",
+		Reason:  constants.HookManifestReasonNotCompleted,
 		Message: fmt.Sprintf("hook manifestWork %v is not completed.", hookWork.Name),
 	})
 
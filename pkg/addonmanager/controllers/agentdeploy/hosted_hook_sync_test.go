@@ -356,13 +356,14 @@ func TestHostingHookReconcile(t *testing.T) {
 			}
 
 			controller := addonDeployController{
-				workApplier:               workapplier.NewWorkApplierWithTypedClient(fakeWorkClient, workInformerFactory.Work().V1().ManifestWorks().Lister()),
-				workBuilder:               workbuilder.NewWorkBuilder(),
-				addonClient:               fakeAddonClient,
-				managedClusterLister:      clusterInformers.Cluster().V1().ManagedClusters().Lister(),
-				managedClusterAddonLister: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
-				workIndexer:               workInformerFactory.Work().V1().ManifestWorks().Informer().GetIndexer(),
-				agentAddons:               map[string]agent.AgentAddon{c.testaddon.name: c.testaddon},
+				workApplier:                  workapplier.NewWorkApplierWithTypedClient(fakeWorkClient, workInformerFactory.Work().V1().ManifestWorks().Lister()),
+				workBuilder:                  workbuilder.NewWorkBuilder(),
+				addonClient:                  fakeAddonClient,
+				managedClusterLister:         clusterInformers.Cluster().V1().ManagedClusters().Lister(),
+				managedClusterAddonLister:    addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				clusterManagementAddonLister: addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Lister(),
+				workIndexer:                  workInformerFactory.Work().V1().ManifestWorks().Informer().GetIndexer(),
+				agentAddons:                  map[string]agent.AgentAddon{c.testaddon.name: c.testaddon},
 			}
 
 			syncContext := addontesting.NewFakeSyncContext(t)
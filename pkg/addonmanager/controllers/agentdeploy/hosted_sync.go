@@ -15,10 +15,11 @@ import (
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 )
 
 type hostedSyncer struct {
-	buildWorks func(installMode, workNamespace string, cluster *clusterv1.ManagedCluster, existingWorks []*workapiv1.ManifestWork,
+	buildWorks func(ctx context.Context, installMode, workNamespace string, cluster *clusterv1.ManagedCluster, existingWorks []*workapiv1.ManifestWork,
 		addon *addonapiv1alpha1.ManagedClusterAddOn) (appliedWorks, deleteWorks []*workapiv1.ManifestWork, err error)
 
 	applyWork func(ctx context.Context, appliedType string,
@@ -31,6 +32,11 @@ type hostedSyncer struct {
 	getCluster func(clusterName string) (*clusterv1.ManagedCluster, error)
 
 	agentAddon agent.AgentAddon
+
+	installThrottle *installThrottle
+
+	// pruneDryRun only logs the stale works instead of deleting them
+	pruneDryRun bool
 }
 
 func (s *hostedSyncer) sync(ctx context.Context,
@@ -111,24 +117,31 @@ func (s *hostedSyncer) sync(ctx context.Context,
 		return addon, nil
 	}
 
+	// remove the works of the disabled addon, the registration of the addon is preserved.
+	if utils.IsAddonDisabled(addon) {
+		if err = s.cleanupDeployWork(ctx, addon); err != nil {
+			return addon, err
+		}
+		setAddonDisabledCondition(addon, addonapiv1alpha1.ManagedClusterAddOnHostingManifestApplied)
+		return addon, nil
+	}
+
 	currentWorks, err := s.getWorkByAddon(addon.Name, addon.Namespace)
 	if err != nil {
 		return addon, err
 	}
 
-	deployWorks, deleteWorks, err := s.buildWorks(constants.InstallModeHosted, hostingClusterName, cluster, currentWorks, addon)
+	deployWorks, deleteWorks, err := s.buildWorks(ctx, constants.InstallModeHosted, hostingClusterName, cluster, currentWorks, addon)
 	if err != nil {
 		return addon, err
 	}
 
-	var errs []error
-	for _, deleteWork := range deleteWorks {
-		err = s.deleteWork(ctx, deleteWork.Namespace, deleteWork.Name)
-		if err != nil {
-			errs = append(errs, err)
-		}
+	if throttleInitialInstall(s.installThrottle, syncCtx, addonapiv1alpha1.ManagedClusterAddOnHostingManifestApplied, addon, currentWorks, deployWorks) {
+		return addon, nil
 	}
 
+	errs := pruneStaleWorks(ctx, s.deleteWork, addon, deleteWorks, s.pruneDryRun)
+
 	for _, deployWork := range deployWorks {
 		_, err = s.applyWork(ctx, addonapiv1alpha1.ManagedClusterAddOnHostingManifestApplied, deployWork, addon)
 		if err != nil {
@@ -0,0 +1,125 @@
+package agentdeploy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+)
+
+// installThrottleQueueLength is the number of the addons waiting for their first-time manifestworks.
+var installThrottleQueueLength = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "addon_manager_initial_install_throttle_queue_length",
+	Help: "The number of the addons whose first manifestworks are held by the initial install throttle.",
+})
+
+func init() {
+	legacyregistry.RawMustRegister(installThrottleQueueLength)
+}
+
+// installThrottle is a token bucket rate limiter of the first-time installs of all the addons of a controller,
+// the burst is the installs per minute so a minute of installs can be created at once. A non-positive
+// installsPerMinute disables the limit. The waiting addons of all the throttles are added up in the
+// addon_manager_initial_install_throttle_queue_length metric.
+type installThrottle struct {
+	lock    sync.Mutex
+	limiter *rate.Limiter
+	// waiting is the keys of the addons throttled and not installed yet
+	waiting sets.Set[string]
+}
+
+func newInstallThrottle(installsPerMinute int) *installThrottle {
+	t := &installThrottle{waiting: sets.New[string]()}
+	t.setLimit(installsPerMinute)
+	return t
+}
+
+func (t *installThrottle) setLimit(installsPerMinute int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if installsPerMinute <= 0 {
+		t.limiter = nil
+		return
+	}
+	t.limiter = rate.NewLimiter(rate.Limit(float64(installsPerMinute)/60), installsPerMinute)
+}
+
+// allow returns true if the addon is allowed to be installed now, otherwise it returns the delay to retry.
+func (t *installThrottle) allow(key string) (bool, time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.limiter == nil {
+		t.stopWaiting(key)
+		return true, 0
+	}
+
+	reservation := t.limiter.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		t.stopWaiting(key)
+		return true, 0
+	}
+
+	// do not consume the token in the future, the addon competes again after the delay
+	reservation.Cancel()
+	if !t.waiting.Has(key) {
+		t.waiting.Insert(key)
+		installThrottleQueueLength.Inc()
+	}
+	return false, delay
+}
+
+// stopWaiting removes the addon from the waiting addons, it must be called with the lock held.
+func (t *installThrottle) stopWaiting(key string) {
+	if t.waiting.Has(key) {
+		t.waiting.Delete(key)
+		installThrottleQueueLength.Dec()
+	}
+}
+
+// forget removes the addon from the waiting addons, e.g. when the addon is deleted.
+func (t *installThrottle) forget(key string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.stopWaiting(key)
+}
+
+// throttleInitialInstall returns true if the first-time manifestworks of the addon are throttled. The addon is
+// requeued after the throttle delay and the applied condition is set to InstallThrottled.
+func throttleInitialInstall(throttle *installThrottle, syncCtx factory.SyncContext, appliedType string,
+	addon *addonapiv1alpha1.ManagedClusterAddOn, currentWorks, deployWorks []*workapiv1.ManifestWork) bool {
+	if throttle == nil || len(currentWorks) != 0 || len(deployWorks) == 0 {
+		return false
+	}
+
+	key := fmt.Sprintf("%s/%s", addon.Namespace, addon.Name)
+	allowed, delay := throttle.allow(key)
+	if allowed {
+		return false
+	}
+
+	klog.V(4).Infof("The initial install of addon %s is throttled, retry after %v", key, delay)
+	meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+		Type:    appliedType,
+		Status:  metav1.ConditionFalse,
+		Reason:  constants.ManifestAppliedReasonInstallThrottled,
+		Message: "the initial install of the addon is throttled by the hub, the manifests will be applied later",
+	})
+	syncCtx.Queue().AddAfter(key, delay)
+	return true
+}
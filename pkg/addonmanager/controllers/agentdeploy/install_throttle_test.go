@@ -0,0 +1,104 @@
+package agentdeploy
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func TestInstallThrottle(t *testing.T) {
+	unlimited := newInstallThrottle(0)
+	for i := 0; i < 100; i++ {
+		if allowed, _ := unlimited.allow("cluster1/test"); !allowed {
+			t.Fatalf("expected the unlimited throttle allows all the installs")
+		}
+	}
+
+	waiting := testutil.ToFloat64(installThrottleQueueLength)
+	throttle := newInstallThrottle(2)
+	for _, key := range []string{"cluster1/test", "cluster2/test"} {
+		if allowed, _ := throttle.allow(key); !allowed {
+			t.Errorf("expected %s is allowed in the burst", key)
+		}
+	}
+	allowed, delay := throttle.allow("cluster3/test")
+	if allowed {
+		t.Errorf("expected cluster3/test is throttled")
+	}
+	if delay <= 0 {
+		t.Errorf("expected a positive retry delay, but got %v", delay)
+	}
+	if length := testutil.ToFloat64(installThrottleQueueLength) - waiting; length != 1 {
+		t.Errorf("expected 1 addon waiting, but got %v", length)
+	}
+
+	// retrying does not consume the tokens
+	if allowed, _ := throttle.allow("cluster3/test"); allowed {
+		t.Errorf("expected cluster3/test is still throttled")
+	}
+	if length := testutil.ToFloat64(installThrottleQueueLength) - waiting; length != 1 {
+		t.Errorf("expected 1 addon waiting, but got %v", length)
+	}
+
+	throttle.forget("cluster3/test")
+	if length := testutil.ToFloat64(installThrottleQueueLength) - waiting; length != 0 {
+		t.Errorf("expected no addon waiting, but got %v", length)
+	}
+
+	// the limit is removed
+	throttle.setLimit(0)
+	if allowed, _ := throttle.allow("cluster3/test"); !allowed {
+		t.Errorf("expected cluster3/test is allowed after the limit is removed")
+	}
+}
+
+func TestThrottleInitialInstall(t *testing.T) {
+	work := addontesting.NewManifestWork("addon-test-deploy-0", "cluster1")
+
+	cases := []struct {
+		name            string
+		currentWorks    []*workapiv1.ManifestWork
+		deployWorks     []*workapiv1.ManifestWork
+		expectThrottled bool
+	}{
+		{
+			name:         "update existing works",
+			deployWorks:  []*workapiv1.ManifestWork{work},
+			currentWorks: []*workapiv1.ManifestWork{work},
+		},
+		{
+			name: "no works to deploy",
+		},
+		{
+			name:            "initial install",
+			deployWorks:     []*workapiv1.ManifestWork{work},
+			expectThrottled: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			throttle := newInstallThrottle(1)
+			// consume the burst
+			throttle.allow("cluster2/test")
+
+			addon := addontesting.NewAddon("test", "cluster1")
+			throttled := throttleInitialInstall(throttle, addontesting.NewFakeSyncContext(t),
+				addonapiv1alpha1.ManagedClusterAddOnManifestApplied, addon, c.currentWorks, c.deployWorks)
+			if throttled != c.expectThrottled {
+				t.Errorf("expected throttled %v, but got %v", c.expectThrottled, throttled)
+			}
+
+			cond := meta.FindStatusCondition(addon.Status.Conditions, addonapiv1alpha1.ManagedClusterAddOnManifestApplied)
+			if c.expectThrottled != (cond != nil && cond.Reason == constants.ManifestAppliedReasonInstallThrottled) {
+				t.Errorf("unexpected condition %v", cond)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package agentdeploy
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/metrics"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+// detectManifestDrift stamps the required work with the content digest of its manifests, and returns whether
+// the existing work is drifted from the required work and the modifications are kept. The existing work is
+// drifted if it is stamped with the same digest but its manifests are modified since then, a work stamped with
+// another digest is being updated with newly rendered manifests. The names of the kept drifted works are added
+// to drifted. The restores of the drifted works are recorded as the events of the addon by the recorder.
+func detectManifestDrift(recorder utils.AddonEventRecorder, required, existing *workapiv1.ManifestWork, addon *addonapiv1alpha1.ManagedClusterAddOn,
+	detection *agent.ManifestDriftDetection, drifted sets.Set[string]) (bool, error) {
+	digest, err := utils.ManifestWorkDigest(required)
+	if err != nil {
+		return false, err
+	}
+	if required.Annotations == nil {
+		required.Annotations = map[string]string{}
+	}
+	required.Annotations[constants.ManifestDigestAnnotationKey] = digest
+
+	if existing == nil || existing.Annotations[constants.ManifestDigestAnnotationKey] != digest {
+		return false, nil
+	}
+	isDrifted, err := utils.ManifestWorkDrifted(existing)
+	if err != nil || !isDrifted {
+		return false, err
+	}
+
+	if detection.AutoRestore {
+		klog.Infof("The manifestwork %s/%s of addon %s is modified out-of-band, restoring it",
+			existing.Namespace, existing.Name, addon.Name)
+		metrics.RecordManifestWorkDrift(addon.Name)
+		utils.RecordAddonEvent(recorder, addon, corev1.EventTypeWarning, constants.EventReasonManifestWorkDrifted,
+			"the ManifestWork %s/%s is modified out-of-band and restored", existing.Namespace, existing.Name)
+		return false, nil
+	}
+	drifted.Insert(existing.Name)
+	return true, nil
+}
+
+// setManifestDriftedCondition sets the ManifestDrifted condition of the addon by the drifted works kept in the
+// sync. The condition is only set when there are drifted works or it was set before. It returns whether the
+// addon is newly drifted.
+func setManifestDriftedCondition(addon *addonapiv1alpha1.ManagedClusterAddOn, drifted sets.Set[string]) bool {
+	if drifted.Len() == 0 {
+		if meta.FindStatusCondition(addon.Status.Conditions, constants.AddonManifestDrifted) == nil {
+			return false
+		}
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:    constants.AddonManifestDrifted,
+			Status:  metav1.ConditionFalse,
+			Reason:  constants.ManifestDriftedReasonNoDrift,
+			Message: "the manifestworks of the addon match the rendered manifests",
+		})
+		return false
+	}
+
+	newlyDrifted := !meta.IsStatusConditionTrue(addon.Status.Conditions, constants.AddonManifestDrifted)
+	meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+		Type:   constants.AddonManifestDrifted,
+		Status: metav1.ConditionTrue,
+		Reason: constants.ManifestDriftedReasonDrifted,
+		Message: fmt.Sprintf("the manifestworks are modified out-of-band and differ from the rendered manifests: %s",
+			strings.Join(sets.List(drifted), ", ")),
+	})
+	return newlyDrifted
+}
@@ -0,0 +1,160 @@
+package agentdeploy
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+func TestDetectManifestDrift(t *testing.T) {
+	newWork := func(configMapName, digestOf string) *workapiv1.ManifestWork {
+		work := addontesting.NewManifestWork("addon-test-deploy-0", "cluster1",
+			addontesting.NewUnstructured("v1", "ConfigMap", "default", configMapName))
+		if len(digestOf) > 0 {
+			digest, err := utils.ManifestWorkDigest(addontesting.NewManifestWork("addon-test-deploy-0", "cluster1",
+				addontesting.NewUnstructured("v1", "ConfigMap", "default", digestOf)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			work.Annotations = map[string]string{constants.ManifestDigestAnnotationKey: digest}
+		}
+		return work
+	}
+
+	cases := []struct {
+		name            string
+		existing        *workapiv1.ManifestWork
+		autoRestore     bool
+		expectedKeep    bool
+		expectedDrifted []string
+	}{
+		{
+			name: "work is created",
+		},
+		{
+			name:     "work is not stamped",
+			existing: newWork("test", ""),
+		},
+		{
+			name:     "work is not drifted",
+			existing: newWork("test", "test"),
+		},
+		{
+			name:     "work is updated with newly rendered manifests",
+			existing: newWork("old", "old"),
+		},
+		{
+			name:     "work is updated but not stamped yet",
+			existing: newWork("test", "old"),
+		},
+		{
+			name:            "drifted work is kept",
+			existing:        newWork("modified", "test"),
+			expectedKeep:    true,
+			expectedDrifted: []string{"addon-test-deploy-0"},
+		},
+		{
+			name:        "drifted work is restored",
+			existing:    newWork("modified", "test"),
+			autoRestore: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			required := newWork("test", "")
+			drifted := sets.New[string]()
+			keep, err := detectManifestDrift(nil, required, c.existing, addontesting.NewAddon("test", "cluster1"),
+				&agent.ManifestDriftDetection{AutoRestore: c.autoRestore}, drifted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keep != c.expectedKeep {
+				t.Errorf("expected keep %v, but got %v", c.expectedKeep, keep)
+			}
+			if !drifted.Equal(sets.New(c.expectedDrifted...)) {
+				t.Errorf("expected drifted works %v, but got %v", c.expectedDrifted, sets.List(drifted))
+			}
+
+			digest, err := utils.ManifestWorkDigest(required)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if required.Annotations[constants.ManifestDigestAnnotationKey] != digest {
+				t.Errorf("expected the required work stamped with %s, but got %v", digest, required.Annotations)
+			}
+		})
+	}
+}
+
+func TestSetManifestDriftedCondition(t *testing.T) {
+	cases := []struct {
+		name                 string
+		existingCondition    *metav1.Condition
+		drifted              []string
+		expectedStatus       metav1.ConditionStatus
+		expectedNewlyDrifted bool
+	}{
+		{
+			name: "no drift",
+		},
+		{
+			name:                 "newly drifted",
+			drifted:              []string{"addon-test-deploy-0"},
+			expectedStatus:       metav1.ConditionTrue,
+			expectedNewlyDrifted: true,
+		},
+		{
+			name: "still drifted",
+			existingCondition: &metav1.Condition{
+				Type:   constants.AddonManifestDrifted,
+				Status: metav1.ConditionTrue,
+				Reason: constants.ManifestDriftedReasonDrifted,
+			},
+			drifted:        []string{"addon-test-deploy-0"},
+			expectedStatus: metav1.ConditionTrue,
+		},
+		{
+			name: "drift is resolved",
+			existingCondition: &metav1.Condition{
+				Type:   constants.AddonManifestDrifted,
+				Status: metav1.ConditionTrue,
+				Reason: constants.ManifestDriftedReasonDrifted,
+			},
+			expectedStatus: metav1.ConditionFalse,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addon := addontesting.NewAddon("test", "cluster1")
+			if c.existingCondition != nil {
+				meta.SetStatusCondition(&addon.Status.Conditions, *c.existingCondition)
+			}
+
+			newlyDrifted := setManifestDriftedCondition(addon, sets.New(c.drifted...))
+			if newlyDrifted != c.expectedNewlyDrifted {
+				t.Errorf("expected newly drifted %v, but got %v", c.expectedNewlyDrifted, newlyDrifted)
+			}
+
+			cond := meta.FindStatusCondition(addon.Status.Conditions, constants.AddonManifestDrifted)
+			if len(c.expectedStatus) == 0 {
+				if cond != nil {
+					t.Errorf("expected no condition, but got %v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != c.expectedStatus {
+				t.Errorf("expected condition status %s, but got %v", c.expectedStatus, cond)
+			}
+		})
+	}
+}
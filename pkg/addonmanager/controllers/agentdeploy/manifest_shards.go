@@ -0,0 +1,64 @@
+package agentdeploy
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"open-cluster-management.io/api/utils/work/v1/workbuilder"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+// manifestWorkSizeLimit is the size limit of the manifests in a deploy manifestWork.
+const manifestWorkSizeLimit = 500 * 1024
+
+// manifestShardSize is the max size of the manifests in a shard, it leaves the same buffer as the work builder.
+var manifestShardSize = int(float64(manifestWorkSizeLimit) * workbuilder.DefaultManifestThreshold)
+
+// manifestOrder returns the order of the object in the shards, the CRDs and namespaces are the first so they
+// are created in the first works and are available before the resources depending on them.
+func manifestOrder(obj runtime.Object) int {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	switch {
+	case gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition":
+		return 0
+	case gvk.Group == "" && gvk.Kind == "Namespace":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// shardManifests splits the objects into shards deterministically, the objects are ordered by manifestOrder and
+// the render order, and are filled into the shards in turn by their encoded size. An object bigger than the
+// shard size is put in a shard by itself.
+func shardManifests(objects []runtime.Object, shardSize int) ([][]runtime.Object, error) {
+	ordered := make([]runtime.Object, len(objects))
+	copy(ordered, objects)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return manifestOrder(ordered[i]) < manifestOrder(ordered[j])
+	})
+
+	var shards [][]runtime.Object
+	var shard []runtime.Object
+	size := 0
+	for _, obj := range ordered {
+		raw, err := runtime.Encode(unstructured.UnstructuredJSONScheme, obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode object %v, err: %v", obj, err)
+		}
+		manifestSize := (&workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: raw}}).Size()
+
+		if len(shard) > 0 && size+manifestSize >= shardSize {
+			shards = append(shards, shard)
+			shard, size = nil, 0
+		}
+		shard = append(shard, obj)
+		size += manifestSize
+	}
+	if len(shard) > 0 {
+		shards = append(shards, shard)
+	}
+	return shards, nil
+}
@@ -0,0 +1,117 @@
+package agentdeploy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"open-cluster-management.io/api/utils/work/v1/workbuilder"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+)
+
+func TestShardManifests(t *testing.T) {
+	newConfigMap := func(name string, size int) *unstructured.Unstructured {
+		obj := addontesting.NewUnstructured("v1", "ConfigMap", "addon-ns", name)
+		obj.Object["data"] = map[string]interface{}{"key": strings.Repeat("a", size)}
+		return obj
+	}
+	crd := addontesting.NewUnstructured("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "foos.test.io")
+	namespace := addontesting.NewUnstructured("v1", "Namespace", "", "addon-ns")
+
+	names := func(shards [][]runtime.Object) [][]string {
+		result := [][]string{}
+		for _, shard := range shards {
+			shardNames := []string{}
+			for _, obj := range shard {
+				shardNames = append(shardNames, obj.(*unstructured.Unstructured).GetName())
+			}
+			result = append(result, shardNames)
+		}
+		return result
+	}
+
+	cases := []struct {
+		name           string
+		objects        []runtime.Object
+		shardSize      int
+		expectedShards [][]string
+	}{
+		{
+			name:           "no objects",
+			shardSize:      1024,
+			expectedShards: [][]string{},
+		},
+		{
+			name:           "crds and namespaces first",
+			objects:        []runtime.Object{newConfigMap("cm1", 10), namespace, newConfigMap("cm2", 10), crd},
+			shardSize:      1024,
+			expectedShards: [][]string{{"foos.test.io", "addon-ns", "cm1", "cm2"}},
+		},
+		{
+			name:           "split into shards",
+			objects:        []runtime.Object{newConfigMap("cm1", 600), newConfigMap("cm2", 600), crd, newConfigMap("cm3", 100)},
+			shardSize:      1024,
+			expectedShards: [][]string{{"foos.test.io", "cm1"}, {"cm2", "cm3"}},
+		},
+		{
+			name:           "object bigger than the shard size",
+			objects:        []runtime.Object{newConfigMap("cm1", 100), newConfigMap("cm2", 2048), newConfigMap("cm3", 100)},
+			shardSize:      1024,
+			expectedShards: [][]string{{"cm1"}, {"cm2"}, {"cm3"}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			shards, err := shardManifests(c.objects, c.shardSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := names(shards); !reflect.DeepEqual(actual, c.expectedShards) {
+				t.Errorf("expected shards %v, but got %v", c.expectedShards, actual)
+			}
+		})
+	}
+}
+
+func TestBuildDeployWorksWithShards(t *testing.T) {
+	shardSize := manifestShardSize
+	manifestShardSize = 1024
+	defer func() { manifestShardSize = shardSize }()
+
+	newConfigMap := func(name string) *unstructured.Unstructured {
+		obj := addontesting.NewUnstructured("v1", "ConfigMap", "addon-ns", name)
+		obj.Object["data"] = map[string]interface{}{"key": strings.Repeat("a", 600)}
+		return obj
+	}
+	crd := addontesting.NewUnstructured("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "foos.test.io")
+
+	builder := newAddonWorksBuilder(false, workbuilder.NewWorkBuilder())
+	addon := addontesting.NewAddon("test", "cluster1")
+	deployWorks, deleteWorks, err := builder.BuildDeployWorks("cluster1", addon, nil,
+		[]runtime.Object{newConfigMap("cm1"), newConfigMap("cm2"), newConfigMap("cm3"), crd}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleteWorks) != 0 {
+		t.Errorf("expected no deleted works, but got %d", len(deleteWorks))
+	}
+
+	expectedWorks := []string{"addon-test-deploy-0", "addon-test-deploy-1", "addon-test-deploy-2"}
+	actualWorks := []string{}
+	for _, work := range deployWorks {
+		actualWorks = append(actualWorks, work.Name)
+	}
+	if !reflect.DeepEqual(actualWorks, expectedWorks) {
+		t.Fatalf("expected works %v, but got %v", expectedWorks, actualWorks)
+	}
+	for _, manifest := range deployWorks[0].Spec.Workload.Manifests {
+		if strings.Contains(string(manifest.Raw), "CustomResourceDefinition") {
+			return
+		}
+	}
+	t.Errorf("expected the crd in the first work")
+}
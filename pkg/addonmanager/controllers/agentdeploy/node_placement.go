@@ -0,0 +1,59 @@
+package agentdeploy
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+// injectNodePlacement injects the node placement of the AddOnDeploymentConfigs in the addon status into all the
+// workloads of the manifests, so the node placement takes effect even if the addon templates do not consume
+// it. The nodeSelector and tolerations are only overridden when they are set in the node placement. If there
+// are multiple AddOnDeploymentConfigs, the big index one overrides the one from small index. An
+// AddOnDeploymentConfig not found in the cache is skipped, the addon is re-rendered once it is created and
+// referenced by the addon status again.
+func injectNodePlacement(ctx context.Context, getter utils.AddOnDeploymentConfigGetter,
+	addon *addonapiv1alpha1.ManagedClusterAddOn, objects []runtime.Object) ([]runtime.Object, error) {
+	var nodePlacement *addonapiv1alpha1.NodePlacement
+	for _, config := range utils.GetDesiredConfigsOf(addon, utils.AddOnDeploymentConfigGroupResource) {
+		addOnDeploymentConfig, err := getter.Get(ctx, config.Namespace, config.Name)
+		if apierrors.IsNotFound(err) {
+			klog.FromContext(ctx).V(4).Info("AddOnDeploymentConfig is not found, its node placement is skipped",
+				"namespace", config.Namespace, "name", config.Name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if addOnDeploymentConfig.Spec.NodePlacement != nil {
+			nodePlacement = addOnDeploymentConfig.Spec.NodePlacement
+		}
+	}
+	if nodePlacement == nil {
+		return objects, nil
+	}
+
+	mutated := make([]runtime.Object, 0, len(objects))
+	for _, obj := range objects {
+		mutatedObj, err := utils.MutateWorkload(obj, func(_ string, _ metav1.Object, podSpec *corev1.PodSpec) {
+			if len(nodePlacement.NodeSelector) > 0 {
+				podSpec.NodeSelector = nodePlacement.NodeSelector
+			}
+			if len(nodePlacement.Tolerations) > 0 {
+				podSpec.Tolerations = nodePlacement.Tolerations
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		mutated = append(mutated, mutatedObj)
+	}
+	return mutated, nil
+}
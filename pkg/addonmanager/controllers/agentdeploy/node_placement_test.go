@@ -0,0 +1,157 @@
+package agentdeploy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+func TestInjectNodePlacement(t *testing.T) {
+	nodeSelector := map[string]string{"host": "ssd"}
+	tolerations := []corev1.Toleration{{Key: "foo", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute}}
+	existingNodeSelector := map[string]string{"zone": "a"}
+
+	newDeployment := func() *appsv1.Deployment {
+		deployment := &appsv1.Deployment{
+			TypeMeta:   metav1.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "addon-ns"},
+		}
+		deployment.Spec.Template.Spec.NodeSelector = existingNodeSelector
+		return deployment
+	}
+	newUnstructuredStatefulSet := func() *unstructured.Unstructured {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&appsv1.StatefulSet{
+			TypeMeta:   metav1.TypeMeta{Kind: "StatefulSet", APIVersion: "apps/v1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "store", Namespace: "addon-ns"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &unstructured.Unstructured{Object: content}
+	}
+	newJob := func() *batchv1.Job {
+		return &batchv1.Job{
+			TypeMeta:   metav1.TypeMeta{Kind: "Job", APIVersion: "batch/v1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "cleanup", Namespace: "addon-ns"},
+		}
+	}
+	newConfig := func(nodePlacement *addonapiv1alpha1.NodePlacement) *addonapiv1alpha1.AddOnDeploymentConfig {
+		return &addonapiv1alpha1.AddOnDeploymentConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "cluster1"},
+			Spec:       addonapiv1alpha1.AddOnDeploymentConfigSpec{NodePlacement: nodePlacement},
+		}
+	}
+	podSpecs := func(t *testing.T, objects []runtime.Object) []corev1.PodSpec {
+		specs := []corev1.PodSpec{}
+		for _, obj := range objects {
+			switch o := obj.(type) {
+			case *appsv1.Deployment:
+				specs = append(specs, o.Spec.Template.Spec)
+			case *batchv1.Job:
+				specs = append(specs, o.Spec.Template.Spec)
+			case *unstructured.Unstructured:
+				if o.GetKind() != "StatefulSet" {
+					continue
+				}
+				statefulSet := &appsv1.StatefulSet{}
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, statefulSet); err != nil {
+					t.Fatal(err)
+				}
+				specs = append(specs, statefulSet.Spec.Template.Spec)
+			}
+		}
+		return specs
+	}
+
+	cases := []struct {
+		name                 string
+		config               *addonapiv1alpha1.AddOnDeploymentConfig
+		configNotFound       bool
+		expectedNodeSelector map[string]string
+		expectedTolerations  []corev1.Toleration
+	}{
+		{
+			name: "no addon deployment config",
+		},
+		{
+			name:           "addon deployment config not found",
+			config:         newConfig(&addonapiv1alpha1.NodePlacement{NodeSelector: nodeSelector}),
+			configNotFound: true,
+		},
+		{
+			name:   "no node placement",
+			config: newConfig(nil),
+		},
+		{
+			name:                 "node placement with tolerations only",
+			config:               newConfig(&addonapiv1alpha1.NodePlacement{Tolerations: tolerations}),
+			expectedTolerations:  tolerations,
+			expectedNodeSelector: nil,
+		},
+		{
+			name:                 "node placement",
+			config:               newConfig(&addonapiv1alpha1.NodePlacement{NodeSelector: nodeSelector, Tolerations: tolerations}),
+			expectedNodeSelector: nodeSelector,
+			expectedTolerations:  tolerations,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addon := addontesting.NewAddon("test", "cluster1")
+			configs := []runtime.Object{}
+			if c.config != nil {
+				if !c.configNotFound {
+					configs = append(configs, c.config)
+				}
+				addon.Status.ConfigReferences = []addonapiv1alpha1.ConfigReference{{
+					ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+						Group:    utils.AddOnDeploymentConfigGVR.Group,
+						Resource: utils.AddOnDeploymentConfigGVR.Resource,
+					},
+					ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config"},
+				}}
+			}
+
+			deployment := newDeployment()
+			objects, err := injectNodePlacement(context.TODO(), utils.NewAddOnDeploymentConfigGetter(fakeaddon.NewSimpleClientset(configs...)), addon,
+				[]runtime.Object{deployment, newUnstructuredStatefulSet(), newJob(),
+					addontesting.NewUnstructured("v1", "ConfigMap", "addon-ns", "test")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objects) != 4 {
+				t.Fatalf("expected 4 objects, but got %d", len(objects))
+			}
+
+			for i, spec := range podSpecs(t, objects) {
+				expectedNodeSelector := c.expectedNodeSelector
+				// the existing node selector is kept if the node selector is not set
+				if i == 0 && expectedNodeSelector == nil {
+					expectedNodeSelector = existingNodeSelector
+				}
+				if !reflect.DeepEqual(spec.NodeSelector, expectedNodeSelector) {
+					t.Errorf("expected node selector %v, but got %v", expectedNodeSelector, spec.NodeSelector)
+				}
+				if !reflect.DeepEqual(spec.Tolerations, c.expectedTolerations) {
+					t.Errorf("expected tolerations %v, but got %v", c.expectedTolerations, spec.Tolerations)
+				}
+			}
+			if !reflect.DeepEqual(deployment.Spec.Template.Spec.NodeSelector, existingNodeSelector) {
+				t.Errorf("expected the original deployment is not changed")
+			}
+		})
+	}
+}
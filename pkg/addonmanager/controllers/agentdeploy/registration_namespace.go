@@ -0,0 +1,63 @@
+package agentdeploy
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+// injectRegistrationNamespace adds the registration namespace of the addon, where the registration secret and
+// the lease of the addon agent are, to the manifests if they do not create it, so the registration
+// and the lease health check do not fail when the agent is installed in a namespace created by others. The
+// namespace may be shared by the addons, so it is orphaned when the works are deleted. It is only added in the
+// Default install mode.
+func injectRegistrationNamespace(addon *addonapiv1alpha1.ManagedClusterAddOn, namespace string,
+	objects []runtime.Object) []runtime.Object {
+	if len(namespace) == 0 || len(objects) == 0 {
+		return objects
+	}
+	if installMode, _ := constants.GetHostedModeInfo(addon.GetAnnotations()); installMode != constants.InstallModeDefault {
+		return objects
+	}
+
+	for _, obj := range objects {
+		if obj.GetObjectKind().GroupVersionKind().GroupKind() != corev1.SchemeGroupVersion.WithKind("Namespace").GroupKind() {
+			continue
+		}
+		accessor, err := meta.Accessor(obj)
+		if err == nil && accessor.GetName() == namespace {
+			return objects
+		}
+	}
+
+	ns := &corev1.Namespace{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: namespace,
+			Labels: map[string]string{
+				constants.AddonRegistrationNamespaceLabelKey: "true",
+			},
+			Annotations: map[string]string{
+				addonapiv1alpha1.DeletionOrphanAnnotationKey: "",
+			},
+		},
+	}
+	return append([]runtime.Object{ns}, objects...)
+}
+
+// isRegistrationNamespace returns true if the object is the registration namespace added by
+// injectRegistrationNamespace.
+func isRegistrationNamespace(obj runtime.Object) bool {
+	if obj.GetObjectKind().GroupVersionKind().GroupKind() != corev1.SchemeGroupVersion.WithKind("Namespace").GroupKind() {
+		return false
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	return accessor.GetLabels()[constants.AddonRegistrationNamespaceLabelKey] == "true"
+}
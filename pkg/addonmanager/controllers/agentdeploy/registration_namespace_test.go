@@ -0,0 +1,88 @@
+package agentdeploy
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func TestInjectRegistrationNamespace(t *testing.T) {
+	newAddon := func(namespace string, annotations map[string]string) *addonapiv1alpha1.ManagedClusterAddOn {
+		addon := addontesting.NewAddon("test", "cluster1")
+		addon.Annotations = annotations
+		addon.Status.Namespace = namespace
+		return addon
+	}
+	deployment := addontesting.NewUnstructured("apps/v1", "Deployment", "addon-ns", "agent")
+
+	cases := []struct {
+		name              string
+		addon             *addonapiv1alpha1.ManagedClusterAddOn
+		objects           []runtime.Object
+		expectedInjected  bool
+		expectedObjectLen int
+	}{
+		{
+			name:              "no registration namespace",
+			addon:             newAddon("", nil),
+			objects:           []runtime.Object{deployment},
+			expectedObjectLen: 1,
+		},
+		{
+			name:  "no manifests",
+			addon: newAddon("addon-ns", nil),
+		},
+		{
+			name:              "inject the registration namespace",
+			addon:             newAddon("addon-ns", nil),
+			objects:           []runtime.Object{deployment},
+			expectedInjected:  true,
+			expectedObjectLen: 2,
+		},
+		{
+			name:  "the manifests create the registration namespace",
+			addon: newAddon("addon-ns", nil),
+			objects: []runtime.Object{
+				addontesting.NewUnstructured("v1", "Namespace", "", "addon-ns"),
+				deployment,
+			},
+			expectedObjectLen: 2,
+		},
+		{
+			name:              "hosted mode",
+			addon:             newAddon("addon-ns", map[string]string{addonapiv1alpha1.HostingClusterNameAnnotationKey: "cluster2"}),
+			objects:           []runtime.Object{deployment},
+			expectedObjectLen: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			objects := injectRegistrationNamespace(c.addon, c.addon.Status.Namespace, c.objects)
+			if len(objects) != c.expectedObjectLen {
+				t.Fatalf("expected %d objects, but got %d", c.expectedObjectLen, len(objects))
+			}
+			if len(objects) == 0 {
+				return
+			}
+			ns, injected := objects[0].(*corev1.Namespace)
+			if injected != c.expectedInjected {
+				t.Fatalf("expected namespace injected %v, but got %v", c.expectedInjected, objects[0])
+			}
+			if !injected {
+				return
+			}
+			if ns.Name != "addon-ns" || ns.Labels[constants.AddonRegistrationNamespaceLabelKey] != "true" {
+				t.Errorf("unexpected namespace %v", ns)
+			}
+			if _, ok := ns.Annotations[addonapiv1alpha1.DeletionOrphanAnnotationKey]; !ok {
+				t.Errorf("expected the namespace is orphaned on deletion, but got annotations %v", ns.Annotations)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package agentdeploy
+
+import (
+	"context"
+
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"open-cluster-management.io/api/utils/work/v1/workbuilder"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+// RenderManifestWorks builds the ManifestWorks of the agent of the addon on the cluster in the default install
+// mode as the deploy controller does, without applying them, so the manifests of an addon can be rendered
+// offline. The AddOnDeploymentConfigs referenced by the addon are read by the addonClient, and the pre-delete
+// hook ManifestWork is the last one if the agent has hook manifests.
+func RenderManifestWorks(agentAddon agent.AgentAddon, addonClient addonv1alpha1client.Interface,
+	cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) ([]*workapiv1.ManifestWork, error) {
+	c := &addonDeployController{
+		workBuilder: workbuilder.NewWorkBuilder().WithManifestsLimit(manifestWorkSizeLimit),
+		addonClient: addonClient,
+		agentAddons: map[string]agent.AgentAddon{addon.Name: agentAddon},
+		// the addon is rendered offline without the informers
+		addOnDeploymentConfigGetter: utils.NewAddOnDeploymentConfigGetter(addonClient),
+	}
+
+	addon = addon.DeepCopy()
+	works, _, err := c.buildDeployManifestWorks(context.TODO(), constants.InstallModeDefault, cluster.Name, cluster, nil, addon)
+	if err != nil {
+		return nil, err
+	}
+	hookWork, err := c.buildHookManifestWork(context.TODO(), constants.InstallModeDefault, cluster.Name, cluster, addon)
+	if err != nil {
+		return nil, err
+	}
+	if hookWork != nil {
+		works = append(works, hookWork)
+	}
+	return works, nil
+}
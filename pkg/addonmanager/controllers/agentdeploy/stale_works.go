@@ -0,0 +1,31 @@
+package agentdeploy
+
+import (
+	"context"
+
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
+)
+
+// pruneStaleWorks deletes the stale deploy works of the addon, or only logs them in the dry-run mode.
+func pruneStaleWorks(ctx context.Context,
+	deleteWork func(ctx context.Context, workNamespace, workName string) error,
+	addon *addonapiv1alpha1.ManagedClusterAddOn,
+	staleWorks []*workapiv1.ManifestWork,
+	dryRun bool) []error {
+	var errs []error
+	for _, work := range staleWorks {
+		_, logger := logging.WithWork(ctx, work)
+		if dryRun {
+			logger.Info("[dry-run] The manifestwork is stale and would be deleted")
+			continue
+		}
+		logger.V(2).Info("Deleting the stale manifestwork")
+		if err := deleteWork(ctx, work.Namespace, work.Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,50 @@
+package agentdeploy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+)
+
+func TestPruneStaleWorks(t *testing.T) {
+	cases := []struct {
+		name            string
+		dryRun          bool
+		expectedDeleted []string
+	}{
+		{
+			name:            "delete the stale works",
+			expectedDeleted: []string{"cluster1/addon-test-deploy-1", "cluster1/addon-test-deploy-2"},
+		},
+		{
+			name:   "only log the stale works in dry-run mode",
+			dryRun: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var deleted []string
+			deleteWork := func(ctx context.Context, workNamespace, workName string) error {
+				deleted = append(deleted, workNamespace+"/"+workName)
+				return nil
+			}
+			staleWorks := []*workapiv1.ManifestWork{
+				addontesting.NewManifestWork("addon-test-deploy-1", "cluster1"),
+				addontesting.NewManifestWork("addon-test-deploy-2", "cluster1"),
+			}
+
+			errs := pruneStaleWorks(context.TODO(), deleteWork, addontesting.NewAddon("test", "cluster1"), staleWorks, c.dryRun)
+			if len(errs) != 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			if !reflect.DeepEqual(deleted, c.expectedDeleted) {
+				t.Errorf("expected deleted works %v, but got %v", c.expectedDeleted, deleted)
+			}
+		})
+	}
+}
@@ -6,8 +6,14 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/agent"
 )
 
 func TestConfigsToAnnotations(t *testing.T) {
@@ -153,3 +159,379 @@ func TestAddonAddFinalizer(t *testing.T) {
 		})
 	}
 }
+
+type optionsAgent struct {
+	testAgent
+	options agent.AgentAddonOptions
+}
+
+func (a *optionsAgent) GetAgentAddonOptions() agent.AgentAddonOptions {
+	return a.options
+}
+
+func TestGetManifestConfigOption(t *testing.T) {
+	deployment := workapiv1.ResourceIdentifier{Group: "apps", Resource: "deployments", Name: "agent", Namespace: "addon-ns"}
+	crd := workapiv1.ResourceIdentifier{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions", Name: "foos.test"}
+	serverSideApply := &workapiv1.UpdateStrategy{Type: workapiv1.UpdateStrategyTypeServerSideApply}
+	createOnly := &workapiv1.UpdateStrategy{Type: workapiv1.UpdateStrategyTypeCreateOnly}
+	wellKnownStatus := []workapiv1.FeedbackRule{{Type: workapiv1.WellKnownStatusType}}
+	workProber := &agent.HealthProber{
+		Type: agent.HealthProberTypeWork,
+		WorkProber: &agent.WorkHealthProber{
+			ProbeFields: []agent.ProbeField{{ResourceIdentifier: deployment, ProbeRules: wellKnownStatus}},
+		},
+	}
+
+	tenantA := addontesting.NewUnstructured("apps/v1", "Deployment", "tenant-a", "agent")
+	tenantA.SetLabels(map[string]string{"tenant": "a"})
+	tenantB := addontesting.NewUnstructured("apps/v1", "Deployment", "tenant-b", "agent")
+	tenantB.SetLabels(map[string]string{"tenant": "b"})
+	objects := []runtime.Object{
+		tenantA, tenantB,
+		addontesting.NewUnstructured("apps/v1", "Deployment", "addon-ns", "agent"),
+		addontesting.NewUnstructured("v1", "ConfigMap", "tenant-a", "agent"),
+	}
+	tenantIdentifier := func(namespace string) workapiv1.ResourceIdentifier {
+		return workapiv1.ResourceIdentifier{Group: "apps", Resource: "deployments", Name: "agent", Namespace: namespace}
+	}
+
+	cases := []struct {
+		name            string
+		options         agent.AgentAddonOptions
+		expectedConfigs []workapiv1.ManifestConfigOption
+	}{
+		{
+			name: "no manifest config",
+		},
+		{
+			name: "work prober with wildcard namespace",
+			options: agent.AgentAddonOptions{HealthProber: &agent.HealthProber{
+				Type: agent.HealthProberTypeWork,
+				WorkProber: &agent.WorkHealthProber{ProbeFields: []agent.ProbeField{{
+					ResourceIdentifier: tenantIdentifier("tenant-*"),
+					ProbeRules:         wellKnownStatus,
+				}}},
+			}},
+			expectedConfigs: []workapiv1.ManifestConfigOption{
+				{ResourceIdentifier: tenantIdentifier("tenant-a"), FeedbackRules: wellKnownStatus},
+				{ResourceIdentifier: tenantIdentifier("tenant-b"), FeedbackRules: wellKnownStatus},
+			},
+		},
+		{
+			name: "work prober with label selector",
+			options: agent.AgentAddonOptions{HealthProber: &agent.HealthProber{
+				Type: agent.HealthProberTypeWork,
+				WorkProber: &agent.WorkHealthProber{ProbeFields: []agent.ProbeField{{
+					ResourceIdentifier: tenantIdentifier("*"),
+					LabelSelector:      &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "b"}},
+					ProbeRules:         wellKnownStatus,
+				}}},
+			}},
+			expectedConfigs: []workapiv1.ManifestConfigOption{
+				{ResourceIdentifier: tenantIdentifier("tenant-b"), FeedbackRules: wellKnownStatus},
+			},
+		},
+		{
+			name:    "lease prober",
+			options: agent.AgentAddonOptions{HealthProber: &agent.HealthProber{Type: agent.HealthProberTypeLease}},
+		},
+		{
+			name:    "work prober",
+			options: agent.AgentAddonOptions{HealthProber: workProber},
+			expectedConfigs: []workapiv1.ManifestConfigOption{
+				{ResourceIdentifier: deployment, FeedbackRules: wellKnownStatus},
+			},
+		},
+		{
+			name: "manifest configs",
+			options: agent.AgentAddonOptions{ManifestConfigs: []workapiv1.ManifestConfigOption{
+				{ResourceIdentifier: crd, UpdateStrategy: createOnly},
+			}},
+			expectedConfigs: []workapiv1.ManifestConfigOption{
+				{ResourceIdentifier: crd, UpdateStrategy: createOnly},
+			},
+		},
+		{
+			name: "manifest configs merged with work prober",
+			options: agent.AgentAddonOptions{
+				HealthProber: workProber,
+				ManifestConfigs: []workapiv1.ManifestConfigOption{
+					{ResourceIdentifier: deployment, UpdateStrategy: serverSideApply},
+					{ResourceIdentifier: crd, UpdateStrategy: createOnly},
+				},
+			},
+			expectedConfigs: []workapiv1.ManifestConfigOption{
+				{ResourceIdentifier: deployment, UpdateStrategy: serverSideApply, FeedbackRules: wellKnownStatus},
+				{ResourceIdentifier: crd, UpdateStrategy: createOnly},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			configs := getManifestConfigOption(&optionsAgent{options: c.options}, objects)
+			if len(configs) == 0 && len(c.expectedConfigs) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(configs, c.expectedConfigs) {
+				t.Errorf("expected manifest configs %v, but got %v", c.expectedConfigs, configs)
+			}
+		})
+	}
+}
+
+func TestMergeDeletionOrphaningRules(t *testing.T) {
+	rule1 := workapiv1.OrphaningRule{Resource: "configmaps", Name: "test1", Namespace: "addon-ns"}
+	rule2 := workapiv1.OrphaningRule{Resource: "configmaps", Name: "test2", Namespace: "addon-ns"}
+	orphan := &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeOrphan}
+	foreground := &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeForeground}
+	selectivelyOrphan := func(rules ...workapiv1.OrphaningRule) *workapiv1.DeleteOption {
+		return &workapiv1.DeleteOption{
+			PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+			SelectivelyOrphan: &workapiv1.SelectivelyOrphan{OrphaningRules: rules},
+		}
+	}
+
+	cases := []struct {
+		name           string
+		deleteOption   *workapiv1.DeleteOption
+		rules          []workapiv1.OrphaningRule
+		expectedOption *workapiv1.DeleteOption
+	}{
+		{
+			name: "no delete option and rules",
+		},
+		{
+			name:           "delete option only",
+			deleteOption:   foreground,
+			expectedOption: foreground,
+		},
+		{
+			name:           "rules only",
+			rules:          []workapiv1.OrphaningRule{rule1},
+			expectedOption: selectivelyOrphan(rule1),
+		},
+		{
+			name:           "orphan all",
+			deleteOption:   orphan,
+			rules:          []workapiv1.OrphaningRule{rule1},
+			expectedOption: orphan,
+		},
+		{
+			name:           "foreground with rules",
+			deleteOption:   foreground,
+			rules:          []workapiv1.OrphaningRule{rule1},
+			expectedOption: selectivelyOrphan(rule1),
+		},
+		{
+			name:           "selectively orphan with rules",
+			deleteOption:   selectivelyOrphan(rule1),
+			rules:          []workapiv1.OrphaningRule{rule2},
+			expectedOption: selectivelyOrphan(rule1, rule2),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			option := mergeDeletionOrphaningRules(c.deleteOption, c.rules)
+			if !reflect.DeepEqual(option, c.expectedOption) {
+				t.Errorf("expected delete option %v, but got %v", c.expectedOption, option)
+			}
+		})
+	}
+}
+
+func TestDeployWorkConfiguration(t *testing.T) {
+	orphan := &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeOrphan}
+	foreground := &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeForeground}
+	executor := &workapiv1.ManifestWorkExecutor{
+		Subject: workapiv1.ManifestWorkExecutorSubject{Type: workapiv1.ExecutorSubjectTypeServiceAccount},
+	}
+
+	cases := []struct {
+		name           string
+		options        agent.AgentAddonOptions
+		annotations    map[string]string
+		expectedConfig *agent.WorkConfiguration
+	}{
+		{
+			name: "no deletion policy",
+		},
+		{
+			name:           "no deletion policy keeps the work configuration",
+			options:        agent.AgentAddonOptions{WorkConfiguration: &agent.WorkConfiguration{DeleteOption: foreground}},
+			expectedConfig: &agent.WorkConfiguration{DeleteOption: foreground},
+		},
+		{
+			name:           "orphan by the addon options",
+			options:        agent.AgentAddonOptions{DeletionPolicy: agent.DeletionPolicyOrphan},
+			expectedConfig: &agent.WorkConfiguration{DeleteOption: orphan},
+		},
+		{
+			name: "orphan by the annotation keeps the executor",
+			options: agent.AgentAddonOptions{
+				WorkConfiguration: &agent.WorkConfiguration{DeleteOption: foreground, Executor: executor},
+			},
+			annotations:    map[string]string{constants.AddonDeletionPolicyAnnotationKey: "Orphan"},
+			expectedConfig: &agent.WorkConfiguration{DeleteOption: orphan, Executor: executor},
+		},
+		{
+			name:        "cascade by the annotation overrides the addon options",
+			options:     agent.AgentAddonOptions{DeletionPolicy: agent.DeletionPolicyOrphan},
+			annotations: map[string]string{constants.AddonDeletionPolicyAnnotationKey: "Cascade"},
+		},
+		{
+			name: "cascade removes the orphan delete option",
+			options: agent.AgentAddonOptions{
+				WorkConfiguration: &agent.WorkConfiguration{DeleteOption: orphan, Executor: executor},
+			},
+			annotations:    map[string]string{constants.AddonDeletionPolicyAnnotationKey: "Cascade"},
+			expectedConfig: &agent.WorkConfiguration{Executor: executor},
+		},
+		{
+			name:           "unsupported annotation is ignored",
+			options:        agent.AgentAddonOptions{DeletionPolicy: agent.DeletionPolicyOrphan},
+			annotations:    map[string]string{constants.AddonDeletionPolicyAnnotationKey: "Unknown"},
+			expectedConfig: &agent.WorkConfiguration{DeleteOption: orphan},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addon := addontesting.NewAddon("test", "cluster1")
+			addon.Annotations = c.annotations
+			config := deployWorkConfiguration(c.options, addon)
+			if !reflect.DeepEqual(config, c.expectedConfig) {
+				t.Errorf("expected work configuration %v, but got %v", c.expectedConfig, config)
+			}
+		})
+	}
+}
+
+func TestWithServerSideApply(t *testing.T) {
+	configMap := workapiv1.ResourceIdentifier{Resource: "configmaps", Name: "test", Namespace: "addon-ns"}
+	deployment := workapiv1.ResourceIdentifier{Group: "apps", Resource: "deployments", Name: "agent", Namespace: "addon-ns"}
+	serverSideApply := &workapiv1.UpdateStrategy{
+		Type:            workapiv1.UpdateStrategyTypeServerSideApply,
+		ServerSideApply: &workapiv1.ServerSideApplyConfig{FieldManager: constants.ServerSideApplyFieldManager},
+	}
+	createOnly := &workapiv1.UpdateStrategy{Type: workapiv1.UpdateStrategyTypeCreateOnly}
+	feedbackRules := []workapiv1.FeedbackRule{{Type: workapiv1.WellKnownStatusType}}
+
+	cases := []struct {
+		name            string
+		manifestOptions []workapiv1.ManifestConfigOption
+		expectedConfigs []workapiv1.ManifestConfigOption
+	}{
+		{
+			name: "no manifest configs",
+			expectedConfigs: []workapiv1.ManifestConfigOption{
+				{ResourceIdentifier: configMap, UpdateStrategy: serverSideApply},
+				{ResourceIdentifier: deployment, UpdateStrategy: serverSideApply},
+			},
+		},
+		{
+			name: "manifest configs without update strategy",
+			manifestOptions: []workapiv1.ManifestConfigOption{
+				{ResourceIdentifier: deployment, FeedbackRules: feedbackRules},
+			},
+			expectedConfigs: []workapiv1.ManifestConfigOption{
+				{ResourceIdentifier: deployment, FeedbackRules: feedbackRules, UpdateStrategy: serverSideApply},
+				{ResourceIdentifier: configMap, UpdateStrategy: serverSideApply},
+			},
+		},
+		{
+			name: "manifest configs with update strategy",
+			manifestOptions: []workapiv1.ManifestConfigOption{
+				{ResourceIdentifier: configMap, UpdateStrategy: createOnly},
+			},
+			expectedConfigs: []workapiv1.ManifestConfigOption{
+				{ResourceIdentifier: configMap, UpdateStrategy: createOnly},
+				{ResourceIdentifier: deployment, UpdateStrategy: serverSideApply},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			objects := []runtime.Object{
+				addontesting.NewUnstructured("v1", "ConfigMap", "addon-ns", "test"),
+				addontesting.NewUnstructured("apps/v1", "Deployment", "addon-ns", "agent"),
+			}
+			configs := withServerSideApply(c.manifestOptions, objects)
+			if !reflect.DeepEqual(configs, c.expectedConfigs) {
+				t.Errorf("expected manifest configs %v, but got %v", c.expectedConfigs, configs)
+			}
+		})
+	}
+}
+
+func TestSetManifestApplyConflictCondition(t *testing.T) {
+	newWork := func(reason string) *workapiv1.ManifestWork {
+		work := addontesting.NewManifestWork("addon-test-deploy", "cluster1")
+		work.Status.ResourceStatus.Manifests = []workapiv1.ManifestCondition{
+			{
+				ResourceMeta: workapiv1.ManifestResourceMeta{
+					Group: "apps", Resource: "deployments", Name: "agent", Namespace: "addon-ns"},
+				Conditions: []metav1.Condition{{
+					Type:    string(workapiv1.ManifestApplied),
+					Status:  metav1.ConditionFalse,
+					Reason:  reason,
+					Message: "conflict with kubectl",
+				}},
+			},
+		}
+		return work
+	}
+
+	cases := []struct {
+		name           string
+		existingCond   *metav1.Condition
+		works          []*workapiv1.ManifestWork
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name:  "no conflict without condition",
+			works: []*workapiv1.ManifestWork{newWork("AppliedManifestFailed")},
+		},
+		{
+			name:           "conflict",
+			works:          []*workapiv1.ManifestWork{newWork(workApplyConflictReason)},
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: constants.ManifestApplyConflictReasonConflict,
+		},
+		{
+			name: "conflict resolved",
+			existingCond: &metav1.Condition{
+				Type:   constants.AddonManifestApplyConflict,
+				Status: metav1.ConditionTrue,
+				Reason: constants.ManifestApplyConflictReasonConflict,
+			},
+			works:          []*workapiv1.ManifestWork{addontesting.NewManifestWork("addon-test-deploy", "cluster1")},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: constants.ManifestApplyConflictReasonNoConflict,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addon := addontesting.NewAddon("test", "cluster1")
+			if c.existingCond != nil {
+				meta.SetStatusCondition(&addon.Status.Conditions, *c.existingCond)
+			}
+			setManifestApplyConflictCondition(addon, c.works)
+
+			cond := meta.FindStatusCondition(addon.Status.Conditions, constants.AddonManifestApplyConflict)
+			if len(c.expectedStatus) == 0 {
+				if cond != nil {
+					t.Errorf("expected no condition, but got %v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != c.expectedStatus || cond.Reason != c.expectedReason {
+				t.Errorf("expected condition %s with reason %s, but got %v", c.expectedStatus, c.expectedReason, cond)
+			}
+		})
+	}
+}
@@ -3,11 +3,13 @@ package agentdeploy
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	"open-cluster-management.io/api/utils/work/v1/workbuilder"
@@ -17,6 +19,10 @@ import (
 	"open-cluster-management.io/addon-framework/pkg/agent"
 )
 
+// workApplyConflictReason is the reason of the Applied condition of a manifest in the work status when the work
+// agent fails to apply the manifest due to a server side apply conflict.
+const workApplyConflictReason = "ApplyConflict"
+
 func addonHasFinalizer(addon *addonapiv1alpha1.ManagedClusterAddOn, finalizer string) bool {
 	for _, f := range addon.Finalizers {
 		if f == finalizer {
@@ -262,7 +268,8 @@ func (b *addonWorksBuilder) BuildDeployWorks(addonWorkNamespace string,
 	addon *addonapiv1alpha1.ManagedClusterAddOn,
 	existingWorks []workapiv1.ManifestWork,
 	objects []runtime.Object,
-	manifestOptions []workapiv1.ManifestConfigOption) (deployWorks, deleteWorks []*workapiv1.ManifestWork, err error) {
+	manifestOptions []workapiv1.ManifestConfigOption,
+	workConfig *agent.WorkConfiguration) (deployWorks, deleteWorks []*workapiv1.ManifestWork, err error) {
 	var deployObjects []runtime.Object
 	var owner *metav1.OwnerReference
 	installMode, _ := constants.GetHostedModeInfo(addon.GetAnnotations())
@@ -301,36 +308,294 @@ func (b *addonWorksBuilder) BuildDeployWorks(addonWorkNamespace string,
 		return nil, nil, nil
 	}
 
+	var executor *workapiv1.ManifestWorkExecutor
 	var deletionOption *workapiv1.DeleteOption
-	if len(deletionOrphaningRules) != 0 {
-		deletionOption = &workapiv1.DeleteOption{
-			PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
-			SelectivelyOrphan: &workapiv1.SelectivelyOrphan{
-				OrphaningRules: deletionOrphaningRules,
-			},
+	if workConfig != nil {
+		executor = workConfig.Executor
+		deletionOption = workConfig.DeleteOption
+		if workConfig.ServerSideApply {
+			manifestOptions = withServerSideApply(manifestOptions, deployObjects)
 		}
 	}
+	deletionOption = mergeDeletionOrphaningRules(deletionOption, deletionOrphaningRules)
 
 	annotations, err := configsToAnnotations(addon.Status.ConfigReferences)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return b.workBuilder.Build(deployObjects,
-		newAddonWorkObjectMeta(b.processor.manifestWorkNamePrefix(addon.Namespace, addon.Name), addon.Name, addon.Namespace, addonWorkNamespace, owner),
-		workbuilder.ExistingManifestWorksOption(existingWorks),
+	workLabels, workAnnotations, err := getWorkMetadata(deployObjects)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	workObjectMeta := newAddonWorkObjectMeta(b.processor.manifestWorkNamePrefix(addon.Namespace, addon.Name),
+		addon.Name, addon.Namespace, addonWorkNamespace, owner)
+	options := []workbuilder.WorkBuilderOption{
 		workbuilder.ManifestConfigOption(manifestOptions),
 		workbuilder.ManifestAnnotations(annotations),
-		workbuilder.DeletionOption(deletionOption))
+		workbuilder.DeletionOption(deletionOption),
+		workbuilder.ManifestWorkExecutorOption(executor),
+	}
+
+	if len(existingWorks) == 0 {
+		// the first install splits the manifests into the works deterministically, the CRDs and namespaces are
+		// in the first works. The existing manifests are kept in their works by the work builder afterwards
+		// to avoid moving the resources across the works.
+		shards, err := shardManifests(deployObjects, manifestShardSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, shard := range shards {
+			offset := len(deployWorks)
+			works, _, err := b.workBuilder.Build(shard, func(index int) metav1.ObjectMeta {
+				return workObjectMeta(offset + index)
+			}, options...)
+			if err != nil {
+				return nil, nil, err
+			}
+			deployWorks = append(deployWorks, works...)
+		}
+	} else {
+		deployWorks, deleteWorks, err = b.workBuilder.Build(deployObjects, workObjectMeta,
+			append(options, workbuilder.ExistingManifestWorksOption(existingWorks))...)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	setWorkMetadata(deployWorks, workLabels, workAnnotations)
+	return deployWorks, deleteWorks, nil
+}
+
+// withServerSideApply sets the server side apply update strategy on the manifest configs of the objects, the
+// manifest configs with an update strategy are kept.
+func withServerSideApply(manifestOptions []workapiv1.ManifestConfigOption,
+	objects []runtime.Object) []workapiv1.ManifestConfigOption {
+	serverSideApply := &workapiv1.UpdateStrategy{
+		Type: workapiv1.UpdateStrategyTypeServerSideApply,
+		ServerSideApply: &workapiv1.ServerSideApplyConfig{
+			FieldManager: constants.ServerSideApplyFieldManager,
+		},
+	}
+
+	configs := make([]workapiv1.ManifestConfigOption, 0, len(manifestOptions)+len(objects))
+	configs = append(configs, manifestOptions...)
+	for _, obj := range objects {
+		identifier, _, err := resourceIdentifier(obj)
+		if err != nil {
+			continue
+		}
+
+		found := false
+		for i := range configs {
+			if configs[i].ResourceIdentifier != identifier {
+				continue
+			}
+			found = true
+			if configs[i].UpdateStrategy == nil {
+				configs[i].UpdateStrategy = serverSideApply.DeepCopy()
+			}
+		}
+		if !found {
+			configs = append(configs, workapiv1.ManifestConfigOption{
+				ResourceIdentifier: identifier,
+				UpdateStrategy:     serverSideApply.DeepCopy(),
+			})
+		}
+	}
+	return configs
+}
+
+// applyConflicts returns the resources of the works failed to be applied due to the server side apply conflicts.
+func applyConflicts(works []*workapiv1.ManifestWork) []string {
+	var conflicts []string
+	for _, work := range works {
+		for _, manifest := range work.Status.ResourceStatus.Manifests {
+			cond := meta.FindStatusCondition(manifest.Conditions, string(workapiv1.ManifestApplied))
+			if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != workApplyConflictReason {
+				continue
+			}
+			resource := manifest.ResourceMeta
+			conflicts = append(conflicts, fmt.Sprintf("%s.%s %s/%s: %s",
+				resource.Resource, resource.Group, resource.Namespace, resource.Name, cond.Message))
+		}
+	}
+	return conflicts
+}
+
+// setManifestApplyConflictCondition sets the ManifestApplyConflict condition of the addon by the apply conflicts
+// of its works. The condition is only set when there are conflicts or it was set before.
+func setManifestApplyConflictCondition(addon *addonapiv1alpha1.ManagedClusterAddOn, works []*workapiv1.ManifestWork) {
+	conflicts := applyConflicts(works)
+	if len(conflicts) == 0 {
+		if meta.FindStatusCondition(addon.Status.Conditions, constants.AddonManifestApplyConflict) == nil {
+			return
+		}
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:    constants.AddonManifestApplyConflict,
+			Status:  metav1.ConditionFalse,
+			Reason:  constants.ManifestApplyConflictReasonNoConflict,
+			Message: "no conflict in applying the manifests of the addon",
+		})
+		return
+	}
+
+	meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+		Type:    constants.AddonManifestApplyConflict,
+		Status:  metav1.ConditionTrue,
+		Reason:  constants.ManifestApplyConflictReasonConflict,
+		Message: fmt.Sprintf("fields of the manifests are owned by other field managers: %s", strings.Join(conflicts, "; ")),
+	})
+}
+
+// setValuesInvalidCondition sets the ValuesInvalid condition of the addon by the error of rendering the manifests
+// of the agent. The condition is only set when the values are invalid or it was set before, and it is kept if the
+// manifests fail to be rendered for other reasons.
+func setValuesInvalidCondition(addon *addonapiv1alpha1.ManagedClusterAddOn, err error) {
+	switch {
+	case agent.IsValuesInvalid(err):
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:    constants.AddonValuesInvalid,
+			Status:  metav1.ConditionTrue,
+			Reason:  constants.ValuesInvalidReasonSchemaValidationFailed,
+			Message: err.Error(),
+		})
+	case err == nil && meta.FindStatusCondition(addon.Status.Conditions, constants.AddonValuesInvalid) != nil:
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:    constants.AddonValuesInvalid,
+			Status:  metav1.ConditionFalse,
+			Reason:  constants.ValuesInvalidReasonValuesValid,
+			Message: "the values of the addon are valid",
+		})
+	}
+}
+
+// deployWorkConfiguration returns the work configuration of the deploy manifestWorks of the addon, the delete
+// option is set to Orphan if the deletion policy of the addon agent, or the deletion policy annotation of the
+// addon which overrides it, is Orphan.
+func deployWorkConfiguration(options agent.AgentAddonOptions,
+	addon *addonapiv1alpha1.ManagedClusterAddOn) *agent.WorkConfiguration {
+	policy := options.DeletionPolicy
+	if value, ok := addon.Annotations[constants.AddonDeletionPolicyAnnotationKey]; ok {
+		switch agent.DeletionPolicy(value) {
+		case agent.DeletionPolicyCascade, agent.DeletionPolicyOrphan:
+			policy = agent.DeletionPolicy(value)
+		default:
+			klog.Warningf("the deletion policy %q of addon %s/%s is not supported, use %q instead",
+				value, addon.Namespace, addon.Name, policy)
+		}
+	}
+
+	workConfig := options.WorkConfiguration
+	switch policy {
+	case agent.DeletionPolicyOrphan:
+		config := agent.WorkConfiguration{}
+		if workConfig != nil {
+			config = *workConfig
+		}
+		config.DeleteOption = &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeOrphan}
+		return &config
+	case agent.DeletionPolicyCascade:
+		if workConfig != nil && workConfig.DeleteOption != nil &&
+			workConfig.DeleteOption.PropagationPolicy == workapiv1.DeletePropagationPolicyTypeOrphan {
+			config := *workConfig
+			config.DeleteOption = nil
+			return &config
+		}
+	}
+	return workConfig
+}
+
+// mergeDeletionOrphaningRules merges the orphaning rules of the resources with the deletion orphan annotation
+// into the delete option. The resources are already orphaned if the propagation policy is Orphan, otherwise
+// the delete option is changed to SelectivelyOrphan with the rules.
+func mergeDeletionOrphaningRules(deleteOption *workapiv1.DeleteOption,
+	rules []workapiv1.OrphaningRule) *workapiv1.DeleteOption {
+	if len(rules) == 0 {
+		return deleteOption
+	}
+	if deleteOption != nil && deleteOption.PropagationPolicy == workapiv1.DeletePropagationPolicyTypeOrphan {
+		return deleteOption
+	}
+
+	merged := &workapiv1.DeleteOption{
+		PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+		SelectivelyOrphan: &workapiv1.SelectivelyOrphan{},
+	}
+	if deleteOption != nil && deleteOption.SelectivelyOrphan != nil {
+		merged.SelectivelyOrphan.OrphaningRules = append(merged.SelectivelyOrphan.OrphaningRules,
+			deleteOption.SelectivelyOrphan.OrphaningRules...)
+	}
+	merged.SelectivelyOrphan.OrphaningRules = append(merged.SelectivelyOrphan.OrphaningRules, rules...)
+	return merged
+}
+
+// buildOverrideWorks sets the namespace, labels, owner and config annotations on the works built by a
+// WorksBuilderOverride, and returns the existing works of the addon not built anymore as the works to delete.
+func buildOverrideWorks(addonWorkNamespace string,
+	addon *addonapiv1alpha1.ManagedClusterAddOn,
+	existingWorks []*workapiv1.ManifestWork,
+	works []*workapiv1.ManifestWork) (deployWorks, deleteWorks []*workapiv1.ManifestWork, err error) {
+	annotations, err := configsToAnnotations(addon.Status.ConfigReferences)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	owner := metav1.NewControllerRef(addon, addonapiv1alpha1.GroupVersion.WithKind("ManagedClusterAddOn"))
+	names := sets.New[string]()
+	for _, work := range works {
+		if len(work.Name) == 0 {
+			return nil, nil, fmt.Errorf("the name of the manifestwork is empty")
+		}
+		if names.Has(work.Name) {
+			return nil, nil, fmt.Errorf("the name of the manifestwork %s is duplicated", work.Name)
+		}
+		if work.Name == constants.PreDeleteHookWorkName(addon.Name) {
+			return nil, nil, fmt.Errorf("the name of the manifestwork %s is reserved for the pre-delete hook", work.Name)
+		}
+		names.Insert(work.Name)
+
+		work = work.DeepCopy()
+		work.Namespace = addonWorkNamespace
+		if work.Labels == nil {
+			work.Labels = map[string]string{}
+		}
+		work.Labels[addonapiv1alpha1.AddonLabelKey] = addon.Name
+		if addon.Namespace != addonWorkNamespace {
+			work.Labels[addonapiv1alpha1.AddonNamespaceLabelKey] = addon.Namespace
+		} else {
+			work.OwnerReferences = append(work.OwnerReferences, *owner)
+		}
+		if len(annotations) > 0 {
+			if work.Annotations == nil {
+				work.Annotations = map[string]string{}
+			}
+			for key, value := range annotations {
+				work.Annotations[key] = value
+			}
+		}
+		deployWorks = append(deployWorks, work)
+	}
+
+	for _, work := range existingWorks {
+		if !names.Has(work.Name) {
+			deleteWorks = append(deleteWorks, work)
+		}
+	}
+	return deployWorks, deleteWorks, nil
 }
 
 // BuildHookWork returns the preDelete manifestWork, if there is no manifest need
-// to deploy, will return nil.
+// to deploy, will return nil. The registration namespace added by the addon manager is deployed with the hook
+// manifests and orphaned, so the hooks run in the same namespace as the deploy manifests after they are deleted.
 func (b *addonWorksBuilder) BuildHookWork(addonWorkNamespace string,
 	addon *addonapiv1alpha1.ManagedClusterAddOn,
-	objects []runtime.Object) (hookWork *workapiv1.ManifestWork, err error) {
+	objects []runtime.Object,
+	workConfig *agent.WorkConfiguration) (hookWork *workapiv1.ManifestWork, err error) {
 	var hookManifests []workapiv1.Manifest
 	var hookManifestConfigs []workapiv1.ManifestConfigOption
+	var registrationNamespaceManifests []workapiv1.Manifest
+	var deletionOrphaningRules []workapiv1.OrphaningRule
 	var owner *metav1.OwnerReference
 	installMode, _ := constants.GetHostedModeInfo(addon.GetAnnotations())
 
@@ -348,6 +613,23 @@ func (b *addonWorksBuilder) BuildHookWork(addonWorkNamespace string,
 			continue
 		}
 
+		if isRegistrationNamespace(object) {
+			rawObject, err := runtime.Encode(unstructured.UnstructuredJSONScheme, object)
+			if err != nil {
+				return nil, err
+			}
+			rule, err := getDeletionOrphaningRule(object)
+			if err != nil {
+				return nil, err
+			}
+			if rule != nil {
+				deletionOrphaningRules = append(deletionOrphaningRules, *rule)
+			}
+			registrationNamespaceManifests = append(registrationNamespaceManifests,
+				workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: rawObject}})
+			continue
+		}
+
 		isHookObject, manifestConfig := b.isPreDeleteHookObject(object)
 		if !isHookObject {
 			continue
@@ -363,12 +645,17 @@ func (b *addonWorksBuilder) BuildHookWork(addonWorkNamespace string,
 	if len(hookManifests) == 0 {
 		return nil, nil
 	}
+	hookManifests = append(registrationNamespaceManifests, hookManifests...)
 
 	hookWork = newManifestWork(addon.Namespace, addon.Name, addonWorkNamespace, hookManifests, b.processor.preDeleteHookManifestWorkName)
 	if owner != nil {
 		hookWork.OwnerReferences = []metav1.OwnerReference{*owner}
 	}
 	hookWork.Spec.ManifestConfigs = hookManifestConfigs
+	hookWork.Spec.DeleteOption = mergeDeletionOrphaningRules(nil, deletionOrphaningRules)
+	if workConfig != nil {
+		hookWork.Spec.Executor = workConfig.Executor
+	}
 	if addon.Namespace != addonWorkNamespace {
 		hookWork.Labels[addonapiv1alpha1.AddonNamespaceLabelKey] = addon.Namespace
 	}
@@ -473,26 +760,82 @@ func newAddonWorkObjectMeta(namePrefix, addonName, addonNamespace, workNamespace
 	}
 }
 
-func getManifestConfigOption(agentAddon agent.AgentAddon) []workapiv1.ManifestConfigOption {
-	if agentAddon.GetAgentAddonOptions().HealthProber == nil {
-		return nil
+// resourceIdentifier returns the resource identifier and the labels of the object.
+func resourceIdentifier(obj runtime.Object) (workapiv1.ResourceIdentifier, map[string]string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return workapiv1.ResourceIdentifier{}, nil, err
 	}
+	plural, _ := meta.UnsafeGuessKindToResource(obj.GetObjectKind().GroupVersionKind())
+	return workapiv1.ResourceIdentifier{
+		Group:     plural.Group,
+		Resource:  plural.Resource,
+		Name:      accessor.GetName(),
+		Namespace: accessor.GetNamespace(),
+	}, accessor.GetLabels(), nil
+}
 
-	if agentAddon.GetAgentAddonOptions().HealthProber.Type != agent.HealthProberTypeWork {
-		return nil
+// probedResources returns the resources of the objects probed by the probe field, the probe field matching
+// the resources by patterns is expanded against the objects.
+func probedResources(field agent.ProbeField, objects []runtime.Object) []workapiv1.ResourceIdentifier {
+	if !field.IsPattern() {
+		return []workapiv1.ResourceIdentifier{field.ResourceIdentifier}
 	}
 
-	if agentAddon.GetAgentAddonOptions().HealthProber.WorkProber == nil {
-		return nil
+	var identifiers []workapiv1.ResourceIdentifier
+	for _, obj := range objects {
+		identifier, labels, err := resourceIdentifier(obj)
+		if err != nil {
+			continue
+		}
+		matched, err := field.Matches(identifier, labels)
+		if err != nil {
+			klog.Warningf("Failed to match probe field: %v", err)
+			return nil
+		}
+		if matched {
+			identifiers = append(identifiers, identifier)
+		}
 	}
+	return identifiers
+}
+
+// getManifestConfigOption returns the manifest configs of the agent addon, the probe rules of the work health
+// prober are merged into the manifest config of the same resource. The probe fields matching the resources by
+// patterns are expanded against the objects of the addon.
+func getManifestConfigOption(agentAddon agent.AgentAddon, objects []runtime.Object) []workapiv1.ManifestConfigOption {
+	options := agentAddon.GetAgentAddonOptions()
 
 	manifestConfigs := []workapiv1.ManifestConfigOption{}
-	probeRules := agentAddon.GetAgentAddonOptions().HealthProber.WorkProber.ProbeFields
-	for _, rule := range probeRules {
-		manifestConfigs = append(manifestConfigs, workapiv1.ManifestConfigOption{
-			ResourceIdentifier: rule.ResourceIdentifier,
-			FeedbackRules:      rule.ProbeRules,
-		})
+	for _, config := range options.ManifestConfigs {
+		manifestConfigs = append(manifestConfigs, *config.DeepCopy())
+	}
+
+	if options.HealthProber == nil || options.HealthProber.Type != agent.HealthProberTypeWork ||
+		options.HealthProber.WorkProber == nil {
+		if len(manifestConfigs) == 0 {
+			return nil
+		}
+		return manifestConfigs
+	}
+
+	for _, rule := range options.HealthProber.WorkProber.ProbeFields {
+		for _, identifier := range probedResources(rule, objects) {
+			merged := false
+			for i := range manifestConfigs {
+				if manifestConfigs[i].ResourceIdentifier == identifier {
+					manifestConfigs[i].FeedbackRules = append(manifestConfigs[i].FeedbackRules, rule.ProbeRules...)
+					merged = true
+					break
+				}
+			}
+			if !merged {
+				manifestConfigs = append(manifestConfigs, workapiv1.ManifestConfigOption{
+					ResourceIdentifier: identifier,
+					FeedbackRules:      append([]workapiv1.FeedbackRule{}, rule.ProbeRules...),
+				})
+			}
+		}
 	}
 	return manifestConfigs
 }
@@ -559,3 +902,13 @@ func configsToAnnotations(configReference []addonapiv1alpha1.ConfigReference) (m
 		workapiv1.ManifestConfigSpecHashAnnotationKey: string(jsonBytes),
 	}, nil
 }
+
+// setAddonDisabledCondition sets the manifest applied condition of the disabled addon.
+func setAddonDisabledCondition(addon *addonapiv1alpha1.ManagedClusterAddOn, appliedType string) {
+	meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+		Type:    appliedType,
+		Status:  metav1.ConditionFalse,
+		Reason:  constants.ManifestAppliedReasonAddonDisabled,
+		Message: "the addon is disabled, the manifests of the addon are removed",
+	})
+}
@@ -0,0 +1,185 @@
+package agentdeploy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
+	"open-cluster-management.io/api/utils/work/v1/workapplier"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/metrics"
+)
+
+// workApplier applies and deletes the ManifestWorks of the addons, e.g. workapplier.WorkApplier.
+type workApplier interface {
+	Apply(ctx context.Context, work *workapiv1.ManifestWork) (*workapiv1.ManifestWork, error)
+	Delete(ctx context.Context, namespace, name string) error
+}
+
+// workApplyThrottledError is returned when the apply of a work is throttled by the rate limit of its cluster.
+type workApplyThrottledError struct {
+	cluster string
+	delay   time.Duration
+}
+
+func (e *workApplyThrottledError) Error() string {
+	return fmt.Sprintf("the manifestwork applies in cluster %s are throttled, retry after %v", e.cluster, e.delay)
+}
+
+// workApplyThrottled returns the longest delay of the throttled applies in the error, and whether all the
+// errors are throttled applies.
+func workApplyThrottled(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+
+	var throttled *workApplyThrottledError
+	if errors.As(err, &throttled) {
+		return throttled.delay, true
+	}
+
+	var aggregate utilerrors.Aggregate
+	if !errors.As(err, &aggregate) {
+		return 0, false
+	}
+	var maxDelay time.Duration
+	for _, e := range aggregate.Errors() {
+		delay, ok := workApplyThrottled(e)
+		if !ok {
+			return 0, false
+		}
+		if delay > maxDelay {
+			maxDelay = delay
+		}
+	}
+	return maxDelay, true
+}
+
+// appliedWork is the spec hash of a work applied by the addon manager, and the resource version of the work
+// after the apply.
+type appliedWork struct {
+	specHash        string
+	resourceVersion string
+}
+
+// cachedWorkApplier skips the applies which change nothing in the spec of the existing works, and limits the
+// rest by a token bucket per cluster namespace. The spec hash of each applied work is cached, so an apply of
+// the same spec on the unchanged work is skipped without comparing the manifests, e.g. on each resync.
+type cachedWorkApplier struct {
+	workApplier
+	workLister worklister.ManifestWorkLister
+	qps        rate.Limit
+	burst      int
+
+	lock         sync.Mutex
+	limiters     map[string]*rate.Limiter
+	appliedWorks map[string]appliedWork
+}
+
+func newCachedWorkApplier(applier workApplier, workLister worklister.ManifestWorkLister,
+	qps float64, burst int) *cachedWorkApplier {
+	if burst < 1 {
+		burst = 1
+	}
+	return &cachedWorkApplier{
+		workApplier:  applier,
+		workLister:   workLister,
+		qps:          rate.Limit(qps),
+		burst:        burst,
+		limiters:     map[string]*rate.Limiter{},
+		appliedWorks: map[string]appliedWork{},
+	}
+}
+
+func (a *cachedWorkApplier) Apply(ctx context.Context, work *workapiv1.ManifestWork) (*workapiv1.ManifestWork, error) {
+	key := work.Namespace + "/" + work.Name
+	specHash, err := workSpecHash(work)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := a.workLister.ManifestWorks(work.Namespace).Get(work.Name)
+	if err == nil {
+		// the work is not changed since it was applied with the same spec
+		a.lock.Lock()
+		applied, ok := a.appliedWorks[key]
+		a.lock.Unlock()
+		if ok && applied.specHash == specHash && applied.resourceVersion == existing.ResourceVersion {
+			metrics.RecordManifestWorkUpdateAvoided()
+			return existing.DeepCopy(), nil
+		}
+
+		if workapplier.ManifestWorkSpecEqual(work.Spec, existing.Spec) {
+			a.cacheAppliedWork(key, specHash, existing.ResourceVersion)
+			metrics.RecordManifestWorkUpdateAvoided()
+			return existing.DeepCopy(), nil
+		}
+	}
+
+	if delay := a.reserve(work.Namespace); delay > 0 {
+		return nil, &workApplyThrottledError{cluster: work.Namespace, delay: delay}
+	}
+
+	applied, err := a.workApplier.Apply(ctx, work)
+	if err != nil {
+		return applied, err
+	}
+	a.cacheAppliedWork(key, specHash, applied.ResourceVersion)
+	return applied, nil
+}
+
+func (a *cachedWorkApplier) Delete(ctx context.Context, namespace, name string) error {
+	if err := a.workApplier.Delete(ctx, namespace, name); err != nil {
+		return err
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	delete(a.appliedWorks, namespace+"/"+name)
+	return nil
+}
+
+func (a *cachedWorkApplier) cacheAppliedWork(key, specHash, resourceVersion string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.appliedWorks[key] = appliedWork{specHash: specHash, resourceVersion: resourceVersion}
+}
+
+// workSpecHash returns the hash of the spec of the work.
+func workSpecHash(work *workapiv1.ManifestWork) (string, error) {
+	data, err := json.Marshal(work.Spec)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// reserve takes a token of the cluster, it returns the delay until a token is available if there is none now.
+func (a *cachedWorkApplier) reserve(cluster string) time.Duration {
+	if a.qps <= 0 {
+		return 0
+	}
+
+	a.lock.Lock()
+	limiter, ok := a.limiters[cluster]
+	if !ok {
+		limiter = rate.NewLimiter(a.qps, a.burst)
+		a.limiters[cluster] = limiter
+	}
+	a.lock.Unlock()
+
+	reservation := limiter.Reserve()
+	delay := reservation.Delay()
+	if delay > 0 {
+		// do not consume the token in the future, the apply competes again after the delay
+		reservation.Cancel()
+	}
+	return delay
+}
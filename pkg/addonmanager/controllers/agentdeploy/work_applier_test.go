@@ -0,0 +1,185 @@
+package agentdeploy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	fakework "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	"open-cluster-management.io/api/utils/work/v1/workapplier"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+)
+
+func TestCachedWorkApplier(t *testing.T) {
+	existing := addontesting.NewManifestWork("existing", "cluster1",
+		addontesting.NewUnstructured("v1", "ConfigMap", "default", "test"))
+	fakeWorkClient := fakework.NewSimpleClientset(existing)
+	workInformerFactory := workinformers.NewSharedInformerFactory(fakeWorkClient, 10*time.Minute)
+	workLister := workInformerFactory.Work().V1().ManifestWorks().Lister()
+	if err := workInformerFactory.Work().V1().ManifestWorks().Informer().GetStore().Add(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	applier := newCachedWorkApplier(workapplier.NewWorkApplierWithTypedClient(fakeWorkClient, workLister),
+		workLister, 0.001, 1)
+
+	// the applies changing nothing are not limited
+	for i := 0; i < 3; i++ {
+		if _, err := applier.Apply(context.TODO(), existing.DeepCopy()); err != nil {
+			t.Fatalf("expected the no-op apply is not limited, but got %v", err)
+		}
+	}
+	if len(fakeWorkClient.Actions()) != 0 {
+		t.Errorf("expected no action for the no-op applies, but got %v", fakeWorkClient.Actions())
+	}
+
+	newWork := func(name, cluster string) *workapiv1.ManifestWork {
+		return addontesting.NewManifestWork(name, cluster, addontesting.NewUnstructured("v1", "ConfigMap", "default", name))
+	}
+	if _, err := applier.Apply(context.TODO(), newWork("work1", "cluster1")); err != nil {
+		t.Errorf("expected the apply in the burst, but got %v", err)
+	}
+	_, err := applier.Apply(context.TODO(), newWork("work2", "cluster1"))
+	if delay, ok := workApplyThrottled(err); !ok || delay <= 0 {
+		t.Errorf("expected the apply is throttled, but got %v", err)
+	}
+
+	// the rate limit is per cluster
+	if _, err := applier.Apply(context.TODO(), newWork("work1", "cluster2")); err != nil {
+		t.Errorf("expected the apply in another cluster is not limited, but got %v", err)
+	}
+
+	unlimited := newCachedWorkApplier(workapplier.NewWorkApplierWithTypedClient(fakeWorkClient, workLister),
+		workLister, 0, 0)
+	for i := 0; i < 3; i++ {
+		if _, err := unlimited.Apply(context.TODO(), newWork(fmt.Sprintf("work%d", i), "cluster3")); err != nil {
+			t.Errorf("expected the apply is not limited, but got %v", err)
+		}
+	}
+}
+
+type countingWorkApplier struct {
+	applied, deleted int
+}
+
+func (a *countingWorkApplier) Apply(_ context.Context, work *workapiv1.ManifestWork) (*workapiv1.ManifestWork, error) {
+	a.applied++
+	applied := work.DeepCopy()
+	applied.ResourceVersion = fmt.Sprintf("%d", a.applied)
+	return applied, nil
+}
+
+func (a *countingWorkApplier) Delete(_ context.Context, _, _ string) error {
+	a.deleted++
+	return nil
+}
+
+func TestCachedWorkApplierSpecHash(t *testing.T) {
+	work := addontesting.NewManifestWork("test", "cluster1",
+		addontesting.NewUnstructured("v1", "ConfigMap", "default", "test"))
+	workInformerFactory := workinformers.NewSharedInformerFactory(fakework.NewSimpleClientset(), 10*time.Minute)
+	workStore := workInformerFactory.Work().V1().ManifestWorks().Informer().GetStore()
+	inner := &countingWorkApplier{}
+	applier := newCachedWorkApplier(inner, workInformerFactory.Work().V1().ManifestWorks().Lister(), 0, 0)
+
+	// the work is created and the spec hash is cached
+	applied, err := applier.Apply(context.TODO(), work.DeepCopy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := workStore.Add(applied); err != nil {
+		t.Fatal(err)
+	}
+	if inner.applied != 1 || len(applier.appliedWorks) != 1 {
+		t.Errorf("expected the work is applied and cached, but applied %d and cached %v", inner.applied, applier.appliedWorks)
+	}
+
+	// the resync of the same spec is skipped by the cache
+	if _, err := applier.Apply(context.TODO(), work.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	if inner.applied != 1 {
+		t.Errorf("expected the apply of the same spec is skipped, but applied %d", inner.applied)
+	}
+
+	// the work is changed by others, the spec is compared and the cache is refreshed
+	changed := applied.DeepCopy()
+	changed.ResourceVersion = "changed"
+	if err := workStore.Update(changed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := applier.Apply(context.TODO(), work.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	if inner.applied != 1 || applier.appliedWorks["cluster1/test"].resourceVersion != "changed" {
+		t.Errorf("expected the cache is refreshed without apply, but applied %d and cached %v", inner.applied, applier.appliedWorks)
+	}
+
+	// the rendered manifests are changed
+	updated := addontesting.NewManifestWork("test", "cluster1",
+		addontesting.NewUnstructured("v1", "ConfigMap", "default", "updated"))
+	if _, err := applier.Apply(context.TODO(), updated); err != nil {
+		t.Fatal(err)
+	}
+	if inner.applied != 2 {
+		t.Errorf("expected the changed work is applied, but applied %d", inner.applied)
+	}
+
+	if err := applier.Delete(context.TODO(), "cluster1", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if inner.deleted != 1 || len(applier.appliedWorks) != 0 {
+		t.Errorf("expected the work is deleted and uncached, but deleted %d and cached %v", inner.deleted, applier.appliedWorks)
+	}
+}
+
+func TestWorkApplyThrottled(t *testing.T) {
+	throttled := func(delay time.Duration) error {
+		return &workApplyThrottledError{cluster: "cluster1", delay: delay}
+	}
+
+	cases := []struct {
+		name              string
+		err               error
+		expectedThrottled bool
+		expectedDelay     time.Duration
+	}{
+		{
+			name: "no error",
+		},
+		{
+			name: "other error",
+			err:  fmt.Errorf("failed"),
+		},
+		{
+			name:              "throttled",
+			err:               throttled(time.Second),
+			expectedThrottled: true,
+			expectedDelay:     time.Second,
+		},
+		{
+			name:              "all throttled",
+			err:               utilerrors.NewAggregate([]error{throttled(time.Second), utilerrors.NewAggregate([]error{throttled(2 * time.Second)})}),
+			expectedThrottled: true,
+			expectedDelay:     2 * time.Second,
+		},
+		{
+			name: "throttled with other error",
+			err:  utilerrors.NewAggregate([]error{throttled(time.Second), fmt.Errorf("failed")}),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			delay, ok := workApplyThrottled(c.err)
+			if ok != c.expectedThrottled || delay != c.expectedDelay {
+				t.Errorf("expected throttled %v with delay %v, but got %v with %v", c.expectedThrottled, c.expectedDelay, ok, delay)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package agentdeploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+// reservedWorkMetadataDomain is the domain of the labels and annotations of the works reserved by the framework.
+const reservedWorkMetadataDomain = "open-cluster-management.io/"
+
+// stampedWorkAnnotationKeys are the annotations in the reserved domain stamped on the works by the framework,
+// they are patched on the existing works with the other metadata.
+var stampedWorkAnnotationKeys = []string{
+	constants.ManifestDigestAnnotationKey,
+	constants.AgentVersionAnnotationKey,
+}
+
+// getWorkMetadata returns the labels and annotations of the works set by the WorkLabelsAnnotationKey and
+// WorkAnnotationsAnnotationKey annotations of the rendered manifests. If a key is set by multiple manifests,
+// the one from the latter manifest wins.
+func getWorkMetadata(objects []runtime.Object) (labels, annotations map[string]string, err error) {
+	labels, annotations = map[string]string{}, map[string]string{}
+	for _, obj := range objects {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for annotationKey, metadata := range map[string]map[string]string{
+			constants.WorkLabelsAnnotationKey:      labels,
+			constants.WorkAnnotationsAnnotationKey: annotations,
+		} {
+			value, ok := accessor.GetAnnotations()[annotationKey]
+			if !ok {
+				continue
+			}
+
+			entries := map[string]string{}
+			if err := json.Unmarshal([]byte(value), &entries); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse the annotation %s of %s/%s: %v",
+					annotationKey, accessor.GetNamespace(), accessor.GetName(), err)
+			}
+			for key, value := range entries {
+				if strings.Contains(key, reservedWorkMetadataDomain) {
+					continue
+				}
+				metadata[key] = value
+			}
+		}
+	}
+	return labels, annotations, nil
+}
+
+// setWorkMetadata sets the labels and annotations on the works.
+func setWorkMetadata(works []*workapiv1.ManifestWork, labels, annotations map[string]string) {
+	for _, work := range works {
+		for key, value := range labels {
+			if work.Labels == nil {
+				work.Labels = map[string]string{}
+			}
+			work.Labels[key] = value
+		}
+		for key, value := range annotations {
+			if work.Annotations == nil {
+				work.Annotations = map[string]string{}
+			}
+			work.Annotations[key] = value
+		}
+	}
+}
+
+// setAgentVersion stamps the desired version of the addon agent on the deploy works, nothing is stamped if the
+// version is empty.
+func setAgentVersion(works []*workapiv1.ManifestWork, version string) {
+	if len(version) == 0 {
+		return
+	}
+	for _, work := range works {
+		if work.Annotations == nil {
+			work.Annotations = map[string]string{}
+		}
+		work.Annotations[constants.AgentVersionAnnotationKey] = version
+	}
+}
+
+// workMetadataPatch returns the merge patch to set the labels and annotations of the required work on the
+// existing work, which are not updated by the work applier. The ones reserved by the framework are not
+// patched, except the ones stamped by the framework. It returns nil if they are already set.
+func workMetadataPatch(required, existing *workapiv1.ManifestWork) ([]byte, error) {
+	labels := missingEntries(required.Labels, existing.Labels)
+	annotations := missingEntries(required.Annotations, existing.Annotations)
+	for _, key := range stampedWorkAnnotationKeys {
+		if value, ok := required.Annotations[key]; ok && existing.Annotations[key] != value {
+			annotations[key] = value
+		}
+	}
+	if len(labels) == 0 && len(annotations) == 0 {
+		return nil, nil
+	}
+
+	metadata := map[string]interface{}{}
+	if len(labels) > 0 {
+		metadata["labels"] = labels
+	}
+	if len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+	return json.Marshal(map[string]interface{}{"metadata": metadata})
+}
+
+func missingEntries(required, existing map[string]string) map[string]string {
+	missing := map[string]string{}
+	for key, value := range required {
+		if strings.Contains(key, reservedWorkMetadataDomain) {
+			continue
+		}
+		if existingValue, ok := existing[key]; !ok || existingValue != value {
+			missing[key] = value
+		}
+	}
+	return missing
+}
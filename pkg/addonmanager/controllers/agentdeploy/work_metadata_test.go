@@ -0,0 +1,159 @@
+package agentdeploy
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func TestGetWorkMetadata(t *testing.T) {
+	newObject := func(name string, annotations map[string]string) *unstructured.Unstructured {
+		obj := addontesting.NewUnstructured("v1", "ConfigMap", "addon-ns", name)
+		obj.SetAnnotations(annotations)
+		return obj
+	}
+
+	cases := []struct {
+		name                string
+		objects             []runtime.Object
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+		expectedErr         bool
+	}{
+		{
+			name:                "no work metadata",
+			objects:             []runtime.Object{newObject("test", map[string]string{"foo": "bar"})},
+			expectedLabels:      map[string]string{},
+			expectedAnnotations: map[string]string{},
+		},
+		{
+			name: "work metadata from multiple objects",
+			objects: []runtime.Object{
+				newObject("test1", map[string]string{
+					constants.WorkLabelsAnnotationKey:      `{"cost-center":"1234","owner":"team-a"}`,
+					constants.WorkAnnotationsAnnotationKey: `{"ticket":"OPS-1"}`,
+				}),
+				newObject("test2", map[string]string{
+					constants.WorkLabelsAnnotationKey: `{"owner":"team-b","addon.open-cluster-management.io/name":"foo"}`,
+				}),
+			},
+			expectedLabels:      map[string]string{"cost-center": "1234", "owner": "team-b"},
+			expectedAnnotations: map[string]string{"ticket": "OPS-1"},
+		},
+		{
+			name: "invalid work metadata",
+			objects: []runtime.Object{
+				newObject("test", map[string]string{constants.WorkLabelsAnnotationKey: "owner=team-a"}),
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			labels, annotations, err := getWorkMetadata(c.objects)
+			if c.expectedErr {
+				if err == nil {
+					t.Errorf("expected error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(labels, c.expectedLabels) {
+				t.Errorf("expected labels %v, but got %v", c.expectedLabels, labels)
+			}
+			if !reflect.DeepEqual(annotations, c.expectedAnnotations) {
+				t.Errorf("expected annotations %v, but got %v", c.expectedAnnotations, annotations)
+			}
+		})
+	}
+}
+
+func TestWorkMetadataPatch(t *testing.T) {
+	newWork := func(labels, annotations map[string]string) *workapiv1.ManifestWork {
+		work := addontesting.NewManifestWork("addon-test-deploy-0", "cluster1")
+		work.Labels = labels
+		work.Annotations = annotations
+		return work
+	}
+
+	cases := []struct {
+		name          string
+		required      *workapiv1.ManifestWork
+		existing      *workapiv1.ManifestWork
+		expectedPatch map[string]interface{}
+	}{
+		{
+			name:     "metadata is set",
+			required: newWork(map[string]string{"owner": "team-a"}, map[string]string{"ticket": "OPS-1"}),
+			existing: newWork(map[string]string{"owner": "team-a", "foo": "bar"}, map[string]string{"ticket": "OPS-1"}),
+		},
+		{
+			name:     "reserved metadata is not patched",
+			required: newWork(map[string]string{"addon.open-cluster-management.io/name": "test"}, nil),
+			existing: newWork(nil, nil),
+		},
+		{
+			name:     "manifest digest is patched",
+			required: newWork(nil, map[string]string{constants.ManifestDigestAnnotationKey: "sha256:b"}),
+			existing: newWork(nil, map[string]string{constants.ManifestDigestAnnotationKey: "sha256:a"}),
+			expectedPatch: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{constants.ManifestDigestAnnotationKey: "sha256:b"},
+				},
+			},
+		},
+		{
+			name:     "agent version is patched",
+			required: newWork(nil, map[string]string{constants.AgentVersionAnnotationKey: "v2"}),
+			existing: newWork(nil, map[string]string{constants.AgentVersionAnnotationKey: "v1"}),
+			expectedPatch: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{constants.AgentVersionAnnotationKey: "v2"},
+				},
+			},
+		},
+		{
+			name:     "metadata is changed",
+			required: newWork(map[string]string{"owner": "team-b"}, map[string]string{"ticket": "OPS-1"}),
+			existing: newWork(map[string]string{"owner": "team-a"}, map[string]string{"ticket": "OPS-1"}),
+			expectedPatch: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"labels": map[string]interface{}{"owner": "team-b"},
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			patch, err := workMetadataPatch(c.required, c.existing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.expectedPatch == nil {
+				if patch != nil {
+					t.Errorf("expected no patch, but got %s", string(patch))
+				}
+				return
+			}
+
+			actual := map[string]interface{}{}
+			if err := json.Unmarshal(patch, &actual); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actual, c.expectedPatch) {
+				t.Errorf("expected patch %v, but got %v", c.expectedPatch, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package certificate
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/klog/v2"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+// defaultRenewalRatio is the ratio of the certificate lifetime after which the certificate is renewed if the
+// RenewBefore is not set.
+const defaultRenewalRatio = 0.8
+
+// certRotationController issues the client certificates of the addon agents for the registrations with
+// customized signers, and renews them before they expire.
+type certRotationController struct {
+	kubeClient                kubernetes.Interface
+	agentAddons               map[string]agent.AgentAddon
+	managedClusterAddonLister addonlisterv1alpha1.ManagedClusterAddOnLister
+	secretLister              corelisters.SecretLister
+}
+
+// NewCertRotationController creates a new certificate rotation controller
+func NewCertRotationController(
+	kubeClient kubernetes.Interface,
+	secretInformer coreinformers.SecretInformer,
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	agentAddons map[string]agent.AgentAddon,
+) factory.Controller {
+	c := &certRotationController{
+		kubeClient:                kubeClient,
+		agentAddons:               agentAddons,
+		managedClusterAddonLister: addonInformers.Lister(),
+		secretLister:              secretInformer.Lister(),
+	}
+	return factory.New().
+		WithFilteredEventsInformersQueueKeysFunc(
+			func(obj runtime.Object) []string {
+				key, _ := cache.MetaNamespaceKeyFunc(obj)
+				return []string{key}
+			},
+			func(obj interface{}) bool {
+				addon, ok := obj.(*addonapiv1alpha1.ManagedClusterAddOn)
+				if !ok {
+					return false
+				}
+				return c.rotationEnabled(addon.Name)
+			},
+			addonInformers.Informer()).
+		WithFilteredEventsInformersQueueKeysFunc(
+			func(obj runtime.Object) []string {
+				secret := obj.(*corev1.Secret)
+				return []string{fmt.Sprintf("%s/%s", secret.Namespace, secret.Labels[addonapiv1alpha1.AddonLabelKey])}
+			},
+			func(obj interface{}) bool {
+				secret, ok := obj.(*corev1.Secret)
+				if !ok {
+					return false
+				}
+				return c.rotationEnabled(secret.Labels[addonapiv1alpha1.AddonLabelKey])
+			},
+			secretInformer.Informer()).
+		WithSync(c.sync).
+		ToController("CertRotationController")
+}
+
+func (c *certRotationController) rotationEnabled(addonName string) bool {
+	agentAddon, ok := c.agentAddons[addonName]
+	if !ok {
+		return false
+	}
+	registrationOption := agentAddon.GetAgentAddonOptions().Registration
+	return registrationOption != nil && registrationOption.CertificateRotation != nil && registrationOption.CSRSign != nil
+}
+
+func (c *certRotationController) sync(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+	clusterName, addonName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		// ignore addon whose key is not in format: namespace/name
+		return nil
+	}
+	ctx, logger := logging.WithAddon(ctx, clusterName, addonName)
+	logger.V(4).Info("Reconciling client certificates")
+
+	if !c.rotationEnabled(addonName) {
+		return nil
+	}
+	registrationOption := c.agentAddons[addonName].GetAgentAddonOptions().Registration
+
+	addon, err := c.managedClusterAddonLister.ManagedClusterAddOns(clusterName).Get(addonName)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !addon.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	var nextRenewal time.Time
+	for _, registration := range addon.Status.Registrations {
+		// the client certificates of the kube-apiserver-client signer are requested by the klusterlet
+		if registration.SignerName == certificatesv1.KubeAPIServerClientSignerName {
+			continue
+		}
+
+		renewalTime, err := c.ensureCertificate(ctx, addon, registration, registrationOption)
+		if err != nil {
+			return err
+		}
+		if nextRenewal.IsZero() || renewalTime.Before(nextRenewal) {
+			nextRenewal = renewalTime
+		}
+	}
+
+	if !nextRenewal.IsZero() {
+		syncCtx.Queue().AddAfter(key, time.Until(nextRenewal))
+	}
+	return nil
+}
+
+// ensureCertificate issues the client certificate of the registration if it does not exist or needs to be
+// renewed, and returns the time to renew the certificate.
+func (c *certRotationController) ensureCertificate(
+	ctx context.Context,
+	addon *addonapiv1alpha1.ManagedClusterAddOn,
+	registration addonapiv1alpha1.RegistrationConfig,
+	registrationOption *agent.RegistrationOption) (time.Time, error) {
+	secretName := constants.SignedCertSecretName(addon.Name, registration.SignerName)
+	secret, err := c.secretLister.Secrets(addon.Namespace).Get(secretName)
+	switch {
+	case errors.IsNotFound(err):
+		secret = nil
+	case err != nil:
+		return time.Time{}, err
+	}
+
+	logger := logging.FromContext(ctx).WithValues("signer", registration.SignerName)
+	if secret != nil {
+		cert, err := parseCertificate(secret.Data[corev1.TLSCertKey])
+		if err == nil {
+			renewalTime := certRenewalTime(cert, registrationOption.CertificateRotation)
+			if time.Now().Before(renewalTime) {
+				return renewalTime, nil
+			}
+		} else {
+			logger.Info("Invalid client certificate, issuing a new one", "secret", klog.KRef(addon.Namespace, secretName), "err", err)
+		}
+	}
+
+	keyData, certData, err := issueCertificate(addon, registration, registrationOption.CSRSign)
+	if err != nil {
+		return time.Time{}, err
+	}
+	cert, err := parseCertificate(certData)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid client certificate signed by %s for addon %s/%s: %v",
+			registration.SignerName, addon.Namespace, addon.Name, err)
+	}
+
+	owner := metav1.NewControllerRef(addon, addonapiv1alpha1.GroupVersion.WithKind("ManagedClusterAddOn"))
+	required := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: addon.Namespace,
+			Labels: map[string]string{
+				addonapiv1alpha1.AddonLabelKey: addon.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{*owner},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certData,
+			corev1.TLSPrivateKeyKey: keyData,
+		},
+	}
+	if _, _, err := utils.ApplySecret(ctx, c.kubeClient.CoreV1(), required); err != nil {
+		return time.Time{}, err
+	}
+
+	logger.Info("Issued client certificate", "expiration", cert.NotAfter)
+	return certRenewalTime(cert, registrationOption.CertificateRotation), nil
+}
+
+// issueCertificate generates a private key and signs a client certificate with the subject of the registration.
+func issueCertificate(
+	addon *addonapiv1alpha1.ManagedClusterAddOn,
+	registration addonapiv1alpha1.RegistrationConfig,
+	sign agent.CSRSignerFunc) ([]byte, []byte, error) {
+	keyData, err := keyutil.MakeEllipticPrivateKeyPEM()
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKey, err := keyutil.ParsePrivateKeyPEM(keyData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subject := registration.Subject
+	if len(subject.User) == 0 {
+		subject.User = agent.DefaultUser(addon.Namespace, addon.Name, addon.Name)
+		subject.Groups = agent.DefaultGroups(addon.Namespace, addon.Name)
+	}
+	request, err := certutil.MakeCSR(privateKey, &pkix.Name{
+		CommonName:         subject.User,
+		Organization:       subject.Groups,
+		OrganizationalUnit: subject.OrganizationUnits,
+	}, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the csr is not created on the hub, it is only passed to the signer of the addon.
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: constants.SignedCertSecretName(addon.Name, registration.SignerName),
+			Labels: map[string]string{
+				clusterv1.ClusterNameLabelKey:  addon.Namespace,
+				addonapiv1alpha1.AddonLabelKey: addon.Name,
+			},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:    request,
+			SignerName: registration.SignerName,
+			Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature, certificatesv1.UsageKeyEncipherment, certificatesv1.UsageClientAuth},
+		},
+	}
+
+	certData := sign(csr)
+	if len(certData) == 0 {
+		return nil, nil, fmt.Errorf("empty client certificate signed by %s for addon %s/%s",
+			registration.SignerName, addon.Namespace, addon.Name)
+	}
+	return keyData, certData, nil
+}
+
+// certRenewalTime returns the time to renew the certificate.
+func certRenewalTime(cert *x509.Certificate, rotation *agent.CertificateRotation) time.Time {
+	if rotation != nil && rotation.RenewBefore > 0 {
+		return cert.NotAfter.Add(-rotation.RenewBefore)
+	}
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	return cert.NotBefore.Add(time.Duration(float64(lifetime) * defaultRenewalRatio))
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("PEM block type is not CERTIFICATE")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
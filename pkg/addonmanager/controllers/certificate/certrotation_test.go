@@ -0,0 +1,184 @@
+package certificate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/crypto"
+	certv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeinformers "k8s.io/client-go/informers"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+const testSignerName = "example.com/signer"
+
+type testRotationAgent struct {
+	name        string
+	signer      agent.CSRSignerFunc
+	renewBefore time.Duration
+}
+
+func (t *testRotationAgent) Manifests(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) ([]runtime.Object, error) {
+	return []runtime.Object{}, nil
+}
+
+func (t *testRotationAgent) GetAgentAddonOptions() agent.AgentAddonOptions {
+	return agent.AgentAddonOptions{
+		AddonName: t.name,
+		Registration: &agent.RegistrationOption{
+			CSRSign:             t.signer,
+			CertificateRotation: &agent.CertificateRotation{RenewBefore: t.renewBefore},
+		},
+	}
+}
+
+func newRegisteredAddon(signerNames ...string) *addonapiv1alpha1.ManagedClusterAddOn {
+	addon := addontesting.NewAddon("test", "cluster1")
+	for _, signerName := range signerNames {
+		addon.Status.Registrations = append(addon.Status.Registrations, addonapiv1alpha1.RegistrationConfig{
+			SignerName: signerName,
+			Subject: addonapiv1alpha1.Subject{
+				User:   "user1",
+				Groups: []string{"group1"},
+			},
+		})
+	}
+	return addon
+}
+
+func TestCertRotationReconcile(t *testing.T) {
+	caConfig, err := crypto.MakeSelfSignedCAConfig("test", 10)
+	if err != nil {
+		t.Fatalf("Failed to generate self signed CA config: %v", err)
+	}
+	ca, key, err := caConfig.GetPEMBytes()
+	if err != nil {
+		t.Fatalf("Failed to get ca cert/key: %v", err)
+	}
+	signer := utils.DefaultSignerWithExpiry(key, ca, 24*time.Hour)
+
+	registeredAddon := newRegisteredAddon(testSignerName)
+	keyData, certData, err := issueCertificate(registeredAddon, registeredAddon.Status.Registrations[0], signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	existingSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.SignedCertSecretName("test", testSignerName),
+			Namespace: "cluster1",
+			Labels:    map[string]string{addonapiv1alpha1.AddonLabelKey: "test"},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certData,
+			corev1.TLSPrivateKeyKey: keyData,
+		},
+	}
+
+	cases := []struct {
+		name                  string
+		addon                 []runtime.Object
+		secrets               []runtime.Object
+		renewBefore           time.Duration
+		validateSecretActions func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:                  "no addon",
+			validateSecretActions: addontesting.AssertNoActions,
+		},
+		{
+			name:                  "no custom signer",
+			addon:                 []runtime.Object{newRegisteredAddon(certv1.KubeAPIServerClientSignerName)},
+			validateSecretActions: addontesting.AssertNoActions,
+		},
+		{
+			name:  "issue certificate",
+			addon: []runtime.Object{newRegisteredAddon(certv1.KubeAPIServerClientSignerName, testSignerName)},
+			validateSecretActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "get", "create")
+				secret := actions[1].(clienttesting.CreateActionImpl).Object.(*corev1.Secret)
+				if secret.Name != "addon-test-example-com-signer-client-cert" {
+					t.Errorf("unexpected secret name %s", secret.Name)
+				}
+				cert, err := parseCertificate(secret.Data[corev1.TLSCertKey])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if cert.Subject.CommonName != "user1" {
+					t.Errorf("unexpected subject %v", cert.Subject)
+				}
+				if len(secret.Data[corev1.TLSPrivateKeyKey]) == 0 {
+					t.Errorf("expected private key in the secret")
+				}
+			},
+		},
+		{
+			name:                  "certificate is valid",
+			addon:                 []runtime.Object{newRegisteredAddon(testSignerName)},
+			secrets:               []runtime.Object{existingSecret},
+			validateSecretActions: addontesting.AssertNoActions,
+		},
+		{
+			name:        "renew certificate",
+			addon:       []runtime.Object{newRegisteredAddon(testSignerName)},
+			secrets:     []runtime.Object{existingSecret},
+			renewBefore: 48 * time.Hour,
+			validateSecretActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "get", "update")
+				secret := actions[1].(clienttesting.UpdateActionImpl).Object.(*corev1.Secret)
+				if string(secret.Data[corev1.TLSCertKey]) == string(certData) {
+					t.Errorf("expected certificate to be renewed")
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeKubeClient := fakekube.NewSimpleClientset(c.secrets...)
+			fakeAddonClient := fakeaddon.NewSimpleClientset(c.addon...)
+
+			kubeInformers := kubeinformers.NewSharedInformerFactory(fakeKubeClient, 10*time.Minute)
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+			for _, obj := range c.secrets {
+				if err := kubeInformers.Core().V1().Secrets().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, obj := range c.addon {
+				if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			controller := &certRotationController{
+				kubeClient: fakeKubeClient,
+				agentAddons: map[string]agent.AgentAddon{
+					"test": &testRotationAgent{name: "test", signer: signer, renewBefore: c.renewBefore},
+				},
+				managedClusterAddonLister: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				secretLister:              kubeInformers.Core().V1().Secrets().Lister(),
+			}
+
+			err := controller.sync(context.TODO(), addontesting.NewFakeSyncContext(t), "cluster1/test")
+			if err != nil {
+				t.Errorf("expected no error when sync: %v", err)
+			}
+			c.validateSecretActions(t, fakeKubeClient.Actions())
+		})
+	}
+}
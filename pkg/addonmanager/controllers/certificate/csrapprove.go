@@ -26,8 +26,11 @@ import (
 	clusterlister "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 )
 
 var (
@@ -53,6 +56,7 @@ var (
 // csrApprovingController auto approve the renewal CertificateSigningRequests for an accepted spoke cluster on the hub.
 type csrApprovingController struct {
 	kubeClient                kubernetes.Interface
+	eventRecorder             utils.AddonEventRecorder
 	agentAddons               map[string]agent.AgentAddon
 	managedClusterLister      clusterlister.ManagedClusterLister
 	managedClusterAddonLister addonlisterv1alpha1.ManagedClusterAddOnLister
@@ -63,6 +67,7 @@ type csrApprovingController struct {
 // NewCSRApprovingController creates a new csr approving controller
 func NewCSRApprovingController(
 	kubeClient kubernetes.Interface,
+	eventRecorder utils.AddonEventRecorder,
 	clusterInformers clusterinformers.ManagedClusterInformer,
 	csrV1Informer certificatesinformers.CertificateSigningRequestInformer,
 	csrBetaInformer v1beta1certificatesinformers.CertificateSigningRequestInformer,
@@ -74,6 +79,7 @@ func NewCSRApprovingController(
 	}
 	c := &csrApprovingController{
 		kubeClient:                kubeClient,
+		eventRecorder:             eventRecorder,
 		agentAddons:               agentAddons,
 		managedClusterLister:      clusterInformers.Lister(),
 		managedClusterAddonLister: addonInformers.Lister(),
@@ -114,7 +120,7 @@ func NewCSRApprovingController(
 }
 
 func (c *csrApprovingController) sync(ctx context.Context, syncCtx factory.SyncContext, csrName string) error {
-	klog.V(4).Infof("Reconciling CertificateSigningRequests %q", csrName)
+	logging.FromContext(ctx).V(4).Info("Reconciling CertificateSigningRequest")
 
 	csr, err := c.getCSR(csrName)
 	if csr == nil {
@@ -142,6 +148,7 @@ func (c *csrApprovingController) sync(ctx context.Context, syncCtx factory.SyncC
 	if !ok {
 		return nil
 	}
+	ctx, logger := logging.WithAddon(ctx, clusterName, addonName)
 
 	// Get ManagedCluster
 	managedCluster, err := c.managedClusterLister.Get(clusterName)
@@ -162,15 +169,11 @@ func (c *csrApprovingController) sync(ctx context.Context, syncCtx factory.SyncC
 	}
 
 	if registrationOption.CSRApproveCheck == nil {
-		klog.V(4).Infof("addon csr %q cannont be auto approved due to approve check not defined", csr.GetName())
+		logger.V(4).Info("The addon csr cannot be auto approved due to approve check not defined")
 		return nil
 	}
 
-	if err := c.approve(ctx, registrationOption, managedCluster, managedClusterAddon, csr); err != nil {
-		return err
-	}
-
-	return nil
+	return c.approve(ctx, registrationOption, managedCluster, managedClusterAddon, csr)
 }
 
 func (c *csrApprovingController) getCSR(csrName string) (metav1.Object, error) {
@@ -208,29 +211,37 @@ func (c *csrApprovingController) approve(
 	case *certificatesv1.CertificateSigningRequest:
 		approve := registrationOption.CSRApproveCheck(managedCluster, managedClusterAddon, t)
 		if !approve {
-			klog.V(4).Infof("addon csr %q cannont be auto approved due to approve check fails", csr.GetName())
+			logging.FromContext(ctx).V(4).Info("The addon csr cannot be auto approved due to approve check fails")
 			return nil
 		}
-		return c.approveCSRV1(ctx, t)
+		if err := c.approveCSRV1(ctx, t); err != nil {
+			return err
+		}
 	// TODO: remove the following block for deprecating V1beta1 CSR compatibility
 	case *certificatesv1beta1.CertificateSigningRequest:
 		v1CSR := unsafeConvertV1beta1CSRToV1CSR(t)
 		approve := registrationOption.CSRApproveCheck(managedCluster, managedClusterAddon, v1CSR)
 		if !approve {
-			klog.V(4).Infof("addon csr %q cannont be auto approved due to approve check fails", csr.GetName())
+			logging.FromContext(ctx).V(4).Info("The addon csr cannot be auto approved due to approve check fails")
 			return nil
 		}
-		return c.approveCSRV1Beta1(ctx, t)
+		if err := c.approveCSRV1Beta1(ctx, t); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("unknown csr object type: %t", csr)
 	}
+
+	utils.RecordAddonEvent(c.eventRecorder, managedClusterAddon, corev1.EventTypeNormal, constants.EventReasonRegistrationApproved,
+		"the csr %s of the addon agent is approved", csr.GetName())
+	return nil
 }
 
 func (c *csrApprovingController) approveCSRV1(ctx context.Context, v1CSR *certificatesv1.CertificateSigningRequest) error {
 	v1CSR.Status.Conditions = append(v1CSR.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
 		Type:    certificatesv1.CertificateApproved,
 		Status:  corev1.ConditionTrue,
-		Reason:  "AutoApprovedByHubCSRApprovingController",
+		Reason:  constants.CSRReasonAutoApproved,
 		Message: "Auto approving addon agent certificate.",
 	})
 	_, err := c.kubeClient.CertificatesV1().CertificateSigningRequests().UpdateApproval(ctx, v1CSR.GetName(), v1CSR, metav1.UpdateOptions{})
@@ -244,7 +255,7 @@ func (c *csrApprovingController) approveCSRV1Beta1(ctx context.Context, v1beta1C
 	v1beta1CSR.Status.Conditions = append(v1beta1CSR.Status.Conditions, certificatesv1beta1.CertificateSigningRequestCondition{
 		Type:    certificatesv1beta1.CertificateApproved,
 		Status:  corev1.ConditionTrue,
-		Reason:  "AutoApprovedByHubCSRApprovingController",
+		Reason:  constants.CSRReasonAutoApproved,
 		Message: "Auto approving addon agent certificate.",
 	})
 	_, err := c.kubeClient.CertificatesV1beta1().CertificateSigningRequests().UpdateApproval(ctx, v1beta1CSR, metav1.UpdateOptions{})
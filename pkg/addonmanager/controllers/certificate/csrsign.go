@@ -13,7 +13,6 @@ import (
 	certificatesinformers "k8s.io/client-go/informers/certificates/v1"
 	"k8s.io/client-go/kubernetes"
 	certificateslisters "k8s.io/client-go/listers/certificates/v1"
-	"k8s.io/klog/v2"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
 	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
@@ -21,6 +20,7 @@ import (
 	clusterlister "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
 )
@@ -75,7 +75,7 @@ func NewCSRSignController(
 }
 
 func (c *csrSignController) sync(ctx context.Context, syncCtx factory.SyncContext, csrName string) error {
-	klog.V(4).Infof("Reconciling CertificateSigningRequests %q", csrName)
+	logging.FromContext(ctx).V(4).Info("Reconciling CertificateSigningRequest")
 	csr, err := c.csrLister.Get(csrName)
 	if errors.IsNotFound(err) {
 		return nil
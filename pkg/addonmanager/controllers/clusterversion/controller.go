@@ -0,0 +1,125 @@
+package clusterversion
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1"
+	clusterlister "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/hubdependency"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+)
+
+const (
+	controllerName = "addon-cluster-version-controller"
+
+	// kubeVersionClaimName is the name of the cluster claim reporting the kubernetes version of the cluster
+	kubeVersionClaimName = "kubeversion.open-cluster-management.io"
+)
+
+// clusterVersionController triggers the re-rendering of the addons on a managed cluster when the kubernetes
+// version of the cluster changes, so that the version gated manifests are re-evaluated after the upgrade.
+type clusterVersionController struct {
+	managedClusterLister      clusterlister.ManagedClusterLister
+	managedClusterAddonLister addonlisterv1alpha1.ManagedClusterAddOnLister
+	agentAddons               map[string]agent.AgentAddon
+	trigger                   hubdependency.TriggerFunc
+
+	// versions records the last observed kubernetes version of each cluster
+	versions map[string]string
+	lock     sync.Mutex
+}
+
+func NewClusterVersionController(
+	clusterInformers clusterinformers.ManagedClusterInformer,
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	agentAddons map[string]agent.AgentAddon,
+	trigger hubdependency.TriggerFunc,
+) factory.Controller {
+	c := &clusterVersionController{
+		managedClusterLister:      clusterInformers.Lister(),
+		managedClusterAddonLister: addonInformers.Lister(),
+		agentAddons:               agentAddons,
+		trigger:                   trigger,
+		versions:                  map[string]string{},
+	}
+
+	return factory.New().WithInformersQueueKeysFunc(
+		func(obj runtime.Object) []string {
+			accessor, _ := meta.Accessor(obj)
+			return []string{accessor.GetName()}
+		},
+		clusterInformers.Informer()).
+		WithBareInformers(addonInformers.Informer()).
+		WithSync(c.sync).ToController(controllerName)
+}
+
+func (c *clusterVersionController) sync(ctx context.Context, syncCtx factory.SyncContext, clusterName string) error {
+	cluster, err := c.managedClusterLister.Get(clusterName)
+	if errors.IsNotFound(err) {
+		c.lock.Lock()
+		delete(c.versions, clusterName)
+		c.lock.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	version := kubeVersion(cluster)
+	if len(version) == 0 {
+		return nil
+	}
+
+	c.lock.Lock()
+	lastVersion, observed := c.versions[clusterName]
+	c.versions[clusterName] = version
+	c.lock.Unlock()
+
+	// the addons are rendered with the current version when the cluster is observed the first time
+	if !observed || lastVersion == version {
+		return nil
+	}
+
+	addons, err := c.managedClusterAddonLister.ManagedClusterAddOns(clusterName).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	for _, addon := range addons {
+		if _, ok := c.agentAddons[addon.Name]; !ok {
+			continue
+		}
+
+		_, logger := logging.WithAddon(ctx, clusterName, addon.Name)
+		logger.V(4).Info("Kubernetes version of the cluster changed, triggering re-rendering",
+			"lastVersion", lastVersion, "version", version)
+		c.trigger(clusterName, addon.Name)
+	}
+	return nil
+}
+
+// kubeVersion returns the kubernetes version of the cluster from its status, or from the kubeversion
+// cluster claim if the status is not reported.
+func kubeVersion(cluster *clusterv1.ManagedCluster) string {
+	if len(cluster.Status.Version.Kubernetes) > 0 {
+		return cluster.Status.Version.Kubernetes
+	}
+
+	for _, claim := range cluster.Status.ClusterClaims {
+		if claim.Name == kubeVersionClaimName {
+			return claim.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,147 @@
+package clusterversion
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	fakecluster "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterv1informers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+)
+
+type testAgent struct {
+	name string
+}
+
+func (t *testAgent) Manifests(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) ([]runtime.Object, error) {
+	return nil, nil
+}
+
+func (t *testAgent) GetAgentAddonOptions() agent.AgentAddonOptions {
+	return agent.AgentAddonOptions{
+		AddonName: t.name,
+	}
+}
+
+func newCluster(name, version, claimVersion string) *clusterv1.ManagedCluster {
+	cluster := addontesting.NewManagedCluster(name)
+	cluster.Status.Version.Kubernetes = version
+	if len(claimVersion) > 0 {
+		cluster.Status.ClusterClaims = []clusterv1.ManagedClusterClaim{
+			{Name: kubeVersionClaimName, Value: claimVersion},
+		}
+	}
+	return cluster
+}
+
+func TestSync(t *testing.T) {
+	cases := []struct {
+		name              string
+		cluster           runtime.Object
+		versions          map[string]string
+		expectedTriggered []string
+		expectedVersions  map[string]string
+	}{
+		{
+			name:              "cluster is not found",
+			versions:          map[string]string{"cluster1": "v1.27.0"},
+			expectedTriggered: nil,
+			expectedVersions:  map[string]string{},
+		},
+		{
+			name:              "cluster is observed the first time",
+			cluster:           newCluster("cluster1", "v1.27.0", ""),
+			versions:          map[string]string{},
+			expectedTriggered: nil,
+			expectedVersions:  map[string]string{"cluster1": "v1.27.0"},
+		},
+		{
+			name:              "version is not changed",
+			cluster:           newCluster("cluster1", "v1.27.0", ""),
+			versions:          map[string]string{"cluster1": "v1.27.0"},
+			expectedTriggered: nil,
+			expectedVersions:  map[string]string{"cluster1": "v1.27.0"},
+		},
+		{
+			name:              "version is changed",
+			cluster:           newCluster("cluster1", "v1.28.0", ""),
+			versions:          map[string]string{"cluster1": "v1.27.0"},
+			expectedTriggered: []string{"cluster1/test"},
+			expectedVersions:  map[string]string{"cluster1": "v1.28.0"},
+		},
+		{
+			name:              "version claim is changed",
+			cluster:           newCluster("cluster1", "", "v1.28.0"),
+			versions:          map[string]string{"cluster1": "v1.27.0"},
+			expectedTriggered: []string{"cluster1/test"},
+			expectedVersions:  map[string]string{"cluster1": "v1.28.0"},
+		},
+		{
+			name:              "version is not reported",
+			cluster:           newCluster("cluster1", "", ""),
+			versions:          map[string]string{"cluster1": "v1.27.0"},
+			expectedTriggered: nil,
+			expectedVersions:  map[string]string{"cluster1": "v1.27.0"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var clusters []runtime.Object
+			if c.cluster != nil {
+				clusters = append(clusters, c.cluster)
+			}
+			addons := []runtime.Object{
+				addontesting.NewAddon("test", "cluster1"),
+				addontesting.NewAddon("other", "cluster1"),
+				addontesting.NewAddon("test", "cluster2"),
+			}
+
+			fakeClusterClient := fakecluster.NewSimpleClientset(clusters...)
+			fakeAddonClient := fakeaddon.NewSimpleClientset(addons...)
+			clusterInformers := clusterv1informers.NewSharedInformerFactory(fakeClusterClient, 10*time.Minute)
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+			for _, obj := range clusters {
+				if err := clusterInformers.Cluster().V1().ManagedClusters().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, obj := range addons {
+				if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var triggered []string
+			controller := &clusterVersionController{
+				managedClusterLister:      clusterInformers.Cluster().V1().ManagedClusters().Lister(),
+				managedClusterAddonLister: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				agentAddons:               map[string]agent.AgentAddon{"test": &testAgent{name: "test"}},
+				trigger: func(clusterName, addonName string) {
+					triggered = append(triggered, clusterName+"/"+addonName)
+				},
+				versions: c.versions,
+			}
+
+			err := controller.sync(context.TODO(), addontesting.NewFakeSyncContext(t), "cluster1")
+			if err != nil {
+				t.Errorf("expected no error when sync: %v", err)
+			}
+			if !reflect.DeepEqual(triggered, c.expectedTriggered) {
+				t.Errorf("expected triggered %v, but got %v", c.expectedTriggered, triggered)
+			}
+			if !reflect.DeepEqual(controller.versions, c.expectedVersions) {
+				t.Errorf("expected versions %v, but got %v", c.expectedVersions, controller.versions)
+			}
+		})
+	}
+}
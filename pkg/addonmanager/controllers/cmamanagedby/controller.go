@@ -0,0 +1,171 @@
+package cmamanagedby
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+)
+
+const controllerName = "cma-managed-by-controller"
+
+// cmaManagedByController creates the ClusterManagementAddOns of the addons, or adopts the existing ones, and
+// reconciles the drift of the lifecycle annotation, the addOnMeta and the supportedConfigs from the options of
+// the addons, so the addons are installed without applying their ClusterManagementAddOns out of band.
+type cmaManagedByController struct {
+	addonClient                  addonv1alpha1client.Interface
+	clusterManagementAddonLister addonlisterv1alpha1.ClusterManagementAddOnLister
+	agentAddons                  map[string]agent.AgentAddon
+}
+
+func NewCMAManagedByController(
+	addonClient addonv1alpha1client.Interface,
+	clusterManagementAddonInformers addoninformerv1alpha1.ClusterManagementAddOnInformer,
+	agentAddons map[string]agent.AgentAddon,
+) factory.Controller {
+	c := &cmaManagedByController{
+		addonClient:                  addonClient,
+		clusterManagementAddonLister: clusterManagementAddonInformers.Lister(),
+		agentAddons:                  agentAddons,
+	}
+
+	return factory.New().WithFilteredEventsInformersQueueKeysFunc(
+		func(obj runtime.Object) []string {
+			accessor, _ := meta.Accessor(obj)
+			return []string{accessor.GetName()}
+		},
+		func(obj interface{}) bool {
+			accessor, _ := meta.Accessor(obj)
+			_, ok := c.agentAddons[accessor.GetName()]
+			return ok
+		},
+		clusterManagementAddonInformers.Informer()).
+		WithSync(c.sync).ToController(controllerName)
+}
+
+func (c *cmaManagedByController) sync(ctx context.Context, syncCtx factory.SyncContext, addonName string) error {
+	agentAddon, ok := c.agentAddons[addonName]
+	if !ok {
+		return nil
+	}
+	options := agentAddon.GetAgentAddonOptions()
+	ctx, logger := logging.WithValues(ctx, logging.AddonKey, addonName)
+
+	cma, err := c.clusterManagementAddonLister.Get(addonName)
+	if errors.IsNotFound(err) {
+		cma = &addonapiv1alpha1.ClusterManagementAddOn{
+			ObjectMeta: metav1.ObjectMeta{Name: addonName},
+		}
+		desired := desiredClusterManagementAddOn(cma, options)
+		logger.Info("Creating the clustermanagementaddon")
+		_, err = c.addonClient.AddonV1alpha1().ClusterManagementAddOns().Create(ctx, desired, metav1.CreateOptions{})
+		if errors.IsAlreadyExists(err) {
+			// the cache is not synced yet, it is reconciled by the event of the existing one
+			return nil
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if !cma.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	return c.patchClusterManagementAddOn(ctx, desiredClusterManagementAddOn(cma, options), cma)
+}
+
+// desiredClusterManagementAddOn returns a copy of the ClusterManagementAddOn with the lifecycle annotation, the
+// addOnMeta and the supportedConfigs of the addon. The default configs of the supported configs are kept.
+func desiredClusterManagementAddOn(cma *addonapiv1alpha1.ClusterManagementAddOn,
+	options agent.AgentAddonOptions) *addonapiv1alpha1.ClusterManagementAddOn {
+	desired := cma.DeepCopy()
+	if desired.Annotations == nil {
+		desired.Annotations = map[string]string{}
+	}
+	desired.Annotations[addonapiv1alpha1.AddonLifecycleAnnotationKey] = addonapiv1alpha1.AddonLifecycleAddonManagerAnnotationValue
+
+	desired.Spec.AddOnMeta = addonapiv1alpha1.AddOnMeta{DisplayName: options.AddonName}
+	if options.AddOnMeta != nil {
+		desired.Spec.AddOnMeta.Description = options.AddOnMeta.Description
+		if len(options.AddOnMeta.DisplayName) > 0 {
+			desired.Spec.AddOnMeta.DisplayName = options.AddOnMeta.DisplayName
+		}
+	}
+
+	defaultConfigs := map[addonapiv1alpha1.ConfigGroupResource]*addonapiv1alpha1.ConfigReferent{}
+	for _, config := range cma.Spec.SupportedConfigs {
+		defaultConfigs[config.ConfigGroupResource] = config.DefaultConfig
+	}
+	var supportedConfigs []addonapiv1alpha1.ConfigMeta
+	for _, gvr := range options.SupportedConfigGVRs {
+		gr := addonapiv1alpha1.ConfigGroupResource{Group: gvr.Group, Resource: gvr.Resource}
+		supportedConfigs = append(supportedConfigs, addonapiv1alpha1.ConfigMeta{
+			ConfigGroupResource: gr,
+			DefaultConfig:       defaultConfigs[gr],
+		})
+	}
+	desired.Spec.SupportedConfigs = supportedConfigs
+	return desired
+}
+
+func (c *cmaManagedByController) patchClusterManagementAddOn(ctx context.Context, new, old *addonapiv1alpha1.ClusterManagementAddOn) error {
+	if equality.Semantic.DeepEqual(new.Annotations, old.Annotations) &&
+		equality.Semantic.DeepEqual(new.Spec.AddOnMeta, old.Spec.AddOnMeta) &&
+		equality.Semantic.DeepEqual(new.Spec.SupportedConfigs, old.Spec.SupportedConfigs) {
+		return nil
+	}
+
+	oldData, err := json.Marshal(&addonapiv1alpha1.ClusterManagementAddOn{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: old.Annotations,
+		},
+		Spec: addonapiv1alpha1.ClusterManagementAddOnSpec{
+			AddOnMeta:        old.Spec.AddOnMeta,
+			SupportedConfigs: old.Spec.SupportedConfigs,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	newData, err := json.Marshal(&addonapiv1alpha1.ClusterManagementAddOn{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:             new.UID,
+			ResourceVersion: new.ResourceVersion,
+			Annotations:     new.Annotations,
+		},
+		Spec: addonapiv1alpha1.ClusterManagementAddOnSpec{
+			AddOnMeta:        new.Spec.AddOnMeta,
+			SupportedConfigs: new.Spec.SupportedConfigs,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	patchBytes, err := jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return fmt.Errorf("failed to create patch for clustermanagementaddon %s: %w", new.Name, err)
+	}
+
+	logging.FromContext(ctx).V(2).Info("Patching the clustermanagementaddon", "patch", string(patchBytes))
+	_, err = c.addonClient.AddonV1alpha1().ClusterManagementAddOns().Patch(
+		ctx, new.Name, types.MergePatchType, patchBytes, metav1.PatchOptions{})
+	return err
+}
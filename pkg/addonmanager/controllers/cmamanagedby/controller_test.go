@@ -0,0 +1,157 @@
+package cmamanagedby
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clienttesting "k8s.io/client-go/testing"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+)
+
+type testAgent struct {
+	options agent.AgentAddonOptions
+}
+
+func (t *testAgent) Manifests(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) ([]runtime.Object, error) {
+	return nil, nil
+}
+
+func (t *testAgent) GetAgentAddonOptions() agent.AgentAddonOptions {
+	return t.options
+}
+
+var (
+	fooGVR = schema.GroupVersionResource{Group: "test", Version: "v1", Resource: "foos"}
+	fooGR  = addonapiv1alpha1.ConfigGroupResource{Group: "test", Resource: "foos"}
+	barGR  = addonapiv1alpha1.ConfigGroupResource{Group: "test", Resource: "bars"}
+)
+
+func newManagedCMA(name string, supportedConfigs ...addonapiv1alpha1.ConfigMeta) *addonapiv1alpha1.ClusterManagementAddOn {
+	cma := addontesting.NewClusterManagementAddon(name, "", "").WithSupportedConfigs(supportedConfigs...).Build()
+	cma.Annotations = map[string]string{
+		addonapiv1alpha1.AddonLifecycleAnnotationKey: addonapiv1alpha1.AddonLifecycleAddonManagerAnnotationValue,
+	}
+	cma.Spec.AddOnMeta = addonapiv1alpha1.AddOnMeta{DisplayName: "Test", Description: "test addon"}
+	return cma
+}
+
+func TestSync(t *testing.T) {
+	defaultConfig := &addonapiv1alpha1.ConfigReferent{Namespace: "default", Name: "test"}
+
+	cases := []struct {
+		name            string
+		addonName       string
+		cma             *addonapiv1alpha1.ClusterManagementAddOn
+		validateActions func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:      "unknown addon",
+			addonName: "other",
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertNoActions(t, actions)
+			},
+		},
+		{
+			name:      "create",
+			addonName: "test",
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "create")
+				cma := actions[0].(clienttesting.CreateActionImpl).Object.(*addonapiv1alpha1.ClusterManagementAddOn)
+				if !isManaged(cma) || cma.Spec.AddOnMeta.DisplayName != "Test" || len(cma.Spec.SupportedConfigs) != 1 ||
+					cma.Spec.SupportedConfigs[0].ConfigGroupResource != fooGR {
+					t.Errorf("unexpected clustermanagementaddon %v", cma)
+				}
+			},
+		},
+		{
+			name:      "adopt",
+			addonName: "test",
+			cma:       addontesting.NewClusterManagementAddon("test", "", "").Build(),
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				cma := &addonapiv1alpha1.ClusterManagementAddOn{}
+				if err := json.Unmarshal(actions[0].(clienttesting.PatchActionImpl).Patch, cma); err != nil {
+					t.Fatal(err)
+				}
+				if !isManaged(cma) || cma.Spec.AddOnMeta.DisplayName != "Test" {
+					t.Errorf("unexpected patch %v", cma)
+				}
+			},
+		},
+		{
+			name:      "supported configs drift",
+			addonName: "test",
+			cma: newManagedCMA("test",
+				addonapiv1alpha1.ConfigMeta{ConfigGroupResource: fooGR, DefaultConfig: defaultConfig},
+				addonapiv1alpha1.ConfigMeta{ConfigGroupResource: barGR}),
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				cma := &addonapiv1alpha1.ClusterManagementAddOn{}
+				if err := json.Unmarshal(actions[0].(clienttesting.PatchActionImpl).Patch, cma); err != nil {
+					t.Fatal(err)
+				}
+				if len(cma.Spec.SupportedConfigs) != 1 || cma.Spec.SupportedConfigs[0].DefaultConfig == nil ||
+					*cma.Spec.SupportedConfigs[0].DefaultConfig != *defaultConfig {
+					t.Errorf("expected the unsupported config removed and the default config kept, but got %v",
+						cma.Spec.SupportedConfigs)
+				}
+			},
+		},
+		{
+			name:      "no drift",
+			addonName: "test",
+			cma:       newManagedCMA("test", addonapiv1alpha1.ConfigMeta{ConfigGroupResource: fooGR, DefaultConfig: defaultConfig}),
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertNoActions(t, actions)
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var objs []runtime.Object
+			if c.cma != nil {
+				objs = append(objs, c.cma)
+			}
+			fakeAddonClient := fakeaddon.NewSimpleClientset(objs...)
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+			if c.cma != nil {
+				if err := addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().GetStore().Add(c.cma); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			controller := &cmaManagedByController{
+				addonClient:                  fakeAddonClient,
+				clusterManagementAddonLister: addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Lister(),
+				agentAddons: map[string]agent.AgentAddon{
+					"test": &testAgent{options: agent.AgentAddonOptions{
+						AddonName:           "test",
+						SupportedConfigGVRs: []schema.GroupVersionResource{fooGVR},
+						AddOnMeta:           &addonapiv1alpha1.AddOnMeta{DisplayName: "Test", Description: "test addon"},
+					}},
+				},
+			}
+
+			syncContext := addontesting.NewFakeSyncContext(t)
+			if err := controller.sync(context.TODO(), syncContext, c.addonName); err != nil {
+				t.Errorf("expected no error, but got %v", err)
+			}
+			c.validateActions(t, fakeAddonClient.Actions())
+		})
+	}
+}
+
+func isManaged(cma *addonapiv1alpha1.ClusterManagementAddOn) bool {
+	return cma.Annotations[addonapiv1alpha1.AddonLifecycleAnnotationKey] == addonapiv1alpha1.AddonLifecycleAddonManagerAnnotationValue
+}
@@ -0,0 +1,129 @@
+package hubdependency
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+)
+
+const controllerName = "addon-hub-dependency-controller"
+
+// TriggerFunc triggers the re-rendering of the addon on the cluster.
+type TriggerFunc func(clusterName, addonName string)
+
+// hubDependencyController watches the Secrets/ConfigMaps on the hub that the manifests of the addons
+// depend on, and triggers the re-rendering of the affected addons when these objects change.
+type hubDependencyController struct {
+	managedClusterAddonLister addonlisterv1alpha1.ManagedClusterAddOnLister
+	agentAddons               map[string]agent.AgentAddon
+	trigger                   TriggerFunc
+}
+
+// NewHubDependencyController returns a controller that triggers the re-rendering of addons when the
+// hub objects declared in the HubDependencies of the addons change. The secretInformer/configMapInformer
+// can be nil if no addon depends on Secrets/ConfigMaps.
+func NewHubDependencyController(
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	secretInformer coreinformers.SecretInformer,
+	configMapInformer coreinformers.ConfigMapInformer,
+	agentAddons map[string]agent.AgentAddon,
+	trigger TriggerFunc,
+) factory.Controller {
+	c := &hubDependencyController{
+		managedClusterAddonLister: addonInformers.Lister(),
+		agentAddons:               agentAddons,
+		trigger:                   trigger,
+	}
+
+	controllerFactory := factory.New().WithBareInformers(addonInformers.Informer())
+	if secretInformer != nil {
+		controllerFactory = controllerFactory.WithInformersQueueKeysFunc(
+			c.addonQueueKeysFunc(agent.HubDependencyKindSecret), secretInformer.Informer())
+	}
+	if configMapInformer != nil {
+		controllerFactory = controllerFactory.WithInformersQueueKeysFunc(
+			c.addonQueueKeysFunc(agent.HubDependencyKindConfigMap), configMapInformer.Informer())
+	}
+
+	return controllerFactory.WithSync(c.sync).ToController(controllerName)
+}
+
+// addonQueueKeysFunc returns the keys of the addons that depend on the hub object.
+func (c *hubDependencyController) addonQueueKeysFunc(kind agent.HubDependencyKind) factory.ObjectQueueKeysFunc {
+	return func(obj runtime.Object) []string {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return []string{}
+		}
+
+		keys := sets.New[string]()
+		for addonName, agentAddon := range c.agentAddons {
+			for _, dependency := range agentAddon.GetAgentAddonOptions().HubDependencies {
+				if dependency.Kind != kind || dependency.Name != accessor.GetName() {
+					continue
+				}
+
+				// the object is in the cluster namespace, only the addon on that cluster depends on it.
+				if len(dependency.Namespace) == 0 {
+					_, err := c.managedClusterAddonLister.ManagedClusterAddOns(accessor.GetNamespace()).Get(addonName)
+					if err == nil {
+						keys.Insert(fmt.Sprintf("%s/%s", accessor.GetNamespace(), addonName))
+					}
+					continue
+				}
+
+				if dependency.Namespace != accessor.GetNamespace() {
+					continue
+				}
+
+				addons, err := c.managedClusterAddonLister.List(labels.Everything())
+				if err != nil {
+					klog.Errorf("failed to list addons: %v", err)
+					continue
+				}
+				for _, addon := range addons {
+					if addon.Name == addonName {
+						keys.Insert(fmt.Sprintf("%s/%s", addon.Namespace, addon.Name))
+					}
+				}
+			}
+		}
+
+		return sets.List(keys)
+	}
+}
+
+func (c *hubDependencyController) sync(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+	clusterName, addonName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		// ignore addon whose key is not in format: namespace/name
+		return nil
+	}
+
+	_, err = c.managedClusterAddonLister.ManagedClusterAddOns(clusterName).Get(addonName)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, logger := logging.WithAddon(ctx, clusterName, addonName)
+	logger.V(4).Info("Hub dependency of the addon changed, triggering re-rendering")
+	c.trigger(clusterName, addonName)
+	return nil
+}
@@ -0,0 +1,179 @@
+package hubdependency
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+)
+
+type testAgent struct {
+	name         string
+	dependencies []agent.HubDependency
+}
+
+func (t *testAgent) Manifests(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) ([]runtime.Object, error) {
+	return nil, nil
+}
+
+func (t *testAgent) GetAgentAddonOptions() agent.AgentAddonOptions {
+	return agent.AgentAddonOptions{
+		AddonName:       t.name,
+		HubDependencies: t.dependencies,
+	}
+}
+
+func TestAddonQueueKeysFunc(t *testing.T) {
+	cases := []struct {
+		name         string
+		kind         agent.HubDependencyKind
+		object       runtime.Object
+		addons       []runtime.Object
+		expectedKeys []string
+	}{
+		{
+			name: "object is not a dependency",
+			kind: agent.HubDependencyKindSecret,
+			object: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "hub"},
+			},
+			addons:       []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			expectedKeys: []string{},
+		},
+		{
+			name: "kind mismatch",
+			kind: agent.HubDependencyKindConfigMap,
+			object: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "hub"},
+			},
+			addons:       []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			expectedKeys: []string{},
+		},
+		{
+			name: "dependency in a hub namespace",
+			kind: agent.HubDependencyKindSecret,
+			object: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "hub"},
+			},
+			addons: []runtime.Object{
+				addontesting.NewAddon("test", "cluster1"),
+				addontesting.NewAddon("test", "cluster2"),
+				addontesting.NewAddon("other", "cluster1"),
+			},
+			expectedKeys: []string{"cluster1/test", "cluster2/test"},
+		},
+		{
+			name: "dependency in the cluster namespace",
+			kind: agent.HubDependencyKindConfigMap,
+			object: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "values", Namespace: "cluster2"},
+			},
+			addons: []runtime.Object{
+				addontesting.NewAddon("test", "cluster1"),
+				addontesting.NewAddon("test", "cluster2"),
+			},
+			expectedKeys: []string{"cluster2/test"},
+		},
+		{
+			name: "dependency in the cluster namespace without addon",
+			kind: agent.HubDependencyKindConfigMap,
+			object: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "values", Namespace: "cluster3"},
+			},
+			addons: []runtime.Object{
+				addontesting.NewAddon("test", "cluster1"),
+			},
+			expectedKeys: []string{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeAddonClient := fakeaddon.NewSimpleClientset(c.addons...)
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+			for _, obj := range c.addons {
+				if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			controller := &hubDependencyController{
+				managedClusterAddonLister: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				agentAddons: map[string]agent.AgentAddon{
+					"test": &testAgent{name: "test", dependencies: []agent.HubDependency{
+						{Kind: agent.HubDependencyKindSecret, Namespace: "hub", Name: "ca"},
+						{Kind: agent.HubDependencyKindConfigMap, Name: "values"},
+					}},
+					"other": &testAgent{name: "other"},
+				},
+			}
+
+			keys := controller.addonQueueKeysFunc(c.kind)(c.object)
+			if !reflect.DeepEqual(keys, c.expectedKeys) {
+				t.Errorf("expected keys %v, but got %v", c.expectedKeys, keys)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	cases := []struct {
+		name              string
+		syncKey           string
+		addons            []runtime.Object
+		expectedTriggered []string
+	}{
+		{
+			name:              "addon is not found",
+			syncKey:           "cluster1/test",
+			addons:            []runtime.Object{},
+			expectedTriggered: nil,
+		},
+		{
+			name:              "trigger the addon",
+			syncKey:           "cluster1/test",
+			addons:            []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			expectedTriggered: []string{"cluster1/test"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeAddonClient := fakeaddon.NewSimpleClientset(c.addons...)
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+			for _, obj := range c.addons {
+				if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var triggered []string
+			controller := &hubDependencyController{
+				managedClusterAddonLister: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				agentAddons:               map[string]agent.AgentAddon{"test": &testAgent{name: "test"}},
+				trigger: func(clusterName, addonName string) {
+					triggered = append(triggered, clusterName+"/"+addonName)
+				},
+			}
+
+			err := controller.sync(context.TODO(), addontesting.NewFakeSyncContext(t), c.syncKey)
+			if err != nil {
+				t.Errorf("expected no error when sync: %v", err)
+			}
+			if !reflect.DeepEqual(triggered, c.expectedTriggered) {
+				t.Errorf("expected triggered %v, but got %v", c.expectedTriggered, triggered)
+			}
+		})
+	}
+}
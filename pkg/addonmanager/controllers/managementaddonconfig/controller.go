@@ -2,13 +2,13 @@ package managementaddonconfig
 
 import (
 	"context"
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 
 	jsonpatch "github.com/evanphx/json-patch"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -16,17 +16,19 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
-	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/dynamic/dynamiclister"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
-	"k8s.io/klog/v2"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
 	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
 	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
 
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
+	"open-cluster-management.io/addon-framework/pkg/agent"
 	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 )
 
 const (
@@ -42,14 +44,16 @@ type clusterManagementAddonConfigController struct {
 	clusterManagementAddonLister  addonlisterv1alpha1.ClusterManagementAddOnLister
 	clusterManagementAddonIndexer cache.Indexer
 	configListers                 map[schema.GroupResource]dynamiclister.Lister
+	specHashFuncs                 map[schema.GroupResource]agent.ConfigSpecHashFunc
 	queue                         workqueue.RateLimitingInterface
 }
 
 func NewManagementAddonConfigController(
 	addonClient addonv1alpha1client.Interface,
 	clusterManagementAddonInformers addoninformerv1alpha1.ClusterManagementAddOnInformer,
-	configInformerFactory dynamicinformer.DynamicSharedInformerFactory,
+	configInformers *utils.SharedConfigInformers,
 	configGVRs map[schema.GroupVersionResource]bool,
+	specHashFuncs map[schema.GroupVersionResource]agent.ConfigSpecHashFunc,
 ) factory.Controller {
 	syncCtx := factory.NewSyncContext(controllerName)
 
@@ -58,10 +62,15 @@ func NewManagementAddonConfigController(
 		clusterManagementAddonLister:  clusterManagementAddonInformers.Lister(),
 		clusterManagementAddonIndexer: clusterManagementAddonInformers.Informer().GetIndexer(),
 		configListers:                 map[schema.GroupResource]dynamiclister.Lister{},
+		specHashFuncs:                 map[schema.GroupResource]agent.ConfigSpecHashFunc{},
 		queue:                         syncCtx.Queue(),
 	}
 
-	configInformers := c.buildConfigInformers(configInformerFactory, configGVRs)
+	for gvr, specHashFunc := range specHashFuncs {
+		c.specHashFuncs[gvr.GroupResource()] = specHashFunc
+	}
+
+	informers := c.buildConfigInformers(configInformers, configGVRs)
 
 	if err := clusterManagementAddonInformers.Informer().AddIndexers(cache.Indexers{byClusterManagementAddOnConfig: c.indexByConfig}); err != nil {
 		utilruntime.HandleError(err)
@@ -73,18 +82,22 @@ func NewManagementAddonConfigController(
 			key, _ := cache.MetaNamespaceKeyFunc(obj)
 			return []string{key}
 		}, clusterManagementAddonInformers.Informer()).
-		WithBareInformers(configInformers...).
+		WithBareInformers(informers...).
 		WithSync(c.sync).ToController(controllerName)
 }
 
 func (c *clusterManagementAddonConfigController) buildConfigInformers(
-	configInformerFactory dynamicinformer.DynamicSharedInformerFactory,
+	configInformers *utils.SharedConfigInformers,
 	configGVRs map[schema.GroupVersionResource]bool,
 ) []factory.Informer {
-	configInformers := []factory.Informer{}
+	informers := []factory.Informer{}
 	for gvr := range configGVRs {
-		indexInformer := configInformerFactory.ForResource(gvr).Informer()
-		_, err := indexInformer.AddEventHandler(
+		indexInformer, err := configInformers.Acquire(gvr, nil)
+		if err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+		_, err = indexInformer.AddEventHandler(
 			cache.ResourceEventHandlerFuncs{
 				AddFunc: c.enqueueClusterManagementAddOnsByConfig(gvr),
 				UpdateFunc: func(oldObj, newObj interface{}) {
@@ -96,10 +109,10 @@ func (c *clusterManagementAddonConfigController) buildConfigInformers(
 		if err != nil {
 			utilruntime.HandleError(err)
 		}
-		configInformers = append(configInformers, indexInformer)
+		informers = append(informers, indexInformer)
 		c.configListers[schema.GroupResource{Group: gvr.Group, Resource: gvr.Resource}] = dynamiclister.New(indexInformer.GetIndexer(), gvr)
 	}
-	return configInformers
+	return informers
 }
 
 func (c *clusterManagementAddonConfigController) enqueueClusterManagementAddOnsByConfig(gvr schema.GroupVersionResource) enqueueFunc {
@@ -162,6 +175,7 @@ func (c *clusterManagementAddonConfigController) sync(ctx context.Context, syncC
 		// ignore addon whose key is invalid
 		return nil
 	}
+	ctx, _ = logging.WithValues(ctx, logging.AddonKey, addonName)
 
 	cma, err := c.clusterManagementAddonLister.Get(addonName)
 	if errors.IsNotFound(err) {
@@ -190,7 +204,7 @@ func (c *clusterManagementAddonConfigController) updateConfigSpecHash(cma *addon
 
 		specHash, err := c.getConfigSpecHash(defaultConfigReference.ConfigGroupResource, defaultConfigReference.DesiredConfig.ConfigReferent)
 		if err != nil {
-			return nil
+			return err
 		}
 		cma.Status.DefaultConfigReferences[i].DesiredConfig.SpecHash = specHash
 	}
@@ -203,7 +217,12 @@ func (c *clusterManagementAddonConfigController) updateConfigSpecHash(cma *addon
 
 			specHash, err := c.getConfigSpecHash(configReference.ConfigGroupResource, configReference.DesiredConfig.ConfigReferent)
 			if err != nil {
-				return nil
+				return err
+			}
+
+			// a new desired config restarts the rollout which is rolled back previously
+			if configReference.DesiredConfig.SpecHash != specHash {
+				meta.RemoveStatusCondition(&cma.Status.InstallProgressions[i].Conditions, constants.InstallProgressionConditionRolloutFailed)
 			}
 			cma.Status.InstallProgressions[i].ConfigReferences[j].DesiredConfig.SpecHash = specHash
 		}
@@ -247,7 +266,7 @@ func (c *clusterManagementAddonConfigController) patchConfigReferences(ctx conte
 		return fmt.Errorf("failed to create patch for addon %s: %w", new.Name, err)
 	}
 
-	klog.V(4).Infof("Patching addon %s/%s config reference with %s", new.Namespace, new.Name, string(patchBytes))
+	logging.FromContext(ctx).V(4).Info("Patching the config references", "patch", string(patchBytes))
 	_, err = c.addonClient.AddonV1alpha1().ClusterManagementAddOns().Patch(
 		ctx,
 		new.Name,
@@ -261,7 +280,8 @@ func (c *clusterManagementAddonConfigController) patchConfigReferences(ctx conte
 
 func (c *clusterManagementAddonConfigController) getConfigSpecHash(gr addonapiv1alpha1.ConfigGroupResource,
 	cr addonapiv1alpha1.ConfigReferent) (string, error) {
-	lister, ok := c.configListers[schema.GroupResource{Group: gr.Group, Resource: gr.Resource}]
+	groupResource := schema.GroupResource{Group: gr.Group, Resource: gr.Resource}
+	lister, ok := c.configListers[groupResource]
 	if !ok {
 		return "", nil
 	}
@@ -280,7 +300,7 @@ func (c *clusterManagementAddonConfigController) getConfigSpecHash(gr addonapiv1
 		return "", err
 	}
 
-	return GetSpecHash(config)
+	return utils.ConfigSpecHash(c.specHashFuncs, groupResource, config)
 }
 
 func getIndex(configGroupResource addonapiv1alpha1.ConfigGroupResource, configSpecHash addonapiv1alpha1.ConfigSpecHash) string {
@@ -291,18 +311,8 @@ func getIndex(configGroupResource addonapiv1alpha1.ConfigGroupResource, configSp
 	return fmt.Sprintf("%s/%s/%s", configGroupResource.Group, configGroupResource.Resource, configSpecHash.Name)
 }
 
+// GetSpecHash returns the sha256 hash of the spec field of an addon configuration.
+// Deprecated: use utils.GetSpecHash instead.
 func GetSpecHash(obj *unstructured.Unstructured) (string, error) {
-	spec, ok := obj.Object["spec"]
-	if !ok {
-		return "", fmt.Errorf("object has no spec field")
-	}
-
-	specBytes, err := json.Marshal(spec)
-	if err != nil {
-		return "", err
-	}
-
-	hash := sha256.Sum256(specBytes)
-
-	return fmt.Sprintf("%x", hash), nil
+	return utils.GetSpecHash(obj)
 }
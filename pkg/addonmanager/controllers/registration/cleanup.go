@@ -0,0 +1,225 @@
+package registration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	certificatesinformers "k8s.io/client-go/informers/certificates/v1"
+	"k8s.io/client-go/kubernetes"
+	certificateslisters "k8s.io/client-go/listers/certificates/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
+	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+// manifestsFinalizers are the finalizers of the deploy and the pre-delete hook manifestworks of the addon, the
+// registration of the addon is removed after all of them are removed.
+var manifestsFinalizers = sets.New[string](
+	addonapiv1alpha1.AddonPreDeleteHookFinalizer,
+	addonapiv1alpha1.AddonHostingPreDeleteHookFinalizer,
+	addonapiv1alpha1.AddonHostingManifestFinalizer,
+	addonapiv1alpha1.AddonDeprecatedPreDeleteHookFinalizer,
+	addonapiv1alpha1.AddonDeprecatedHostingPreDeleteHookFinalizer,
+	addonapiv1alpha1.AddonDeprecatedHostingManifestFinalizer,
+)
+
+// registrationCleanupController removes the registration of the deleting addons from the hub only after the deploy
+// manifestworks are removed and the pre-delete hook is completed, so the addon agent does not lose its hub access
+// before it is uninstalled. The CSRs of the addon agent are deleted, and the Roles, RoleBindings, client
+// certificates and the ServiceAccount of the token registration in the cluster namespace, which are owned by the
+// addon, are garbage collected once the finalizer is removed and the addon is gone.
+type registrationCleanupController struct {
+	kubeClient                kubernetes.Interface
+	addonClient               addonv1alpha1client.Interface
+	eventRecorder             utils.AddonEventRecorder
+	managedClusterAddonLister addonlisterv1alpha1.ManagedClusterAddOnLister
+	workLister                worklister.ManifestWorkLister
+	// csrLister is nil if the v1 CSR API is not served by the hub, the CSRs are kept there.
+	csrLister               certificateslisters.CertificateSigningRequestLister
+	agentAddons             map[string]agent.AgentAddon
+	manifestsCleanupTimeout time.Duration
+}
+
+// NewRegistrationCleanupController creates a new registration cleanup controller. The csrInformer is nil if the v1
+// CSR API is not served by the hub. The deleting addons wait for their deploy manifestworks to be removed in the
+// manifestsCleanupTimeout, or the default timeout if it is not positive.
+func NewRegistrationCleanupController(
+	kubeClient kubernetes.Interface,
+	addonClient addonv1alpha1client.Interface,
+	eventRecorder utils.AddonEventRecorder,
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	workInformers workinformers.ManifestWorkInformer,
+	csrInformer certificatesinformers.CertificateSigningRequestInformer,
+	agentAddons map[string]agent.AgentAddon,
+	manifestsCleanupTimeout time.Duration,
+) factory.Controller {
+	c := &registrationCleanupController{
+		kubeClient:                kubeClient,
+		addonClient:               addonClient,
+		eventRecorder:             eventRecorder,
+		managedClusterAddonLister: addonInformers.Lister(),
+		workLister:                workInformers.Lister(),
+		agentAddons:               agentAddons,
+		manifestsCleanupTimeout:   manifestsCleanupTimeout,
+	}
+	if csrInformer != nil {
+		c.csrLister = csrInformer.Lister()
+	}
+	return factory.New().
+		WithFilteredEventsInformersQueueKeysFunc(
+			func(obj runtime.Object) []string {
+				key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+				return []string{key}
+			},
+			func(obj interface{}) bool {
+				accessor, _ := meta.Accessor(obj)
+				return c.registrationEnabled(accessor.GetName())
+			},
+			addonInformers.Informer()).
+		WithFilteredEventsInformersQueueKeysFunc(
+			func(obj runtime.Object) []string {
+				accessor, _ := meta.Accessor(obj)
+				return []string{fmt.Sprintf("%s/%s", accessor.GetNamespace(), accessor.GetLabels()[addonapiv1alpha1.AddonLabelKey])}
+			},
+			func(obj interface{}) bool {
+				accessor, _ := meta.Accessor(obj)
+				// the deploy works of the addons in Hosted mode are in the hosting cluster namespace.
+				if _, ok := accessor.GetLabels()[addonapiv1alpha1.AddonNamespaceLabelKey]; ok {
+					return false
+				}
+				return c.registrationEnabled(accessor.GetLabels()[addonapiv1alpha1.AddonLabelKey])
+			},
+			workInformers.Informer()).
+		WithQueuePartitionFunc(factory.NamePartitionFunc).
+		WithSync(c.sync).
+		ToController("addon-registration-cleanup-controller")
+}
+
+// registrationEnabled returns whether the agent of the addon is registered to the hub.
+func (c *registrationCleanupController) registrationEnabled(addonName string) bool {
+	agentAddon, ok := c.agentAddons[addonName]
+	return ok && agentAddon.GetAgentAddonOptions().Registration != nil
+}
+
+func (c *registrationCleanupController) sync(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+	clusterName, addonName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		// ignore addon whose key is not in format: namespace/name
+		return nil
+	}
+	ctx, logger := logging.WithAddon(ctx, clusterName, addonName)
+
+	if !c.registrationEnabled(addonName) {
+		return nil
+	}
+
+	addon, err := c.managedClusterAddonLister.ManagedClusterAddOns(clusterName).Get(addonName)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if addon.DeletionTimestamp.IsZero() {
+		if sets.New[string](addon.Finalizers...).Has(constants.AddonRegistrationCleanupFinalizer) {
+			return nil
+		}
+		addonCopy := addon.DeepCopy()
+		addonCopy.Finalizers = append(addonCopy.Finalizers, constants.AddonRegistrationCleanupFinalizer)
+		_, err = c.addonClient.AddonV1alpha1().ManagedClusterAddOns(clusterName).Update(ctx, addonCopy, metav1.UpdateOptions{})
+		return err
+	}
+
+	if !sets.New[string](addon.Finalizers...).Has(constants.AddonRegistrationCleanupFinalizer) {
+		return nil
+	}
+
+	// the manifests finalizers are removed once the hosted deploy works are removed and the pre-delete hook is
+	// completed, the addon is queued again by the update.
+	if finalizers := manifestsFinalizers.Intersection(sets.New[string](addon.Finalizers...)); finalizers.Len() > 0 {
+		logger.V(4).Info("Waiting for the manifests of the addon to be removed", "finalizers", sets.List(finalizers))
+		return nil
+	}
+
+	works, err := c.workLister.ManifestWorks(clusterName).List(labels.SelectorFromSet(labels.Set{
+		addonapiv1alpha1.AddonLabelKey: addonName,
+	}))
+	if err != nil {
+		return err
+	}
+	if wait := utils.ManifestsCleanupWait(addon, works, c.manifestsCleanupTimeout); wait > 0 {
+		logger.V(4).Info("Waiting for the deploy manifestworks of the addon to be removed", "timeout", wait)
+		syncCtx.Queue().AddAfter(key, wait)
+		return nil
+	}
+
+	if err := c.removeRegistration(ctx, addon); err != nil {
+		return err
+	}
+	logger.Info("Removed the registration of the addon from the hub")
+	utils.RecordAddonEvent(c.eventRecorder, addon, corev1.EventTypeNormal, constants.EventReasonRegistrationRemoved,
+		"the registration of the addon is removed from the hub")
+
+	addonCopy := addon.DeepCopy()
+	addonCopy.Finalizers = nil
+	for _, finalizer := range addon.Finalizers {
+		if finalizer != constants.AddonRegistrationCleanupFinalizer {
+			addonCopy.Finalizers = append(addonCopy.Finalizers, finalizer)
+		}
+	}
+	_, err = c.addonClient.AddonV1alpha1().ManagedClusterAddOns(clusterName).Update(ctx, addonCopy, metav1.UpdateOptions{})
+	return err
+}
+
+// removeRegistration deletes the CSRs of the addon agent, which are not owned by the addon. The CSRs are kept if
+// the manager is not allowed to delete them, so the uninstall of the addon is not blocked.
+func (c *registrationCleanupController) removeRegistration(ctx context.Context,
+	addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+	if c.csrLister == nil {
+		return nil
+	}
+
+	csrs, err := c.csrLister.List(labels.SelectorFromSet(labels.Set{
+		addonapiv1alpha1.AddonLabelKey: addon.Name,
+		clusterv1.ClusterNameLabelKey:  addon.Namespace,
+	}))
+	if err != nil {
+		return err
+	}
+
+	logger := klog.FromContext(ctx)
+	var errs []error
+	for _, csr := range csrs {
+		err := c.kubeClient.CertificatesV1().CertificateSigningRequests().Delete(ctx, csr.Name, metav1.DeleteOptions{})
+		switch {
+		case errors.IsNotFound(err):
+		case errors.IsForbidden(err):
+			logger.Info("The manager is not allowed to delete the csr of the addon, it is kept", "csr", csr.Name)
+		case err != nil:
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
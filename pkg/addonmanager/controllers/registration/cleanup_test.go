@@ -0,0 +1,169 @@
+package registration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeinformers "k8s.io/client-go/informers"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	fakework "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+)
+
+func TestRegistrationCleanupReconcile(t *testing.T) {
+	newDeletingAddon := func(finalizers ...string) *addonapiv1alpha1.ManagedClusterAddOn {
+		addon := addontesting.NewAddon("test", "cluster1")
+		addon.UID = "uid"
+		return addontesting.SetAddonFinalizers(addontesting.SetAddonDeletionTimestamp(addon, time.Now()), finalizers...)
+	}
+	newCSR := func(name, clusterName string) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				addonapiv1alpha1.AddonLabelKey: "test",
+				clusterv1.ClusterNameLabelKey:  clusterName,
+			},
+		}}
+	}
+	csr := newCSR("addon-cluster1-test-abcde", "cluster1")
+	otherCSR := newCSR("addon-cluster2-test-abcde", "cluster2")
+	deployWork := addontesting.NewManifestWork("addon-test-deploy-0", "cluster1")
+	deployWork.SetLabels(map[string]string{addonapiv1alpha1.AddonLabelKey: "test"})
+
+	cases := []struct {
+		name                 string
+		addon                []runtime.Object
+		works                []runtime.Object
+		testAgent            *testAgent
+		forbidden            bool
+		expectedAddonActions []string
+		expectedKubeActions  []string
+		validateAddon        func(t *testing.T, addon *addonapiv1alpha1.ManagedClusterAddOn)
+	}{
+		{
+			name:      "no registration",
+			addon:     []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			testAgent: &testAgent{name: "test"},
+		},
+		{
+			name:                 "add finalizer",
+			addon:                []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			testAgent:            &testAgent{name: "test", registrations: []addonapiv1alpha1.RegistrationConfig{{}}},
+			expectedAddonActions: []string{"update"},
+			validateAddon: func(t *testing.T, addon *addonapiv1alpha1.ManagedClusterAddOn) {
+				if len(addon.Finalizers) != 1 || addon.Finalizers[0] != constants.AddonRegistrationCleanupFinalizer {
+					t.Errorf("expected the registration cleanup finalizer, but got %v", addon.Finalizers)
+				}
+			},
+		},
+		{
+			name: "wait for the pre-delete hook",
+			addon: []runtime.Object{newDeletingAddon(
+				addonapiv1alpha1.AddonPreDeleteHookFinalizer, constants.AddonRegistrationCleanupFinalizer)},
+			testAgent: &testAgent{name: "test", registrations: []addonapiv1alpha1.RegistrationConfig{{}}},
+		},
+		{
+			name:      "wait for the deploy works",
+			addon:     []runtime.Object{newDeletingAddon(constants.AddonRegistrationCleanupFinalizer)},
+			works:     []runtime.Object{deployWork},
+			testAgent: &testAgent{name: "test", registrations: []addonapiv1alpha1.RegistrationConfig{{}}},
+		},
+		{
+			name:                 "remove registration",
+			addon:                []runtime.Object{newDeletingAddon(constants.AddonRegistrationCleanupFinalizer)},
+			testAgent:            &testAgent{name: "test", registrations: []addonapiv1alpha1.RegistrationConfig{{}}},
+			expectedAddonActions: []string{"update"},
+			expectedKubeActions:  []string{"delete"},
+			validateAddon: func(t *testing.T, addon *addonapiv1alpha1.ManagedClusterAddOn) {
+				if len(addon.Finalizers) != 0 {
+					t.Errorf("expected the registration cleanup finalizer removed, but got %v", addon.Finalizers)
+				}
+			},
+		},
+		{
+			name:                 "keep the csrs if the deletion is forbidden",
+			addon:                []runtime.Object{newDeletingAddon(constants.AddonRegistrationCleanupFinalizer)},
+			testAgent:            &testAgent{name: "test", registrations: []addonapiv1alpha1.RegistrationConfig{{}}},
+			forbidden:            true,
+			expectedAddonActions: []string{"update"},
+			expectedKubeActions:  []string{"delete"},
+			validateAddon: func(t *testing.T, addon *addonapiv1alpha1.ManagedClusterAddOn) {
+				if len(addon.Finalizers) != 0 {
+					t.Errorf("expected the registration cleanup finalizer removed, but got %v", addon.Finalizers)
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeKubeClient := fakekube.NewSimpleClientset(csr, otherCSR)
+			if c.forbidden {
+				fakeKubeClient.PrependReactor("delete", "certificatesigningrequests",
+					func(action clienttesting.Action) (bool, runtime.Object, error) {
+						return true, nil, errors.NewForbidden(certificatesv1.Resource("certificatesigningrequests"), csr.Name, nil)
+					})
+			}
+			kubeInformers := kubeinformers.NewSharedInformerFactory(fakeKubeClient, 10*time.Minute)
+			for _, obj := range []runtime.Object{csr, otherCSR} {
+				if err := kubeInformers.Certificates().V1().CertificateSigningRequests().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+			fakeAddonClient := fakeaddon.NewSimpleClientset(c.addon...)
+			fakeWorkClient := fakework.NewSimpleClientset(c.works...)
+
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+			workInformers := workinformers.NewSharedInformerFactory(fakeWorkClient, 10*time.Minute)
+			for _, obj := range c.addon {
+				if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, obj := range c.works {
+				if err := workInformers.Work().V1().ManifestWorks().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			controller := &registrationCleanupController{
+				kubeClient:                fakeKubeClient,
+				addonClient:               fakeAddonClient,
+				managedClusterAddonLister: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				workLister:                workInformers.Work().V1().ManifestWorks().Lister(),
+				csrLister:                 kubeInformers.Certificates().V1().CertificateSigningRequests().Lister(),
+				agentAddons:               map[string]agent.AgentAddon{"test": c.testAgent},
+			}
+
+			err := controller.sync(context.TODO(), addontesting.NewFakeSyncContext(t), "cluster1/test")
+			if err != nil {
+				t.Errorf("expected no error when sync: %v", err)
+			}
+			addontesting.AssertActions(t, fakeAddonClient.Actions(), c.expectedAddonActions...)
+			addontesting.AssertActions(t, fakeKubeClient.Actions(), c.expectedKubeActions...)
+			if c.validateAddon != nil {
+				actions := fakeAddonClient.Actions()
+				addon := actions[len(actions)-1].(clienttesting.UpdateActionImpl).Object.(*addonapiv1alpha1.ManagedClusterAddOn)
+				c.validateAddon(t, addon)
+			}
+			if _, err := fakeKubeClient.CertificatesV1().CertificateSigningRequests().Get(
+				context.TODO(), otherCSR.Name, metav1.GetOptions{}); err != nil {
+				t.Errorf("expected the csr of the addon on the other cluster kept: %v", err)
+			}
+		})
+	}
+}
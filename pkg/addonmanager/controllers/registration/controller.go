@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
 	"open-cluster-management.io/addon-framework/pkg/utils"
 
 	jsonpatch "github.com/evanphx/json-patch"
+	certificatesv1 "k8s.io/api/certificates/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -15,7 +17,6 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/klog/v2"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
 	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
@@ -23,6 +24,7 @@ import (
 	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1"
 	clusterlister "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
 
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
 )
@@ -62,17 +64,18 @@ func NewAddonConfigurationController(
 			return true
 		},
 		addonInformers.Informer()).
+		WithQueuePartitionFunc(factory.NamePartitionFunc).
 		WithSync(c.sync).ToController("addon-registration-controller")
 }
 
 func (c *addonConfigurationController) sync(ctx context.Context, syncCtx factory.SyncContext, key string) error {
-	klog.V(4).Infof("Reconciling addon registration %q", key)
-
 	clusterName, addonName, err := cache.SplitMetaNamespaceKey(key)
 	if err != nil {
 		// ignore addon whose key is not in format: namespace/name
 		return nil
 	}
+	ctx, logger := logging.WithAddon(ctx, clusterName, addonName)
+	logger.V(4).Info("Reconciling addon registration")
 
 	agentAddon, ok := c.agentAddons[addonName]
 	if !ok {
@@ -96,6 +99,12 @@ func (c *addonConfigurationController) sync(ctx context.Context, syncCtx factory
 		return err
 	}
 
+	// the registration of the deleting addon is not re-applied, it is removed by the registration cleanup
+	// controller after the agent is uninstalled.
+	if !managedClusterAddon.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
 	managedClusterAddonCopy := managedClusterAddon.DeepCopy()
 
 	// wait until the mca's ownerref is set.
@@ -115,9 +124,9 @@ func (c *addonConfigurationController) sync(ctx context.Context, syncCtx factory
 	registrationOption := agentAddon.GetAgentAddonOptions().Registration
 	if registrationOption == nil {
 		meta.SetStatusCondition(&managedClusterAddonCopy.Status.Conditions, metav1.Condition{
-			Type:    "RegistrationApplied",
+			Type:    constants.AddonRegistrationApplied,
 			Status:  metav1.ConditionTrue,
-			Reason:  "NilRegistration",
+			Reason:  constants.RegistrationReasonNilRegistration,
 			Message: "Registration of the addon agent is configured",
 		})
 		return c.patchAddonStatus(ctx, managedClusterAddonCopy, managedClusterAddon)
@@ -127,9 +136,9 @@ func (c *addonConfigurationController) sync(ctx context.Context, syncCtx factory
 		err = registrationOption.PermissionConfig(managedCluster, managedClusterAddon)
 		if err != nil {
 			meta.SetStatusCondition(&managedClusterAddonCopy.Status.Conditions, metav1.Condition{
-				Type:    "RegistrationApplied",
+				Type:    constants.AddonRegistrationApplied,
 				Status:  metav1.ConditionFalse,
-				Reason:  "SetPermissionFailed",
+				Reason:  constants.RegistrationReasonSetPermissionFailed,
 				Message: fmt.Sprintf("Failed to set permission for hub agent: %v", err),
 			})
 			if patchErr := c.patchAddonStatus(ctx, managedClusterAddonCopy, managedClusterAddon); patchErr != nil {
@@ -139,16 +148,23 @@ func (c *addonConfigurationController) sync(ctx context.Context, syncCtx factory
 		}
 	}
 
-	if registrationOption.CSRConfigurations == nil {
+	if registrationOption.CSRConfigurations == nil && registrationOption.TokenRegistration == nil {
 		meta.SetStatusCondition(&managedClusterAddonCopy.Status.Conditions, metav1.Condition{
-			Type:    "RegistrationApplied",
+			Type:    constants.AddonRegistrationApplied,
 			Status:  metav1.ConditionTrue,
-			Reason:  "NilRegistration",
+			Reason:  constants.RegistrationReasonNilRegistration,
 			Message: "Registration of the addon agent is configured",
 		})
 		return c.patchAddonStatus(ctx, managedClusterAddonCopy, managedClusterAddon)
 	}
-	configs := registrationOption.CSRConfigurations(managedCluster)
+	var configs []addonapiv1alpha1.RegistrationConfig
+	if registrationOption.CSRConfigurations != nil {
+		configs = registrationOption.CSRConfigurations(managedCluster)
+	}
+	// the hub kubeconfig of the agent registered with a token is provisioned by the hub instead of the klusterlet.
+	if registrationOption.TokenRegistration != nil {
+		configs = withoutKubeAPIServerClientSigner(configs)
+	}
 
 	managedClusterAddonCopy.Status.Registrations = configs
 
@@ -157,9 +173,41 @@ func (c *addonConfigurationController) sync(ctx context.Context, syncCtx factory
 		managedClusterAddonCopy.Status.Namespace = managedClusterAddonCopy.Spec.InstallNamespace
 	}
 
+	// the hub kubeconfig secret and the lease of the agent are in the agent install namespace.
+	if nsFunc := agentAddon.GetAgentAddonOptions().AgentInstallNamespace; nsFunc != nil {
+		namespace, err := nsFunc(managedClusterAddonCopy)
+		if err != nil {
+			meta.SetStatusCondition(&managedClusterAddonCopy.Status.Conditions, metav1.Condition{
+				Type:    constants.AddonRegistrationApplied,
+				Status:  metav1.ConditionFalse,
+				Reason:  constants.RegistrationReasonGetInstallNamespaceFailed,
+				Message: fmt.Sprintf("Failed to get the agent install namespace: %v", err),
+			})
+			if patchErr := c.patchAddonStatus(ctx, managedClusterAddonCopy, managedClusterAddon); patchErr != nil {
+				return patchErr
+			}
+			return err
+		}
+		if len(namespace) > 0 {
+			managedClusterAddonCopy.Status.Namespace = namespace
+		}
+	}
+
 	return c.patchAddonStatus(ctx, managedClusterAddonCopy, managedClusterAddon)
 }
 
+// withoutKubeAPIServerClientSigner removes the registrations of the kube-apiserver-client signer.
+func withoutKubeAPIServerClientSigner(configs []addonapiv1alpha1.RegistrationConfig) []addonapiv1alpha1.RegistrationConfig {
+	var filtered []addonapiv1alpha1.RegistrationConfig
+	for _, config := range configs {
+		if config.SignerName == certificatesv1.KubeAPIServerClientSignerName {
+			continue
+		}
+		filtered = append(filtered, config)
+	}
+	return filtered
+}
+
 func (c *addonConfigurationController) patchAddonStatus(ctx context.Context, new, old *addonapiv1alpha1.ManagedClusterAddOn) error {
 	if equality.Semantic.DeepEqual(new.Status.Registrations, old.Status.Registrations) &&
 		equality.Semantic.DeepEqual(new.Status.Conditions, old.Status.Conditions) &&
@@ -201,7 +249,7 @@ func (c *addonConfigurationController) patchAddonStatus(ctx context.Context, new
 		return fmt.Errorf("failed to create patch for addon %s: %w", new.Name, err)
 	}
 
-	klog.V(2).Infof("Patching addon %s/%s status with %s", new.Namespace, new.Name, string(patchBytes))
+	logging.FromContext(ctx).V(2).Info("Patching the addon status", "patch", string(patchBytes))
 	_, err = c.addonClient.AddonV1alpha1().ManagedClusterAddOns(new.Namespace).Patch(
 		ctx, new.Name, types.MergePatchType, patchBytes, metav1.PatchOptions{}, "status")
 	return err
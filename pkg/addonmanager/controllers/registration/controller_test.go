@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	certificatesv1 "k8s.io/api/certificates/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clienttesting "k8s.io/client-go/testing"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
@@ -22,9 +23,11 @@ import (
 )
 
 type testAgent struct {
-	name          string
-	namespace     string
-	registrations []addonapiv1alpha1.RegistrationConfig
+	name                  string
+	namespace             string
+	agentInstallNamespace string
+	registrations         []addonapiv1alpha1.RegistrationConfig
+	tokenRegistration     *agent.TokenRegistration
 }
 
 func (t *testAgent) Manifests(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) ([]runtime.Object, error) {
@@ -37,7 +40,7 @@ func (t *testAgent) GetAgentAddonOptions() agent.AgentAddonOptions {
 			AddonName: t.name,
 		}
 	}
-	return agent.AgentAddonOptions{
+	options := agent.AgentAddonOptions{
 		AddonName: t.name,
 		Registration: &agent.RegistrationOption{
 			CSRConfigurations: func(cluster *clusterv1.ManagedCluster) []addonapiv1alpha1.RegistrationConfig {
@@ -46,9 +49,16 @@ func (t *testAgent) GetAgentAddonOptions() agent.AgentAddonOptions {
 			PermissionConfig: func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
 				return nil
 			},
-			Namespace: t.namespace,
+			Namespace:         t.namespace,
+			TokenRegistration: t.tokenRegistration,
 		},
 	}
+	if len(t.agentInstallNamespace) > 0 {
+		options.AgentInstallNamespace = func(addon *addonapiv1alpha1.ManagedClusterAddOn) (string, error) {
+			return t.agentInstallNamespace, nil
+		}
+	}
+	return options
 }
 
 func TestReconcile(t *testing.T) {
@@ -175,6 +185,73 @@ func TestReconcile(t *testing.T) {
 				},
 			}},
 		},
+		{
+			name:    "with token registration",
+			cluster: []runtime.Object{addontesting.NewManagedCluster("cluster1")},
+			addon: []runtime.Object{
+				addontesting.NewAddon("test", "cluster1", metav1.OwnerReference{
+					Kind: "ClusterManagementAddOn",
+					Name: "test",
+				}),
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				actual := actions[0].(clienttesting.PatchActionImpl).Patch
+				addOn := &addonapiv1alpha1.ManagedClusterAddOn{}
+				err := json.Unmarshal(actual, addOn)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(addOn.Status.Registrations) != 1 || addOn.Status.Registrations[0].SignerName != "test" {
+					t.Errorf("expected the registration of the kube-apiserver-client signer removed, got %s", string(actual))
+				}
+				if addOn.Status.Namespace != "default" {
+					t.Errorf("Namespace in status is not correct")
+				}
+			},
+			testaddon: &testAgent{name: "test", namespace: "default",
+				tokenRegistration: &agent.TokenRegistration{Server: "https://hub:6443"},
+				registrations: []addonapiv1alpha1.RegistrationConfig{
+					{
+						SignerName: certificatesv1.KubeAPIServerClientSignerName,
+					},
+					{
+						SignerName: "test",
+					},
+				}},
+		},
+		{
+			name:    "with registrations and agent install namespace",
+			cluster: []runtime.Object{addontesting.NewManagedCluster("cluster1")},
+			addon: []runtime.Object{
+				func() *addonapiv1alpha1.ManagedClusterAddOn {
+					addon := addontesting.NewAddon("test", "cluster1", metav1.OwnerReference{
+						Kind: "ClusterManagementAddOn",
+						Name: "test",
+					})
+					addon.Spec.InstallNamespace = "default2"
+					return addon
+				}(),
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				actual := actions[0].(clienttesting.PatchActionImpl).Patch
+				addOn := &addonapiv1alpha1.ManagedClusterAddOn{}
+				err := json.Unmarshal(actual, addOn)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if addOn.Status.Namespace != "default3" {
+					t.Errorf("Namespace in status is not correct")
+				}
+			},
+			testaddon: &testAgent{name: "test", namespace: "default", agentInstallNamespace: "default3",
+				registrations: []addonapiv1alpha1.RegistrationConfig{
+					{
+						SignerName: "test",
+					},
+				}},
+		},
 	}
 
 	for _, c := range cases {
@@ -0,0 +1,397 @@
+package registration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/logging"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/hubconfig"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+const (
+	// defaultTokenExpiration is the lifetime of the tokens if the Expiration of the token registration is not set.
+	defaultTokenExpiration = 24 * time.Hour
+
+	// defaultTokenRenewalRatio is the ratio of the token lifetime after which the token is renewed if the
+	// RenewBefore is not set.
+	defaultTokenRenewalRatio = 0.8
+
+	// minTokenRenewalInterval is the minimum interval between the issue and the renewal of a token, so a token
+	// issued with a lifetime shorter than requested is not renewed in a hot loop.
+	minTokenRenewalInterval = time.Minute
+)
+
+// tokenRegistrationController provisions the ServiceAccounts and the hub kubeconfigs with the ServiceAccount
+// tokens of the addon agents registered with tokens, and renews the tokens before they expire. The hub
+// kubeconfigs are kept in the managed cluster namespaces, and only the klusterlets of the managed clusters are
+// granted to read them.
+type tokenRegistrationController struct {
+	kubeClient                kubernetes.Interface
+	agentAddons               map[string]agent.AgentAddon
+	managedClusterAddonLister addonlisterv1alpha1.ManagedClusterAddOnLister
+	secretLister              corelisters.SecretLister
+	// defaultCABundle is the CA bundle of the hub kubeconfigs if the CABundle of the token registration is not set.
+	defaultCABundle []byte
+}
+
+// NewTokenRegistrationController creates a new token registration controller
+func NewTokenRegistrationController(
+	kubeClient kubernetes.Interface,
+	secretInformer coreinformers.SecretInformer,
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	agentAddons map[string]agent.AgentAddon,
+	defaultCABundle []byte,
+) factory.Controller {
+	c := &tokenRegistrationController{
+		kubeClient:                kubeClient,
+		agentAddons:               agentAddons,
+		managedClusterAddonLister: addonInformers.Lister(),
+		secretLister:              secretInformer.Lister(),
+		defaultCABundle:           defaultCABundle,
+	}
+	return factory.New().
+		WithFilteredEventsInformersQueueKeysFunc(
+			func(obj runtime.Object) []string {
+				key, _ := cache.MetaNamespaceKeyFunc(obj)
+				return []string{key}
+			},
+			func(obj interface{}) bool {
+				addon, ok := obj.(*addonapiv1alpha1.ManagedClusterAddOn)
+				if !ok {
+					return false
+				}
+				return TokenRegistrationEnabled(c.agentAddons[addon.Name])
+			},
+			addonInformers.Informer()).
+		WithFilteredEventsInformersQueueKeysFunc(
+			func(obj runtime.Object) []string {
+				secret := obj.(*corev1.Secret)
+				return []string{fmt.Sprintf("%s/%s", secret.Namespace, secret.Labels[addonapiv1alpha1.AddonLabelKey])}
+			},
+			func(obj interface{}) bool {
+				secret, ok := obj.(*corev1.Secret)
+				if !ok {
+					return false
+				}
+				addonName := secret.Labels[addonapiv1alpha1.AddonLabelKey]
+				return secret.Name == constants.TokenKubeConfigSecretName(addonName) &&
+					TokenRegistrationEnabled(c.agentAddons[addonName])
+			},
+			secretInformer.Informer()).
+		WithSync(c.sync).
+		ToController("TokenRegistrationController")
+}
+
+// TokenRegistrationEnabled returns whether the agent of the addon is registered with a ServiceAccount token.
+func TokenRegistrationEnabled(agentAddon agent.AgentAddon) bool {
+	if agentAddon == nil {
+		return false
+	}
+	registrationOption := agentAddon.GetAgentAddonOptions().Registration
+	return registrationOption != nil && registrationOption.TokenRegistration != nil
+}
+
+func (c *tokenRegistrationController) sync(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+	clusterName, addonName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		// ignore addon whose key is not in format: namespace/name
+		return nil
+	}
+	ctx, logger := logging.WithAddon(ctx, clusterName, addonName)
+	logger.V(4).Info("Reconciling token registration")
+
+	agentAddon := c.agentAddons[addonName]
+	if !TokenRegistrationEnabled(agentAddon) {
+		return nil
+	}
+	tokenRegistration := agentAddon.GetAgentAddonOptions().Registration.TokenRegistration
+
+	addon, err := c.managedClusterAddonLister.ManagedClusterAddOns(clusterName).Get(addonName)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !addon.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	if err := c.ensureServiceAccount(ctx, addon); err != nil {
+		return err
+	}
+	if err := c.ensureKubeConfigReader(ctx, addon); err != nil {
+		return err
+	}
+
+	renewalTime, err := c.ensureKubeConfig(ctx, addon, tokenRegistration)
+	if err != nil {
+		return err
+	}
+	requeueAfter := time.Until(renewalTime)
+	if requeueAfter < minTokenRenewalInterval {
+		requeueAfter = minTokenRenewalInterval
+	}
+	syncCtx.Queue().AddAfter(key, requeueAfter)
+	return nil
+}
+
+// ensureServiceAccount creates the ServiceAccount of the addon agent in the managed cluster namespace.
+func (c *tokenRegistrationController) ensureServiceAccount(ctx context.Context,
+	addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+	name := constants.TokenServiceAccountName(addon.Name)
+	_, err := c.kubeClient.CoreV1().ServiceAccounts(addon.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	owner := metav1.NewControllerRef(addon, addonapiv1alpha1.GroupVersion.WithKind("ManagedClusterAddOn"))
+	_, err = c.kubeClient.CoreV1().ServiceAccounts(addon.Namespace).Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: addon.Namespace,
+			Labels: map[string]string{
+				addonapiv1alpha1.AddonLabelKey: addon.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{*owner},
+		},
+	}, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// ensureKubeConfigReader grants the klusterlet of the managed cluster to read the hub kubeconfig secret of the
+// addon, so the klusterlet delivers it to the registration namespace of the agent.
+func (c *tokenRegistrationController) ensureKubeConfigReader(ctx context.Context,
+	addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+	name := constants.TokenKubeConfigReaderName(addon.Name)
+	owner := metav1.NewControllerRef(addon, addonapiv1alpha1.GroupVersion.WithKind("ManagedClusterAddOn"))
+	labels := map[string]string{addonapiv1alpha1.AddonLabelKey: addon.Name}
+
+	_, _, err := utils.ApplyRole(ctx, c.kubeClient.RbacV1(), &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       addon.Namespace,
+			Labels:          labels,
+			OwnerReferences: []metav1.OwnerReference{*owner},
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups:     []string{""},
+				Resources:     []string{"secrets"},
+				ResourceNames: []string{constants.TokenKubeConfigSecretName(addon.Name)},
+				Verbs:         []string{"get", "list", "watch"},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, _, err = utils.ApplyRoleBinding(ctx, c.kubeClient.RbacV1(), &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       addon.Namespace,
+			Labels:          labels,
+			OwnerReferences: []metav1.OwnerReference{*owner},
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: "Role",
+			Name: name,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind: rbacv1.GroupKind,
+				Name: constants.KlusterletGroup(addon.Namespace),
+			},
+		},
+	})
+	return err
+}
+
+// ensureKubeConfig requests a new token and writes the hub kubeconfig with it if the kubeconfig does not exist,
+// is out of date or needs to be renewed, and returns the time to renew the token.
+func (c *tokenRegistrationController) ensureKubeConfig(ctx context.Context,
+	addon *addonapiv1alpha1.ManagedClusterAddOn, tokenRegistration *agent.TokenRegistration) (time.Time, error) {
+	caBundle := tokenRegistration.CABundle
+	if len(caBundle) == 0 {
+		caBundle = c.defaultCABundle
+	}
+
+	secretName := constants.TokenKubeConfigSecretName(addon.Name)
+	secret, err := c.secretLister.Secrets(addon.Namespace).Get(secretName)
+	switch {
+	case errors.IsNotFound(err):
+		secret = nil
+	case err != nil:
+		return time.Time{}, err
+	}
+
+	logger := logging.FromContext(ctx).WithValues("secret", klog.KRef(addon.Namespace, secretName))
+	if secret != nil {
+		expiration, err := time.Parse(time.RFC3339, secret.Annotations[constants.TokenExpirationAnnotationKey])
+		switch {
+		case err != nil:
+			logger.Info("Invalid token expiration, requesting a new token", "err", err)
+		case !kubeConfigUpToDate(secret.Data[hubconfig.KubeconfigKey], tokenRegistration.Server, caBundle):
+			logger.Info("The hub of the kubeconfig is changed, requesting a new token")
+		default:
+			issued, err := time.Parse(time.RFC3339, secret.Annotations[constants.TokenIssuedAnnotationKey])
+			if err != nil {
+				// the secret written before the issue time is recorded.
+				issued = expiration.Add(-tokenExpiration(tokenRegistration))
+			}
+			renewalTime := tokenRenewalTime(issued, expiration, tokenRegistration)
+			if time.Now().Before(renewalTime) {
+				return renewalTime, nil
+			}
+		}
+	}
+
+	expirationSeconds := int64(tokenExpiration(tokenRegistration).Seconds())
+	issued := time.Now()
+	tokenRequest, err := c.kubeClient.CoreV1().ServiceAccounts(addon.Namespace).CreateToken(ctx,
+		constants.TokenServiceAccountName(addon.Name), &authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &expirationSeconds},
+		}, metav1.CreateOptions{})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to request the token of addon %s/%s: %w", addon.Namespace, addon.Name, err)
+	}
+	// the lifetime of the issued token may be shorter than requested, e.g. it is capped by the
+	// service-account-max-token-expiration of the kube-apiserver.
+	expiration := tokenRequest.Status.ExpirationTimestamp.Time
+	if expiration.IsZero() {
+		expiration = issued.Add(time.Duration(expirationSeconds) * time.Second)
+	}
+
+	kubeConfig, err := buildTokenKubeConfig(tokenRegistration.Server, caBundle, tokenRequest.Status.Token)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	owner := metav1.NewControllerRef(addon, addonapiv1alpha1.GroupVersion.WithKind("ManagedClusterAddOn"))
+	required := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: addon.Namespace,
+			Labels: map[string]string{
+				addonapiv1alpha1.AddonLabelKey: addon.Name,
+			},
+			Annotations: map[string]string{
+				constants.TokenExpirationAnnotationKey: expiration.UTC().Format(time.RFC3339),
+				constants.TokenIssuedAnnotationKey:     issued.UTC().Format(time.RFC3339),
+			},
+			OwnerReferences: []metav1.OwnerReference{*owner},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			hubconfig.KubeconfigKey: kubeConfig,
+		},
+	}
+	if secret == nil {
+		_, err = c.kubeClient.CoreV1().Secrets(addon.Namespace).Create(ctx, required, metav1.CreateOptions{})
+	} else {
+		// the token annotations are not merged by utils.ApplySecret.
+		updated := secret.DeepCopy()
+		updated.Labels, updated.OwnerReferences = required.Labels, required.OwnerReferences
+		if updated.Annotations == nil {
+			updated.Annotations = map[string]string{}
+		}
+		for key, value := range required.Annotations {
+			updated.Annotations[key] = value
+		}
+		updated.Data = required.Data
+		_, err = c.kubeClient.CoreV1().Secrets(addon.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	logger.Info("Issued token", "expiration", expiration)
+	return tokenRenewalTime(issued, expiration, tokenRegistration), nil
+}
+
+// buildTokenKubeConfig builds the hub kubeconfig authenticating with the token.
+func buildTokenKubeConfig(server string, caBundle []byte, token string) ([]byte, error) {
+	return clientcmd.Write(clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{
+			"hub": {
+				Server:                   server,
+				CertificateAuthorityData: caBundle,
+			},
+		},
+		AuthInfos: map[string]*clientcmdapi.AuthInfo{
+			"agent": {
+				Token: token,
+			},
+		},
+		Contexts: map[string]*clientcmdapi.Context{
+			"default": {
+				Cluster:  "hub",
+				AuthInfo: "agent",
+			},
+		},
+		CurrentContext: "default",
+	})
+}
+
+// kubeConfigUpToDate returns whether the kubeconfig is of the hub server and CA bundle.
+func kubeConfigUpToDate(kubeConfig []byte, server string, caBundle []byte) bool {
+	config, err := clientcmd.Load(kubeConfig)
+	if err != nil {
+		return false
+	}
+	cluster, ok := config.Clusters["hub"]
+	if !ok {
+		return false
+	}
+	return cluster.Server == server && bytes.Equal(cluster.CertificateAuthorityData, caBundle)
+}
+
+func tokenExpiration(tokenRegistration *agent.TokenRegistration) time.Duration {
+	if tokenRegistration.Expiration > 0 {
+		return tokenRegistration.Expiration
+	}
+	return defaultTokenExpiration
+}
+
+// tokenRenewalTime returns the time to renew the token by the lifetime the token is issued with. The RenewBefore
+// is ignored if it is not less than the lifetime, and the token is renewed at least minTokenRenewalInterval after
+// it is issued.
+func tokenRenewalTime(issued, expiration time.Time, tokenRegistration *agent.TokenRegistration) time.Time {
+	lifetime := expiration.Sub(issued)
+	renewBefore := tokenRegistration.RenewBefore
+	if renewBefore <= 0 || renewBefore >= lifetime {
+		renewBefore = time.Duration(float64(lifetime) * (1 - defaultTokenRenewalRatio))
+	}
+	renewalTime := expiration.Add(-renewBefore)
+	if earliest := issued.Add(minTokenRenewalInterval); renewalTime.Before(earliest) {
+		return earliest
+	}
+	return renewalTime
+}
@@ -0,0 +1,267 @@
+package registration
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeinformers "k8s.io/client-go/informers"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/hubconfig"
+)
+
+const testHubServer = "https://hub:6443"
+
+var testCABundle = []byte("ca")
+
+func newTokenSecret(t *testing.T, server string, expiration, issued time.Time) *corev1.Secret {
+	kubeConfig, err := buildTokenKubeConfig(server, testCABundle, "token0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.TokenKubeConfigSecretName("test"),
+			Namespace: "cluster1",
+			Labels:    map[string]string{addonapiv1alpha1.AddonLabelKey: "test"},
+			Annotations: map[string]string{
+				constants.TokenExpirationAnnotationKey: expiration.UTC().Format(time.RFC3339),
+				constants.TokenIssuedAnnotationKey:     issued.UTC().Format(time.RFC3339),
+			},
+		},
+		Data: map[string][]byte{hubconfig.KubeconfigKey: kubeConfig},
+	}
+}
+
+func TestTokenRegistrationReconcile(t *testing.T) {
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.TokenServiceAccountName("test"),
+			Namespace: "cluster1",
+		},
+	}
+
+	cases := []struct {
+		name            string
+		addon           []runtime.Object
+		objects         []runtime.Object
+		secrets         []runtime.Object
+		expectedActions []string
+		validateActions func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name: "no addon",
+		},
+		{
+			name:            "issue token",
+			addon:           []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			expectedActions: []string{"get", "create", "get", "create", "get", "create", "create", "create"},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				sa := actions[1].(clienttesting.CreateActionImpl).Object.(*corev1.ServiceAccount)
+				if sa.Name != "addon-test-agent" || len(sa.OwnerReferences) != 1 {
+					t.Errorf("unexpected service account %v", sa)
+				}
+				if actions[6].GetSubresource() != "token" {
+					t.Errorf("expected token request, but got %v", actions[6])
+				}
+				secret := actions[7].(clienttesting.CreateActionImpl).Object.(*corev1.Secret)
+				if secret.Name != "addon-test-token-kubeconfig" {
+					t.Errorf("unexpected secret name %s", secret.Name)
+				}
+				config, err := clientcmd.Load(secret.Data[hubconfig.KubeconfigKey])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if config.Clusters["hub"].Server != testHubServer || config.AuthInfos["agent"].Token != "token1" {
+					t.Errorf("unexpected kubeconfig %s", string(secret.Data[hubconfig.KubeconfigKey]))
+				}
+				if len(secret.Annotations[constants.TokenExpirationAnnotationKey]) == 0 ||
+					len(secret.Annotations[constants.TokenIssuedAnnotationKey]) == 0 {
+					t.Errorf("expected the token expiration and issued annotations")
+				}
+			},
+		},
+		{
+			name:            "token is valid",
+			addon:           []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			objects:         []runtime.Object{serviceAccount},
+			secrets:         []runtime.Object{newTokenSecret(t, testHubServer, time.Now().Add(20*time.Hour), time.Now().Add(-4*time.Hour))},
+			expectedActions: []string{"get", "get", "create", "get", "create"},
+		},
+		{
+			name:            "renew token",
+			addon:           []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			objects:         []runtime.Object{serviceAccount},
+			secrets:         []runtime.Object{newTokenSecret(t, testHubServer, time.Now().Add(time.Hour), time.Now().Add(-23*time.Hour))},
+			expectedActions: []string{"get", "get", "create", "get", "create", "create", "update"},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				secret := actions[6].(clienttesting.UpdateActionImpl).Object.(*corev1.Secret)
+				config, err := clientcmd.Load(secret.Data[hubconfig.KubeconfigKey])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if config.AuthInfos["agent"].Token != "token1" {
+					t.Errorf("expected the token to be renewed")
+				}
+			},
+		},
+		{
+			name:    "token with a capped lifetime is valid",
+			addon:   []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			objects: []runtime.Object{serviceAccount},
+			secrets: []runtime.Object{newTokenSecret(t, testHubServer, time.Now().Add(50*time.Minute),
+				time.Now().Add(-10*time.Minute))},
+			expectedActions: []string{"get", "get", "create", "get", "create"},
+		},
+		{
+			name:            "hub server changed",
+			addon:           []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			objects:         []runtime.Object{serviceAccount},
+			secrets:         []runtime.Object{newTokenSecret(t, "https://old-hub:6443", time.Now().Add(20*time.Hour), time.Now().Add(-4*time.Hour))},
+			expectedActions: []string{"get", "get", "create", "get", "create", "create", "update"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeKubeClient := fakekube.NewSimpleClientset(append(c.objects, c.secrets...)...)
+			fakeKubeClient.PrependReactor("create", "serviceaccounts",
+				func(action clienttesting.Action) (bool, runtime.Object, error) {
+					if action.GetSubresource() != "token" {
+						return false, nil, nil
+					}
+					return true, &authenticationv1.TokenRequest{
+						Status: authenticationv1.TokenRequestStatus{
+							Token:               "token1",
+							ExpirationTimestamp: metav1.NewTime(time.Now().Add(24 * time.Hour)),
+						},
+					}, nil
+				})
+			fakeAddonClient := fakeaddon.NewSimpleClientset(c.addon...)
+
+			kubeInformers := kubeinformers.NewSharedInformerFactory(fakeKubeClient, 10*time.Minute)
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+			for _, obj := range c.secrets {
+				if err := kubeInformers.Core().V1().Secrets().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, obj := range c.addon {
+				if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			controller := &tokenRegistrationController{
+				kubeClient: fakeKubeClient,
+				agentAddons: map[string]agent.AgentAddon{
+					"test": &testAgent{name: "test", registrations: []addonapiv1alpha1.RegistrationConfig{{}},
+						tokenRegistration: &agent.TokenRegistration{Server: testHubServer}},
+				},
+				managedClusterAddonLister: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				secretLister:              kubeInformers.Core().V1().Secrets().Lister(),
+				defaultCABundle:           testCABundle,
+			}
+
+			err := controller.sync(context.TODO(), addontesting.NewFakeSyncContext(t), "cluster1/test")
+			if err != nil {
+				t.Errorf("expected no error when sync: %v", err)
+			}
+			addontesting.AssertActions(t, fakeKubeClient.Actions(), c.expectedActions...)
+			if c.validateActions != nil {
+				c.validateActions(t, fakeKubeClient.Actions())
+			}
+			if len(c.addon) == 0 {
+				return
+			}
+
+			// only the klusterlet of the cluster is granted to read the hub kubeconfig secret
+			roleBinding, err := fakeKubeClient.RbacV1().RoleBindings("cluster1").Get(context.TODO(),
+				constants.TokenKubeConfigReaderName("test"), metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(roleBinding.Subjects) != 1 || roleBinding.Subjects[0].Name != "system:open-cluster-management:cluster1" {
+				t.Errorf("unexpected subjects %v", roleBinding.Subjects)
+			}
+			role, err := fakeKubeClient.RbacV1().Roles("cluster1").Get(context.TODO(),
+				roleBinding.RoleRef.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(role.Rules) != 1 || !reflect.DeepEqual(role.Rules[0].ResourceNames,
+				[]string{constants.TokenKubeConfigSecretName("test")}) {
+				t.Errorf("unexpected rules %v", role.Rules)
+			}
+		})
+	}
+}
+
+func TestTokenRenewalTime(t *testing.T) {
+	expiration := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name              string
+		issued            time.Time
+		tokenRegistration *agent.TokenRegistration
+		expected          time.Time
+	}{
+		{
+			name:              "default",
+			issued:            expiration.Add(-24 * time.Hour),
+			tokenRegistration: &agent.TokenRegistration{},
+			expected:          expiration.Add(-time.Duration(float64(24*time.Hour) * 0.2)),
+		},
+		{
+			name:              "expiration",
+			issued:            expiration.Add(-10 * time.Hour),
+			tokenRegistration: &agent.TokenRegistration{Expiration: 10 * time.Hour},
+			expected:          expiration.Add(-2 * time.Hour),
+		},
+		{
+			name:              "renew before",
+			issued:            expiration.Add(-24 * time.Hour),
+			tokenRegistration: &agent.TokenRegistration{RenewBefore: time.Hour},
+			expected:          expiration.Add(-time.Hour),
+		},
+		{
+			name:              "lifetime capped by the hub",
+			issued:            expiration.Add(-time.Hour),
+			tokenRegistration: &agent.TokenRegistration{Expiration: 24 * time.Hour},
+			expected:          expiration.Add(-12 * time.Minute),
+		},
+		{
+			name:              "renew before not less than the issued lifetime",
+			issued:            expiration.Add(-time.Hour),
+			tokenRegistration: &agent.TokenRegistration{Expiration: 24 * time.Hour, RenewBefore: 2 * time.Hour},
+			expected:          expiration.Add(-12 * time.Minute),
+		},
+		{
+			name:              "minimum renewal interval",
+			issued:            expiration.Add(-time.Minute),
+			tokenRegistration: &agent.TokenRegistration{},
+			expected:          expiration,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if actual := tokenRenewalTime(c.issued, expiration, c.tokenRegistration); !actual.Equal(c.expected) {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package ctrladapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+)
+
+// Reconciler wraps a controller of the framework as a controller-runtime Reconciler, each request is synced
+// with the queue key of the controller, which is "<namespace>/<name>" for the namespaced objects and "<name>"
+// for the cluster scoped objects.
+//
+// The informers that the wrapped controller lists from must be started by the caller, the Reconciler only
+// drives the sync of the controller. The keys other than the reconciled one that the sync adds are added to the
+// queue of the controller, they are reconciled if the controller is set up by SetupWithManager.
+type Reconciler struct {
+	controller factory.Controller
+}
+
+var _ reconcile.Reconciler = &Reconciler{}
+
+// NewReconciler returns a Reconciler of the given controller.
+func NewReconciler(controller factory.Controller) *Reconciler {
+	return &Reconciler{controller: controller}
+}
+
+// Reconcile syncs the controller with the key of the request. The requeue of the key that the sync asks
+// for on the sync context queue is returned as the result of the reconciliation.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	key := QueueKey(req)
+	syncCtx := &requeueSyncContext{
+		queue: &requeueQueue{
+			RateLimitingInterface: r.controller.SyncContext().Queue(),
+			controllerName:        r.controller.Name(),
+			key:                   key,
+		},
+	}
+
+	if err := r.controller.Sync(ctx, syncCtx, key); err != nil {
+		return reconcile.Result{}, err
+	}
+	return syncCtx.queue.result(), nil
+}
+
+// QueueKey returns the queue key of the controllers of the framework for the request.
+func QueueKey(req reconcile.Request) string {
+	if len(req.Namespace) == 0 {
+		return req.Name
+	}
+	return fmt.Sprintf("%s/%s", req.Namespace, req.Name)
+}
+
+// requeueSyncContext is the sync context of a single reconciliation.
+type requeueSyncContext struct {
+	queue *requeueQueue
+}
+
+func (c *requeueSyncContext) Queue() workqueue.RateLimitingInterface {
+	return c.queue
+}
+
+// requeueQueue records the requeue of the reconciled key, the keys other than the reconciled one are added
+// to the queue of the wrapped controller, which are moved to the request queue by the QueueSource.
+type requeueQueue struct {
+	workqueue.RateLimitingInterface
+	controllerName string
+	key            string
+
+	requeue      bool
+	requeueAfter time.Duration
+}
+
+func (q *requeueQueue) Add(item interface{}) {
+	if item != q.key {
+		q.forward(item)
+		q.RateLimitingInterface.Add(item)
+		return
+	}
+	q.requeue = true
+}
+
+func (q *requeueQueue) AddRateLimited(item interface{}) {
+	if item != q.key {
+		q.forward(item)
+		q.RateLimitingInterface.AddRateLimited(item)
+		return
+	}
+	q.requeue = true
+}
+
+func (q *requeueQueue) AddAfter(item interface{}, duration time.Duration) {
+	if item != q.key {
+		q.forward(item)
+		q.RateLimitingInterface.AddAfter(item, duration)
+		return
+	}
+	if q.requeueAfter == 0 || duration < q.requeueAfter {
+		q.requeueAfter = duration
+	}
+}
+
+func (q *requeueQueue) forward(item interface{}) {
+	klog.V(4).Infof("Key %v is added to the queue of controller %q when reconciling %q",
+		item, q.controllerName, q.key)
+}
+
+func (q *requeueQueue) result() reconcile.Result {
+	if q.requeueAfter > 0 {
+		return ctrl.Result{RequeueAfter: q.requeueAfter}
+	}
+	return ctrl.Result{Requeue: q.requeue}
+}
@@ -0,0 +1,123 @@
+package ctrladapter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+)
+
+type fakeController struct {
+	syncCtx factory.SyncContext
+	sync    factory.SyncFunc
+	keys    []string
+}
+
+func (c *fakeController) Run(ctx context.Context, workers int) {}
+
+func (c *fakeController) Sync(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+	c.keys = append(c.keys, key)
+	return c.sync(ctx, syncCtx, key)
+}
+
+func (c *fakeController) Name() string {
+	return "fake-controller"
+}
+
+func (c *fakeController) SyncContext() factory.SyncContext {
+	return c.syncCtx
+}
+
+func TestReconcile(t *testing.T) {
+	cases := []struct {
+		name           string
+		request        reconcile.Request
+		sync           factory.SyncFunc
+		expectedKey    string
+		expectedResult reconcile.Result
+		expectedErr    bool
+		expectedQueued int
+	}{
+		{
+			name:        "namespaced request",
+			request:     reconcile.Request{NamespacedName: namespacedName("cluster1", "test")},
+			sync:        func(ctx context.Context, syncCtx factory.SyncContext, key string) error { return nil },
+			expectedKey: "cluster1/test",
+		},
+		{
+			name:        "cluster scoped request",
+			request:     reconcile.Request{NamespacedName: namespacedName("", "test")},
+			sync:        func(ctx context.Context, syncCtx factory.SyncContext, key string) error { return nil },
+			expectedKey: "test",
+		},
+		{
+			name:    "sync error",
+			request: reconcile.Request{NamespacedName: namespacedName("cluster1", "test")},
+			sync: func(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+				return fmt.Errorf("sync error")
+			},
+			expectedKey: "cluster1/test",
+			expectedErr: true,
+		},
+		{
+			name:    "requeue",
+			request: reconcile.Request{NamespacedName: namespacedName("cluster1", "test")},
+			sync: func(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+				syncCtx.Queue().Add(key)
+				return nil
+			},
+			expectedKey:    "cluster1/test",
+			expectedResult: reconcile.Result{Requeue: true},
+		},
+		{
+			name:    "requeue after",
+			request: reconcile.Request{NamespacedName: namespacedName("cluster1", "test")},
+			sync: func(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+				syncCtx.Queue().AddAfter(key, 2*time.Minute)
+				syncCtx.Queue().AddAfter(key, time.Minute)
+				return nil
+			},
+			expectedKey:    "cluster1/test",
+			expectedResult: reconcile.Result{RequeueAfter: time.Minute},
+		},
+		{
+			name:    "other keys are added to the controller queue",
+			request: reconcile.Request{NamespacedName: namespacedName("cluster1", "test")},
+			sync: func(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+				syncCtx.Queue().Add("cluster2/test")
+				return nil
+			},
+			expectedKey:    "cluster1/test",
+			expectedQueued: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			controller := &fakeController{syncCtx: factory.NewSyncContext("test"), sync: c.sync}
+			defer controller.syncCtx.Queue().ShutDown()
+
+			result, err := NewReconciler(controller).Reconcile(context.TODO(), c.request)
+			if c.expectedErr && err == nil {
+				t.Errorf("expected error, but got nil")
+			}
+			if !c.expectedErr && err != nil {
+				t.Errorf("expected no error, but got %v", err)
+			}
+			if result != c.expectedResult {
+				t.Errorf("expected result %v, but got %v", c.expectedResult, result)
+			}
+			if len(controller.keys) != 1 || controller.keys[0] != c.expectedKey {
+				t.Errorf("expected key %q, but got %v", c.expectedKey, controller.keys)
+			}
+			if controller.syncCtx.Queue().Len() != c.expectedQueued {
+				t.Errorf("expected %d keys in the controller queue, but got %d",
+					c.expectedQueued, controller.syncCtx.Queue().Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package ctrladapter
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager"
+)
+
+// Runnable runs an AddonManager in a controller-runtime manager, so the lifecycle of the registered addons
+// is handled by the controllers of the framework and shares the leader election of the manager.
+type Runnable struct {
+	addonManager addonmanager.AddonManager
+}
+
+var _ manager.Runnable = &Runnable{}
+var _ manager.LeaderElectionRunnable = &Runnable{}
+
+// NewRunnable returns a Runnable of the AddonManager, the AddonManager is usually created with the rest
+// config of the controller-runtime manager.
+func NewRunnable(addonManager addonmanager.AddonManager) *Runnable {
+	return &Runnable{addonManager: addonManager}
+}
+
+// Start starts the AddonManager and blocks until the context is done and the in-flight syncs of the addon
+// controllers are drained, so the controller-runtime manager releases the leader election after them.
+func (r *Runnable) Start(ctx context.Context) error {
+	if err := r.addonManager.Start(ctx); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	<-r.addonManager.Stopped()
+	return nil
+}
+
+// NeedLeaderElection returns true, the addon controllers only run in the leader.
+func (r *Runnable) NeedLeaderElection() bool {
+	return true
+}
@@ -0,0 +1,78 @@
+package ctrladapter
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+)
+
+// SetupWithManager registers the controller to the manager as a controller-runtime controller. The keys are
+// computed by the event handlers that the controller registers to its informers, so the key of any controller,
+// e.g. of a cluster, a ClusterManagementAddOn or a CSR, is reconciled, and the keys that the syncs add to the
+// queue of the controller are reconciled as well.
+//
+// The informers that the controller lists from must be started by the caller.
+func SetupWithManager(mgr ctrl.Manager, controller factory.Controller) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controller.Name()).
+		Watches(NewQueueSource(controller), &handler.Funcs{}).
+		Complete(NewReconciler(controller))
+}
+
+// QueueSource is a controller-runtime source of the keys added to the queue of a controller of the framework.
+// Each key is moved from the queue of the controller to the request queue of the controller-runtime controller,
+// and the queue of the controller is shut down when the controller-runtime controller stops.
+type QueueSource struct {
+	controller factory.Controller
+}
+
+var _ source.Source = &QueueSource{}
+
+// NewQueueSource returns a QueueSource of the controller.
+func NewQueueSource(controller factory.Controller) *QueueSource {
+	return &QueueSource{controller: controller}
+}
+
+// Start moves the keys of the controller to the request queue until the context is done, the handler and the
+// predicates are not used since the keys are computed by the controller.
+func (s *QueueSource) Start(ctx context.Context, _ handler.EventHandler, queue workqueue.RateLimitingInterface,
+	_ ...predicate.Predicate) error {
+	from := s.controller.SyncContext().Queue()
+	go func() {
+		<-ctx.Done()
+		from.ShutDown()
+	}()
+	go func() {
+		for {
+			key, quit := from.Get()
+			if quit {
+				return
+			}
+			if queueKey, ok := key.(string); ok {
+				queue.Add(Request(queueKey))
+			}
+			from.Forget(key)
+			from.Done(key)
+		}
+	}()
+	return nil
+}
+
+// Request returns the request of the queue key of the controllers of the framework, it is the reverse of
+// QueueKey.
+func Request(key string) reconcile.Request {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return reconcile.Request{NamespacedName: types.NamespacedName{Name: key}}
+	}
+	return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}
+}
@@ -0,0 +1,91 @@
+package ctrladapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+)
+
+func namespacedName(namespace, name string) types.NamespacedName {
+	return types.NamespacedName{Namespace: namespace, Name: name}
+}
+
+func TestRequest(t *testing.T) {
+	cases := []struct {
+		name            string
+		key             string
+		expectedRequest reconcile.Request
+	}{
+		{
+			name:            "namespaced key",
+			key:             "cluster1/test",
+			expectedRequest: reconcile.Request{NamespacedName: namespacedName("cluster1", "test")},
+		},
+		{
+			name:            "cluster scoped key",
+			key:             "cluster1",
+			expectedRequest: reconcile.Request{NamespacedName: namespacedName("", "cluster1")},
+		},
+		{
+			name:            "key of another format",
+			key:             "a/b/c",
+			expectedRequest: reconcile.Request{NamespacedName: namespacedName("", "a/b/c")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			request := Request(c.key)
+			if request != c.expectedRequest {
+				t.Errorf("expected request %v, but got %v", c.expectedRequest, request)
+			}
+			if key := QueueKey(request); key != c.key {
+				t.Errorf("expected the queue key %q of the request, but got %q", c.key, key)
+			}
+		})
+	}
+}
+
+func TestQueueSource(t *testing.T) {
+	controller := &fakeController{syncCtx: factory.NewSyncContext("test")}
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer queue.ShutDown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := NewQueueSource(controller).Start(ctx, &handler.Funcs{}, queue); err != nil {
+		t.Fatal(err)
+	}
+
+	// the keys of the controller, including a cluster key and the default key, are moved to the request queue
+	controller.syncCtx.Queue().Add("cluster1/test")
+	controller.syncCtx.Queue().Add("cluster1")
+	controller.syncCtx.Queue().AddAfter(factory.DefaultQueueKey, 10*time.Millisecond)
+	expectedRequests := []reconcile.Request{
+		{NamespacedName: namespacedName("cluster1", "test")},
+		{NamespacedName: namespacedName("", "cluster1")},
+		{NamespacedName: namespacedName("", factory.DefaultQueueKey)},
+	}
+	for _, expected := range expectedRequests {
+		item, _ := queue.Get()
+		if item != expected {
+			t.Errorf("expected request %v, but got %v", expected, item)
+		}
+		queue.Done(item)
+	}
+
+	// the queue of the controller is shut down with the source
+	cancel()
+	if err := wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		return controller.syncCtx.Queue().ShuttingDown(), nil
+	}); err != nil {
+		t.Errorf("expected the queue of the controller shut down")
+	}
+}
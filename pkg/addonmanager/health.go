@@ -0,0 +1,48 @@
+package addonmanager
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"time"
+
+	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/klog/v2"
+
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+)
+
+// informerSyncChecker returns a readiness check of the caches of the informers started by the informer factory.
+func informerSyncChecker(name string, factory interface {
+	WaitForCacheSync(stopCh <-chan struct{}) map[reflect.Type]bool
+}) healthz.HealthChecker {
+	return healthz.NamedCheck(name, healthz.NewInformerSyncHealthz(factory).Check)
+}
+
+// serveHealthProbes serves the health probes on the address until the context is done.
+func serveHealthProbes(ctx context.Context, address string, readyzChecks ...healthz.HealthChecker) error {
+	livezChecks := []healthz.HealthChecker{healthz.PingHealthz, factory.ControllersLivenessChecker()}
+	readyzChecks = append([]healthz.HealthChecker{healthz.PingHealthz, factory.ControllersLivenessChecker(),
+		factory.ControllersReadyChecker()}, readyzChecks...)
+
+	mux := http.NewServeMux()
+	healthz.InstallPathHandler(mux, "/healthz", livezChecks...)
+	healthz.InstallPathHandler(mux, "/readyz", readyzChecks...)
+
+	server := &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			klog.Errorf("failed to shutdown the health probe server: %v", err)
+		}
+	}()
+
+	klog.Infof("serving the addon manager health probes on %s", address)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
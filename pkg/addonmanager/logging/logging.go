@@ -0,0 +1,46 @@
+// Package logging attaches the contextual fields of the addons to the logs of the addon manager controllers.
+// The controllers get the logger of the sync by FromContext, which is named by the controller name and has
+// the queue key of the sync, and add the fields of the objects they reconcile, so the logs of an addon on a
+// multi-addon hub are found by the fields, e.g. cluster="cluster1" addon="helloworld".
+package logging
+
+import (
+	"context"
+
+	"k8s.io/klog/v2"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+// The keys of the contextual fields.
+const (
+	ClusterKey    = "cluster"
+	AddonKey      = "addon"
+	WorkKey       = "work"
+	ConfigHashKey = "configHash"
+)
+
+// FromContext returns the logger of the context, or the global klog logger if the context has no logger.
+func FromContext(ctx context.Context) klog.Logger {
+	return klog.FromContext(ctx)
+}
+
+// WithValues returns the context with the logger of the context with the fields added, and the logger.
+func WithValues(ctx context.Context, keysAndValues ...interface{}) (context.Context, klog.Logger) {
+	logger := klog.LoggerWithValues(klog.FromContext(ctx), keysAndValues...)
+	return klog.NewContext(ctx, logger), logger
+}
+
+// WithAddon adds the cluster and the name of the addon to the logger of the context.
+func WithAddon(ctx context.Context, clusterName, addonName string) (context.Context, klog.Logger) {
+	return WithValues(ctx, ClusterKey, clusterName, AddonKey, addonName)
+}
+
+// WithWork adds the namespaced name of the ManifestWork to the logger of the context.
+func WithWork(ctx context.Context, work *workapiv1.ManifestWork) (context.Context, klog.Logger) {
+	return WithValues(ctx, WorkKey, klog.KObj(work))
+}
+
+// WithConfigHash adds the spec hash of the desired addon configs to the logger of the context.
+func WithConfigHash(ctx context.Context, hash string) (context.Context, klog.Logger) {
+	return WithValues(ctx, ConfigHashKey, hash)
+}
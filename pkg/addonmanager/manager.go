@@ -3,21 +3,31 @@ package addonmanager
 import (
 	"context"
 	"fmt"
+	"os"
+	"sync"
 	"time"
 
+	"k8s.io/apiserver/pkg/server/healthz"
 	"k8s.io/client-go/tools/cache"
 
 	"open-cluster-management.io/addon-framework/pkg/index"
 	"open-cluster-management.io/addon-framework/pkg/manager/controllers/addonconfiguration"
+	"open-cluster-management.io/addon-framework/pkg/manager/controllers/addonconfigvalidation"
 	"open-cluster-management.io/addon-framework/pkg/manager/controllers/addonowner"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	kubeinformers "k8s.io/client-go/informers"
+	certificatesinformers "k8s.io/client-go/informers/certificates/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
 	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
@@ -29,19 +39,28 @@ import (
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/addonconfig"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/addonhealthcheck"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/addoninstall"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/addonprogressing"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/agentdeploy"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/certificate"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/clusterversion"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/cmamanagedby"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/hubdependency"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/managementaddonconfig"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/registration"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/metrics"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	cloudeventswork "open-cluster-management.io/addon-framework/pkg/cloudevents/work"
 	"open-cluster-management.io/addon-framework/pkg/utils"
 )
 
 // AddonManager is the interface to initialize a manager on hub to manage the addon
 // agents on all managedcluster
 type AddonManager interface {
-	// AddAgent register an addon agent to the manager.
+	// AddAgent register an addon agent to the manager. Several addons with different names can be registered to
+	// one manager, they share the informers and the controllers of the manager, and the controllers queue the
+	// keys of each addon separately so a busy addon does not starve the others. It returns an error if the
+	// options of the addon are invalid, the deprecated options are only logged.
 	AddAgent(addon agent.AgentAddon) error
 
 	// Trigger triggers a reconcile loop in the manager. Currently it
@@ -50,13 +69,75 @@ type AddonManager interface {
 
 	// Start starts all registered addon agent.
 	Start(ctx context.Context) error
+
+	// Stopped returns a channel closed when the controllers started by Start or StartWithInformers are stopped
+	// after the context is done. On shutdown the controllers stop taking new keys and finish the in-flight
+	// syncs, including their ManifestWork writes and addon status patches, in the drain timeout set by
+	// WithShutdownDrainTimeout. The status patches of the syncs cancelled after the timeout are still flushed. The
+	// caller waits for it before exiting or releasing the leader election, so the next leader does not start with
+	// half-written ManifestWorks and stale conditions. It is also closed if the manager fails to start.
+	Stopped() <-chan struct{}
+
+	// StartWithInformers starts the controllers of all the registered addon agents with the given clients and
+	// informer factories instead of creating them, so the manager can be embedded in a controller which already
+	// runs the informers, e.g. with its own leader election. It must be called before the informer factories
+	// are started, the caller starts them afterwards. The ManifestWork and kube informers are expected to select
+	// the resources labeled with the open-cluster-management.io/addon-name label to limit the cache.
+	StartWithInformers(ctx context.Context,
+		kubeClient kubernetes.Interface,
+		addonClient addonv1alpha1client.Interface,
+		workClient workv1client.Interface,
+		kubeInformers kubeinformers.SharedInformerFactory,
+		addonInformers addoninformers.SharedInformerFactory,
+		clusterInformers clusterv1informers.SharedInformerFactory,
+		workInformers workv1informers.SharedInformerFactory,
+		dynamicInformers dynamicinformer.DynamicSharedInformerFactory) error
 }
 
 type addonManager struct {
-	addonAgents  map[string]agent.AgentAddon
-	addonConfigs map[schema.GroupVersionResource]bool
-	config       *rest.Config
-	syncContexts []factory.SyncContext
+	addonAgents   map[string]agent.AgentAddon
+	addonConfigs  map[schema.GroupVersionResource]bool
+	specHashFuncs map[schema.GroupVersionResource]agent.ConfigSpecHashFunc
+	config        *rest.Config
+	syncContexts  []factory.SyncContext
+
+	workDriver                    WorkDriver
+	scopedAddonInformers          bool
+	clusterSets                   []string
+	manageClusterManagementAddOns bool
+
+	// autoInstallExcludedClusters and removeExcludedAutoInstalled configure the clusters excluded from the
+	// automatic installation of the addons.
+	autoInstallExcludedClusters *metav1.LabelSelector
+	removeExcludedAutoInstalled bool
+
+	// queueOptions are the work queue options of the controllers by the controller name, the options of the
+	// empty name apply to the controllers without their own options.
+	queueOptions map[string]factory.QueueOptions
+
+	// deployOptions tunes the addon deploy controller, e.g. the rate limits of the manifestwork writes.
+	deployOptions agentdeploy.Options
+	// deployWorkers is the number of the workers of the addon deploy controller, 1 worker is used if it is not
+	// positive.
+	deployWorkers int
+
+	// metricsBindAddress and healthProbeBindAddress are the addresses the metrics and the health probes are
+	// served on, they are disabled if the address is empty.
+	metricsBindAddress     string
+	healthProbeBindAddress string
+
+	// logVerbosities are the log verbosities of the controllers by the controller name, the verbosity of the
+	// empty name applies to the controllers without their own verbosity.
+	logVerbosities map[string]int
+
+	// drainTimeout is how long the controllers wait for their in-flight syncs on shutdown, the timeout of the
+	// context or the default of the controllers is used if it is zero.
+	drainTimeout time.Duration
+
+	// controllers tracks the running controllers, stopped is closed when all of them are stopped.
+	controllers sync.WaitGroup
+	stopped     chan struct{}
+	stopOnce    sync.Once
 }
 
 func (a *addonManager) AddAgent(addon agent.AgentAddon) error {
@@ -67,6 +148,12 @@ func (a *addonManager) AddAgent(addon agent.AgentAddon) error {
 	if _, ok := a.addonAgents[addonOption.AddonName]; ok {
 		return fmt.Errorf("an agent is added for the addon already")
 	}
+	if err := addonOption.Validate(); err != nil {
+		return fmt.Errorf("invalid options of addon %s: %w", addonOption.AddonName, err)
+	}
+	for _, warning := range addonOption.Deprecations() {
+		klog.Warningf("Addon %s: %s", addonOption.AddonName, warning)
+	}
 	a.addonAgents[addonOption.AddonName] = addon
 	return nil
 }
@@ -78,6 +165,15 @@ func (a *addonManager) Trigger(clusterName, addonName string) {
 }
 
 func (a *addonManager) Start(ctx context.Context) error {
+	if err := a.start(ctx); err != nil {
+		// nothing is left running if the manager fails to start
+		a.stop()
+		return err
+	}
+	return nil
+}
+
+func (a *addonManager) start(ctx context.Context) error {
 	dynamicClient, err := dynamic.NewForConfig(a.config)
 	if err != nil {
 		return err
@@ -98,113 +194,306 @@ func (a *addonManager) Start(ctx context.Context) error {
 		return err
 	}
 
-	workClient, err := workv1client.NewForConfig(a.config)
+	workClient, err := a.workDriver(ctx, a.config)
 	if err != nil {
 		return err
 	}
+	if workClient == nil {
+		return fmt.Errorf("the work driver returns no work client")
+	}
+
+	addonNames := make([]string, 0, len(a.addonAgents))
+	for addonName := range a.addonAgents {
+		addonNames = append(addonNames, addonName)
+	}
+	addonInformers := addoninformers.NewSharedInformerFactory(addonClient, 10*time.Minute)
+	// the ManagedClusterAddOn informer is in its own factory if it is scoped, the ClusterManagementAddOns
+	// are not selected by the label of the addon name.
+	managedClusterAddOnInformers := addonInformers
+	if a.scopedAddonInformers {
+		if err := adoptManagedClusterAddOns(ctx, addonClient, addonNames); err != nil {
+			return err
+		}
+		managedClusterAddOnInformers = addoninformers.NewSharedInformerFactoryWithOptions(addonClient, 10*time.Minute,
+			addoninformers.WithTweakListOptions(managedClusterAddOnListOptions(addonNames)))
+	}
+	workInformers := workv1informers.NewSharedInformerFactoryWithOptions(workClient, 10*time.Minute,
+		workv1informers.WithTweakListOptions(addonLabelListOptions(addonNames)))
+	clusterInformers := clusterv1informers.NewSharedInformerFactory(clusterClient, 10*time.Minute)
+	kubeInfomers := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 10*time.Minute,
+		kubeinformers.WithTweakListOptions(addonLabelListOptions(addonNames)))
+	if len(a.clusterSets) > 0 {
+		namespaces, err := clusterSetNamespaces(ctx, clusterClient, a.clusterSets)
+		if err != nil {
+			return err
+		}
+		klog.Infof("The addon manager is scoped to the cluster sets %v, cluster namespaces %v", a.clusterSets, namespaces)
+
+		clusterInformers = clusterv1informers.NewSharedInformerFactoryWithOptions(clusterClient, 10*time.Minute,
+			clusterv1informers.WithTweakListOptions(clusterSetListOptions(a.clusterSets)))
+		var managedClusterAddOnTweak func(*metav1.ListOptions)
+		if a.scopedAddonInformers {
+			managedClusterAddOnTweak = managedClusterAddOnListOptions(addonNames)
+		}
+		clusterNamespaces := scopeInformersToNamespaces(ctx.Done(), namespaces, managedClusterAddOnInformers,
+			managedClusterAddOnTweak, workInformers, kubeInfomers, addonLabelListOptions(addonNames))
+		if _, err := clusterInformers.Cluster().V1().ManagedClusters().Informer().AddEventHandler(
+			clusterNamespaces.clusterEventHandler()); err != nil {
+			return err
+		}
+	}
+	dynamicInformers := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, 10*time.Minute)
+
+	dependencyInformers := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 10*time.Minute,
+		kubeinformers.WithNamespace(a.dependencyNamespace()))
+
+	h := &hubInformers{
+		kubeClient:                   kubeClient,
+		addonClient:                  addonClient,
+		workClient:                   workClient,
+		kubeInformers:                kubeInfomers,
+		addonInformers:               addonInformers,
+		managedClusterAddOnInformers: managedClusterAddOnInformers,
+		clusterInformers:             clusterInformers,
+		workInformers:                workInformers,
+		dynamicInformers:             dynamicInformers,
+		dependencyInformers:          dependencyInformers,
+	}
+	if err := a.startControllers(ctx, h); err != nil {
+		return err
+	}
+
+	go addonInformers.Start(ctx.Done())
+	if managedClusterAddOnInformers != addonInformers {
+		go managedClusterAddOnInformers.Start(ctx.Done())
+	}
+	go workInformers.Start(ctx.Done())
+	go clusterInformers.Start(ctx.Done())
+	go kubeInfomers.Start(ctx.Done())
+	go dynamicInformers.Start(ctx.Done())
+	go dependencyInformers.Start(ctx.Done())
+	return nil
+}
+
+func (a *addonManager) StartWithInformers(ctx context.Context,
+	kubeClient kubernetes.Interface,
+	addonClient addonv1alpha1client.Interface,
+	workClient workv1client.Interface,
+	kubeInformers kubeinformers.SharedInformerFactory,
+	addonInformers addoninformers.SharedInformerFactory,
+	clusterInformers clusterv1informers.SharedInformerFactory,
+	workInformers workv1informers.SharedInformerFactory,
+	dynamicInformers dynamicinformer.DynamicSharedInformerFactory) error {
+	err := a.startControllers(ctx, &hubInformers{
+		kubeClient:                   kubeClient,
+		addonClient:                  addonClient,
+		workClient:                   workClient,
+		kubeInformers:                kubeInformers,
+		addonInformers:               addonInformers,
+		managedClusterAddOnInformers: addonInformers,
+		clusterInformers:             clusterInformers,
+		workInformers:                workInformers,
+		dynamicInformers:             dynamicInformers,
+		dependencyInformers:          kubeInformers,
+	})
+	if err != nil {
+		a.stop()
+	}
+	return err
+}
+
+// hubInformers are the clients and the informer factories the controllers of the manager run with.
+type hubInformers struct {
+	kubeClient  kubernetes.Interface
+	addonClient addonv1alpha1client.Interface
+	workClient  workv1client.Interface
+
+	kubeInformers  kubeinformers.SharedInformerFactory
+	addonInformers addoninformers.SharedInformerFactory
+	// managedClusterAddOnInformers is the factory of the ManagedClusterAddOn informer, it is different from
+	// the addonInformers if the informer is scoped to the addons of the manager.
+	managedClusterAddOnInformers addoninformers.SharedInformerFactory
+	clusterInformers             clusterv1informers.SharedInformerFactory
+	workInformers                workv1informers.SharedInformerFactory
+	dynamicInformers             dynamicinformer.DynamicSharedInformerFactory
+	// dependencyInformers is the factory of the informers of the hub dependencies of the addons.
+	dependencyInformers kubeinformers.SharedInformerFactory
+}
+
+// dependencyNamespace returns the namespace of the hub dependencies if all of them are in the same namespace,
+// otherwise all the namespaces are watched.
+func (a *addonManager) dependencyNamespace() string {
+	dependencyNamespaces := sets.New[string]()
+	for _, agentImpl := range a.addonAgents {
+		for _, dependency := range agentImpl.GetAgentAddonOptions().HubDependencies {
+			dependencyNamespaces.Insert(dependency.Namespace)
+		}
+	}
+	if dependencyNamespaces.Len() == 1 {
+		return sets.List(dependencyNamespaces)[0]
+	}
+	return metav1.NamespaceAll
+}
+
+// startControllers adds the indexers to the informers and runs the controllers of the manager, the informers
+// are not started.
+func (a *addonManager) startControllers(ctx context.Context, h *hubInformers) error {
+	var err error
+	var excludedClusterSelector labels.Selector
+	if a.autoInstallExcludedClusters != nil {
+		excludedClusterSelector, err = metav1.LabelSelectorAsSelector(a.autoInstallExcludedClusters)
+		if err != nil {
+			return fmt.Errorf("invalid selector of the clusters excluded from the automatic installation: %w", err)
+		}
+	}
 
-	v1CSRSupported, v1beta1Supported, err := utils.IsCSRSupported(kubeClient)
+	eventRecorder := utils.NewAddonEventRecorder(h.kubeClient, "addon-manager")
+
+	v1CSRSupported, v1beta1Supported, err := utils.IsCSRSupported(h.kubeClient)
 	if err != nil {
 		return err
 	}
 
-	var addonNames []string
-	for key, agentImpl := range a.addonAgents {
-		addonNames = append(addonNames, key)
+	dependencyKinds := sets.New[agent.HubDependencyKind]()
+	certRotationEnabled := false
+	tokenRegistrationEnabled := false
+	for _, agentImpl := range a.addonAgents {
+		if registration := agentImpl.GetAgentAddonOptions().Registration; registration != nil &&
+			registration.CertificateRotation != nil && registration.CSRSign != nil {
+			certRotationEnabled = true
+		}
+		if registration.TokenRegistrationEnabled(agentImpl) {
+			tokenRegistrationEnabled = true
+		}
+		for _, dependency := range agentImpl.GetAgentAddonOptions().HubDependencies {
+			dependencyKinds.Insert(dependency.Kind)
+		}
 		for _, configGVR := range agentImpl.GetAgentAddonOptions().SupportedConfigGVRs {
 			a.addonConfigs[configGVR] = true
 		}
-	}
-	addonInformers := addoninformers.NewSharedInformerFactory(addonClient, 10*time.Minute)
-	workInformers := workv1informers.NewSharedInformerFactoryWithOptions(workClient, 10*time.Minute,
-		workv1informers.WithTweakListOptions(func(listOptions *metav1.ListOptions) {
-			selector := &metav1.LabelSelector{
-				MatchExpressions: []metav1.LabelSelectorRequirement{
-					{
-						Key:      addonv1alpha1.AddonLabelKey,
-						Operator: metav1.LabelSelectorOpIn,
-						Values:   addonNames,
-					},
-				},
+		for configGVR, specHashFunc := range agentImpl.GetAgentAddonOptions().ConfigSpecHashFuncs {
+			if specHashFunc == nil {
+				continue
 			}
-			listOptions.LabelSelector = metav1.FormatLabelSelector(selector)
-		}),
-	)
-	clusterInformers := clusterv1informers.NewSharedInformerFactory(clusterClient, 10*time.Minute)
-	kubeInfomers := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 10*time.Minute,
-		kubeinformers.WithTweakListOptions(func(listOptions *metav1.ListOptions) {
-			selector := &metav1.LabelSelector{
-				MatchExpressions: []metav1.LabelSelectorRequirement{
-					{
-						Key:      addonv1alpha1.AddonLabelKey,
-						Operator: metav1.LabelSelectorOpIn,
-						Values:   addonNames,
-					},
-				},
+			if _, ok := a.specHashFuncs[configGVR]; ok {
+				return fmt.Errorf("the spec hash func of config %s is registered by more than one addon", configGVR)
 			}
-			listOptions.LabelSelector = metav1.FormatLabelSelector(selector)
-		}),
-	)
-	dynamicInformers := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, 10*time.Minute)
+			a.specHashFuncs[configGVR] = specHashFunc
+		}
+	}
+	// the config informers are shared by all the addons, so each config GVR is only watched once.
+	configInformers := utils.NewSharedConfigInformers(h.dynamicInformers)
 
 	deployController := agentdeploy.NewAddonDeployController(
-		workClient,
-		addonClient,
-		clusterInformers.Cluster().V1().ManagedClusters(),
-		addonInformers.Addon().V1alpha1().ManagedClusterAddOns(),
-		workInformers.Work().V1().ManifestWorks(),
+		h.workClient,
+		h.addonClient,
+		eventRecorder,
+		h.clusterInformers.Cluster().V1().ManagedClusters(),
+		h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+		h.addonInformers.Addon().V1alpha1().ClusterManagementAddOns(),
+		h.addonInformers.Addon().V1alpha1().AddOnDeploymentConfigs(),
+		h.workInformers.Work().V1().ManifestWorks(),
 		a.addonAgents,
+		a.deployOptions,
 	)
 
 	registrationController := registration.NewAddonConfigurationController(
-		addonClient,
-		clusterInformers.Cluster().V1().ManagedClusters(),
-		addonInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+		h.addonClient,
+		h.clusterInformers.Cluster().V1().ManagedClusters(),
+		h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
 		a.addonAgents,
 	)
 
 	addonInstallController := addoninstall.NewAddonInstallController(
-		addonClient,
-		clusterInformers.Cluster().V1().ManagedClusters(),
-		addonInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+		h.addonClient,
+		h.clusterInformers.Cluster().V1().ManagedClusters(),
+		h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
 		a.addonAgents,
+		addoninstall.ClusterExclusion{
+			Selector:        excludedClusterSelector,
+			RemoveInstalled: a.removeExcludedAutoInstalled,
+		},
 	)
 
 	addonHealthCheckController := addonhealthcheck.NewAddonHealthCheckController(
-		addonClient,
-		addonInformers.Addon().V1alpha1().ManagedClusterAddOns(),
-		workInformers.Work().V1().ManifestWorks(),
+		h.addonClient,
+		eventRecorder,
+		h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+		h.workInformers.Work().V1().ManifestWorks(),
+		a.addonAgents,
+	)
+
+	addonProgressingController := addonprogressing.NewAddonProgressingController(
+		h.addonClient,
+		eventRecorder,
+		h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+		h.workInformers.Work().V1().ManifestWorks(),
+		a.addonAgents,
+	)
+
+	clusterVersionController := clusterversion.NewClusterVersionController(
+		h.clusterInformers.Cluster().V1().ManagedClusters(),
+		h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
 		a.addonAgents,
+		a.Trigger,
 	)
 
+	var hubDependencyController factory.Controller
+	if dependencyKinds.Len() > 0 {
+		var secretInformer coreinformers.SecretInformer
+		var configMapInformer coreinformers.ConfigMapInformer
+		if dependencyKinds.Has(agent.HubDependencyKindSecret) {
+			secretInformer = h.dependencyInformers.Core().V1().Secrets()
+		}
+		if dependencyKinds.Has(agent.HubDependencyKindConfigMap) {
+			configMapInformer = h.dependencyInformers.Core().V1().ConfigMaps()
+		}
+		hubDependencyController = hubdependency.NewHubDependencyController(
+			h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+			secretInformer,
+			configMapInformer,
+			a.addonAgents,
+			a.Trigger,
+		)
+	}
+
 	// This is a duplicate controller in general addon-manager. This should be removed when we
 	// alway enable the addon-manager
 	addonOwnerController := addonowner.NewAddonOwnerController(
-		addonClient,
-		addonInformers.Addon().V1alpha1().ManagedClusterAddOns(),
-		addonInformers.Addon().V1alpha1().ClusterManagementAddOns(),
+		h.addonClient,
+		h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+		h.addonInformers.Addon().V1alpha1().ClusterManagementAddOns(),
+		utils.ManagedBySelf,
+	)
+
+	addonConfigValidationController := addonconfigvalidation.NewAddonConfigValidationController(
+		h.addonClient,
+		h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+		h.addonInformers.Addon().V1alpha1().ClusterManagementAddOns(),
 		utils.ManagedBySelf,
 	)
 
 	var addonConfigController, managementAddonConfigController, addonConfigurationController factory.Controller
 	if len(a.addonConfigs) != 0 {
 		addonConfigController = addonconfig.NewAddonConfigController(
-			addonClient,
-			addonInformers.Addon().V1alpha1().ManagedClusterAddOns(),
-			dynamicInformers,
+			h.addonClient,
+			h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+			configInformers,
 			a.addonConfigs,
+			a.specHashFuncs,
 		)
 		managementAddonConfigController = managementaddonconfig.NewManagementAddonConfigController(
-			addonClient,
-			addonInformers.Addon().V1alpha1().ClusterManagementAddOns(),
-			dynamicInformers,
+			h.addonClient,
+			h.addonInformers.Addon().V1alpha1().ClusterManagementAddOns(),
+			configInformers,
 			a.addonConfigs,
+			a.specHashFuncs,
 		)
 
 		// start addonConfiguration controller, note this is to handle the case when the general addon-manager
 		// is not started, we should consider to remove this when the general addon-manager are always started.
 		// This controller will also ignore the installStrategy part.
-		err = addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().AddIndexers(
+		err = h.addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().AddIndexers(
 			cache.Indexers{
 				index.ClusterManagementAddonByPlacement: index.IndexClusterManagementAddonByPlacement,
 			})
@@ -212,7 +501,7 @@ func (a *addonManager) Start(ctx context.Context) error {
 			return err
 		}
 
-		err = addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().AddIndexers(
+		err = h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().AddIndexers(
 			cache.Indexers{
 				index.ManagedClusterAddonByName: index.IndexManagedClusterAddonByName,
 			})
@@ -220,11 +509,13 @@ func (a *addonManager) Start(ctx context.Context) error {
 			return err
 		}
 		addonConfigurationController = addonconfiguration.NewAddonConfigurationController(
-			addonClient,
-			addonInformers.Addon().V1alpha1().ManagedClusterAddOns(),
-			addonInformers.Addon().V1alpha1().ClusterManagementAddOns(),
-			nil, nil,
+			h.addonClient,
+			eventRecorder,
+			h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+			h.addonInformers.Addon().V1alpha1().ClusterManagementAddOns(),
+			nil, nil, nil,
 			utils.ManagedBySelf,
+			nil,
 		)
 	}
 
@@ -236,68 +527,470 @@ func (a *addonManager) Start(ctx context.Context) error {
 	// disabled to avoid conflict.
 	if v1CSRSupported {
 		csrApproveController = certificate.NewCSRApprovingController(
-			kubeClient,
-			clusterInformers.Cluster().V1().ManagedClusters(),
-			kubeInfomers.Certificates().V1().CertificateSigningRequests(),
+			h.kubeClient,
+			eventRecorder,
+			h.clusterInformers.Cluster().V1().ManagedClusters(),
+			h.kubeInformers.Certificates().V1().CertificateSigningRequests(),
 			nil,
-			addonInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+			h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
 			a.addonAgents,
 		)
 		csrSignController = certificate.NewCSRSignController(
-			kubeClient,
-			clusterInformers.Cluster().V1().ManagedClusters(),
-			kubeInfomers.Certificates().V1().CertificateSigningRequests(),
-			addonInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+			h.kubeClient,
+			h.clusterInformers.Cluster().V1().ManagedClusters(),
+			h.kubeInformers.Certificates().V1().CertificateSigningRequests(),
+			h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
 			a.addonAgents,
 		)
 	} else if v1beta1Supported {
 		csrApproveController = certificate.NewCSRApprovingController(
-			kubeClient,
-			clusterInformers.Cluster().V1().ManagedClusters(),
+			h.kubeClient,
+			eventRecorder,
+			h.clusterInformers.Cluster().V1().ManagedClusters(),
 			nil,
-			kubeInfomers.Certificates().V1beta1().CertificateSigningRequests(),
-			addonInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+			h.kubeInformers.Certificates().V1beta1().CertificateSigningRequests(),
+			h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+			a.addonAgents,
+		)
+	}
+
+	var certRotationController factory.Controller
+	if certRotationEnabled {
+		certRotationController = certificate.NewCertRotationController(
+			h.kubeClient,
+			h.kubeInformers.Core().V1().Secrets(),
+			h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+			a.addonAgents,
+		)
+	}
+
+	var tokenRegistrationController factory.Controller
+	if tokenRegistrationEnabled {
+		caBundle, err := a.hubCABundle()
+		if err != nil {
+			return err
+		}
+		tokenRegistrationController = registration.NewTokenRegistrationController(
+			h.kubeClient,
+			h.kubeInformers.Core().V1().Secrets(),
+			h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+			a.addonAgents,
+			caBundle,
+		)
+	}
+
+	// the CSRs are not deleted on the hubs not serving the v1 CSR API
+	var csrInformer certificatesinformers.CertificateSigningRequestInformer
+	if v1CSRSupported {
+		csrInformer = h.kubeInformers.Certificates().V1().CertificateSigningRequests()
+	}
+	registrationCleanupController := registration.NewRegistrationCleanupController(
+		h.kubeClient,
+		h.addonClient,
+		eventRecorder,
+		h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+		h.workInformers.Work().V1().ManifestWorks(),
+		csrInformer,
+		a.addonAgents,
+		a.deployOptions.ManifestsCleanupTimeout,
+	)
+
+	var cmaManagedByController factory.Controller
+	if a.manageClusterManagementAddOns {
+		cmaManagedByController = cmamanagedby.NewCMAManagedByController(
+			h.addonClient,
+			h.addonInformers.Addon().V1alpha1().ClusterManagementAddOns(),
 			a.addonAgents,
 		)
+		// the missing ClusterManagementAddOns have no event, queue all the addons to create them at startup
+		for addonName := range a.addonAgents {
+			cmaManagedByController.SyncContext().Queue().Add(addonName)
+		}
 	}
 
 	a.syncContexts = append(a.syncContexts, deployController.SyncContext())
 
-	go addonInformers.Start(ctx.Done())
-	go workInformers.Start(ctx.Done())
-	go clusterInformers.Start(ctx.Done())
-	go kubeInfomers.Start(ctx.Done())
-	go dynamicInformers.Start(ctx.Done())
+	if len(a.metricsBindAddress) > 0 {
+		handler, err := metrics.Handler(metrics.NewAddonCollector(h.managedClusterAddOnInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister()))
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := metrics.Serve(ctx, a.metricsBindAddress, handler); err != nil {
+				klog.Errorf("failed to serve the metrics: %v", err)
+			}
+		}()
+	}
 
-	go deployController.Run(ctx, 1)
-	go registrationController.Run(ctx, 1)
-	go addonInstallController.Run(ctx, 1)
-	go addonHealthCheckController.Run(ctx, 1)
-	go addonOwnerController.Run(ctx, 1)
+	if len(a.healthProbeBindAddress) > 0 {
+		readyzChecks := []healthz.HealthChecker{
+			informerSyncChecker("addon-informer-sync", h.addonInformers),
+			informerSyncChecker("work-informer-sync", h.workInformers),
+			informerSyncChecker("cluster-informer-sync", h.clusterInformers),
+			informerSyncChecker("kube-informer-sync", h.kubeInformers),
+		}
+		if h.dependencyInformers != h.kubeInformers {
+			readyzChecks = append(readyzChecks,
+				informerSyncChecker("dependency-informer-sync", h.dependencyInformers))
+		}
+		if h.managedClusterAddOnInformers != h.addonInformers {
+			readyzChecks = append(readyzChecks,
+				informerSyncChecker("managed-cluster-addon-informer-sync", h.managedClusterAddOnInformers))
+		}
+		go func() {
+			if err := serveHealthProbes(ctx, a.healthProbeBindAddress, readyzChecks...); err != nil {
+				klog.Errorf("failed to serve the health probes: %v", err)
+			}
+		}()
+	}
+
+	deployWorkers := a.deployWorkers
+	if deployWorkers <= 0 {
+		deployWorkers = 1
+	}
+	a.runController(ctx, deployController, deployWorkers)
+	a.runController(ctx, registrationController, 1)
+	a.runController(ctx, addonInstallController, 1)
+	a.runController(ctx, addonHealthCheckController, 1)
+	a.runController(ctx, addonProgressingController, 1)
+	a.runController(ctx, addonOwnerController, 1)
+	a.runController(ctx, addonConfigValidationController, 1)
+	a.runController(ctx, clusterVersionController, 1)
+	if hubDependencyController != nil {
+		a.runController(ctx, hubDependencyController, 1)
+	}
 	if addonConfigController != nil {
-		go addonConfigController.Run(ctx, 1)
+		a.runController(ctx, addonConfigController, 1)
 	}
 	if managementAddonConfigController != nil {
-		go managementAddonConfigController.Run(ctx, 1)
+		a.runController(ctx, managementAddonConfigController, 1)
 	}
 	if addonConfigurationController != nil {
-		go addonConfigurationController.Run(ctx, 1)
+		a.runController(ctx, addonConfigurationController, 1)
 	}
 	if csrApproveController != nil {
-		go csrApproveController.Run(ctx, 1)
+		a.runController(ctx, csrApproveController, 1)
 	}
 	if csrSignController != nil {
-		go csrSignController.Run(ctx, 1)
+		a.runController(ctx, csrSignController, 1)
+	}
+	if certRotationController != nil {
+		a.runController(ctx, certRotationController, 1)
 	}
+	if tokenRegistrationController != nil {
+		a.runController(ctx, tokenRegistrationController, 1)
+	}
+	a.runController(ctx, registrationCleanupController, 1)
+	if cmaManagedByController != nil {
+		a.runController(ctx, cmaManagedByController, 1)
+	}
+	go func() {
+		a.controllers.Wait()
+		a.stop()
+	}()
 	return nil
 }
 
+// stop closes the stopped channel once the controllers are stopped or the manager fails to start.
+func (a *addonManager) stop() {
+	a.stopOnce.Do(func() { close(a.stopped) })
+}
+
+// runController runs the controller by the workers with the options of the manager until the context is done and
+// its in-flight syncs are drained.
+func (a *addonManager) runController(ctx context.Context, controller factory.Controller, workers int) {
+	if workers <= 0 {
+		workers = 1
+	}
+	factory.Configure(controller, a.controllerOptions(controller.Name()))
+	a.controllers.Add(1)
+	go func() {
+		defer a.controllers.Done()
+		controller.Run(ctx, workers)
+	}()
+}
+
+// controllerOptions returns the options of the controller of the manager by the controller name.
+func (a *addonManager) controllerOptions(name string) factory.ControllerOptions {
+	options := factory.ControllerOptions{Queue: a.queueOptions[""]}
+	if queueOptions, ok := a.queueOptions[name]; ok {
+		options.Queue = queueOptions
+	}
+	options.DrainTimeout = a.drainTimeout
+	if verbosity, ok := a.logVerbosities[name]; ok {
+		options.LogVerbosity = &verbosity
+	} else if verbosity, ok := a.logVerbosities[""]; ok {
+		options.LogVerbosity = &verbosity
+	}
+	return options
+}
+
+func (a *addonManager) Stopped() <-chan struct{} {
+	return a.stopped
+}
+
+// Option configures the addon manager created by New.
+type Option func(manager *addonManager)
+
+// WithScopedAddonInformers restricts the ManagedClusterAddOn informer of the manager to the addons added to the
+// manager, so a manager does not cache the ManagedClusterAddOns of all the other addons sharing the hub. The
+// ManifestWork informer is always restricted to the works labeled with the names of the added addons.
+//
+// If only one addon is added, the ManagedClusterAddOns are selected by the name. Otherwise they are selected by
+// the open-cluster-management.io/addon-name label, which is set on the ManagedClusterAddOns created by the
+// addon manager. The existing ManagedClusterAddOns created by others are labeled when the manager starts, and
+// the ones installed automatically are labeled when they are found to exist.
+func WithScopedAddonInformers() Option {
+	return func(manager *addonManager) {
+		manager.scopedAddonInformers = true
+	}
+}
+
+// WithManagedClusterManagementAddOns makes the manager create the ClusterManagementAddOn of each added addon at
+// startup, or adopt the existing one, and reconcile its drift. The ClusterManagementAddOn is annotated with
+// addon.open-cluster-management.io/lifecycle: addon-manager, and its addOnMeta and supportedConfigs are set from
+// the AddOnMeta and SupportedConfigGVRs of the addon options, the default configs set on it are kept.
+func WithManagedClusterManagementAddOns() Option {
+	return func(manager *addonManager) {
+		manager.manageClusterManagementAddOns = true
+	}
+}
+
+// WithAutoInstallExcludedClusters excludes the managed clusters selected by the label selector from the automatic
+// installation of the addons by their InstallStrategy, in addition to the clusters annotated with
+// addon.open-cluster-management.io/disable-automatic-installation: "true". If removeInstalled is true, the
+// ManagedClusterAddOns installed automatically on the excluded clusters are deleted, the ones created by the
+// users are kept. Otherwise only the new installations are skipped.
+func WithAutoInstallExcludedClusters(selector *metav1.LabelSelector, removeInstalled bool) Option {
+	return func(manager *addonManager) {
+		manager.autoInstallExcludedClusters = selector
+		manager.removeExcludedAutoInstalled = removeInstalled
+	}
+}
+
+// WithQueueOptions tunes the work queues of the controllers with the given names, e.g. addon-deploy-controller,
+// or of all the controllers without their own options if no name is given. The options set the rate limiter,
+// the backoff caps and the max retries of the failed keys, e.g. to retry faster on a hub managing thousands of
+// clusters. The options only apply to the controllers of this manager. Regardless of the options, the deletions
+// and the health degradation of the addons are processed ahead of the routine resyncs.
+func WithQueueOptions(options factory.QueueOptions, controllerNames ...string) Option {
+	return func(manager *addonManager) {
+		if len(controllerNames) == 0 {
+			manager.queueOptions[""] = options
+			return
+		}
+		for _, name := range controllerNames {
+			manager.queueOptions[name] = options
+		}
+	}
+}
+
+// WithLogVerbosity sets the log verbosity of the controllers with the given names, e.g. addon-deploy-controller,
+// or of all the controllers without their own verbosity if no name is given, regardless of the -v flag. The
+// logs of the controllers carry the cluster, addon and work of the sync, e.g. to debug one controller with the
+// level 4 on a hub running with the level 2. The verbosity only applies to the controllers of this manager.
+func WithLogVerbosity(verbosity int, controllerNames ...string) Option {
+	return func(manager *addonManager) {
+		if len(controllerNames) == 0 {
+			manager.logVerbosities[""] = verbosity
+			return
+		}
+		for _, name := range controllerNames {
+			manager.logVerbosities[name] = verbosity
+		}
+	}
+}
+
+// WithInitialInstallRateLimit limits the number of the addons receiving their first manifestworks per minute on
+// the hub, e.g. 200 to protect the hub when a whole fleet is re-registered. The installs held by the limit are
+// exposed by the addon_manager_initial_install_throttle_queue_length metric. It is unlimited by default.
+func WithInitialInstallRateLimit(installsPerMinute int) Option {
+	return func(manager *addonManager) {
+		manager.deployOptions.InitialInstallsPerMinute = installsPerMinute
+	}
+}
+
+// WithWorkApplyRateLimit limits the creates and updates of the ManifestWorks of the addons in each cluster
+// namespace to qps per second with the burst, e.g. 1 and 5, to protect the hub API server of a large fleet.
+// The applies that change nothing are skipped and not limited. It is unlimited by default.
+func WithWorkApplyRateLimit(qps float64, burst int) Option {
+	return func(manager *addonManager) {
+		manager.deployOptions.WorkApplyQPS = qps
+		manager.deployOptions.WorkApplyBurst = burst
+	}
+}
+
+// WithManifestsCleanupTimeout sets how long the uninstall of a deleting addon waits for its deploy ManifestWorks to
+// be removed from the managed cluster before the pre-delete hook runs and the registration of the addon, i.e. the
+// Roles, RoleBindings, CSRs and client certificates on the hub, is removed, e.g. when the managed cluster is
+// offline. It is 5m by default.
+func WithManifestsCleanupTimeout(timeout time.Duration) Option {
+	return func(manager *addonManager) {
+		manager.deployOptions.ManifestsCleanupTimeout = timeout
+	}
+}
+
+// WithStaleWorkPruneDryRun makes the addon manager only log the stale ManifestWorks of the addons, which are not
+// built from the manifests of the addon agents anymore, instead of deleting them, so the operators can check
+// which works would be pruned before enabling it. The stale works are deleted by default.
+func WithStaleWorkPruneDryRun(dryRun bool) Option {
+	return func(manager *addonManager) {
+		manager.deployOptions.StaleWorkPruneDryRun = dryRun
+	}
+}
+
+// WithDeployWorkers sets the number of the workers of the addon deploy controller, which render and apply the
+// ManifestWorks of the addons concurrently, e.g. 20 when the default configs of a ClusterManagementAddOn change on
+// a hub with thousands of clusters. The Manifests of the AgentAddons must be safe to be called concurrently if it
+// is more than 1. It is 1 by default.
+func WithDeployWorkers(workers int) Option {
+	return func(manager *addonManager) {
+		manager.deployWorkers = workers
+	}
+}
+
+// WithMetricsBindAddress serves the metrics of the addon manager on the address, e.g. ":8080", when the manager
+// is started. The metrics include the sync durations and counts of the controllers, the number of the managed
+// addons by Available condition, the ManifestWork apply errors, the config rollout progress and the work-queue
+// depths. The metrics server is disabled by default.
+func WithMetricsBindAddress(address string) Option {
+	return func(manager *addonManager) {
+		manager.metricsBindAddress = address
+	}
+}
+
+// WithHealthProbeBindAddress serves the /healthz and /readyz probes of the addon manager on the address, e.g.
+// ":8000", when the manager is started. /healthz fails if a controller is stuck in a sync, and /readyz fails
+// until the informer caches of the manager and its controllers are synced. It must be different from the
+// metrics bind address. The probes are disabled by default.
+func WithHealthProbeBindAddress(address string) Option {
+	return func(manager *addonManager) {
+		manager.healthProbeBindAddress = address
+	}
+}
+
+// WithShutdownDrainTimeout sets how long the controllers of the manager wait for their in-flight syncs to finish
+// when the context of the manager is done, e.g. 30s for the ManifestWork writes and the addon status patches of a
+// large fleet on a hub upgrade. The syncs still running after the timeout are cancelled. The timeout set by
+// factory.WithDrainTimeout on the context of the manager, or 10s, is used by default.
+func WithShutdownDrainTimeout(timeout time.Duration) Option {
+	return func(manager *addonManager) {
+		manager.drainTimeout = timeout
+	}
+}
+
+// WorkDriver returns the client the manager delivers the ManifestWorks of the addons through. The
+// ManifestWorks are created, updated, deleted and watched by the client.
+type WorkDriver func(ctx context.Context, config *rest.Config) (workv1client.Interface, error)
+
+// KubeWorkDriver delivers the ManifestWorks through the hub kube-apiserver, it is the default work driver.
+func KubeWorkDriver(_ context.Context, config *rest.Config) (workv1client.Interface, error) {
+	return workv1client.NewForConfig(config)
+}
+
+// MQTTWorkDriver delivers the ManifestWorks as cloudevents to the agents of the managed clusters over the MQTT
+// broker of the options, the agents report the status of the ManifestWorks back over the broker. The
+// ManifestWorks are persisted in the Secrets of the state namespace of the options on the hub.
+func MQTTWorkDriver(options *cloudeventswork.MQTTOptions) WorkDriver {
+	return func(ctx context.Context, config *rest.Config) (workv1client.Interface, error) {
+		return cloudeventswork.NewMQTTWorkClient(ctx, config, options)
+	}
+}
+
+// WithWorkDriver makes the manager deliver the ManifestWorks through the client of the driver instead of the
+// hub kube-apiserver, e.g. the MQTTWorkDriver, or a driver implemented out of the framework. The status of the
+// ManifestWorks is reported back through the watch of the client. The driver must not be nil, and it must return
+// a client when the manager is started.
+func WithWorkDriver(driver WorkDriver) Option {
+	return func(manager *addonManager) {
+		manager.workDriver = driver
+	}
+}
+
 // New returns a new Manager for creating addon agents.
-func New(config *rest.Config) (AddonManager, error) {
-	return &addonManager{
-		config:       config,
-		syncContexts: []factory.SyncContext{},
-		addonConfigs: map[schema.GroupVersionResource]bool{},
-		addonAgents:  map[string]agent.AgentAddon{},
-	}, nil
+func New(config *rest.Config, opts ...Option) (AddonManager, error) {
+	manager := &addonManager{
+		config:         config,
+		syncContexts:   []factory.SyncContext{},
+		addonConfigs:   map[schema.GroupVersionResource]bool{},
+		specHashFuncs:  map[schema.GroupVersionResource]agent.ConfigSpecHashFunc{},
+		addonAgents:    map[string]agent.AgentAddon{},
+		workDriver:     KubeWorkDriver,
+		queueOptions:   map[string]factory.QueueOptions{},
+		logVerbosities: map[string]int{},
+		stopped:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(manager)
+	}
+	if manager.workDriver == nil {
+		return nil, fmt.Errorf("the work driver of the addon manager is nil")
+	}
+	return manager, nil
+}
+
+// hubCABundle returns the CA bundle of the kubeconfig of the manager, which is the default CA bundle of the hub
+// kubeconfigs of the addon agents registered with tokens.
+func (a *addonManager) hubCABundle() ([]byte, error) {
+	if len(a.config.CAData) > 0 || len(a.config.CAFile) == 0 {
+		return a.config.CAData, nil
+	}
+	caBundle, err := os.ReadFile(a.config.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CA file of the hub kubeconfig: %w", err)
+	}
+	return caBundle, nil
+}
+
+// addonLabelListOptions selects the resources labeled with the names of the addons.
+func addonLabelListOptions(addonNames []string) func(listOptions *metav1.ListOptions) {
+	return func(listOptions *metav1.ListOptions) {
+		selector := &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{
+					Key:      addonv1alpha1.AddonLabelKey,
+					Operator: metav1.LabelSelectorOpIn,
+					Values:   addonNames,
+				},
+			},
+		}
+		listOptions.LabelSelector = metav1.FormatLabelSelector(selector)
+	}
+}
+
+// adoptManagedClusterAddOns labels the existing ManagedClusterAddOns of the addons created by others without the
+// addon label, so they are selected by the ManagedClusterAddOn informer scoped by the label. Nothing is done if
+// there is only one addon, the ManagedClusterAddOns are selected by the name then.
+func adoptManagedClusterAddOns(ctx context.Context, addonClient addonv1alpha1client.Interface, addonNames []string) error {
+	if len(addonNames) == 1 {
+		return nil
+	}
+	for _, addonName := range addonNames {
+		addons, err := addonClient.AddonV1alpha1().ManagedClusterAddOns(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("metadata.name", addonName).String(),
+		})
+		if err != nil {
+			return err
+		}
+		for _, addon := range addons.Items {
+			if addon.Name != addonName || addon.Labels[addonv1alpha1.AddonLabelKey] == addonName {
+				continue
+			}
+			if err := addoninstall.AdoptAddon(ctx, addonClient, addon.Namespace, addonName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// managedClusterAddOnListOptions selects the ManagedClusterAddOns of the addons, by the name if there is only
+// one addon, or by the label of the addon names.
+func managedClusterAddOnListOptions(addonNames []string) func(listOptions *metav1.ListOptions) {
+	if len(addonNames) == 1 {
+		return func(listOptions *metav1.ListOptions) {
+			listOptions.FieldSelector = fields.OneTermEqualSelector("metadata.name", addonNames[0]).String()
+		}
+	}
+	return addonLabelListOptions(addonNames)
 }
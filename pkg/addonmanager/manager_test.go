@@ -0,0 +1,302 @@
+package addonmanager
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	clienttesting "k8s.io/client-go/testing"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	workv1client "open-cluster-management.io/api/client/work/clientset/versioned"
+	fakework "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/agentdeploy"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+)
+
+func TestManagedClusterAddOnListOptions(t *testing.T) {
+	cases := []struct {
+		name                  string
+		addonNames            []string
+		expectedLabelSelector string
+		expectedFieldSelector string
+	}{
+		{
+			name:                  "one addon",
+			addonNames:            []string{"addon1"},
+			expectedFieldSelector: "metadata.name=addon1",
+		},
+		{
+			name:                  "multiple addons",
+			addonNames:            []string{"addon1", "addon2"},
+			expectedLabelSelector: "open-cluster-management.io/addon-name in (addon1,addon2)",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			listOptions := &metav1.ListOptions{}
+			managedClusterAddOnListOptions(c.addonNames)(listOptions)
+			if listOptions.LabelSelector != c.expectedLabelSelector {
+				t.Errorf("expected label selector %q, but got %q", c.expectedLabelSelector, listOptions.LabelSelector)
+			}
+			if listOptions.FieldSelector != c.expectedFieldSelector {
+				t.Errorf("expected field selector %q, but got %q", c.expectedFieldSelector, listOptions.FieldSelector)
+			}
+		})
+	}
+}
+
+func TestAdoptManagedClusterAddOns(t *testing.T) {
+	labeled := addontesting.NewAddon("addon2", "cluster1")
+	labeled.Labels = map[string]string{addonv1alpha1.AddonLabelKey: "addon2"}
+	fakeAddonClient := fakeaddon.NewSimpleClientset(
+		addontesting.NewAddon("addon1", "cluster1"),
+		addontesting.NewAddon("addon1", "cluster2"),
+		labeled,
+		addontesting.NewAddon("other", "cluster1"),
+	)
+
+	if err := adoptManagedClusterAddOns(context.TODO(), fakeAddonClient, []string{"addon1"}); err != nil {
+		t.Fatal(err)
+	}
+	addontesting.AssertNoActions(t, fakeAddonClient.Actions())
+
+	if err := adoptManagedClusterAddOns(context.TODO(), fakeAddonClient, []string{"addon1", "addon2"}); err != nil {
+		t.Fatal(err)
+	}
+	var patched []string
+	for _, action := range fakeAddonClient.Actions() {
+		if patch, ok := action.(clienttesting.PatchActionImpl); ok {
+			patched = append(patched, patch.Namespace+"/"+patch.Name)
+		}
+	}
+	expected := []string{"cluster1/addon1", "cluster2/addon1"}
+	if !reflect.DeepEqual(patched, expected) {
+		t.Errorf("expected the unlabeled addons %v adopted, but got %v", expected, patched)
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	manager, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manager.(*addonManager).scopedAddonInformers {
+		t.Errorf("expected the addon informers are not scoped by default")
+	}
+
+	manager, err = New(nil, WithScopedAddonInformers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !manager.(*addonManager).scopedAddonInformers {
+		t.Errorf("expected the addon informers are scoped")
+	}
+
+	manager, err = New(nil,
+		WithQueueOptions(factory.QueueOptions{MaxRetries: 5}),
+		WithQueueOptions(factory.QueueOptions{MaxRetries: 10}, "addon-deploy-controller"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedQueueOptions := map[string]factory.QueueOptions{
+		"":                        {MaxRetries: 5},
+		"addon-deploy-controller": {MaxRetries: 10},
+	}
+	if !reflect.DeepEqual(manager.(*addonManager).queueOptions, expectedQueueOptions) {
+		t.Errorf("expected queue options %v, but got %v", expectedQueueOptions, manager.(*addonManager).queueOptions)
+	}
+
+	manager, err = New(nil, WithLogVerbosity(2), WithLogVerbosity(4, "addon-deploy-controller"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLogVerbosities := map[string]int{"": 2, "addon-deploy-controller": 4}
+	if !reflect.DeepEqual(manager.(*addonManager).logVerbosities, expectedLogVerbosities) {
+		t.Errorf("expected log verbosities %v, but got %v", expectedLogVerbosities, manager.(*addonManager).logVerbosities)
+	}
+}
+
+func TestNewWithDeployOptions(t *testing.T) {
+	manager, err := New(nil,
+		WithInitialInstallRateLimit(200),
+		WithWorkApplyRateLimit(1, 5),
+		WithStaleWorkPruneDryRun(true),
+		WithManifestsCleanupTimeout(time.Minute),
+		WithDeployWorkers(20),
+		WithMetricsBindAddress(":8080"),
+		WithHealthProbeBindAddress(":8000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedDeployOptions := agentdeploy.Options{
+		InitialInstallsPerMinute: 200, WorkApplyQPS: 1, WorkApplyBurst: 5, StaleWorkPruneDryRun: true,
+		ManifestsCleanupTimeout: time.Minute}
+	if !reflect.DeepEqual(manager.(*addonManager).deployOptions, expectedDeployOptions) {
+		t.Errorf("expected deploy options %v, but got %v", expectedDeployOptions, manager.(*addonManager).deployOptions)
+	}
+	if manager.(*addonManager).deployWorkers != 20 {
+		t.Errorf("expected 20 workers of the deploy controller, but got %d", manager.(*addonManager).deployWorkers)
+	}
+	if manager.(*addonManager).metricsBindAddress != ":8080" || manager.(*addonManager).healthProbeBindAddress != ":8000" {
+		t.Errorf("expected the metrics and health probe bind addresses of the manager")
+	}
+
+	// the options of a manager do not leak into the other managers of the process
+	other, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(other.(*addonManager).deployOptions, agentdeploy.Options{}) {
+		t.Errorf("expected the default deploy options of the other manager, but got %v", other.(*addonManager).deployOptions)
+	}
+}
+
+func TestNewWithWorkDriver(t *testing.T) {
+	manager, err := New(&rest.Config{Host: "https://hub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.(*addonManager).workDriver(context.TODO(), &rest.Config{Host: "https://hub"}); err != nil {
+		t.Errorf("expected the kube work driver by default, but got %v", err)
+	}
+
+	fakeWorkClient := fakework.NewSimpleClientset()
+	manager, err = New(nil, WithWorkDriver(func(_ context.Context, _ *rest.Config) (workv1client.Interface, error) {
+		return fakeWorkClient, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workClient, err := manager.(*addonManager).workDriver(context.TODO(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workClient != fakeWorkClient {
+		t.Errorf("expected the work client of the driver")
+	}
+
+	if _, err := New(nil, WithWorkDriver(nil)); err == nil {
+		t.Errorf("expected error of the nil work driver")
+	}
+
+	manager, err = New(&rest.Config{Host: "https://hub"},
+		WithWorkDriver(func(_ context.Context, _ *rest.Config) (workv1client.Interface, error) {
+			return nil, nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Start(context.TODO()); err == nil {
+		t.Errorf("expected error of the work driver without a work client")
+	}
+	select {
+	case <-manager.Stopped():
+	default:
+		t.Errorf("expected the manager failed to start to be stopped")
+	}
+}
+
+func TestControllerOptions(t *testing.T) {
+	manager, err := New(nil,
+		WithQueueOptions(factory.QueueOptions{MaxRetries: 5}),
+		WithQueueOptions(factory.QueueOptions{MaxRetries: 10}, "addon-deploy-controller"),
+		WithLogVerbosity(4, "addon-deploy-controller"),
+		WithShutdownDrainTimeout(30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if options := manager.(*addonManager).controllerOptions("addon-deploy-controller"); options.Queue.MaxRetries != 10 {
+		t.Errorf("expected the queue options of the controller, but got %v", options.Queue)
+	}
+	if options := manager.(*addonManager).controllerOptions("addon-install-controller"); options.Queue.MaxRetries != 5 {
+		t.Errorf("expected the default queue options of the manager, but got %v", options.Queue)
+	}
+	if options := manager.(*addonManager).controllerOptions("addon-deploy-controller"); options.LogVerbosity == nil ||
+		*options.LogVerbosity != 4 {
+		t.Errorf("expected the log verbosity 4 of the controller")
+	}
+	if options := manager.(*addonManager).controllerOptions("addon-install-controller"); options.DrainTimeout != 30*time.Second {
+		t.Errorf("expected the drain timeout of the manager, but got %v", options.DrainTimeout)
+	}
+	if options := manager.(*addonManager).controllerOptions("addon-install-controller"); options.LogVerbosity != nil {
+		t.Errorf("expected the controller follows the klog verbosity, but got %d", *options.LogVerbosity)
+	}
+	// the options of a manager do not leak into the other managers of the process
+	options := other.(*addonManager).controllerOptions("addon-deploy-controller")
+	if options.Queue.MaxRetries != 0 || options.LogVerbosity != nil || options.DrainTimeout != 0 {
+		t.Errorf("expected the default options of the other manager, but got %v", options)
+	}
+}
+
+func TestStoppedOnFailedStart(t *testing.T) {
+	manager, err := New(&rest.Config{Host: "https://hub"}, WithWorkDriver(
+		func(_ context.Context, _ *rest.Config) (workv1client.Interface, error) {
+			return nil, fmt.Errorf("the work driver is not available")
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// starting the manager again does not panic on closing the stopped channel
+	for i := 0; i < 2; i++ {
+		if err := manager.Start(context.TODO()); err == nil {
+			t.Fatalf("expected the manager fails to start")
+		}
+	}
+	select {
+	case <-manager.Stopped():
+	default:
+		t.Errorf("expected the stopped channel closed when the manager fails to start")
+	}
+}
+
+type testAgent struct {
+	options agent.AgentAddonOptions
+}
+
+func (a *testAgent) Manifests(_ *clusterv1.ManagedCluster, _ *addonv1alpha1.ManagedClusterAddOn) ([]runtime.Object, error) {
+	return nil, nil
+}
+
+func (a *testAgent) GetAgentAddonOptions() agent.AgentAddonOptions {
+	return a.options
+}
+
+func TestAddAgent(t *testing.T) {
+	manager, err := New(&rest.Config{Host: "https://hub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the options are invalid since the registration has no CSR configurations
+	err = manager.AddAgent(&testAgent{options: agent.AgentAddonOptions{
+		AddonName:    "invalid",
+		Registration: &agent.RegistrationOption{},
+	}})
+	if err == nil {
+		t.Errorf("expected error adding the addon with invalid options")
+	}
+
+	if err := manager.AddAgent(&testAgent{options: agent.AgentAddonOptions{AddonName: "test"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := manager.AddAgent(&testAgent{options: agent.AgentAddonOptions{AddonName: "test"}}); err == nil {
+		t.Errorf("expected error adding the addon twice")
+	}
+}
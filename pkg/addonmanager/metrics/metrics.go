@@ -0,0 +1,186 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/component-base/metrics/legacyregistry"
+	// register the work-queue metrics provider, so the depth, latency and retries of the named work queues of
+	// the controllers are exposed.
+	_ "k8s.io/component-base/metrics/prometheus/workqueue"
+	"k8s.io/klog/v2"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+)
+
+// Path is the path the metrics are served by the metrics server.
+const Path = "/metrics"
+
+// manifestWorkApplyErrors counts the failures of applying the ManifestWorks of the addons.
+var manifestWorkApplyErrors = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "addon_manager_manifestwork_apply_errors_total",
+		Help: "The number of the failures of applying the ManifestWorks of the addons, labeled by addon.",
+	},
+	[]string{"addon"},
+)
+
+// manifestWorkUpdatesAvoided counts the applies of the ManifestWorks skipped since nothing is changed.
+var manifestWorkUpdatesAvoided = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "addon_manager_manifestwork_updates_avoided_total",
+		Help: "The number of the applies of the ManifestWorks skipped since the spec is not changed.",
+	},
+)
+
+// manifestWorkDrifts counts the ManifestWorks of the addons detected as modified out-of-band on the hub.
+var manifestWorkDrifts = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "addon_manager_manifestwork_drifts_total",
+		Help: "The number of the drifts of the ManifestWorks of the addons from the rendered manifests, labeled by addon.",
+	},
+	[]string{"addon"},
+)
+
+func init() {
+	legacyregistry.RawMustRegister(manifestWorkApplyErrors, manifestWorkUpdatesAvoided, manifestWorkDrifts)
+}
+
+// RecordManifestWorkApplyError counts a failure of applying a ManifestWork of the addon.
+func RecordManifestWorkApplyError(addonName string) {
+	manifestWorkApplyErrors.WithLabelValues(addonName).Inc()
+}
+
+// RecordManifestWorkUpdateAvoided counts an apply of a ManifestWork skipped since the spec is not changed.
+func RecordManifestWorkUpdateAvoided() {
+	manifestWorkUpdatesAvoided.Inc()
+}
+
+// RecordManifestWorkDrift counts a drift of a ManifestWork of the addon from the rendered manifests.
+func RecordManifestWorkDrift(addonName string) {
+	manifestWorkDrifts.WithLabelValues(addonName).Inc()
+}
+
+var (
+	managedAddonsDesc = prometheus.NewDesc(
+		"addon_manager_managed_addons",
+		"The number of the ManagedClusterAddOns, labeled by addon and the status of the Available condition.",
+		[]string{"addon", "available"}, nil,
+	)
+	rolloutAddonsDesc = prometheus.NewDesc(
+		"addon_manager_config_rollout_addons",
+		"The number of the ManagedClusterAddOns with desired configs, labeled by addon and rollout state. "+
+			"The state is applied if all the desired configs are applied, otherwise it is progressing.",
+		[]string{"addon", "state"}, nil,
+	)
+)
+
+// addonCollector collects the addon fleet metrics from the ManagedClusterAddOn cache on each scrape, so the
+// gauges never drift from the addons on the hub.
+type addonCollector struct {
+	addonLister addonlisterv1alpha1.ManagedClusterAddOnLister
+}
+
+// NewAddonCollector returns a collector of the number of the managed addons by Available condition and the
+// config rollout progress of the addons.
+func NewAddonCollector(addonLister addonlisterv1alpha1.ManagedClusterAddOnLister) prometheus.Collector {
+	return &addonCollector{addonLister: addonLister}
+}
+
+func (c *addonCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- managedAddonsDesc
+	ch <- rolloutAddonsDesc
+}
+
+func (c *addonCollector) Collect(ch chan<- prometheus.Metric) {
+	addons, err := c.addonLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("failed to list the ManagedClusterAddOns for metrics: %v", err)
+		return
+	}
+
+	available := map[[2]string]int{}
+	rollout := map[[2]string]int{}
+	for _, addon := range addons {
+		status := string(metav1.ConditionUnknown)
+		if cond := meta.FindStatusCondition(addon.Status.Conditions, addonapiv1alpha1.ManagedClusterAddOnConditionAvailable); cond != nil {
+			status = string(cond.Status)
+		}
+		available[[2]string{addon.Name, status}]++
+
+		if state, ok := rolloutState(addon); ok {
+			rollout[[2]string{addon.Name, state}]++
+		}
+	}
+
+	for key, count := range available {
+		ch <- prometheus.MustNewConstMetric(managedAddonsDesc, prometheus.GaugeValue, float64(count), key[0], key[1])
+	}
+	for key, count := range rollout {
+		ch <- prometheus.MustNewConstMetric(rolloutAddonsDesc, prometheus.GaugeValue, float64(count), key[0], key[1])
+	}
+}
+
+// rolloutState returns the config rollout state of the addon, false if the addon has no desired config.
+func rolloutState(addon *addonapiv1alpha1.ManagedClusterAddOn) (string, bool) {
+	hasDesired := false
+	for _, ref := range addon.Status.ConfigReferences {
+		if ref.DesiredConfig == nil {
+			continue
+		}
+		hasDesired = true
+		if ref.LastAppliedConfig == nil || *ref.DesiredConfig != *ref.LastAppliedConfig {
+			return "progressing", true
+		}
+	}
+	if !hasDesired {
+		return "", false
+	}
+	return "applied", true
+}
+
+// Handler returns an HTTP handler serving the metrics of the legacy registry, which includes the controller
+// sync durations, the work-queue metrics and the ManifestWork apply errors, together with the given collectors.
+func Handler(collectors ...prometheus.Collector) (http.Handler, error) {
+	registry := prometheus.NewRegistry()
+	for _, collector := range collectors {
+		if err := registry.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return promhttp.HandlerFor(prometheus.Gatherers{legacyregistry.DefaultGatherer, registry},
+		promhttp.HandlerOpts{}), nil
+}
+
+// Serve serves the metrics handler on the address until the context is done. The metrics are also served in the
+// OpenMetrics format on factory.OpenMetricsPath.
+func Serve(ctx context.Context, address string, handler http.Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, handler)
+	mux.Handle(factory.OpenMetricsPath, factory.OpenMetricsHandler())
+
+	server := &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			klog.Errorf("failed to shutdown the metrics server: %v", err)
+		}
+	}()
+
+	klog.Infof("serving the addon manager metrics on %s%s", address, Path)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+)
+
+func newAddon(name, namespace string, available metav1.ConditionStatus,
+	configs ...addonapiv1alpha1.ConfigReference) *addonapiv1alpha1.ManagedClusterAddOn {
+	addon := addontesting.NewAddon(name, namespace)
+	if len(available) > 0 {
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:   addonapiv1alpha1.ManagedClusterAddOnConditionAvailable,
+			Status: available,
+			Reason: "test",
+		})
+	}
+	addon.Status.ConfigReferences = configs
+	return addon
+}
+
+func newConfigReference(desired, lastApplied string) addonapiv1alpha1.ConfigReference {
+	ref := addonapiv1alpha1.ConfigReference{
+		ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{Group: "test", Resource: "tests"},
+		DesiredConfig: &addonapiv1alpha1.ConfigSpecHash{
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{Name: "test"},
+			SpecHash:       desired,
+		},
+	}
+	if len(lastApplied) > 0 {
+		ref.LastAppliedConfig = &addonapiv1alpha1.ConfigSpecHash{
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{Name: "test"},
+			SpecHash:       lastApplied,
+		}
+	}
+	return ref
+}
+
+func TestAddonCollector(t *testing.T) {
+	addons := []*addonapiv1alpha1.ManagedClusterAddOn{
+		newAddon("test", "cluster1", metav1.ConditionTrue, newConfigReference("hash1", "hash1")),
+		newAddon("test", "cluster2", metav1.ConditionTrue, newConfigReference("hash1", "hash0")),
+		newAddon("test", "cluster3", metav1.ConditionFalse, newConfigReference("hash1", "")),
+		newAddon("test", "cluster4", ""),
+		newAddon("other", "cluster1", metav1.ConditionTrue),
+	}
+
+	addonInformers := addoninformers.NewSharedInformerFactory(fakeaddon.NewSimpleClientset(), 10*time.Minute)
+	addonStore := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore()
+	for _, addon := range addons {
+		if err := addonStore.Add(addon); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := `
+# HELP addon_manager_config_rollout_addons The number of the ManagedClusterAddOns with desired configs, labeled by addon and rollout state. The state is applied if all the desired configs are applied, otherwise it is progressing.
+# TYPE addon_manager_config_rollout_addons gauge
+addon_manager_config_rollout_addons{addon="test",state="applied"} 1
+addon_manager_config_rollout_addons{addon="test",state="progressing"} 2
+# HELP addon_manager_managed_addons The number of the ManagedClusterAddOns, labeled by addon and the status of the Available condition.
+# TYPE addon_manager_managed_addons gauge
+addon_manager_managed_addons{addon="other",available="True"} 1
+addon_manager_managed_addons{addon="test",available="False"} 1
+addon_manager_managed_addons{addon="test",available="True"} 2
+addon_manager_managed_addons{addon="test",available="Unknown"} 1
+`
+	collector := NewAddonCollector(addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister())
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	RecordManifestWorkApplyError("test")
+	RecordManifestWorkUpdateAvoided()
+
+	addonInformers := addoninformers.NewSharedInformerFactory(fakeaddon.NewSimpleClientset(), 10*time.Minute)
+	if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(
+		newAddon("test", "cluster1", metav1.ConditionTrue)); err != nil {
+		t.Fatal(err)
+	}
+
+	handler, err := Handler(NewAddonCollector(addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", Path, nil))
+	body := recorder.Body.String()
+	for _, metric := range []string{
+		`addon_manager_manifestwork_apply_errors_total{addon="test"} 1`,
+		`addon_manager_managed_addons{addon="test",available="True"} 1`,
+		`addon_manager_manifestwork_updates_avoided_total 1`,
+	} {
+		if !strings.Contains(body, metric) {
+			t.Errorf("expected metric %q in the response, got %s", metric, body)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package agent
+
+import "errors"
+
+// ValuesInvalidError is returned by the Manifests of an AgentAddon if the values of the agent are invalid, e.g.
+// they fail the validation against the values schema of the addon. The addon manager sets the ValuesInvalid
+// condition of the ManagedClusterAddOn with the error instead of rendering the manifests.
+type ValuesInvalidError struct {
+	Err error
+}
+
+func (e *ValuesInvalidError) Error() string {
+	return "invalid values: " + e.Err.Error()
+}
+
+func (e *ValuesInvalidError) Unwrap() error {
+	return e.Err
+}
+
+// IsValuesInvalid returns true if the err is or wraps a ValuesInvalidError.
+func IsValuesInvalid(err error) bool {
+	var valuesInvalidErr *ValuesInvalidError
+	return errors.As(err, &valuesInvalidErr)
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FeatureGates are the states of the feature gates of the addon agent by the gate names. It is in the format of
+// "Name1=true,Name2=false" as a string, the same as the --feature-gates flag of the Kubernetes components, and
+// it implements the pflag.Value interface so it can be set by a flag of the addon manager, e.g.
+//
+//	gates := agent.FeatureGates{"Foo": false}
+//	flags.Var(&gates, "addon-feature-gates", "The default feature gates of the addon agent.")
+type FeatureGates map[string]bool
+
+// ParseFeatureGates parses the feature gates in the format of "Name1=true,Name2=false".
+func ParseFeatureGates(value string) (FeatureGates, error) {
+	gates := FeatureGates{}
+	if err := gates.Set(value); err != nil {
+		return nil, err
+	}
+	return gates, nil
+}
+
+// Enabled returns true if the feature gate is enabled, the unknown gates are disabled.
+func (f FeatureGates) Enabled(name string) bool {
+	return f[name]
+}
+
+// String returns the feature gates sorted by the names in the format of "Name1=true,Name2=false".
+func (f FeatureGates) String() string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", name, f[name]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set sets the states of the feature gates in the format of "Name1=true,Name2=false", the other gates are kept.
+func (f *FeatureGates) Set(value string) error {
+	if *f == nil {
+		*f = FeatureGates{}
+	}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		name, state, found := strings.Cut(pair, "=")
+		if !found {
+			return fmt.Errorf("missing the state of feature gate %q", pair)
+		}
+		name = strings.TrimSpace(name)
+		if err := validateFeatureGateName(name); err != nil {
+			return err
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(state))
+		if err != nil {
+			return fmt.Errorf("invalid state %q of feature gate %q", state, name)
+		}
+		(*f)[name] = enabled
+	}
+	return nil
+}
+
+// Type returns the type of the flag value.
+func (f *FeatureGates) Type() string {
+	return "mapStringBool"
+}
+
+func validateFeatureGateName(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("the name of a feature gate should be set")
+	}
+	if strings.ContainsAny(name, "=, ") {
+		return fmt.Errorf("invalid feature gate name %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFeatureGates(t *testing.T) {
+	cases := []struct {
+		name           string
+		value          string
+		expectedGates  FeatureGates
+		expectedString string
+		expectedErr    bool
+	}{
+		{
+			name:          "empty",
+			expectedGates: FeatureGates{},
+		},
+		{
+			name:           "feature gates",
+			value:          " Foo=true, Bar=false,",
+			expectedGates:  FeatureGates{"Foo": true, "Bar": false},
+			expectedString: "Bar=false,Foo=true",
+		},
+		{
+			name:        "missing state",
+			value:       "Foo",
+			expectedErr: true,
+		},
+		{
+			name:        "invalid state",
+			value:       "Foo=yes",
+			expectedErr: true,
+		},
+		{
+			name:        "missing name",
+			value:       "=true",
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gates, err := ParseFeatureGates(c.value)
+			if c.expectedErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if c.expectedErr {
+				return
+			}
+			if !reflect.DeepEqual(gates, c.expectedGates) {
+				t.Errorf("expected feature gates %v, but got %v", c.expectedGates, gates)
+			}
+			if gates.String() != c.expectedString {
+				t.Errorf("expected %q, but got %q", c.expectedString, gates.String())
+			}
+		})
+	}
+}
+
+func TestFeatureGatesFlag(t *testing.T) {
+	gates := FeatureGates{"Foo": false, "Bar": true}
+	if err := gates.Set("Foo=true"); err != nil {
+		t.Fatal(err)
+	}
+	if !gates.Enabled("Foo") || !gates.Enabled("Bar") || gates.Enabled("Unknown") {
+		t.Errorf("expected the set gate is enabled and the others are kept, but got %v", gates)
+	}
+
+	var unset FeatureGates
+	if err := unset.Set("Foo=true"); err != nil {
+		t.Fatal(err)
+	}
+	if !unset.Enabled("Foo") {
+		t.Errorf("expected the gate is set on the nil feature gates, but got %v", unset)
+	}
+}
@@ -2,12 +2,15 @@ package agent
 
 import (
 	"fmt"
+	"time"
 
 	certificatesv1 "k8s.io/api/certificates/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
@@ -38,6 +41,22 @@ type AgentAddon interface {
 	GetAgentAddonOptions() AgentAddonOptions
 }
 
+// WorksBuilderOverride is an optional advanced interface of the AgentAddon for the addons which need full
+// control over the fields of the ManifestWorks, e.g. the executor, the manifest configs, the delete options,
+// or multiple works with special semantics.
+// If an AgentAddon implements this interface, the deploy ManifestWorks are built by BuildWorks instead of from
+// the Manifests, and the framework only sets the namespace, labels and owner of the works, applies them,
+// deletes the stale works of the addon and aggregates their status into the addon. The Manifests is still
+// used to build the pre-delete hook ManifestWork.
+// The works are deployed in the managed cluster namespace only, they are not deployed in the Hosted mode.
+type WorksBuilderOverride interface {
+	AgentAddon
+
+	// BuildWorks returns the ManifestWorks to be deployed for the addon on the managed cluster. Each work
+	// must have a unique name which is stable across the calls, the works of the addon not returned are deleted.
+	BuildWorks(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) ([]*workapiv1.ManifestWork, error)
+}
+
 // AgentAddonOptions prescribes the future customization for the addon.
 type AgentAddonOptions struct {
 	// AddonName is the name of the addon.
@@ -70,8 +89,141 @@ type AgentAddonOptions struct {
 	// SupportedConfigGVRs is a list of addon supported configuration GroupVersionResource
 	// each configuration GroupVersionResource should be unique
 	SupportedConfigGVRs []schema.GroupVersionResource
+
+	// ConfigSpecHashFuncs overrides how the spec hash of a supported configuration is computed, keyed
+	// by the configuration GroupVersionResource. The spec hash is recorded in the desiredConfig and
+	// lastAppliedConfig of the addon status and is used to determine whether a configuration changes.
+	// If the func of a configuration is not set, the hash of the spec field of the configuration is used.
+	// +optional
+	ConfigSpecHashFuncs map[schema.GroupVersionResource]ConfigSpecHashFunc
+
+	// HubDependencies is a list of Secrets/ConfigMaps on the hub that the manifests of the addon agent
+	// depend on, e.g. a CA bundle read by a GetValuesFunc. The manifests of the addon are re-rendered
+	// when any of these objects changes.
+	// +optional
+	HubDependencies []HubDependency
+
+	// AgentInstallNamespace returns the namespace the addon agent is installed in on the managed cluster,
+	// overriding the spec.installNamespace of the ManagedClusterAddOn. It is used as the namespace of the
+	// registration (hub kubeconfig secret and lease) and of the rendered manifests. If it returns an empty
+	// string, the spec.installNamespace is used. See utils.AgentInstallNamespaceFromDeploymentConfigFunc.
+	// +optional
+	AgentInstallNamespace func(addon *addonapiv1alpha1.ManagedClusterAddOn) (string, error)
+
+	// ManifestConfigs is a list of per-resource configurations set on the ManifestWorks of the addon agent,
+	// e.g. the update strategy (ServerSideApply or CreateOnly) of a resource. The probe rules of the work
+	// health prober are merged into the configuration of the same resource.
+	// +optional
+	ManifestConfigs []workapiv1.ManifestConfigOption
+
+	// WorkConfiguration defines the delete option and the executor of the ManifestWorks of the addon agent.
+	// If nil, the ManifestWorks are deleted in the background and applied by the work agent itself.
+	// +optional
+	WorkConfiguration *WorkConfiguration
+
+	// DeletionPolicy defines whether the resources of the addon agent are deleted from the managed cluster or
+	// orphaned on it when the ManagedClusterAddOn is deleted, e.g. to migrate the addon agent to be managed by
+	// itself. It is overridden per ManagedClusterAddOn by the addon.open-cluster-management.io/deletion-policy
+	// annotation. If empty, DeletionPolicyCascade is used.
+	// +optional
+	DeletionPolicy DeletionPolicy
+
+	// FeatureGates are the feature gates supported by the addon agent and their default states, e.g. set from
+	// the flags of the addon manager. The states are overridden per cluster by the featureGates customized
+	// variable of the AddOnDeploymentConfigs, see addonfactory.GetFeatureGates.
+	// +optional
+	FeatureGates FeatureGates
+
+	// Version is the desired version of the addon agent, e.g. the image tag of the agent. It is stamped on the
+	// deploy ManifestWorks of the addon by the addon.open-cluster-management.io/agent-version annotation, and
+	// the version applied on each managed cluster is compared with it by the AgentVersionUpToDate condition of
+	// the ManagedClusterAddOn, so the clusters still running the old agent are found during a rollout.
+	// +optional
+	Version string
+
+	// ManifestDriftDetection stamps the ManifestWorks of the addon agent with the digest of their spec, and
+	// detects the ManifestWorks modified out-of-band on the hub, e.g. by kubectl edit. The drift is reported
+	// by the ManifestDrifted condition of the ManagedClusterAddOn. If nil, the drift is not detected and the
+	// ManifestWorks are always restored to the rendered manifests.
+	// +optional
+	ManifestDriftDetection *ManifestDriftDetection
+
+	// AddOnMeta is the display name and description of the addon set on its ClusterManagementAddOn, when the
+	// ClusterManagementAddOn is created or adopted by the addon manager. If the display name is empty, the
+	// addon name is used.
+	// +optional
+	AddOnMeta *addonapiv1alpha1.AddOnMeta
+}
+
+// WorkConfiguration is the configuration of the ManifestWorks of an addon agent.
+type WorkConfiguration struct {
+	// DeleteOption is the delete option of the ManifestWorks of the addon agent, e.g. Orphan to keep the
+	// resources on the managed cluster when the ManifestWorks are deleted. The resources with the
+	// addon.open-cluster-management.io/deletion-orphan annotation are always orphaned.
+	// +optional
+	DeleteOption *workapiv1.DeleteOption
+
+	// Executor is the subject the work agent uses to apply the resources of the ManifestWorks of the
+	// addon agent, including the pre-delete hook ManifestWork.
+	// +optional
+	Executor *workapiv1.ManifestWorkExecutor
+
+	// ServerSideApply makes the work agent apply the resources of the addon agent with server side apply by
+	// a field manager owned by the framework, instead of updating the whole resources. The update strategy
+	// set in the ManifestConfigs of a resource is kept. The conflicts with the fields owned by another field
+	// manager are reported by the ManifestApplyConflict condition of the ManagedClusterAddOn.
+	// +optional
+	ServerSideApply bool
+}
+
+// ManifestDriftDetection is the configuration of the drift detection of the ManifestWorks of an addon agent.
+type ManifestDriftDetection struct {
+	// AutoRestore restores the drifted ManifestWorks to the rendered manifests. If false, the out-of-band
+	// modifications are kept until the manifests of the addon agent are rendered differently.
+	// +optional
+	AutoRestore bool
+}
+
+// DeletionPolicy is the policy of the resources of the addon agent on the managed cluster when the addon is deleted.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyCascade deletes the resources of the addon agent with the ManifestWorks by the delete option
+	// of the WorkConfiguration.
+	DeletionPolicyCascade DeletionPolicy = "Cascade"
+
+	// DeletionPolicyOrphan orphans all the resources of the addon agent on the managed cluster when the
+	// ManifestWorks are deleted.
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// HubDependencyKind is the kind of a hub object that the manifests of an addon agent depend on.
+type HubDependencyKind string
+
+const (
+	HubDependencyKindSecret    HubDependencyKind = "Secret"
+	HubDependencyKindConfigMap HubDependencyKind = "ConfigMap"
+)
+
+// HubDependency is a reference to a hub object that the manifests of an addon agent depend on.
+type HubDependency struct {
+	// Kind is the kind of the object, Secret or ConfigMap.
+	// +required
+	Kind HubDependencyKind
+
+	// Namespace is the namespace of the object. If it is empty, the object is in the namespace of each
+	// managed cluster, and only the addon on that cluster is re-rendered when the object changes.
+	// +optional
+	Namespace string
+
+	// Name is the name of the object.
+	// +required
+	Name string
 }
 
+// ConfigSpecHashFunc computes the spec hash of an addon configuration.
+type ConfigSpecHashFunc func(config *unstructured.Unstructured) (string, error)
+
 type CSRSignerFunc func(csr *certificatesv1.CertificateSigningRequest) []byte
 
 type CSRApproveFunc func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn, csr *certificatesv1.CertificateSigningRequest) bool
@@ -90,13 +242,16 @@ type RegistrationOption struct {
 	CSRConfigurations func(cluster *clusterv1.ManagedCluster) []addonapiv1alpha1.RegistrationConfig
 
 	// Namespace is the namespace where registraiton credential will be put on the managed cluster. It
-	// will be overridden by installNamespace on ManagedClusterAddon spec if set
+	// will be overridden by installNamespace on ManagedClusterAddon spec if set.
+	// It is deprecated in favor of AgentAddonOptions.AgentInstallNamespace, which sets the namespace of both
+	// the registration and the manifests.
 	Namespace string
 
 	// CSRApproveCheck checks whether the addon agent registration should be approved by the hub.
 	// Addon hub controller can implement this func to auto-approve all the CSRs. A better CSR check is
 	// recommended to include (1) the validity of requester's requesting identity and (2) the other request
-	// payload such as key-usages.
+	// payload such as key-usages. The built-in approvers in the utils package, e.g. DefaultCSRApprovePolicy,
+	// can be composed with custom checks by UnionCSRApprover and AnyCSRApprover.
 	// If the function is not set, the registration and certificate renewal of addon agent needs to be approved
 	// manually on hub.
 	// NB auto-approving csr requires the addon manager to have sufficient RBAC permission for the target
@@ -118,6 +273,59 @@ type RegistrationOption struct {
 	// The returned byte array shall be a valid non-nil PEM encoded x509 certificate.
 	// +optional
 	CSRSign CSRSignerFunc
+
+	// CertificateRotation enables the hub to issue the client certificates of the addon agent for the
+	// registrations with customized signers. The hub generates the private key, signs the certificate with
+	// CSRSign, stores them in a secret in the managed cluster namespace, and renews the certificate before
+	// it expires. The secret can be declared as a HubDependency to deliver the rotated certificate to the
+	// managed cluster. It requires CSRSign to be set.
+	// +optional
+	CertificateRotation *CertificateRotation
+
+	// TokenRegistration registers the addon agent with a ServiceAccount token instead of a client certificate
+	// of the kube-apiserver-client signer, for the hubs whose signer can not issue the client certificates.
+	// The hub creates the ServiceAccount constants.TokenServiceAccountName in the managed cluster namespace,
+	// requests a token of it, and rotates the token before it expires. The hub kubeconfig with the token is
+	// kept in the secret constants.TokenKubeConfigSecretName in the managed cluster namespace, and only the
+	// klusterlet of the cluster is granted to read it, it is not carried by the manifestworks. The klusterlet
+	// syncs it to the secret constants.HubKubeConfigSecretName in the registration namespace, the same secret it
+	// creates for the CSR registration, so the agent mounts it in the same way, e.g. by
+	// utils.MountHubKubeConfigSecret. The CSRs of the kube-apiserver-client
+	// signer are not requested. The agent roles bound by utils.RBACPermissionBuilder are also bound to the
+	// ServiceAccount when the builder is given the registration option by WithRegistrationOption. It only
+	// applies to the Default install mode.
+	// +optional
+	TokenRegistration *TokenRegistration
+}
+
+// TokenRegistration defines the hub kubeconfig with the ServiceAccount token of the addon agent.
+type TokenRegistration struct {
+	// Server is the address of the hub kube-apiserver reachable from the managed clusters.
+	// +required
+	Server string
+
+	// CABundle is the PEM encoded CA bundle to verify the hub kube-apiserver. If it is not set, the CA of the
+	// kubeconfig of the addon manager is used.
+	// +optional
+	CABundle []byte
+
+	// Expiration is the lifetime of the tokens, the kube-apiserver may issue tokens of a different lifetime.
+	// Defaults to 24h, and the minimum is 10m.
+	// +optional
+	Expiration time.Duration
+
+	// RenewBefore is the duration before the token expires to renew it. If it is not set, the token is renewed
+	// after 80% of its lifetime.
+	// +optional
+	RenewBefore time.Duration
+}
+
+// CertificateRotation defines how the client certificates issued by the hub are renewed.
+type CertificateRotation struct {
+	// RenewBefore is the duration before the certificate expires to renew it. If it is not set, the
+	// certificate is renewed after 80% of its lifetime.
+	// +optional
+	RenewBefore time.Duration
 }
 
 // InstallStrategy is the installation strategy of the manifests prescribed by Manifests(..).
@@ -141,6 +349,8 @@ type HealthProber struct {
 	Type HealthProberType
 
 	WorkProber *WorkHealthProber
+
+	ProxyProber *ProxyHealthProber
 }
 
 type AddonHealthCheckFunc func(workapiv1.ResourceIdentifier, workapiv1.StatusFeedbackResult) error
@@ -155,13 +365,65 @@ type WorkHealthProber struct {
 
 // ProbeField defines the field of a resource to be probed
 type ProbeField struct {
-	// ResourceIdentifier sets what resource shoule be probed
+	// ResourceIdentifier sets what resource shoule be probed. The name and the namespace can be wildcard
+	// patterns, ex: tenant-*, to probe the resources whose names are not known when the addon is registered.
+	// The pattern syntax is the one of path.Match.
 	ResourceIdentifier workapiv1.ResourceIdentifier
 
+	// LabelSelector selects the resources of the ResourceIdentifier to probe by their labels. The resources
+	// matched by the wildcard patterns or the label selector are expanded against the manifests of the addon,
+	// and a probe field matching no resource is ignored.
+	LabelSelector *metav1.LabelSelector
+
 	// ProbeRules sets the rules to probe the field
 	ProbeRules []workapiv1.FeedbackRule
 }
 
+// ProxyHealthProber probes the addon agent on the managed cluster through the kube-apiserver of the managed
+// cluster, which is reached through a proxy on the hub, ex: the cluster-proxy or the ClusterGateway.
+type ProxyHealthProber struct {
+	// ClusterConfig returns the rest config to access the kube-apiserver of the managed cluster through the
+	// proxy.
+	ClusterConfig func(clusterName string) (*rest.Config, error)
+
+	// Probes are the endpoints to probe, the addon is available if all the probes succeed.
+	Probes []ProxyProbe
+
+	// Period is how often the addon is probed, it is 30s by default.
+	Period time.Duration
+
+	// Timeout is the timeout of each probe, it is 10s by default.
+	Timeout time.Duration
+}
+
+// ProxyProbe is an endpoint of the managed cluster to probe.
+type ProxyProbe struct {
+	// Path is the path to probe. It is a path of the kube api, ex: /apis/apps/v1/namespaces/ns/deployments/agent,
+	// or a path of the service if the Service is set, ex: /healthz.
+	Path string
+
+	// Service is the service of the addon agent, the path is probed on the service through the service proxy
+	// of the kube-apiserver.
+	Service *ProxyProbeService
+
+	// HealthCheck checks the response of the probe. If it is nil, the probe succeeds if the response status
+	// code is 2xx.
+	HealthCheck ProxyHealthCheckFunc
+}
+
+// ProxyProbeService is a service on the managed cluster.
+type ProxyProbeService struct {
+	Namespace string
+	Name      string
+	// Port is the name or number of the service port.
+	Port string
+	// Scheme is http or https, it is http by default.
+	Scheme string
+}
+
+// ProxyHealthCheckFunc checks the status code and the body of the response of a proxy probe.
+type ProxyHealthCheckFunc func(statusCode int, body []byte) error
+
 type HealthProberType string
 
 const (
@@ -179,6 +441,10 @@ const (
 	// clusters. The addon framework will check if the work is Available on the spoke. In addition
 	// user can define a prober to check more detailed status based on status feedback from work.
 	HealthProberTypeWork HealthProberType = "Work"
+	// HealthProberTypeProxy indicates the healthiness of the addon is probed directly on the managed cluster
+	// through a proxy on the hub, ex: checking the /healthz of the addon agent. It's applicable to those addons
+	// whose healthiness is not surfaced in the status of any resource.
+	HealthProberTypeProxy HealthProberType = "Proxy"
 )
 
 func KubeClientSignerConfigurations(addonName, agentName string) func(cluster *clusterv1.ManagedCluster) []addonapiv1alpha1.RegistrationConfig {
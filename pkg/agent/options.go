@@ -0,0 +1,286 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+// Option sets an option of the AgentAddonOptions. The new options of the addons are added as new Option funcs,
+// so the addons built with OptionsV2 are not broken when the AgentAddonOptions grows.
+type Option func(options *AgentAddonOptions)
+
+// OptionsV2 builds the AgentAddonOptions of the addon from the options and validates them, e.g.
+//
+//	options, err := agent.OptionsV2("helloworld",
+//		agent.WithRegistration(registration),
+//		agent.WithLeaseHealthProber(),
+//		agent.WithSupportedConfigGVRs(utils.AddOnDeploymentConfigGVR),
+//	)
+func OptionsV2(addonName string, opts ...Option) (AgentAddonOptions, error) {
+	options := AgentAddonOptions{AddonName: addonName}.With(opts...)
+	if err := options.Validate(); err != nil {
+		return options, err
+	}
+	return options, nil
+}
+
+// With returns a copy of the options with the given options applied. It adapts the AgentAddonOptions built as
+// a struct literal to the functional options.
+func (o AgentAddonOptions) With(opts ...Option) AgentAddonOptions {
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithRegistration sets how the addon agent is registered to the hub.
+func WithRegistration(registration *RegistrationOption) Option {
+	return func(options *AgentAddonOptions) {
+		options.Registration = registration
+	}
+}
+
+// WithInstallStrategy sets on which clusters the addon is installed automatically.
+func WithInstallStrategy(strategy *InstallStrategy) Option {
+	return func(options *AgentAddonOptions) {
+		options.InstallStrategy = strategy
+	}
+}
+
+// WithHealthProber sets how the health of the addon is probed.
+func WithHealthProber(prober *HealthProber) Option {
+	return func(options *AgentAddonOptions) {
+		options.HealthProber = prober
+	}
+}
+
+// WithLeaseHealthProber probes the health of the addon by the lease updated by the addon agent.
+func WithLeaseHealthProber() Option {
+	return WithHealthProber(&HealthProber{Type: HealthProberTypeLease})
+}
+
+// WithWorkHealthProber probes the health of the addon by the status of its ManifestWorks. If the prober is nil,
+// the addon is available once the ManifestWorks are available.
+func WithWorkHealthProber(prober *WorkHealthProber) Option {
+	return WithHealthProber(&HealthProber{Type: HealthProberTypeWork, WorkProber: prober})
+}
+
+// WithProxyHealthProber probes the health of the addon directly on the managed cluster through a proxy on the hub.
+func WithProxyHealthProber(prober *ProxyHealthProber) Option {
+	return WithHealthProber(&HealthProber{Type: HealthProberTypeProxy, ProxyProber: prober})
+}
+
+// WithNoneHealthProber leaves the health of the addon to be set by the addon itself.
+func WithNoneHealthProber() Option {
+	return WithHealthProber(&HealthProber{Type: HealthProberTypeNone})
+}
+
+// WithHostedMode enables the Hosted deploying mode of the addon agent.
+func WithHostedMode() Option {
+	return func(options *AgentAddonOptions) {
+		options.HostedModeEnabled = true
+	}
+}
+
+// WithSupportedConfigGVRs adds the configuration GroupVersionResources supported by the addon.
+func WithSupportedConfigGVRs(gvrs ...schema.GroupVersionResource) Option {
+	return func(options *AgentAddonOptions) {
+		options.SupportedConfigGVRs = append(options.SupportedConfigGVRs, gvrs...)
+	}
+}
+
+// WithConfigSpecHashFunc overrides how the spec hash of the supported configuration is computed.
+func WithConfigSpecHashFunc(gvr schema.GroupVersionResource, specHashFunc ConfigSpecHashFunc) Option {
+	return func(options *AgentAddonOptions) {
+		if options.ConfigSpecHashFuncs == nil {
+			options.ConfigSpecHashFuncs = map[schema.GroupVersionResource]ConfigSpecHashFunc{}
+		}
+		options.ConfigSpecHashFuncs[gvr] = specHashFunc
+	}
+}
+
+// WithHubDependencies adds the Secrets/ConfigMaps on the hub the manifests of the addon agent depend on.
+func WithHubDependencies(dependencies ...HubDependency) Option {
+	return func(options *AgentAddonOptions) {
+		options.HubDependencies = append(options.HubDependencies, dependencies...)
+	}
+}
+
+// WithAgentInstallNamespace sets the namespace the addon agent is installed in on the managed cluster.
+func WithAgentInstallNamespace(nsFunc func(addon *addonapiv1alpha1.ManagedClusterAddOn) (string, error)) Option {
+	return func(options *AgentAddonOptions) {
+		options.AgentInstallNamespace = nsFunc
+	}
+}
+
+// WithManifestConfigs adds the per-resource configurations set on the ManifestWorks of the addon agent.
+func WithManifestConfigs(configs ...workapiv1.ManifestConfigOption) Option {
+	return func(options *AgentAddonOptions) {
+		options.ManifestConfigs = append(options.ManifestConfigs, configs...)
+	}
+}
+
+// WithWorkConfiguration sets the delete option and the executor of the ManifestWorks of the addon agent.
+func WithWorkConfiguration(config *WorkConfiguration) Option {
+	return func(options *AgentAddonOptions) {
+		options.WorkConfiguration = config
+	}
+}
+
+// WithDeletionPolicy sets whether the resources of the addon agent are orphaned when the addon is deleted.
+func WithDeletionPolicy(policy DeletionPolicy) Option {
+	return func(options *AgentAddonOptions) {
+		options.DeletionPolicy = policy
+	}
+}
+
+// WithFeatureGates adds the feature gates supported by the addon agent with their default states.
+func WithFeatureGates(gates FeatureGates) Option {
+	return func(options *AgentAddonOptions) {
+		if options.FeatureGates == nil {
+			options.FeatureGates = FeatureGates{}
+		}
+		for name, enabled := range gates {
+			options.FeatureGates[name] = enabled
+		}
+	}
+}
+
+// WithVersion sets the desired version of the addon agent, e.g. the image tag of the agent.
+func WithVersion(version string) Option {
+	return func(options *AgentAddonOptions) {
+		options.Version = version
+	}
+}
+
+// WithManifestDriftDetection detects the ManifestWorks of the addon agent modified out-of-band on the hub, and
+// restores them to the rendered manifests if autoRestore is true.
+func WithManifestDriftDetection(autoRestore bool) Option {
+	return func(options *AgentAddonOptions) {
+		options.ManifestDriftDetection = &ManifestDriftDetection{AutoRestore: autoRestore}
+	}
+}
+
+// WithAddOnMeta sets the display name and description of the addon set on its ClusterManagementAddOn.
+func WithAddOnMeta(displayName, description string) Option {
+	return func(options *AgentAddonOptions) {
+		options.AddOnMeta = &addonapiv1alpha1.AddOnMeta{DisplayName: displayName, Description: description}
+	}
+}
+
+// Validate checks the options are consistent, and returns the aggregated errors of all the invalid options.
+func (o AgentAddonOptions) Validate() error {
+	var errs []error
+	if len(o.AddonName) == 0 {
+		errs = append(errs, fmt.Errorf("addon name should be set"))
+	}
+
+	if o.Registration != nil {
+		if o.Registration.CSRConfigurations == nil && o.Registration.TokenRegistration == nil {
+			errs = append(errs, fmt.Errorf("registration.CSRConfigurations should be set"))
+		}
+		if o.Registration.CertificateRotation != nil && o.Registration.CSRSign == nil {
+			errs = append(errs, fmt.Errorf("registration.CertificateRotation requires registration.CSRSign"))
+		}
+		if tokenRegistration := o.Registration.TokenRegistration; tokenRegistration != nil {
+			if len(tokenRegistration.Server) == 0 {
+				errs = append(errs, fmt.Errorf("registration.TokenRegistration.Server should be set"))
+			}
+			if tokenRegistration.Expiration != 0 && tokenRegistration.Expiration < 10*time.Minute {
+				errs = append(errs, fmt.Errorf("registration.TokenRegistration.Expiration should be at least 10m"))
+			}
+			if tokenRegistration.RenewBefore < 0 || (tokenRegistration.Expiration != 0 &&
+				tokenRegistration.RenewBefore >= tokenRegistration.Expiration) {
+				errs = append(errs, fmt.Errorf("registration.TokenRegistration.RenewBefore should be less than the expiration"))
+			}
+		}
+	}
+
+	if o.HealthProber != nil {
+		switch o.HealthProber.Type {
+		case HealthProberTypeNone, HealthProberTypeLease, HealthProberTypeWork, HealthProberTypeProxy:
+		default:
+			errs = append(errs, fmt.Errorf("unknown health prober type %q", o.HealthProber.Type))
+		}
+		if o.HealthProber.Type != HealthProberTypeWork && o.HealthProber.WorkProber != nil {
+			errs = append(errs, fmt.Errorf("healthProber.WorkProber is only used by the %s health prober", HealthProberTypeWork))
+		}
+		if o.HealthProber.Type != HealthProberTypeProxy && o.HealthProber.ProxyProber != nil {
+			errs = append(errs, fmt.Errorf("healthProber.ProxyProber is only used by the %s health prober", HealthProberTypeProxy))
+		}
+		if o.HealthProber.Type == HealthProberTypeProxy {
+			switch {
+			case o.HealthProber.ProxyProber == nil:
+				errs = append(errs, fmt.Errorf("healthProber.ProxyProber should be set"))
+			case o.HealthProber.ProxyProber.ClusterConfig == nil:
+				errs = append(errs, fmt.Errorf("healthProber.ProxyProber.ClusterConfig should be set"))
+			case len(o.HealthProber.ProxyProber.Probes) == 0:
+				errs = append(errs, fmt.Errorf("healthProber.ProxyProber.Probes should be set"))
+			}
+		}
+		if o.HealthProber.WorkProber != nil {
+			for _, field := range o.HealthProber.WorkProber.ProbeFields {
+				if err := field.validate(); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+
+	switch o.DeletionPolicy {
+	case "", DeletionPolicyCascade, DeletionPolicyOrphan:
+	default:
+		errs = append(errs, fmt.Errorf("unknown deletion policy %q", o.DeletionPolicy))
+	}
+
+	for name := range o.FeatureGates {
+		if err := validateFeatureGateName(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	gvrs := sets.New[schema.GroupVersionResource]()
+	for _, gvr := range o.SupportedConfigGVRs {
+		if gvrs.Has(gvr) {
+			errs = append(errs, fmt.Errorf("supported config %s is duplicated", gvr))
+		}
+		gvrs.Insert(gvr)
+	}
+	for gvr := range o.ConfigSpecHashFuncs {
+		if !gvrs.Has(gvr) {
+			errs = append(errs, fmt.Errorf("the spec hash func of config %s is set, but the config is not supported", gvr))
+		}
+	}
+
+	for _, dependency := range o.HubDependencies {
+		if dependency.Kind != HubDependencyKindSecret && dependency.Kind != HubDependencyKindConfigMap {
+			errs = append(errs, fmt.Errorf("unknown kind %q of hub dependency %s", dependency.Kind, dependency.Name))
+		}
+		if len(dependency.Name) == 0 {
+			errs = append(errs, fmt.Errorf("the name of the %s hub dependency should be set", dependency.Kind))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// Deprecations returns the warnings of the deprecated usages of the options. The deprecated usages still work,
+// the addon manager logs the warnings when the addon is added.
+func (o AgentAddonOptions) Deprecations() []string {
+	var warnings []string
+	if o.HealthProber == nil {
+		warnings = append(warnings, "the healthProber is not set and defaults to the Lease health prober, "+
+			"the default is deprecated, set it explicitly, e.g. by agent.WithLeaseHealthProber")
+	}
+	if o.Registration != nil && len(o.Registration.Namespace) > 0 {
+		warnings = append(warnings, "registration.Namespace is deprecated, "+
+			"use agentInstallNamespace, e.g. by agent.WithAgentInstallNamespace")
+	}
+	return warnings
+}
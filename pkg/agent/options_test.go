@@ -0,0 +1,190 @@
+package agent
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+var testConfigGVR = schema.GroupVersionResource{Group: "addon.open-cluster-management.io", Version: "v1alpha1", Resource: "addondeploymentconfigs"}
+
+func testClusterConfig(_ string) (*rest.Config, error) {
+	return &rest.Config{}, nil
+}
+
+func TestOptionsV2(t *testing.T) {
+	registration := &RegistrationOption{
+		CSRConfigurations: func(cluster *clusterv1.ManagedCluster) []addonapiv1alpha1.RegistrationConfig { return nil },
+	}
+
+	cases := []struct {
+		name        string
+		addonName   string
+		opts        []Option
+		expectedErr bool
+		validate    func(t *testing.T, options AgentAddonOptions)
+	}{
+		{
+			name:      "valid options",
+			addonName: "test",
+			opts: []Option{
+				WithRegistration(registration),
+				WithLeaseHealthProber(),
+				WithHostedMode(),
+				WithSupportedConfigGVRs(testConfigGVR),
+				WithConfigSpecHashFunc(testConfigGVR, nil),
+				WithHubDependencies(HubDependency{Kind: HubDependencyKindSecret, Name: "ca"}),
+			},
+			validate: func(t *testing.T, options AgentAddonOptions) {
+				if options.AddonName != "test" || options.Registration != registration || !options.HostedModeEnabled {
+					t.Errorf("unexpected options %v", options)
+				}
+				if options.HealthProber == nil || options.HealthProber.Type != HealthProberTypeLease {
+					t.Errorf("expected lease health prober, but got %v", options.HealthProber)
+				}
+				if !reflect.DeepEqual(options.SupportedConfigGVRs, []schema.GroupVersionResource{testConfigGVR}) {
+					t.Errorf("unexpected supported configs %v", options.SupportedConfigGVRs)
+				}
+				if len(options.Deprecations()) != 0 {
+					t.Errorf("expected no deprecation, but got %v", options.Deprecations())
+				}
+			},
+		},
+		{
+			name:        "no addon name",
+			expectedErr: true,
+		},
+		{
+			name:      "invalid registration",
+			addonName: "test",
+			opts: []Option{
+				WithRegistration(&RegistrationOption{CertificateRotation: &CertificateRotation{}}),
+			},
+			expectedErr: true,
+		},
+		{
+			name:      "token registration",
+			addonName: "test",
+			opts: []Option{
+				WithRegistration(&RegistrationOption{TokenRegistration: &TokenRegistration{Server: "https://hub:6443"}}),
+			},
+		},
+		{
+			name:      "token registration without server",
+			addonName: "test",
+			opts: []Option{
+				WithRegistration(&RegistrationOption{TokenRegistration: &TokenRegistration{}}),
+			},
+			expectedErr: true,
+		},
+		{
+			name:      "token registration renewed after expiration",
+			addonName: "test",
+			opts: []Option{
+				WithRegistration(&RegistrationOption{TokenRegistration: &TokenRegistration{
+					Server: "https://hub:6443", Expiration: time.Hour, RenewBefore: 2 * time.Hour}}),
+			},
+			expectedErr: true,
+		},
+		{
+			name:        "unknown health prober",
+			addonName:   "test",
+			opts:        []Option{WithHealthProber(&HealthProber{Type: "Unknown"})},
+			expectedErr: true,
+		},
+		{
+			name:        "proxy health prober without probes",
+			addonName:   "test",
+			opts:        []Option{WithProxyHealthProber(&ProxyHealthProber{ClusterConfig: testClusterConfig})},
+			expectedErr: true,
+		},
+		{
+			name:      "proxy prober of the lease health prober",
+			addonName: "test",
+			opts: []Option{WithHealthProber(&HealthProber{
+				Type:        HealthProberTypeLease,
+				ProxyProber: &ProxyHealthProber{ClusterConfig: testClusterConfig, Probes: []ProxyProbe{{Path: "/healthz"}}},
+			})},
+			expectedErr: true,
+		},
+		{
+			name:      "proxy health prober",
+			addonName: "test",
+			opts: []Option{WithProxyHealthProber(&ProxyHealthProber{
+				ClusterConfig: testClusterConfig,
+				Probes:        []ProxyProbe{{Path: "/healthz"}},
+			})},
+			validate: func(t *testing.T, options AgentAddonOptions) {
+				if options.HealthProber.Type != HealthProberTypeProxy || options.HealthProber.ProxyProber == nil {
+					t.Errorf("expected proxy health prober, but got %v", options.HealthProber)
+				}
+			},
+		},
+		{
+			name:        "duplicated supported config",
+			addonName:   "test",
+			opts:        []Option{WithSupportedConfigGVRs(testConfigGVR, testConfigGVR)},
+			expectedErr: true,
+		},
+		{
+			name:        "spec hash func of an unsupported config",
+			addonName:   "test",
+			opts:        []Option{WithConfigSpecHashFunc(testConfigGVR, nil)},
+			expectedErr: true,
+		},
+		{
+			name:        "unknown deletion policy",
+			addonName:   "test",
+			opts:        []Option{WithDeletionPolicy("Unknown")},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid feature gate name",
+			addonName:   "test",
+			opts:        []Option{WithFeatureGates(FeatureGates{"Foo=Bar": true})},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid hub dependency",
+			addonName:   "test",
+			opts:        []Option{WithHubDependencies(HubDependency{Kind: "Pod"})},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			options, err := OptionsV2(c.addonName, c.opts...)
+			if c.expectedErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if c.validate != nil {
+				c.validate(t, options)
+			}
+		})
+	}
+}
+
+func TestDeprecations(t *testing.T) {
+	legacy := AgentAddonOptions{
+		AddonName:    "test",
+		Registration: &RegistrationOption{Namespace: "test"},
+	}
+	if warnings := legacy.Deprecations(); len(warnings) != 2 {
+		t.Errorf("expected 2 deprecation warnings, but got %v", warnings)
+	}
+
+	// the legacy options are adapted with the functional options
+	options := legacy.With(WithLeaseHealthProber(), WithRegistration(&RegistrationOption{}))
+	if warnings := options.Deprecations(); len(warnings) != 0 {
+		t.Errorf("expected no deprecation warning, but got %v", warnings)
+	}
+	if legacy.HealthProber != nil {
+		t.Errorf("expected the legacy options not changed")
+	}
+}
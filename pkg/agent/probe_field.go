@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+// IsPattern returns true if the probe field matches the resources by the wildcard patterns of the resource
+// identifier or by the label selector, rather than a single resource.
+func (f ProbeField) IsPattern() bool {
+	return f.LabelSelector != nil || isWildcard(f.ResourceIdentifier.Name) || isWildcard(f.ResourceIdentifier.Namespace)
+}
+
+// Matches returns true if the resource of the identifier, which has the given labels, is probed by the probe
+// field.
+func (f ProbeField) Matches(identifier workapiv1.ResourceIdentifier, resourceLabels map[string]string) (bool, error) {
+	if identifier.Group != f.ResourceIdentifier.Group || identifier.Resource != f.ResourceIdentifier.Resource {
+		return false, nil
+	}
+
+	for _, pair := range [][2]string{
+		{f.ResourceIdentifier.Name, identifier.Name},
+		{f.ResourceIdentifier.Namespace, identifier.Namespace},
+	} {
+		matched, err := path.Match(pair[0], pair[1])
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q of probe field: %w", pair[0], err)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	if f.LabelSelector == nil {
+		return true, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(f.LabelSelector)
+	if err != nil {
+		return false, fmt.Errorf("invalid label selector of probe field: %w", err)
+	}
+	return selector.Matches(labels.Set(resourceLabels)), nil
+}
+
+// validate returns an error if the patterns or the label selector of the probe field are invalid.
+func (f ProbeField) validate() error {
+	for _, pattern := range []string{f.ResourceIdentifier.Name, f.ResourceIdentifier.Namespace} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q of probe field: %w", pattern, err)
+		}
+	}
+	if f.LabelSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(f.LabelSelector); err != nil {
+			return fmt.Errorf("invalid label selector of probe field: %w", err)
+		}
+	}
+	return nil
+}
+
+func isWildcard(s string) bool {
+	return strings.ContainsAny(s, `*?[\`)
+}
@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+func TestProbeFieldMatches(t *testing.T) {
+	deployment := func(namespace, name string) workapiv1.ResourceIdentifier {
+		return workapiv1.ResourceIdentifier{Group: "apps", Resource: "deployments", Name: name, Namespace: namespace}
+	}
+
+	cases := []struct {
+		name            string
+		field           ProbeField
+		identifier      workapiv1.ResourceIdentifier
+		labels          map[string]string
+		expectedPattern bool
+		expectedMatched bool
+		expectErr       bool
+	}{
+		{
+			name:            "exact resource",
+			field:           ProbeField{ResourceIdentifier: deployment("ns", "agent")},
+			identifier:      deployment("ns", "agent"),
+			expectedMatched: true,
+		},
+		{
+			name:       "different resource",
+			field:      ProbeField{ResourceIdentifier: deployment("ns", "agent")},
+			identifier: workapiv1.ResourceIdentifier{Resource: "configmaps", Name: "agent", Namespace: "ns"},
+		},
+		{
+			name:            "wildcard name",
+			field:           ProbeField{ResourceIdentifier: deployment("ns", "tenant-*")},
+			identifier:      deployment("ns", "tenant-a"),
+			expectedPattern: true,
+			expectedMatched: true,
+		},
+		{
+			name:            "wildcard name not matched",
+			field:           ProbeField{ResourceIdentifier: deployment("ns", "tenant-*")},
+			identifier:      deployment("ns", "agent"),
+			expectedPattern: true,
+		},
+		{
+			name: "label selector",
+			field: ProbeField{
+				ResourceIdentifier: deployment("*", "*"),
+				LabelSelector:      &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "a"}},
+			},
+			identifier:      deployment("ns", "agent"),
+			labels:          map[string]string{"tenant": "a"},
+			expectedPattern: true,
+			expectedMatched: true,
+		},
+		{
+			name: "label selector not matched",
+			field: ProbeField{
+				ResourceIdentifier: deployment("ns", "agent"),
+				LabelSelector:      &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "a"}},
+			},
+			identifier:      deployment("ns", "agent"),
+			expectedPattern: true,
+		},
+		{
+			name:            "invalid pattern",
+			field:           ProbeField{ResourceIdentifier: deployment("ns", "tenant-[")},
+			identifier:      deployment("ns", "tenant-a"),
+			expectedPattern: true,
+			expectErr:       true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.field.IsPattern() != c.expectedPattern {
+				t.Errorf("expected pattern %v, but got %v", c.expectedPattern, c.field.IsPattern())
+			}
+			if err := c.field.validate(); (err != nil) != c.expectErr {
+				t.Errorf("expected validation error %v, but got %v", c.expectErr, err)
+			}
+			matched, err := c.field.Matches(c.identifier, c.labels)
+			if (err != nil) != c.expectErr {
+				t.Fatalf("expected error %v, but got %v", c.expectErr, err)
+			}
+			if matched != c.expectedMatched {
+				t.Errorf("expected matched %v, but got %v", c.expectedMatched, matched)
+			}
+		})
+	}
+}
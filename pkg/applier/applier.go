@@ -0,0 +1,196 @@
+package applier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+// DefaultFieldManager is the field manager of the server-side apply if it is not set.
+const DefaultFieldManager = "addon-framework"
+
+// Applier applies the resources of the addons on the hub idempotently. It is safe to call Apply repeatedly
+// with the same object, the resource is only changed when the required fields differ.
+type Applier interface {
+	// Apply creates or updates the object, and returns the object on the hub after it is applied.
+	Apply(ctx context.Context, required runtime.Object) (runtime.Object, error)
+}
+
+// ConflictError is returned by Apply when the fields of the object are managed by another field manager
+// and the applier is not allowed to force the ownership of them.
+type ConflictError struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Err       error
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict applying %s %s/%s: %v", e.Kind, e.Namespace, e.Name, e.Err)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// IsConflict returns true if the error is a ConflictError.
+func IsConflict(err error) bool {
+	var conflictErr *ConflictError
+	return errors.As(err, &conflictErr)
+}
+
+// ServerSideApplier applies the objects with the server-side apply. The supported kinds are ConfigMap,
+// Secret, ServiceAccount, Role, RoleBinding, ClusterRole and ClusterRoleBinding.
+type ServerSideApplier struct {
+	client         kubernetes.Interface
+	fieldManager   string
+	ownerLabels    map[string]string
+	forceConflicts bool
+}
+
+var _ Applier = &ServerSideApplier{}
+
+// NewServerSideApplier returns an applier applying the objects with the field manager.
+func NewServerSideApplier(client kubernetes.Interface, fieldManager string) *ServerSideApplier {
+	if len(fieldManager) == 0 {
+		fieldManager = DefaultFieldManager
+	}
+	return &ServerSideApplier{
+		client:       client,
+		fieldManager: fieldManager,
+	}
+}
+
+// WithOwnerLabels sets the labels on all the applied objects, so that the resources created by an addon
+// can be listed and cleaned up by the labels.
+func (a *ServerSideApplier) WithOwnerLabels(labels map[string]string) *ServerSideApplier {
+	a.ownerLabels = labels
+	return a
+}
+
+// WithForceConflicts makes the applier take the ownership of the fields managed by other field managers
+// instead of returning a ConflictError.
+func (a *ServerSideApplier) WithForceConflicts() *ServerSideApplier {
+	a.forceConflicts = true
+	return a
+}
+
+// Apply applies the object with the server-side apply. The transient errors of the API server are retried.
+func (a *ServerSideApplier) Apply(ctx context.Context, required runtime.Object) (runtime.Object, error) {
+	required = required.DeepCopyObject()
+	kind, patcher, err := a.patcherFor(required)
+	if err != nil {
+		return nil, err
+	}
+	required.GetObjectKind().SetGroupVersionKind(kind)
+	accessor, err := meta.Accessor(required)
+	if err != nil {
+		return nil, err
+	}
+	accessor.SetResourceVersion("")
+	accessor.SetManagedFields(nil)
+	if len(a.ownerLabels) > 0 {
+		labels := accessor.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		for key, value := range a.ownerLabels {
+			labels[key] = value
+		}
+		accessor.SetLabels(labels)
+	}
+
+	data, err := json.Marshal(required)
+	if err != nil {
+		return nil, err
+	}
+
+	force := a.forceConflicts
+	options := metav1.PatchOptions{FieldManager: a.fieldManager, Force: &force}
+
+	var actual runtime.Object
+	err = retry.OnError(retry.DefaultBackoff, isRetriable, func() error {
+		actual, err = patcher(ctx, accessor.GetNamespace(), accessor.GetName(), data, options)
+		return err
+	})
+	if apierrors.IsConflict(err) {
+		return nil, &ConflictError{
+			Kind:      kind.Kind,
+			Namespace: accessor.GetNamespace(),
+			Name:      accessor.GetName(),
+			Err:       err,
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	klog.V(4).Infof("Applied %s %s/%s", kind.Kind, accessor.GetNamespace(), accessor.GetName())
+	return actual, nil
+}
+
+type patchFunc func(ctx context.Context, namespace, name string, data []byte, options metav1.PatchOptions) (runtime.Object, error)
+
+func (a *ServerSideApplier) patcherFor(obj runtime.Object) (schema.GroupVersionKind, patchFunc, error) {
+	switch obj.(type) {
+	case *corev1.ConfigMap:
+		return corev1.SchemeGroupVersion.WithKind("ConfigMap"),
+			func(ctx context.Context, namespace, name string, data []byte, options metav1.PatchOptions) (runtime.Object, error) {
+				return a.client.CoreV1().ConfigMaps(namespace).Patch(ctx, name, types.ApplyPatchType, data, options)
+			}, nil
+	case *corev1.Secret:
+		return corev1.SchemeGroupVersion.WithKind("Secret"),
+			func(ctx context.Context, namespace, name string, data []byte, options metav1.PatchOptions) (runtime.Object, error) {
+				return a.client.CoreV1().Secrets(namespace).Patch(ctx, name, types.ApplyPatchType, data, options)
+			}, nil
+	case *corev1.ServiceAccount:
+		return corev1.SchemeGroupVersion.WithKind("ServiceAccount"),
+			func(ctx context.Context, namespace, name string, data []byte, options metav1.PatchOptions) (runtime.Object, error) {
+				return a.client.CoreV1().ServiceAccounts(namespace).Patch(ctx, name, types.ApplyPatchType, data, options)
+			}, nil
+	case *rbacv1.Role:
+		return rbacv1.SchemeGroupVersion.WithKind("Role"),
+			func(ctx context.Context, namespace, name string, data []byte, options metav1.PatchOptions) (runtime.Object, error) {
+				return a.client.RbacV1().Roles(namespace).Patch(ctx, name, types.ApplyPatchType, data, options)
+			}, nil
+	case *rbacv1.RoleBinding:
+		return rbacv1.SchemeGroupVersion.WithKind("RoleBinding"),
+			func(ctx context.Context, namespace, name string, data []byte, options metav1.PatchOptions) (runtime.Object, error) {
+				return a.client.RbacV1().RoleBindings(namespace).Patch(ctx, name, types.ApplyPatchType, data, options)
+			}, nil
+	case *rbacv1.ClusterRole:
+		return rbacv1.SchemeGroupVersion.WithKind("ClusterRole"),
+			func(ctx context.Context, _, name string, data []byte, options metav1.PatchOptions) (runtime.Object, error) {
+				return a.client.RbacV1().ClusterRoles().Patch(ctx, name, types.ApplyPatchType, data, options)
+			}, nil
+	case *rbacv1.ClusterRoleBinding:
+		return rbacv1.SchemeGroupVersion.WithKind("ClusterRoleBinding"),
+			func(ctx context.Context, _, name string, data []byte, options metav1.PatchOptions) (runtime.Object, error) {
+				return a.client.RbacV1().ClusterRoleBindings().Patch(ctx, name, types.ApplyPatchType, data, options)
+			}, nil
+	default:
+		return schema.GroupVersionKind{}, nil, fmt.Errorf("unsupported type %T", obj)
+	}
+}
+
+// isRetriable returns true if the error is a transient error of the API server.
+func isRetriable(err error) bool {
+	return apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsInternalError(err)
+}
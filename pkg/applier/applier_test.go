@@ -0,0 +1,139 @@
+package applier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestServerSideApply(t *testing.T) {
+	cases := []struct {
+		name            string
+		required        runtime.Object
+		forceConflicts  bool
+		patchErrs       []error
+		expectedPatches int
+		validateErr     func(t *testing.T, err error)
+	}{
+		{
+			name: "apply configmap",
+			required: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns1", ResourceVersion: "1"},
+				Data:       map[string]string{"key": "value"},
+			},
+			expectedPatches: 1,
+		},
+		{
+			name: "apply cluster role with force",
+			required: &rbacv1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+			},
+			forceConflicts:  true,
+			expectedPatches: 1,
+		},
+		{
+			name:            "retry transient errors",
+			required:        &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns1"}},
+			patchErrs:       []error{apierrors.NewInternalError(fmt.Errorf("internal")), apierrors.NewTooManyRequests("busy", 0)},
+			expectedPatches: 3,
+		},
+		{
+			name:     "conflict",
+			required: &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns1"}},
+			patchErrs: []error{
+				apierrors.NewConflict(schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "roles"}, "test",
+					fmt.Errorf("field managed by another manager")),
+			},
+			expectedPatches: 1,
+			validateErr: func(t *testing.T, err error) {
+				if !IsConflict(err) {
+					t.Errorf("expected conflict error, but got %v", err)
+				}
+			},
+		},
+		{
+			name:     "unsupported type",
+			required: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns1"}},
+			validateErr: func(t *testing.T, err error) {
+				if err == nil || IsConflict(err) {
+					t.Errorf("expected unsupported error, but got %v", err)
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeClient := fakekube.NewSimpleClientset()
+			var patches []clienttesting.PatchAction
+			kubeClient.PrependReactor("patch", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				patches = append(patches, action.(clienttesting.PatchAction))
+				if len(patches) <= len(c.patchErrs) {
+					return true, nil, c.patchErrs[len(patches)-1]
+				}
+				return true, c.required, nil
+			})
+
+			applier := NewServerSideApplier(kubeClient, "").WithOwnerLabels(map[string]string{"owner": "addon1"})
+			if c.forceConflicts {
+				applier = applier.WithForceConflicts()
+			}
+			_, err := applier.Apply(context.TODO(), c.required)
+			if c.validateErr != nil {
+				c.validateErr(t, err)
+			} else if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			if len(patches) != c.expectedPatches {
+				t.Fatalf("expected %d patches, but got %d", c.expectedPatches, len(patches))
+			}
+			if len(patches) == 0 || c.validateErr != nil {
+				return
+			}
+
+			patch := patches[len(patches)-1]
+			if patch.GetPatchType() != types.ApplyPatchType {
+				t.Errorf("expected apply patch, but got %s", patch.GetPatchType())
+			}
+			applied := &metav1.PartialObjectMetadata{}
+			if err := json.Unmarshal(patch.GetPatch(), applied); err != nil {
+				t.Fatal(err)
+			}
+			if len(applied.Kind) == 0 || len(applied.APIVersion) == 0 {
+				t.Errorf("expected type meta is set, but got %v", applied.TypeMeta)
+			}
+			if applied.Labels["owner"] != "addon1" {
+				t.Errorf("expected owner label, but got %v", applied.Labels)
+			}
+			if len(applied.ResourceVersion) != 0 {
+				t.Errorf("expected resource version is cleared, but got %s", applied.ResourceVersion)
+			}
+		})
+	}
+}
+
+func TestFakeApplier(t *testing.T) {
+	applier := NewFakeApplier().WithError("ns1", "failed", fmt.Errorf("failed"))
+
+	if _, err := applier.Apply(context.TODO(), &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns1"}}); err != nil {
+		t.Errorf("expected no error, but got %v", err)
+	}
+	if _, err := applier.Apply(context.TODO(), &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "failed", Namespace: "ns1"}}); err == nil {
+		t.Errorf("expected error, but got nil")
+	}
+	if len(applier.Applied()) != 1 {
+		t.Errorf("expected 1 applied object, but got %d", len(applier.Applied()))
+	}
+}
@@ -0,0 +1,56 @@
+package applier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// FakeApplier is an Applier for the unit tests of the addon hub components. It records the applied objects
+// instead of sending them to the API server.
+type FakeApplier struct {
+	lock    sync.Mutex
+	applied []runtime.Object
+	errs    map[string]error
+}
+
+var _ Applier = &FakeApplier{}
+
+// NewFakeApplier returns a FakeApplier.
+func NewFakeApplier() *FakeApplier {
+	return &FakeApplier{errs: map[string]error{}}
+}
+
+// WithError makes the applier return the error when the object with the namespace and name is applied.
+func (f *FakeApplier) WithError(namespace, name string, err error) *FakeApplier {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.errs[fmt.Sprintf("%s/%s", namespace, name)] = err
+	return f
+}
+
+// Apply records the object, and returns the error set by WithError if any.
+func (f *FakeApplier) Apply(_ context.Context, required runtime.Object) (runtime.Object, error) {
+	accessor, err := meta.Accessor(required)
+	if err != nil {
+		return nil, err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err, ok := f.errs[fmt.Sprintf("%s/%s", accessor.GetNamespace(), accessor.GetName())]; ok {
+		return nil, err
+	}
+	f.applied = append(f.applied, required.DeepCopyObject())
+	return required, nil
+}
+
+// Applied returns the objects applied successfully, in the order they are applied.
+func (f *FakeApplier) Applied() []runtime.Object {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]runtime.Object{}, f.applied...)
+}
@@ -0,0 +1,141 @@
+package factory
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+)
+
+const (
+	// maxBackPressureLevel is the max level of the back-pressure, at which the worker concurrency of the
+	// controllers is shrunk by 2^maxBackPressureLevel.
+	maxBackPressureLevel = 5
+
+	// backPressureRecoveryInterval is the interval without throttling after which the back-pressure
+	// decreases by one level.
+	backPressureRecoveryInterval = 30 * time.Second
+
+	// backPressureBaseDelay is the delay to retry a throttled key at level 1, it doubles at each level.
+	backPressureBaseDelay = 1 * time.Second
+)
+
+// globalBackPressure is shared by all the controllers, so that they all back off when the hub API server
+// is overloaded instead of amplifying the overload.
+var globalBackPressure = newBackPressure(clock.RealClock{})
+
+// backPressure is an adaptive back-pressure on the requests to the API server. Each time a sync is throttled
+// by the API server, the level increases by one: the number of active workers of each controller is halved
+// and the retry delay of the throttled keys is doubled. The level increases at most once in the retry delay of
+// the current level, so a burst of throttled syncs of the concurrent workers raises it by one level only. The
+// level decreases by one each recovery interval without throttling.
+type backPressure struct {
+	lock  sync.Mutex
+	clock clock.Clock
+	level int
+	// lastChangeTime is when the level was increased or decreased the last time, or the last throttling time
+	lastChangeTime time.Time
+	// lastIncreaseTime is when the level was increased the last time
+	lastIncreaseTime time.Time
+}
+
+func newBackPressure(clock clock.Clock) *backPressure {
+	return &backPressure{clock: clock}
+}
+
+// throttled increases the back-pressure after a request is throttled.
+func (b *backPressure) throttled() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.recover()
+	now := b.clock.Now()
+	// the requests throttled in the retry delay of the last increase were sent before the back-pressure of the
+	// current level took effect
+	if b.level < maxBackPressureLevel && (b.level == 0 || now.Sub(b.lastIncreaseTime) >= b.delay()) {
+		b.level++
+		b.lastIncreaseTime = now
+		klog.Warningf("The API server is throttling the requests, increase back-pressure to level %d", b.level)
+	}
+	b.lastChangeTime = now
+}
+
+// currentLevel returns the level of the back-pressure.
+func (b *backPressure) currentLevel() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.recover()
+	return b.level
+}
+
+// recover decreases the level by one for each recovery interval passed since the last change.
+func (b *backPressure) recover() {
+	if b.level == 0 {
+		return
+	}
+
+	steps := int(b.clock.Since(b.lastChangeTime) / backPressureRecoveryInterval)
+	if steps == 0 {
+		return
+	}
+	if steps > b.level {
+		steps = b.level
+	}
+
+	b.level -= steps
+	b.lastChangeTime = b.lastChangeTime.Add(time.Duration(steps) * backPressureRecoveryInterval)
+	klog.Infof("Decrease back-pressure to level %d", b.level)
+}
+
+// activeWorkers returns how many of the workers of a controller are allowed to process the queue, at least
+// one worker is always active.
+func (b *backPressure) activeWorkers(workers int) int {
+	active := workers >> b.currentLevel()
+	if active < 1 {
+		return 1
+	}
+	return active
+}
+
+// retryDelay returns the delay to retry a key whose sync is throttled.
+func (b *backPressure) retryDelay() time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.recover()
+	return b.delay()
+}
+
+// delay returns the retry delay of the current level, the lock must be held.
+func (b *backPressure) delay() time.Duration {
+	if b.level == 0 {
+		return 0
+	}
+	return backPressureBaseDelay << (b.level - 1)
+}
+
+// isThrottlingError returns true if the error, or any of the aggregated errors, is a 429 returned by the API
+// server, including the rejections of the API Priority and Fairness.
+func isThrottlingError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if apierrors.IsTooManyRequests(err) {
+		return true
+	}
+
+	var aggregate utilerrors.Aggregate
+	if errors.As(err, &aggregate) {
+		for _, e := range aggregate.Errors() {
+			if isThrottlingError(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
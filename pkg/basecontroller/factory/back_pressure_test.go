@@ -0,0 +1,133 @@
+package factory
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestBackPressure(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	b := newBackPressure(fakeClock)
+
+	if b.activeWorkers(4) != 4 || b.retryDelay() != 0 {
+		t.Errorf("expected no back-pressure, but got %d workers and delay %v", b.activeWorkers(4), b.retryDelay())
+	}
+
+	b.throttled()
+	if b.activeWorkers(4) != 2 || b.retryDelay() != time.Second {
+		t.Errorf("expected level 1, but got %d workers and delay %v", b.activeWorkers(4), b.retryDelay())
+	}
+
+	// the throttling in the retry delay of the last increase does not increase the level
+	b.throttled()
+	if b.currentLevel() != 1 {
+		t.Errorf("expected level 1, but got %d", b.currentLevel())
+	}
+
+	fakeClock.Step(b.retryDelay())
+	b.throttled()
+	fakeClock.Step(b.retryDelay())
+	b.throttled()
+	if b.activeWorkers(4) != 1 || b.retryDelay() != 4*time.Second {
+		t.Errorf("expected level 3, but got %d workers and delay %v", b.activeWorkers(4), b.retryDelay())
+	}
+
+	for i := 0; i < 10; i++ {
+		fakeClock.Step(b.retryDelay())
+		b.throttled()
+	}
+	if b.currentLevel() != maxBackPressureLevel {
+		t.Errorf("expected level %d, but got %d", maxBackPressureLevel, b.currentLevel())
+	}
+
+	// recover one level each interval without throttling
+	fakeClock.Step(backPressureRecoveryInterval)
+	if b.currentLevel() != maxBackPressureLevel-1 {
+		t.Errorf("expected level %d, but got %d", maxBackPressureLevel-1, b.currentLevel())
+	}
+
+	fakeClock.Step(10 * backPressureRecoveryInterval)
+	if b.currentLevel() != 0 || b.activeWorkers(4) != 4 {
+		t.Errorf("expected recovered, but got level %d", b.currentLevel())
+	}
+}
+
+func TestBackPressureConcurrentThrottling(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	b := newBackPressure(fakeClock)
+
+	// the workers throttled at the same time raise the back-pressure by one level
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.throttled()
+		}()
+	}
+	wg.Wait()
+	if b.currentLevel() != 1 {
+		t.Errorf("expected level 1, but got %d", b.currentLevel())
+	}
+
+	// the next burst after the retry delay raises it by one more level
+	fakeClock.Step(b.retryDelay())
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.throttled()
+		}()
+	}
+	wg.Wait()
+	if b.currentLevel() != 2 {
+		t.Errorf("expected level 2, but got %d", b.currentLevel())
+	}
+}
+
+func TestIsThrottlingError(t *testing.T) {
+	tooManyRequests := apierrors.NewTooManyRequests("too many requests", 1)
+	cases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "not found",
+			err:  apierrors.NewNotFound(schema.GroupResource{Resource: "test"}, "test"),
+		},
+		{
+			name:     "too many requests",
+			err:      tooManyRequests,
+			expected: true,
+		},
+		{
+			name:     "wrapped",
+			err:      fmt.Errorf("failed to patch: %w", tooManyRequests),
+			expected: true,
+		},
+		{
+			name:     "aggregated",
+			err:      utilerrors.NewAggregate([]error{fmt.Errorf("other"), tooManyRequests}),
+			expected: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if actual := isThrottlingError(c.err); actual != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
@@ -14,6 +14,33 @@ import (
 
 var defaultCacheSyncTimeout = 10 * time.Minute
 
+// defaultDrainTimeout is how long the controllers wait for the in-flight syncs to finish on shutdown before they
+// are cancelled.
+const defaultDrainTimeout = 10 * time.Second
+
+type drainTimeoutKey struct{}
+
+// WithDrainTimeout returns a context that the controllers run with wait for their in-flight syncs to finish in the
+// timeout when the context is done, e.g. to let the syncs finish writing the ManifestWorks and patching the addon
+// status on a hub upgrade, unless the drain timeout of a controller is set by Configure. The syncs still running
+// after the timeout are cancelled.
+func WithDrainTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, drainTimeoutKey{}, timeout)
+}
+
+// valuesContext keeps the values of the parent context but is never cancelled with it.
+type valuesContext struct {
+	parent context.Context
+}
+
+func (valuesContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (valuesContext) Done() <-chan struct{} { return nil }
+
+func (valuesContext) Err() error { return nil }
+
+func (c valuesContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
 // baseController represents generic Kubernetes controller boiler-plate
 type baseController struct {
 	name             string
@@ -22,6 +49,13 @@ type baseController struct {
 	syncContext      SyncContext
 	resyncEvery      time.Duration
 	cacheSyncTimeout time.Duration
+	// maxRetries is the number of the retries of a failed key, zero means no limit
+	maxRetries int
+	// drainTimeout is how long the in-flight syncs are waited for on shutdown, the timeout of the context of Run
+	// or the default is used if it is zero.
+	drainTimeout time.Duration
+	// logger is the logger of the syncs, named by the controller name
+	logger klog.Logger
 }
 
 var _ Controller = &baseController{}
@@ -47,6 +81,9 @@ func (c *baseController) SyncContext() SyncContext {
 }
 
 func (c *baseController) Run(ctx context.Context, workers int) {
+	globalControllerHealth.started(c)
+	defer globalControllerHealth.stopped(c)
+
 	// give caches 10 minutes to sync
 	cacheSyncCtx, cacheSyncCancel := context.WithTimeout(ctx, c.cacheSyncTimeout)
 	defer cacheSyncCancel()
@@ -64,6 +101,7 @@ func (c *baseController) Run(ctx context.Context, workers int) {
 			klog.Exit(err)
 		}
 	}
+	globalControllerHealth.cachesSynced(c)
 
 	var workerWg sync.WaitGroup
 	defer func() {
@@ -71,19 +109,23 @@ func (c *baseController) Run(ctx context.Context, workers int) {
 		workerWg.Wait()
 	}()
 
-	// queueContext is used to track and initiate queue shutdown
-	queueContext, queueContextCancel := context.WithCancel(context.TODO())
+	// queueContext is the context of the syncs with the values of the context of Run, it is only cancelled when
+	// the in-flight syncs are not drained in time on shutdown. stopContext tells the workers to stop taking new
+	// keys from the queue.
+	queueContext, queueContextCancel := context.WithCancel(valuesContext{ctx})
+	defer queueContextCancel()
+	stopContext, stopContextCancel := context.WithCancel(queueContext)
 
 	for i := 1; i <= workers; i++ {
 		klog.Infof("Starting #%d worker of %s controller ...", i, c.name)
 		workerWg.Add(1)
-		go func() {
+		go func(index int) {
 			defer func() {
 				klog.Infof("Shutting down worker of %s controller ...", c.name)
 				workerWg.Done()
 			}()
-			c.runWorker(queueContext)
-		}()
+			c.runWorker(queueContext, stopContext, index, workers)
+		}(i)
 	}
 
 	// runPeriodicalResync is independent from queue
@@ -98,13 +140,35 @@ func (c *baseController) Run(ctx context.Context, workers int) {
 	// Handle controller shutdown
 
 	<-ctx.Done()                     // wait for controller context to be cancelled
-	c.syncContext.Queue().ShutDown() // shutdown the controller queue first
-	queueContextCancel()             // cancel the queue context, which tell workers to initiate shutdown
-
-	// Wait for all workers to finish their job.
-	// at this point the Run() can hang and caller have to implement the logic that will kill
-	// this controller (SIGKILL).
+	c.syncContext.Queue().ShutDown() // shutdown the controller queue first, the new keys are ignored
+	stopContextCancel()              // tell the workers to stop taking keys and finish the in-flight syncs
 	klog.Infof("Shutting down %s ...", c.name)
+
+	drainTimeout := c.drainTimeoutOf(ctx)
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		workerWg.Wait()
+	}()
+	select {
+	case <-drained:
+	case <-time.After(drainTimeout):
+		// cancel the in-flight syncs, at this point the Run() can still hang until the syncs return and the
+		// caller has to implement the logic that will kill this controller (SIGKILL).
+		klog.Warningf("The in-flight syncs of %s are not finished in %v, cancelling them", c.name, drainTimeout)
+		queueContextCancel()
+	}
+}
+
+// drainTimeoutOf returns the drain timeout of the controller run with the context.
+func (c *baseController) drainTimeoutOf(ctx context.Context) time.Duration {
+	if c.drainTimeout > 0 {
+		return c.drainTimeout
+	}
+	if timeout, ok := ctx.Value(drainTimeoutKey{}).(time.Duration); ok && timeout > 0 {
+		return timeout
+	}
+	return defaultDrainTimeout
 }
 
 func (c *baseController) Sync(ctx context.Context, syncCtx SyncContext, key string) error {
@@ -121,31 +185,57 @@ func (c *baseController) runPeriodicalResync(ctx context.Context, interval time.
 }
 
 // runWorker runs a single worker
-// The worker is asked to terminate when the passed context is cancelled and is given terminationGraceDuration time
-// to complete its shutdown.
-func (c *baseController) runWorker(queueCtx context.Context) {
+// The worker stops taking keys from the queue when the stop context is cancelled, the in-flight sync is finished
+// with the queue context, which is only cancelled when the sync is not finished in the drain timeout.
+// The worker with the index (starting from 1) out of the total workers is paused while the back-pressure shrinks
+// the active workers below its index.
+func (c *baseController) runWorker(queueCtx, stopCtx context.Context, index, workers int) {
 	wait.UntilWithContext(
-		queueCtx,
-		func(queueCtx context.Context) {
+		stopCtx,
+		func(stopCtx context.Context) {
 			for {
+				if index > globalBackPressure.activeWorkers(workers) {
+					select {
+					case <-stopCtx.Done():
+						return
+					case <-time.After(backPressureBaseDelay):
+						continue
+					}
+				}
+
 				select {
-				case <-queueCtx.Done():
+				case <-stopCtx.Done():
 					return
 				default:
-					c.processNextWorkItem(queueCtx)
+					c.processNextWorkItem(queueCtx, stopCtx)
 				}
 			}
 		},
 		1*time.Second)
 }
 
-func (c *baseController) processNextWorkItem(queueCtx context.Context) {
+// withLogger returns the context of the sync of the key with the logger of the controller, which has the key as
+// the "key" field.
+func (c *baseController) withLogger(ctx context.Context, key string) context.Context {
+	logger := c.logger
+	if logger.GetSink() == nil {
+		logger = klog.LoggerWithName(klog.Background(), c.name)
+	}
+	return klog.NewContext(ctx, klog.LoggerWithValues(logger, "key", key))
+}
+
+func (c *baseController) processNextWorkItem(queueCtx, stopCtx context.Context) {
 	key, quit := c.syncContext.Queue().Get()
 	if quit {
 		return
 	}
 	defer c.syncContext.Queue().Done(key)
 
+	// the keys left in the queue on shutdown are not synced, they are synced by the next leader on startup.
+	if stopCtx.Err() != nil {
+		return
+	}
+
 	syncCtx := c.syncContext.(syncContext)
 	var ok bool
 	queueKey, ok := key.(string)
@@ -154,12 +244,24 @@ func (c *baseController) processNextWorkItem(queueCtx context.Context) {
 		return
 	}
 
-	if err := c.sync(queueCtx, syncCtx, queueKey); err != nil {
+	if err := c.tracedSync(c.withLogger(queueCtx, queueKey), syncCtx, queueKey); err != nil {
 		if klog.V(4).Enabled() || key != "key" {
 			utilruntime.HandleError(fmt.Errorf("%q controller failed to sync %q, err: %w", c.name, key, err))
 		} else {
 			utilruntime.HandleError(fmt.Errorf("%s reconciliation failed: %w", c.name, err))
 		}
+		// retry the throttled key after the back-pressure delay instead of the per-key backoff, so that the
+		// retries of all the controllers slow down together.
+		if isThrottlingError(err) {
+			globalBackPressure.throttled()
+			c.syncContext.Queue().AddAfter(key, globalBackPressure.retryDelay())
+			return
+		}
+		if c.maxRetries > 0 && c.syncContext.Queue().NumRequeues(key) >= c.maxRetries {
+			klog.Warningf("%q controller drops %q after %d retries", c.name, key, c.maxRetries)
+			c.syncContext.Queue().Forget(key)
+			return
+		}
 		c.syncContext.Queue().AddRateLimited(key)
 		return
 	}
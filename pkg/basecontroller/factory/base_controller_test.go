@@ -0,0 +1,109 @@
+package factory
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestControllerDrain(t *testing.T) {
+	cases := []struct {
+		name              string
+		drainTimeout      time.Duration
+		ctxDrainTimeout   time.Duration
+		release           bool
+		expectedCancelled bool
+	}{
+		{
+			name:         "in-flight sync is drained",
+			drainTimeout: time.Minute,
+			release:      true,
+		},
+		{
+			name:              "in-flight sync is cancelled after the drain timeout",
+			drainTimeout:      500 * time.Millisecond,
+			expectedCancelled: true,
+		},
+		{
+			name:              "in-flight sync is cancelled after the drain timeout of the context",
+			ctxDrainTimeout:   500 * time.Millisecond,
+			expectedCancelled: true,
+		},
+		{
+			name:            "drain timeout of the controller overrides the context",
+			drainTimeout:    time.Minute,
+			ctxDrainTimeout: 500 * time.Millisecond,
+			release:         true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			cancelled := make(chan bool, 1)
+			synced := make(chan string, 10)
+			controller := New().WithSync(func(ctx context.Context, syncCtx SyncContext, key string) error {
+				synced <- key
+				if key != "in-flight" {
+					return nil
+				}
+				close(started)
+				select {
+				case <-release:
+					cancelled <- false
+				case <-ctx.Done():
+					cancelled <- true
+				}
+				return nil
+			}).ToController("test")
+			Configure(controller, ControllerOptions{DrainTimeout: c.drainTimeout})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if c.ctxDrainTimeout > 0 {
+				ctx = WithDrainTimeout(ctx, c.ctxDrainTimeout)
+			}
+			stopped := make(chan struct{})
+			go func() {
+				defer close(stopped)
+				controller.Run(ctx, 1)
+			}()
+
+			controller.SyncContext().Queue().Add("in-flight")
+			<-started
+			controller.SyncContext().Queue().Add("queued")
+			cancel()
+
+			// the keys added on shutdown are ignored
+			time.Sleep(50 * time.Millisecond)
+			controller.SyncContext().Queue().Add("new")
+			select {
+			case <-stopped:
+				t.Fatalf("expected the controller waiting for the in-flight sync")
+			default:
+			}
+			if c.release {
+				// release the sync after the drain timeout of the context
+				time.Sleep(c.ctxDrainTimeout)
+				close(release)
+			}
+
+			select {
+			case <-stopped:
+			case <-time.After(10 * time.Second):
+				t.Fatalf("expected the controller stopped")
+			}
+			if actual := <-cancelled; actual != c.expectedCancelled {
+				t.Errorf("expected the in-flight sync cancelled %v, but got %v", c.expectedCancelled, actual)
+			}
+			close(synced)
+			var keys []string
+			for key := range synced {
+				keys = append(keys, key)
+			}
+			if len(keys) != 1 || keys[0] != "in-flight" {
+				t.Errorf("expected only the in-flight key synced, but got %v", keys)
+			}
+		})
+	}
+}
@@ -3,24 +3,43 @@ package factory
 import (
 	"fmt"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
 
+// PriorityFunc returns true if the update of the object should be processed ahead of the routine events and
+// resyncs, e.g. the health degradation of an addon. The deletions are always prioritized.
+type PriorityFunc func(old, new runtime.Object) bool
+
 // syncContext implements SyncContext and provide user access to queue and object that caused
 // the sync to be triggered.
 type syncContext struct {
-	queue workqueue.RateLimitingInterface
+	queue    workqueue.RateLimitingInterface
+	priority priorityAdder
+	// rateLimiter is the rate limiter of the queue, it is replaced when the queue options are configured.
+	rateLimiter *queueRateLimiter
 }
 
 var _ SyncContext = syncContext{}
 
 // NewSyncContext gives new sync context.
 func NewSyncContext(name string) SyncContext {
+	return newSyncContext(name, noPartitionFunc)
+}
+
+func newSyncContext(name string, partitionFunc QueuePartitionFunc) syncContext {
+	queue := newPartitionedQueue(name, partitionFunc)
+	rateLimiter := newQueueRateLimiter(QueueOptions{})
 	return syncContext{
-		queue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), name),
+		queue: workqueue.NewRateLimitingQueueWithDelayingInterface(
+			workqueue.NewDelayingQueueWithCustomQueue(queue, name),
+			rateLimiter,
+		),
+		priority:    queue,
+		rateLimiter: rateLimiter,
 	}
 }
 
@@ -29,7 +48,8 @@ func (c syncContext) Queue() workqueue.RateLimitingInterface {
 }
 
 // eventHandler provides default event handler that is added to an informers passed to controller factory.
-func (c syncContext) eventHandler(queueKeysFunc ObjectQueueKeysFunc, filter EventFilterFunc) cache.ResourceEventHandler {
+// The deletions and the updates that the priorityFunc returns true for are queued ahead of the other events.
+func (c syncContext) eventHandler(queueKeysFunc ObjectQueueKeysFunc, filter EventFilterFunc, priorityFunc PriorityFunc) cache.ResourceEventHandler {
 	resourceEventHandler := cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			runtimeObj, ok := obj.(runtime.Object)
@@ -45,20 +65,25 @@ func (c syncContext) eventHandler(queueKeysFunc ObjectQueueKeysFunc, filter Even
 				utilruntime.HandleError(fmt.Errorf("updated object %+v is not runtime Object", runtimeObj))
 				return
 			}
+			oldRuntimeObj, _ := old.(runtime.Object)
+			if isDeleting(oldRuntimeObj, runtimeObj) || (priorityFunc != nil && oldRuntimeObj != nil && priorityFunc(oldRuntimeObj, runtimeObj)) {
+				c.enqueuePriorityKeys(queueKeysFunc(runtimeObj)...)
+				return
+			}
 			c.enqueueKeys(queueKeysFunc(runtimeObj)...)
 		},
 		DeleteFunc: func(obj interface{}) {
 			runtimeObj, ok := obj.(runtime.Object)
 			if !ok {
 				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
-					c.enqueueKeys(queueKeysFunc(tombstone.Obj.(runtime.Object))...)
+					c.enqueuePriorityKeys(queueKeysFunc(tombstone.Obj.(runtime.Object))...)
 
 					return
 				}
 				utilruntime.HandleError(fmt.Errorf("updated object %+v is not runtime Object", runtimeObj))
 				return
 			}
-			c.enqueueKeys(queueKeysFunc(runtimeObj)...)
+			c.enqueuePriorityKeys(queueKeysFunc(runtimeObj)...)
 		},
 	}
 	if filter == nil {
@@ -75,3 +100,30 @@ func (c syncContext) enqueueKeys(keys ...string) {
 		c.queue.Add(qKey)
 	}
 }
+
+// enqueuePriorityKeys queues the keys ahead of the other keys if the queue supports the priority.
+func (c syncContext) enqueuePriorityKeys(keys ...string) {
+	if c.priority == nil {
+		c.enqueueKeys(keys...)
+		return
+	}
+	for _, qKey := range keys {
+		c.priority.AddPriority(qKey)
+	}
+}
+
+// isDeleting returns true if the object starts to be deleted by the update.
+func isDeleting(old, new runtime.Object) bool {
+	newAccessor, err := meta.Accessor(new)
+	if err != nil || newAccessor.GetDeletionTimestamp() == nil {
+		return false
+	}
+	if old == nil {
+		return true
+	}
+	oldAccessor, err := meta.Accessor(old)
+	if err != nil {
+		return true
+	}
+	return oldAccessor.GetDeletionTimestamp() == nil
+}
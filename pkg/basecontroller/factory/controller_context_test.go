@@ -0,0 +1,53 @@
+package factory
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestEventHandlerPriority(t *testing.T) {
+	newConfigMap := func(name string, labels map[string]string, deleting bool) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Labels: labels}}
+		if deleting {
+			now := metav1.Now()
+			cm.DeletionTimestamp = &now
+		}
+		return cm
+	}
+	degraded := func(old, new runtime.Object) bool {
+		return new.(*corev1.ConfigMap).Labels["health"] == "degraded"
+	}
+
+	syncCtx := newSyncContext("test", noPartitionFunc)
+	defer syncCtx.Queue().ShutDown()
+	handler := syncCtx.eventHandler(func(obj runtime.Object) []string {
+		key, _ := cache.MetaNamespaceKeyFunc(obj)
+		return []string{key}
+	}, nil, degraded)
+
+	handler.OnAdd(newConfigMap("added", nil, false))
+	handler.OnUpdate(newConfigMap("resynced", nil, false), newConfigMap("resynced", nil, false))
+	handler.OnUpdate(newConfigMap("degraded", nil, false), newConfigMap("degraded", map[string]string{"health": "degraded"}, false))
+	handler.OnUpdate(newConfigMap("deleting", nil, false), newConfigMap("deleting", nil, true))
+	handler.OnDelete(newConfigMap("deleted", nil, false))
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/tombstone", Obj: newConfigMap("tombstone", nil, false)})
+
+	var actual []string
+	for syncCtx.Queue().Len() > 0 {
+		key, _ := syncCtx.Queue().Get()
+		actual = append(actual, key.(string))
+		syncCtx.Queue().Done(key)
+	}
+	expected := []string{
+		"default/degraded", "default/deleting", "default/deleted", "default/tombstone",
+		"default/added", "default/resynced",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected keys %v, but got %v", expected, actual)
+	}
+}
@@ -22,6 +22,8 @@ func DefaultQueueKeysFunc(_ runtime.Object) []string {
 type Factory struct {
 	sync              SyncFunc
 	syncContext       SyncContext
+	partitionFunc     QueuePartitionFunc
+	priorityFunc      PriorityFunc
 	resyncInterval    time.Duration
 	informers         []filteredInformers
 	informerQueueKeys []informersWithQueueKey
@@ -142,6 +144,22 @@ func (f *Factory) WithSyncContext(ctx SyncContext) *Factory {
 	return f
 }
 
+// WithQueuePartitionFunc partitions the queue keys of the controller by the partitionFunc, and the workers take
+// the keys from the partitions in turn, e.g. NamePartitionFunc isolates the addons driven by one controller so a
+// slow or flooded addon does not starve the others. It is ignored if the sync context is set by WithSyncContext.
+func (f *Factory) WithQueuePartitionFunc(partitionFunc QueuePartitionFunc) *Factory {
+	f.partitionFunc = partitionFunc
+	return f
+}
+
+// WithPriorityFunc queues the keys of the updates that the priorityFunc returns true for ahead of the routine
+// events and resyncs, e.g. to process the health degradation of the addons first on a hub with thousands of
+// clusters. The deletions are always queued ahead.
+func (f *Factory) WithPriorityFunc(priorityFunc PriorityFunc) *Factory {
+	f.priorityFunc = priorityFunc
+	return f
+}
+
 // Controller produce a runnable controller.
 func (f *Factory) ToController(name string) Controller {
 	if f.sync == nil {
@@ -149,10 +167,13 @@ func (f *Factory) ToController(name string) Controller {
 	}
 
 	var ctx SyncContext
-	if f.syncContext != nil {
+	switch {
+	case f.syncContext != nil:
 		ctx = f.syncContext
-	} else {
-		ctx = NewSyncContext(name)
+	case f.partitionFunc != nil:
+		ctx = newSyncContext(name, f.partitionFunc)
+	default:
+		ctx = newSyncContext(name, noPartitionFunc)
 	}
 
 	c := &baseController{
@@ -162,13 +183,14 @@ func (f *Factory) ToController(name string) Controller {
 		cachesToSync:     append([]cache.InformerSynced{}, f.cachesToSync...),
 		syncContext:      ctx,
 		cacheSyncTimeout: defaultCacheSyncTimeout,
+		logger:           controllerLogger(name, nil),
 	}
 
 	for i := range f.informerQueueKeys {
 		for d := range f.informerQueueKeys[i].informers {
 			informer := f.informerQueueKeys[i].informers[d]
 			queueKeyFn := f.informerQueueKeys[i].queueKeyFn
-			_, err := informer.AddEventHandler(c.syncContext.(syncContext).eventHandler(queueKeyFn, f.informerQueueKeys[i].filter))
+			_, err := informer.AddEventHandler(c.syncContext.(syncContext).eventHandler(queueKeyFn, f.informerQueueKeys[i].filter, f.priorityFunc))
 			if err != nil {
 				utilruntime.HandleError(err)
 			}
@@ -179,7 +201,7 @@ func (f *Factory) ToController(name string) Controller {
 	for i := range f.informers {
 		for d := range f.informers[i].informers {
 			informer := f.informers[i].informers[d]
-			_, err := informer.AddEventHandler(c.syncContext.(syncContext).eventHandler(DefaultQueueKeysFunc, f.informers[i].filter))
+			_, err := informer.AddEventHandler(c.syncContext.(syncContext).eventHandler(DefaultQueueKeysFunc, f.informers[i].filter, f.priorityFunc))
 			if err != nil {
 				utilruntime.HandleError(err)
 			}
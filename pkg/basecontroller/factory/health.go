@@ -0,0 +1,134 @@
+package factory
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/utils/clock"
+)
+
+// stuckSyncTimeout is the duration after which a sync in progress is considered stuck, e.g. blocked on a
+// dead connection, and the controller is reported unhealthy so the process is restarted by the liveness probe.
+const stuckSyncTimeout = 10 * time.Minute
+
+// globalControllerHealth tracks the health of all the running controllers in the process.
+var globalControllerHealth = newControllerHealth(clock.RealClock{})
+
+// controllerHealth tracks whether the caches of the running controllers are synced and the syncs in progress
+// of the controllers.
+type controllerHealth struct {
+	lock        sync.Mutex
+	clock       clock.Clock
+	controllers map[*baseController]*controllerState
+	nextSyncID  uint64
+}
+
+type controllerState struct {
+	cachesSynced bool
+	// syncs are the start times of the syncs in progress, keyed by sync id
+	syncs map[uint64]time.Time
+}
+
+func newControllerHealth(clock clock.Clock) *controllerHealth {
+	return &controllerHealth{clock: clock, controllers: map[*baseController]*controllerState{}}
+}
+
+// started registers a controller waiting for its caches to sync.
+func (h *controllerHealth) started(c *baseController) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.controllers[c] = &controllerState{syncs: map[uint64]time.Time{}}
+}
+
+// cachesSynced marks the caches of the controller as synced.
+func (h *controllerHealth) cachesSynced(c *baseController) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if state, ok := h.controllers[c]; ok {
+		state.cachesSynced = true
+	}
+}
+
+// stopped unregisters a controller after it is shut down.
+func (h *controllerHealth) stopped(c *baseController) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.controllers, c)
+}
+
+// syncStarted tracks a sync in progress of the controller, the returned func must be called when the sync ends.
+func (h *controllerHealth) syncStarted(c *baseController) func() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	state, ok := h.controllers[c]
+	if !ok {
+		return func() {}
+	}
+	id := h.nextSyncID
+	h.nextSyncID++
+	state.syncs[id] = h.clock.Now()
+	return func() {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		delete(state.syncs, id)
+	}
+}
+
+// notSynced returns the names of the running controllers whose caches are not synced yet.
+func (h *controllerHealth) notSynced() []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	var names []string
+	for c, state := range h.controllers {
+		if !state.cachesSynced {
+			names = append(names, c.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stuck returns the names of the running controllers with a sync in progress longer than the timeout.
+func (h *controllerHealth) stuck(timeout time.Duration) []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	var names []string
+	for c, state := range h.controllers {
+		for _, startTime := range state.syncs {
+			if h.clock.Since(startTime) > timeout {
+				names = append(names, c.name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ControllersReadyChecker returns a readiness check which fails until the informer caches of all the running
+// controllers are synced.
+func ControllersReadyChecker() healthz.HealthChecker {
+	return healthz.NamedCheck("controllers-cache-sync", func(_ *http.Request) error {
+		if names := globalControllerHealth.notSynced(); len(names) > 0 {
+			return fmt.Errorf("the caches of the controllers %s are not synced", strings.Join(names, ", "))
+		}
+		return nil
+	})
+}
+
+// ControllersLivenessChecker returns a liveness check which fails if a sync of a running controller has been in
+// progress for more than 10 minutes, since the worker of the controller is likely stuck.
+func ControllersLivenessChecker() healthz.HealthChecker {
+	return healthz.NamedCheck("controllers", func(_ *http.Request) error {
+		if names := globalControllerHealth.stuck(stuckSyncTimeout); len(names) > 0 {
+			return fmt.Errorf("the syncs of the controllers %s are stuck for more than %s",
+				strings.Join(names, ", "), stuckSyncTimeout)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,56 @@
+package factory
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestControllerHealth(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	h := newControllerHealth(fakeClock)
+	controllerA := &baseController{name: "a"}
+	controllerB := &baseController{name: "b"}
+
+	h.started(controllerA)
+	h.started(controllerB)
+	if names := h.notSynced(); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("expected controllers a and b not synced, but got %v", names)
+	}
+
+	h.cachesSynced(controllerA)
+	if names := h.notSynced(); !reflect.DeepEqual(names, []string{"b"}) {
+		t.Errorf("expected controller b not synced, but got %v", names)
+	}
+
+	syncDone := h.syncStarted(controllerA)
+	h.syncStarted(controllerB)
+	fakeClock.Step(time.Minute)
+	if names := h.stuck(5 * time.Minute); len(names) != 0 {
+		t.Errorf("expected no stuck controller, but got %v", names)
+	}
+
+	fakeClock.Step(5 * time.Minute)
+	if names := h.stuck(5 * time.Minute); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("expected controllers a and b stuck, but got %v", names)
+	}
+
+	syncDone()
+	if names := h.stuck(5 * time.Minute); !reflect.DeepEqual(names, []string{"b"}) {
+		t.Errorf("expected controller b stuck, but got %v", names)
+	}
+
+	// the controller is not reported after it is stopped
+	h.stopped(controllerB)
+	if names := h.stuck(5 * time.Minute); len(names) != 0 {
+		t.Errorf("expected no stuck controller, but got %v", names)
+	}
+	if names := h.notSynced(); len(names) != 0 {
+		t.Errorf("expected no controller not synced, but got %v", names)
+	}
+
+	// the syncs of a controller not running are not tracked
+	h.syncStarted(controllerB)()
+}
@@ -0,0 +1,59 @@
+package factory
+
+import (
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2"
+)
+
+// controllerLogger returns the logger of the controller, named by the controller name. The controllers get it
+// in their sync funcs by klog.FromContext, with the queue key of the sync as the "key" field. The logs are
+// filtered by the verbosity if it is set, otherwise by the klog verbosity.
+func controllerLogger(controllerName string, verbosity *int) klog.Logger {
+	logger := klog.LoggerWithName(klog.Background(), controllerName)
+	if verbosity == nil {
+		return logger
+	}
+
+	sink := logger.GetSink()
+	if sink == nil {
+		return logger
+	}
+	// skip the frame of the verbositySink, so the caller of the logger is reported.
+	if callDepthSink, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = callDepthSink.WithCallDepth(1)
+	}
+	return logr.New(&verbositySink{sink: sink, verbosity: *verbosity})
+}
+
+// verbositySink filters the info logs by the verbosity of the controller instead of the klog verbosity.
+type verbositySink struct {
+	sink      logr.LogSink
+	verbosity int
+}
+
+var _ logr.LogSink = &verbositySink{}
+
+// Init does not initialize the wrapped sink, which is initialized by its own logger.
+func (s *verbositySink) Init(info logr.RuntimeInfo) {}
+
+func (s *verbositySink) Enabled(level int) bool {
+	return level <= s.verbosity
+}
+
+// Info writes the logs enabled by the verbosity of the controller with the level 0, so they are not filtered by
+// the klog verbosity again.
+func (s *verbositySink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.sink.Info(0, msg, keysAndValues...)
+}
+
+func (s *verbositySink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.sink.Error(err, msg, keysAndValues...)
+}
+
+func (s *verbositySink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &verbositySink{sink: s.sink.WithValues(keysAndValues...), verbosity: s.verbosity}
+}
+
+func (s *verbositySink) WithName(name string) logr.LogSink {
+	return &verbositySink{sink: s.sink.WithName(name), verbosity: s.verbosity}
+}
@@ -0,0 +1,69 @@
+package factory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+type recordingSink struct {
+	levels *[]int
+	values []interface{}
+}
+
+func (s *recordingSink) Init(info logr.RuntimeInfo) {}
+
+func (s *recordingSink) Enabled(level int) bool { return true }
+
+func (s *recordingSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	*s.levels = append(*s.levels, level)
+}
+
+func (s *recordingSink) Error(err error, msg string, keysAndValues ...interface{}) {}
+
+func (s *recordingSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &recordingSink{levels: s.levels, values: append(s.values, keysAndValues...)}
+}
+
+func (s *recordingSink) WithName(name string) logr.LogSink { return s }
+
+func TestVerbositySink(t *testing.T) {
+	var levels []int
+	logger := logr.New(&verbositySink{sink: &recordingSink{levels: &levels}, verbosity: 4})
+
+	logger.V(2).Info("written")
+	logger.WithValues("cluster", "cluster1").V(4).Info("written")
+	logger.V(5).Info("filtered")
+
+	if len(levels) != 2 {
+		t.Fatalf("expected 2 logs written, but got %d", len(levels))
+	}
+	for _, level := range levels {
+		if level != 0 {
+			t.Errorf("expected the logs written with level 0, but got %d", level)
+		}
+	}
+	if !logger.V(4).Enabled() || logger.V(5).Enabled() {
+		t.Errorf("expected the logs enabled up to level 4")
+	}
+}
+
+func TestConfigureLogVerbosity(t *testing.T) {
+	newController := func() *baseController {
+		return New().
+			WithSync(func(_ context.Context, _ SyncContext, _ string) error { return nil }).
+			ToController("test-controller").(*baseController)
+	}
+	controller1, controller2 := newController(), newController()
+
+	verbosity := 4
+	Configure(controller1, ControllerOptions{LogVerbosity: &verbosity})
+	if !controller1.logger.V(4).Enabled() || controller1.logger.V(5).Enabled() {
+		t.Errorf("expected the logs of the controller enabled up to level 4")
+	}
+	// the controller with the same name follows the klog verbosity
+	if _, ok := controller2.logger.GetSink().(*verbositySink); ok {
+		t.Errorf("expected the other controller follows the klog verbosity")
+	}
+}
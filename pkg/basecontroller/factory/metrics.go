@@ -0,0 +1,83 @@
+package factory
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// tracerName is the name of the tracer starting the sync spans.
+const tracerName = "open-cluster-management.io/addon-framework"
+
+// OpenMetricsPath is the path the metrics are served in the OpenMetrics format, which carries the exemplars.
+const OpenMetricsPath = "/metrics/openmetrics"
+
+// syncDuration is the histogram of the sync duration of the controllers. When tracing is enabled, i.e. a
+// global tracer provider is set by otel.SetTracerProvider, each observation of a sampled sync carries the
+// trace of the sync as an exemplar, so a latency spike can be followed to the trace of the slow sync. The
+// sync key (e.g. <cluster>/<addon>) is an attribute of the trace instead of a label to bound the cardinality.
+var syncDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "addon_manager_controller_sync_duration_seconds",
+		Help:    "The duration of the syncs of the controllers, labeled by controller and result.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 16),
+	},
+	[]string{"controller", "result"},
+)
+
+func init() {
+	legacyregistry.RawMustRegister(syncDuration)
+}
+
+// OpenMetricsHandler returns an HTTP handler serving the metrics in the OpenMetrics format. The exemplars are
+// only exposed in the OpenMetrics format, which is not enabled by the default metrics handler.
+func OpenMetricsHandler() http.Handler {
+	return promhttp.HandlerFor(legacyregistry.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// tracedSync calls the sync func in a span and observes the sync duration.
+func (c *baseController) tracedSync(ctx context.Context, syncCtx SyncContext, key string) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, c.name,
+		trace.WithAttributes(attribute.String("controller", c.name), attribute.String("key", key)))
+	defer span.End()
+
+	syncDone := globalControllerHealth.syncStarted(c)
+	defer syncDone()
+
+	start := time.Now()
+	err := c.sync(ctx, syncCtx, key)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	observeSyncDuration(ctx, c.name, err, time.Since(start))
+	return err
+}
+
+// observeSyncDuration observes the sync duration with the trace in the context as the exemplar if the trace
+// is sampled.
+func observeSyncDuration(ctx context.Context, controller string, err error, duration time.Duration) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	observer := syncDuration.WithLabelValues(controller, result)
+
+	spanContext := trace.SpanContextFromContext(ctx)
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && spanContext.IsSampled() {
+		exemplarObserver.ObserveWithExemplar(duration.Seconds(), prometheus.Labels{
+			"trace_id": spanContext.TraceID().String(),
+			"span_id":  spanContext.SpanID().String(),
+		})
+		return
+	}
+	observer.Observe(duration.Seconds())
+}
@@ -0,0 +1,87 @@
+package factory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestObserveSyncDuration(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	spanID, _ := trace.SpanIDFromHex("0102030405060708")
+
+	cases := []struct {
+		name            string
+		traceFlags      trace.TraceFlags
+		err             error
+		expectedResult  string
+		expectExemplars bool
+	}{
+		{
+			name:           "no sampled trace",
+			expectedResult: "success",
+		},
+		{
+			name:            "sampled trace",
+			traceFlags:      trace.FlagsSampled,
+			expectedResult:  "success",
+			expectExemplars: true,
+		},
+		{
+			name:            "sampled trace of failed sync",
+			traceFlags:      trace.FlagsSampled,
+			err:             fmt.Errorf("failed"),
+			expectedResult:  "error",
+			expectExemplars: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			controller := "test-" + c.name
+			ctx := trace.ContextWithSpanContext(context.TODO(), trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    traceID,
+				SpanID:     spanID,
+				TraceFlags: c.traceFlags,
+			}))
+
+			observeSyncDuration(ctx, controller, c.err, 100*time.Millisecond)
+
+			metric := &dto.Metric{}
+			if err := syncDuration.WithLabelValues(controller, c.expectedResult).(prometheus.Metric).Write(metric); err != nil {
+				t.Fatal(err)
+			}
+			if count := metric.GetHistogram().GetSampleCount(); count != 1 {
+				t.Fatalf("expected 1 sample, but got %d", count)
+			}
+
+			var exemplars []*dto.Exemplar
+			for _, bucket := range metric.GetHistogram().GetBucket() {
+				if bucket.GetExemplar() != nil {
+					exemplars = append(exemplars, bucket.GetExemplar())
+				}
+			}
+			if !c.expectExemplars {
+				if len(exemplars) != 0 {
+					t.Errorf("expected no exemplar, but got %v", exemplars)
+				}
+				return
+			}
+			if len(exemplars) != 1 {
+				t.Fatalf("expected 1 exemplar, but got %v", exemplars)
+			}
+			labels := map[string]string{}
+			for _, label := range exemplars[0].GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["trace_id"] != traceID.String() || labels["span_id"] != spanID.String() {
+				t.Errorf("unexpected exemplar labels %v", labels)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package factory
+
+import "time"
+
+// ControllerOptions are the options of a controller set by the component running it instead of its constructor,
+// e.g. each addon manager in a process tunes the controllers it runs without changing the controllers of the
+// other managers.
+type ControllerOptions struct {
+	// Queue tunes the work queue of the controller.
+	Queue QueueOptions
+
+	// LogVerbosity is the log verbosity of the controller regardless of the klog verbosity, e.g. the logs of the
+	// level 4 of the controller are written if it is 4 while the klog verbosity is 2. The klog verbosity is used
+	// if it is nil.
+	LogVerbosity *int
+
+	// DrainTimeout is how long the in-flight syncs of the controller are waited for on shutdown before they are
+	// cancelled. The timeout set by WithDrainTimeout on the context of Run, or 10s, is used if it is zero.
+	DrainTimeout time.Duration
+}
+
+// Configure sets the options of the controller created by the Factory, the other controllers, including the ones
+// with the same name, are not changed. It must be called before the controller is run, and it does nothing if
+// the controller is not created by the Factory.
+func Configure(controller Controller, options ControllerOptions) {
+	c, ok := controller.(*baseController)
+	if !ok {
+		return
+	}
+
+	c.maxRetries = options.Queue.MaxRetries
+	c.logger = controllerLogger(c.name, options.LogVerbosity)
+	c.drainTimeout = options.DrainTimeout
+	if syncCtx, ok := c.syncContext.(syncContext); ok && syncCtx.rateLimiter != nil {
+		syncCtx.rateLimiter.set(options.Queue)
+	}
+}
@@ -0,0 +1,244 @@
+package factory
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// QueuePartitionFunc returns the partition of the queue key. The keys of each partition are queued separately
+// and the workers take the keys from the partitions in turn, so a partition flooded with keys does not delay
+// the keys of the other partitions.
+type QueuePartitionFunc func(key string) string
+
+// NamePartitionFunc partitions the namespace/name queue keys by the name, e.g. the cluster/addon keys of the
+// addon controllers are partitioned by the addon.
+func NamePartitionFunc(key string) string {
+	_, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return key
+	}
+	return name
+}
+
+// noPartitionFunc puts all the keys in one partition.
+func noPartitionFunc(_ string) string {
+	return ""
+}
+
+// NewPartitionedSyncContext gives a new sync context whose queue is partitioned by the partitionFunc.
+func NewPartitionedSyncContext(name string, partitionFunc QueuePartitionFunc) SyncContext {
+	return newSyncContext(name, partitionFunc)
+}
+
+// priorityAdder is implemented by the queues that queue the prioritized keys ahead of the others.
+type priorityAdder interface {
+	AddPriority(item interface{})
+}
+
+// partitionedQueue is a workqueue.Interface with a queue for each partition and a priority queue. Get takes
+// the keys from the priority queue first, and then from the non-empty partitions in round-robin.
+//
+// The partition queues and the priority queue only keep the order of the keys, the dirty and processing keys
+// are tracked by the partitionedQueue, so a key is never processed by two workers at the same time even if it
+// is queued in both its partition and the priority queue.
+type partitionedQueue struct {
+	name          string
+	partitionFunc QueuePartitionFunc
+
+	lock       sync.Mutex
+	cond       *sync.Cond
+	partitions []string
+	queues     map[string]*workqueue.Type
+	priority   *workqueue.Type
+	next       int
+	// dirty are the queued keys, the value is true if the key is prioritized
+	dirty map[interface{}]bool
+	// processing are the keys being processed
+	processing map[interface{}]bool
+	// requeued are the keys added while being processed, they are queued when they are done
+	requeued     map[interface{}]bool
+	shuttingDown bool
+}
+
+var _ workqueue.Interface = &partitionedQueue{}
+var _ priorityAdder = &partitionedQueue{}
+
+func newPartitionedQueue(name string, partitionFunc QueuePartitionFunc) *partitionedQueue {
+	q := &partitionedQueue{
+		name:          name,
+		partitionFunc: partitionFunc,
+		queues:        map[string]*workqueue.Type{},
+		priority:      workqueue.NewNamed(fmt.Sprintf("%s-priority", name)),
+		dirty:         map[interface{}]bool{},
+		processing:    map[interface{}]bool{},
+		requeued:      map[interface{}]bool{},
+	}
+	q.cond = sync.NewCond(&q.lock)
+	return q
+}
+
+func (q *partitionedQueue) partition(item interface{}) string {
+	key, ok := item.(string)
+	if !ok {
+		return ""
+	}
+	return q.partitionFunc(key)
+}
+
+// queue returns the queue of the partition, it must be called with the lock held.
+func (q *partitionedQueue) queue(partition string) *workqueue.Type {
+	queue, ok := q.queues[partition]
+	if !ok {
+		// the depth of each partition is exposed by the work-queue metrics of the partition
+		name := q.name
+		if len(partition) > 0 {
+			name = fmt.Sprintf("%s-%s", q.name, partition)
+		}
+		queue = workqueue.NewNamed(name)
+		q.queues[partition] = queue
+		q.partitions = append(q.partitions, partition)
+	}
+	return queue
+}
+
+func (q *partitionedQueue) Add(item interface{}) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.add(item, false)
+}
+
+// AddPriority adds the key ahead of the keys of all the partitions, a key already queued in its partition is
+// moved ahead.
+func (q *partitionedQueue) AddPriority(item interface{}) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.add(item, true)
+}
+
+// add queues the key, it must be called with the lock held.
+func (q *partitionedQueue) add(item interface{}, prioritized bool) {
+	if q.shuttingDown {
+		return
+	}
+	if q.processing[item] {
+		q.requeued[item] = q.requeued[item] || prioritized
+		return
+	}
+	if queuedPrioritized, ok := q.dirty[item]; ok {
+		if !prioritized || queuedPrioritized {
+			return
+		}
+		// the key left in the partition queue is skipped by Get
+	}
+
+	q.dirty[item] = prioritized
+	if prioritized {
+		q.priority.Add(item)
+	} else {
+		q.queue(q.partition(item)).Add(item)
+	}
+	q.cond.Signal()
+}
+
+func (q *partitionedQueue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.dirty)
+}
+
+// Get blocks until a key is available in the priority queue or any partition. Only Get takes the keys from the
+// queues and it holds the lock, so the Get of a non-empty queue never blocks.
+func (q *partitionedQueue) Get() (interface{}, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for {
+		if item, ok := q.pop(); ok {
+			delete(q.dirty, item)
+			q.processing[item] = true
+			return item, false
+		}
+		if q.shuttingDown {
+			return nil, true
+		}
+		q.cond.Wait()
+	}
+}
+
+// pop takes the next key from the queues, it must be called with the lock held.
+func (q *partitionedQueue) pop() (interface{}, bool) {
+	for q.priority.Len() > 0 {
+		item := q.take(q.priority)
+		if prioritized, ok := q.dirty[item]; ok && prioritized {
+			return item, true
+		}
+	}
+
+	for i := range q.partitions {
+		index := (q.next + i) % len(q.partitions)
+		queue := q.queues[q.partitions[index]]
+		for queue.Len() > 0 {
+			item := q.take(queue)
+			// skip the key moved to the priority queue
+			if prioritized, ok := q.dirty[item]; !ok || prioritized {
+				continue
+			}
+			q.next = (index + 1) % len(q.partitions)
+			return item, true
+		}
+	}
+	return nil, false
+}
+
+// take gets the key from a non-empty queue and marks it done in the queue, the processing of the key is
+// tracked by the partitionedQueue.
+func (q *partitionedQueue) take(queue *workqueue.Type) interface{} {
+	item, _ := queue.Get()
+	queue.Done(item)
+	return item
+}
+
+func (q *partitionedQueue) Done(item interface{}) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.processing, item)
+	// the key added again while it was processed is queued by Done
+	if prioritized, ok := q.requeued[item]; ok {
+		delete(q.requeued, item)
+		q.add(item, prioritized)
+	}
+	q.cond.Broadcast()
+}
+
+func (q *partitionedQueue) ShutDown() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.shutDown()
+}
+
+func (q *partitionedQueue) ShutDownWithDrain() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.shutDown()
+	for len(q.processing) > 0 {
+		q.cond.Wait()
+	}
+}
+
+// shutDown marks the queue shutting down and wakes up the waiting Gets, it must be called with the lock held.
+func (q *partitionedQueue) shutDown() {
+	q.shuttingDown = true
+	q.cond.Broadcast()
+	q.priority.ShutDown()
+	for _, queue := range q.queues {
+		queue.ShutDown()
+	}
+}
+
+func (q *partitionedQueue) ShuttingDown() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.shuttingDown
+}
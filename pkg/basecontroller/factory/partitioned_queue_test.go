@@ -0,0 +1,152 @@
+package factory
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNamePartitionFunc(t *testing.T) {
+	cases := map[string]string{
+		"cluster1/addon1": "addon1",
+		"addon1":          "addon1",
+		"a/b/c":           "a/b/c",
+	}
+	for key, expected := range cases {
+		if actual := NamePartitionFunc(key); actual != expected {
+			t.Errorf("expected partition %q of key %q, but got %q", expected, key, actual)
+		}
+	}
+}
+
+func TestPartitionedQueue(t *testing.T) {
+	q := newPartitionedQueue("test", NamePartitionFunc)
+
+	// addon1 floods the queue before addon2 and addon3 are queued
+	for _, key := range []string{"cluster1/addon1", "cluster2/addon1", "cluster3/addon1", "cluster1/addon2", "cluster1/addon3"} {
+		q.Add(key)
+	}
+	q.Add("cluster1/addon1")
+	if q.Len() != 5 {
+		t.Errorf("expected 5 keys queued, but got %d", q.Len())
+	}
+
+	var actual []interface{}
+	for i := 0; i < 5; i++ {
+		key, shutdown := q.Get()
+		if shutdown {
+			t.Fatalf("unexpected shutdown")
+		}
+		actual = append(actual, key)
+		if key == "cluster1/addon1" {
+			// added again while it is processed
+			q.Add(key)
+		}
+		q.Done(key)
+	}
+	expected := []interface{}{"cluster1/addon1", "cluster1/addon2", "cluster1/addon3", "cluster2/addon1", "cluster3/addon1"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected keys %v, but got %v", expected, actual)
+	}
+
+	key, _ := q.Get()
+	if key != "cluster1/addon1" {
+		t.Errorf("expected the key added in processing is queued again, but got %v", key)
+	}
+	q.Done(key)
+
+	// the Get waiting for a key is woken up by the Add
+	got := make(chan interface{})
+	go func() {
+		key, _ := q.Get()
+		got <- key
+	}()
+	time.Sleep(10 * time.Millisecond)
+	q.Add("cluster2/addon2")
+	select {
+	case key := <-got:
+		if key != "cluster2/addon2" {
+			t.Errorf("expected key cluster2/addon2, but got %v", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the Get is not woken up by the Add")
+	}
+	q.Done("cluster2/addon2")
+
+	q.ShutDown()
+	if !q.ShuttingDown() {
+		t.Errorf("expected the queue is shutting down")
+	}
+	if _, shutdown := q.Get(); !shutdown {
+		t.Errorf("expected the Get returns shutdown")
+	}
+}
+
+func TestPartitionedSyncContext(t *testing.T) {
+	queue := NewPartitionedSyncContext("test", NamePartitionFunc).Queue()
+	defer queue.ShutDown()
+
+	queue.AddAfter("cluster1/addon1", 10*time.Millisecond)
+	queue.AddRateLimited("cluster1/addon2")
+	for i := 0; i < 2; i++ {
+		done := make(chan interface{})
+		go func() {
+			key, _ := queue.Get()
+			done <- key
+		}()
+		select {
+		case key := <-done:
+			queue.Forget(key)
+			queue.Done(key)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the delayed key is not queued")
+		}
+	}
+}
+
+func TestPartitionedQueuePriority(t *testing.T) {
+	q := newPartitionedQueue("test", NamePartitionFunc)
+	defer q.ShutDown()
+
+	for _, key := range []string{"cluster1/addon1", "cluster2/addon1", "cluster1/addon2"} {
+		q.Add(key)
+	}
+	// the queued key is moved ahead, and a prioritized key is not moved back by the Add
+	q.AddPriority("cluster2/addon1")
+	q.AddPriority("cluster3/addon1")
+	q.Add("cluster3/addon1")
+	if q.Len() != 4 {
+		t.Errorf("expected 4 keys queued, but got %d", q.Len())
+	}
+
+	var actual []interface{}
+	for i := 0; i < 4; i++ {
+		key, _ := q.Get()
+		actual = append(actual, key)
+		if key == "cluster1/addon1" && i == 2 {
+			// prioritized while it is processed
+			q.AddPriority(key)
+			q.Add("cluster2/addon2")
+		}
+		q.Done(key)
+	}
+	expected := []interface{}{"cluster2/addon1", "cluster3/addon1", "cluster1/addon1", "cluster1/addon1"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected keys %v, but got %v", expected, actual)
+	}
+
+	key, _ := q.Get()
+	if key != "cluster1/addon2" {
+		t.Errorf("expected key cluster1/addon2, but got %v", key)
+	}
+	// the key queued in processing is not got by another worker
+	q.Add(key)
+	if next, _ := q.Get(); next != "cluster2/addon2" {
+		t.Errorf("expected key cluster2/addon2, but got %v", next)
+	}
+	q.Done("cluster2/addon2")
+	q.Done(key)
+	if q.Len() != 1 {
+		t.Errorf("expected the key added in processing is queued, but got %d keys", q.Len())
+	}
+}
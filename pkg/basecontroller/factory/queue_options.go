@@ -0,0 +1,95 @@
+package factory
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// defaultBaseDelay and defaultMaxDelay are the backoff of the failed keys of the
+	// workqueue.DefaultControllerRateLimiter.
+	defaultBaseDelay = 5 * time.Millisecond
+	defaultMaxDelay  = 1000 * time.Second
+)
+
+// QueueOptions tunes the work queue of a controller.
+type QueueOptions struct {
+	// RateLimiter limits the requeue of the keys. If it is nil, the keys are requeued with an exponential
+	// backoff from BaseDelay to MaxDelay and an overall limit of 10 qps and 100 burst, as the
+	// workqueue.DefaultControllerRateLimiter.
+	RateLimiter workqueue.RateLimiter
+
+	// BaseDelay is the backoff of the first retry of a failed key, it is ignored if the RateLimiter is set.
+	// Defaults to 5ms.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the backoff of the retries of a failed key, it is ignored if the RateLimiter is set.
+	// Defaults to 1000s.
+	MaxDelay time.Duration
+
+	// MaxRetries is the number of the retries of a failed key, after which the key is dropped until it is
+	// queued again by an event or a resync. Zero means the key is retried until it succeeds.
+	MaxRetries int
+}
+
+// rateLimiter returns the rate limiter of the queue.
+func (o QueueOptions) rateLimiter() workqueue.RateLimiter {
+	if o.RateLimiter != nil {
+		return o.RateLimiter
+	}
+
+	baseDelay, maxDelay := o.BaseDelay, o.MaxDelay
+	if baseDelay <= 0 {
+		baseDelay = defaultBaseDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxDelay
+	}
+	if maxDelay < baseDelay {
+		maxDelay = baseDelay
+	}
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(baseDelay, maxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	)
+}
+
+// queueRateLimiter is the rate limiter of a controller queue, it delegates to the rate limiter of the queue
+// options set by Configure, so the options apply to the queue created with the controller.
+type queueRateLimiter struct {
+	lock        sync.RWMutex
+	rateLimiter workqueue.RateLimiter
+}
+
+var _ workqueue.RateLimiter = &queueRateLimiter{}
+
+func newQueueRateLimiter(options QueueOptions) *queueRateLimiter {
+	return &queueRateLimiter{rateLimiter: options.rateLimiter()}
+}
+
+func (r *queueRateLimiter) set(options QueueOptions) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.rateLimiter = options.rateLimiter()
+}
+
+func (r *queueRateLimiter) When(item interface{}) time.Duration {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.rateLimiter.When(item)
+}
+
+func (r *queueRateLimiter) Forget(item interface{}) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	r.rateLimiter.Forget(item)
+}
+
+func (r *queueRateLimiter) NumRequeues(item interface{}) int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.rateLimiter.NumRequeues(item)
+}
@@ -0,0 +1,82 @@
+package factory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestQueueOptionsRateLimiter(t *testing.T) {
+	cases := []struct {
+		name           string
+		options        QueueOptions
+		failures       int
+		expectedDelays []time.Duration
+	}{
+		{
+			name:           "default backoff",
+			failures:       3,
+			expectedDelays: []time.Duration{5 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond},
+		},
+		{
+			name:           "backoff cap",
+			options:        QueueOptions{BaseDelay: time.Second, MaxDelay: 3 * time.Second},
+			failures:       3,
+			expectedDelays: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second},
+		},
+		{
+			name:           "max delay less than base delay",
+			options:        QueueOptions{BaseDelay: time.Second, MaxDelay: time.Millisecond},
+			failures:       2,
+			expectedDelays: []time.Duration{time.Second, time.Second},
+		},
+		{
+			name: "custom rate limiter",
+			options: QueueOptions{
+				RateLimiter: workqueue.NewItemFastSlowRateLimiter(time.Millisecond, time.Minute, 1),
+				BaseDelay:   time.Second,
+			},
+			failures:       2,
+			expectedDelays: []time.Duration{time.Millisecond, time.Minute},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rateLimiter := c.options.rateLimiter()
+			for i := 0; i < c.failures; i++ {
+				if delay := rateLimiter.When("key"); delay != c.expectedDelays[i] {
+					t.Errorf("expected delay %v of failure %d, but got %v", c.expectedDelays[i], i+1, delay)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigureQueueOptions(t *testing.T) {
+	newController := func(name string) *baseController {
+		return New().
+			WithSync(func(_ context.Context, _ SyncContext, _ string) error { return nil }).
+			ToController(name).(*baseController)
+	}
+	controller1, controller2 := newController("controller"), newController("controller")
+
+	Configure(controller1, ControllerOptions{Queue: QueueOptions{BaseDelay: time.Second, MaxRetries: 5}})
+
+	if controller1.maxRetries != 5 {
+		t.Errorf("expected the max retries of the controller is 5, but got %d", controller1.maxRetries)
+	}
+	if delay := controller1.syncContext.(syncContext).rateLimiter.When("key"); delay != time.Second {
+		t.Errorf("expected the backoff of the configured queue is 1s, but got %v", delay)
+	}
+
+	// the controller with the same name is not changed
+	if controller2.maxRetries != 0 {
+		t.Errorf("expected the max retries of the other controller is 0, but got %d", controller2.maxRetries)
+	}
+	if delay := controller2.syncContext.(syncContext).rateLimiter.When("key"); delay != defaultBaseDelay {
+		t.Errorf("expected the default backoff of the other controller, but got %v", delay)
+	}
+}
@@ -0,0 +1,352 @@
+// Package mqtt is a minimal MQTT 5 client publishing and subscribing the messages with QoS 0 and 1, which is
+// what the cloudevents work driver requires from a broker.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultKeepAlive   = 60 * time.Second
+	defaultDialTimeout = 10 * time.Second
+)
+
+// ClientOptions are the options to connect to a MQTT broker.
+type ClientOptions struct {
+	// BrokerHost is the host:port of the broker.
+	BrokerHost string
+	// ClientID identifies the client to the broker, the broker disconnects the existing client of the same id.
+	ClientID string
+	// Username and Password authenticate the client to the broker if they are set.
+	Username string
+	Password string
+	// TLSConfig connects to the broker over TLS if it is set.
+	TLSConfig *tls.Config
+	// KeepAlive is the interval the client pings the broker in, the connection is lost if the broker does not
+	// respond in the interval. It is 60s by default.
+	KeepAlive time.Duration
+	// DialTimeout is the timeout to connect to the broker, it is 10s by default.
+	DialTimeout time.Duration
+}
+
+// MessageHandler handles a message received from the subscribed topics. It is called in the order of the
+// messages from the goroutine reading the connection, so it must not wait for a publish or a subscribe of the
+// client.
+type MessageHandler func(message *PublishPacket)
+
+// Client is a connection to a MQTT broker. It is not reconnected once the connection is lost, the caller
+// connects a new client after Done is closed.
+type Client struct {
+	conn    net.Conn
+	handler MessageHandler
+
+	writeLock sync.Mutex
+
+	lock         sync.Mutex
+	nextPacketID uint16
+	// pending are the channels of the publishes and the subscribes waiting for their acknowledgements.
+	pending map[uint16]chan *Packet
+	err     error
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Connect connects to the broker and starts the session, the handler is called with the messages of the
+// topics subscribed later.
+func Connect(ctx context.Context, options ClientOptions, handler MessageHandler) (*Client, error) {
+	keepAlive := options.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = defaultKeepAlive
+	}
+	dialTimeout := options.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = defaultDialTimeout
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	var conn net.Conn
+	var err error
+	if options.TLSConfig != nil {
+		dialer := &tls.Dialer{Config: options.TLSConfig}
+		conn, err = dialer.DialContext(dialCtx, "tcp", options.BrokerHost)
+	} else {
+		dialer := &net.Dialer{}
+		conn, err = dialer.DialContext(dialCtx, "tcp", options.BrokerHost)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the MQTT broker %s: %w", options.BrokerHost, err)
+	}
+
+	reader := bufio.NewReader(conn)
+	if err := startSession(conn, reader, options, keepAlive, dialTimeout); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &Client{
+		conn:         conn,
+		handler:      handler,
+		nextPacketID: 1,
+		pending:      map[uint16]chan *Packet{},
+		done:         make(chan struct{}),
+	}
+	go c.read(reader, keepAlive)
+	go c.ping(keepAlive)
+	return c, nil
+}
+
+func startSession(conn net.Conn, reader *bufio.Reader, options ClientOptions, keepAlive, timeout time.Duration) error {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	connect := &ConnectPacket{
+		ClientID:  options.ClientID,
+		Username:  options.Username,
+		Password:  options.Password,
+		KeepAlive: uint16(keepAlive / time.Second),
+	}
+	if err := WritePacket(conn, connect.Packet()); err != nil {
+		return fmt.Errorf("failed to connect to the MQTT broker %s: %w", options.BrokerHost, err)
+	}
+	packet, err := ReadPacket(reader)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the MQTT broker %s: %w", options.BrokerHost, err)
+	}
+	code, err := ParseConnAck(packet)
+	if err != nil {
+		return err
+	}
+	if code != ConnAccepted {
+		return fmt.Errorf("the MQTT broker %s refused the connection with code %d", options.BrokerHost, code)
+	}
+	return conn.SetDeadline(time.Time{})
+}
+
+// Publish publishes the message to its topic, the packet id of the message is set by the client. It waits for
+// the broker to acknowledge the message if the qos is 1.
+func (c *Client) Publish(ctx context.Context, message *PublishPacket) error {
+	if message.QoS > 1 {
+		return fmt.Errorf("unsupported QoS %d", message.QoS)
+	}
+	publish := *message
+	if publish.QoS == 0 {
+		publish.PacketID = 0
+		return c.write(publish.Packet())
+	}
+
+	var ack chan *Packet
+	var err error
+	publish.PacketID, ack, err = c.newPendingPacket()
+	if err != nil {
+		return err
+	}
+	if err := c.write(publish.Packet()); err != nil {
+		c.removePendingPacket(publish.PacketID)
+		return err
+	}
+	packet, err := c.wait(ctx, publish.PacketID, ack)
+	if err != nil {
+		return err
+	}
+	// the reason code is omitted on success
+	if len(packet.Body) > 2 && packet.Body[2] >= ReasonCodeFailure {
+		return fmt.Errorf("the MQTT broker refused to publish to %s with reason code %#x", publish.Topic, packet.Body[2])
+	}
+	return nil
+}
+
+// Subscribe subscribes the topic filters with QoS 1, and waits for the broker to acknowledge them.
+func (c *Client) Subscribe(ctx context.Context, filters ...string) error {
+	packetID, ack, err := c.newPendingPacket()
+	if err != nil {
+		return err
+	}
+	subscribe := &SubscribePacket{PacketID: packetID, Filters: filters}
+	if err := c.write(subscribe.Packet()); err != nil {
+		c.removePendingPacket(packetID)
+		return err
+	}
+
+	packet, err := c.wait(ctx, packetID, ack)
+	if err != nil {
+		return err
+	}
+	codes, err := ParseSubAck(packet)
+	if err != nil {
+		return err
+	}
+	for i, code := range codes {
+		if code >= ReasonCodeFailure && i < len(filters) {
+			return fmt.Errorf("the MQTT broker refused to subscribe %s with reason code %#x", filters[i], code)
+		}
+	}
+	return nil
+}
+
+// Done returns a channel closed when the connection is closed or lost.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection is lost, it is nil if the connection is open or closed by Close.
+func (c *Client) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+// Close disconnects from the broker.
+func (c *Client) Close() error {
+	_ = c.write(&Packet{Type: PacketDisconnect})
+	c.close(nil)
+	return nil
+}
+
+func (c *Client) close(err error) {
+	c.closeOnce.Do(func() {
+		c.lock.Lock()
+		c.err = err
+		c.lock.Unlock()
+		c.conn.Close()
+		close(c.done)
+	})
+}
+
+func (c *Client) write(packet *Packet) error {
+	select {
+	case <-c.done:
+		return c.closedError()
+	default:
+	}
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if err := WritePacket(c.conn, packet); err != nil {
+		c.close(err)
+		return err
+	}
+	return nil
+}
+
+func (c *Client) closedError() error {
+	if err := c.Err(); err != nil {
+		return fmt.Errorf("the connection to the MQTT broker is lost: %w", err)
+	}
+	return fmt.Errorf("the connection to the MQTT broker is closed")
+}
+
+func (c *Client) newPendingPacket() (uint16, chan *Packet, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.pending) >= 65535 {
+		return 0, nil, fmt.Errorf("too many packets are waiting for the acknowledgements")
+	}
+	for {
+		packetID := c.nextPacketID
+		c.nextPacketID++
+		if c.nextPacketID == 0 {
+			c.nextPacketID = 1
+		}
+		if _, ok := c.pending[packetID]; !ok {
+			ack := make(chan *Packet, 1)
+			c.pending[packetID] = ack
+			return packetID, ack, nil
+		}
+	}
+}
+
+func (c *Client) removePendingPacket(packetID uint16) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.pending, packetID)
+}
+
+func (c *Client) wait(ctx context.Context, packetID uint16, ack chan *Packet) (*Packet, error) {
+	defer c.removePendingPacket(packetID)
+	select {
+	case packet := <-ack:
+		return packet, nil
+	case <-c.done:
+		return nil, c.closedError()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// read reads the packets from the broker until the connection is lost, the connection is lost if nothing is
+// read in the keep alive interval and a half.
+func (c *Client) read(reader *bufio.Reader, keepAlive time.Duration) {
+	for {
+		if err := c.conn.SetReadDeadline(time.Now().Add(keepAlive * 3 / 2)); err != nil {
+			c.close(err)
+			return
+		}
+		packet, err := ReadPacket(reader)
+		if err != nil {
+			c.close(err)
+			return
+		}
+
+		switch packet.Type {
+		case PacketPublish:
+			publish, err := ParsePublish(packet)
+			if err != nil {
+				c.close(err)
+				return
+			}
+			if c.handler != nil {
+				c.handler(publish)
+			}
+			if publish.QoS > 0 {
+				if err := c.write(NewPubAck(publish.PacketID)); err != nil {
+					return
+				}
+			}
+		case PacketPubAck, PacketSubAck:
+			packetID, err := ParsePacketID(packet)
+			if err != nil {
+				c.close(err)
+				return
+			}
+			c.lock.Lock()
+			ack, ok := c.pending[packetID]
+			c.lock.Unlock()
+			if ok {
+				select {
+				case ack <- packet:
+				default:
+				}
+			}
+		case PacketPingResp:
+		case PacketDisconnect:
+			c.close(fmt.Errorf("disconnected by the MQTT broker"))
+			return
+		default:
+			c.close(fmt.Errorf("unexpected packet type %d", packet.Type))
+			return
+		}
+	}
+}
+
+func (c *Client) ping(keepAlive time.Duration) {
+	ticker := time.NewTicker(keepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.write(&Packet{Type: PacketPingReq}); err != nil {
+				return
+			}
+		}
+	}
+}
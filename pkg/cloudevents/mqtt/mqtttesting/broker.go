@@ -0,0 +1,176 @@
+// Package mqtttesting provides a fake MQTT broker to test the clients publishing and subscribing the cloudevents.
+package mqtttesting
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"testing"
+
+	"open-cluster-management.io/addon-framework/pkg/cloudevents/mqtt"
+)
+
+// Broker is an in-process MQTT broker routing the published messages to the subscribers of the matching topic
+// filters with QoS 0. The messages are not retained, and the sessions are not persisted.
+type Broker struct {
+	listener net.Listener
+
+	lock          sync.Mutex
+	subscriptions map[*brokerConn][]string
+	published     []*mqtt.PublishPacket
+}
+
+type brokerConn struct {
+	conn      net.Conn
+	writeLock sync.Mutex
+}
+
+func (c *brokerConn) write(packet *mqtt.Packet) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return mqtt.WritePacket(c.conn, packet)
+}
+
+// NewBroker starts a broker listening on a random local port, it is stopped when the test is done.
+func NewBroker(t *testing.T) *Broker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start the MQTT broker: %v", err)
+	}
+	b := &Broker{
+		listener:      listener,
+		subscriptions: map[*brokerConn][]string{},
+	}
+	go b.serve()
+	t.Cleanup(b.Stop)
+	return b
+}
+
+// Host returns the host:port the broker listens on.
+func (b *Broker) Host() string {
+	return b.listener.Addr().String()
+}
+
+// Stop stops the broker and closes the connections of the clients.
+func (b *Broker) Stop() {
+	b.listener.Close()
+	b.DisconnectAll()
+}
+
+// DisconnectAll closes the connections of the clients, so they have to reconnect.
+func (b *Broker) DisconnectAll() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for conn := range b.subscriptions {
+		conn.conn.Close()
+		delete(b.subscriptions, conn)
+	}
+}
+
+// Published returns the messages published to the topics matching the topic filter.
+func (b *Broker) Published(filter string) []*mqtt.PublishPacket {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var published []*mqtt.PublishPacket
+	for _, publish := range b.published {
+		if mqtt.TopicMatches(filter, publish.Topic) {
+			published = append(published, publish)
+		}
+	}
+	return published
+}
+
+func (b *Broker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(&brokerConn{conn: conn})
+	}
+}
+
+func (b *Broker) handle(conn *brokerConn) {
+	defer func() {
+		conn.conn.Close()
+		b.lock.Lock()
+		delete(b.subscriptions, conn)
+		b.lock.Unlock()
+	}()
+
+	reader := bufio.NewReader(conn.conn)
+	packet, err := mqtt.ReadPacket(reader)
+	if err != nil || packet.Type != mqtt.PacketConnect {
+		return
+	}
+	if _, err := mqtt.ParseConnect(packet); err != nil {
+		return
+	}
+	b.lock.Lock()
+	b.subscriptions[conn] = nil
+	b.lock.Unlock()
+	if err := conn.write(mqtt.NewConnAck(mqtt.ConnAccepted)); err != nil {
+		return
+	}
+
+	for {
+		packet, err := mqtt.ReadPacket(reader)
+		if err != nil {
+			return
+		}
+
+		switch packet.Type {
+		case mqtt.PacketSubscribe:
+			subscribe, err := mqtt.ParseSubscribe(packet)
+			if err != nil {
+				return
+			}
+			b.lock.Lock()
+			b.subscriptions[conn] = append(b.subscriptions[conn], subscribe.Filters...)
+			b.lock.Unlock()
+			if err := conn.write(mqtt.NewSubAck(subscribe.PacketID, make([]byte, len(subscribe.Filters)))); err != nil {
+				return
+			}
+		case mqtt.PacketPublish:
+			publish, err := mqtt.ParsePublish(packet)
+			if err != nil {
+				return
+			}
+			b.route(publish)
+			if publish.QoS > 0 {
+				if err := conn.write(mqtt.NewPubAck(publish.PacketID)); err != nil {
+					return
+				}
+			}
+		case mqtt.PacketPingReq:
+			if err := conn.write(&mqtt.Packet{Type: mqtt.PacketPingResp}); err != nil {
+				return
+			}
+		case mqtt.PacketPubAck:
+		default:
+			return
+		}
+	}
+}
+
+func (b *Broker) route(publish *mqtt.PublishPacket) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.published = append(b.published, publish)
+
+	message := (&mqtt.PublishPacket{
+		Topic:          publish.Topic,
+		ContentType:    publish.ContentType,
+		UserProperties: publish.UserProperties,
+		Payload:        publish.Payload,
+	}).Packet()
+	for conn, filters := range b.subscriptions {
+		for _, filter := range filters {
+			if mqtt.TopicMatches(filter, publish.Topic) {
+				// the message is dropped if the subscriber is gone, as a broker does with QoS 0.
+				_ = conn.write(message)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,513 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PacketType is the type of the MQTT 5 control packets, only the packets used by the clients publishing and
+// subscribing the cloudevents are supported.
+type PacketType byte
+
+const (
+	PacketConnect    PacketType = 1
+	PacketConnAck    PacketType = 2
+	PacketPublish    PacketType = 3
+	PacketPubAck     PacketType = 4
+	PacketSubscribe  PacketType = 8
+	PacketSubAck     PacketType = 9
+	PacketPingReq    PacketType = 12
+	PacketPingResp   PacketType = 13
+	PacketDisconnect PacketType = 14
+)
+
+// maxRemainingLength is the max remaining length of a packet encoded in the 4 bytes of the fixed header.
+const maxRemainingLength = 268435455
+
+// Packet is a MQTT control packet, the Body is the variable header and the payload of the packet.
+type Packet struct {
+	Type  PacketType
+	Flags byte
+	Body  []byte
+}
+
+// ReadPacket reads a packet from the reader.
+func ReadPacket(r io.Reader) (*Packet, error) {
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	packet := &Packet{Type: PacketType(b[0] >> 4), Flags: b[0] & 0x0f}
+
+	length, err := readVarint(byteReader{r})
+	if err != nil {
+		return nil, err
+	}
+
+	packet.Body = make([]byte, length)
+	if _, err := io.ReadFull(r, packet.Body); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+// byteReader reads the bytes of a packet one by one from the reader.
+type byteReader struct {
+	r io.Reader
+}
+
+func (b byteReader) ReadByte() (byte, error) {
+	buf := make([]byte, 1)
+	_, err := io.ReadFull(b.r, buf)
+	return buf[0], err
+}
+
+// WritePacket writes the packet to the writer.
+func WritePacket(w io.Writer, packet *Packet) error {
+	length := len(packet.Body)
+	if length > maxRemainingLength {
+		return fmt.Errorf("the packet of %d bytes is too large", length)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, length+5))
+	buf.WriteByte(byte(packet.Type)<<4 | packet.Flags&0x0f)
+	writeVarint(buf, length)
+	buf.Write(packet.Body)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// protocolLevel is the protocol level of MQTT 5, the cloudevents of the ManifestWorks are published with the
+// content type property of MQTT 5 so the agents decode them in the structured mode.
+const protocolLevel = 5
+
+// ConnectPacket is the CONNECT packet a client starts the session with.
+type ConnectPacket struct {
+	ClientID string
+	Username string
+	Password string
+	// KeepAlive is the keep alive interval of the connection in seconds.
+	KeepAlive uint16
+}
+
+// Packet encodes the CONNECT packet with a clean start and no properties.
+func (p *ConnectPacket) Packet() *Packet {
+	var flags byte = 0x02
+	if len(p.Username) > 0 {
+		flags |= 0x80
+	}
+	if len(p.Password) > 0 {
+		flags |= 0x40
+	}
+
+	buf := &bytes.Buffer{}
+	writeString(buf, "MQTT")
+	buf.WriteByte(protocolLevel)
+	buf.WriteByte(flags)
+	writeUint16(buf, p.KeepAlive)
+	writeProperties(buf, nil)
+	writeString(buf, p.ClientID)
+	if len(p.Username) > 0 {
+		writeString(buf, p.Username)
+	}
+	if len(p.Password) > 0 {
+		writeString(buf, p.Password)
+	}
+	return &Packet{Type: PacketConnect, Body: buf.Bytes()}
+}
+
+// ParseConnect decodes a CONNECT packet, the will message of the packet is not supported and the properties
+// are ignored.
+func ParseConnect(packet *Packet) (*ConnectPacket, error) {
+	r := bytes.NewReader(packet.Body)
+	protocol, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+	level, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if protocol != "MQTT" || level != protocolLevel {
+		return nil, fmt.Errorf("unsupported protocol %s level %d", protocol, level)
+	}
+	flags, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if flags&0x04 != 0 {
+		return nil, fmt.Errorf("the will message is not supported")
+	}
+
+	connect := &ConnectPacket{}
+	if connect.KeepAlive, err = readUint16(r); err != nil {
+		return nil, err
+	}
+	if _, err := readProperties(r); err != nil {
+		return nil, err
+	}
+	if connect.ClientID, err = readString(r); err != nil {
+		return nil, err
+	}
+	if flags&0x80 != 0 {
+		if connect.Username, err = readString(r); err != nil {
+			return nil, err
+		}
+	}
+	if flags&0x40 != 0 {
+		if connect.Password, err = readString(r); err != nil {
+			return nil, err
+		}
+	}
+	return connect, nil
+}
+
+// The reason codes of the CONNACK packet.
+const (
+	ConnAccepted                   byte = 0x00
+	ConnRefusedBadUsernamePassword byte = 0x86
+	ConnRefusedNotAuthorized       byte = 0x87
+)
+
+// NewConnAck returns a CONNACK packet with the reason code and no properties.
+func NewConnAck(code byte) *Packet {
+	return &Packet{Type: PacketConnAck, Body: []byte{0, code, 0}}
+}
+
+// ParseConnAck returns the reason code of a CONNACK packet.
+func ParseConnAck(packet *Packet) (byte, error) {
+	if packet.Type != PacketConnAck || len(packet.Body) < 2 {
+		return 0, fmt.Errorf("malformed CONNACK packet")
+	}
+	return packet.Body[1], nil
+}
+
+// PublishPacket is the PUBLISH packet of a message, the PacketID is only set for QoS 1.
+type PublishPacket struct {
+	Topic    string
+	QoS      byte
+	PacketID uint16
+	// ContentType is the content type property of the message, e.g. application/cloudevents+json.
+	ContentType string
+	// UserProperties are the user properties of the message in their order.
+	UserProperties []UserProperty
+	Payload        []byte
+}
+
+// UserProperty is a user property of a MQTT 5 packet.
+type UserProperty struct {
+	Key   string
+	Value string
+}
+
+// Packet encodes the PUBLISH packet.
+func (p *PublishPacket) Packet() *Packet {
+	buf := bytes.NewBuffer(make([]byte, 0, len(p.Topic)+len(p.Payload)+len(p.ContentType)+8))
+	writeString(buf, p.Topic)
+	if p.QoS > 0 {
+		writeUint16(buf, p.PacketID)
+	}
+	writeProperties(buf, &properties{contentType: p.ContentType, userProperties: p.UserProperties})
+	buf.Write(p.Payload)
+	return &Packet{Type: PacketPublish, Flags: p.QoS << 1, Body: buf.Bytes()}
+}
+
+// ParsePublish decodes a PUBLISH packet, the properties other than the content type and the user properties
+// are ignored.
+func ParsePublish(packet *Packet) (*PublishPacket, error) {
+	publish := &PublishPacket{QoS: (packet.Flags >> 1) & 0x03}
+	if publish.QoS > 1 {
+		return nil, fmt.Errorf("unsupported QoS %d", publish.QoS)
+	}
+
+	r := bytes.NewReader(packet.Body)
+	var err error
+	if publish.Topic, err = readString(r); err != nil {
+		return nil, err
+	}
+	if publish.QoS > 0 {
+		if publish.PacketID, err = readUint16(r); err != nil {
+			return nil, err
+		}
+	}
+	props, err := readProperties(r)
+	if err != nil {
+		return nil, err
+	}
+	publish.ContentType = props.contentType
+	publish.UserProperties = props.userProperties
+	publish.Payload = packet.Body[len(packet.Body)-r.Len():]
+	return publish, nil
+}
+
+// NewPubAck returns a PUBACK packet acknowledging the QoS 1 PUBLISH packet of the id with success, the reason
+// code and the properties are omitted.
+func NewPubAck(packetID uint16) *Packet {
+	buf := &bytes.Buffer{}
+	writeUint16(buf, packetID)
+	return &Packet{Type: PacketPubAck, Body: buf.Bytes()}
+}
+
+// SubscribePacket is the SUBSCRIBE packet of the topic filters, the filters are subscribed with QoS 1.
+type SubscribePacket struct {
+	PacketID uint16
+	Filters  []string
+}
+
+// Packet encodes the SUBSCRIBE packet with no properties.
+func (p *SubscribePacket) Packet() *Packet {
+	buf := &bytes.Buffer{}
+	writeUint16(buf, p.PacketID)
+	writeProperties(buf, nil)
+	for _, filter := range p.Filters {
+		writeString(buf, filter)
+		buf.WriteByte(1)
+	}
+	return &Packet{Type: PacketSubscribe, Flags: 0x02, Body: buf.Bytes()}
+}
+
+// ParseSubscribe decodes a SUBSCRIBE packet, the properties and the subscription options are ignored.
+func ParseSubscribe(packet *Packet) (*SubscribePacket, error) {
+	r := bytes.NewReader(packet.Body)
+	subscribe := &SubscribePacket{}
+	var err error
+	if subscribe.PacketID, err = readUint16(r); err != nil {
+		return nil, err
+	}
+	if _, err := readProperties(r); err != nil {
+		return nil, err
+	}
+	for r.Len() > 0 {
+		filter, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.ReadByte(); err != nil {
+			return nil, err
+		}
+		subscribe.Filters = append(subscribe.Filters, filter)
+	}
+	if len(subscribe.Filters) == 0 {
+		return nil, fmt.Errorf("no topic filter is subscribed")
+	}
+	return subscribe, nil
+}
+
+// ReasonCodeFailure is the minimum reason code of the failures acknowledged by the PUBACK and SUBACK packets.
+const ReasonCodeFailure byte = 0x80
+
+// NewSubAck returns a SUBACK packet with the reason codes of the topic filters and no properties.
+func NewSubAck(packetID uint16, codes []byte) *Packet {
+	buf := &bytes.Buffer{}
+	writeUint16(buf, packetID)
+	writeProperties(buf, nil)
+	buf.Write(codes)
+	return &Packet{Type: PacketSubAck, Body: buf.Bytes()}
+}
+
+// ParseSubAck returns the reason codes of the topic filters of a SUBACK packet.
+func ParseSubAck(packet *Packet) ([]byte, error) {
+	r := bytes.NewReader(packet.Body)
+	if _, err := readUint16(r); err != nil {
+		return nil, err
+	}
+	if _, err := readProperties(r); err != nil {
+		return nil, err
+	}
+	return packet.Body[len(packet.Body)-r.Len():], nil
+}
+
+// ParsePacketID returns the packet id at the start of the variable header of the packet, e.g. PUBACK and SUBACK.
+func ParsePacketID(packet *Packet) (uint16, error) {
+	return readUint16(bytes.NewReader(packet.Body))
+}
+
+// TopicMatches returns whether the topic matches the topic filter with the single level wildcard "+" and the
+// multi level wildcard "#".
+func TopicMatches(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+// The identifiers of the MQTT 5 properties, the properties are skipped by the type of their values if they are
+// not used.
+const (
+	propertyPayloadFormat          byte = 0x01
+	propertyMessageExpiry          byte = 0x02
+	propertyContentType            byte = 0x03
+	propertyResponseTopic          byte = 0x08
+	propertyCorrelationData        byte = 0x09
+	propertySubscriptionIdentifier byte = 0x0B
+	propertySessionExpiry          byte = 0x11
+	propertyAssignedClientID       byte = 0x12
+	propertyServerKeepAlive        byte = 0x13
+	propertyAuthMethod             byte = 0x15
+	propertyAuthData               byte = 0x16
+	propertyRequestProblemInfo     byte = 0x17
+	propertyWillDelay              byte = 0x18
+	propertyRequestResponseInfo    byte = 0x19
+	propertyResponseInfo           byte = 0x1A
+	propertyServerReference        byte = 0x1C
+	propertyReasonString           byte = 0x1F
+	propertyReceiveMaximum         byte = 0x21
+	propertyTopicAliasMaximum      byte = 0x22
+	propertyTopicAlias             byte = 0x23
+	propertyMaximumQoS             byte = 0x24
+	propertyRetainAvailable        byte = 0x25
+	propertyUserProperty           byte = 0x26
+	propertyMaximumPacketSize      byte = 0x27
+	propertyWildcardAvailable      byte = 0x28
+	propertySubIDAvailable         byte = 0x29
+	propertySharedSubAvailable     byte = 0x2A
+)
+
+// properties are the MQTT 5 properties of a packet used by the clients.
+type properties struct {
+	contentType    string
+	userProperties []UserProperty
+}
+
+func writeProperties(buf *bytes.Buffer, props *properties) {
+	data := &bytes.Buffer{}
+	if props != nil {
+		if len(props.contentType) > 0 {
+			data.WriteByte(propertyContentType)
+			writeString(data, props.contentType)
+		}
+		for _, userProperty := range props.userProperties {
+			data.WriteByte(propertyUserProperty)
+			writeString(data, userProperty.Key)
+			writeString(data, userProperty.Value)
+		}
+	}
+	writeVarint(buf, data.Len())
+	buf.Write(data.Bytes())
+}
+
+func readProperties(r *bytes.Reader) (*properties, error) {
+	length, err := readVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > r.Len() {
+		return nil, fmt.Errorf("malformed properties of %d bytes", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	props := &properties{}
+	pr := bytes.NewReader(data)
+	for pr.Len() > 0 {
+		id, _ := pr.ReadByte()
+		switch id {
+		case propertyContentType:
+			if props.contentType, err = readString(pr); err != nil {
+				return nil, err
+			}
+		case propertyUserProperty:
+			userProperty := UserProperty{}
+			if userProperty.Key, err = readString(pr); err != nil {
+				return nil, err
+			}
+			if userProperty.Value, err = readString(pr); err != nil {
+				return nil, err
+			}
+			props.userProperties = append(props.userProperties, userProperty)
+		case propertyPayloadFormat, propertyRequestProblemInfo, propertyRequestResponseInfo, propertyMaximumQoS,
+			propertyRetainAvailable, propertyWildcardAvailable, propertySubIDAvailable, propertySharedSubAvailable:
+			_, err = pr.ReadByte()
+		case propertyServerKeepAlive, propertyReceiveMaximum, propertyTopicAliasMaximum, propertyTopicAlias:
+			_, err = readUint16(pr)
+		case propertyMessageExpiry, propertySessionExpiry, propertyWillDelay, propertyMaximumPacketSize:
+			_, err = pr.Seek(4, io.SeekCurrent)
+		case propertyResponseTopic, propertyCorrelationData, propertyAssignedClientID, propertyAuthMethod,
+			propertyAuthData, propertyResponseInfo, propertyServerReference, propertyReasonString:
+			_, err = readString(pr)
+		case propertySubscriptionIdentifier:
+			_, err = readVarint(pr)
+		default:
+			return nil, fmt.Errorf("unknown property %#x", id)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed property %#x: %w", id, err)
+		}
+	}
+	return props, nil
+}
+
+func writeVarint(buf *bytes.Buffer, v int) {
+	for {
+		b := byte(v % 128)
+		v /= 128
+		if v > 0 {
+			b |= 0x80
+		}
+		buf.WriteByte(b)
+		if v == 0 {
+			return
+		}
+	}
+}
+
+func readVarint(r io.ByteReader) (int, error) {
+	v, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("malformed variable byte integer: %w", err)
+		}
+		v += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("malformed variable byte integer")
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeUint16(buf, uint16(len(s)))
+	buf.WriteString(s)
+}
+
+func writeUint16(buf *bytes.Buffer, v uint16) {
+	_ = binary.Write(buf, binary.BigEndian, v)
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	length, err := readUint16(r)
+	if err != nil {
+		return "", err
+	}
+	if int(length) > r.Len() {
+		return "", fmt.Errorf("malformed string of %d bytes", length)
+	}
+	s := make([]byte, length)
+	_, err = io.ReadFull(r, s)
+	return string(s), err
+}
+
+func readUint16(r *bytes.Reader) (uint16, error) {
+	var v uint16
+	if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+		return 0, fmt.Errorf("malformed packet: %w", err)
+	}
+	return v, nil
+}
@@ -0,0 +1,89 @@
+package mqtt
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestPublishPacketRoundTrip(t *testing.T) {
+	cases := []struct {
+		name    string
+		publish *PublishPacket
+	}{
+		{
+			name:    "qos 0",
+			publish: &PublishPacket{Topic: "sources/s1/clusters/c1/sourceevents", Payload: []byte("{}")},
+		},
+		{
+			name: "qos 1 with a payload longer than 127 bytes",
+			publish: &PublishPacket{
+				Topic:    "sources/s1/clusters/c1/sourceevents",
+				QoS:      1,
+				PacketID: 42,
+				Payload:  bytes.Repeat([]byte("a"), 20000),
+			},
+		},
+		{
+			name: "content type and user properties",
+			publish: &PublishPacket{
+				Topic:       "sources/s1/clusters/c1/agentevents",
+				QoS:         1,
+				PacketID:    7,
+				ContentType: "application/json",
+				UserProperties: []UserProperty{
+					{Key: "ce-specversion", Value: "1.0"},
+					{Key: "ce-type", Value: "status.update_request"},
+				},
+				Payload: []byte("{}"),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := WritePacket(buf, c.publish.Packet()); err != nil {
+				t.Fatal(err)
+			}
+			packet, err := ReadPacket(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if packet.Type != PacketPublish {
+				t.Fatalf("expected a publish packet, but got %d", packet.Type)
+			}
+			publish, err := ParsePublish(packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(publish, c.publish) {
+				t.Errorf("expected %v, but got %v", c.publish, publish)
+			}
+		})
+	}
+}
+
+func TestTopicMatches(t *testing.T) {
+	cases := []struct {
+		filter   string
+		topic    string
+		expected bool
+	}{
+		{filter: "a/b/c", topic: "a/b/c", expected: true},
+		{filter: "a/b/c", topic: "a/b/d", expected: false},
+		{filter: "sources/s1/clusters/+/agentevents", topic: "sources/s1/clusters/c1/agentevents", expected: true},
+		{filter: "sources/s1/clusters/+/agentevents", topic: "sources/s2/clusters/c1/agentevents", expected: false},
+		{filter: "clusters/+/agentbroadcast", topic: "clusters/c1/c2/agentbroadcast", expected: false},
+		{filter: "sources/#", topic: "sources/s1/sourcebroadcast", expected: true},
+		{filter: "sources/#", topic: "sources", expected: true},
+		{filter: "#", topic: "a/b", expected: true},
+		{filter: "a/+", topic: "a", expected: false},
+	}
+
+	for _, c := range cases {
+		if actual := TopicMatches(c.filter, c.topic); actual != c.expected {
+			t.Errorf("expected %q matching %q to be %v, but got %v", c.filter, c.topic, c.expected, actual)
+		}
+	}
+}
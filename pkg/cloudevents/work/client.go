@@ -0,0 +1,256 @@
+package work
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage/names"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	workv1client "open-cluster-management.io/api/client/work/clientset/versioned"
+	workv1typed "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
+	workv1alpha1typed "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1alpha1"
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+// clientSet is the work client of the ManifestWorks delivered as cloudevents, only the ManifestWorks are
+// supported.
+type clientSet struct {
+	workV1 *workV1Client
+}
+
+var _ workv1client.Interface = &clientSet{}
+
+func (c *clientSet) Discovery() discovery.DiscoveryInterface {
+	return nil
+}
+
+func (c *clientSet) WorkV1() workv1typed.WorkV1Interface {
+	return c.workV1
+}
+
+func (c *clientSet) WorkV1alpha1() workv1alpha1typed.WorkV1alpha1Interface {
+	return nil
+}
+
+type workV1Client struct {
+	source *workSource
+}
+
+func (c *workV1Client) RESTClient() rest.Interface {
+	return nil
+}
+
+func (c *workV1Client) AppliedManifestWorks() workv1typed.AppliedManifestWorkInterface {
+	return nil
+}
+
+func (c *workV1Client) ManifestWorks(namespace string) workv1typed.ManifestWorkInterface {
+	return &manifestWorkClient{source: c.source, namespace: namespace}
+}
+
+// manifestWorkClient creates, updates and deletes the ManifestWorks by publishing their spec to the agents, and
+// lists and watches the ManifestWorks with the status reported by the agents from the store of the source.
+type manifestWorkClient struct {
+	source    *workSource
+	namespace string
+}
+
+var _ workv1typed.ManifestWorkInterface = &manifestWorkClient{}
+
+func (c *manifestWorkClient) Create(ctx context.Context, work *workv1.ManifestWork,
+	opts metav1.CreateOptions) (*workv1.ManifestWork, error) {
+	if len(opts.DryRun) > 0 {
+		return nil, apierrors.NewBadRequest("dry run is not supported")
+	}
+	if len(work.Namespace) > 0 && work.Namespace != c.namespace {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf(
+			"the namespace of the work %s does not match the namespace %s", work.Namespace, c.namespace))
+	}
+	if len(c.namespace) == 0 {
+		return nil, apierrors.NewBadRequest("the namespace of the work should be set")
+	}
+
+	created := work.DeepCopy()
+	created.Namespace = c.namespace
+	if len(created.Name) == 0 {
+		if len(created.GenerateName) == 0 {
+			return nil, apierrors.NewBadRequest("the name of the work should be set")
+		}
+		created.Name = names.SimpleNameGenerator.GenerateName(created.GenerateName)
+	}
+	created.UID = uuid.NewUUID()
+	created.ResourceVersion = ""
+	created.Generation = 1
+	created.CreationTimestamp = metav1.Now()
+	created.DeletionTimestamp = nil
+	created.Status = workv1.ManifestWorkStatus{}
+
+	c.source.writeLock.Lock()
+	defer c.source.writeLock.Unlock()
+	if _, err := c.source.store.get(created.Namespace, created.Name); err == nil {
+		return nil, apierrors.NewAlreadyExists(manifestWorkResource, created.Name)
+	}
+	if err := c.source.persister.save(ctx, created); err != nil {
+		return nil, err
+	}
+	if err := c.source.publish(ctx, SpecCreateRequestEventType, created); err != nil {
+		return nil, err
+	}
+	return c.source.store.add(created)
+}
+
+func (c *manifestWorkClient) Update(ctx context.Context, work *workv1.ManifestWork,
+	opts metav1.UpdateOptions) (*workv1.ManifestWork, error) {
+	if len(opts.DryRun) > 0 {
+		return nil, apierrors.NewBadRequest("dry run is not supported")
+	}
+	return c.update(ctx, work.Name, func(existing *workv1.ManifestWork) (*workv1.ManifestWork, error) {
+		return work, nil
+	})
+}
+
+// UpdateStatus is not supported, the status of the ManifestWorks is reported by the agents.
+func (c *manifestWorkClient) UpdateStatus(_ context.Context, _ *workv1.ManifestWork,
+	_ metav1.UpdateOptions) (*workv1.ManifestWork, error) {
+	return nil, apierrors.NewMethodNotSupported(manifestWorkResource, "updatestatus")
+}
+
+func (c *manifestWorkClient) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	if len(opts.DryRun) > 0 {
+		return apierrors.NewBadRequest("dry run is not supported")
+	}
+	return c.source.delete(ctx, c.namespace, name, opts.Preconditions)
+}
+
+// DeleteCollection is not supported, the ManifestWorks are deleted one by one.
+func (c *manifestWorkClient) DeleteCollection(_ context.Context, _ metav1.DeleteOptions, _ metav1.ListOptions) error {
+	return apierrors.NewMethodNotSupported(manifestWorkResource, "deletecollection")
+}
+
+func (c *manifestWorkClient) Get(_ context.Context, name string, _ metav1.GetOptions) (*workv1.ManifestWork, error) {
+	return c.source.store.get(c.namespace, name)
+}
+
+func (c *manifestWorkClient) List(_ context.Context, opts metav1.ListOptions) (*workv1.ManifestWorkList, error) {
+	return c.source.store.list(c.namespace, opts)
+}
+
+func (c *manifestWorkClient) Watch(_ context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	return c.source.store.watch(c.namespace, opts)
+}
+
+// Patch supports the merge and JSON patches of the ManifestWorks, the status can not be patched.
+func (c *manifestWorkClient) Patch(ctx context.Context, name string, pt types.PatchType, data []byte,
+	opts metav1.PatchOptions, subresources ...string) (*workv1.ManifestWork, error) {
+	if len(opts.DryRun) > 0 {
+		return nil, apierrors.NewBadRequest("dry run is not supported")
+	}
+	if len(subresources) > 0 {
+		return nil, apierrors.NewMethodNotSupported(manifestWorkResource, "patch "+subresources[0])
+	}
+
+	return c.update(ctx, name, func(existing *workv1.ManifestWork) (*workv1.ManifestWork, error) {
+		existingData, err := json.Marshal(existing)
+		if err != nil {
+			return nil, err
+		}
+
+		var patchedData []byte
+		switch pt {
+		case types.MergePatchType:
+			patchedData, err = jsonpatch.MergePatch(existingData, data)
+		case types.JSONPatchType:
+			var patch jsonpatch.Patch
+			patch, err = jsonpatch.DecodePatch(data)
+			if err == nil {
+				patchedData, err = patch.Apply(existingData)
+			}
+		default:
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("unsupported patch type %s", pt))
+		}
+		if err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("failed to patch the work %s: %v", name, err))
+		}
+
+		patched := &workv1.ManifestWork{}
+		if err := json.Unmarshal(patchedData, patched); err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("failed to patch the work %s: %v", name, err))
+		}
+		return patched, nil
+	})
+}
+
+// update updates the metadata and the spec of the ManifestWork of the name to the ManifestWork returned by the
+// updateFunc, and publishes the update to the agent if anything is changed.
+func (c *manifestWorkClient) update(ctx context.Context, name string,
+	updateFunc func(existing *workv1.ManifestWork) (*workv1.ManifestWork, error)) (*workv1.ManifestWork, error) {
+	c.source.writeLock.Lock()
+	defer c.source.writeLock.Unlock()
+	existing, err := c.source.store.get(c.namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	required, err := updateFunc(existing.DeepCopy())
+	if err != nil {
+		return nil, err
+	}
+	if len(required.ResourceVersion) > 0 && required.ResourceVersion != existing.ResourceVersion {
+		return nil, apierrors.NewConflict(manifestWorkResource, name, fmt.Errorf(
+			"the object has been modified; please apply your changes to the latest version and try again"))
+	}
+	if len(required.UID) > 0 && required.UID != existing.UID {
+		return nil, apierrors.NewConflict(manifestWorkResource, name, fmt.Errorf("the uid %s does not match", required.UID))
+	}
+
+	updated := existing.DeepCopy()
+	updated.Labels = required.Labels
+	updated.Annotations = required.Annotations
+	updated.OwnerReferences = required.OwnerReferences
+	updated.Finalizers = required.Finalizers
+	updated.Spec = required.Spec
+	if equality.Semantic.DeepEqual(updated, existing) {
+		return existing, nil
+	}
+	if !equality.Semantic.DeepEqual(updated.Spec, existing.Spec) {
+		updated.Generation++
+	}
+
+	if err := c.source.persister.save(ctx, updated); err != nil {
+		return nil, err
+	}
+	if err := c.source.publish(ctx, SpecUpdateRequestEventType, updated); err != nil {
+		return nil, err
+	}
+	return c.source.store.update(c.namespace, name, func(work *workv1.ManifestWork) bool {
+		// the status may be reported by the agent in the meantime, it is kept.
+		updated.Status = work.Status
+		updated.ResourceVersion = work.ResourceVersion
+		*work = *updated
+		return true
+	})
+}
+
+func checkPreconditions(work *workv1.ManifestWork, preconditions *metav1.Preconditions) error {
+	if preconditions == nil {
+		return nil
+	}
+	if preconditions.UID != nil && *preconditions.UID != work.UID {
+		return apierrors.NewConflict(manifestWorkResource, work.Name, fmt.Errorf(
+			"the uid in the precondition %s does not match the uid %s", *preconditions.UID, work.UID))
+	}
+	if preconditions.ResourceVersion != nil && *preconditions.ResourceVersion != work.ResourceVersion {
+		return apierrors.NewConflict(manifestWorkResource, work.Name, fmt.Errorf(
+			"the resource version in the precondition %s does not match the resource version %s",
+			*preconditions.ResourceVersion, work.ResourceVersion))
+	}
+	return nil
+}
@@ -0,0 +1,527 @@
+package work
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	workv1client "open-cluster-management.io/api/client/work/clientset/versioned"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/cloudevents/mqtt"
+	"open-cluster-management.io/addon-framework/pkg/cloudevents/mqtt/mqtttesting"
+)
+
+const testSourceID = "test-source"
+
+// fakeAgent is the agent of a managed cluster receiving the events of the source from the broker.
+type fakeAgent struct {
+	clusterName string
+	client      *mqtt.Client
+
+	lock   sync.Mutex
+	events []*CloudEvent
+}
+
+func newFakeAgent(t *testing.T, broker *mqtttesting.Broker, clusterName string) *fakeAgent {
+	agent := &fakeAgent{clusterName: clusterName}
+	client, err := mqtt.Connect(context.Background(), mqtt.ClientOptions{
+		BrokerHost: broker.Host(),
+		ClientID:   "agent-" + clusterName,
+	}, func(message *mqtt.PublishPacket) {
+		if message.ContentType != StructuredContentType {
+			t.Errorf("unexpected content type %q of topic %s", message.ContentType, message.Topic)
+		}
+		evt, err := DecodeCloudEvent(message)
+		if err != nil {
+			t.Errorf("invalid event of topic %s: %v", message.Topic, err)
+			return
+		}
+		agent.lock.Lock()
+		defer agent.lock.Unlock()
+		agent.events = append(agent.events, evt)
+	})
+	if err != nil {
+		t.Fatalf("failed to connect the agent: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	if err := client.Subscribe(context.Background(),
+		SourceEventsTopic(testSourceID, clusterName), SourceBroadcastTopic(testSourceID)); err != nil {
+		t.Fatalf("failed to subscribe the events of the source: %v", err)
+	}
+	agent.client = client
+	return agent
+}
+
+// waitForEvent waits for the first event of the type and returns its ManifestBundle, the event is removed from
+// the received events.
+func (a *fakeAgent) waitForEvent(t *testing.T, eventType string) (*CloudEvent, *ManifestBundle) {
+	var received *CloudEvent
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		for i, evt := range a.events {
+			if evt.Type == eventType {
+				received = evt
+				a.events = append(a.events[:i:i], a.events[i+1:]...)
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		t.Fatalf("the agent did not receive the event %s: %v", eventType, err)
+	}
+
+	if len(received.Data) == 0 {
+		return received, nil
+	}
+	bundle := &ManifestBundle{}
+	if err := json.Unmarshal(received.Data, bundle); err != nil {
+		t.Fatalf("invalid manifest bundle of the event: %v", err)
+	}
+	return received, bundle
+}
+
+// publish publishes the event with the data in the binary mode, as the agents built with the cloudevents SDK do.
+func (a *fakeAgent) publish(t *testing.T, topic, eventType, resourceID string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userProperties := []mqtt.UserProperty{
+		{Key: "specversion", Value: CloudEventsSpecVersion},
+		{Key: "id", Value: string(uuid.NewUUID())},
+		{Key: "source", Value: "agent-" + a.clusterName},
+		{Key: "type", Value: eventType},
+		{Key: "originalsource", Value: testSourceID},
+	}
+	if len(resourceID) > 0 {
+		userProperties = append(userProperties, mqtt.UserProperty{Key: "resourceid", Value: resourceID})
+	}
+	if err := a.client.Publish(context.Background(), &mqtt.PublishPacket{
+		Topic:          topic,
+		QoS:            1,
+		ContentType:    JSONContentType,
+		UserProperties: userProperties,
+		Payload:        payload,
+	}); err != nil {
+		t.Fatalf("failed to publish the event %s: %v", eventType, err)
+	}
+}
+
+func (a *fakeAgent) reportStatus(t *testing.T, resourceID string, conditions ...metav1.Condition) {
+	a.publish(t, AgentEventsTopic(testSourceID, a.clusterName), StatusUpdateRequestEventType, resourceID,
+		&ManifestBundleStatus{Conditions: conditions})
+}
+
+func newTestWorkClient(t *testing.T, broker *mqtttesting.Broker, kubeClient *fakekube.Clientset,
+	addonClient *fakeaddon.Clientset) workv1client.Interface {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	options := NewMQTTOptions()
+	options.BrokerHost = broker.Host()
+	options.SourceID = testSourceID
+	options.StateNamespace = "open-cluster-management-hub"
+	client, err := newMQTTWorkClient(ctx, kubeClient, addonClient, options)
+	if err != nil {
+		t.Fatalf("failed to create the work client: %v", err)
+	}
+	return client
+}
+
+func newTestWork(name string) *workv1.ManifestWork {
+	return &workv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"app": "test"},
+		},
+		Spec: workv1.ManifestWorkSpec{
+			Workload: workv1.ManifestsTemplate{
+				Manifests: []workv1.Manifest{
+					{RawExtension: runtime.RawExtension{
+						Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test","namespace":"default"}}`),
+					}},
+				},
+			},
+		},
+	}
+}
+
+func waitForWatchEvent(t *testing.T, w watch.Interface, eventType watch.EventType) *workv1.ManifestWork {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case evt, ok := <-w.ResultChan():
+			if !ok {
+				t.Fatalf("the watch is closed before the event %s", eventType)
+			}
+			if evt.Type == eventType {
+				return evt.Object.(*workv1.ManifestWork)
+			}
+		case <-timeout:
+			t.Fatalf("the watch event %s is not received", eventType)
+		}
+	}
+}
+
+func TestManifestWorkLifecycle(t *testing.T) {
+	broker := mqtttesting.NewBroker(t)
+	agent := newFakeAgent(t, broker, "cluster1")
+	kubeClient := fakekube.NewSimpleClientset()
+	client := newTestWorkClient(t, broker, kubeClient, fakeaddon.NewSimpleClientset())
+	works := client.WorkV1().ManifestWorks("cluster1")
+	ctx := context.Background()
+
+	created, err := works.Create(ctx, newTestWork("test"), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create the work: %v", err)
+	}
+	if len(created.UID) == 0 || created.Generation != 1 || len(created.ResourceVersion) == 0 {
+		t.Errorf("unexpected created work: %v", created.ObjectMeta)
+	}
+	if _, err := works.Create(ctx, newTestWork("test"), metav1.CreateOptions{}); !apierrors.IsAlreadyExists(err) {
+		t.Errorf("expected an already exists error, but got %v", err)
+	}
+	secrets, err := kubeClient.CoreV1().Secrets("open-cluster-management-hub").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets.Items) != 1 || secrets.Items[0].Labels[sourceLabelKey] != testSourceID {
+		t.Errorf("expected the work to be persisted, but got %v", secrets.Items)
+	}
+
+	evt, published := agent.waitForEvent(t, SpecCreateRequestEventType)
+	if evt.ResourceID != string(created.UID) || evt.ClusterName != "cluster1" || evt.ResourceVersion != 1 {
+		t.Errorf("unexpected extensions of the create request: %v", evt)
+	}
+	if len(published.Manifests) != 1 {
+		t.Errorf("unexpected manifests of the created work: %v", published)
+	}
+
+	list, err := works.List(ctx, metav1.ListOptions{LabelSelector: "app=test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("expected 1 work, but got %d", len(list.Items))
+	}
+	w, err := works.Watch(ctx, metav1.ListOptions{ResourceVersion: list.ResourceVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// the status reported by the agent is watched.
+	agent.reportStatus(t, evt.ResourceID, metav1.Condition{
+		Type: workv1.WorkApplied, Status: metav1.ConditionTrue, Reason: "Applied"})
+	modified := waitForWatchEvent(t, w, watch.Modified)
+	if !meta.IsStatusConditionTrue(modified.Status.Conditions, workv1.WorkApplied) {
+		t.Errorf("expected the applied status, but got %v", modified.Status)
+	}
+
+	// the status reported to the topic of another cluster is ignored.
+	other := newFakeAgent(t, broker, "cluster2")
+	other.reportStatus(t, evt.ResourceID, metav1.Condition{
+		Type: workv1.WorkDegraded, Status: metav1.ConditionTrue, Reason: "Degraded"})
+	// the broker routes the status of cluster2 to the source before it acks, so the source has handled it once the
+	// next status of cluster1 is watched.
+	agent.reportStatus(t, evt.ResourceID,
+		metav1.Condition{Type: workv1.WorkApplied, Status: metav1.ConditionTrue, Reason: "Applied"},
+		metav1.Condition{Type: workv1.WorkAvailable, Status: metav1.ConditionTrue, Reason: "Available"})
+	modified = waitForWatchEvent(t, w, watch.Modified)
+	if meta.FindStatusCondition(modified.Status.Conditions, workv1.WorkDegraded) != nil {
+		t.Errorf("expected the status of cluster2 to be ignored, but got %v", modified.Status)
+	}
+
+	// a patch of the spec bumps the generation and is published as an update.
+	patched, err := works.Patch(ctx, "test", types.MergePatchType,
+		[]byte(`{"metadata":{"labels":{"app":"test","patched":"true"}},"spec":{"deleteOption":{"propagationPolicy":"Orphan"}}}`),
+		metav1.PatchOptions{})
+	if err != nil {
+		t.Fatalf("failed to patch the work: %v", err)
+	}
+	if patched.Generation != 2 || patched.Labels["patched"] != "true" {
+		t.Errorf("unexpected patched work: %v", patched.ObjectMeta)
+	}
+	if !meta.IsStatusConditionTrue(patched.Status.Conditions, workv1.WorkAvailable) {
+		t.Errorf("unexpected status of the patched work: %v", patched.Status)
+	}
+	evt, published = agent.waitForEvent(t, SpecUpdateRequestEventType)
+	if evt.ResourceVersion != 2 || published.DeleteOption == nil {
+		t.Errorf("expected the generation 2 of the update request with the delete option, but got %v", evt)
+	}
+
+	// an update with a stale resource version conflicts.
+	if _, err := works.Update(ctx, created, metav1.UpdateOptions{}); !apierrors.IsConflict(err) {
+		t.Errorf("expected a conflict error, but got %v", err)
+	}
+	if _, err := works.UpdateStatus(ctx, patched, metav1.UpdateOptions{}); !apierrors.IsMethodNotSupported(err) {
+		t.Errorf("expected a method not supported error, but got %v", err)
+	}
+
+	// the work is removed once the agent reports it is deleted.
+	if err := works.Delete(ctx, "test", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete the work: %v", err)
+	}
+	deleting, err := works.Get(ctx, "test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleting.DeletionTimestamp.IsZero() {
+		t.Errorf("expected the deletion timestamp of the deleting work")
+	}
+	evt, _ = agent.waitForEvent(t, SpecDeleteRequestEventType)
+	if len(evt.DeletionTimestamp) == 0 || evt.ResourceID != string(created.UID) {
+		t.Errorf("unexpected delete request %v", evt)
+	}
+	agent.reportStatus(t, evt.ResourceID, metav1.Condition{
+		Type: WorkDeleted, Status: metav1.ConditionTrue, Reason: "Deleted"})
+	waitForWatchEvent(t, w, watch.Deleted)
+	if _, err := works.Get(ctx, "test", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected a not found error, but got %v", err)
+	}
+	secrets, err = kubeClient.CoreV1().Secrets("open-cluster-management-hub").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets.Items) != 0 {
+		t.Errorf("expected the persisted work to be removed, but got %v", secrets.Items)
+	}
+}
+
+func TestManifestWorkResync(t *testing.T) {
+	broker := mqtttesting.NewBroker(t)
+	agent := newFakeAgent(t, broker, "cluster1")
+	client := newTestWorkClient(t, broker, fakekube.NewSimpleClientset(), fakeaddon.NewSimpleClientset())
+	works := client.WorkV1().ManifestWorks("cluster1")
+	ctx := context.Background()
+
+	// the source asks the agents to report the status once it is connected.
+	agent.waitForEvent(t, StatusResyncRequestEventType)
+
+	var test1 *workv1.ManifestWork
+	for _, name := range []string{"test1", "test2", "test3"} {
+		work, err := works.Create(ctx, newTestWork(name), metav1.CreateOptions{})
+		if err != nil {
+			t.Fatalf("failed to create the work: %v", err)
+		}
+		if name == "test1" {
+			test1 = work
+		}
+		agent.waitForEvent(t, SpecCreateRequestEventType)
+	}
+	if err := works.Delete(ctx, "test2", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete the work: %v", err)
+	}
+	evt, _ := agent.waitForEvent(t, SpecDeleteRequestEventType)
+	test2UID := evt.ResourceID
+
+	// the agent has test1 of the current generation, an orphan work and a stale test2, the changed works are
+	// published again on the resync request of the agent, and the orphan work is deleted.
+	agent.publish(t, AgentBroadcastTopic("cluster1"), SpecResyncRequestEventType, "", &ResourceVersionList{
+		ResourceVersions: []ResourceVersion{
+			{ResourceID: string(test1.UID), ResourceVersion: 1},
+			{ResourceID: test2UID, ResourceVersion: 1},
+			{ResourceID: "orphan", ResourceVersion: 3},
+		},
+	})
+	_, resynced := agent.waitForEvent(t, SpecCreateRequestEventType)
+	if len(resynced.Manifests) != 1 {
+		t.Errorf("expected the work test3 to be resynced, but got %v", resynced)
+	}
+	deletes := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		evt, _ := agent.waitForEvent(t, SpecDeleteRequestEventType)
+		deletes[evt.ResourceID] = true
+	}
+	if !deletes[test2UID] || !deletes["orphan"] {
+		t.Errorf("expected the deleting work test2 and the orphan work to be deleted, but got %v", deletes)
+	}
+
+	// the status of a work which is not found is not dropped silently, the agent is asked to delete it.
+	agent.reportStatus(t, "unknown", metav1.Condition{
+		Type: workv1.WorkApplied, Status: metav1.ConditionTrue, Reason: "Applied"})
+	evt, _ = agent.waitForEvent(t, SpecDeleteRequestEventType)
+	if evt.ResourceID != "unknown" || evt.ClusterName != "cluster1" {
+		t.Errorf("expected the unknown work to be deleted, but got %v", evt)
+	}
+
+	// the source reconnects once the connection is lost, asks the agents to report the status again and
+	// publishes the pending deletions again.
+	broker.DisconnectAll()
+	agent = newFakeAgent(t, broker, "cluster1")
+	agent.waitForEvent(t, StatusResyncRequestEventType)
+	evt, _ = agent.waitForEvent(t, SpecDeleteRequestEventType)
+	if evt.ResourceID != test2UID {
+		t.Errorf("expected the deletion of test2 to be published again, but got %v", evt)
+	}
+	if _, err := works.Patch(ctx, "test1", types.MergePatchType,
+		[]byte(`{"spec":{"deleteOption":{"propagationPolicy":"Orphan"}}}`), metav1.PatchOptions{}); err != nil {
+		t.Fatalf("failed to patch the work after reconnecting: %v", err)
+	}
+	agent.waitForEvent(t, SpecUpdateRequestEventType)
+}
+
+func TestManifestWorkRestore(t *testing.T) {
+	broker := mqtttesting.NewBroker(t)
+	agent := newFakeAgent(t, broker, "cluster1")
+	kubeClient := fakekube.NewSimpleClientset()
+	ctx := context.Background()
+
+	// the works are created and deleted by the source before it restarts
+	client := newTestWorkClient(t, broker, kubeClient, fakeaddon.NewSimpleClientset())
+	agent.waitForEvent(t, StatusResyncRequestEventType)
+	created, err := client.WorkV1().ManifestWorks("cluster1").Create(ctx, newTestWork("test1"), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.WorkV1().ManifestWorks("cluster1").Create(ctx, newTestWork("test2"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.WorkV1().ManifestWorks("cluster1").Delete(ctx, "test2", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	agent.waitForEvent(t, SpecDeleteRequestEventType)
+
+	// the restarted source restores the works, and publishes the pending deletion again
+	restarted := newTestWorkClient(t, broker, kubeClient, fakeaddon.NewSimpleClientset())
+	works := restarted.WorkV1().ManifestWorks("cluster1")
+	restored, err := works.Get(ctx, "test1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the work to be restored: %v", err)
+	}
+	if restored.UID != created.UID || restored.Generation != created.Generation || restored.Labels["app"] != "test" {
+		t.Errorf("expected the restored work %v, but got %v", created.ObjectMeta, restored.ObjectMeta)
+	}
+	deleting, err := works.Get(ctx, "test2", metav1.GetOptions{})
+	if err != nil || deleting.DeletionTimestamp.IsZero() {
+		t.Errorf("expected the deleting work to be restored, but got %v, %v", deleting, err)
+	}
+	evt, _ := agent.waitForEvent(t, StatusResyncRequestEventType)
+	hashes := &StatusHashList{}
+	if err := json.Unmarshal(evt.Data, hashes); err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes.StatusHashes) != 2 {
+		t.Errorf("expected the status hashes of the restored works, but got %v", hashes)
+	}
+	evt, _ = agent.waitForEvent(t, SpecDeleteRequestEventType)
+	if evt.ResourceID != string(deleting.UID) {
+		t.Errorf("expected the deletion of test2 to be published again, but got %v", evt)
+	}
+
+	// the status reported by the agent after the restart is kept
+	w, err := works.Watch(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	agent.reportStatus(t, string(created.UID), metav1.Condition{
+		Type: workv1.WorkApplied, Status: metav1.ConditionTrue, Reason: "Applied"})
+	modified := waitForWatchEvent(t, w, watch.Modified)
+	if modified.Name != "test1" || !meta.IsStatusConditionTrue(modified.Status.Conditions, workv1.WorkApplied) {
+		t.Errorf("expected the status of test1, but got %v", modified)
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	broker := mqtttesting.NewBroker(t)
+	agent := newFakeAgent(t, broker, "cluster1")
+	addon := addontesting.NewAddon("test", "cluster1")
+	addon.UID = "addon-uid"
+	addonClient := fakeaddon.NewSimpleClientset(addon)
+	client := newTestWorkClient(t, broker, fakekube.NewSimpleClientset(), addonClient)
+	works := client.WorkV1().ManifestWorks("cluster1")
+	ctx := context.Background()
+
+	ownedBy := func(name string, uid types.UID) *workv1.ManifestWork {
+		work := newTestWork(name)
+		work.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: addonapiv1alpha1.GroupVersion.String(), Kind: "ManagedClusterAddOn", Name: "test", UID: uid,
+		}}
+		return work
+	}
+	for _, work := range []*workv1.ManifestWork{
+		ownedBy("owned", addon.UID), ownedBy("orphan", "deleted-uid"), newTestWork("not-owned"),
+	} {
+		if _, err := works.Create(ctx, work, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source := client.(*clientSet).workV1.source
+	if err := source.collectGarbage(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for name, expectedDeleting := range map[string]bool{"owned": false, "orphan": true, "not-owned": false} {
+		work, err := works.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleting := !work.DeletionTimestamp.IsZero(); deleting != expectedDeleting {
+			t.Errorf("expected the work %s deleting %v, but got %v", name, expectedDeleting, deleting)
+		}
+	}
+	agent.waitForEvent(t, SpecDeleteRequestEventType)
+}
+
+func TestMQTTOptionsValidate(t *testing.T) {
+	cases := []struct {
+		name        string
+		options     func(o *MQTTOptions)
+		expectedErr bool
+	}{
+		{
+			name:    "valid",
+			options: func(o *MQTTOptions) {},
+		},
+		{
+			name:        "no broker host",
+			options:     func(o *MQTTOptions) { o.BrokerHost = "" },
+			expectedErr: true,
+		},
+		{
+			name:        "source id with a wildcard",
+			options:     func(o *MQTTOptions) { o.SourceID = "source+" },
+			expectedErr: true,
+		},
+		{
+			name:        "client cert without key",
+			options:     func(o *MQTTOptions) { o.CAFile = "ca.crt"; o.ClientCertFile = "tls.crt" },
+			expectedErr: true,
+		},
+		{
+			name:        "client cert without CA",
+			options:     func(o *MQTTOptions) { o.ClientCertFile = "tls.crt"; o.ClientKeyFile = "tls.key" },
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			options := NewMQTTOptions()
+			options.BrokerHost = "127.0.0.1:1883"
+			c.options(options)
+			err := options.Validate()
+			if c.expectedErr != (err != nil) {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package work
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/cloudevents/mqtt"
+)
+
+// CloudEventsSpecVersion is the version of the CloudEvents spec of the events.
+const CloudEventsSpecVersion = "1.0"
+
+// The content types of the MQTT messages of the events.
+const (
+	// StructuredContentType is the content type of the events in the structured JSON mode of the CloudEvents spec.
+	StructuredContentType = "application/cloudevents+json"
+	// JSONContentType is the content type of the data of the events.
+	JSONContentType = "application/json"
+)
+
+// ManifestBundleEventDataType is the data type of the events of the ManifestWorks in the format of the OCM work
+// agents, the type of an event is the data type followed by the subresource and the action of the event.
+const ManifestBundleEventDataType = "io.open-cluster-management.works.v1alpha1.manifestbundles"
+
+// The types of the events of the ManifestWorks.
+const (
+	// SpecCreateRequestEventType is published by the source to create the ManifestBundle of the event data on the
+	// managed cluster.
+	SpecCreateRequestEventType = ManifestBundleEventDataType + ".spec.create_request"
+	// SpecUpdateRequestEventType is published by the source to update the ManifestBundle of the event data on the
+	// managed cluster.
+	SpecUpdateRequestEventType = ManifestBundleEventDataType + ".spec.update_request"
+	// SpecDeleteRequestEventType is published by the source to delete the ManifestBundle of the resource id from
+	// the managed cluster, the agent reports the Deleted condition once the manifests are removed.
+	SpecDeleteRequestEventType = ManifestBundleEventDataType + ".spec.delete_request"
+	// SpecResyncRequestEventType is published by the agent with the resource versions of its ManifestBundles to ask
+	// the sources to publish the ManifestBundles which are changed or deleted, e.g. after the agent restarts.
+	SpecResyncRequestEventType = ManifestBundleEventDataType + ".spec.resync_request"
+	// StatusUpdateRequestEventType is published by the agent to report the status of the ManifestBundle of the
+	// resource id.
+	StatusUpdateRequestEventType = ManifestBundleEventDataType + ".status.update_request"
+	// StatusResyncRequestEventType is published by the source with the status hashes of its ManifestBundles to ask
+	// the agents to report the status which is changed, e.g. after the source restarts or reconnects.
+	StatusResyncRequestEventType = ManifestBundleEventDataType + ".status.resync_request"
+)
+
+// WorkDeleted is the condition type of the ManifestBundle the agent reports once the manifests of the
+// ManifestBundle are removed from the managed cluster, the source removes the ManifestWork then.
+const WorkDeleted = "Deleted"
+
+// ManifestBundle is the data of the spec events, it is the spec of the ManifestWork the agent applies.
+type ManifestBundle struct {
+	Manifests       []workv1.Manifest             `json:"manifests"`
+	DeleteOption    *workv1.DeleteOption          `json:"deleteOption,omitempty"`
+	ManifestConfigs []workv1.ManifestConfigOption `json:"manifestConfigs,omitempty"`
+}
+
+// ManifestBundleStatus is the data of the status events, it is the status of the ManifestWork reported by the
+// agent.
+type ManifestBundleStatus struct {
+	Conditions     []metav1.Condition         `json:"conditions"`
+	ResourceStatus []workv1.ManifestCondition `json:"resourceStatus"`
+}
+
+// ResourceVersionList is the data of the spec resync requests, it has the resource versions of the
+// ManifestBundles on the managed cluster.
+type ResourceVersionList struct {
+	ResourceVersions []ResourceVersion `json:"resourceVersions"`
+}
+
+// ResourceVersion is the resource version of a ManifestBundle on the managed cluster.
+type ResourceVersion struct {
+	ResourceID      string `json:"resourceID"`
+	ResourceVersion int64  `json:"resourceVersion"`
+}
+
+// StatusHashList is the data of the status resync requests, it has the hashes of the status of the ManifestWorks
+// the source has, the agents only report the status whose hash is different.
+type StatusHashList struct {
+	StatusHashes []ResourceStatusHash `json:"statusHashes"`
+}
+
+// ResourceStatusHash is the hash of the status of a ManifestWork.
+type ResourceStatusHash struct {
+	ResourceID string `json:"resourceID"`
+	StatusHash string `json:"statusHash"`
+}
+
+// The names of the extension attributes of the events.
+const (
+	extensionResourceID        = "resourceid"
+	extensionResourceVersion   = "resourceversion"
+	extensionClusterName       = "clustername"
+	extensionDeletionTimestamp = "deletiontimestamp"
+	extensionOriginalSource    = "originalsource"
+)
+
+// CloudEvent is a cloudevent of a ManifestWork, it is published in the structured JSON mode of the CloudEvents
+// spec and is decoded from both the structured and the binary mode.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+
+	// ResourceID is the UID of the ManifestWork.
+	ResourceID string `json:"resourceid,omitempty"`
+	// ResourceVersion is the generation of the spec of the ManifestWork, the status reported by the agent is of
+	// the spec of the generation.
+	ResourceVersion int64 `json:"resourceversion,omitempty"`
+	// ClusterName is the name of the managed cluster of the ManifestWork.
+	ClusterName string `json:"clustername,omitempty"`
+	// DeletionTimestamp is set on the delete requests, in RFC3339.
+	DeletionTimestamp string `json:"deletiontimestamp,omitempty"`
+	// OriginalSource is the id of the source the agent reports the status to.
+	OriginalSource string `json:"originalsource,omitempty"`
+}
+
+// NewCloudEvent returns an event of the type from the source with the data.
+func NewCloudEvent(source, eventType string, data interface{}) (*CloudEvent, error) {
+	evt := &CloudEvent{
+		SpecVersion: CloudEventsSpecVersion,
+		ID:          string(uuid.NewUUID()),
+		Source:      source,
+		Type:        eventType,
+		Time:        time.Now().UTC().Format(time.RFC3339),
+	}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		evt.DataContentType = JSONContentType
+		evt.Data = raw
+	}
+	return evt, nil
+}
+
+// DecodeCloudEvent decodes the event of a MQTT message. The message is in the binary mode if its attributes are
+// in the user properties, otherwise it is in the structured JSON mode.
+func DecodeCloudEvent(message *mqtt.PublishPacket) (*CloudEvent, error) {
+	evt := &CloudEvent{}
+	if attributes := binaryAttributes(message); len(attributes) > 0 {
+		evt.SpecVersion = attributes["specversion"]
+		evt.ID = attributes["id"]
+		evt.Source = attributes["source"]
+		evt.Type = attributes["type"]
+		evt.Time = attributes["time"]
+		evt.DataContentType = message.ContentType
+		if len(evt.DataContentType) == 0 {
+			evt.DataContentType = attributes["datacontenttype"]
+		}
+		evt.ResourceID = attributes[extensionResourceID]
+		evt.ClusterName = attributes[extensionClusterName]
+		evt.DeletionTimestamp = attributes[extensionDeletionTimestamp]
+		evt.OriginalSource = attributes[extensionOriginalSource]
+		if value, ok := attributes[extensionResourceVersion]; ok {
+			resourceVersion, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid resource version %q of the cloudevent: %w", value, err)
+			}
+			evt.ResourceVersion = resourceVersion
+		}
+		if len(message.Payload) > 0 {
+			evt.Data = message.Payload
+		}
+	} else if err := json.Unmarshal(message.Payload, evt); err != nil {
+		return nil, fmt.Errorf("invalid cloudevent: %w", err)
+	}
+
+	if evt.SpecVersion != CloudEventsSpecVersion {
+		return nil, fmt.Errorf("unsupported cloudevents spec version %q", evt.SpecVersion)
+	}
+	if len(evt.ID) == 0 || len(evt.Source) == 0 || len(evt.Type) == 0 {
+		return nil, fmt.Errorf("the id, source and type of the cloudevent should be set")
+	}
+	return evt, nil
+}
+
+// binaryAttributes returns the attributes of the event in the user properties of the message, the names of the
+// attributes may have the "ce-" prefix. It is empty if the message is not in the binary mode.
+func binaryAttributes(message *mqtt.PublishPacket) map[string]string {
+	if message.ContentType == StructuredContentType {
+		return nil
+	}
+	attributes := map[string]string{}
+	for _, property := range message.UserProperties {
+		attributes[strings.TrimPrefix(strings.ToLower(property.Key), "ce-")] = property.Value
+	}
+	if _, ok := attributes["specversion"]; !ok {
+		return nil
+	}
+	return attributes
+}
+
+// Message returns the MQTT message of the event in the structured JSON mode to the topic.
+func (e *CloudEvent) Message(topic string, qos byte) (*mqtt.PublishPacket, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return &mqtt.PublishPacket{
+		Topic:       topic,
+		QoS:         qos,
+		ContentType: StructuredContentType,
+		// the content type is also set in the user properties for the receivers reading the mode from them.
+		UserProperties: []mqtt.UserProperty{{Key: "Content-Type", Value: StructuredContentType}},
+		Payload:        payload,
+	}, nil
+}
+
+// SourceEventsTopic is the MQTT topic the source publishes the events of the ManifestWorks of the cluster to.
+func SourceEventsTopic(sourceID, clusterName string) string {
+	return fmt.Sprintf("sources/%s/clusters/%s/sourceevents", sourceID, clusterName)
+}
+
+// AgentEventsTopic is the MQTT topic the agent of the cluster publishes the status of the ManifestWorks of the
+// source to.
+func AgentEventsTopic(sourceID, clusterName string) string {
+	return fmt.Sprintf("sources/%s/clusters/%s/agentevents", sourceID, clusterName)
+}
+
+// SourceBroadcastTopic is the MQTT topic the source publishes the events to all the agents to.
+func SourceBroadcastTopic(sourceID string) string {
+	return fmt.Sprintf("sources/%s/sourcebroadcast", sourceID)
+}
+
+// AgentBroadcastTopic is the MQTT topic the agent of the cluster publishes the events to all the sources to.
+func AgentBroadcastTopic(clusterName string) string {
+	return fmt.Sprintf("clusters/%s/agentbroadcast", clusterName)
+}
@@ -0,0 +1,218 @@
+package work
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	workv1client "open-cluster-management.io/api/client/work/clientset/versioned"
+
+	"open-cluster-management.io/addon-framework/pkg/cloudevents/mqtt"
+)
+
+const (
+	defaultStateNamespace = "open-cluster-management-hub"
+
+	// garbageCollectionInterval is the interval to delete the ManifestWorks whose owners are deleted.
+	garbageCollectionInterval = 5 * time.Minute
+)
+
+// MQTTOptions are the options to deliver the ManifestWorks as cloudevents over a MQTT broker.
+type MQTTOptions struct {
+	// BrokerHost is the host:port of the MQTT broker.
+	BrokerHost string
+	// Username and PasswordFile authenticate the source to the broker if they are set.
+	Username     string
+	PasswordFile string
+	// CAFile is the CA bundle to verify the broker with, the broker is connected over TLS if it is set.
+	CAFile string
+	// ClientCertFile and ClientKeyFile authenticate the source to the broker over TLS if they are set.
+	ClientCertFile string
+	ClientKeyFile  string
+	// SourceID identifies the source to the agents, the agents report the status of the ManifestWorks to the
+	// topics of the source.
+	SourceID string
+	// KeepAlive is the interval the source pings the broker in.
+	KeepAlive time.Duration
+	// DialTimeout is the timeout to connect to the broker.
+	DialTimeout time.Duration
+	// StateNamespace is the namespace on the hub the ManifestWorks of the source are persisted in.
+	StateNamespace string
+}
+
+// NewMQTTOptions returns the MQTT options with default values set
+func NewMQTTOptions() *MQTTOptions {
+	return &MQTTOptions{
+		SourceID:       "addon-manager",
+		KeepAlive:      60 * time.Second,
+		DialTimeout:    10 * time.Second,
+		StateNamespace: podNamespace(),
+	}
+}
+
+// AddFlags registers and binds the MQTT flags
+func (o *MQTTOptions) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.BrokerHost, "mqtt-broker-host", o.BrokerHost, "The host:port of the MQTT broker.")
+	flags.StringVar(&o.Username, "mqtt-username", o.Username, "The username to connect to the MQTT broker.")
+	flags.StringVar(&o.PasswordFile, "mqtt-password-file", o.PasswordFile,
+		"The file containing the password to connect to the MQTT broker.")
+	flags.StringVar(&o.CAFile, "mqtt-ca-file", o.CAFile,
+		"The CA bundle to verify the MQTT broker with, the broker is connected over TLS if it is set.")
+	flags.StringVar(&o.ClientCertFile, "mqtt-client-cert-file", o.ClientCertFile,
+		"The client certificate to connect to the MQTT broker over TLS.")
+	flags.StringVar(&o.ClientKeyFile, "mqtt-client-key-file", o.ClientKeyFile,
+		"The client key to connect to the MQTT broker over TLS.")
+	flags.StringVar(&o.SourceID, "mqtt-source-id", o.SourceID,
+		"The source id of the ManifestWorks published to the MQTT broker.")
+	flags.DurationVar(&o.KeepAlive, "mqtt-keep-alive", o.KeepAlive, "The keep alive interval of the MQTT connection.")
+	flags.DurationVar(&o.DialTimeout, "mqtt-dial-timeout", o.DialTimeout, "The timeout to connect to the MQTT broker.")
+	flags.StringVar(&o.StateNamespace, "mqtt-state-namespace", o.StateNamespace,
+		"The namespace on the hub the ManifestWorks published to the MQTT broker are persisted in.")
+}
+
+// podNamespace returns the namespace of the pod, the ManifestWorks are persisted in it by default.
+func podNamespace() string {
+	data, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err != nil {
+		return defaultStateNamespace
+	}
+	if namespace := strings.TrimSpace(string(data)); len(namespace) > 0 {
+		return namespace
+	}
+	return defaultStateNamespace
+}
+
+// Validate validates the MQTT options.
+func (o *MQTTOptions) Validate() error {
+	if len(o.BrokerHost) == 0 {
+		return fmt.Errorf("the MQTT broker host should be set")
+	}
+	if len(o.SourceID) == 0 {
+		return fmt.Errorf("the source id should be set")
+	}
+	// the source id is a level of the topics and the label value of the persisted ManifestWorks, so it can not
+	// contain the separator or the wildcards of the topics.
+	if errs := validation.IsValidLabelValue(o.SourceID); len(errs) > 0 {
+		return fmt.Errorf("invalid source id %q: %s", o.SourceID, strings.Join(errs, ", "))
+	}
+	if len(o.StateNamespace) == 0 {
+		return fmt.Errorf("the state namespace should be set")
+	}
+	if (len(o.ClientCertFile) == 0) != (len(o.ClientKeyFile) == 0) {
+		return fmt.Errorf("the client cert file and the client key file should be set together")
+	}
+	if len(o.ClientCertFile) > 0 && len(o.CAFile) == 0 {
+		return fmt.Errorf("the CA file should be set to connect to the MQTT broker with the client certificate")
+	}
+	return nil
+}
+
+func (o *MQTTOptions) clientOptions() (mqtt.ClientOptions, error) {
+	clientOptions := mqtt.ClientOptions{
+		BrokerHost: o.BrokerHost,
+		// the broker disconnects the existing client of the same id, so each replica of the source connects with
+		// its own id.
+		ClientID:    fmt.Sprintf("%s-%s", o.SourceID, utilrand.String(5)),
+		Username:    o.Username,
+		KeepAlive:   o.KeepAlive,
+		DialTimeout: o.DialTimeout,
+	}
+
+	if len(o.PasswordFile) > 0 {
+		password, err := os.ReadFile(o.PasswordFile)
+		if err != nil {
+			return clientOptions, fmt.Errorf("failed to read the MQTT password file: %w", err)
+		}
+		clientOptions.Password = strings.TrimSpace(string(password))
+	}
+
+	if len(o.CAFile) > 0 {
+		caData, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return clientOptions, fmt.Errorf("failed to read the MQTT CA file: %w", err)
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caData) {
+			return clientOptions, fmt.Errorf("no certificate is found in the MQTT CA file %s", o.CAFile)
+		}
+		clientOptions.TLSConfig = &tls.Config{
+			RootCAs:    certPool,
+			MinVersion: tls.VersionTLS12,
+		}
+		if len(o.ClientCertFile) > 0 {
+			cert, err := tls.LoadX509KeyPair(o.ClientCertFile, o.ClientKeyFile)
+			if err != nil {
+				return clientOptions, fmt.Errorf("failed to load the MQTT client certificate: %w", err)
+			}
+			clientOptions.TLSConfig.Certificates = []tls.Certificate{cert}
+		}
+	}
+	return clientOptions, nil
+}
+
+// NewMQTTWorkClient connects to the MQTT broker and returns the work client publishing the ManifestWorks to the
+// agents over the broker. Only the ManifestWorks of the client are supported, they are persisted in the state
+// namespace on the hub of the config, and are listed and watched with the status reported by the agents. The
+// ManifestWorks whose owner ManagedClusterAddOns are deleted are deleted by the client. The client reconnects to
+// the broker once the connection is lost, and is stopped when the context is done.
+func NewMQTTWorkClient(ctx context.Context, config *rest.Config, options *MQTTOptions) (workv1client.Interface, error) {
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	addonClient, err := addonv1alpha1client.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return newMQTTWorkClient(ctx, kubeClient, addonClient, options)
+}
+
+func newMQTTWorkClient(ctx context.Context, kubeClient kubernetes.Interface, addonClient addonv1alpha1client.Interface,
+	options *MQTTOptions) (workv1client.Interface, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	clientOptions, err := options.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	source := &workSource{
+		sourceID:      options.SourceID,
+		clientOptions: clientOptions,
+		store:         newManifestWorkStore(),
+		persister: &manifestWorkPersister{
+			kubeClient: kubeClient,
+			namespace:  options.StateNamespace,
+			sourceID:   options.SourceID,
+		},
+		addonClient: addonClient,
+		ctx:         ctx,
+	}
+	if err := source.restore(ctx); err != nil {
+		return nil, err
+	}
+	if err := source.connect(ctx); err != nil {
+		return nil, fmt.Errorf("failed to connect to the MQTT broker %s: %w", options.BrokerHost, err)
+	}
+	go source.run(ctx)
+	go wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := source.collectGarbage(ctx); err != nil {
+			klog.Errorf("Failed to delete the works whose owners are deleted: %v", err)
+		}
+	}, garbageCollectionInterval)
+
+	return &clientSet{workV1: &workV1Client{source: source}}, nil
+}
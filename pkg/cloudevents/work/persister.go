@@ -0,0 +1,105 @@
+package work
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+const (
+	// sourceLabelKey is the label of the Secrets persisting the ManifestWorks of the source of its value.
+	sourceLabelKey = "work.addon.open-cluster-management.io/source"
+	// workDataKey is the data key of the Secrets persisting the ManifestWork.
+	workDataKey = "manifestwork"
+)
+
+// manifestWorkPersister persists the ManifestWorks of the source in the Secrets of a namespace on the hub, so the
+// store is rebuilt with the ManifestWorks, their uids and generations after the source restarts. The status is
+// not persisted, it is reported by the agents on the status resync.
+type manifestWorkPersister struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	sourceID   string
+}
+
+// secretName returns the name of the Secret of the ManifestWork, it is unique per source, cluster and name.
+func (p *manifestWorkPersister) secretName(namespace, name string) string {
+	return fmt.Sprintf("manifestwork-%x", sha256.Sum256([]byte(p.sourceID+"/"+namespace+"/"+name)))[:48]
+}
+
+// save persists the metadata and the spec of the ManifestWork.
+func (p *manifestWorkPersister) save(ctx context.Context, work *workv1.ManifestWork) error {
+	persisted := &workv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              work.Name,
+			Namespace:         work.Namespace,
+			UID:               work.UID,
+			Generation:        work.Generation,
+			Labels:            work.Labels,
+			Annotations:       work.Annotations,
+			OwnerReferences:   work.OwnerReferences,
+			Finalizers:        work.Finalizers,
+			CreationTimestamp: work.CreationTimestamp,
+			DeletionTimestamp: work.DeletionTimestamp,
+		},
+		Spec: work.Spec,
+	}
+	data, err := json.Marshal(persisted)
+	if err != nil {
+		return err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      p.secretName(work.Namespace, work.Name),
+			Namespace: p.namespace,
+			Labels: map[string]string{
+				sourceLabelKey: p.sourceID,
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{workDataKey: data},
+	}
+	if _, _, err := utils.ApplySecret(ctx, p.kubeClient.CoreV1(), secret); err != nil {
+		return fmt.Errorf("failed to persist the work %s/%s: %w", work.Namespace, work.Name, err)
+	}
+	return nil
+}
+
+// remove removes the persisted ManifestWork.
+func (p *manifestWorkPersister) remove(ctx context.Context, namespace, name string) error {
+	err := p.kubeClient.CoreV1().Secrets(p.namespace).Delete(ctx, p.secretName(namespace, name), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to remove the persisted work %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// load returns the persisted ManifestWorks of the source.
+func (p *manifestWorkPersister) load(ctx context.Context) ([]*workv1.ManifestWork, error) {
+	secrets, err := p.kubeClient.CoreV1().Secrets(p.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", sourceLabelKey, p.sourceID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the persisted works: %w", err)
+	}
+
+	var works []*workv1.ManifestWork
+	for _, secret := range secrets.Items {
+		work := &workv1.ManifestWork{}
+		if err := json.Unmarshal(secret.Data[workDataKey], work); err != nil {
+			return nil, fmt.Errorf("invalid persisted work of secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+		works = append(works, work)
+	}
+	return works, nil
+}
@@ -0,0 +1,436 @@
+package work
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/cloudevents/mqtt"
+)
+
+// publishQoS is the QoS the events are published with, the events are delivered at least once.
+const publishQoS = 1
+
+// workSource is the source of the ManifestWorks, it publishes the spec of the ManifestWorks to the agents of the
+// managed clusters over the MQTT broker and keeps the status reported by the agents in the store. The
+// ManifestWorks are persisted on the hub, so the store is rebuilt after the source restarts.
+type workSource struct {
+	sourceID      string
+	clientOptions mqtt.ClientOptions
+	store         *manifestWorkStore
+	persister     *manifestWorkPersister
+	// addonClient is used to delete the ManifestWorks whose owner ManagedClusterAddOns are deleted, as the
+	// garbage collector of the hub does with the ManifestWorks of the kube-apiserver.
+	addonClient addonv1alpha1client.Interface
+
+	// writeLock serializes the writes of the ManifestWorks, so the events of a ManifestWork are published in the
+	// order of its changes.
+	writeLock sync.Mutex
+
+	lock   sync.RWMutex
+	client *mqtt.Client
+	// ctx is the context the source runs in, the resyncs requested by the agents are published in it.
+	ctx context.Context
+}
+
+// restore rebuilds the store with the persisted ManifestWorks, it is called before the source connects to the
+// broker so the status reported by the agents is kept.
+func (s *workSource) restore(ctx context.Context) error {
+	works, err := s.persister.load(ctx)
+	if err != nil {
+		return err
+	}
+	for _, work := range works {
+		if _, err := s.store.add(work); err != nil {
+			return err
+		}
+	}
+	klog.V(2).Infof("Restored %d works of source %s", len(works), s.sourceID)
+	return nil
+}
+
+// connect connects to the broker, subscribes the events of the agents and asks the agents to report the status
+// of the ManifestWorks again, since the status reported when the source is disconnected is lost. The deletions
+// of the ManifestWorks are published again, since they may be lost when the source is disconnected or stopped.
+func (s *workSource) connect(ctx context.Context) error {
+	client, err := mqtt.Connect(ctx, s.clientOptions, s.handle)
+	if err != nil {
+		return err
+	}
+	if err := client.Subscribe(ctx, AgentEventsTopic(s.sourceID, "+"), AgentBroadcastTopic("+")); err != nil {
+		client.Close()
+		return fmt.Errorf("failed to subscribe the events of the agents: %w", err)
+	}
+
+	s.lock.Lock()
+	s.client = client
+	s.lock.Unlock()
+
+	works, err := s.store.list(metav1.NamespaceAll, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	statusHashes := &StatusHashList{StatusHashes: []ResourceStatusHash{}}
+	for i := range works.Items {
+		statusHashes.StatusHashes = append(statusHashes.StatusHashes, ResourceStatusHash{
+			ResourceID: string(works.Items[i].UID),
+			StatusHash: statusHash(&works.Items[i].Status),
+		})
+	}
+	evt, err := NewCloudEvent(s.sourceID, StatusResyncRequestEventType, statusHashes)
+	if err != nil {
+		return err
+	}
+	if err := s.publishEvent(ctx, SourceBroadcastTopic(s.sourceID), evt); err != nil {
+		return err
+	}
+
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	for i := range works.Items {
+		work := &works.Items[i]
+		if work.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := s.publish(ctx, SpecDeleteRequestEventType, work); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run reconnects to the broker once the connection is lost until the context is done.
+func (s *workSource) run(ctx context.Context) {
+	backoff := wait.Backoff{Duration: time.Second, Factor: 2, Steps: 1 << 30, Cap: 30 * time.Second}
+	for {
+		s.lock.RLock()
+		client := s.client
+		s.lock.RUnlock()
+
+		select {
+		case <-ctx.Done():
+			client.Close()
+			s.store.shutdown()
+			return
+		case <-client.Done():
+		}
+		klog.Warningf("The connection to the MQTT broker %s is lost: %v", s.clientOptions.BrokerHost, client.Err())
+
+		for {
+			err := s.connect(ctx)
+			if err == nil {
+				klog.Infof("Reconnected to the MQTT broker %s", s.clientOptions.BrokerHost)
+				break
+			}
+			delay := backoff.Step()
+			klog.Errorf("Failed to reconnect to the MQTT broker %s, retry in %s: %v", s.clientOptions.BrokerHost, delay, err)
+			select {
+			case <-ctx.Done():
+				s.store.shutdown()
+				return
+			case <-time.After(delay):
+			}
+		}
+		backoff.Duration = time.Second
+	}
+}
+
+// publish publishes the spec of the ManifestWork to the agent of its cluster, the delete requests have no data.
+func (s *workSource) publish(ctx context.Context, eventType string, work *workv1.ManifestWork) error {
+	var data interface{}
+	if eventType != SpecDeleteRequestEventType {
+		data = &ManifestBundle{
+			Manifests:       work.Spec.Workload.Manifests,
+			DeleteOption:    work.Spec.DeleteOption,
+			ManifestConfigs: work.Spec.ManifestConfigs,
+		}
+	}
+	evt, err := NewCloudEvent(s.sourceID, eventType, data)
+	if err != nil {
+		return err
+	}
+	evt.ResourceID = string(work.UID)
+	evt.ResourceVersion = work.Generation
+	evt.ClusterName = work.Namespace
+	if !work.DeletionTimestamp.IsZero() {
+		evt.DeletionTimestamp = work.DeletionTimestamp.UTC().Format(time.RFC3339)
+	}
+	return s.publishEvent(ctx, SourceEventsTopic(s.sourceID, work.Namespace), evt)
+}
+
+// publishOrphanDelete asks the agent of the cluster to delete the ManifestBundle of the resource id which is not
+// a ManifestWork of the source, e.g. it is removed from the source when the agent is disconnected.
+func (s *workSource) publishOrphanDelete(ctx context.Context, clusterName, resourceID string) error {
+	now := metav1.Now()
+	return s.publish(ctx, SpecDeleteRequestEventType, &workv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         clusterName,
+			UID:               types.UID(resourceID),
+			DeletionTimestamp: &now,
+		},
+	})
+}
+
+func (s *workSource) publishEvent(ctx context.Context, topic string, evt *CloudEvent) error {
+	message, err := evt.Message(topic, publishQoS)
+	if err != nil {
+		return err
+	}
+
+	s.lock.RLock()
+	client := s.client
+	s.lock.RUnlock()
+	if err := client.Publish(ctx, message); err != nil {
+		return fmt.Errorf("failed to publish the event %s to %s: %w", evt.Type, topic, err)
+	}
+	return nil
+}
+
+// handle handles the events published by the agents. It is called from the goroutine reading the connection,
+// so the events are published in their own goroutines.
+func (s *workSource) handle(message *mqtt.PublishPacket) {
+	evt, err := DecodeCloudEvent(message)
+	if err != nil {
+		klog.Warningf("Ignored the message of topic %s: %v", message.Topic, err)
+		return
+	}
+
+	// the agents are only allowed to publish to the topics of their clusters by the broker, so the cluster of an
+	// event is the cluster of its topic.
+	clusterName := topicClusterName(message.Topic)
+	if len(clusterName) == 0 {
+		klog.Warningf("Ignored the event %s of topic %s without a cluster", evt.ID, message.Topic)
+		return
+	}
+
+	switch evt.Type {
+	case StatusUpdateRequestEventType:
+		if err := s.updateStatus(clusterName, evt); err != nil {
+			klog.Warningf("Ignored the status event %s from %s: %v", evt.ID, evt.Source, err)
+		}
+	case SpecResyncRequestEventType:
+		versions := &ResourceVersionList{}
+		if len(evt.Data) > 0 {
+			if err := json.Unmarshal(evt.Data, versions); err != nil {
+				klog.Warningf("Ignored the resync event %s from %s: %v", evt.ID, evt.Source, err)
+				return
+			}
+		}
+		go s.resync(clusterName, versions)
+	default:
+		klog.V(4).Infof("Ignored the event %s of type %s from %s", evt.ID, evt.Type, evt.Source)
+	}
+}
+
+// updateStatus updates the status of the ManifestWork reported by the agent, and removes the ManifestWork once
+// the agent reports it is deleted. The agent is asked to delete the ManifestBundle which is not a ManifestWork of
+// the source.
+func (s *workSource) updateStatus(clusterName string, evt *CloudEvent) error {
+	if len(evt.OriginalSource) > 0 && evt.OriginalSource != s.sourceID {
+		return nil
+	}
+	if len(evt.ResourceID) == 0 {
+		return fmt.Errorf("the resource id of the status should be set")
+	}
+	reported := &ManifestBundleStatus{}
+	if err := json.Unmarshal(evt.Data, reported); err != nil {
+		return fmt.Errorf("invalid status of the work: %w", err)
+	}
+	deleted := meta.IsStatusConditionTrue(reported.Conditions, WorkDeleted)
+
+	uid := types.UID(evt.ResourceID)
+	work := s.store.getByUID(uid)
+	if work == nil {
+		if deleted {
+			return nil
+		}
+		klog.V(2).Infof("Deleting the work %s from cluster %s which is not found", uid, clusterName)
+		go func() {
+			if err := s.publishOrphanDelete(s.ctx, clusterName, evt.ResourceID); err != nil {
+				klog.Errorf("Failed to delete the work %s from cluster %s: %v", uid, clusterName, err)
+			}
+		}()
+		return nil
+	}
+	if work.Namespace != clusterName {
+		return fmt.Errorf("the work %s is not of cluster %s", uid, clusterName)
+	}
+
+	if !work.DeletionTimestamp.IsZero() && deleted {
+		klog.V(2).Infof("The work %s/%s is deleted from the managed cluster", work.Namespace, work.Name)
+		if err := s.persister.remove(s.ctx, work.Namespace, work.Name); err != nil {
+			return err
+		}
+		s.store.remove(work.Namespace, work.Name)
+		return nil
+	}
+
+	status := workv1.ManifestWorkStatus{
+		Conditions:     reported.Conditions,
+		ResourceStatus: workv1.ManifestResourceStatus{Manifests: reported.ResourceStatus},
+	}
+	_, err := s.store.update(work.Namespace, work.Name, func(work *workv1.ManifestWork) bool {
+		if work.UID != uid || equality.Semantic.DeepEqual(work.Status, status) {
+			return false
+		}
+		work.Status = status
+		return true
+	})
+	return err
+}
+
+// resync publishes the ManifestWorks of the cluster whose resource versions are different from the agent, and
+// asks the agent to delete the ManifestBundles which are not ManifestWorks of the source.
+func (s *workSource) resync(clusterName string, versions *ResourceVersionList) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	works, err := s.store.list(clusterName, metav1.ListOptions{})
+	if err != nil {
+		klog.Errorf("Failed to resync the works of cluster %s: %v", clusterName, err)
+		return
+	}
+
+	agentVersions := map[string]int64{}
+	for _, version := range versions.ResourceVersions {
+		agentVersions[version.ResourceID] = version.ResourceVersion
+	}
+
+	for i := range works.Items {
+		work := &works.Items[i]
+		version, ok := agentVersions[string(work.UID)]
+		delete(agentVersions, string(work.UID))
+
+		eventType := SpecCreateRequestEventType
+		switch {
+		case !work.DeletionTimestamp.IsZero():
+			eventType = SpecDeleteRequestEventType
+		case ok && version == work.Generation:
+			continue
+		case ok:
+			eventType = SpecUpdateRequestEventType
+		}
+		if err := s.publish(s.ctx, eventType, work); err != nil {
+			klog.Errorf("Failed to resync the work %s/%s: %v", work.Namespace, work.Name, err)
+			return
+		}
+	}
+
+	for resourceID := range agentVersions {
+		if err := s.publishOrphanDelete(s.ctx, clusterName, resourceID); err != nil {
+			klog.Errorf("Failed to delete the work %s from cluster %s: %v", resourceID, clusterName, err)
+			return
+		}
+	}
+}
+
+// delete marks the ManifestWork deleting and publishes the delete request to the agent, it is removed once the
+// agent reports it is deleted.
+func (s *workSource) delete(ctx context.Context, namespace, name string, preconditions *metav1.Preconditions) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	existing, err := s.store.get(namespace, name)
+	if err != nil {
+		return err
+	}
+	if err := checkPreconditions(existing, preconditions); err != nil {
+		return err
+	}
+	if !existing.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	now := metav1.Now()
+	deleting := existing.DeepCopy()
+	deleting.DeletionTimestamp = &now
+	if err := s.persister.save(ctx, deleting); err != nil {
+		return err
+	}
+	if err := s.publish(ctx, SpecDeleteRequestEventType, deleting); err != nil {
+		return err
+	}
+	_, err = s.store.update(namespace, name, func(work *workv1.ManifestWork) bool {
+		work.DeletionTimestamp = &now
+		return true
+	})
+	return err
+}
+
+// collectGarbage deletes the ManifestWorks whose owner ManagedClusterAddOns are all deleted, e.g. when the addons
+// are deleted while the source is stopped.
+func (s *workSource) collectGarbage(ctx context.Context) error {
+	addons, err := s.addonClient.AddonV1alpha1().ManagedClusterAddOns(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	addonUIDs := sets.New[types.UID]()
+	for _, addon := range addons.Items {
+		addonUIDs.Insert(addon.UID)
+	}
+
+	works, err := s.store.list(metav1.NamespaceAll, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range works.Items {
+		work := &works.Items[i]
+		if !work.DeletionTimestamp.IsZero() || !ownersDeleted(work, addonUIDs) {
+			continue
+		}
+		klog.V(2).Infof("Deleting the work %s/%s whose owners are deleted", work.Namespace, work.Name)
+		if err := s.delete(ctx, work.Namespace, work.Name, &metav1.Preconditions{UID: &work.UID}); err != nil &&
+			!apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownersDeleted returns true if the ManifestWork is owned by ManagedClusterAddOns and they are all deleted.
+func ownersDeleted(work *workv1.ManifestWork, addonUIDs sets.Set[types.UID]) bool {
+	owned := false
+	for _, owner := range work.OwnerReferences {
+		if owner.APIVersion != addonv1alpha1.GroupVersion.String() || owner.Kind != "ManagedClusterAddOn" {
+			return false
+		}
+		if addonUIDs.Has(owner.UID) {
+			return false
+		}
+		owned = true
+	}
+	return owned
+}
+
+// statusHash returns the hash of the status of a ManifestWork the agents compare their status with on the status
+// resync.
+func statusHash(status *workv1.ManifestWorkStatus) string {
+	data, _ := json.Marshal(status)
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// topicClusterName returns the cluster name of the topics of the agents, e.g.
+// sources/{sourceID}/clusters/{clusterName}/agentevents and clusters/{clusterName}/agentbroadcast.
+func topicClusterName(topic string) string {
+	levels := strings.Split(topic, "/")
+	for i := 0; i < len(levels)-1; i++ {
+		if levels[i] == "clusters" {
+			return levels[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,226 @@
+package work
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	// watchHistorySize is the number of the latest changes kept to resume the watches from a resource version,
+	// the watches from an older resource version are expired and the watchers list the ManifestWorks again.
+	watchHistorySize = 1000
+	// watchQueueLength is the number of the changes queued for a watcher.
+	watchQueueLength = 1000
+)
+
+var manifestWorkResource = workv1.Resource("manifestworks")
+
+type storeEvent struct {
+	eventType       watch.EventType
+	work            *workv1.ManifestWork
+	resourceVersion uint64
+}
+
+// manifestWorkStore keeps the ManifestWorks of the source in memory, the ManifestWorks are listed and watched from
+// the store with the resource versions of the store.
+type manifestWorkStore struct {
+	lock            sync.RWMutex
+	works           map[string]*workv1.ManifestWork
+	resourceVersion uint64
+	history         []storeEvent
+	broadcaster     *watch.Broadcaster
+}
+
+func newManifestWorkStore() *manifestWorkStore {
+	return &manifestWorkStore{
+		works:       map[string]*workv1.ManifestWork{},
+		broadcaster: watch.NewLongQueueBroadcaster(watchQueueLength, watch.WaitIfChannelFull),
+	}
+}
+
+func storeKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+func (s *manifestWorkStore) get(namespace, name string) (*workv1.ManifestWork, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	work, ok := s.works[storeKey(namespace, name)]
+	if !ok {
+		return nil, apierrors.NewNotFound(manifestWorkResource, name)
+	}
+	return work.DeepCopy(), nil
+}
+
+// getByUID returns the ManifestWork of the uid, it is nil if the ManifestWork is not found.
+func (s *manifestWorkStore) getByUID(uid types.UID) *workv1.ManifestWork {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, work := range s.works {
+		if work.UID == uid {
+			return work.DeepCopy()
+		}
+	}
+	return nil
+}
+
+func (s *manifestWorkStore) list(namespace string, opts metav1.ListOptions) (*workv1.ManifestWorkList, error) {
+	match, err := matchFunc(namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	list := &workv1.ManifestWorkList{
+		ListMeta: metav1.ListMeta{ResourceVersion: strconv.FormatUint(s.resourceVersion, 10)},
+	}
+	for _, work := range s.works {
+		if match(work) {
+			list.Items = append(list.Items, *work.DeepCopy())
+		}
+	}
+	return list, nil
+}
+
+// watch watches the changes of the ManifestWorks after the resource version of the options, the changes are
+// watched from now on if the resource version is not set.
+func (s *manifestWorkStore) watch(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+	match, err := matchFunc(namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var prefix []watch.Event
+	if len(opts.ResourceVersion) > 0 && opts.ResourceVersion != "0" {
+		since, err := strconv.ParseUint(opts.ResourceVersion, 10, 64)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid resource version %q", opts.ResourceVersion))
+		}
+		if since < s.resourceVersion {
+			if len(s.history) == 0 || s.history[0].resourceVersion > since+1 {
+				return nil, apierrors.NewResourceExpired(fmt.Sprintf("too old resource version: %d", since))
+			}
+			for _, evt := range s.history {
+				if evt.resourceVersion > since {
+					prefix = append(prefix, watch.Event{Type: evt.eventType, Object: evt.work.DeepCopy()})
+				}
+			}
+		}
+	}
+
+	w, err := s.broadcaster.WatchWithPrefix(prefix)
+	if err != nil {
+		return nil, apierrors.NewServiceUnavailable(err.Error())
+	}
+	return watch.Filter(w, func(evt watch.Event) (watch.Event, bool) {
+		work, ok := evt.Object.(*workv1.ManifestWork)
+		return evt, ok && match(work)
+	}), nil
+}
+
+// add adds the ManifestWork to the store.
+func (s *manifestWorkStore) add(work *workv1.ManifestWork) (*workv1.ManifestWork, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := storeKey(work.Namespace, work.Name)
+	if _, ok := s.works[key]; ok {
+		return nil, apierrors.NewAlreadyExists(manifestWorkResource, work.Name)
+	}
+	return s.commit(watch.Added, work.DeepCopy()), nil
+}
+
+// update updates the ManifestWork of the namespace and name with the mutate func, nothing is changed if the func
+// returns false.
+func (s *manifestWorkStore) update(namespace, name string,
+	mutate func(work *workv1.ManifestWork) bool) (*workv1.ManifestWork, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	existing, ok := s.works[storeKey(namespace, name)]
+	if !ok {
+		return nil, apierrors.NewNotFound(manifestWorkResource, name)
+	}
+	work := existing.DeepCopy()
+	if !mutate(work) {
+		return existing.DeepCopy(), nil
+	}
+	return s.commit(watch.Modified, work), nil
+}
+
+// remove removes the ManifestWork of the namespace and name from the store.
+func (s *manifestWorkStore) remove(namespace, name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	existing, ok := s.works[storeKey(namespace, name)]
+	if !ok {
+		return
+	}
+	s.commit(watch.Deleted, existing.DeepCopy())
+}
+
+// commit records the change of the ManifestWork with a new resource version and notifies the watchers, the
+// lock is held by the caller.
+func (s *manifestWorkStore) commit(eventType watch.EventType, work *workv1.ManifestWork) *workv1.ManifestWork {
+	s.resourceVersion++
+	work.ResourceVersion = strconv.FormatUint(s.resourceVersion, 10)
+
+	key := storeKey(work.Namespace, work.Name)
+	if eventType == watch.Deleted {
+		delete(s.works, key)
+	} else {
+		s.works[key] = work
+	}
+
+	s.history = append(s.history, storeEvent{eventType: eventType, work: work, resourceVersion: s.resourceVersion})
+	if len(s.history) > watchHistorySize {
+		s.history = s.history[len(s.history)-watchHistorySize:]
+	}
+	// the broadcaster is only stopped with the store.
+	_ = s.broadcaster.Action(eventType, work.DeepCopy())
+	return work.DeepCopy()
+}
+
+// shutdown stops the watches of the store.
+func (s *manifestWorkStore) shutdown() {
+	s.broadcaster.Shutdown()
+}
+
+func matchFunc(namespace string, opts metav1.ListOptions) (func(work *workv1.ManifestWork) bool, error) {
+	labelSelector := labels.Everything()
+	if len(opts.LabelSelector) > 0 {
+		selector, err := labels.Parse(opts.LabelSelector)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+		labelSelector = selector
+	}
+	fieldSelector := fields.Everything()
+	if len(opts.FieldSelector) > 0 {
+		selector, err := fields.ParseSelector(opts.FieldSelector)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+		fieldSelector = selector
+	}
+
+	return func(work *workv1.ManifestWork) bool {
+		if namespace != metav1.NamespaceAll && work.Namespace != namespace {
+			return false
+		}
+		return labelSelector.Matches(labels.Set(work.Labels)) && fieldSelector.Matches(fields.Set{
+			"metadata.name":      work.Name,
+			"metadata.namespace": work.Namespace,
+		})
+	}, nil
+}
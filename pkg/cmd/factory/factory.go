@@ -30,6 +30,8 @@ import (
 	"k8s.io/client-go/tools/record"
 	"k8s.io/component-base/logs"
 	"k8s.io/klog/v2"
+
+	basefactory "open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
 )
 
 // ControllerFlags provides the "normal" controller flags
@@ -40,11 +42,29 @@ type ControllerFlags struct {
 	EnableLeaderElection bool
 	// ComponentNamespace is the namespace to run component
 	ComponentNamespace string
+	// LeaderElectionNamespace is the namespace of the leader election lease, defaults to the ComponentNamespace
+	LeaderElectionNamespace string
+	// LeaderElectionIdentity is the identity of the candidate, defaults to the hostname with a unique suffix
+	LeaderElectionIdentity string
+	// LeaseDuration is the duration that non-leader candidates will wait to force acquire leadership
+	LeaseDuration time.Duration
+	// RenewDeadline is the duration that the acting leader will retry refreshing leadership before giving up
+	RenewDeadline time.Duration
+	// RetryPeriod is the duration the candidates should wait between tries of actions
+	RetryPeriod time.Duration
+	// ShutdownDrainTimeout is how long the controllers wait for their in-flight syncs to finish on shutdown
+	ShutdownDrainTimeout time.Duration
 }
 
 // NewControllerFlags returns flags with default values set
 func NewControllerFlags() *ControllerFlags {
-	return &ControllerFlags{}
+	return &ControllerFlags{
+		LeaseDuration: 137 * time.Second,
+		RenewDeadline: 107 * time.Second,
+		RetryPeriod:   26 * time.Second,
+
+		ShutdownDrainTimeout: 10 * time.Second,
+	}
 }
 
 // AddFlags register and binds the default flags
@@ -55,6 +75,19 @@ func (f *ControllerFlags) AddFlags(cmd *cobra.Command) {
 	flags.StringVar(&f.KubeConfigFile, "kubeconfig", f.KubeConfigFile, "Location of the master configuration file to run from.")
 	flags.StringVar(&f.ComponentNamespace, "component-namespace", f.ComponentNamespace, "Namespace of the component.")
 	flags.BoolVar(&f.EnableLeaderElection, "enable-leader-election", f.EnableLeaderElection, "Enables the leader election for the controller")
+	flags.StringVar(&f.LeaderElectionNamespace, "leader-election-namespace", f.LeaderElectionNamespace,
+		"Namespace of the leader election lease, defaults to the component namespace.")
+	flags.StringVar(&f.LeaderElectionIdentity, "leader-election-identity", f.LeaderElectionIdentity,
+		"Identity of the leader election candidate, defaults to the hostname with a unique suffix.")
+	flags.DurationVar(&f.LeaseDuration, "leader-election-lease-duration", f.LeaseDuration,
+		"The duration that non-leader candidates will wait after observing a leadership renewal before attempting to acquire leadership.")
+	flags.DurationVar(&f.RenewDeadline, "leader-election-renew-deadline", f.RenewDeadline,
+		"The duration that the acting leader will retry refreshing leadership before giving up, must be less than the lease duration.")
+	flags.DurationVar(&f.RetryPeriod, "leader-election-retry-period", f.RetryPeriod,
+		"The duration the candidates should wait between tries of acquiring or renewing the leadership.")
+	flags.DurationVar(&f.ShutdownDrainTimeout, "shutdown-drain-timeout", f.ShutdownDrainTimeout,
+		"The duration the controllers wait for their in-flight syncs to finish on shutdown before cancelling them, "+
+			"the leader election is released after the controllers are stopped.")
 }
 
 // ControllerCommandConfig holds values required to construct a command to run.
@@ -63,6 +96,7 @@ type ControllerCommandConfig struct {
 	startFunc     StartFunc
 	version       version.Info
 	healthChecks  []healthz.HealthChecker
+	renderFunc    RenderFunc
 
 	basicFlags *ControllerFlags
 }
@@ -87,6 +121,15 @@ func (c *ControllerCommandConfig) WithHealthChecks(healthChecks ...healthz.Healt
 	return c
 }
 
+// WithLeaderElection enables the leader election by default, so multiple replicas of the controller can run for
+// HA and only the leader runs the controllers. The lease is created in the namespace, or in the component
+// namespace if it is empty. The election can still be disabled by the --enable-leader-election=false flag.
+func (c *ControllerCommandConfig) WithLeaderElection(namespace string) *ControllerCommandConfig {
+	c.basicFlags.EnableLeaderElection = true
+	c.basicFlags.LeaderElectionNamespace = namespace
+	return c
+}
+
 func (c *ControllerCommandConfig) NewCommand() *cobra.Command {
 	ctx := context.TODO()
 	cmd := &cobra.Command{
@@ -114,6 +157,9 @@ func (c *ControllerCommandConfig) NewCommand() *cobra.Command {
 	}
 
 	c.basicFlags.AddFlags(cmd)
+	if c.renderFunc != nil {
+		cmd.AddCommand(c.newRenderCommand())
+	}
 
 	return cmd
 }
@@ -132,11 +178,17 @@ func (c *ControllerCommandConfig) StartController(ctx context.Context) error {
 		return err
 	}
 	serverConfig.HealthzChecks = append(serverConfig.HealthzChecks, c.healthChecks...)
+	// report the controllers stuck in a sync on /healthz and /livez, and the controllers waiting for their
+	// caches to sync on /readyz.
+	serverConfig.AddHealthChecks(basefactory.ControllersLivenessChecker())
+	serverConfig.AddReadyzChecks(basefactory.ControllersReadyChecker())
 
 	server, err = serverConfig.Complete(nil).New(c.componentName, genericapiserver.NewEmptyDelegate())
 	if err != nil {
 		return err
 	}
+	// serve the metrics with the exemplars of the sync traces in the OpenMetrics format
+	server.Handler.NonGoRestfulMux.Handle(basefactory.OpenMetricsPath, basefactory.OpenMetricsHandler())
 
 	go func() {
 		if err := server.PrepareRun().Run(ctx.Done()); err != nil {
@@ -145,28 +197,50 @@ func (c *ControllerCommandConfig) StartController(ctx context.Context) error {
 		klog.Info("server exited")
 	}()
 
+	// the controllers run with the context wait for their in-flight syncs in the drain timeout on shutdown
+	ctx = basefactory.WithDrainTimeout(ctx, c.basicFlags.ShutdownDrainTimeout)
 	if !c.basicFlags.EnableLeaderElection {
 		return c.startFunc(ctx, kubeConfig)
 	}
 
 	leaderConfig := rest.CopyConfig(kubeConfig)
-	leaderElection, err := toLeaderElection(leaderConfig, c.componentName, c.basicFlags.ComponentNamespace)
+	leaderElection, err := toLeaderElection(leaderConfig, c.componentName, c.basicFlags)
 	if err != nil {
 		return err
 	}
 
-	// 10s is the graceful termination time we give the controllers to finish their workers.
-	// when this time pass, we exit with non-zero code, killing all controller workers.
+	// the leader election runs with its own context, so the lease is released after the controllers drain their
+	// in-flight syncs on shutdown, instead of when the shutdown signal is received.
+	leaderCtx, releaseLeader := context.WithCancel(context.Background())
+	defer releaseLeader()
+	leadingCh, stoppedCh := make(chan struct{}), make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		select {
+		case <-leadingCh:
+			<-stoppedCh
+		default:
+		}
+		releaseLeader()
+	}()
+
+	// the drain timeout and 10s more for flushing the status patches of the cancelled syncs is the graceful
+	// termination time we give the controllers to finish their workers. when this time pass, we exit with
+	// non-zero code, killing all controller workers.
 	// NOTE: The pod must set the termination graceful time.
-	leaderElection.Callbacks.OnStartedLeading = c.getOnStartedLeadingFunc(kubeConfig, 10*time.Second)
+	leaderElection.Callbacks.OnStartedLeading = c.getOnStartedLeadingFunc(ctx, kubeConfig,
+		c.basicFlags.ShutdownDrainTimeout+10*time.Second, leadingCh, stoppedCh)
 
-	leaderelection.RunOrDie(ctx, leaderElection)
+	leaderelection.RunOrDie(leaderCtx, leaderElection)
 	return nil
 }
 
-func (c *ControllerCommandConfig) getOnStartedLeadingFunc(kubeConfig *rest.Config, gracefulTerminationDuration time.Duration) func(ctx context.Context) {
-	return func(ctx context.Context) {
-		stoppedCh := make(chan struct{})
+// getOnStartedLeadingFunc returns the callback running the controllers with the context when the leadership is
+// acquired. The leadingCh is closed when the controllers start, and the stoppedCh is closed when they stop.
+func (c *ControllerCommandConfig) getOnStartedLeadingFunc(ctx context.Context, kubeConfig *rest.Config,
+	gracefulTerminationDuration time.Duration, leadingCh, stoppedCh chan struct{}) func(context.Context) {
+	return func(_ context.Context) {
+		close(leadingCh)
 		go func() {
 			defer close(stoppedCh)
 			if err := c.startFunc(ctx, kubeConfig); err != nil {
@@ -195,19 +269,35 @@ func (c *ControllerCommandConfig) getOnStartedLeadingFunc(kubeConfig *rest.Confi
 	}
 }
 
-func toLeaderElection(clientConfig *rest.Config, component, namespace string) (leaderelection.LeaderElectionConfig, error) {
+func toLeaderElection(clientConfig *rest.Config, component string, flags *ControllerFlags) (leaderelection.LeaderElectionConfig, error) {
+	if flags.LeaseDuration <= flags.RenewDeadline {
+		return leaderelection.LeaderElectionConfig{}, fmt.Errorf("leader election lease duration %s must be greater than the renew deadline %s",
+			flags.LeaseDuration, flags.RenewDeadline)
+	}
+	if flags.RetryPeriod <= 0 || flags.RenewDeadline <= flags.RetryPeriod {
+		return leaderelection.LeaderElectionConfig{}, fmt.Errorf("leader election renew deadline %s must be greater than the retry period %s",
+			flags.RenewDeadline, flags.RetryPeriod)
+	}
+
 	kubeClient, err := kubernetes.NewForConfig(clientConfig)
 	if err != nil {
 		return leaderelection.LeaderElectionConfig{}, err
 	}
 
-	var identity string
-	if hostname, err := os.Hostname(); err != nil {
-		// on errors, make sure we're unique
-		identity = string(uuid.NewUUID())
-	} else {
-		// add a uniquifier so that two processes on the same host don't accidentally both become active
-		identity = hostname + "_" + string(uuid.NewUUID())
+	identity := flags.LeaderElectionIdentity
+	if len(identity) == 0 {
+		if hostname, err := os.Hostname(); err != nil {
+			// on errors, make sure we're unique
+			identity = string(uuid.NewUUID())
+		} else {
+			// add a uniquifier so that two processes on the same host don't accidentally both become active
+			identity = hostname + "_" + string(uuid.NewUUID())
+		}
+	}
+
+	namespace := flags.LeaderElectionNamespace
+	if len(namespace) == 0 {
+		namespace = flags.ComponentNamespace
 	}
 
 	var electionNamespace string
@@ -246,9 +336,9 @@ func toLeaderElection(clientConfig *rest.Config, component, namespace string) (l
 	return leaderelection.LeaderElectionConfig{
 		Lock:            rl,
 		ReleaseOnCancel: true,
-		LeaseDuration:   137 * time.Second,
-		RenewDeadline:   107 * time.Second,
-		RetryPeriod:     26 * time.Second,
+		LeaseDuration:   flags.LeaseDuration,
+		RenewDeadline:   flags.RenewDeadline,
+		RetryPeriod:     flags.RetryPeriod,
 		Callbacks: leaderelection.LeaderCallbacks{
 			OnStoppedLeading: func() {
 				defer os.Exit(0)
@@ -0,0 +1,83 @@
+package factory
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestToLeaderElection(t *testing.T) {
+	cases := []struct {
+		name        string
+		flags       func(flags *ControllerFlags)
+		expectedErr bool
+	}{
+		{
+			name:  "default durations",
+			flags: func(flags *ControllerFlags) {},
+		},
+		{
+			name: "custom durations",
+			flags: func(flags *ControllerFlags) {
+				flags.LeaseDuration = 15 * time.Second
+				flags.RenewDeadline = 10 * time.Second
+				flags.RetryPeriod = 2 * time.Second
+			},
+		},
+		{
+			name: "lease duration not greater than renew deadline",
+			flags: func(flags *ControllerFlags) {
+				flags.LeaseDuration = 10 * time.Second
+				flags.RenewDeadline = 10 * time.Second
+			},
+			expectedErr: true,
+		},
+		{
+			name: "renew deadline not greater than retry period",
+			flags: func(flags *ControllerFlags) {
+				flags.RenewDeadline = 10 * time.Second
+				flags.RetryPeriod = 10 * time.Second
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			flags := NewControllerFlags()
+			flags.ComponentNamespace = "component"
+			flags.LeaderElectionNamespace = "election"
+			flags.LeaderElectionIdentity = "replica-1"
+			c.flags(flags)
+
+			config, err := toLeaderElection(&rest.Config{Host: "https://localhost"}, "test", flags)
+			if c.expectedErr {
+				if err == nil {
+					t.Errorf("expected error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if config.LeaseDuration != flags.LeaseDuration || config.RenewDeadline != flags.RenewDeadline ||
+				config.RetryPeriod != flags.RetryPeriod {
+				t.Errorf("expected durations of the flags, but got %v, %v, %v",
+					config.LeaseDuration, config.RenewDeadline, config.RetryPeriod)
+			}
+			if config.Lock.Identity() != "replica-1" {
+				t.Errorf("expected identity replica-1, but got %s", config.Lock.Identity())
+			}
+			lock, ok := config.Lock.(*resourcelock.LeaseLock)
+			if !ok {
+				t.Fatalf("expected lease lock, but got %T", config.Lock)
+			}
+			if lock.LeaseMeta.Namespace != "election" || lock.LeaseMeta.Name != "test" {
+				t.Errorf("expected lease election/test, but got %s/%s", lock.LeaseMeta.Namespace, lock.LeaseMeta.Name)
+			}
+		})
+	}
+}
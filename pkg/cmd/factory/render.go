@@ -0,0 +1,202 @@
+package factory
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/yaml"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/controllers/agentdeploy"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+// RenderFunc returns the agents of the addons rendered by the render command. The agents must read the
+// AddOnDeploymentConfigs by the addonClient, which serves the configs given to the command instead of a hub.
+type RenderFunc func(addonClient addonv1alpha1client.Interface) ([]agent.AgentAddon, error)
+
+// RenderFlags are the flags of the render command
+type RenderFlags struct {
+	// ClusterName is the name of the managed cluster the manifests are rendered for
+	ClusterName string
+	// AddonName is the name of the addon rendered, it is required if more than one agent is returned
+	AddonName string
+	// ConfigFiles are the YAML files of the AddOnDeploymentConfigs referenced by the addon, and optionally the
+	// ManagedClusterAddOn and the ManagedCluster
+	ConfigFiles []string
+}
+
+// AddFlags register and binds the render flags
+func (f *RenderFlags) AddFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVar(&f.ClusterName, "cluster-name", f.ClusterName, "Name of the managed cluster to render the addon for.")
+	flags.StringVar(&f.AddonName, "addon-name", f.AddonName, "Name of the addon to render, required if the "+
+		"controller has more than one addon.")
+	flags.StringArrayVarP(&f.ConfigFiles, "filename", "f", f.ConfigFiles, "YAML files of the AddOnDeploymentConfigs "+
+		"referenced by the addon, and optionally the ManagedClusterAddOn and the ManagedCluster.")
+}
+
+// WithRenderCommand adds the render sub-command to the command, which renders the ManifestWorks of an addon
+// returned by the renderFunc for a managed cluster offline and prints them in YAML, to debug the manifests and
+// the values of an addon without a hub.
+func (c *ControllerCommandConfig) WithRenderCommand(renderFunc RenderFunc) *ControllerCommandConfig {
+	c.renderFunc = renderFunc
+	return c
+}
+
+func (c *ControllerCommandConfig) newRenderCommand() *cobra.Command {
+	flags := &RenderFlags{}
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "Render the ManifestWorks of the addon for a managed cluster without a hub",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return render(cmd.OutOrStdout(), flags, c.renderFunc)
+		},
+	}
+	flags.AddFlags(cmd)
+	return cmd
+}
+
+func render(out io.Writer, flags *RenderFlags, renderFunc RenderFunc) error {
+	if len(flags.ClusterName) == 0 {
+		return fmt.Errorf("--cluster-name is required")
+	}
+
+	objects, err := readObjects(flags.ConfigFiles)
+	if err != nil {
+		return err
+	}
+
+	var configs []runtime.Object
+	var addon *addonapiv1alpha1.ManagedClusterAddOn
+	cluster := &clusterv1.ManagedCluster{}
+	for _, obj := range objects {
+		switch obj.GetKind() {
+		case "AddOnDeploymentConfig":
+			config := &addonapiv1alpha1.AddOnDeploymentConfig{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, config); err != nil {
+				return err
+			}
+			configs = append(configs, config)
+		case "ManagedClusterAddOn":
+			addon = &addonapiv1alpha1.ManagedClusterAddOn{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, addon); err != nil {
+				return err
+			}
+		case "ManagedCluster":
+			cluster = &clusterv1.ManagedCluster{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, cluster); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported %s %s, only AddOnDeploymentConfig, ManagedClusterAddOn and "+
+				"ManagedCluster are supported", obj.GetKind(), obj.GetName())
+		}
+	}
+	cluster.Name = flags.ClusterName
+
+	addonClient := fakeaddon.NewSimpleClientset(configs...)
+	agentAddons, err := renderFunc(addonClient)
+	if err != nil {
+		return err
+	}
+	agentAddon, err := selectAgent(agentAddons, flags.AddonName)
+	if err != nil {
+		return err
+	}
+	addonName := agentAddon.GetAgentAddonOptions().AddonName
+
+	if addon == nil {
+		addon = &addonapiv1alpha1.ManagedClusterAddOn{
+			ObjectMeta: metav1.ObjectMeta{Name: addonName, Namespace: flags.ClusterName},
+		}
+		// the given configs are the desired configs of the addon
+		for _, obj := range configs {
+			config := obj.(*addonapiv1alpha1.AddOnDeploymentConfig)
+			referent := addonapiv1alpha1.ConfigReferent{Namespace: config.Namespace, Name: config.Name}
+			addon.Status.ConfigReferences = append(addon.Status.ConfigReferences, addonapiv1alpha1.ConfigReference{
+				ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+					Group:    utils.AddOnDeploymentConfigGVR.Group,
+					Resource: utils.AddOnDeploymentConfigGVR.Resource,
+				},
+				ConfigReferent: referent,
+				DesiredConfig:  &addonapiv1alpha1.ConfigSpecHash{ConfigReferent: referent},
+			})
+		}
+	}
+	addon.Name = addonName
+	addon.Namespace = flags.ClusterName
+
+	works, err := agentdeploy.RenderManifestWorks(agentAddon, addonClient, cluster, addon)
+	if err != nil {
+		return err
+	}
+	for _, work := range works {
+		work.TypeMeta.APIVersion = "work.open-cluster-management.io/v1"
+		work.TypeMeta.Kind = "ManifestWork"
+		data, err := yaml.Marshal(work)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(out, "---\n%s", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func selectAgent(agentAddons []agent.AgentAddon, addonName string) (agent.AgentAddon, error) {
+	if len(addonName) == 0 {
+		if len(agentAddons) != 1 {
+			return nil, fmt.Errorf("--addon-name is required, %d addons are found", len(agentAddons))
+		}
+		return agentAddons[0], nil
+	}
+	for _, agentAddon := range agentAddons {
+		if agentAddon.GetAgentAddonOptions().AddonName == addonName {
+			return agentAddon, nil
+		}
+	}
+	return nil, fmt.Errorf("addon %s is not found", addonName)
+}
+
+// readObjects reads the objects in the YAML files, a file can have multiple documents.
+func readObjects(files []string) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	for _, file := range files {
+		f, err := os.Open(filepath.Clean(file))
+		if err != nil {
+			return nil, err
+		}
+		decoder := utilyaml.NewYAMLOrJSONDecoder(f, 4096)
+		for {
+			obj := &unstructured.Unstructured{}
+			err := decoder.Decode(&obj.Object)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to decode %s: %v", file, err)
+			}
+			if len(obj.Object) == 0 {
+				continue
+			}
+			objects = append(objects, obj)
+		}
+		f.Close()
+	}
+	return objects, nil
+}
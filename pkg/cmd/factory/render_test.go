@@ -0,0 +1,106 @@
+package factory
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+)
+
+type testAgent struct {
+	name string
+}
+
+func (t *testAgent) Manifests(cluster *clusterv1.ManagedCluster,
+	addon *addonapiv1alpha1.ManagedClusterAddOn) ([]runtime.Object, error) {
+	configMap := addontesting.NewUnstructured("v1", "ConfigMap", "default", "test")
+	configMap.Object["data"] = map[string]interface{}{
+		"cluster": cluster.Name,
+		"configs": len(addon.Status.ConfigReferences),
+	}
+	return []runtime.Object{configMap}, nil
+}
+
+func (t *testAgent) GetAgentAddonOptions() agent.AgentAddonOptions {
+	return agent.AgentAddonOptions{AddonName: t.name}
+}
+
+const deploymentConfig = `apiVersion: addon.open-cluster-management.io/v1alpha1
+kind: AddOnDeploymentConfig
+metadata:
+  name: test-config
+  namespace: cluster1
+spec:
+  customizedVariables:
+  - name: IMAGE
+    value: quay.io/test
+---
+`
+
+func TestRender(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte(deploymentConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	renderFunc := func(addonClient addonv1alpha1client.Interface) ([]agent.AgentAddon, error) {
+		return []agent.AgentAddon{&testAgent{name: "test"}, &testAgent{name: "other"}}, nil
+	}
+
+	cases := []struct {
+		name           string
+		flags          *RenderFlags
+		expectedErr    bool
+		expectedOutput []string
+	}{
+		{
+			name:        "no cluster name",
+			flags:       &RenderFlags{AddonName: "test"},
+			expectedErr: true,
+		},
+		{
+			name:        "no addon name",
+			flags:       &RenderFlags{ClusterName: "cluster1"},
+			expectedErr: true,
+		},
+		{
+			name:        "addon not found",
+			flags:       &RenderFlags{ClusterName: "cluster1", AddonName: "unknown"},
+			expectedErr: true,
+		},
+		{
+			name:  "render with configs",
+			flags: &RenderFlags{ClusterName: "cluster1", AddonName: "test", ConfigFiles: []string{configFile}},
+			expectedOutput: []string{
+				"kind: ManifestWork",
+				"name: addon-test-deploy-0",
+				"namespace: cluster1",
+				"cluster: cluster1",
+				"configs: 1",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			err := render(out, c.flags, renderFunc)
+			if c.expectedErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			for _, expected := range c.expectedOutput {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("expected %q in the output:\n%s", expected, out.String())
+				}
+			}
+		})
+	}
+}
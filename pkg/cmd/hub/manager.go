@@ -10,11 +10,13 @@ import (
 
 // NewHubManager generates a command to start hub manager
 func NewHubManager() *cobra.Command {
+	managerOptions := manager.NewManagerOptions()
 	cmdConfig := factory.
-		NewControllerCommandConfig("manager", version.Get(), manager.RunManager)
+		NewControllerCommandConfig("manager", version.Get(), managerOptions.RunManager)
 	cmd := cmdConfig.NewCommand()
 	cmd.Use = "manager"
 	cmd.Short = "Start the Addon Manager"
+	managerOptions.AddFlags(cmd.Flags())
 
 	return cmd
 }
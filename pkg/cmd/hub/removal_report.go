@@ -0,0 +1,67 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	workv1client "open-cluster-management.io/api/client/work/clientset/versioned"
+
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+// NewRemovalReport generates a command to report what will be removed when an addon is deleted
+func NewRemovalReport() *cobra.Command {
+	var kubeconfig, addonName string
+	var clusterNames []string
+
+	cmd := &cobra.Command{
+		Use:   "removal-report",
+		Short: "Report the resources removed or orphaned when an addon is deleted, without deleting anything",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(addonName) == 0 {
+				return fmt.Errorf("the addon name is required")
+			}
+
+			config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+			if err != nil {
+				return err
+			}
+			kubeClient, err := kubernetes.NewForConfig(config)
+			if err != nil {
+				return err
+			}
+			addonClient, err := addonv1alpha1client.NewForConfig(config)
+			if err != nil {
+				return err
+			}
+			workClient, err := workv1client.NewForConfig(config)
+			if err != nil {
+				return err
+			}
+
+			report, err := utils.NewAddonRemovalReport(context.Background(),
+				kubeClient, addonClient, workClient, addonName, clusterNames...)
+			if err != nil {
+				return err
+			}
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			return err
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&kubeconfig, "kubeconfig", kubeconfig, "The kubeconfig of the hub, the in-cluster config is used if it is empty.")
+	flags.StringVar(&addonName, "addon", addonName, "The name of the addon.")
+	flags.StringSliceVar(&clusterNames, "cluster", clusterNames,
+		"The clusters to delete the addon from. If it is empty, the deletion of the ClusterManagementAddOn is reported.")
+	return cmd
+}
@@ -0,0 +1,164 @@
+package hubconfig
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+// KubeconfigKey is the key of the kubeconfig in the hub kubeconfig secret. The kubeconfig refers to the client
+// certificate and key by the file names TLSCertKey and TLSPrivateKeyKey in the same secret.
+const KubeconfigKey = "kubeconfig"
+
+// HubConfigWatcher watches the hub kubeconfig secret created by the registration for the addon agent on the
+// managed cluster, and builds the rest config to talk to the hub from it.
+type HubConfigWatcher interface {
+	// Start watches the secret until the context is done.
+	Start(ctx context.Context)
+
+	// GetHubConfig returns the rest config built from the latest hub kubeconfig secret. It returns an error
+	// if the secret is not synced yet.
+	GetHubConfig() (*rest.Config, error)
+}
+
+// hubConfigWatcher watches the hub kubeconfig secret with the given name and namespace
+type hubConfigWatcher struct {
+	kubeClient      kubernetes.Interface
+	secretName      string
+	secretNamespace string
+	onRotation      []func(config *rest.Config)
+
+	lock   sync.RWMutex
+	config *rest.Config
+	secret *corev1.Secret
+}
+
+// NewHubConfigWatcher returns a watcher of the {addonName}-hub-kubeconfig secret in the install namespace of the
+// addon agent. The onRotation funcs are called with the new rest config once the content of the secret is
+// changed, e.g. the client certificate is rotated, so the agent can rebuild its hub clients.
+func NewHubConfigWatcher(
+	kubeClient kubernetes.Interface,
+	addonName, namespace string,
+	onRotation ...func(config *rest.Config),
+) HubConfigWatcher {
+	return &hubConfigWatcher{
+		kubeClient:      kubeClient,
+		secretName:      constants.HubKubeConfigSecretName(addonName),
+		secretNamespace: namespace,
+		onRotation:      onRotation,
+	}
+}
+
+func (w *hubConfigWatcher) Start(ctx context.Context) {
+	informerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(w.kubeClient, 10*time.Minute,
+		kubeinformers.WithNamespace(w.secretNamespace),
+		kubeinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", w.secretName).String()
+		}))
+	_, err := informerFactory.Core().V1().Secrets().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			w.update(obj)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			w.update(obj)
+		},
+	})
+	if err != nil {
+		klog.Errorf("Failed to watch the hub kubeconfig secret %s/%s: %v", w.secretNamespace, w.secretName, err)
+		return
+	}
+
+	informerFactory.Start(ctx.Done())
+	<-ctx.Done()
+}
+
+func (w *hubConfigWatcher) GetHubConfig() (*rest.Config, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	if w.config == nil {
+		return nil, fmt.Errorf("the hub kubeconfig secret %s/%s is not synced", w.secretNamespace, w.secretName)
+	}
+	return rest.CopyConfig(w.config), nil
+}
+
+func (w *hubConfigWatcher) update(obj interface{}) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok || secret.Name != w.secretName {
+		return
+	}
+
+	w.lock.Lock()
+	if w.secret != nil && secretDataEqual(w.secret, secret) {
+		w.lock.Unlock()
+		return
+	}
+	config, err := BuildConfigFromSecret(secret)
+	if err != nil {
+		w.lock.Unlock()
+		klog.Errorf("Failed to build the hub config from secret %s/%s: %v", w.secretNamespace, w.secretName, err)
+		return
+	}
+	rotated := w.config != nil
+	w.config = config
+	w.secret = secret
+	w.lock.Unlock()
+
+	if !rotated {
+		return
+	}
+	klog.Infof("The hub kubeconfig secret %s/%s is rotated", w.secretNamespace, w.secretName)
+	for _, f := range w.onRotation {
+		f(rest.CopyConfig(config))
+	}
+}
+
+// BuildConfigFromSecret builds the rest config from the hub kubeconfig secret. The client certificate and key
+// of the kubeconfig are read from the secret instead of the files the kubeconfig refers to, so the secret is not
+// required to be mounted.
+func BuildConfigFromSecret(secret *corev1.Secret) (*rest.Config, error) {
+	kubeconfig, ok := secret.Data[KubeconfigKey]
+	if !ok {
+		return nil, fmt.Errorf("no %s in secret %s/%s", KubeconfigKey, secret.Namespace, secret.Name)
+	}
+	rawConfig, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	for _, authInfo := range rawConfig.AuthInfos {
+		if cert, ok := secret.Data[corev1.TLSCertKey]; ok {
+			authInfo.ClientCertificate = ""
+			authInfo.ClientCertificateData = cert
+		}
+		if key, ok := secret.Data[corev1.TLSPrivateKeyKey]; ok {
+			authInfo.ClientKey = ""
+			authInfo.ClientKeyData = key
+		}
+	}
+	return clientcmd.NewDefaultClientConfig(*rawConfig, &clientcmd.ConfigOverrides{}).ClientConfig()
+}
+
+func secretDataEqual(old, new *corev1.Secret) bool {
+	if len(old.Data) != len(new.Data) {
+		return false
+	}
+	for key, value := range old.Data {
+		if !bytes.Equal(value, new.Data[key]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package hubconfig
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const agentNs = "open-cluster-management-agent-addon"
+
+func newHubKubeconfigSecret(t *testing.T, server, cert string) *corev1.Secret {
+	kubeconfig, err := clientcmd.Write(clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{"hub": {Server: server}},
+		AuthInfos: map[string]*clientcmdapi.AuthInfo{"user": {
+			ClientCertificate: "tls.crt",
+			ClientKey:         "tls.key",
+		}},
+		Contexts:       map[string]*clientcmdapi.Context{"default": {Cluster: "hub", AuthInfo: "user"}},
+		CurrentContext: "default",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-hub-kubeconfig", Namespace: agentNs},
+		Data: map[string][]byte{
+			KubeconfigKey:           kubeconfig,
+			corev1.TLSCertKey:       []byte(cert),
+			corev1.TLSPrivateKeyKey: []byte("key"),
+		},
+	}
+}
+
+func TestBuildConfigFromSecret(t *testing.T) {
+	config, err := BuildConfigFromSecret(newHubKubeconfigSecret(t, "https://hub:6443", "cert"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "https://hub:6443" {
+		t.Errorf("expected the host of the hub, but got %q", config.Host)
+	}
+	if len(config.CertFile) != 0 || string(config.CertData) != "cert" || string(config.KeyData) != "key" {
+		t.Errorf("expected the client certificate read from the secret, but got %v", config.TLSClientConfig)
+	}
+
+	if _, err := BuildConfigFromSecret(&corev1.Secret{}); err == nil {
+		t.Errorf("expected error when the kubeconfig is missing")
+	}
+}
+
+func TestHubConfigWatcher(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset(newHubKubeconfigSecret(t, "https://hub:6443", "cert1"))
+
+	rotated := make(chan *rest.Config, 1)
+	watcher := NewHubConfigWatcher(kubeClient, "test", agentNs, func(config *rest.Config) {
+		rotated <- config
+	})
+	if _, err := watcher.GetHubConfig(); err == nil {
+		t.Errorf("expected error before the secret is synced")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Start(ctx)
+
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		_, err := watcher.GetHubConfig()
+		return err == nil, nil
+	}); err != nil {
+		t.Fatalf("expected the hub config is synced, but got %v", err)
+	}
+	config, _ := watcher.GetHubConfig()
+	if string(config.CertData) != "cert1" {
+		t.Errorf("expected the client certificate cert1, but got %q", config.CertData)
+	}
+
+	if _, err := kubeClient.CoreV1().Secrets(agentNs).Update(context.TODO(),
+		newHubKubeconfigSecret(t, "https://hub:6443", "cert2"), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case config := <-rotated:
+		if string(config.CertData) != "cert2" {
+			t.Errorf("expected the rotated client certificate cert2, but got %q", config.CertData)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the rotation callback is called")
+	}
+	config, _ = watcher.GetHubConfig()
+	if string(config.CertData) != "cert2" {
+		t.Errorf("expected the client certificate cert2, but got %q", config.CertData)
+	}
+}
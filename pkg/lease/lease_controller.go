@@ -22,9 +22,13 @@ const (
 
 // LeaseUpdater is to update lease with certain period
 type LeaseUpdater interface {
-	// Start starts a goroutine to update lease
+	// Start updates the lease periodically until the context is done.
 	Start(ctx context.Context)
 
+	// WithLeaseDuration sets the duration of the lease, the lease is updated once in the duration. It is 60
+	// seconds by default.
+	WithLeaseDuration(duration time.Duration) LeaseUpdater
+
 	// WithHubLeaseConfig sets the lease config on hub cluster. It allows LeaseUpdater to create/update
 	// addon lease on hub cluster when resource 'Lease' is not available on managed cluster.
 	WithHubLeaseConfig(config *rest.Config, clusterName string) LeaseUpdater
@@ -56,7 +60,14 @@ func NewLeaseUpdater(
 }
 
 func (r *leaseUpdater) Start(ctx context.Context) {
-	wait.JitterUntilWithContext(context.TODO(), r.reconcile, time.Duration(r.leaseDurationSeconds)*time.Second, leaseUpdateJitterFactor, true)
+	wait.JitterUntilWithContext(ctx, r.reconcile, time.Duration(r.leaseDurationSeconds)*time.Second, leaseUpdateJitterFactor, true)
+}
+
+func (r *leaseUpdater) WithLeaseDuration(duration time.Duration) LeaseUpdater {
+	if seconds := int32(duration / time.Second); seconds > 0 {
+		r.leaseDurationSeconds = seconds
+	}
+	return r
 }
 
 func (r *leaseUpdater) WithHubLeaseConfig(config *rest.Config, clusterName string) LeaseUpdater {
@@ -96,7 +107,8 @@ func (r *leaseUpdater) updateLease(ctx context.Context, namespace string, client
 	default:
 		//update lease
 		lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now()}
-		if _, err = client.CoordinationV1().Leases(namespace).Update(context.TODO(), lease, metav1.UpdateOptions{}); err != nil {
+		lease.Spec.LeaseDurationSeconds = &r.leaseDurationSeconds
+		if _, err = client.CoordinationV1().Leases(namespace).Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
 			return err
 		}
 	}
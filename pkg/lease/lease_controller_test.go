@@ -4,12 +4,14 @@ import (
 	"context"
 	"net/http"
 	"testing"
+	"time"
 
 	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	kubefake "k8s.io/client-go/kubernetes/fake"
 	clienttesting "k8s.io/client-go/testing"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
@@ -110,6 +112,39 @@ func TestReconcileWithHealthCheck(t *testing.T) {
 	addontesting.AssertActions(t, kubeClient.Actions(), "get", "create")
 }
 
+func TestStartWithLeaseDuration(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset()
+	leaseUpdater := NewLeaseUpdater(kubeClient, leaseName, agentNs).WithLeaseDuration(30 * time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		leaseUpdater.Start(ctx)
+		close(stopped)
+	}()
+
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		_, err := kubeClient.CoordinationV1().Leases(agentNs).Get(context.TODO(), leaseName, metav1.GetOptions{})
+		return err == nil, nil
+	}); err != nil {
+		t.Fatalf("expected the lease is created, but got %v", err)
+	}
+	lease, err := kubeClient.CoordinationV1().Leases(agentNs).Get(context.TODO(), leaseName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *lease.Spec.LeaseDurationSeconds != 30 {
+		t.Errorf("expected the lease duration 30, but got %d", *lease.Spec.LeaseDurationSeconds)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected the lease updater is stopped when the context is done")
+	}
+}
+
 func TestCheckAddonPodFunc(t *testing.T) {
 	cases := []struct {
 		name     string
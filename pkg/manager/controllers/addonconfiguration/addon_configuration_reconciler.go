@@ -7,72 +7,31 @@ import (
 
 	jsonpatch "github.com/evanphx/json-patch"
 	"k8s.io/apimachinery/pkg/api/equality"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
-	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
 
-	"open-cluster-management.io/addon-framework/pkg/index"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 )
 
 type managedClusterAddonConfigurationReconciler struct {
-	addonClient                addonv1alpha1client.Interface
-	managedClusterAddonIndexer cache.Indexer
-	getClustersByPlacement     func(name, namespace string) ([]string, error)
-}
-
-func (d *managedClusterAddonConfigurationReconciler) buildConfigurationGraph(cma *addonv1alpha1.ClusterManagementAddOn) (*configurationGraph, error) {
-	graph := newGraph(cma.Spec.SupportedConfigs, cma.Status.DefaultConfigReferences)
-	addons, err := d.managedClusterAddonIndexer.ByIndex(index.ManagedClusterAddonByName, cma.Name)
-	if err != nil {
-		return graph, err
-	}
-
-	// add all existing addons to the default at first
-	for _, addonObject := range addons {
-		addon := addonObject.(*addonv1alpha1.ManagedClusterAddOn)
-		graph.addAddonNode(addon)
-	}
-
-	if cma.Spec.InstallStrategy.Type == "" || cma.Spec.InstallStrategy.Type == addonv1alpha1.AddonInstallStrategyManual {
-		return graph, nil
-	}
-
-	// check each install strategy in status and override the default configs.
-	var errs []error
-	for _, installProgression := range cma.Status.InstallProgressions {
-		clusters, err := d.getClustersByPlacement(installProgression.PlacementRef.Name, installProgression.PlacementRef.Namespace)
-		if errors.IsNotFound(err) {
-			klog.V(2).Infof("placement %s/%s is not found for addon %s", installProgression.PlacementRef.Namespace, installProgression.PlacementRef.Name, cma.Name)
-			continue
-		}
-		if err != nil {
-			errs = append(errs, err)
-			continue
-		}
-
-		graph.addPlacementNode(installProgression.ConfigReferences, clusters)
-	}
-
-	return graph, utilerrors.NewAggregate(errs)
+	addonClient   addonv1alpha1client.Interface
+	eventRecorder utils.AddonEventRecorder
 }
 
 func (d *managedClusterAddonConfigurationReconciler) reconcile(
-	ctx context.Context, cma *addonv1alpha1.ClusterManagementAddOn) (*addonv1alpha1.ClusterManagementAddOn, reconcileState, error) {
-
+	ctx context.Context, cma *addonv1alpha1.ClusterManagementAddOn,
+	graph *configurationGraph) (*addonv1alpha1.ClusterManagementAddOn, reconcileState, error) {
 	var errs []error
-	// build the configuration graph
-	graph, err := d.buildConfigurationGraph(cma)
-	if err != nil {
-		errs = append(errs, err)
-	}
-
 	for _, addon := range graph.addonToUpdate() {
+		// the configs of the paused addons are not updated until they are resumed.
+		if utils.IsAddonPaused(nil, addon.mca) {
+			continue
+		}
 		mca := d.mergeAddonConfig(addon.mca, addon.desiredConfigs)
 		err := d.patchAddonStatus(ctx, mca, addon.mca)
 		if err != nil {
@@ -158,5 +117,9 @@ func (d *managedClusterAddonConfigurationReconciler) patchAddonStatus(ctx contex
 	klog.V(2).Infof("Patching addon %s/%s status with %s", new.Namespace, new.Name, string(patchBytes))
 	_, err = d.addonClient.AddonV1alpha1().ManagedClusterAddOns(new.Namespace).Patch(
 		ctx, new.Name, types.MergePatchType, patchBytes, metav1.PatchOptions{}, "status")
-	return err
+	if err != nil {
+		return err
+	}
+	utils.RecordAddonStatusEvents(d.eventRecorder, old, new)
+	return nil
 }
@@ -13,6 +13,7 @@ import (
 	clienttesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
 	"open-cluster-management.io/addon-framework/pkg/index"
 	"open-cluster-management.io/api/addon/v1alpha1"
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
@@ -76,6 +77,35 @@ func TestAddonConfigReconcile(t *testing.T) {
 				}})
 			},
 		},
+		{
+			name: "paused addon",
+			managedClusteraddon: []runtime.Object{
+				addontesting.NewAddon("test", "cluster1"),
+				func() *addonv1alpha1.ManagedClusterAddOn {
+					addon := addontesting.NewAddon("test", "cluster2")
+					addon.Annotations = map[string]string{constants.AddonPausedAnnotationKey: "true"}
+					return addon
+				}(),
+			},
+			clusterManagementAddon: addontesting.NewClusterManagementAddon("test", "", "").WithSupportedConfigs(addonv1alpha1.ConfigMeta{
+				ConfigGroupResource: addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"},
+				DefaultConfig:       &addonv1alpha1.ConfigReferent{Name: "test"},
+			}).WithDefaultConfigReferences(addonv1alpha1.DefaultConfigReference{
+				ConfigGroupResource: v1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"},
+				DesiredConfig: &v1alpha1.ConfigSpecHash{
+					ConfigReferent: v1alpha1.ConfigReferent{Name: "test"},
+					SpecHash:       "hash",
+				},
+			}).Build(),
+			placements:         []runtime.Object{},
+			placementDecisions: []runtime.Object{},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				if name := actions[0].(clienttesting.PatchActionImpl).Namespace; name != "cluster1" {
+					t.Errorf("expected the addon in cluster1 patched, but got %s", name)
+				}
+			},
+		},
 		{
 			name: "placement installStrategy",
 			managedClusteraddon: []runtime.Object{
@@ -414,17 +444,21 @@ func TestAddonConfigReconcile(t *testing.T) {
 			}
 
 			controller := &addonConfigurationController{
-				placementDecisionLister: clusterInformers.Cluster().V1beta1().PlacementDecisions().Lister(),
-				placementLister:         clusterInformers.Cluster().V1beta1().Placements().Lister(),
+				managedClusterAddonIndexer: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetIndexer(),
+				placementDecisionLister:    clusterInformers.Cluster().V1beta1().PlacementDecisions().Lister(),
+				placementLister:            clusterInformers.Cluster().V1beta1().Placements().Lister(),
 			}
 
 			reconcile := &managedClusterAddonConfigurationReconciler{
-				addonClient:                fakeAddonClient,
-				getClustersByPlacement:     controller.getClustersByPlacement,
-				managedClusterAddonIndexer: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetIndexer(),
+				addonClient: fakeAddonClient,
+			}
+
+			graph, err := controller.buildConfigurationGraph(c.clusterManagementAddon)
+			if err != nil {
+				t.Fatal(err)
 			}
 
-			_, _, err = reconcile.reconcile(context.TODO(), c.clusterManagementAddon)
+			_, _, err = reconcile.reconcile(context.TODO(), c.clusterManagementAddon, graph)
 			if err != nil && !c.expectErr {
 				t.Errorf("expected no error when sync: %v", err)
 			}
@@ -2,6 +2,7 @@ package addonconfiguration
 
 import (
 	"context"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/labels"
@@ -14,10 +15,13 @@ import (
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
 	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
 	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterinformersv1 "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1"
 	clusterinformersv1beta1 "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1beta1"
+	clusterlisterv1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
 	clusterlisterv1beta1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1beta1"
 	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
 
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
 	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
 	"open-cluster-management.io/addon-framework/pkg/index"
 	"open-cluster-management.io/addon-framework/pkg/utils"
@@ -27,19 +31,25 @@ import (
 // 1. use configuration in mca spec if it is set
 // 2. use configuration in install strategy
 // 3. use configuration in the default configuration in cma
+// the resolved configs are written into the configReferences of the mca status, and read by the agent addons
+// with utils.GetDesiredConfigs.
 type addonConfigurationController struct {
 	addonClient                   addonv1alpha1client.Interface
 	clusterManagementAddonLister  addonlisterv1alpha1.ClusterManagementAddOnLister
 	clusterManagementAddonIndexer cache.Indexer
+	managedClusterAddonIndexer    cache.Indexer
 	addonFilterFunc               utils.AddonManagementFilterFunc
 	placementLister               clusterlisterv1beta1.PlacementLister
 	placementDecisionLister       clusterlisterv1beta1.PlacementDecisionLister
+	managedClusterLister          clusterlisterv1.ManagedClusterLister
+	segmentStateStore             SegmentStateStore
 
 	reconcilers []addonConfigurationReconcile
 }
 
 type addonConfigurationReconcile interface {
-	reconcile(ctx context.Context, cma *addonv1alpha1.ClusterManagementAddOn) (*addonv1alpha1.ClusterManagementAddOn, reconcileState, error)
+	reconcile(ctx context.Context, cma *addonv1alpha1.ClusterManagementAddOn,
+		graph *configurationGraph) (*addonv1alpha1.ClusterManagementAddOn, reconcileState, error)
 }
 
 type reconcileState int64
@@ -51,24 +61,32 @@ const (
 
 func NewAddonConfigurationController(
 	addonClient addonv1alpha1client.Interface,
+	eventRecorder utils.AddonEventRecorder,
 	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
 	clusterManagementAddonInformers addoninformerv1alpha1.ClusterManagementAddOnInformer,
 	placementInformer clusterinformersv1beta1.PlacementInformer,
 	placementDecisionInformer clusterinformersv1beta1.PlacementDecisionInformer,
+	managedClusterInformer clusterinformersv1.ManagedClusterInformer,
 	addonFilterFunc utils.AddonManagementFilterFunc,
+	segmentStateStore SegmentStateStore,
 ) factory.Controller {
 	c := &addonConfigurationController{
 		addonClient:                   addonClient,
 		clusterManagementAddonLister:  clusterManagementAddonInformers.Lister(),
 		clusterManagementAddonIndexer: clusterManagementAddonInformers.Informer().GetIndexer(),
+		managedClusterAddonIndexer:    addonInformers.Informer().GetIndexer(),
 		addonFilterFunc:               addonFilterFunc,
+		segmentStateStore:             segmentStateStore,
 	}
 
 	c.reconcilers = []addonConfigurationReconcile{
 		&managedClusterAddonConfigurationReconciler{
-			addonClient:                addonClient,
-			managedClusterAddonIndexer: addonInformers.Informer().GetIndexer(),
-			getClustersByPlacement:     c.getClustersByPlacement,
+			addonClient:   addonClient,
+			eventRecorder: eventRecorder,
+		},
+		&clusterManagementAddonProgressingReconciler{
+			addonClient:       addonClient,
+			segmentStateStore: segmentStateStore,
 		},
 	}
 
@@ -89,6 +107,12 @@ func NewAddonConfigurationController(
 		c.placementDecisionLister = placementDecisionInformer.Lister()
 	}
 
+	// the labels of the clusters are only read to order the rollout, their changes do not trigger a reconcile.
+	if managedClusterInformer != nil {
+		controllerFactory = controllerFactory.WithBareInformers(managedClusterInformer.Informer())
+		c.managedClusterLister = managedClusterInformer.Lister()
+	}
+
 	return controllerFactory.WithSync(c.sync).ToController("addon-configuration-controller")
 }
 
@@ -113,12 +137,43 @@ func (c *addonConfigurationController) sync(ctx context.Context, syncCtx factory
 		return nil
 	}
 
+	// do not roll out the configs of the paused addon until it is resumed.
+	if utils.IsAddonPaused(cma, nil) {
+		klog.V(4).Infof("Addon %s is paused, skip updating its configs", addonName)
+		return nil
+	}
+
 	cma = cma.DeepCopy()
 
-	var state reconcileState
 	var errs []error
+	// build the configuration graph
+	graph, err := c.buildConfigurationGraph(cma)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// hold the rollouts out of the maintenance window, and requeue when the window opens or closes to
+	// update the rollouts and their Progressing conditions.
+	window, err := newMaintenanceWindow(cma)
+	if err != nil {
+		klog.Warningf("Maintenance window of addon %s is ignored: %v", cma.Name, err)
+	}
+	if window != nil {
+		now := time.Now()
+		for _, node := range graph.getPlacementNodes() {
+			node.outOfMaintenanceWindow = !window.open(now)
+		}
+		syncCtx.Queue().AddAfter(key, window.nextTransition(now))
+	}
+
+	if err := syncRolloutState(ctx, syncCtx, c.segmentStateStore, key, cma, graph); err != nil {
+		return err
+	}
+	requeueRollbackTimeout(syncCtx, key, cma, graph)
+
+	var state reconcileState
 	for _, reconciler := range c.reconcilers {
-		cma, state, err = reconciler.reconcile(ctx, cma)
+		cma, state, err = reconciler.reconcile(ctx, cma, graph)
 		if err != nil {
 			errs = append(errs, err)
 		}
@@ -130,6 +185,144 @@ func (c *addonConfigurationController) sync(ctx context.Context, syncCtx factory
 	return utilerrors.NewAggregate(errs)
 }
 
+// syncRolloutState updates the state of the segmented rollouts on the placement nodes, holds the segments whose
+// previous segment is soaking, and requeues when the soak ends. The states are loaded from the store, and saved
+// by the progressing reconciler. The rollouts are not segmented without a store.
+func syncRolloutState(ctx context.Context, syncCtx factory.SyncContext, store SegmentStateStore, key string,
+	cma *addonv1alpha1.ClusterManagementAddOn, graph *configurationGraph) error {
+	if store == nil {
+		return nil
+	}
+
+	soakTime, err := segmentSoakTime(cma)
+	if err != nil {
+		klog.Warningf("Segment soak time of addon %s is ignored: %v", cma.Name, err)
+	}
+
+	states, err := store.Load(ctx, cma.Name)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for placementRef, node := range graph.getPlacementNodes() {
+		if node.segments == nil || node.rollingUpdate() == nil {
+			continue
+		}
+		state, soakRemaining := node.updateSegmentState(states[placementRef], soakTime, now)
+		node.segmentState = &state
+		if soakRemaining > 0 {
+			syncCtx.Queue().AddAfter(key, soakRemaining)
+		}
+	}
+	return nil
+}
+
+// requeueRollbackTimeout requeues the addon when the first unavailable addon of the rollouts exceeds the rollback
+// timeout, so a failed rollout is rolled back even if the addons do not change anymore.
+func requeueRollbackTimeout(syncCtx factory.SyncContext, key string,
+	cma *addonv1alpha1.ClusterManagementAddOn, graph *configurationGraph) {
+	rollback, err := newRollbackOptions(cma)
+	if err != nil || rollback == nil {
+		return
+	}
+
+	now := time.Now()
+	placementNodes := graph.getPlacementNodes()
+	var shortest time.Duration
+	for i := range cma.Status.InstallProgressions {
+		installProgression := &cma.Status.InstallProgressions[i]
+		node, ok := placementNodes[installProgression.PlacementRef]
+		if !ok {
+			continue
+		}
+		remaining := rollback.timeoutRemaining(installProgression, node, now)
+		if remaining > 0 && (shortest == 0 || remaining < shortest) {
+			shortest = remaining
+		}
+	}
+	if shortest > 0 {
+		syncCtx.Queue().AddAfter(key, shortest)
+	}
+}
+
+func (c *addonConfigurationController) buildConfigurationGraph(cma *addonv1alpha1.ClusterManagementAddOn) (*configurationGraph, error) {
+	graph := newGraph(cma.Spec.SupportedConfigs, cma.Status.DefaultConfigReferences)
+	addons, err := c.managedClusterAddonIndexer.ByIndex(index.ManagedClusterAddonByName, cma.Name)
+	if err != nil {
+		return graph, err
+	}
+
+	// add all existing addons to the default at first
+	for _, addonObject := range addons {
+		addon := addonObject.(*addonv1alpha1.ManagedClusterAddOn)
+		graph.addAddonNode(addon)
+	}
+
+	if cma.Spec.InstallStrategy.Type == "" || cma.Spec.InstallStrategy.Type == addonv1alpha1.AddonInstallStrategyManual {
+		return graph, nil
+	}
+
+	// check each install strategy in status and override the default configs.
+	var errs []error
+	for _, installProgression := range cma.Status.InstallProgressions {
+		clusters, err := c.getClustersByPlacement(installProgression.PlacementRef.Name, installProgression.PlacementRef.Namespace)
+		if errors.IsNotFound(err) {
+			klog.V(2).Infof("placement %s/%s is not found for addon %s", installProgression.PlacementRef.Namespace, installProgression.PlacementRef.Name, cma.Name)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		placementStrategy := addonv1alpha1.PlacementStrategy{PlacementRef: installProgression.PlacementRef}
+		for _, strategy := range cma.Spec.InstallStrategy.Placements {
+			if strategy.PlacementRef == installProgression.PlacementRef {
+				placementStrategy = strategy
+				break
+			}
+		}
+
+		var canaryClusters []string
+		if placementStrategy.RolloutStrategy.Type == addonv1alpha1.AddonRolloutStrategyRollingUpdateWithCanary &&
+			placementStrategy.RolloutStrategy.RollingUpdateWithCanary != nil {
+			canary := placementStrategy.RolloutStrategy.RollingUpdateWithCanary.Placement
+			canaryClusters, err = c.getClustersByPlacement(canary.Name, canary.Namespace)
+			if err != nil && !errors.IsNotFound(err) {
+				errs = append(errs, err)
+				continue
+			}
+		}
+
+		graph.addPlacementNode(placementStrategy, installProgression, clusters, canaryClusters)
+		graph.getPlacementNodes()[installProgression.PlacementRef].segments = c.getClusterSegments(cma, clusters)
+	}
+
+	return graph, utilerrors.NewAggregate(errs)
+}
+
+// getClusterSegments returns the rollout segments of the clusters, which is the value of the cluster label set
+// by the RolloutSegmentLabelAnnotationKey annotation of the ClusterManagementAddOn. It returns nil if the
+// annotation is not set.
+func (c *addonConfigurationController) getClusterSegments(cma *addonv1alpha1.ClusterManagementAddOn, clusters []string) map[string]string {
+	labelKey, ok := cma.Annotations[constants.RolloutSegmentLabelAnnotationKey]
+	if !ok || len(labelKey) == 0 || c.managedClusterLister == nil {
+		return nil
+	}
+
+	segments := map[string]string{}
+	for _, clusterName := range clusters {
+		cluster, err := c.managedClusterLister.Get(clusterName)
+		if err != nil {
+			// the cluster without a segment is rolled out at last
+			continue
+		}
+		segments[clusterName] = cluster.Labels[labelKey]
+	}
+	return segments
+}
+
 func (c *addonConfigurationController) getClustersByPlacement(name, namespace string) ([]string, error) {
 	var clusters []string
 	if c.placementLister == nil || c.placementDecisionLister == nil {
@@ -1,8 +1,31 @@
 package addonconfiguration
 
 import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+var defaultMaxConcurrency = intstr.FromString("25%")
+
+// rolloutStatus is the status of an addon during the rollout of the desired configs
+type rolloutStatus int
+
+const (
+	// toApply means the desired configs are not set on the addon yet
+	toApply rolloutStatus = iota
+	// updating means the desired configs are set on the addon, but the addon has not applied
+	// them or is not available yet
+	updating
+	// succeeded means the addon has applied the desired configs and is available
+	succeeded
 )
 
 // configurationTree is a 2 level snapshot tree on the configuration of addons
@@ -17,10 +40,32 @@ type configurationGraph struct {
 
 // installStrategyNode is a node in configurationGraph defined by a install strategy
 type installStrategyNode struct {
-	desiredConfigs addonConfigMap
+	placementRef    addonv1alpha1.PlacementRef
+	rolloutStrategy addonv1alpha1.RolloutStrategy
+	desiredConfigs  addonConfigMap
 	// children keeps a map of addons node as the children of this node
 	children map[string]*addonNode
 	clusters sets.Set[string]
+	// canaryClusters is the clusters selected by the canary placement of the RollingUpdateWithCanary rollout
+	canaryClusters sets.Set[string]
+	// noKnownGoodConfig is true if a config of the RollingUpdateWithCanary rollout has not passed the
+	// canary yet, the addons of the node are not updated until it passes.
+	noKnownGoodConfig bool
+	// rolledBack is true if the rollout of the desired configs failed, the addons of the node are rolled
+	// back to the last known good configs at once.
+	rolledBack bool
+	// segments maps the clusters to their rollout segments. If it is set, the rolling update processes
+	// the segments one by one, the clusters without a segment are processed at last.
+	segments map[string]string
+	// outOfMaintenanceWindow is true if the rollout is out of the maintenance window of the addon, the
+	// addons which have not started to apply the desired configs are held.
+	outOfMaintenanceWindow bool
+	// segmentSoakUntil is set if the previous segment is soaking, the addons of the current segment which
+	// have not started to apply the desired configs are held until then.
+	segmentSoakUntil time.Time
+	// segmentState is the state of the segmented rolling update of the node, it is nil if the rollout of the
+	// node is not segmented.
+	segmentState *SegmentState
 }
 
 // addonNode is node as a child of installStrategy node represting a mca
@@ -87,24 +132,47 @@ func (g *configurationGraph) addAddonNode(mca *addonv1alpha1.ManagedClusterAddOn
 }
 
 // addNode delete clusters on existing graph so the new configuration overrides the previous
-func (g *configurationGraph) addPlacementNode(installConfigReference []addonv1alpha1.InstallConfigReference, clusters []string) {
+func (g *configurationGraph) addPlacementNode(
+	placementStrategy addonv1alpha1.PlacementStrategy,
+	installProgression addonv1alpha1.InstallProgression,
+	clusters, canaryClusters []string,
+) {
+	installConfigReference := installProgression.ConfigReferences
 	node := &installStrategyNode{
-		desiredConfigs: g.defaults.desiredConfigs,
-		children:       map[string]*addonNode{},
-		clusters:       sets.New[string](clusters...),
+		placementRef:    installProgression.PlacementRef,
+		rolloutStrategy: placementStrategy.RolloutStrategy,
+		desiredConfigs:  g.defaults.desiredConfigs,
+		children:        map[string]*addonNode{},
+		clusters:        sets.New[string](clusters...),
+		canaryClusters:  sets.New[string](canaryClusters...),
+		rolledBack:      meta.IsStatusConditionTrue(installProgression.Conditions, constants.InstallProgressionConditionRolloutFailed),
 	}
 
 	// overrides configuration by install strategy
 	if len(installConfigReference) > 0 {
 		node.desiredConfigs = node.desiredConfigs.copy()
 		for _, configRef := range installConfigReference {
-			if configRef.DesiredConfig == nil {
+			config := configRef.DesiredConfig
+			switch {
+			case node.rolledBack:
+				// roll back to the last known good config
+				if configRef.LastKnownGoodConfig != nil {
+					config = configRef.LastKnownGoodConfig
+				}
+			case node.rolloutStrategy.Type == addonv1alpha1.AddonRolloutStrategyRollingUpdateWithCanary:
+				// only roll out the config that has passed the canary
+				config = configRef.LastKnownGoodConfig
+				if config == nil && configRef.DesiredConfig != nil {
+					node.noKnownGoodConfig = true
+				}
+			}
+			if config == nil {
 				continue
 			}
 			node.desiredConfigs[configRef.ConfigGroupResource] = addonv1alpha1.ConfigReference{
 				ConfigGroupResource: configRef.ConfigGroupResource,
-				ConfigReferent:      configRef.DesiredConfig.ConfigReferent,
-				DesiredConfig:       configRef.DesiredConfig.DeepCopy(),
+				ConfigReferent:      config.ConfigReferent,
+				DesiredConfig:       config.DeepCopy(),
 			}
 		}
 	}
@@ -128,6 +196,13 @@ func (g *configurationGraph) addPlacementNode(installConfigReference []addonv1al
 func (g *configurationGraph) addonToUpdate() []*addonNode {
 	var addons []*addonNode
 	for _, node := range g.nodes {
+		// hold the rollout if the canary regresses
+		if node.rolloutStrategy.Type == addonv1alpha1.AddonRolloutStrategyRollingUpdateWithCanary &&
+			!node.rolledBack && !g.canaryAvailable(node) {
+			klog.V(4).Infof("The canary of placement %s/%s is not available, hold the rollout",
+				node.placementRef.Namespace, node.placementRef.Name)
+			continue
+		}
 		addons = append(addons, node.addonToUpdate()...)
 	}
 
@@ -136,6 +211,62 @@ func (g *configurationGraph) addonToUpdate() []*addonNode {
 	return addons
 }
 
+// canaryAddons returns the addons on the clusters of the canary placement of the node.
+func (g *configurationGraph) canaryAddons(node *installStrategyNode) []*addonNode {
+	var addons []*addonNode
+	nodes := append([]*installStrategyNode{g.defaults}, g.nodes...)
+	for _, n := range nodes {
+		for cluster, addon := range n.children {
+			if node.canaryClusters.Has(cluster) {
+				addons = append(addons, addon)
+			}
+		}
+	}
+	return addons
+}
+
+// canaryAvailable checks whether there are addons on the canary clusters of the node and all of
+// them are available.
+func (g *configurationGraph) canaryAvailable(node *installStrategyNode) bool {
+	addons := g.canaryAddons(node)
+	if len(addons) == 0 {
+		return false
+	}
+
+	for _, addon := range addons {
+		if !meta.IsStatusConditionTrue(addon.mca.Status.Conditions, addonv1alpha1.ManagedClusterAddOnConditionAvailable) {
+			return false
+		}
+	}
+	return true
+}
+
+// canaryPassed checks whether all the addons on the canary clusters of the node have applied the
+// config and are available.
+func (g *configurationGraph) canaryPassed(node *installStrategyNode,
+	gr addonv1alpha1.ConfigGroupResource, desired *addonv1alpha1.ConfigSpecHash) bool {
+	if !g.canaryAvailable(node) {
+		return false
+	}
+
+	for _, addon := range g.canaryAddons(node) {
+		if !addon.configApplied(gr, desired) {
+			return false
+		}
+	}
+	return true
+}
+
+// getPlacementNodes returns the install strategy nodes keyed by the placement reference.
+func (g *configurationGraph) getPlacementNodes() map[addonv1alpha1.PlacementRef]*installStrategyNode {
+	placementNodeMap := map[addonv1alpha1.PlacementRef]*installStrategyNode{}
+	for _, node := range g.nodes {
+		placementNodeMap[node.placementRef] = node
+	}
+
+	return placementNodeMap
+}
+
 func (n *installStrategyNode) addNode(addon *addonv1alpha1.ManagedClusterAddOn) {
 	n.children[addon.Namespace] = &addonNode{
 		mca:            addon,
@@ -168,13 +299,271 @@ func (n *installStrategyNode) addNode(addon *addonv1alpha1.ManagedClusterAddOn)
 	}
 }
 
-// addonToUpdate finds the addons to be updated by placement
+// addonToUpdate finds the addons to be updated by placement. With the RollingUpdate rollout strategy,
+// the number of addons that are being updated is bounded by the maxConcurrency, and the next addons
+// are updated only after the previous ones have applied the desired configs and are available.
+// The RollingUpdateWithCanary rollout strategy rolls out the last known good configs in the same way.
+// If the node has rollout segments, the segments are rolled out one by one, and the maxConcurrency is
+// scaled by the number of addons in the current segment.
 func (n *installStrategyNode) addonToUpdate() []*addonNode {
 	var addons []*addonNode
 
-	for _, addon := range n.children {
+	// sort the addons by cluster name so the rollout order is stable
+	clusters := sets.List(sets.KeySet(n.children))
+
+	if n.rolledBack {
+		for _, cluster := range clusters {
+			addons = append(addons, n.children[cluster])
+		}
+		return addons
+	}
+
+	if n.outOfMaintenanceWindow {
+		for _, cluster := range clusters {
+			if n.children[cluster].rolloutStatus() == updating {
+				addons = append(addons, n.children[cluster])
+			}
+		}
+		return addons
+	}
+
+	if n.rollingUpdate() == nil {
+		for _, cluster := range clusters {
+			addons = append(addons, n.children[cluster])
+		}
+		return addons
+	}
+
+	if n.noKnownGoodConfig {
+		return addons
+	}
+
+	if _, err := n.maxConcurrency(); err != nil {
+		klog.Warningf("failed to get the max concurrency of placement %s/%s: %v",
+			n.placementRef.Namespace, n.placementRef.Name, err)
+		return addons
+	}
+
+	// the addons being updated are kept updating, and the addons to apply are only picked from the current
+	// segment so that a bad config never affects more than one segment at a time.
+	segment, segmentClusters := n.currentSegment()
+	if len(segmentClusters) == 0 {
+		segmentClusters = clusters
+	}
+	maxConcurrency, _ := n.scaledMaxConcurrency(len(segmentClusters))
+
+	var addonsToApply []*addonNode
+	segmentUpdating := 0
+	for _, cluster := range clusters {
+		switch n.children[cluster].rolloutStatus() {
+		case updating:
+			addons = append(addons, n.children[cluster])
+			if n.segments == nil || n.segments[cluster] == segment {
+				segmentUpdating++
+			}
+		case toApply:
+			if n.segments == nil || n.segments[cluster] == segment {
+				addonsToApply = append(addonsToApply, n.children[cluster])
+			}
+		}
+	}
+
+	if !n.segmentSoakUntil.IsZero() {
+		return addons
+	}
+
+	for _, addon := range addonsToApply {
+		if segmentUpdating >= maxConcurrency {
+			break
+		}
 		addons = append(addons, addon)
+		segmentUpdating++
 	}
 
 	return addons
 }
+
+// orderedSegments returns the rollout segments of the node in order, the segment of the clusters without a
+// segment is the last one.
+func (n *installStrategyNode) orderedSegments() []string {
+	segments := sets.New[string]()
+	unsegmented := false
+	for cluster := range n.children {
+		if segment := n.segments[cluster]; len(segment) > 0 {
+			segments.Insert(segment)
+		} else {
+			unsegmented = true
+		}
+	}
+
+	ordered := sets.List(segments)
+	if unsegmented {
+		ordered = append(ordered, "")
+	}
+	return ordered
+}
+
+// segmentClusters returns the sorted clusters in the segment.
+func (n *installStrategyNode) segmentClusters(segment string) []string {
+	var clusters []string
+	for _, cluster := range sets.List(sets.KeySet(n.children)) {
+		if n.segments[cluster] == segment {
+			clusters = append(clusters, cluster)
+		}
+	}
+	return clusters
+}
+
+// currentSegment returns the first segment in which not all the addons have succeeded, and its clusters. It
+// returns empty if the node has no segments or all the segments have succeeded.
+func (n *installStrategyNode) currentSegment() (string, []string) {
+	if n.segments == nil {
+		return "", nil
+	}
+
+	for _, segment := range n.orderedSegments() {
+		clusters := n.segmentClusters(segment)
+		for _, cluster := range clusters {
+			if n.children[cluster].rolloutStatus() != succeeded {
+				return segment, clusters
+			}
+		}
+	}
+	return "", nil
+}
+
+// maxConcurrency returns the max number of addons that can be updated at the same time in a rolling update.
+// It is at least 1 so that the rollout can always make progress.
+func (n *installStrategyNode) maxConcurrency() (int, error) {
+	return n.scaledMaxConcurrency(len(n.children))
+}
+
+// scaledMaxConcurrency returns the max number of addons that can be updated at the same time out of the total.
+func (n *installStrategyNode) scaledMaxConcurrency(total int) (int, error) {
+	maxConcurrency := defaultMaxConcurrency
+	if rollingUpdate := n.rollingUpdate(); rollingUpdate != nil && rollingUpdate.MaxConcurrency != (intstr.IntOrString{}) {
+		maxConcurrency = rollingUpdate.MaxConcurrency
+	}
+
+	length, err := intstr.GetScaledValueFromIntOrPercent(&maxConcurrency, total, true)
+	if err != nil {
+		return 0, fmt.Errorf("invalid maxConcurrency %q: %v", maxConcurrency.String(), err)
+	}
+	if length < 1 {
+		length = 1
+	}
+
+	return length, nil
+}
+
+// rollingUpdate returns the rolling update params of the node, it returns nil if the addons of the
+// node are not rolling updated.
+func (n *installStrategyNode) rollingUpdate() *addonv1alpha1.RollingUpdate {
+	switch n.rolloutStrategy.Type {
+	case addonv1alpha1.AddonRolloutStrategyRollingUpdate:
+		if n.rolloutStrategy.RollingUpdate == nil {
+			return &addonv1alpha1.RollingUpdate{}
+		}
+		return n.rolloutStrategy.RollingUpdate
+	case addonv1alpha1.AddonRolloutStrategyRollingUpdateWithCanary:
+		if n.rolloutStrategy.RollingUpdateWithCanary == nil {
+			return &addonv1alpha1.RollingUpdate{}
+		}
+		return &n.rolloutStrategy.RollingUpdateWithCanary.RollingUpdate
+	}
+	return nil
+}
+
+// rolloutCount returns the number of addons in each rollout status.
+func (n *installStrategyNode) rolloutCount() map[rolloutStatus]int {
+	count := map[rolloutStatus]int{}
+	for _, addon := range n.children {
+		count[addon.rolloutStatus()]++
+	}
+	return count
+}
+
+// configApplied checks whether the desired config of the install strategy has been applied on all
+// the addons of this node. The addons that override the config in their spec are not counted.
+func (n *installStrategyNode) configApplied(gr addonv1alpha1.ConfigGroupResource, desired *addonv1alpha1.ConfigSpecHash) bool {
+	for _, addon := range n.children {
+		addonDesiredConfig, ok := addon.desiredConfigs[gr]
+		if !ok || addonDesiredConfig.DesiredConfig == nil || addonDesiredConfig.DesiredConfig.ConfigReferent != desired.ConfigReferent {
+			continue
+		}
+
+		if !addon.configApplied(gr, desired) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// rolloutStatus returns the status of the addon in the rollout of its desired configs.
+func (n *addonNode) rolloutStatus() rolloutStatus {
+	for _, configRef := range n.mca.Status.ConfigReferences {
+		if _, ok := n.desiredConfigs[configRef.ConfigGroupResource]; !ok {
+			return toApply
+		}
+	}
+
+	status := succeeded
+	for gr, desired := range n.desiredConfigs {
+		var actual *addonv1alpha1.ConfigReference
+		for i := range n.mca.Status.ConfigReferences {
+			if n.mca.Status.ConfigReferences[i].ConfigGroupResource == gr {
+				actual = &n.mca.Status.ConfigReferences[i]
+				break
+			}
+		}
+
+		if actual == nil || actual.DesiredConfig == nil || *actual.DesiredConfig != *desired.DesiredConfig {
+			return toApply
+		}
+
+		if actual.LastAppliedConfig == nil || *actual.LastAppliedConfig != *desired.DesiredConfig {
+			status = updating
+		}
+	}
+
+	if !meta.IsStatusConditionTrue(n.mca.Status.Conditions, addonv1alpha1.ManagedClusterAddOnConditionAvailable) {
+		status = updating
+	}
+
+	return status
+}
+
+// configSucceeded checks whether the desired config of the install strategy has been applied on all
+// the addons of this node and the addons are available. The addons that override the config in their
+// spec are not counted.
+func (n *installStrategyNode) configSucceeded(gr addonv1alpha1.ConfigGroupResource, desired *addonv1alpha1.ConfigSpecHash) bool {
+	if !n.configApplied(gr, desired) {
+		return false
+	}
+
+	for _, addon := range n.children {
+		addonDesiredConfig, ok := addon.desiredConfigs[gr]
+		if !ok || addonDesiredConfig.DesiredConfig == nil || addonDesiredConfig.DesiredConfig.ConfigReferent != desired.ConfigReferent {
+			continue
+		}
+
+		if !meta.IsStatusConditionTrue(addon.mca.Status.Conditions, addonv1alpha1.ManagedClusterAddOnConditionAvailable) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// configApplied checks whether the addon has applied the config with the given spec hash.
+func (n *addonNode) configApplied(gr addonv1alpha1.ConfigGroupResource, desired *addonv1alpha1.ConfigSpecHash) bool {
+	for _, configRef := range n.mca.Status.ConfigReferences {
+		if configRef.ConfigGroupResource != gr {
+			continue
+		}
+		return configRef.LastAppliedConfig != nil && *configRef.LastAppliedConfig == *desired
+	}
+
+	return false
+}
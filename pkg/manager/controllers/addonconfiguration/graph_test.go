@@ -4,14 +4,17 @@ import (
 	"reflect"
 	"testing"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
 )
 
 type placementStrategy struct {
-	configs  []addonv1alpha1.AddOnConfig
-	clusters []string
+	configs         []addonv1alpha1.AddOnConfig
+	clusters        []string
+	rolloutStrategy addonv1alpha1.RolloutStrategy
 }
 
 func TestConfigurationGraph(t *testing.T) {
@@ -302,7 +305,10 @@ func TestConfigurationGraph(t *testing.T) {
 				graph.addAddonNode(addon)
 			}
 			for i, strategy := range c.placementStrategies {
-				graph.addPlacementNode(c.installProgressions[i].ConfigReferences, strategy.clusters)
+				graph.addPlacementNode(addonv1alpha1.PlacementStrategy{
+					PlacementRef:    c.installProgressions[i].PlacementRef,
+					RolloutStrategy: strategy.rolloutStrategy,
+				}, c.installProgressions[i], strategy.clusters, nil)
 			}
 
 			actual := graph.addonToUpdate()
@@ -332,6 +338,355 @@ func TestConfigurationGraph(t *testing.T) {
 	}
 }
 
+func TestRollingUpdateAddonToUpdate(t *testing.T) {
+	fooGR := addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"}
+	newConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash2"}
+	oldConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash1"}
+
+	// newAddon returns an addon with the given desired and last applied configs
+	newAddon := func(cluster string, desired, lastApplied *addonv1alpha1.ConfigSpecHash, available bool) *addonv1alpha1.ManagedClusterAddOn {
+		addon := addontesting.NewAddon("test", cluster)
+		addon.Status.ConfigReferences = []addonv1alpha1.ConfigReference{{
+			ConfigGroupResource: fooGR,
+			ConfigReferent:      desired.ConfigReferent,
+			DesiredConfig:       desired.DeepCopy(),
+			LastAppliedConfig:   lastApplied.DeepCopy(),
+		}}
+		if available {
+			addon.Status.Conditions = []metav1.Condition{{
+				Type:   addonv1alpha1.ManagedClusterAddOnConditionAvailable,
+				Status: metav1.ConditionTrue,
+			}}
+		}
+		return addon
+	}
+
+	cases := []struct {
+		name             string
+		maxConcurrency   *intstr.IntOrString
+		addons           []*addonv1alpha1.ManagedClusterAddOn
+		expectedClusters []string
+	}{
+		{
+			name:           "start rollout with absolute max concurrency",
+			maxConcurrency: &intstr.IntOrString{Type: intstr.Int, IntVal: 2},
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", oldConfig, oldConfig, true),
+				newAddon("cluster2", oldConfig, oldConfig, true),
+				newAddon("cluster3", oldConfig, oldConfig, true),
+			},
+			expectedClusters: []string{"cluster1", "cluster2"},
+		},
+		{
+			name: "start rollout with default max concurrency",
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", oldConfig, oldConfig, true),
+				newAddon("cluster2", oldConfig, oldConfig, true),
+				newAddon("cluster3", oldConfig, oldConfig, true),
+				newAddon("cluster4", oldConfig, oldConfig, true),
+				newAddon("cluster5", oldConfig, oldConfig, true),
+			},
+			expectedClusters: []string{"cluster1", "cluster2"},
+		},
+		{
+			name:           "wait for the addons to apply the config",
+			maxConcurrency: &intstr.IntOrString{Type: intstr.String, StrVal: "50%"},
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", newConfig, oldConfig, true),
+				newAddon("cluster2", oldConfig, oldConfig, true),
+			},
+			expectedClusters: []string{"cluster1"},
+		},
+		{
+			name:           "wait for the addons to be available",
+			maxConcurrency: &intstr.IntOrString{Type: intstr.String, StrVal: "50%"},
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", newConfig, newConfig, false),
+				newAddon("cluster2", oldConfig, oldConfig, true),
+			},
+			expectedClusters: []string{"cluster1"},
+		},
+		{
+			name:           "rollout the next batch",
+			maxConcurrency: &intstr.IntOrString{Type: intstr.String, StrVal: "50%"},
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", newConfig, newConfig, true),
+				newAddon("cluster2", oldConfig, oldConfig, true),
+			},
+			expectedClusters: []string{"cluster2"},
+		},
+		{
+			name:           "invalid max concurrency",
+			maxConcurrency: &intstr.IntOrString{Type: intstr.String, StrVal: "a%"},
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", oldConfig, oldConfig, true),
+			},
+			expectedClusters: []string{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			graph := newGraph(nil, nil)
+			var clusters []string
+			for _, addon := range c.addons {
+				graph.addAddonNode(addon)
+				clusters = append(clusters, addon.Namespace)
+			}
+
+			rolloutStrategy := addonv1alpha1.RolloutStrategy{Type: addonv1alpha1.AddonRolloutStrategyRollingUpdate}
+			if c.maxConcurrency != nil {
+				rolloutStrategy.RollingUpdate = &addonv1alpha1.RollingUpdate{MaxConcurrency: *c.maxConcurrency}
+			}
+			placementRef := addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"}
+			graph.addPlacementNode(
+				addonv1alpha1.PlacementStrategy{PlacementRef: placementRef, RolloutStrategy: rolloutStrategy},
+				addonv1alpha1.InstallProgression{
+					PlacementRef: placementRef,
+					ConfigReferences: []addonv1alpha1.InstallConfigReference{
+						{ConfigGroupResource: fooGR, DesiredConfig: newConfig.DeepCopy()},
+					},
+				},
+				clusters, nil,
+			)
+
+			actual := []string{}
+			for _, addon := range graph.addonToUpdate() {
+				actual = append(actual, addon.mca.Namespace)
+			}
+			if !reflect.DeepEqual(actual, c.expectedClusters) {
+				t.Errorf("expected addons on clusters %v to update, but got %v", c.expectedClusters, actual)
+			}
+		})
+	}
+}
+
+func TestSegmentedRollingUpdateAddonToUpdate(t *testing.T) {
+	fooGR := addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"}
+	newConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash2"}
+	oldConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash1"}
+	segments := map[string]string{"cluster1": "a", "cluster2": "a", "cluster3": "b", "cluster4": "b"}
+
+	newAddon := func(cluster string, desired, lastApplied *addonv1alpha1.ConfigSpecHash, available bool) *addonv1alpha1.ManagedClusterAddOn {
+		addon := addontesting.NewAddon("test", cluster)
+		addon.Status.ConfigReferences = []addonv1alpha1.ConfigReference{{
+			ConfigGroupResource: fooGR,
+			ConfigReferent:      desired.ConfigReferent,
+			DesiredConfig:       desired.DeepCopy(),
+			LastAppliedConfig:   lastApplied.DeepCopy(),
+		}}
+		if available {
+			addon.Status.Conditions = []metav1.Condition{{
+				Type:   addonv1alpha1.ManagedClusterAddOnConditionAvailable,
+				Status: metav1.ConditionTrue,
+			}}
+		}
+		return addon
+	}
+
+	cases := []struct {
+		name             string
+		addons           []*addonv1alpha1.ManagedClusterAddOn
+		expectedClusters []string
+		expectedProgress string
+	}{
+		{
+			name: "start rollout in the first segment",
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", oldConfig, oldConfig, true),
+				newAddon("cluster2", oldConfig, oldConfig, true),
+				newAddon("cluster3", oldConfig, oldConfig, true),
+				newAddon("cluster4", oldConfig, oldConfig, true),
+				newAddon("cluster5", oldConfig, oldConfig, true),
+			},
+			expectedClusters: []string{"cluster1"},
+			expectedProgress: `segment "a" 0/2 completed, 0/3 segments completed`,
+		},
+		{
+			name: "wait for the addons in the segment",
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", newConfig, newConfig, false),
+				newAddon("cluster2", oldConfig, oldConfig, true),
+				newAddon("cluster3", oldConfig, oldConfig, true),
+				newAddon("cluster4", oldConfig, oldConfig, true),
+				newAddon("cluster5", oldConfig, oldConfig, true),
+			},
+			expectedClusters: []string{"cluster1"},
+			expectedProgress: `segment "a" 0/2 completed, 0/3 segments completed`,
+		},
+		{
+			name: "rollout the next batch in the segment",
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", newConfig, newConfig, true),
+				newAddon("cluster2", oldConfig, oldConfig, true),
+				newAddon("cluster3", oldConfig, oldConfig, true),
+				newAddon("cluster4", oldConfig, oldConfig, true),
+				newAddon("cluster5", oldConfig, oldConfig, true),
+			},
+			expectedClusters: []string{"cluster2"},
+			expectedProgress: `segment "a" 1/2 completed, 0/3 segments completed`,
+		},
+		{
+			name: "rollout the next segment",
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", newConfig, newConfig, true),
+				newAddon("cluster2", newConfig, newConfig, true),
+				newAddon("cluster3", oldConfig, oldConfig, true),
+				newAddon("cluster4", oldConfig, oldConfig, true),
+				newAddon("cluster5", oldConfig, oldConfig, true),
+			},
+			expectedClusters: []string{"cluster3"},
+			expectedProgress: `segment "b" 0/2 completed, 1/3 segments completed`,
+		},
+		{
+			name: "rollout the clusters without segment at last",
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", newConfig, newConfig, true),
+				newAddon("cluster2", newConfig, newConfig, true),
+				newAddon("cluster3", newConfig, newConfig, true),
+				newAddon("cluster4", newConfig, newConfig, true),
+				newAddon("cluster5", oldConfig, oldConfig, true),
+			},
+			expectedClusters: []string{"cluster5"},
+			expectedProgress: `segment "" 0/1 completed, 2/3 segments completed`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			graph := newGraph(nil, nil)
+			var clusters []string
+			for _, addon := range c.addons {
+				graph.addAddonNode(addon)
+				clusters = append(clusters, addon.Namespace)
+			}
+
+			placementRef := addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"}
+			graph.addPlacementNode(
+				addonv1alpha1.PlacementStrategy{
+					PlacementRef: placementRef,
+					RolloutStrategy: addonv1alpha1.RolloutStrategy{
+						Type:          addonv1alpha1.AddonRolloutStrategyRollingUpdate,
+						RollingUpdate: &addonv1alpha1.RollingUpdate{MaxConcurrency: intstr.FromString("50%")},
+					},
+				},
+				addonv1alpha1.InstallProgression{
+					PlacementRef: placementRef,
+					ConfigReferences: []addonv1alpha1.InstallConfigReference{
+						{ConfigGroupResource: fooGR, DesiredConfig: newConfig.DeepCopy()},
+					},
+				},
+				clusters, nil,
+			)
+			node := graph.getPlacementNodes()[placementRef]
+			node.segments = segments
+
+			actual := []string{}
+			for _, addon := range graph.addonToUpdate() {
+				actual = append(actual, addon.mca.Namespace)
+			}
+			if !reflect.DeepEqual(actual, c.expectedClusters) {
+				t.Errorf("expected addons on clusters %v to update, but got %v", c.expectedClusters, actual)
+			}
+			if progress := segmentProgress(node); progress != c.expectedProgress {
+				t.Errorf("expected progress %q, but got %q", c.expectedProgress, progress)
+			}
+		})
+	}
+}
+
+func TestCanaryAddonToUpdate(t *testing.T) {
+	fooGR := addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"}
+	newConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash2"}
+	oldConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash1"}
+
+	newAddon := func(cluster string, config *addonv1alpha1.ConfigSpecHash, available bool) *addonv1alpha1.ManagedClusterAddOn {
+		addon := addontesting.NewAddon("test", cluster)
+		addon.Status.ConfigReferences = []addonv1alpha1.ConfigReference{{
+			ConfigGroupResource: fooGR,
+			ConfigReferent:      config.ConfigReferent,
+			DesiredConfig:       config.DeepCopy(),
+			LastAppliedConfig:   config.DeepCopy(),
+		}}
+		status := metav1.ConditionFalse
+		if available {
+			status = metav1.ConditionTrue
+		}
+		addon.Status.Conditions = []metav1.Condition{{
+			Type:   addonv1alpha1.ManagedClusterAddOnConditionAvailable,
+			Status: status,
+		}}
+		return addon
+	}
+
+	cases := []struct {
+		name             string
+		lastKnownGood    *addonv1alpha1.ConfigSpecHash
+		canaryAvailable  bool
+		expectedClusters []string
+	}{
+		{
+			name:             "config has not passed the canary",
+			canaryAvailable:  true,
+			expectedClusters: []string{"canary1"},
+		},
+		{
+			name:             "roll out the last known good config",
+			lastKnownGood:    newConfig,
+			canaryAvailable:  true,
+			expectedClusters: []string{"cluster1", "canary1"},
+		},
+		{
+			name:             "hold the rollout when the canary regresses",
+			lastKnownGood:    newConfig,
+			canaryAvailable:  false,
+			expectedClusters: []string{"canary1"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			graph := newGraph(nil, nil)
+			graph.addAddonNode(newAddon("canary1", newConfig, c.canaryAvailable))
+			graph.addAddonNode(newAddon("cluster1", oldConfig, true))
+			graph.addAddonNode(newAddon("cluster2", oldConfig, true))
+
+			placementRef := addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"}
+			graph.addPlacementNode(
+				addonv1alpha1.PlacementStrategy{
+					PlacementRef: placementRef,
+					RolloutStrategy: addonv1alpha1.RolloutStrategy{
+						Type: addonv1alpha1.AddonRolloutStrategyRollingUpdateWithCanary,
+						RollingUpdateWithCanary: &addonv1alpha1.RollingUpdateWithCanary{
+							Placement:     addonv1alpha1.PlacementRef{Name: "canary", Namespace: "default"},
+							RollingUpdate: addonv1alpha1.RollingUpdate{MaxConcurrency: intstr.FromInt(1)},
+						},
+					},
+				},
+				addonv1alpha1.InstallProgression{
+					PlacementRef: placementRef,
+					ConfigReferences: []addonv1alpha1.InstallConfigReference{
+						{
+							ConfigGroupResource: fooGR,
+							DesiredConfig:       newConfig.DeepCopy(),
+							LastKnownGoodConfig: c.lastKnownGood.DeepCopy(),
+						},
+					},
+				},
+				[]string{"cluster1", "cluster2"}, []string{"canary1"},
+			)
+
+			actual := []string{}
+			for _, addon := range graph.addonToUpdate() {
+				actual = append(actual, addon.mca.Namespace)
+			}
+			if !reflect.DeepEqual(actual, c.expectedClusters) {
+				t.Errorf("expected addons on clusters %v to update, but got %v", c.expectedClusters, actual)
+			}
+		})
+	}
+}
+
 func newInstallConfigReference(group, resource, name, hash string) addonv1alpha1.InstallConfigReference {
 	return addonv1alpha1.InstallConfigReference{
 		ConfigGroupResource: addonv1alpha1.ConfigGroupResource{
@@ -0,0 +1,78 @@
+package addonconfiguration
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+const day = 24 * time.Hour
+
+// maintenanceWindow is a daily time window in UTC. The end is before the start if the window spans midnight.
+type maintenanceWindow struct {
+	start time.Duration
+	end   time.Duration
+}
+
+// newMaintenanceWindow returns the maintenance window from the annotation of the ClusterManagementAddOn.
+// It returns nil if the annotation is not set.
+func newMaintenanceWindow(cma *addonv1alpha1.ClusterManagementAddOn) (*maintenanceWindow, error) {
+	value, ok := cma.Annotations[constants.RolloutMaintenanceWindowAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	start, end, found := strings.Cut(value, "-")
+	if !found {
+		return nil, fmt.Errorf("invalid maintenance window %q, expected HH:MM-HH:MM", value)
+	}
+	startTime, err := parseTimeOfDay(start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance window %q: %v", value, err)
+	}
+	endTime, err := parseTimeOfDay(end)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance window %q: %v", value, err)
+	}
+	if startTime == endTime {
+		return nil, fmt.Errorf("invalid maintenance window %q, the start equals the end", value)
+	}
+
+	return &maintenanceWindow{start: startTime, end: endTime}, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// open checks whether the time is in the window.
+func (w *maintenanceWindow) open(now time.Time) bool {
+	offset := timeOfDay(now)
+	if w.start < w.end {
+		return offset >= w.start && offset < w.end
+	}
+	return offset >= w.start || offset < w.end
+}
+
+// nextTransition returns the duration from the time until the window opens, or until it closes if the
+// window is open.
+func (w *maintenanceWindow) nextTransition(now time.Time) time.Duration {
+	next := w.start
+	if w.open(now) {
+		next = w.end
+	}
+	return (next - timeOfDay(now) + day) % day
+}
+
+func timeOfDay(t time.Time) time.Duration {
+	t = t.UTC()
+	return t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+}
@@ -0,0 +1,148 @@
+package addonconfiguration
+
+import (
+	"testing"
+	"time"
+
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2023, 6, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name                   string
+		annotations            map[string]string
+		now                    time.Time
+		expectErr              bool
+		expectNil              bool
+		expectedOpen           bool
+		expectedNextTransition time.Duration
+	}{
+		{
+			name:      "no annotation",
+			expectNil: true,
+		},
+		{
+			name:        "invalid format",
+			annotations: map[string]string{constants.RolloutMaintenanceWindowAnnotationKey: "22:00"},
+			expectErr:   true,
+		},
+		{
+			name:        "invalid time",
+			annotations: map[string]string{constants.RolloutMaintenanceWindowAnnotationKey: "25:00-02:00"},
+			expectErr:   true,
+		},
+		{
+			name:        "empty window",
+			annotations: map[string]string{constants.RolloutMaintenanceWindowAnnotationKey: "02:00-02:00"},
+			expectErr:   true,
+		},
+		{
+			name:                   "in the window",
+			annotations:            map[string]string{constants.RolloutMaintenanceWindowAnnotationKey: "01:00-03:00"},
+			now:                    at(2, 30),
+			expectedOpen:           true,
+			expectedNextTransition: 30 * time.Minute,
+		},
+		{
+			name:                   "before the window",
+			annotations:            map[string]string{constants.RolloutMaintenanceWindowAnnotationKey: "01:00-03:00"},
+			now:                    at(0, 15),
+			expectedOpen:           false,
+			expectedNextTransition: 45 * time.Minute,
+		},
+		{
+			name:                   "after the window",
+			annotations:            map[string]string{constants.RolloutMaintenanceWindowAnnotationKey: "01:00-03:00"},
+			now:                    at(3, 0),
+			expectedOpen:           false,
+			expectedNextTransition: 22 * time.Hour,
+		},
+		{
+			name:                   "in the window spanning midnight",
+			annotations:            map[string]string{constants.RolloutMaintenanceWindowAnnotationKey: "22:00 - 04:00"},
+			now:                    at(23, 0),
+			expectedOpen:           true,
+			expectedNextTransition: 5 * time.Hour,
+		},
+		{
+			name:                   "out of the window spanning midnight",
+			annotations:            map[string]string{constants.RolloutMaintenanceWindowAnnotationKey: "22:00-04:00"},
+			now:                    at(12, 0),
+			expectedOpen:           false,
+			expectedNextTransition: 10 * time.Hour,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cma := addontesting.NewClusterManagementAddon("test", "", "").Build()
+			cma.Annotations = c.annotations
+
+			window, err := newMaintenanceWindow(cma)
+			if c.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectErr, err)
+			}
+			if c.expectErr {
+				return
+			}
+			if c.expectNil {
+				if window != nil {
+					t.Errorf("expected no maintenance window, but got %v", window)
+				}
+				return
+			}
+
+			if open := window.open(c.now); open != c.expectedOpen {
+				t.Errorf("expected open %v, but got %v", c.expectedOpen, open)
+			}
+			if next := window.nextTransition(c.now); next != c.expectedNextTransition {
+				t.Errorf("expected next transition in %v, but got %v", c.expectedNextTransition, next)
+			}
+		})
+	}
+}
+
+func TestOutOfMaintenanceWindowAddonToUpdate(t *testing.T) {
+	fooGR := addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"}
+	newConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash2"}
+	oldConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash1"}
+
+	graph := newGraph(nil, nil)
+	for cluster, config := range map[string]*addonv1alpha1.ConfigSpecHash{
+		"cluster1": newConfig, "cluster2": oldConfig,
+	} {
+		addon := addontesting.NewAddon("test", cluster)
+		addon.Status.ConfigReferences = []addonv1alpha1.ConfigReference{{
+			ConfigGroupResource: fooGR,
+			ConfigReferent:      config.ConfigReferent,
+			DesiredConfig:       config.DeepCopy(),
+		}}
+		graph.addAddonNode(addon)
+	}
+
+	placementRef := addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"}
+	graph.addPlacementNode(
+		addonv1alpha1.PlacementStrategy{PlacementRef: placementRef},
+		addonv1alpha1.InstallProgression{
+			PlacementRef: placementRef,
+			ConfigReferences: []addonv1alpha1.InstallConfigReference{
+				{ConfigGroupResource: fooGR, DesiredConfig: newConfig.DeepCopy()},
+			},
+		},
+		[]string{"cluster1", "cluster2"}, nil,
+	)
+	graph.getPlacementNodes()[placementRef].outOfMaintenanceWindow = true
+
+	// only the addon updating is continued, the addon has not started is held.
+	addons := graph.addonToUpdate()
+	if len(addons) != 1 || addons[0].mca.Namespace != "cluster1" {
+		t.Errorf("expected only the addon on cluster1 to update, but got %v", addons)
+	}
+}
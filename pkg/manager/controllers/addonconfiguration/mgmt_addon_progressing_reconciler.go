@@ -0,0 +1,194 @@
+package addonconfiguration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+)
+
+// clusterManagementAddonProgressingReconciler records the lastAppliedConfig and lastKnownGoodConfig of
+// each install progression once the desired config is applied on all the addons of the placement, and
+// surfaces the rollout progress of the placement in the Progressing condition of the install progression.
+type clusterManagementAddonProgressingReconciler struct {
+	addonClient       addonv1alpha1client.Interface
+	segmentStateStore SegmentStateStore
+}
+
+func (d *clusterManagementAddonProgressingReconciler) reconcile(
+	ctx context.Context, cma *addonv1alpha1.ClusterManagementAddOn,
+	graph *configurationGraph) (*addonv1alpha1.ClusterManagementAddOn, reconcileState, error) {
+	cmaCopy := cma.DeepCopy()
+	placementNodes := graph.getPlacementNodes()
+	rollback, err := newRollbackOptions(cma)
+	if err != nil {
+		klog.Warningf("Automatic rollback of addon %s is disabled: %v", cma.Name, err)
+	}
+
+	for i, installProgression := range cmaCopy.Status.InstallProgressions {
+		node, ok := placementNodes[installProgression.PlacementRef]
+		if !ok {
+			continue
+		}
+
+		setProgressingCondition(&cmaCopy.Status.InstallProgressions[i], node, graph)
+		setRolloutSegmentCondition(&cmaCopy.Status.InstallProgressions[i], node)
+
+		for j, configReference := range installProgression.ConfigReferences {
+			if configReference.DesiredConfig == nil || configReference.DesiredConfig.SpecHash == "" {
+				continue
+			}
+
+			if node.rolledBack {
+				// the desired config failed, only record the rollback of the last known good config.
+				lastKnownGood := configReference.LastKnownGoodConfig
+				if lastKnownGood != nil && node.configApplied(configReference.ConfigGroupResource, lastKnownGood) {
+					cmaCopy.Status.InstallProgressions[i].ConfigReferences[j].LastAppliedConfig = lastKnownGood.DeepCopy()
+				}
+				continue
+			}
+
+			if node.rolloutStrategy.Type == addonv1alpha1.AddonRolloutStrategyRollingUpdateWithCanary {
+				setCanaryConfigReference(&cmaCopy.Status.InstallProgressions[i].ConfigReferences[j], node, graph)
+				continue
+			}
+
+			if !node.configApplied(configReference.ConfigGroupResource, configReference.DesiredConfig) {
+				continue
+			}
+
+			// for rollout with type UpdateAll or RollingUpdate, the lastKnownGoodConfig is
+			// the same as lastAppliedConfig. If the automatic rollback is enabled, the config
+			// is known good only after the addons are available.
+			cmaCopy.Status.InstallProgressions[i].ConfigReferences[j].LastAppliedConfig = configReference.DesiredConfig.DeepCopy()
+			if rollback == nil || node.configSucceeded(configReference.ConfigGroupResource, configReference.DesiredConfig) {
+				cmaCopy.Status.InstallProgressions[i].ConfigReferences[j].LastKnownGoodConfig = configReference.DesiredConfig.DeepCopy()
+			}
+		}
+
+		if rollback != nil && !node.rolledBack {
+			rollback.setRolloutFailedCondition(&cmaCopy.Status.InstallProgressions[i], node)
+		}
+	}
+
+	if err := d.patchMgmtAddonStatus(ctx, cmaCopy, cma); err != nil {
+		return cmaCopy, reconcileContinue, err
+	}
+
+	err = d.saveSegmentStates(ctx, cmaCopy, placementNodes)
+	return cmaCopy, reconcileContinue, err
+}
+
+// saveSegmentStates saves the states of the segmented rollouts of the placement nodes in the store.
+func (d *clusterManagementAddonProgressingReconciler) saveSegmentStates(ctx context.Context,
+	cma *addonv1alpha1.ClusterManagementAddOn, placementNodes map[addonv1alpha1.PlacementRef]*installStrategyNode) error {
+	if d.segmentStateStore == nil {
+		return nil
+	}
+
+	states := map[addonv1alpha1.PlacementRef]SegmentState{}
+	for placementRef, node := range placementNodes {
+		if node.segmentState != nil {
+			states[placementRef] = *node.segmentState
+		}
+	}
+	return d.segmentStateStore.Save(ctx, cma, states)
+}
+
+// setCanaryConfigReference sets the lastKnownGoodConfig of the config reference to the desired config once
+// the canary passes, and the lastAppliedConfig once the lastKnownGoodConfig is applied on all the addons
+// of the placement. If the desired config changes during a rollout, the lastKnownGoodConfig is not updated
+// until the current rollout is done.
+func setCanaryConfigReference(configReference *addonv1alpha1.InstallConfigReference,
+	node *installStrategyNode, graph *configurationGraph) {
+	lastKnownGood := configReference.LastKnownGoodConfig
+	rolloutDone := lastKnownGood == nil ||
+		(configReference.LastAppliedConfig != nil && *configReference.LastAppliedConfig == *lastKnownGood)
+	if rolloutDone && graph.canaryPassed(node, configReference.ConfigGroupResource, configReference.DesiredConfig) {
+		configReference.LastKnownGoodConfig = configReference.DesiredConfig.DeepCopy()
+	}
+
+	if lastKnownGood != nil && node.configApplied(configReference.ConfigGroupResource, lastKnownGood) {
+		configReference.LastAppliedConfig = lastKnownGood.DeepCopy()
+	}
+}
+
+// setProgressingCondition sets the Progressing condition of the install progression with the state of the
+// rollout of the addons in the placement, see progressingState for the states and their transitions.
+func setProgressingCondition(installProgression *addonv1alpha1.InstallProgression,
+	node *installStrategyNode, graph *configurationGraph) {
+	state, message := nextProgressingState(node, graph)
+	meta.SetStatusCondition(&installProgression.Conditions, state.condition(message))
+}
+
+// segmentProgress returns the progress of the rollout segments of the node, ex:
+// segment "us-east" 2/4 completed, 1/3 segments completed
+func segmentProgress(node *installStrategyNode) string {
+	segments := node.orderedSegments()
+	current, clusters := node.currentSegment()
+
+	segmentsCompleted := 0
+	for _, segment := range segments {
+		if segment == current {
+			break
+		}
+		segmentsCompleted++
+	}
+
+	completed := 0
+	for _, cluster := range clusters {
+		if node.children[cluster].rolloutStatus() == succeeded {
+			completed++
+		}
+	}
+
+	return fmt.Sprintf("segment %q %d/%d completed, %d/%d segments completed",
+		current, completed, len(clusters), segmentsCompleted, len(segments))
+}
+
+func (d *clusterManagementAddonProgressingReconciler) patchMgmtAddonStatus(ctx context.Context, new, old *addonv1alpha1.ClusterManagementAddOn) error {
+	if equality.Semantic.DeepEqual(new.Status.InstallProgressions, old.Status.InstallProgressions) {
+		return nil
+	}
+
+	oldData, err := json.Marshal(&addonv1alpha1.ClusterManagementAddOn{
+		Status: addonv1alpha1.ClusterManagementAddOnStatus{
+			InstallProgressions: old.Status.InstallProgressions,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	newData, err := json.Marshal(&addonv1alpha1.ClusterManagementAddOn{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:             new.UID,
+			ResourceVersion: new.ResourceVersion,
+		},
+		Status: addonv1alpha1.ClusterManagementAddOnStatus{
+			InstallProgressions: new.Status.InstallProgressions,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	patchBytes, err := jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return fmt.Errorf("failed to create patch for clustermanagementaddon %s: %w", new.Name, err)
+	}
+
+	klog.V(2).Infof("Patching clustermanagementaddon %s install progression with %s", new.Name, string(patchBytes))
+	_, err = d.addonClient.AddonV1alpha1().ClusterManagementAddOns().Patch(
+		ctx, new.Name, types.MergePatchType, patchBytes, metav1.PatchOptions{}, "status")
+	return err
+}
@@ -0,0 +1,329 @@
+package addonconfiguration
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/index"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	fakecluster "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterv1informers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
+)
+
+func TestMgmtAddonProgressingReconcile(t *testing.T) {
+	fooGR := addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"}
+	desired := &addonv1alpha1.ConfigSpecHash{
+		ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test1"},
+		SpecHash:       "hash1",
+	}
+	placements := []runtime.Object{
+		&clusterv1beta1.Placement{ObjectMeta: metav1.ObjectMeta{Name: "test-placement", Namespace: "default"}},
+	}
+	placementDecisions := []runtime.Object{
+		&clusterv1beta1.PlacementDecision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-placement",
+				Namespace: "default",
+				Labels:    map[string]string{clusterv1beta1.PlacementLabel: "test-placement"},
+			},
+			Status: clusterv1beta1.PlacementDecisionStatus{
+				Decisions: []clusterv1beta1.ClusterDecision{{ClusterName: "cluster1"}, {ClusterName: "cluster2"}},
+			},
+		},
+	}
+	cma := addontesting.NewClusterManagementAddon("test", "", "").WithPlacementStrategy(addonv1alpha1.PlacementStrategy{
+		PlacementRef: addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"},
+	}).WithInstallProgression(addonv1alpha1.InstallProgression{
+		PlacementRef: addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"},
+		ConfigReferences: []addonv1alpha1.InstallConfigReference{
+			{
+				ConfigGroupResource: fooGR,
+				DesiredConfig:       desired.DeepCopy(),
+			},
+		},
+	}).Build()
+	cma.Spec.InstallStrategy.Type = addonv1alpha1.AddonInstallStrategyPlacements
+
+	appliedConfigReference := func(lastApplied *addonv1alpha1.ConfigSpecHash) []addonv1alpha1.ConfigReference {
+		return []addonv1alpha1.ConfigReference{{
+			ConfigGroupResource: fooGR,
+			ConfigReferent:      desired.ConfigReferent,
+			DesiredConfig:       desired.DeepCopy(),
+			LastAppliedConfig:   lastApplied,
+		}}
+	}
+
+	availableAddon := func(cluster string) *addonv1alpha1.ManagedClusterAddOn {
+		addon := newManagedClusterAddon("test", cluster, nil, appliedConfigReference(desired.DeepCopy()))
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:   addonv1alpha1.ManagedClusterAddOnConditionAvailable,
+			Status: metav1.ConditionTrue,
+			Reason: "test",
+		})
+		return addon
+	}
+
+	cases := []struct {
+		name                 string
+		managedClusteraddon  []runtime.Object
+		validateAddonActions func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name: "not all the addons applied the config",
+			managedClusteraddon: []runtime.Object{
+				newManagedClusterAddon("test", "cluster1", nil, appliedConfigReference(desired.DeepCopy())),
+				newManagedClusterAddon("test", "cluster2", nil, appliedConfigReference(nil)),
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				expectPatchInstallProgressionAction(t, actions[0], nil, constants.ProgressingReasonUpgrading)
+			},
+		},
+		{
+			name: "an addon applied an old config",
+			managedClusteraddon: []runtime.Object{
+				newManagedClusterAddon("test", "cluster1", nil, appliedConfigReference(desired.DeepCopy())),
+				newManagedClusterAddon("test", "cluster2", nil, appliedConfigReference(&addonv1alpha1.ConfigSpecHash{
+					ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test1"},
+					SpecHash:       "hash0",
+				})),
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				expectPatchInstallProgressionAction(t, actions[0], nil, constants.ProgressingReasonUpgrading)
+			},
+		},
+		{
+			name: "addon overriding the config is not counted",
+			managedClusteraddon: []runtime.Object{
+				newManagedClusterAddon("test", "cluster1", nil, appliedConfigReference(desired.DeepCopy())),
+				newManagedClusterAddon("test", "cluster2", []addonv1alpha1.AddOnConfig{{
+					ConfigGroupResource: fooGR,
+					ConfigReferent:      addonv1alpha1.ConfigReferent{Name: "test2"},
+				}}, nil),
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				expectPatchInstallProgressionAction(t, actions[0], desired, constants.ProgressingReasonUpgrading)
+			},
+		},
+		{
+			name: "all the addons applied the config",
+			managedClusteraddon: []runtime.Object{
+				newManagedClusterAddon("test", "cluster1", nil, appliedConfigReference(desired.DeepCopy())),
+				newManagedClusterAddon("test", "cluster2", nil, appliedConfigReference(desired.DeepCopy())),
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				expectPatchInstallProgressionAction(t, actions[0], desired, constants.ProgressingReasonUpgrading)
+			},
+		},
+		{
+			name: "all the addons applied the config and are available",
+			managedClusteraddon: []runtime.Object{
+				availableAddon("cluster1"),
+				availableAddon("cluster2"),
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				expectPatchInstallProgressionAction(t, actions[0], desired, constants.ProgressingReasonUpgradeSucceed)
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clusterObj := append(placements, placementDecisions...)
+			fakeClusterClient := fakecluster.NewSimpleClientset(clusterObj...)
+			fakeAddonClient := fakeaddon.NewSimpleClientset(append(c.managedClusteraddon, cma)...)
+
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+			clusterInformers := clusterv1informers.NewSharedInformerFactory(fakeClusterClient, 10*time.Minute)
+
+			err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().AddIndexers(
+				cache.Indexers{
+					index.ManagedClusterAddonByName: index.IndexManagedClusterAddonByName,
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, obj := range placements {
+				if err := clusterInformers.Cluster().V1beta1().Placements().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, obj := range placementDecisions {
+				if err := clusterInformers.Cluster().V1beta1().PlacementDecisions().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, obj := range c.managedClusteraddon {
+				if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			controller := &addonConfigurationController{
+				managedClusterAddonIndexer: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetIndexer(),
+				placementDecisionLister:    clusterInformers.Cluster().V1beta1().PlacementDecisions().Lister(),
+				placementLister:            clusterInformers.Cluster().V1beta1().Placements().Lister(),
+			}
+
+			reconcile := &clusterManagementAddonProgressingReconciler{
+				addonClient: fakeAddonClient,
+			}
+
+			graph, err := controller.buildConfigurationGraph(cma)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fakeAddonClient.ClearActions()
+			_, _, err = reconcile.reconcile(context.TODO(), cma, graph)
+			if err != nil {
+				t.Errorf("expected no error when sync: %v", err)
+			}
+
+			c.validateAddonActions(t, fakeAddonClient.Actions())
+		})
+	}
+}
+
+func expectPatchInstallProgressionAction(t *testing.T, action clienttesting.Action, expected *addonv1alpha1.ConfigSpecHash, expectedReason string) {
+	patch := action.(clienttesting.PatchActionImpl).GetPatch()
+	cma := &addonv1alpha1.ClusterManagementAddOn{}
+	err := json.Unmarshal(patch, cma)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configReference := cma.Status.InstallProgressions[0].ConfigReferences[0]
+	if !apiequality.Semantic.DeepEqual(configReference.LastAppliedConfig, expected) {
+		t.Errorf("LastAppliedConfig not correctly patched, expected %v, actual %v", expected, configReference.LastAppliedConfig)
+	}
+	if !apiequality.Semantic.DeepEqual(configReference.LastKnownGoodConfig, expected) {
+		t.Errorf("LastKnownGoodConfig not correctly patched, expected %v, actual %v", expected, configReference.LastKnownGoodConfig)
+	}
+
+	cond := meta.FindStatusCondition(cma.Status.InstallProgressions[0].Conditions, addonv1alpha1.ManagedClusterAddOnConditionProgressing)
+	if cond == nil || cond.Reason != expectedReason {
+		t.Errorf("Progressing condition not correctly patched, expected reason %s, actual %v", expectedReason, cond)
+	}
+}
+
+func TestSetCanaryConfigReference(t *testing.T) {
+	fooGR := addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"}
+	config1 := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash1"}
+	config2 := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash2"}
+
+	newAddon := func(cluster string, lastApplied *addonv1alpha1.ConfigSpecHash) *addonv1alpha1.ManagedClusterAddOn {
+		addon := newManagedClusterAddon("test", cluster, nil, []addonv1alpha1.ConfigReference{{
+			ConfigGroupResource: fooGR,
+			ConfigReferent:      lastApplied.ConfigReferent,
+			DesiredConfig:       lastApplied.DeepCopy(),
+			LastAppliedConfig:   lastApplied.DeepCopy(),
+		}})
+		meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+			Type:   addonv1alpha1.ManagedClusterAddOnConditionAvailable,
+			Status: metav1.ConditionTrue,
+			Reason: "test",
+		})
+		return addon
+	}
+
+	cases := []struct {
+		name                  string
+		canaryApplied         *addonv1alpha1.ConfigSpecHash
+		clusterApplied        *addonv1alpha1.ConfigSpecHash
+		configReference       addonv1alpha1.InstallConfigReference
+		expectedLastKnownGood *addonv1alpha1.ConfigSpecHash
+		expectedLastApplied   *addonv1alpha1.ConfigSpecHash
+	}{
+		{
+			name:           "canary has not applied the desired config",
+			canaryApplied:  config1,
+			clusterApplied: config1,
+			configReference: addonv1alpha1.InstallConfigReference{
+				ConfigGroupResource: fooGR,
+				DesiredConfig:       config2,
+			},
+		},
+		{
+			name:           "canary passed",
+			canaryApplied:  config2,
+			clusterApplied: config1,
+			configReference: addonv1alpha1.InstallConfigReference{
+				ConfigGroupResource: fooGR,
+				DesiredConfig:       config2,
+			},
+			expectedLastKnownGood: config2,
+		},
+		{
+			name:           "last known good config is applied",
+			canaryApplied:  config2,
+			clusterApplied: config2,
+			configReference: addonv1alpha1.InstallConfigReference{
+				ConfigGroupResource: fooGR,
+				DesiredConfig:       config2,
+				LastKnownGoodConfig: config2,
+			},
+			expectedLastKnownGood: config2,
+			expectedLastApplied:   config2,
+		},
+		{
+			name:           "continue the current rollout when the desired config changes",
+			canaryApplied:  config2,
+			clusterApplied: config1,
+			configReference: addonv1alpha1.InstallConfigReference{
+				ConfigGroupResource: fooGR,
+				DesiredConfig:       config2,
+				LastKnownGoodConfig: config1.DeepCopy(),
+				LastAppliedConfig:   &addonv1alpha1.ConfigSpecHash{SpecHash: "hash0"},
+			},
+			expectedLastKnownGood: config1,
+			expectedLastApplied:   config1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			graph := newGraph(nil, nil)
+			graph.addAddonNode(newAddon("canary1", c.canaryApplied))
+			graph.addAddonNode(newAddon("cluster1", c.clusterApplied))
+
+			installProgression := addonv1alpha1.InstallProgression{
+				PlacementRef:     addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"},
+				ConfigReferences: []addonv1alpha1.InstallConfigReference{c.configReference},
+			}
+			graph.addPlacementNode(addonv1alpha1.PlacementStrategy{
+				PlacementRef: installProgression.PlacementRef,
+				RolloutStrategy: addonv1alpha1.RolloutStrategy{
+					Type: addonv1alpha1.AddonRolloutStrategyRollingUpdateWithCanary,
+				},
+			}, installProgression, []string{"cluster1"}, []string{"canary1"})
+
+			configReference := c.configReference.DeepCopy()
+			setCanaryConfigReference(configReference, graph.getPlacementNodes()[installProgression.PlacementRef], graph)
+			if !apiequality.Semantic.DeepEqual(configReference.LastKnownGoodConfig, c.expectedLastKnownGood) {
+				t.Errorf("expected lastKnownGoodConfig %v, but got %v", c.expectedLastKnownGood, configReference.LastKnownGoodConfig)
+			}
+			if c.expectedLastApplied != nil && !apiequality.Semantic.DeepEqual(configReference.LastAppliedConfig, c.expectedLastApplied) {
+				t.Errorf("expected lastAppliedConfig %v, but got %v", c.expectedLastApplied, configReference.LastAppliedConfig)
+			}
+		})
+	}
+}
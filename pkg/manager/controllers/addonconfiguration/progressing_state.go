@@ -0,0 +1,118 @@
+package addonconfiguration
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+// progressingState is the state of the rollout of an install progression, it is surfaced as the reason of
+// the Progressing condition of the install progression. The state is evaluated from the graph on each
+// reconcile, the first matching rule wins:
+//
+//	InvalidRolloutStrategy      the max concurrency of the rolling update is invalid. Leaves once the
+//	                            rollout strategy is fixed.
+//	RollingBack                 the RolloutFailed condition is set after the failures exceed the threshold,
+//	                            the addons are rolling back to the lastKnownGoodConfig.
+//	RolledBack                  all the addons have rolled back to the lastKnownGoodConfig and are available.
+//	                            Leaves to Upgrading once the RolloutFailed condition is removed.
+//	WaitingForCanary            the desired configs have not passed the canary placement yet.
+//	UpgradeSucceed              all the addons have applied the desired configs and are available.
+//	CanaryRegressed             the addons on the canary placement become unavailable, the rollout is held
+//	                            until they are available again.
+//	WaitingForMaintenanceWindow the rollout is out of the maintenance window, the addons not started are held
+//	                            until the window opens.
+//	Upgrading                   the addons are applying the desired configs.
+type progressingState string
+
+const (
+	progressingStateInvalidRolloutStrategy      progressingState = constants.ProgressingReasonInvalidRolloutStrategy
+	progressingStateRollingBack                 progressingState = constants.ProgressingReasonRollingBack
+	progressingStateRolledBack                  progressingState = constants.ProgressingReasonRolledBack
+	progressingStateWaitingForCanary            progressingState = constants.ProgressingReasonWaitingForCanary
+	progressingStateUpgradeSucceed              progressingState = constants.ProgressingReasonUpgradeSucceed
+	progressingStateCanaryRegressed             progressingState = constants.ProgressingReasonCanaryRegressed
+	progressingStateWaitingForMaintenanceWindow progressingState = constants.ProgressingReasonWaitingForMaintenanceWindow
+	progressingStateUpgrading                   progressingState = constants.ProgressingReasonUpgrading
+)
+
+// progressingStatus maps the states to the status of the Progressing condition. The status is False if the
+// rollout has stopped and needs no more reconcile or an action of the user, otherwise it is True.
+var progressingStatus = map[progressingState]metav1.ConditionStatus{
+	progressingStateInvalidRolloutStrategy:      metav1.ConditionFalse,
+	progressingStateRollingBack:                 metav1.ConditionTrue,
+	progressingStateRolledBack:                  metav1.ConditionFalse,
+	progressingStateWaitingForCanary:            metav1.ConditionTrue,
+	progressingStateUpgradeSucceed:              metav1.ConditionFalse,
+	progressingStateCanaryRegressed:             metav1.ConditionFalse,
+	progressingStateWaitingForMaintenanceWindow: metav1.ConditionTrue,
+	progressingStateUpgrading:                   metav1.ConditionTrue,
+}
+
+// condition returns the Progressing condition of the state with the message.
+func (s progressingState) condition(message string) metav1.Condition {
+	return metav1.Condition{
+		Type:    addonv1alpha1.ManagedClusterAddOnConditionProgressing,
+		Status:  progressingStatus[s],
+		Reason:  string(s),
+		Message: message,
+	}
+}
+
+// nextProgressingState evaluates the state of the rollout of the node and returns it with the message
+// of the Progressing condition.
+func nextProgressingState(node *installStrategyNode, graph *configurationGraph) (progressingState, string) {
+	if _, err := node.maxConcurrency(); node.rollingUpdate() != nil && err != nil {
+		return progressingStateInvalidRolloutStrategy, err.Error()
+	}
+
+	count := node.rolloutCount()
+	total := len(node.children)
+
+	if node.rolledBack {
+		if count[succeeded] == total {
+			return progressingStateRolledBack,
+				fmt.Sprintf("%d/%d rolled back to the last known good configs", count[succeeded], total)
+		}
+		return progressingStateRollingBack,
+			fmt.Sprintf("%d/%d rolled back to the last known good configs, %d updating, %d pending",
+				count[succeeded], total, count[updating], count[toApply])
+	}
+
+	canary := node.rolloutStrategy.Type == addonv1alpha1.AddonRolloutStrategyRollingUpdateWithCanary
+	if canary && node.noKnownGoodConfig {
+		return progressingStateWaitingForCanary,
+			"Waiting for the addons on the canary placement to apply the desired configs"
+	}
+
+	if count[succeeded] == total {
+		return progressingStateUpgradeSucceed, fmt.Sprintf("%d/%d completed", count[succeeded], total)
+	}
+
+	if canary && !graph.canaryAvailable(node) {
+		return progressingStateCanaryRegressed,
+			fmt.Sprintf("The addons on the canary placement are not available, the rollout is held at %d/%d completed",
+				count[succeeded], total)
+	}
+
+	message := fmt.Sprintf("%d/%d completed, %d updating, %d pending",
+		count[succeeded], total, count[updating], count[toApply])
+
+	if node.outOfMaintenanceWindow {
+		return progressingStateWaitingForMaintenanceWindow,
+			fmt.Sprintf("%s; out of the maintenance window, the pending addons are held", message)
+	}
+
+	if node.segments != nil && node.rollingUpdate() != nil {
+		message = fmt.Sprintf("%s; %s", message, segmentProgress(node))
+		if !node.segmentSoakUntil.IsZero() {
+			message = fmt.Sprintf("%s; the previous segment is soaking until %s",
+				message, node.segmentSoakUntil.UTC().Format(time.RFC3339))
+		}
+	}
+	return progressingStateUpgrading, message
+}
@@ -0,0 +1,211 @@
+package addonconfiguration
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func TestNextProgressingState(t *testing.T) {
+	fooGR := addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"}
+	newConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash2"}
+	oldConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash1"}
+
+	newAddon := func(cluster string, config *addonv1alpha1.ConfigSpecHash, available bool) *addonv1alpha1.ManagedClusterAddOn {
+		addon := addontesting.NewAddon("test", cluster)
+		addon.Status.ConfigReferences = []addonv1alpha1.ConfigReference{{
+			ConfigGroupResource: fooGR,
+			ConfigReferent:      config.ConfigReferent,
+			DesiredConfig:       config.DeepCopy(),
+			LastAppliedConfig:   config.DeepCopy(),
+		}}
+		status := metav1.ConditionFalse
+		if available {
+			status = metav1.ConditionTrue
+		}
+		addon.Status.Conditions = []metav1.Condition{{
+			Type:   addonv1alpha1.ManagedClusterAddOnConditionAvailable,
+			Status: status,
+		}}
+		return addon
+	}
+
+	rollingUpdate := addonv1alpha1.RolloutStrategy{
+		Type:          addonv1alpha1.AddonRolloutStrategyRollingUpdate,
+		RollingUpdate: &addonv1alpha1.RollingUpdate{MaxConcurrency: intstr.FromInt(1)},
+	}
+	canary := addonv1alpha1.RolloutStrategy{
+		Type: addonv1alpha1.AddonRolloutStrategyRollingUpdateWithCanary,
+		RollingUpdateWithCanary: &addonv1alpha1.RollingUpdateWithCanary{
+			Placement:     addonv1alpha1.PlacementRef{Name: "canary", Namespace: "default"},
+			RollingUpdate: addonv1alpha1.RollingUpdate{MaxConcurrency: intstr.FromInt(1)},
+		},
+	}
+
+	cases := []struct {
+		name                   string
+		rolloutStrategy        addonv1alpha1.RolloutStrategy
+		lastKnownGood          *addonv1alpha1.ConfigSpecHash
+		rolloutFailed          bool
+		outOfMaintenanceWindow bool
+		clusterConfig          *addonv1alpha1.ConfigSpecHash
+		clusterAvailable       bool
+		canaryAvailable        bool
+		expectedState          progressingState
+		expectedStatus         metav1.ConditionStatus
+	}{
+		{
+			name: "invalid rollout strategy",
+			rolloutStrategy: addonv1alpha1.RolloutStrategy{
+				Type:          addonv1alpha1.AddonRolloutStrategyRollingUpdate,
+				RollingUpdate: &addonv1alpha1.RollingUpdate{MaxConcurrency: intstr.FromString("a%")},
+			},
+			clusterConfig:    oldConfig,
+			clusterAvailable: true,
+			expectedState:    progressingStateInvalidRolloutStrategy,
+			expectedStatus:   metav1.ConditionFalse,
+		},
+		{
+			name:             "upgrading",
+			rolloutStrategy:  rollingUpdate,
+			clusterConfig:    oldConfig,
+			clusterAvailable: true,
+			expectedState:    progressingStateUpgrading,
+			expectedStatus:   metav1.ConditionTrue,
+		},
+		{
+			name:             "upgrade succeeded",
+			rolloutStrategy:  rollingUpdate,
+			clusterConfig:    newConfig,
+			clusterAvailable: true,
+			expectedState:    progressingStateUpgradeSucceed,
+			expectedStatus:   metav1.ConditionFalse,
+		},
+		{
+			name:                   "waiting for the maintenance window",
+			rolloutStrategy:        rollingUpdate,
+			outOfMaintenanceWindow: true,
+			clusterConfig:          oldConfig,
+			clusterAvailable:       true,
+			expectedState:          progressingStateWaitingForMaintenanceWindow,
+			expectedStatus:         metav1.ConditionTrue,
+		},
+		{
+			name:                   "upgrade succeeded out of the maintenance window",
+			rolloutStrategy:        rollingUpdate,
+			outOfMaintenanceWindow: true,
+			clusterConfig:          newConfig,
+			clusterAvailable:       true,
+			expectedState:          progressingStateUpgradeSucceed,
+			expectedStatus:         metav1.ConditionFalse,
+		},
+		{
+			name:             "waiting for canary",
+			rolloutStrategy:  canary,
+			clusterConfig:    oldConfig,
+			clusterAvailable: true,
+			canaryAvailable:  true,
+			expectedState:    progressingStateWaitingForCanary,
+			expectedStatus:   metav1.ConditionTrue,
+		},
+		{
+			name:             "upgrading after canary passed",
+			rolloutStrategy:  canary,
+			lastKnownGood:    newConfig,
+			clusterConfig:    oldConfig,
+			clusterAvailable: true,
+			canaryAvailable:  true,
+			expectedState:    progressingStateUpgrading,
+			expectedStatus:   metav1.ConditionTrue,
+		},
+		{
+			name:             "canary regressed",
+			rolloutStrategy:  canary,
+			lastKnownGood:    newConfig,
+			clusterConfig:    oldConfig,
+			clusterAvailable: true,
+			canaryAvailable:  false,
+			expectedState:    progressingStateCanaryRegressed,
+			expectedStatus:   metav1.ConditionFalse,
+		},
+		{
+			name:                   "rolling back out of the maintenance window",
+			rolloutStrategy:        rollingUpdate,
+			lastKnownGood:          oldConfig,
+			rolloutFailed:          true,
+			outOfMaintenanceWindow: true,
+			clusterConfig:          newConfig,
+			clusterAvailable:       false,
+			expectedState:          progressingStateRollingBack,
+			expectedStatus:         metav1.ConditionTrue,
+		},
+		{
+			name:             "rolled back",
+			rolloutStrategy:  rollingUpdate,
+			lastKnownGood:    oldConfig,
+			rolloutFailed:    true,
+			clusterConfig:    oldConfig,
+			clusterAvailable: true,
+			expectedState:    progressingStateRolledBack,
+			expectedStatus:   metav1.ConditionFalse,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			graph := newGraph(nil, nil)
+			graph.addAddonNode(newAddon("canary1", newConfig, c.canaryAvailable))
+			graph.addAddonNode(newAddon("cluster1", c.clusterConfig, c.clusterAvailable))
+			graph.addAddonNode(newAddon("cluster2", c.clusterConfig, c.clusterAvailable))
+
+			installProgression := addonv1alpha1.InstallProgression{
+				PlacementRef: addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"},
+				ConfigReferences: []addonv1alpha1.InstallConfigReference{
+					{
+						ConfigGroupResource: fooGR,
+						DesiredConfig:       newConfig.DeepCopy(),
+						LastKnownGoodConfig: c.lastKnownGood.DeepCopy(),
+					},
+				},
+			}
+			if c.rolloutFailed {
+				installProgression.Conditions = []metav1.Condition{{
+					Type:   constants.InstallProgressionConditionRolloutFailed,
+					Status: metav1.ConditionTrue,
+					Reason: constants.RolloutFailedReasonFailureThresholdExceeded,
+				}}
+			}
+
+			graph.addPlacementNode(
+				addonv1alpha1.PlacementStrategy{
+					PlacementRef:    installProgression.PlacementRef,
+					RolloutStrategy: c.rolloutStrategy,
+				},
+				installProgression,
+				[]string{"cluster1", "cluster2"}, []string{"canary1"},
+			)
+			node := graph.getPlacementNodes()[installProgression.PlacementRef]
+			node.outOfMaintenanceWindow = c.outOfMaintenanceWindow
+
+			setProgressingCondition(&installProgression, node, graph)
+			var cond *metav1.Condition
+			for i := range installProgression.Conditions {
+				if installProgression.Conditions[i].Type == addonv1alpha1.ManagedClusterAddOnConditionProgressing {
+					cond = &installProgression.Conditions[i]
+				}
+			}
+			if cond == nil {
+				t.Fatalf("expected Progressing condition to be set")
+			}
+			if cond.Reason != string(c.expectedState) || cond.Status != c.expectedStatus {
+				t.Errorf("expected Progressing condition %s/%s, but got %s/%s: %s",
+					c.expectedStatus, c.expectedState, cond.Status, cond.Reason, cond.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package addonconfiguration
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+const defaultRollbackTimeout = 10 * time.Minute
+
+// rollbackOptions is the options of the automatic rollback of failed rollouts.
+type rollbackOptions struct {
+	failureThreshold intstr.IntOrString
+	timeout          time.Duration
+}
+
+// newRollbackOptions returns the rollback options from the annotations of the ClusterManagementAddOn.
+// It returns nil if the automatic rollback is not enabled.
+func newRollbackOptions(cma *addonv1alpha1.ClusterManagementAddOn) (*rollbackOptions, error) {
+	threshold, ok := cma.Annotations[constants.RollbackFailureThresholdAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	options := &rollbackOptions{
+		failureThreshold: intstr.Parse(threshold),
+		timeout:          defaultRollbackTimeout,
+	}
+	if _, err := intstr.GetScaledValueFromIntOrPercent(&options.failureThreshold, 100, false); err != nil {
+		return nil, fmt.Errorf("invalid rollback failure threshold %q: %v", threshold, err)
+	}
+
+	if timeout, ok := cma.Annotations[constants.RollbackTimeoutAnnotationKey]; ok {
+		duration, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rollback timeout %q: %v", timeout, err)
+		}
+		options.timeout = duration
+	}
+
+	return options, nil
+}
+
+// setRolloutFailedCondition sets the RolloutFailed condition of the install progression if more addons than
+// the failure threshold failed the health check after adopting the desired configs. An addon is failed if it
+// has been unavailable longer than the timeout since it adopted the desired configs, so the addons unavailable
+// before the rollout are not failed at once. The addons of the node are rolled back to the last known good
+// configs once the condition is set.
+func (o *rollbackOptions) setRolloutFailedCondition(installProgression *addonv1alpha1.InstallProgression, node *installStrategyNode) {
+	if !desiredConfigChanged(installProgression) {
+		return
+	}
+
+	total := len(node.children)
+	threshold, _ := intstr.GetScaledValueFromIntOrPercent(&o.failureThreshold, total, false)
+
+	now := time.Now()
+	failed := 0
+	for _, addon := range node.children {
+		if unavailable, ok := addon.unavailableSinceAdopted(now); ok && unavailable > o.timeout {
+			failed++
+		}
+	}
+
+	if failed <= threshold {
+		return
+	}
+
+	meta.SetStatusCondition(&installProgression.Conditions, metav1.Condition{
+		Type:   constants.InstallProgressionConditionRolloutFailed,
+		Status: metav1.ConditionTrue,
+		Reason: constants.RolloutFailedReasonFailureThresholdExceeded,
+		Message: fmt.Sprintf("%d/%d addons are unavailable longer than %s after adopting the desired configs, "+
+			"rolled back to the last known good configs", failed, total, o.timeout),
+	})
+}
+
+// timeoutRemaining returns the shortest time left before an unavailable addon of the node exceeds the timeout
+// after adopting the desired configs, so the rollout is checked again once the addon fails. It returns 0 if no
+// addon is within the timeout.
+func (o *rollbackOptions) timeoutRemaining(installProgression *addonv1alpha1.InstallProgression,
+	node *installStrategyNode, now time.Time) time.Duration {
+	if node.rolledBack || !desiredConfigChanged(installProgression) {
+		return 0
+	}
+
+	var shortest time.Duration
+	for _, addon := range node.children {
+		unavailable, ok := addon.unavailableSinceAdopted(now)
+		if !ok || unavailable > o.timeout {
+			continue
+		}
+		// requeue right after the timeout, the addon is failed only once it is exceeded
+		remaining := o.timeout - unavailable + time.Second
+		if shortest == 0 || remaining < shortest {
+			shortest = remaining
+		}
+	}
+	return shortest
+}
+
+// desiredConfigChanged returns true if a desired config of the install progression is changed from its last
+// known good config, otherwise there is nothing to roll back.
+func desiredConfigChanged(installProgression *addonv1alpha1.InstallProgression) bool {
+	for _, configReference := range installProgression.ConfigReferences {
+		if configReference.DesiredConfig != nil && configReference.LastKnownGoodConfig != nil &&
+			*configReference.DesiredConfig != *configReference.LastKnownGoodConfig {
+			return true
+		}
+	}
+	return false
+}
+
+// unavailableSinceAdopted returns how long the addon has been unavailable since it adopted the desired configs.
+// It returns false if the addon has not adopted the desired configs or is available.
+func (n *addonNode) unavailableSinceAdopted(now time.Time) (time.Duration, bool) {
+	adoptedTime, adopted := n.adoptedTime()
+	if !adopted {
+		return 0, false
+	}
+
+	available := meta.FindStatusCondition(n.mca.Status.Conditions, addonv1alpha1.ManagedClusterAddOnConditionAvailable)
+	if available == nil || available.Status == metav1.ConditionTrue {
+		return 0, false
+	}
+	unavailableTime := available.LastTransitionTime.Time
+	if unavailableTime.Before(adoptedTime) {
+		unavailableTime = adoptedTime
+	}
+	return now.Sub(unavailableTime), true
+}
+
+// adoptedTime returns the time the addon adopted its desired configs, which is when the Progressing condition of
+// the addon became False after the deploy works applied the desired config spec hashes. It returns false if the
+// addon has not applied the desired configs yet.
+func (n *addonNode) adoptedTime() (time.Time, bool) {
+	if n.rolloutStatus() == toApply {
+		return time.Time{}, false
+	}
+
+	for gr, desired := range n.desiredConfigs {
+		applied := false
+		for _, configRef := range n.mca.Status.ConfigReferences {
+			if configRef.ConfigGroupResource == gr && configRef.LastAppliedConfig != nil &&
+				*configRef.LastAppliedConfig == *desired.DesiredConfig {
+				applied = true
+				break
+			}
+		}
+		if !applied {
+			return time.Time{}, false
+		}
+	}
+
+	progressing := meta.FindStatusCondition(n.mca.Status.Conditions, addonv1alpha1.ManagedClusterAddOnConditionProgressing)
+	if progressing == nil || progressing.Status == metav1.ConditionTrue {
+		return time.Time{}, false
+	}
+	return progressing.LastTransitionTime.Time, true
+}
@@ -0,0 +1,313 @@
+package addonconfiguration
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func TestNewRollbackOptions(t *testing.T) {
+	cases := []struct {
+		name            string
+		annotations     map[string]string
+		expectedEnabled bool
+		expectedErr     bool
+		expectedTimeout time.Duration
+	}{
+		{
+			name:            "rollback is not enabled",
+			annotations:     map[string]string{},
+			expectedEnabled: false,
+		},
+		{
+			name: "rollback with default timeout",
+			annotations: map[string]string{
+				constants.RollbackFailureThresholdAnnotationKey: "20%",
+			},
+			expectedEnabled: true,
+			expectedTimeout: defaultRollbackTimeout,
+		},
+		{
+			name: "rollback with timeout",
+			annotations: map[string]string{
+				constants.RollbackFailureThresholdAnnotationKey: "2",
+				constants.RollbackTimeoutAnnotationKey:          "5m",
+			},
+			expectedEnabled: true,
+			expectedTimeout: 5 * time.Minute,
+		},
+		{
+			name: "invalid threshold",
+			annotations: map[string]string{
+				constants.RollbackFailureThresholdAnnotationKey: "a%",
+			},
+			expectedErr: true,
+		},
+		{
+			name: "invalid timeout",
+			annotations: map[string]string{
+				constants.RollbackFailureThresholdAnnotationKey: "20%",
+				constants.RollbackTimeoutAnnotationKey:          "5",
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cma := addontesting.NewClusterManagementAddon("test", "", "").Build()
+			cma.Annotations = c.annotations
+
+			options, err := newRollbackOptions(cma)
+			if (err != nil) != c.expectedErr {
+				t.Fatalf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if (options != nil) != c.expectedEnabled {
+				t.Fatalf("expected rollback enabled %v, but got %v", c.expectedEnabled, options)
+			}
+			if options != nil && options.timeout != c.expectedTimeout {
+				t.Errorf("expected timeout %v, but got %v", c.expectedTimeout, options.timeout)
+			}
+		})
+	}
+}
+
+func TestRollback(t *testing.T) {
+	fooGR := addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"}
+	newConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash2"}
+	oldConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash1"}
+
+	// newAddon returns an addon adopted the config the adopted duration ago, and available or not since the duration
+	newAddon := func(cluster string, config *addonv1alpha1.ConfigSpecHash, available bool, since, adopted time.Duration) *addonv1alpha1.ManagedClusterAddOn {
+		addon := addontesting.NewAddon("test", cluster)
+		addon.Status.ConfigReferences = []addonv1alpha1.ConfigReference{{
+			ConfigGroupResource: fooGR,
+			ConfigReferent:      config.ConfigReferent,
+			DesiredConfig:       config.DeepCopy(),
+			LastAppliedConfig:   config.DeepCopy(),
+		}}
+		status := metav1.ConditionFalse
+		if available {
+			status = metav1.ConditionTrue
+		}
+		addon.Status.Conditions = []metav1.Condition{{
+			Type:               addonv1alpha1.ManagedClusterAddOnConditionAvailable,
+			Status:             status,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-since)),
+		}, {
+			Type:               addonv1alpha1.ManagedClusterAddOnConditionProgressing,
+			Status:             metav1.ConditionFalse,
+			Reason:             constants.AddonProgressingReasonCompleted,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-adopted)),
+		}}
+		return addon
+	}
+
+	cases := []struct {
+		name           string
+		addons         []*addonv1alpha1.ManagedClusterAddOn
+		lastKnownGood  *addonv1alpha1.ConfigSpecHash
+		expectedFailed bool
+		// expectedRequeue is the time the rollout is checked again after, 0 if it is not requeued.
+		expectedRequeue time.Duration
+	}{
+		{
+			name: "addons are healthy",
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", newConfig, true, time.Hour, time.Hour),
+				newAddon("cluster2", newConfig, true, time.Hour, time.Hour),
+			},
+			lastKnownGood:  oldConfig,
+			expectedFailed: false,
+		},
+		{
+			name: "addons are unavailable within the timeout",
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", newConfig, false, time.Minute, time.Minute),
+				newAddon("cluster2", newConfig, false, 2*time.Minute, 2*time.Minute),
+			},
+			lastKnownGood:   oldConfig,
+			expectedFailed:  false,
+			expectedRequeue: 8*time.Minute + time.Second,
+		},
+		{
+			name: "failed addons do not exceed the threshold",
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", newConfig, false, time.Hour, time.Hour),
+				newAddon("cluster2", newConfig, true, time.Hour, time.Hour),
+			},
+			lastKnownGood:  oldConfig,
+			expectedFailed: false,
+		},
+		{
+			name: "unavailable addons not adopting the configs are not counted",
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", newConfig, false, time.Hour, time.Hour),
+				newAddon("cluster2", oldConfig, false, time.Hour, time.Hour),
+			},
+			lastKnownGood:  oldConfig,
+			expectedFailed: false,
+		},
+		{
+			name: "addons unavailable before the rollout are within the timeout",
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", newConfig, false, time.Hour, time.Minute),
+				newAddon("cluster2", newConfig, false, time.Hour, time.Minute),
+			},
+			lastKnownGood:   oldConfig,
+			expectedFailed:  false,
+			expectedRequeue: 9*time.Minute + time.Second,
+		},
+		{
+			name: "addons applying the configs are not counted",
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				func() *addonv1alpha1.ManagedClusterAddOn {
+					addon := newAddon("cluster1", newConfig, false, time.Hour, time.Hour)
+					addon.Status.ConfigReferences[0].LastAppliedConfig = oldConfig.DeepCopy()
+					return addon
+				}(),
+				newAddon("cluster2", newConfig, false, time.Hour, time.Hour),
+			},
+			lastKnownGood:  oldConfig,
+			expectedFailed: false,
+		},
+		{
+			name: "no last known good config",
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", newConfig, false, time.Hour, time.Hour),
+				newAddon("cluster2", newConfig, false, time.Hour, time.Hour),
+			},
+			expectedFailed: false,
+		},
+		{
+			name: "rollout failed",
+			addons: []*addonv1alpha1.ManagedClusterAddOn{
+				newAddon("cluster1", newConfig, false, time.Hour, time.Hour),
+				newAddon("cluster2", newConfig, false, time.Hour, time.Hour),
+			},
+			lastKnownGood:  oldConfig,
+			expectedFailed: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			installProgression := addonv1alpha1.InstallProgression{
+				PlacementRef: addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"},
+				ConfigReferences: []addonv1alpha1.InstallConfigReference{{
+					ConfigGroupResource: fooGR,
+					DesiredConfig:       newConfig.DeepCopy(),
+					LastKnownGoodConfig: c.lastKnownGood.DeepCopy(),
+				}},
+			}
+
+			graph := newGraph(nil, nil)
+			var clusters []string
+			for _, addon := range c.addons {
+				graph.addAddonNode(addon)
+				clusters = append(clusters, addon.Namespace)
+			}
+			graph.addPlacementNode(addonv1alpha1.PlacementStrategy{PlacementRef: installProgression.PlacementRef},
+				installProgression, clusters, nil)
+
+			options := &rollbackOptions{failureThreshold: intstr.FromString("50%"), timeout: 10 * time.Minute}
+			requeue := options.timeoutRemaining(&installProgression,
+				graph.getPlacementNodes()[installProgression.PlacementRef], time.Now())
+			// the addon conditions are set relative to the time of the test
+			if requeue > c.expectedRequeue || requeue < c.expectedRequeue-time.Second {
+				t.Errorf("expected requeue after %v, but got %v", c.expectedRequeue, requeue)
+			}
+			options.setRolloutFailedCondition(&installProgression, graph.getPlacementNodes()[installProgression.PlacementRef])
+
+			failed := meta.IsStatusConditionTrue(installProgression.Conditions, constants.InstallProgressionConditionRolloutFailed)
+			if failed != c.expectedFailed {
+				t.Fatalf("expected rollout failed %v, but got %v", c.expectedFailed, failed)
+			}
+			if !failed {
+				return
+			}
+
+			// the addons are rolled back to the last known good config at once
+			graph = newGraph(nil, nil)
+			for _, addon := range c.addons {
+				graph.addAddonNode(addon)
+			}
+			graph.addPlacementNode(addonv1alpha1.PlacementStrategy{PlacementRef: installProgression.PlacementRef},
+				installProgression, clusters, nil)
+			addons := graph.addonToUpdate()
+			if len(addons) != len(c.addons) {
+				t.Fatalf("expected %d addons to roll back, but got %d", len(c.addons), len(addons))
+			}
+			for _, addon := range addons {
+				if *addon.desiredConfigs[fooGR].DesiredConfig != *c.lastKnownGood {
+					t.Errorf("expected addon %s to roll back to %v, but got %v",
+						addon.mca.Namespace, c.lastKnownGood, addon.desiredConfigs[fooGR].DesiredConfig)
+				}
+			}
+		})
+	}
+}
+
+func TestRequeueRollbackTimeout(t *testing.T) {
+	placementRef := addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"}
+	fooGR := addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"}
+	newConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash2"}
+	oldConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash1"}
+
+	installProgression := addonv1alpha1.InstallProgression{
+		PlacementRef: placementRef,
+		ConfigReferences: []addonv1alpha1.InstallConfigReference{{
+			ConfigGroupResource: fooGR,
+			DesiredConfig:       newConfig.DeepCopy(),
+			LastKnownGoodConfig: oldConfig.DeepCopy(),
+		}},
+	}
+	cma := addontesting.NewClusterManagementAddon("test", "", "").WithInstallProgression(installProgression).Build()
+	cma.Annotations = map[string]string{
+		constants.RollbackFailureThresholdAnnotationKey: "0",
+		constants.RollbackTimeoutAnnotationKey:          "1ms",
+	}
+
+	// the addon adopted the desired configs and turned unavailable just now
+	addon := addontesting.NewAddon("test", "cluster1")
+	addon.Status.ConfigReferences = []addonv1alpha1.ConfigReference{{
+		ConfigGroupResource: fooGR,
+		ConfigReferent:      newConfig.ConfigReferent,
+		DesiredConfig:       newConfig.DeepCopy(),
+		LastAppliedConfig:   newConfig.DeepCopy(),
+	}}
+	addon.Status.Conditions = []metav1.Condition{{
+		Type:               addonv1alpha1.ManagedClusterAddOnConditionAvailable,
+		Status:             metav1.ConditionFalse,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+	}, {
+		Type:               addonv1alpha1.ManagedClusterAddOnConditionProgressing,
+		Status:             metav1.ConditionFalse,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+	}}
+	graph := newGraph(nil, nil)
+	graph.addAddonNode(addon)
+	graph.addPlacementNode(addonv1alpha1.PlacementStrategy{PlacementRef: placementRef},
+		installProgression, []string{"cluster1"}, nil)
+
+	syncCtx := addontesting.NewFakeSyncContext(t)
+	requeueRollbackTimeout(syncCtx, "test", cma, graph)
+	if syncCtx.Queue().Len() != 0 {
+		t.Fatalf("expected the addon not requeued before the timeout")
+	}
+
+	// the addon is requeued once the timeout is exceeded, without any change of the addons
+	if err := wait.Poll(100*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return syncCtx.Queue().Len() == 1, nil
+	}); err != nil {
+		t.Errorf("expected the addon requeued after the rollback timeout: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package addonconfiguration
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+// SegmentState is the bookkeeping of the segmented rollout of a placement. It can not be evaluated from the
+// status of the addons, so it is persisted by the SegmentStateStore to resume the rollout where it left off
+// after the manager restarts.
+type SegmentState struct {
+	// Configs identifies the rollout by the hash of the desired configs, the state is reset when it changes.
+	Configs string `json:"configs"`
+	// Segment is the current segment of the rollout.
+	Segment string `json:"segment"`
+	// StartTime is when the segment became the current segment.
+	StartTime metav1.Time `json:"startTime"`
+	// SoakStartTime is when the previous segment completed. The addons of the segment are held until the
+	// previous segment has soaked for the soak time.
+	SoakStartTime *metav1.Time `json:"soakStartTime,omitempty"`
+}
+
+// DeepCopy returns a copy of the segment state.
+func (s *SegmentState) DeepCopy() *SegmentState {
+	copied := *s
+	if s.SoakStartTime != nil {
+		copied.SoakStartTime = s.SoakStartTime.DeepCopy()
+	}
+	return &copied
+}
+
+// setRolloutSegmentCondition surfaces the current segment of the node in the RolloutSegment condition of the
+// install progression. The condition is removed if the node is not a segmented rolling update.
+func setRolloutSegmentCondition(installProgression *addonv1alpha1.InstallProgression, node *installStrategyNode) {
+	if node.segmentState == nil {
+		meta.RemoveStatusCondition(&installProgression.Conditions, constants.InstallProgressionConditionRolloutSegment)
+		return
+	}
+
+	cond := metav1.Condition{
+		Type:    constants.InstallProgressionConditionRolloutSegment,
+		Status:  metav1.ConditionTrue,
+		Reason:  constants.RolloutSegmentReasonRollingOut,
+		Message: fmt.Sprintf("segment %q is rolling out", node.segmentState.Segment),
+	}
+	if !node.segmentSoakUntil.IsZero() {
+		cond.Reason = constants.RolloutSegmentReasonSoaking
+		cond.Message = fmt.Sprintf("segment %q is held until %s for the previous segment to soak",
+			node.segmentState.Segment, node.segmentSoakUntil.UTC().Format(time.RFC3339))
+	}
+	meta.SetStatusCondition(&installProgression.Conditions, cond)
+}
+
+// segmentSoakTime returns the soak time of the segments from the annotation of the ClusterManagementAddOn,
+// it is 0 if the annotation is not set.
+func segmentSoakTime(cma *addonv1alpha1.ClusterManagementAddOn) (time.Duration, error) {
+	value, ok := cma.Annotations[constants.RolloutSegmentSoakTimeAnnotationKey]
+	if !ok {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid segment soak time %q: %v", value, err)
+	}
+	return duration, nil
+}
+
+// updateSegmentState returns the segment state of the node updated to the current segment, and holds the
+// segment if the previous segment has not soaked for the soak time. It returns the duration until the soak
+// ends, or 0 if the segment is not held.
+func (n *installStrategyNode) updateSegmentState(state SegmentState, soakTime time.Duration, now time.Time) (SegmentState, time.Duration) {
+	configs := n.desiredConfigsHash()
+	segment, clusters := n.currentSegment()
+	switch {
+	case state.Configs != configs:
+		state = SegmentState{Configs: configs, Segment: segment, StartTime: metav1.NewTime(now)}
+	case state.Segment != segment:
+		soakStartTime := metav1.NewTime(now)
+		state = SegmentState{Configs: configs, Segment: segment, StartTime: soakStartTime, SoakStartTime: &soakStartTime}
+	}
+
+	if state.SoakStartTime == nil || soakTime <= 0 || len(clusters) == 0 {
+		return state, 0
+	}
+	soakEndTime := state.SoakStartTime.Add(soakTime)
+	if !now.Before(soakEndTime) {
+		return state, 0
+	}
+	n.segmentSoakUntil = soakEndTime
+	return state, soakEndTime.Sub(now)
+}
+
+// desiredConfigsHash returns the hash of the desired configs of the node.
+func (n *installStrategyNode) desiredConfigsHash() string {
+	var configs []string
+	for gr, config := range n.desiredConfigs {
+		if config.DesiredConfig == nil {
+			continue
+		}
+		configs = append(configs, fmt.Sprintf("%s/%s/%s/%s/%s", gr.Group, gr.Resource,
+			config.DesiredConfig.Namespace, config.DesiredConfig.Name, config.DesiredConfig.SpecHash))
+	}
+	sort.Strings(configs)
+	data, _ := json.Marshal(configs)
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:16]
+}
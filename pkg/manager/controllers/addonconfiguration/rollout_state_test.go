@@ -0,0 +1,238 @@
+package addonconfiguration
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func TestUpdateSegmentState(t *testing.T) {
+	fooGR := addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"}
+	newConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash2"}
+	oldConfig := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash1"}
+	segments := map[string]string{"cluster1": "a", "cluster2": "a", "cluster3": "b", "cluster4": "b"}
+	now := time.Now()
+	soakTime := 5 * time.Minute
+
+	newAddon := func(cluster string, config *addonv1alpha1.ConfigSpecHash) *addonv1alpha1.ManagedClusterAddOn {
+		addon := addontesting.NewAddon("test", cluster)
+		addon.Status.ConfigReferences = []addonv1alpha1.ConfigReference{{
+			ConfigGroupResource: fooGR,
+			ConfigReferent:      config.ConfigReferent,
+			DesiredConfig:       config.DeepCopy(),
+			LastAppliedConfig:   config.DeepCopy(),
+		}}
+		addon.Status.Conditions = []metav1.Condition{{
+			Type:   addonv1alpha1.ManagedClusterAddOnConditionAvailable,
+			Status: metav1.ConditionTrue,
+		}}
+		return addon
+	}
+
+	// segment a has completed and segment b is pending
+	newNode := func() *installStrategyNode {
+		graph := newGraph(nil, nil)
+		var clusters []string
+		for _, addon := range []*addonv1alpha1.ManagedClusterAddOn{
+			newAddon("cluster1", newConfig), newAddon("cluster2", newConfig),
+			newAddon("cluster3", oldConfig), newAddon("cluster4", oldConfig),
+		} {
+			graph.addAddonNode(addon)
+			clusters = append(clusters, addon.Namespace)
+		}
+		placementRef := addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"}
+		graph.addPlacementNode(
+			addonv1alpha1.PlacementStrategy{
+				PlacementRef: placementRef,
+				RolloutStrategy: addonv1alpha1.RolloutStrategy{
+					Type:          addonv1alpha1.AddonRolloutStrategyRollingUpdate,
+					RollingUpdate: &addonv1alpha1.RollingUpdate{MaxConcurrency: intstr.FromString("50%")},
+				},
+			},
+			addonv1alpha1.InstallProgression{
+				PlacementRef: placementRef,
+				ConfigReferences: []addonv1alpha1.InstallConfigReference{
+					{ConfigGroupResource: fooGR, DesiredConfig: newConfig.DeepCopy()},
+				},
+			},
+			clusters, nil,
+		)
+		node := graph.getPlacementNodes()[placementRef]
+		node.segments = segments
+		return node
+	}
+	configs := newNode().desiredConfigsHash()
+	soakStartTime := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(-d))
+		return &t
+	}
+
+	cases := []struct {
+		name              string
+		state             SegmentState
+		expectedState     SegmentState
+		expectedRemaining time.Duration
+		expectedClusters  []string
+	}{
+		{
+			name:             "new rollout",
+			state:            SegmentState{Configs: "old", Segment: "b"},
+			expectedState:    SegmentState{Configs: configs, Segment: "b", StartTime: metav1.NewTime(now)},
+			expectedClusters: []string{"cluster3"},
+		},
+		{
+			name:  "previous segment completed",
+			state: SegmentState{Configs: configs, Segment: "a"},
+			expectedState: SegmentState{Configs: configs, Segment: "b", StartTime: metav1.NewTime(now),
+				SoakStartTime: soakStartTime(0)},
+			expectedRemaining: soakTime,
+			expectedClusters:  []string{},
+		},
+		{
+			name:              "previous segment soaking after restart",
+			state:             SegmentState{Configs: configs, Segment: "b", SoakStartTime: soakStartTime(time.Minute)},
+			expectedState:     SegmentState{Configs: configs, Segment: "b", SoakStartTime: soakStartTime(time.Minute)},
+			expectedRemaining: 4 * time.Minute,
+			expectedClusters:  []string{},
+		},
+		{
+			name:             "previous segment soaked",
+			state:            SegmentState{Configs: configs, Segment: "b", SoakStartTime: soakStartTime(10 * time.Minute)},
+			expectedState:    SegmentState{Configs: configs, Segment: "b", SoakStartTime: soakStartTime(10 * time.Minute)},
+			expectedClusters: []string{"cluster3"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			node := newNode()
+			state, remaining := node.updateSegmentState(c.state, soakTime, now)
+			if !reflect.DeepEqual(state, c.expectedState) {
+				t.Errorf("expected state %v, but got %v", c.expectedState, state)
+			}
+			if remaining != c.expectedRemaining {
+				t.Errorf("expected remaining soak time %v, but got %v", c.expectedRemaining, remaining)
+			}
+
+			actual := []string{}
+			for _, addon := range node.addonToUpdate() {
+				actual = append(actual, addon.mca.Namespace)
+			}
+			if !reflect.DeepEqual(actual, c.expectedClusters) {
+				t.Errorf("expected addons on clusters %v to update, but got %v", c.expectedClusters, actual)
+			}
+		})
+	}
+}
+
+func TestSegmentStateStore(t *testing.T) {
+	fooGR := addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"}
+	config := &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"}, SpecHash: "hash1"}
+	placementRef := addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"}
+	segments := map[string]string{"cluster1": "a", "cluster2": "b"}
+
+	cma := addontesting.NewClusterManagementAddon("test", "", "").WithInstallProgression(addonv1alpha1.InstallProgression{
+		PlacementRef: placementRef,
+		ConfigReferences: []addonv1alpha1.InstallConfigReference{
+			{ConfigGroupResource: fooGR, DesiredConfig: config.DeepCopy()},
+		},
+	}).Build()
+
+	newGraphWithSegments := func(cma *addonv1alpha1.ClusterManagementAddOn) *configurationGraph {
+		graph := newGraph(nil, nil)
+		for _, cluster := range []string{"cluster1", "cluster2"} {
+			graph.addAddonNode(addontesting.NewAddon("test", cluster))
+		}
+		graph.addPlacementNode(
+			addonv1alpha1.PlacementStrategy{
+				PlacementRef: placementRef,
+				RolloutStrategy: addonv1alpha1.RolloutStrategy{
+					Type:          addonv1alpha1.AddonRolloutStrategyRollingUpdate,
+					RollingUpdate: &addonv1alpha1.RollingUpdate{MaxConcurrency: intstr.FromInt(1)},
+				},
+			},
+			cma.Status.InstallProgressions[0],
+			[]string{"cluster1", "cluster2"}, nil,
+		)
+		graph.getPlacementNodes()[placementRef].segments = segments
+		return graph
+	}
+
+	fakeKubeClient := fakekube.NewSimpleClientset()
+	store := NewConfigMapSegmentStateStore(fakeKubeClient, "open-cluster-management-hub")
+	graph := newGraphWithSegments(cma)
+	if err := syncRolloutState(context.TODO(), addontesting.NewFakeSyncContext(t), store, "test", cma, graph); err != nil {
+		t.Fatal(err)
+	}
+	state := graph.getPlacementNodes()[placementRef].segmentState
+	if state == nil || state.Segment != "a" {
+		t.Fatalf("expected the rollout in segment a, but got %v", state)
+	}
+
+	fakeAddonClient := fakeaddon.NewSimpleClientset(cma)
+	reconciler := &clusterManagementAddonProgressingReconciler{addonClient: fakeAddonClient, segmentStateStore: store}
+	if _, _, err := reconciler.reconcile(context.TODO(), cma, graph); err != nil {
+		t.Fatal(err)
+	}
+
+	// the condition only surfaces the current segment, the state is saved in the ConfigMap of the addon
+	actions := fakeAddonClient.Actions()
+	addontesting.AssertActions(t, actions, "patch")
+	patch := &addonv1alpha1.ClusterManagementAddOn{}
+	if err := json.Unmarshal(actions[0].(clienttesting.PatchActionImpl).Patch, patch); err != nil {
+		t.Fatal(err)
+	}
+	cond := meta.FindStatusCondition(patch.Status.InstallProgressions[0].Conditions,
+		constants.InstallProgressionConditionRolloutSegment)
+	if cond == nil || cond.Reason != constants.RolloutSegmentReasonRollingOut || cond.Message != `segment "a" is rolling out` {
+		t.Fatalf("unexpected RolloutSegment condition %v", cond)
+	}
+	configMap, err := fakeKubeClient.CoreV1().ConfigMaps("open-cluster-management-hub").Get(context.TODO(),
+		constants.RolloutStateConfigMapName("test"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := configMap.Data["default.test-placement"]; !ok || len(configMap.OwnerReferences) != 1 {
+		t.Errorf("unexpected rollout state ConfigMap %v", configMap)
+	}
+
+	// the ConfigMap is not updated if the states do not change
+	fakeKubeClient.ClearActions()
+	if _, _, err := reconciler.reconcile(context.TODO(), cma, graph); err != nil {
+		t.Fatal(err)
+	}
+	addontesting.AssertNoActions(t, fakeKubeClient.Actions())
+
+	// the rollout resumes from the persisted state after the manager restarts
+	restartedStore := NewConfigMapSegmentStateStore(fakeKubeClient, "open-cluster-management-hub")
+	resumedGraph := newGraphWithSegments(cma)
+	if err := syncRolloutState(context.TODO(), addontesting.NewFakeSyncContext(t), restartedStore, "test", cma,
+		resumedGraph); err != nil {
+		t.Fatal(err)
+	}
+	resumed := resumedGraph.getPlacementNodes()[placementRef].segmentState
+	if resumed == nil || resumed.Configs != state.Configs || resumed.Segment != state.Segment ||
+		!resumed.StartTime.Time.Equal(state.StartTime.Time.Truncate(time.Second)) {
+		t.Errorf("expected state %v, but got %v", state, resumed)
+	}
+
+	// the rollout is not resumed from an invalid state
+	configMap.Data["default.test-placement"] = "invalid"
+	invalidStore := NewConfigMapSegmentStateStore(fakekube.NewSimpleClientset(configMap), "open-cluster-management-hub")
+	if _, err := invalidStore.Load(context.TODO(), "test"); err == nil {
+		t.Errorf("expected error for the invalid rollout state")
+	}
+}
@@ -0,0 +1,152 @@
+package addonconfiguration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+// SegmentStateStore persists the states of the segmented rollouts of the placements of the addons, so the
+// rollouts resume where they left off after the addon manager restarts.
+type SegmentStateStore interface {
+	// Load returns the states of the segmented rollouts of the addon by placement.
+	Load(ctx context.Context, addonName string) (map[addonv1alpha1.PlacementRef]SegmentState, error)
+
+	// Save replaces the states of the segmented rollouts of the addon.
+	Save(ctx context.Context, cma *addonv1alpha1.ClusterManagementAddOn,
+		states map[addonv1alpha1.PlacementRef]SegmentState) error
+}
+
+// configMapSegmentStateStore persists the segment states of an addon in the ConfigMap
+// RolloutStateConfigMapName(addonName), with a data key per placement. The states are cached after they are
+// loaded, so the ConfigMap is only read once per addon and written when the states change.
+type configMapSegmentStateStore struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+
+	lock   sync.Mutex
+	states map[string]map[addonv1alpha1.PlacementRef]SegmentState
+}
+
+// NewConfigMapSegmentStateStore returns a SegmentStateStore persisting the segment states in the ConfigMaps
+// of the namespace.
+func NewConfigMapSegmentStateStore(kubeClient kubernetes.Interface, namespace string) SegmentStateStore {
+	return &configMapSegmentStateStore{
+		kubeClient: kubeClient,
+		namespace:  namespace,
+		states:     map[string]map[addonv1alpha1.PlacementRef]SegmentState{},
+	}
+}
+
+func (s *configMapSegmentStateStore) Load(ctx context.Context, addonName string) (
+	map[addonv1alpha1.PlacementRef]SegmentState, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if states, ok := s.states[addonName]; ok {
+		return copySegmentStates(states), nil
+	}
+
+	configMap, err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).Get(
+		ctx, constants.RolloutStateConfigMapName(addonName), metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		s.states[addonName] = map[addonv1alpha1.PlacementRef]SegmentState{}
+		return map[addonv1alpha1.PlacementRef]SegmentState{}, nil
+	case err != nil:
+		return nil, err
+	}
+
+	states := map[addonv1alpha1.PlacementRef]SegmentState{}
+	for key, value := range configMap.Data {
+		placementRef, ok := placementRefFromKey(key)
+		if !ok {
+			return nil, fmt.Errorf("invalid placement key %q of the rollout states of addon %s", key, addonName)
+		}
+		state := SegmentState{}
+		if err := json.Unmarshal([]byte(value), &state); err != nil {
+			return nil, fmt.Errorf("invalid rollout state of placement %s/%s of addon %s: %v",
+				placementRef.Namespace, placementRef.Name, addonName, err)
+		}
+		states[placementRef] = state
+	}
+	s.states[addonName] = states
+	return copySegmentStates(states), nil
+}
+
+func (s *configMapSegmentStateStore) Save(ctx context.Context, cma *addonv1alpha1.ClusterManagementAddOn,
+	states map[addonv1alpha1.PlacementRef]SegmentState) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	cached, ok := s.states[cma.Name]
+	if ok && len(cached) == 0 && len(states) == 0 {
+		return nil
+	}
+	if ok && reflect.DeepEqual(cached, states) {
+		return nil
+	}
+
+	data := map[string]string{}
+	for placementRef, state := range states {
+		value, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		data[placementRefKey(placementRef)] = string(value)
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.RolloutStateConfigMapName(cma.Name),
+			Namespace: s.namespace,
+			Labels: map[string]string{
+				addonv1alpha1.AddonLabelKey: cma.Name,
+			},
+			// the ConfigMap is deleted with the ClusterManagementAddOn
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cma, addonv1alpha1.GroupVersion.WithKind("ClusterManagementAddOn")),
+			},
+		},
+		Data: data,
+	}
+	if _, _, err := utils.ApplyConfigMap(ctx, s.kubeClient.CoreV1(), configMap); err != nil {
+		return err
+	}
+
+	s.states[cma.Name] = copySegmentStates(states)
+	return nil
+}
+
+// placementRefKey returns the data key of the placement, the namespace has no dot so the key is not ambiguous.
+func placementRefKey(placementRef addonv1alpha1.PlacementRef) string {
+	return placementRef.Namespace + "." + placementRef.Name
+}
+
+func placementRefFromKey(key string) (addonv1alpha1.PlacementRef, bool) {
+	namespace, name, ok := strings.Cut(key, ".")
+	if !ok || len(namespace) == 0 || len(name) == 0 {
+		return addonv1alpha1.PlacementRef{}, false
+	}
+	return addonv1alpha1.PlacementRef{Namespace: namespace, Name: name}, true
+}
+
+func copySegmentStates(states map[addonv1alpha1.PlacementRef]SegmentState) map[addonv1alpha1.PlacementRef]SegmentState {
+	copied := make(map[addonv1alpha1.PlacementRef]SegmentState, len(states))
+	for placementRef, state := range states {
+		copied[placementRef] = *state.DeepCopy()
+	}
+	return copied
+}
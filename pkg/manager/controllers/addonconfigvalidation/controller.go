@@ -0,0 +1,158 @@
+package addonconfigvalidation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/index"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+// addonConfigValidationController validates the configs of the managedclusteraddons against the supported
+// configs of their clustermanagementaddons, and sets the UnsupportedConfiguration condition of the addons.
+// The agents of the addons with unsupported configs are not rendered until the configs are fixed.
+type addonConfigValidationController struct {
+	addonClient                  addonv1alpha1client.Interface
+	managedClusterAddonLister    addonlisterv1alpha1.ManagedClusterAddOnLister
+	managedClusterAddonIndexer   cache.Indexer
+	clusterManagementAddonLister addonlisterv1alpha1.ClusterManagementAddOnLister
+	addonFilterFunc              utils.AddonManagementFilterFunc
+}
+
+func NewAddonConfigValidationController(
+	addonClient addonv1alpha1client.Interface,
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	clusterManagementAddonInformers addoninformerv1alpha1.ClusterManagementAddOnInformer,
+	addonFilterFunc utils.AddonManagementFilterFunc,
+) factory.Controller {
+	c := &addonConfigValidationController{
+		addonClient:                  addonClient,
+		managedClusterAddonLister:    addonInformers.Lister(),
+		managedClusterAddonIndexer:   addonInformers.Informer().GetIndexer(),
+		clusterManagementAddonLister: clusterManagementAddonInformers.Lister(),
+		addonFilterFunc:              addonFilterFunc,
+	}
+
+	return factory.New().WithInformersQueueKeysFunc(
+		func(obj runtime.Object) []string {
+			key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			return []string{key}
+		},
+		addonInformers.Informer()).
+		WithInformersQueueKeysFunc(c.addonQueueKeysFunc, clusterManagementAddonInformers.Informer()).
+		WithSync(c.sync).ToController("addon-config-validation-controller")
+}
+
+// addonQueueKeysFunc queues the managedclusteraddons of the clustermanagementaddon once its supported configs
+// are changed.
+func (c *addonConfigValidationController) addonQueueKeysFunc(obj runtime.Object) []string {
+	accessor, _ := meta.Accessor(obj)
+	addons, err := c.managedClusterAddonIndexer.ByIndex(index.ManagedClusterAddonByName, accessor.GetName())
+	if err != nil {
+		// the index is not added, list all the addons
+		addonList, err := c.managedClusterAddonLister.List(labels.Everything())
+		if err != nil {
+			return nil
+		}
+		for _, addon := range addonList {
+			addons = append(addons, addon)
+		}
+	}
+
+	var keys []string
+	for _, obj := range addons {
+		addon, ok := obj.(*addonapiv1alpha1.ManagedClusterAddOn)
+		if !ok || addon.Name != accessor.GetName() {
+			continue
+		}
+		keys = append(keys, fmt.Sprintf("%s/%s", addon.Namespace, addon.Name))
+	}
+	return keys
+}
+
+func (c *addonConfigValidationController) sync(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+	klog.V(4).Infof("Validating the configs of addon %q", key)
+
+	namespace, addonName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		// ignore addon whose key is invalid
+		return nil
+	}
+
+	addon, err := c.managedClusterAddonLister.ManagedClusterAddOns(namespace).Get(addonName)
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	clusterManagementAddon, err := c.clusterManagementAddonLister.Get(addonName)
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if !c.addonFilterFunc(clusterManagementAddon) {
+		return nil
+	}
+
+	supported := sets.New[addonapiv1alpha1.ConfigGroupResource]()
+	for _, config := range clusterManagementAddon.Spec.SupportedConfigs {
+		supported.Insert(config.ConfigGroupResource)
+	}
+	var unsupported []string
+	for _, config := range addon.Spec.Configs {
+		if !supported.Has(config.ConfigGroupResource) {
+			unsupported = append(unsupported, configString(config))
+		}
+	}
+
+	addonCopy := addon.DeepCopy()
+	condition := metav1.Condition{
+		Type:    addonapiv1alpha1.ManagedClusterAddOnUnsupportedConfigurationType,
+		Status:  metav1.ConditionFalse,
+		Reason:  addonapiv1alpha1.AddonReasonConfigurationSupported,
+		Message: "the config resources are supported",
+	}
+	if len(unsupported) > 0 {
+		condition = metav1.Condition{
+			Type:   addonapiv1alpha1.ManagedClusterAddOnUnsupportedConfigurationType,
+			Status: metav1.ConditionTrue,
+			Reason: addonapiv1alpha1.AddonReasonConfigurationUnsupported,
+			Message: fmt.Sprintf("the configs %s are not supported by the clustermanagementaddon %s",
+				strings.Join(unsupported, ", "), addonName),
+		}
+	}
+	meta.SetStatusCondition(&addonCopy.Status.Conditions, condition)
+
+	return utils.PatchAddonCondition(ctx, c.addonClient, addonCopy, addon)
+}
+
+func configString(config addonapiv1alpha1.AddOnConfig) string {
+	gr := config.Resource
+	if len(config.Group) > 0 {
+		gr = fmt.Sprintf("%s.%s", config.Resource, config.Group)
+	}
+	if len(config.Namespace) > 0 {
+		return fmt.Sprintf("%s %s/%s", gr, config.Namespace, config.Name)
+	}
+	return fmt.Sprintf("%s %s", gr, config.Name)
+}
@@ -0,0 +1,176 @@
+package addonconfigvalidation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+var deploymentConfigGR = addonapiv1alpha1.ConfigGroupResource{
+	Group:    "addon.open-cluster-management.io",
+	Resource: "addondeploymentconfigs",
+}
+
+func newAddonWithConfigs(configs ...addonapiv1alpha1.AddOnConfig) *addonapiv1alpha1.ManagedClusterAddOn {
+	addon := addontesting.NewAddon("test", "cluster1")
+	addon.Spec.Configs = configs
+	return addon
+}
+
+func newSelfManagedCMA(supportedConfigs ...addonapiv1alpha1.ConfigMeta) *addonapiv1alpha1.ClusterManagementAddOn {
+	cma := addontesting.NewClusterManagementAddon("test", "", "").WithSupportedConfigs(supportedConfigs...).Build()
+	cma.Annotations = map[string]string{
+		addonapiv1alpha1.AddonLifecycleAnnotationKey: addonapiv1alpha1.AddonLifecycleSelfManageAnnotationValue,
+	}
+	return cma
+}
+
+func assertUnsupportedCondition(status metav1.ConditionStatus, reason string) func(t *testing.T, actions []clienttesting.Action) {
+	return func(t *testing.T, actions []clienttesting.Action) {
+		addontesting.AssertActions(t, actions, "patch")
+		patch := actions[0].(clienttesting.PatchActionImpl).Patch
+		addon := &addonapiv1alpha1.ManagedClusterAddOn{}
+		if err := json.Unmarshal(patch, addon); err != nil {
+			t.Fatal(err)
+		}
+		cond := meta.FindStatusCondition(addon.Status.Conditions,
+			addonapiv1alpha1.ManagedClusterAddOnUnsupportedConfigurationType)
+		if cond == nil {
+			t.Fatalf("expected the unsupported configuration condition")
+		}
+		if cond.Status != status || cond.Reason != reason {
+			t.Errorf("expected condition %s with reason %s, but got %v", status, reason, cond)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	cases := []struct {
+		name                   string
+		syncKey                string
+		managedClusteraddon    []runtime.Object
+		clusterManagementAddon []runtime.Object
+		validateAddonActions   func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:                   "no managedclusteraddon",
+			syncKey:                "cluster1/test",
+			clusterManagementAddon: []runtime.Object{newSelfManagedCMA()},
+			validateAddonActions:   addontesting.AssertNoActions,
+		},
+		{
+			name:                 "no clustermanagementaddon",
+			syncKey:              "cluster1/test",
+			managedClusteraddon:  []runtime.Object{newAddonWithConfigs()},
+			validateAddonActions: addontesting.AssertNoActions,
+		},
+		{
+			name:    "clustermanagementaddon managed by others",
+			syncKey: "cluster1/test",
+			managedClusteraddon: []runtime.Object{newAddonWithConfigs(addonapiv1alpha1.AddOnConfig{
+				ConfigGroupResource: deploymentConfigGR,
+				ConfigReferent:      addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config"},
+			})},
+			clusterManagementAddon: []runtime.Object{func() *addonapiv1alpha1.ClusterManagementAddOn {
+				cma := newSelfManagedCMA()
+				cma.Annotations[addonapiv1alpha1.AddonLifecycleAnnotationKey] = addonapiv1alpha1.AddonLifecycleAddonManagerAnnotationValue
+				return cma
+			}()},
+			validateAddonActions: addontesting.AssertNoActions,
+		},
+		{
+			name:    "supported configs",
+			syncKey: "cluster1/test",
+			managedClusteraddon: []runtime.Object{newAddonWithConfigs(addonapiv1alpha1.AddOnConfig{
+				ConfigGroupResource: deploymentConfigGR,
+				ConfigReferent:      addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config"},
+			})},
+			clusterManagementAddon: []runtime.Object{newSelfManagedCMA(addonapiv1alpha1.ConfigMeta{
+				ConfigGroupResource: deploymentConfigGR,
+			})},
+			validateAddonActions: assertUnsupportedCondition(metav1.ConditionFalse,
+				addonapiv1alpha1.AddonReasonConfigurationSupported),
+		},
+		{
+			name:    "unsupported configs",
+			syncKey: "cluster1/test",
+			managedClusteraddon: []runtime.Object{newAddonWithConfigs(addonapiv1alpha1.AddOnConfig{
+				ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{Group: "configs.test", Resource: "configs"},
+				ConfigReferent:      addonapiv1alpha1.ConfigReferent{Name: "config"},
+			})},
+			clusterManagementAddon: []runtime.Object{newSelfManagedCMA(addonapiv1alpha1.ConfigMeta{
+				ConfigGroupResource: deploymentConfigGR,
+			})},
+			validateAddonActions: assertUnsupportedCondition(metav1.ConditionTrue,
+				addonapiv1alpha1.AddonReasonConfigurationUnsupported),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			obj := append(c.clusterManagementAddon, c.managedClusteraddon...)
+			fakeAddonClient := fakeaddon.NewSimpleClientset(obj...)
+
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+			for _, obj := range c.managedClusteraddon {
+				if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, obj := range c.clusterManagementAddon {
+				if err := addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			controller := addonConfigValidationController{
+				addonClient:                  fakeAddonClient,
+				managedClusterAddonLister:    addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				managedClusterAddonIndexer:   addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetIndexer(),
+				clusterManagementAddonLister: addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Lister(),
+				addonFilterFunc:              utils.ManagedBySelf,
+			}
+
+			syncContext := addontesting.NewFakeSyncContext(t)
+			if err := controller.sync(context.TODO(), syncContext, c.syncKey); err != nil {
+				t.Errorf("expected no error when sync: %v", err)
+			}
+			c.validateAddonActions(t, fakeAddonClient.Actions())
+		})
+	}
+}
+
+func TestAddonQueueKeysFunc(t *testing.T) {
+	fakeAddonClient := fakeaddon.NewSimpleClientset()
+	addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+	for _, addon := range []*addonapiv1alpha1.ManagedClusterAddOn{
+		addontesting.NewAddon("test", "cluster1"),
+		addontesting.NewAddon("test", "cluster2"),
+		addontesting.NewAddon("other", "cluster1"),
+	} {
+		if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(addon); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	controller := addonConfigValidationController{
+		managedClusterAddonLister:  addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+		managedClusterAddonIndexer: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetIndexer(),
+	}
+	keys := controller.addonQueueKeysFunc(newSelfManagedCMA())
+	if len(keys) != 2 {
+		t.Errorf("expected the keys of the addons of the clustermanagementaddon, but got %v", keys)
+	}
+}
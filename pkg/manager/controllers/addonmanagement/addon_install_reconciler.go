@@ -2,6 +2,7 @@ package addonmanagement
 
 import (
 	"context"
+	"fmt"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -15,7 +16,9 @@ import (
 	clusterlisterv1beta1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1beta1"
 	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
 
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
 	"open-cluster-management.io/addon-framework/pkg/index"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 )
 
 type managedClusterAddonInstallReconciler struct {
@@ -23,6 +26,8 @@ type managedClusterAddonInstallReconciler struct {
 	managedClusterAddonIndexer cache.Indexer
 	placementLister            clusterlisterv1beta1.PlacementLister
 	placementDecisionLister    clusterlisterv1beta1.PlacementDecisionLister
+	// addOnDeploymentConfigGetter reads the AddOnDeploymentConfigs of the placement strategies from the cache
+	addOnDeploymentConfigGetter utils.AddOnDeploymentConfigGetter
 }
 
 func (d *managedClusterAddonInstallReconciler) reconcile(
@@ -37,29 +42,56 @@ func (d *managedClusterAddonInstallReconciler) reconcile(
 	}
 
 	existingDeployed := sets.Set[string]{}
+	existingAddons := map[string]*addonv1alpha1.ManagedClusterAddOn{}
 	for _, addonObject := range addons {
 		addon := addonObject.(*addonv1alpha1.ManagedClusterAddOn)
 		existingDeployed.Insert(addon.Namespace)
+		existingAddons[addon.Namespace] = addon
 	}
 
-	requiredDeployed, err := d.getAllDecisions(cma.Name, cma.Spec.InstallStrategy.Placements)
+	clusterPlacements, err := d.getAllDecisions(cma.Name, cma.Spec.InstallStrategy.Placements)
 	if err != nil {
 		return cma, reconcileContinue, err
 	}
+	requiredDeployed := sets.KeySet(clusterPlacements)
 
 	owner := metav1.NewControllerRef(cma, addonv1alpha1.GroupVersion.WithKind("ClusterManagementAddOn"))
 	toAdd := requiredDeployed.Difference(existingDeployed)
 	toRemove := existingDeployed.Difference(requiredDeployed)
 
 	var errs []error
+	// the configs of the placements are set on the addons by the addon configuration controller through the
+	// install progressions, so they are not set in the addon spec which would override the rollout. Only the
+	// install namespace of the placement is stamped onto the addon spec.
+	installNamespaces := map[addonv1alpha1.PlacementRef]string{}
+	for _, strategy := range cma.Spec.InstallStrategy.Placements {
+		namespace, err := d.placementInstallNamespace(ctx, strategy)
+		if err != nil {
+			// the addons of the placement are created once the install namespace is resolved, so they are not
+			// installed in a wrong namespace.
+			errs = append(errs, err)
+			continue
+		}
+		installNamespaces[strategy.PlacementRef] = namespace
+	}
+
 	for cluster := range toAdd {
+		installNamespace, ok := installNamespaces[clusterPlacements[cluster]]
+		if !ok {
+			continue
+		}
+		spec := addonv1alpha1.ManagedClusterAddOnSpec{InstallNamespace: installNamespace}
+
 		_, err := d.addonClient.AddonV1alpha1().ManagedClusterAddOns(cluster).Create(ctx, &addonv1alpha1.ManagedClusterAddOn{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:            cma.Name,
 				Namespace:       cluster,
 				OwnerReferences: []metav1.OwnerReference{*owner},
+				Labels: map[string]string{
+					addonv1alpha1.AddonLabelKey: cma.Name,
+				},
 			},
-			Spec: addonv1alpha1.ManagedClusterAddOnSpec{},
+			Spec: spec,
 		}, metav1.CreateOptions{})
 
 		if err != nil && !errors.IsAlreadyExists(err) {
@@ -68,6 +100,10 @@ func (d *managedClusterAddonInstallReconciler) reconcile(
 	}
 
 	for cluster := range toRemove {
+		if !deletionRequired(cma, existingAddons[cluster]) {
+			continue
+		}
+
 		err := d.addonClient.AddonV1alpha1().ManagedClusterAddOns(cluster).Delete(ctx, cma.Name, metav1.DeleteOptions{})
 		if err != nil && !errors.IsNotFound(err) {
 			errs = append(errs, err)
@@ -77,9 +113,52 @@ func (d *managedClusterAddonInstallReconciler) reconcile(
 	return cma, reconcileContinue, utilerrors.NewAggregate(errs)
 }
 
-func (d *managedClusterAddonInstallReconciler) getAllDecisions(addonName string, placements []addonv1alpha1.PlacementStrategy) (sets.Set[string], error) {
+// deletionRequired returns true if the addon on the cluster removed from the placement decisions should be
+// deleted. The deletion can be opted out by the RetainWhenUnselectedAnnotationKey annotation on either the
+// ClusterManagementAddOn or the ManagedClusterAddOn.
+func deletionRequired(cma *addonv1alpha1.ClusterManagementAddOn, addon *addonv1alpha1.ManagedClusterAddOn) bool {
+	if !addon.DeletionTimestamp.IsZero() {
+		return false
+	}
+	if cma.Annotations[constants.RetainWhenUnselectedAnnotationKey] == "true" {
+		klog.V(4).Infof("Skip deleting addon %s/%s, retained by the clustermanagementaddon", addon.Namespace, addon.Name)
+		return false
+	}
+	if addon.Annotations[constants.RetainWhenUnselectedAnnotationKey] == "true" {
+		klog.V(4).Infof("Skip deleting addon %s/%s, retained by the annotation", addon.Namespace, addon.Name)
+		return false
+	}
+	return true
+}
+
+// placementInstallNamespace returns the agent install namespace of the addons created for the placement, set by
+// the AgentInstallNamespaceAnnotationKey annotation of the AddOnDeploymentConfigs in the configs of the placement
+// strategy. If there are multiple AddOnDeploymentConfigs, the last one with the annotation takes precedence.
+func (d *managedClusterAddonInstallReconciler) placementInstallNamespace(ctx context.Context,
+	strategy addonv1alpha1.PlacementStrategy) (string, error) {
+	var namespace string
+	for _, config := range strategy.Configs {
+		if config.ConfigGroupResource != utils.AddOnDeploymentConfigGroupResource {
+			continue
+		}
+		addOnDeploymentConfig, err := d.addOnDeploymentConfigGetter.Get(ctx, config.Namespace, config.Name)
+		if err != nil {
+			return "", fmt.Errorf("failed to get the AddOnDeploymentConfig %s/%s of placement %s/%s: %w",
+				config.Namespace, config.Name, strategy.Namespace, strategy.Name, err)
+		}
+		if ns := addOnDeploymentConfig.Annotations[constants.AgentInstallNamespaceAnnotationKey]; len(ns) > 0 {
+			namespace = ns
+		}
+	}
+	return namespace, nil
+}
+
+// getAllDecisions returns the clusters selected by the placements and the placement of each cluster. If a
+// cluster is selected by multiple placements, the last placement takes precedence.
+func (d *managedClusterAddonInstallReconciler) getAllDecisions(addonName string,
+	placements []addonv1alpha1.PlacementStrategy) (map[string]addonv1alpha1.PlacementRef, error) {
 	var errs []error
-	required := sets.Set[string]{}
+	required := map[string]addonv1alpha1.PlacementRef{}
 	for _, strategy := range placements {
 		_, err := d.placementLister.Placements(strategy.PlacementRef.Namespace).Get(strategy.PlacementRef.Name)
 		if errors.IsNotFound(err) {
@@ -102,7 +181,7 @@ func (d *managedClusterAddonInstallReconciler) getAllDecisions(addonName string,
 
 		for _, d := range decisions {
 			for _, sd := range d.Status.Decisions {
-				required.Insert(sd.ClusterName)
+				required[sd.ClusterName] = strategy.PlacementRef
 			}
 		}
 	}
@@ -16,6 +16,7 @@ import (
 
 	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
 	"open-cluster-management.io/addon-framework/pkg/index"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 )
 
 type addonManagementController struct {
@@ -44,6 +45,7 @@ func NewAddonManagementController(
 	clusterManagementAddonInformers addoninformerv1alpha1.ClusterManagementAddOnInformer,
 	placementInformer clusterinformersv1beta1.PlacementInformer,
 	placementDecisionInformer clusterinformersv1beta1.PlacementDecisionInformer,
+	addOnDeploymentConfigInformers addoninformerv1alpha1.AddOnDeploymentConfigInformer,
 ) factory.Controller {
 	c := &addonManagementController{
 		addonClient:                   addonClient,
@@ -56,6 +58,8 @@ func NewAddonManagementController(
 				placementDecisionLister:    placementDecisionInformer.Lister(),
 				placementLister:            placementInformer.Lister(),
 				managedClusterAddonIndexer: addonInformers.Informer().GetIndexer(),
+				addOnDeploymentConfigGetter: utils.NewAddOnDeploymentConfigGetterFromLister(
+					addOnDeploymentConfigInformers.Lister()),
 			},
 		},
 	}
@@ -68,6 +72,7 @@ func NewAddonManagementController(
 		addonInformers.Informer(), clusterManagementAddonInformers.Informer()).
 		WithInformersQueueKeysFunc(index.ClusterManagementAddonByPlacementDecisionQueueKey(clusterManagementAddonInformers), placementDecisionInformer.Informer()).
 		WithInformersQueueKeysFunc(index.ClusterManagementAddonByPlacementQueueKey(clusterManagementAddonInformers), placementInformer.Informer()).
+		WithBareInformers(addOnDeploymentConfigInformers.Informer()).
 		WithSync(c.sync).ToController("addon-management-controller")
 }
 
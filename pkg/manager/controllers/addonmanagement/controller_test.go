@@ -10,7 +10,9 @@ import (
 	clienttesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
 	"open-cluster-management.io/addon-framework/pkg/index"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
 	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
@@ -20,9 +22,38 @@ import (
 )
 
 func TestAddonInstallReconcile(t *testing.T) {
+	// cluster1 is selected by test-placement, and cluster2 by both, test-placement1 takes precedence.
+	twoPlacements := []runtime.Object{
+		&clusterv1beta1.Placement{ObjectMeta: metav1.ObjectMeta{Name: "test-placement", Namespace: "default"}},
+		&clusterv1beta1.Placement{ObjectMeta: metav1.ObjectMeta{Name: "test-placement1", Namespace: "default"}},
+	}
+	twoPlacementDecisions := []runtime.Object{
+		&clusterv1beta1.PlacementDecision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-placement",
+				Namespace: "default",
+				Labels:    map[string]string{clusterv1beta1.PlacementLabel: "test-placement"},
+			},
+			Status: clusterv1beta1.PlacementDecisionStatus{
+				Decisions: []clusterv1beta1.ClusterDecision{{ClusterName: "cluster1"}, {ClusterName: "cluster2"}},
+			},
+		},
+		&clusterv1beta1.PlacementDecision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-placement1",
+				Namespace: "default",
+				Labels:    map[string]string{clusterv1beta1.PlacementLabel: "test-placement1"},
+			},
+			Status: clusterv1beta1.PlacementDecisionStatus{
+				Decisions: []clusterv1beta1.ClusterDecision{{ClusterName: "cluster2"}},
+			},
+		},
+	}
+
 	cases := []struct {
 		name                   string
 		managedClusteraddon    []runtime.Object
+		configs                []runtime.Object
 		clusterManagementAddon *addonv1alpha1.ClusterManagementAddOn
 		placements             []runtime.Object
 		placementDecisions     []runtime.Object
@@ -213,13 +244,209 @@ func TestAddonInstallReconcile(t *testing.T) {
 				addontesting.AssertActions(t, actions, "create", "create", "delete")
 			},
 		},
+		{
+			name: "addon is retained by the annotation",
+			managedClusteraddon: []runtime.Object{
+				func() *addonv1alpha1.ManagedClusterAddOn {
+					addon := addontesting.NewAddon("test", "cluster0")
+					addon.Annotations = map[string]string{constants.RetainWhenUnselectedAnnotationKey: "true"}
+					return addon
+				}(),
+				addontesting.NewAddon("test", "cluster1"),
+			},
+			clusterManagementAddon: func() *addonv1alpha1.ClusterManagementAddOn {
+				addon := addontesting.NewClusterManagementAddon("test", "", "").Build()
+				addon.Annotations = nil
+				addon.Spec.InstallStrategy = addonv1alpha1.InstallStrategy{
+					Type: addonv1alpha1.AddonInstallStrategyPlacements,
+					Placements: []addonv1alpha1.PlacementStrategy{
+						{
+							PlacementRef: addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"},
+						},
+					},
+				}
+				return addon
+			}(),
+			placements: []runtime.Object{
+				&clusterv1beta1.Placement{ObjectMeta: metav1.ObjectMeta{Name: "test-placement", Namespace: "default"}},
+			},
+			placementDecisions: []runtime.Object{
+				&clusterv1beta1.PlacementDecision{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-placement",
+						Namespace: "default",
+						Labels:    map[string]string{clusterv1beta1.PlacementLabel: "test-placement"},
+					},
+					Status: clusterv1beta1.PlacementDecisionStatus{
+						Decisions: []clusterv1beta1.ClusterDecision{{ClusterName: "cluster1"}},
+					},
+				},
+			},
+			validateAddonActions: addontesting.AssertNoActions,
+		},
+		{
+			name: "addons are retained by the clustermanagementaddon",
+			managedClusteraddon: []runtime.Object{
+				addontesting.NewAddon("test", "cluster0"),
+				addontesting.NewAddon("test", "cluster1"),
+			},
+			clusterManagementAddon: func() *addonv1alpha1.ClusterManagementAddOn {
+				addon := addontesting.NewClusterManagementAddon("test", "", "").Build()
+				addon.Annotations = map[string]string{constants.RetainWhenUnselectedAnnotationKey: "true"}
+				addon.Spec.InstallStrategy = addonv1alpha1.InstallStrategy{
+					Type: addonv1alpha1.AddonInstallStrategyPlacements,
+					Placements: []addonv1alpha1.PlacementStrategy{
+						{
+							PlacementRef: addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"},
+						},
+					},
+				}
+				return addon
+			}(),
+			placements: []runtime.Object{
+				&clusterv1beta1.Placement{ObjectMeta: metav1.ObjectMeta{Name: "test-placement", Namespace: "default"}},
+			},
+			placementDecisions: []runtime.Object{
+				&clusterv1beta1.PlacementDecision{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-placement",
+						Namespace: "default",
+						Labels:    map[string]string{clusterv1beta1.PlacementLabel: "test-placement"},
+					},
+					Status: clusterv1beta1.PlacementDecisionStatus{
+						Decisions: []clusterv1beta1.ClusterDecision{{ClusterName: "cluster1"}},
+					},
+				},
+			},
+			validateAddonActions: addontesting.AssertNoActions,
+		},
+		{
+			name: "addon is deleting",
+			managedClusteraddon: []runtime.Object{
+				func() *addonv1alpha1.ManagedClusterAddOn {
+					addon := addontesting.NewAddon("test", "cluster0")
+					addon.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+					return addon
+				}(),
+				addontesting.NewAddon("test", "cluster1"),
+			},
+			clusterManagementAddon: func() *addonv1alpha1.ClusterManagementAddOn {
+				addon := addontesting.NewClusterManagementAddon("test", "", "").Build()
+				addon.Annotations = nil
+				addon.Spec.InstallStrategy = addonv1alpha1.InstallStrategy{
+					Type: addonv1alpha1.AddonInstallStrategyPlacements,
+					Placements: []addonv1alpha1.PlacementStrategy{
+						{
+							PlacementRef: addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"},
+						},
+					},
+				}
+				return addon
+			}(),
+			placements: []runtime.Object{
+				&clusterv1beta1.Placement{ObjectMeta: metav1.ObjectMeta{Name: "test-placement", Namespace: "default"}},
+			},
+			placementDecisions: []runtime.Object{
+				&clusterv1beta1.PlacementDecision{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-placement",
+						Namespace: "default",
+						Labels:    map[string]string{clusterv1beta1.PlacementLabel: "test-placement"},
+					},
+					Status: clusterv1beta1.PlacementDecisionStatus{
+						Decisions: []clusterv1beta1.ClusterDecision{{ClusterName: "cluster1"}},
+					},
+				},
+			},
+			validateAddonActions: addontesting.AssertNoActions,
+		},
+		{
+			name:                "install addon with the install namespace of the placement",
+			managedClusteraddon: []runtime.Object{},
+			configs: []runtime.Object{
+				func() runtime.Object {
+					config := addontesting.NewAddOnDeploymentConfig("prod", "default").Build()
+					config.Annotations = map[string]string{constants.AgentInstallNamespaceAnnotationKey: "prod"}
+					return config
+				}(),
+			},
+			clusterManagementAddon: func() *addonv1alpha1.ClusterManagementAddOn {
+				addon := addontesting.NewClusterManagementAddon("test", "", "").Build()
+				addon.Spec.InstallStrategy = addonv1alpha1.InstallStrategy{
+					Type: addonv1alpha1.AddonInstallStrategyPlacements,
+					Placements: []addonv1alpha1.PlacementStrategy{
+						{
+							PlacementRef: addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"},
+						},
+						{
+							PlacementRef: addonv1alpha1.PlacementRef{Name: "test-placement1", Namespace: "default"},
+							Configs: []addonv1alpha1.AddOnConfig{{
+								ConfigGroupResource: utils.AddOnDeploymentConfigGroupResource,
+								ConfigReferent:      addonv1alpha1.ConfigReferent{Namespace: "default", Name: "prod"},
+							}},
+						},
+					},
+				}
+				return addon
+			}(),
+			placements:         twoPlacements,
+			placementDecisions: twoPlacementDecisions,
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "create", "create")
+				for _, action := range actions {
+					addon := action.(clienttesting.CreateActionImpl).Object.(*addonv1alpha1.ManagedClusterAddOn)
+					// the configs of the placement are rolled out by the install progressions
+					if len(addon.Spec.Configs) != 0 {
+						t.Errorf("expected no configs in the spec of addon %s, but got %v", addon.Namespace, addon.Spec.Configs)
+					}
+					expectedNamespace := map[string]string{"cluster1": "", "cluster2": "prod"}[addon.Namespace]
+					if addon.Spec.InstallNamespace != expectedNamespace {
+						t.Errorf("expected install namespace %q of addon %s, but got %q",
+							expectedNamespace, addon.Namespace, addon.Spec.InstallNamespace)
+					}
+				}
+			},
+		},
+		{
+			name:                "install namespace of the placement is not resolved",
+			managedClusteraddon: []runtime.Object{},
+			clusterManagementAddon: func() *addonv1alpha1.ClusterManagementAddOn {
+				addon := addontesting.NewClusterManagementAddon("test", "", "").Build()
+				addon.Spec.InstallStrategy = addonv1alpha1.InstallStrategy{
+					Type: addonv1alpha1.AddonInstallStrategyPlacements,
+					Placements: []addonv1alpha1.PlacementStrategy{
+						{
+							PlacementRef: addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"},
+						},
+						{
+							PlacementRef: addonv1alpha1.PlacementRef{Name: "test-placement1", Namespace: "default"},
+							Configs: []addonv1alpha1.AddOnConfig{{
+								ConfigGroupResource: utils.AddOnDeploymentConfigGroupResource,
+								ConfigReferent:      addonv1alpha1.ConfigReferent{Namespace: "default", Name: "prod"},
+							}},
+						},
+					},
+				}
+				return addon
+			}(),
+			placements:         twoPlacements,
+			placementDecisions: twoPlacementDecisions,
+			// the addon of the other placement is still installed
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "create")
+				if namespace := actions[0].GetNamespace(); namespace != "cluster1" {
+					t.Errorf("expected the addon of cluster1 created, but got %s", namespace)
+				}
+			},
+			expectErr: true,
+		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			clusterObj := append(c.placements, c.placementDecisions...)
 			fakeClusterClient := fakecluster.NewSimpleClientset(clusterObj...)
-			fakeAddonClient := fakeaddon.NewSimpleClientset(c.managedClusteraddon...)
+			fakeAddonClient := fakeaddon.NewSimpleClientset(append(c.managedClusteraddon, c.configs...)...)
 
 			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
 			clusterInformers := clusterv1informers.NewSharedInformerFactory(fakeClusterClient, 10*time.Minute)
@@ -251,10 +478,11 @@ func TestAddonInstallReconcile(t *testing.T) {
 			}
 
 			reconcile := &managedClusterAddonInstallReconciler{
-				addonClient:                fakeAddonClient,
-				placementLister:            clusterInformers.Cluster().V1beta1().Placements().Lister(),
-				placementDecisionLister:    clusterInformers.Cluster().V1beta1().PlacementDecisions().Lister(),
-				managedClusterAddonIndexer: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetIndexer(),
+				addonClient:                 fakeAddonClient,
+				placementLister:             clusterInformers.Cluster().V1beta1().Placements().Lister(),
+				placementDecisionLister:     clusterInformers.Cluster().V1beta1().PlacementDecisions().Lister(),
+				managedClusterAddonIndexer:  addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetIndexer(),
+				addOnDeploymentConfigGetter: utils.NewAddOnDeploymentConfigGetter(fakeAddonClient),
 			}
 
 			_, _, err = reconcile.reconcile(context.TODO(), c.clusterManagementAddon)
@@ -264,7 +492,13 @@ func TestAddonInstallReconcile(t *testing.T) {
 			if err == nil && c.expectErr {
 				t.Errorf("Expect error but got no error")
 			}
-			c.validateAddonActions(t, fakeAddonClient.Actions())
+			var addonActions []clienttesting.Action
+			for _, action := range fakeAddonClient.Actions() {
+				if action.GetResource().Resource == "managedclusteraddons" {
+					addonActions = append(addonActions, action)
+				}
+			}
+			c.validateAddonActions(t, addonActions)
 		})
 	}
 }
@@ -0,0 +1,287 @@
+package fleetstatus
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/index"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+const (
+	controllerName = "addon-fleet-status-controller"
+
+	// resyncInterval is the interval to check whether the fleet status of the addons should be refreshed,
+	// it is the minimum refresh interval of the fleet status.
+	resyncInterval = 10 * time.Second
+
+	defaultRefreshInterval = 30 * time.Second
+
+	// maxFailingClusters is the max number of failing clusters listed in the fleet status.
+	maxFailingClusters = 100
+)
+
+// AddOnFleetStatus summarizes the status of an addon on all the managed clusters. It is published in the
+// data key FleetStatusDataKey of the ConfigMap FleetStatusConfigMapName(addonName), so that dashboards can
+// get the status of the addon without listing all the ManagedClusterAddOns.
+type AddOnFleetStatus struct {
+	// AddonName is the name of the addon.
+	AddonName string `json:"addonName"`
+
+	// Total is the number of the ManagedClusterAddOns of the addon.
+	Total int `json:"total"`
+
+	// Available is the number of the ManagedClusterAddOns whose Available condition is True.
+	Available int `json:"available"`
+
+	// Unavailable is the number of the ManagedClusterAddOns whose Available condition is False.
+	Unavailable int `json:"unavailable"`
+
+	// Unknown is the number of the ManagedClusterAddOns whose Available condition is Unknown or not set.
+	Unknown int `json:"unknown"`
+
+	// Configs is the number of clusters that have applied each config.
+	// +optional
+	Configs []ConfigDistribution `json:"configs,omitempty"`
+
+	// FailingClusters is the sorted names of the clusters on which the addon is unavailable, at most
+	// 100 clusters are listed.
+	// +optional
+	FailingClusters []string `json:"failingClusters,omitempty"`
+}
+
+// ConfigDistribution is the number of clusters that have applied a config.
+type ConfigDistribution struct {
+	addonv1alpha1.ConfigGroupResource `json:",inline"`
+	addonv1alpha1.ConfigReferent      `json:",inline"`
+
+	// SpecHash is the hash of spec of the config.
+	SpecHash string `json:"specHash"`
+
+	// Clusters is the number of clusters that have applied the config.
+	Clusters int `json:"clusters"`
+}
+
+// fleetStatusController periodically summarizes the ManagedClusterAddOns of each addon and publishes the
+// summary in a ConfigMap on the hub.
+type fleetStatusController struct {
+	kubeClient                   kubernetes.Interface
+	clusterManagementAddonLister addonlisterv1alpha1.ClusterManagementAddOnLister
+	managedClusterAddonIndexer   cache.Indexer
+	namespace                    string
+
+	// lastRefreshTime records when the fleet status of each addon is refreshed
+	lastRefreshTime map[string]time.Time
+	lock            sync.Mutex
+}
+
+// NewFleetStatusController returns a controller that publishes the fleet status of the addons in the
+// namespace. The fleet status of an addon is refreshed when the ClusterManagementAddOn changes, and
+// periodically at the interval set by the annotation FleetStatusRefreshIntervalAnnotationKey.
+func NewFleetStatusController(
+	kubeClient kubernetes.Interface,
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	clusterManagementAddonInformers addoninformerv1alpha1.ClusterManagementAddOnInformer,
+	namespace string,
+) factory.Controller {
+	c := &fleetStatusController{
+		kubeClient:                   kubeClient,
+		clusterManagementAddonLister: clusterManagementAddonInformers.Lister(),
+		managedClusterAddonIndexer:   addonInformers.Informer().GetIndexer(),
+		namespace:                    namespace,
+		lastRefreshTime:              map[string]time.Time{},
+	}
+
+	// the changes of the ManagedClusterAddOns do not trigger the refresh, they are summarized periodically.
+	return factory.New().
+		WithInformersQueueKeysFunc(
+			func(obj runtime.Object) []string {
+				accessor, _ := meta.Accessor(obj)
+				return []string{accessor.GetName()}
+			},
+			clusterManagementAddonInformers.Informer()).
+		WithBareInformers(addonInformers.Informer()).
+		WithSync(c.sync).
+		ResyncEvery(resyncInterval).
+		ToController(controllerName)
+}
+
+func (c *fleetStatusController) sync(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+	if key != factory.DefaultQueueKey {
+		cma, err := c.clusterManagementAddonLister.Get(key)
+		if errors.IsNotFound(err) {
+			c.forget(key)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return c.refresh(ctx, cma)
+	}
+
+	cmas, err := c.clusterManagementAddonLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, cma := range cmas {
+		if !c.refreshDue(cma) {
+			continue
+		}
+		if err := c.refresh(ctx, cma); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// refreshDue returns true if the refresh interval of the addon has passed since the last refresh.
+func (c *fleetStatusController) refreshDue(cma *addonv1alpha1.ClusterManagementAddOn) bool {
+	interval := defaultRefreshInterval
+	if value, ok := cma.Annotations[constants.FleetStatusRefreshIntervalAnnotationKey]; ok {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			klog.Warningf("invalid fleet status refresh interval %q of addon %s: %v", value, cma.Name, err)
+		} else {
+			interval = duration
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return time.Since(c.lastRefreshTime[cma.Name]) >= interval
+}
+
+func (c *fleetStatusController) forget(addonName string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.lastRefreshTime, addonName)
+}
+
+func (c *fleetStatusController) refresh(ctx context.Context, cma *addonv1alpha1.ClusterManagementAddOn) error {
+	objs, err := c.managedClusterAddonIndexer.ByIndex(index.ManagedClusterAddonByName, cma.Name)
+	if err != nil {
+		return err
+	}
+
+	var addons []*addonv1alpha1.ManagedClusterAddOn
+	for _, obj := range objs {
+		addons = append(addons, obj.(*addonv1alpha1.ManagedClusterAddOn))
+	}
+
+	data, err := json.Marshal(summarize(cma.Name, addons))
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.FleetStatusConfigMapName(cma.Name),
+			Namespace: c.namespace,
+			Labels: map[string]string{
+				addonv1alpha1.AddonLabelKey: cma.Name,
+			},
+			// the ConfigMap is deleted with the ClusterManagementAddOn
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cma, addonv1alpha1.GroupVersion.WithKind("ClusterManagementAddOn")),
+			},
+		},
+		Data: map[string]string{
+			constants.FleetStatusDataKey: string(data),
+		},
+	}
+
+	_, modified, err := utils.ApplyConfigMap(ctx, c.kubeClient.CoreV1(), configMap)
+	if err != nil {
+		return err
+	}
+	if modified {
+		klog.V(4).Infof("Fleet status of addon %s is updated", cma.Name)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lastRefreshTime[cma.Name] = time.Now()
+	return nil
+}
+
+// summarize returns the fleet status of the addons
+func summarize(addonName string, addons []*addonv1alpha1.ManagedClusterAddOn) *AddOnFleetStatus {
+	status := &AddOnFleetStatus{
+		AddonName: addonName,
+		Total:     len(addons),
+	}
+
+	configs := map[ConfigDistribution]int{}
+	for _, addon := range addons {
+		available := meta.FindStatusCondition(addon.Status.Conditions, addonv1alpha1.ManagedClusterAddOnConditionAvailable)
+		switch {
+		case available == nil || available.Status == metav1.ConditionUnknown:
+			status.Unknown++
+		case available.Status == metav1.ConditionTrue:
+			status.Available++
+		default:
+			status.Unavailable++
+			status.FailingClusters = append(status.FailingClusters, addon.Namespace)
+		}
+
+		for _, configReference := range addon.Status.ConfigReferences {
+			if configReference.LastAppliedConfig == nil {
+				continue
+			}
+			configs[ConfigDistribution{
+				ConfigGroupResource: configReference.ConfigGroupResource,
+				ConfigReferent:      configReference.LastAppliedConfig.ConfigReferent,
+				SpecHash:            configReference.LastAppliedConfig.SpecHash,
+			}]++
+		}
+	}
+
+	for config, count := range configs {
+		config.Clusters = count
+		status.Configs = append(status.Configs, config)
+	}
+	sort.Slice(status.Configs, func(i, j int) bool {
+		a, b := status.Configs[i], status.Configs[j]
+		if a.ConfigGroupResource != b.ConfigGroupResource {
+			if a.Group != b.Group {
+				return a.Group < b.Group
+			}
+			return a.Resource < b.Resource
+		}
+		if a.ConfigReferent != b.ConfigReferent {
+			if a.Namespace != b.Namespace {
+				return a.Namespace < b.Namespace
+			}
+			return a.Name < b.Name
+		}
+		return a.SpecHash < b.SpecHash
+	})
+
+	sort.Strings(status.FailingClusters)
+	if len(status.FailingClusters) > maxFailingClusters {
+		status.FailingClusters = status.FailingClusters[:maxFailingClusters]
+	}
+
+	return status
+}
@@ -0,0 +1,194 @@
+package fleetstatus
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/index"
+)
+
+func newAddon(cluster string, available metav1.ConditionStatus, appliedHash string) *addonv1alpha1.ManagedClusterAddOn {
+	addon := addontesting.NewAddon("test", cluster)
+	if len(available) > 0 {
+		addon.Status.Conditions = []metav1.Condition{{
+			Type:   addonv1alpha1.ManagedClusterAddOnConditionAvailable,
+			Status: available,
+		}}
+	}
+	if len(appliedHash) > 0 {
+		addon.Status.ConfigReferences = []addonv1alpha1.ConfigReference{{
+			ConfigGroupResource: addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"},
+			LastAppliedConfig: &addonv1alpha1.ConfigSpecHash{
+				ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test"},
+				SpecHash:       appliedHash,
+			},
+		}}
+	}
+	return addon
+}
+
+func TestSummarize(t *testing.T) {
+	addons := []*addonv1alpha1.ManagedClusterAddOn{
+		newAddon("cluster3", metav1.ConditionFalse, "hash2"),
+		newAddon("cluster1", metav1.ConditionTrue, "hash1"),
+		newAddon("cluster2", metav1.ConditionTrue, "hash1"),
+		newAddon("cluster4", metav1.ConditionUnknown, ""),
+		newAddon("cluster5", "", ""),
+		newAddon("cluster0", metav1.ConditionFalse, ""),
+	}
+
+	expected := &AddOnFleetStatus{
+		AddonName:   "test",
+		Total:       6,
+		Available:   2,
+		Unavailable: 2,
+		Unknown:     2,
+		Configs: []ConfigDistribution{
+			{
+				ConfigGroupResource: addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"},
+				ConfigReferent:      addonv1alpha1.ConfigReferent{Name: "test"},
+				SpecHash:            "hash1",
+				Clusters:            2,
+			},
+			{
+				ConfigGroupResource: addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"},
+				ConfigReferent:      addonv1alpha1.ConfigReferent{Name: "test"},
+				SpecHash:            "hash2",
+				Clusters:            1,
+			},
+		},
+		FailingClusters: []string{"cluster0", "cluster3"},
+	}
+
+	actual := summarize("test", addons)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected fleet status %v, but got %v", expected, actual)
+	}
+}
+
+func TestSync(t *testing.T) {
+	cases := []struct {
+		name            string
+		syncKey         string
+		cmas            []runtime.Object
+		addons          []runtime.Object
+		existing        []runtime.Object
+		lastRefreshTime map[string]time.Time
+		validateActions func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:            "cma is not found",
+			syncKey:         "test",
+			validateActions: addontesting.AssertNoActions,
+		},
+		{
+			name:    "create fleet status",
+			syncKey: "test",
+			cmas:    []runtime.Object{addontesting.NewClusterManagementAddon("test", "", "").Build()},
+			addons:  []runtime.Object{newAddon("cluster1", metav1.ConditionTrue, "")},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "get", "create")
+				configMap := actions[1].(clienttesting.CreateActionImpl).Object.(*corev1.ConfigMap)
+				if configMap.Name != "addon-test-fleet-status" || configMap.Namespace != "hub" {
+					t.Errorf("unexpected fleet status configmap %s/%s", configMap.Namespace, configMap.Name)
+				}
+				status := &AddOnFleetStatus{}
+				if err := json.Unmarshal([]byte(configMap.Data[constants.FleetStatusDataKey]), status); err != nil {
+					t.Fatal(err)
+				}
+				if status.Total != 1 || status.Available != 1 {
+					t.Errorf("unexpected fleet status %v", status)
+				}
+			},
+		},
+		{
+			name:    "periodic refresh is not due",
+			syncKey: factory.DefaultQueueKey,
+			cmas:    []runtime.Object{addontesting.NewClusterManagementAddon("test", "", "").Build()},
+			addons:  []runtime.Object{newAddon("cluster1", metav1.ConditionTrue, "")},
+			lastRefreshTime: map[string]time.Time{
+				"test": time.Now(),
+			},
+			validateActions: addontesting.AssertNoActions,
+		},
+		{
+			name:    "periodic refresh with the refresh interval",
+			syncKey: factory.DefaultQueueKey,
+			cmas: []runtime.Object{func() *addonv1alpha1.ClusterManagementAddOn {
+				cma := addontesting.NewClusterManagementAddon("test", "", "").Build()
+				cma.Annotations = map[string]string{constants.FleetStatusRefreshIntervalAnnotationKey: "1m"}
+				return cma
+			}()},
+			addons: []runtime.Object{newAddon("cluster1", metav1.ConditionFalse, "")},
+			lastRefreshTime: map[string]time.Time{
+				"test": time.Now().Add(-2 * time.Minute),
+			},
+			existing: []runtime.Object{&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "addon-test-fleet-status", Namespace: "hub"},
+			}},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "get", "update")
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeKubeClient := fakekube.NewSimpleClientset(c.existing...)
+			fakeAddonClient := fakeaddon.NewSimpleClientset(append(c.cmas, c.addons...)...)
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+			err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().AddIndexers(
+				cache.Indexers{
+					index.ManagedClusterAddonByName: index.IndexManagedClusterAddonByName,
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, obj := range c.addons {
+				if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, obj := range c.cmas {
+				if err := addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			lastRefreshTime := c.lastRefreshTime
+			if lastRefreshTime == nil {
+				lastRefreshTime = map[string]time.Time{}
+			}
+			controller := &fleetStatusController{
+				kubeClient:                   fakeKubeClient,
+				clusterManagementAddonLister: addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Lister(),
+				managedClusterAddonIndexer:   addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetIndexer(),
+				namespace:                    "hub",
+				lastRefreshTime:              lastRefreshTime,
+			}
+
+			err = controller.sync(context.TODO(), addontesting.NewFakeSyncContext(t), c.syncKey)
+			if err != nil {
+				t.Errorf("expected no error when sync: %v", err)
+			}
+			c.validateActions(t, fakeKubeClient.Actions())
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package templateaddon
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
+	"open-cluster-management.io/addon-framework/pkg/templateagent"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+const controllerName = "template-addon-controller"
+
+// startManagerFunc starts an addon manager of the agent until the context is done, it returns a channel closed
+// when the controllers of the manager are stopped.
+type startManagerFunc func(ctx context.Context, agentAddon agent.AgentAddon) (<-chan struct{}, error)
+
+// templateAddonController runs an addon manager for each ClusterManagementAddOn supporting the AddOnTemplate
+// config, so the template addons are driven by the generic manager without a custom manager binary. The
+// manager of an addon is stopped when its ClusterManagementAddOn is deleted or no longer supports the template.
+type templateAddonController struct {
+	kubeClient                   kubernetes.Interface
+	clusterManagementAddonLister addonlisterv1alpha1.ClusterManagementAddOnLister
+	managedClusterAddonLister    addonlisterv1alpha1.ManagedClusterAddOnLister
+	addOnDeploymentConfigLister  addonlisterv1alpha1.AddOnDeploymentConfigLister
+	templateInformers            dynamicinformer.DynamicSharedInformerFactory
+	startManager                 startManagerFunc
+
+	lock     sync.Mutex
+	managers map[string]context.CancelFunc
+	// running tracks the managers until their controllers are stopped
+	running sync.WaitGroup
+}
+
+// templateAddonRunner runs the templateAddonController, and waits for the managers of the template addons to
+// drain their in-flight syncs after the controller is stopped.
+type templateAddonRunner struct {
+	factory.Controller
+	controller *templateAddonController
+}
+
+func (r *templateAddonRunner) Run(ctx context.Context, workers int) {
+	r.Controller.Run(ctx, workers)
+	r.controller.stopManagers()
+}
+
+func NewTemplateAddonController(
+	kubeConfig *rest.Config,
+	kubeClient kubernetes.Interface,
+	clusterManagementAddonInformers addoninformerv1alpha1.ClusterManagementAddOnInformer,
+	managedClusterAddonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	addOnDeploymentConfigInformers addoninformerv1alpha1.AddOnDeploymentConfigInformer,
+	templateInformers dynamicinformer.DynamicSharedInformerFactory,
+	managerOptions ...addonmanager.Option,
+) factory.Controller {
+	c := &templateAddonController{
+		kubeClient:                   kubeClient,
+		clusterManagementAddonLister: clusterManagementAddonInformers.Lister(),
+		managedClusterAddonLister:    managedClusterAddonInformers.Lister(),
+		addOnDeploymentConfigLister:  addOnDeploymentConfigInformers.Lister(),
+		templateInformers:            templateInformers,
+		startManager: func(ctx context.Context, agentAddon agent.AgentAddon) (<-chan struct{}, error) {
+			mgr, err := addonmanager.New(kubeConfig, managerOptions...)
+			if err != nil {
+				return nil, err
+			}
+			if err := mgr.AddAgent(agentAddon); err != nil {
+				return nil, err
+			}
+			if err := mgr.Start(ctx); err != nil {
+				return nil, err
+			}
+			return mgr.Stopped(), nil
+		},
+		managers: map[string]context.CancelFunc{},
+	}
+
+	return &templateAddonRunner{
+		Controller: factory.New().WithInformersQueueKeysFunc(
+			func(obj runtime.Object) []string {
+				accessor, _ := meta.Accessor(obj)
+				return []string{accessor.GetName()}
+			},
+			clusterManagementAddonInformers.Informer()).
+			WithBareInformers(managedClusterAddonInformers.Informer(), addOnDeploymentConfigInformers.Informer()).
+			WithSync(c.sync).ToController(controllerName),
+		controller: c,
+	}
+}
+
+func (c *templateAddonController) sync(ctx context.Context, syncCtx factory.SyncContext, addonName string) error {
+	cma, err := c.clusterManagementAddonLister.Get(addonName)
+	switch {
+	case errors.IsNotFound(err):
+		c.stopManager(addonName)
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if !cma.DeletionTimestamp.IsZero() || !supportsTemplate(cma) {
+		c.stopManager(addonName)
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.managers[addonName]; ok {
+		return nil
+	}
+
+	// the template informer is started with the first template addon, the AddOnTemplate API may not be
+	// installed on the hubs without template addons.
+	templateInformer := c.templateInformers.ForResource(templateagent.AddOnTemplateGVR)
+	c.templateInformers.Start(ctx.Done())
+
+	agentAddon := templateagent.NewTemplateAgentAddon(
+		addonName,
+		templateInformer.Lister(),
+		c.managedClusterAddonLister,
+		c.kubeClient,
+		utils.NewAddOnDeploymentConfigGetterFromLister(c.addOnDeploymentConfigLister),
+	)
+	managerCtx, cancel := context.WithCancel(ctx)
+	stopped, err := c.startManager(managerCtx, agentAddon)
+	if err != nil {
+		cancel()
+		return err
+	}
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		<-stopped
+	}()
+	klog.Infof("Started the addon manager of template addon %s", addonName)
+	c.managers[addonName] = cancel
+	return nil
+}
+
+func (c *templateAddonController) stopManager(addonName string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cancel, ok := c.managers[addonName]
+	if !ok {
+		return
+	}
+	cancel()
+	delete(c.managers, addonName)
+	klog.Infof("Stopped the addon manager of template addon %s", addonName)
+}
+
+// stopManagers stops the managers of all the template addons and waits for their in-flight syncs to be drained.
+func (c *templateAddonController) stopManagers() {
+	c.lock.Lock()
+	for addonName, cancel := range c.managers {
+		cancel()
+		delete(c.managers, addonName)
+	}
+	c.lock.Unlock()
+	c.running.Wait()
+}
+
+// supportsTemplate returns whether the AddOnTemplate is a supported config of the addon.
+func supportsTemplate(cma *addonapiv1alpha1.ClusterManagementAddOn) bool {
+	for _, config := range cma.Spec.SupportedConfigs {
+		if config.ConfigGroupResource == templateagent.AddOnTemplateGR {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package templateaddon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/templateagent"
+)
+
+func newTemplateAddon(name string) *addonapiv1alpha1.ClusterManagementAddOn {
+	cma := addontesting.NewClusterManagementAddon(name, "", "").Build()
+	cma.Spec.SupportedConfigs = []addonapiv1alpha1.ConfigMeta{
+		{ConfigGroupResource: templateagent.AddOnTemplateGR},
+	}
+	return cma
+}
+
+func TestSync(t *testing.T) {
+	cases := []struct {
+		name             string
+		syncKey          string
+		running          []string
+		cmas             []runtime.Object
+		expectedStarted  []string
+		expectedStopped  []string
+		expectedManagers []string
+	}{
+		{
+			name:    "no cma",
+			syncKey: "test",
+		},
+		{
+			name:    "cma not supporting template",
+			syncKey: "test",
+			cmas:    []runtime.Object{addontesting.NewClusterManagementAddon("test", "", "").Build()},
+		},
+		{
+			name:             "start manager of template addon",
+			syncKey:          "test",
+			cmas:             []runtime.Object{newTemplateAddon("test")},
+			expectedStarted:  []string{"test"},
+			expectedManagers: []string{"test"},
+		},
+		{
+			name:             "manager is running",
+			syncKey:          "test",
+			running:          []string{"test"},
+			cmas:             []runtime.Object{newTemplateAddon("test")},
+			expectedManagers: []string{"test"},
+		},
+		{
+			name:            "stop manager of deleted cma",
+			syncKey:         "test",
+			running:         []string{"test"},
+			expectedStopped: []string{"test"},
+		},
+		{
+			name:            "stop manager of cma not supporting template",
+			syncKey:         "test",
+			running:         []string{"test"},
+			cmas:            []runtime.Object{addontesting.NewClusterManagementAddon("test", "", "").Build()},
+			expectedStopped: []string{"test"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			fakeAddonClient := fakeaddon.NewSimpleClientset(c.cmas...)
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+			for _, obj := range c.cmas {
+				if err := addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+			fakeDynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{templateagent.AddOnTemplateGVR: "AddOnTemplateList"})
+
+			var started, stopped []string
+			controller := &templateAddonController{
+				kubeClient:                   kubefake.NewSimpleClientset(),
+				clusterManagementAddonLister: addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Lister(),
+				managedClusterAddonLister:    addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				addOnDeploymentConfigLister:  addonInformers.Addon().V1alpha1().AddOnDeploymentConfigs().Lister(),
+				templateInformers:            dynamicinformer.NewDynamicSharedInformerFactory(fakeDynamicClient, 10*time.Minute),
+				startManager: func(ctx context.Context, agentAddon agent.AgentAddon) (<-chan struct{}, error) {
+					started = append(started, agentAddon.GetAgentAddonOptions().AddonName)
+					managerStopped := make(chan struct{})
+					go func() {
+						defer close(managerStopped)
+						<-ctx.Done()
+					}()
+					return managerStopped, nil
+				},
+				managers: map[string]context.CancelFunc{},
+			}
+			for _, name := range c.running {
+				addonName := name
+				controller.managers[addonName] = func() { stopped = append(stopped, addonName) }
+			}
+
+			syncContext := addontesting.NewFakeSyncContext(t)
+			if err := controller.sync(ctx, syncContext, c.syncKey); err != nil {
+				t.Fatal(err)
+			}
+
+			assertNames(t, "started", started, c.expectedStarted)
+			assertNames(t, "stopped", stopped, c.expectedStopped)
+			var managers []string
+			for name := range controller.managers {
+				managers = append(managers, name)
+			}
+			assertNames(t, "running", managers, c.expectedManagers)
+
+			// the started managers are stopped and drained with the controller
+			controller.stopManagers()
+			if len(controller.managers) != 0 {
+				t.Errorf("expected all the managers stopped, but got %v", controller.managers)
+			}
+		})
+	}
+}
+
+func assertNames(t *testing.T, kind string, actual, expected []string) {
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %s managers %v, but got %v", kind, expected, actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("expected %s managers %v, but got %v", kind, expected, actual)
+		}
+	}
+}
@@ -2,8 +2,15 @@ package manager
 
 import (
 	"context"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
@@ -11,15 +18,56 @@ import (
 	clusterclientset "open-cluster-management.io/api/client/cluster/clientset/versioned"
 	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions"
 
+	"open-cluster-management.io/addon-framework/pkg/addonmanager"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/factory"
 	"open-cluster-management.io/addon-framework/pkg/index"
 	"open-cluster-management.io/addon-framework/pkg/manager/controllers/addonconfiguration"
+	"open-cluster-management.io/addon-framework/pkg/manager/controllers/addonconfigvalidation"
 	"open-cluster-management.io/addon-framework/pkg/manager/controllers/addonmanagement"
 	"open-cluster-management.io/addon-framework/pkg/manager/controllers/addonowner"
+	"open-cluster-management.io/addon-framework/pkg/manager/controllers/fleetstatus"
 	"open-cluster-management.io/addon-framework/pkg/manager/controllers/managementaddonstatus"
+	"open-cluster-management.io/addon-framework/pkg/manager/controllers/templateaddon"
 	"open-cluster-management.io/addon-framework/pkg/utils"
 )
 
+// defaultComponentNamespace is the namespace to publish the fleet status of the addons if the manager
+// does not run in a pod.
+const defaultComponentNamespace = "open-cluster-management-hub"
+
+// ManagerOptions are the options of the addon manager.
+type ManagerOptions struct {
+	// MaxConcurrentRenders is the number of the template addons rendered and applied concurrently by the manager
+	// of each ClusterManagementAddOn.
+	MaxConcurrentRenders int
+}
+
+// NewManagerOptions returns the options of the addon manager with default values set
+func NewManagerOptions() *ManagerOptions {
+	return &ManagerOptions{
+		MaxConcurrentRenders: 1,
+	}
+}
+
+// AddFlags registers and binds the flags of the addon manager
+func (o *ManagerOptions) AddFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&o.MaxConcurrentRenders, "max-concurrent-renders", o.MaxConcurrentRenders,
+		"The number of the addons rendered and applied concurrently by the manager of each ClusterManagementAddOn, "+
+			"e.g. when all the addons of a ClusterManagementAddOn are reconciled after its configs are changed.")
+}
+
+// RunManager runs the addon manager with the default options.
 func RunManager(ctx context.Context, kubeConfig *rest.Config) error {
+	return NewManagerOptions().RunManager(ctx, kubeConfig)
+}
+
+// RunManager runs the addon manager with the options.
+func (o *ManagerOptions) RunManager(ctx context.Context, kubeConfig *rest.Config) error {
+	kubeClient, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return err
+	}
+
 	hubClusterClient, err := clusterclientset.NewForConfig(kubeConfig)
 	if err != nil {
 		return err
@@ -30,8 +78,16 @@ func RunManager(ctx context.Context, kubeConfig *rest.Config) error {
 		return err
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return err
+	}
+
+	eventRecorder := utils.NewAddonEventRecorder(kubeClient, "addon-manager")
+
 	clusterInformerFactory := clusterinformers.NewSharedInformerFactory(hubClusterClient, 30*time.Minute)
 	addonInformerFactory := addoninformers.NewSharedInformerFactory(addonClient, 30*time.Minute)
+	templateInformerFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, 30*time.Minute)
 
 	err = addonInformerFactory.Addon().V1alpha1().ClusterManagementAddOns().Informer().AddIndexers(
 		cache.Indexers{
@@ -55,15 +111,19 @@ func RunManager(ctx context.Context, kubeConfig *rest.Config) error {
 		addonInformerFactory.Addon().V1alpha1().ClusterManagementAddOns(),
 		clusterInformerFactory.Cluster().V1beta1().Placements(),
 		clusterInformerFactory.Cluster().V1beta1().PlacementDecisions(),
+		addonInformerFactory.Addon().V1alpha1().AddOnDeploymentConfigs(),
 	)
 
 	addonConfigurationController := addonconfiguration.NewAddonConfigurationController(
 		addonClient,
+		eventRecorder,
 		addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns(),
 		addonInformerFactory.Addon().V1alpha1().ClusterManagementAddOns(),
 		clusterInformerFactory.Cluster().V1beta1().Placements(),
 		clusterInformerFactory.Cluster().V1beta1().PlacementDecisions(),
+		clusterInformerFactory.Cluster().V1().ManagedClusters(),
 		utils.ManagedByAddonManager,
+		addonconfiguration.NewConfigMapSegmentStateStore(kubeClient, componentNamespace()),
 	)
 
 	addonOwnerController := addonowner.NewAddonOwnerController(
@@ -73,20 +133,70 @@ func RunManager(ctx context.Context, kubeConfig *rest.Config) error {
 		utils.ManagedByAddonManager,
 	)
 
+	addonConfigValidationController := addonconfigvalidation.NewAddonConfigValidationController(
+		addonClient,
+		addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns(),
+		addonInformerFactory.Addon().V1alpha1().ClusterManagementAddOns(),
+		utils.ManagedByAddonManager,
+	)
+
 	mgmtAddonStatusController := managementaddonstatus.NewMagementAddonStatusController(
 		addonClient,
 		addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns(),
 		addonInformerFactory.Addon().V1alpha1().ClusterManagementAddOns(),
 	)
 
-	go addonManagementController.Run(ctx, 2)
-	go addonConfigurationController.Run(ctx, 2)
-	go addonOwnerController.Run(ctx, 2)
-	go mgmtAddonStatusController.Run(ctx, 2)
+	fleetStatusController := fleetstatus.NewFleetStatusController(
+		kubeClient,
+		addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns(),
+		addonInformerFactory.Addon().V1alpha1().ClusterManagementAddOns(),
+		componentNamespace(),
+	)
+
+	templateAddonController := templateaddon.NewTemplateAddonController(
+		kubeConfig,
+		kubeClient,
+		addonInformerFactory.Addon().V1alpha1().ClusterManagementAddOns(),
+		addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns(),
+		addonInformerFactory.Addon().V1alpha1().AddOnDeploymentConfigs(),
+		templateInformerFactory,
+		addonmanager.WithDeployWorkers(o.MaxConcurrentRenders),
+	)
+
+	// the controllers stop taking new keys when the context is done, RunManager returns after their in-flight
+	// syncs are drained, so the leader election is released after them.
+	var controllers sync.WaitGroup
+	runController := func(controller factory.Controller, workers int) {
+		controllers.Add(1)
+		go func() {
+			defer controllers.Done()
+			controller.Run(ctx, workers)
+		}()
+	}
+	runController(addonManagementController, 2)
+	runController(addonConfigurationController, 2)
+	runController(addonOwnerController, 2)
+	runController(addonConfigValidationController, 2)
+	runController(mgmtAddonStatusController, 2)
+	runController(fleetStatusController, 1)
+	runController(templateAddonController, 1)
 
 	go clusterInformerFactory.Start(ctx.Done())
 	go addonInformerFactory.Start(ctx.Done())
 
 	<-ctx.Done()
+	controllers.Wait()
 	return nil
 }
+
+// componentNamespace returns the namespace of the manager pod
+func componentNamespace() string {
+	data, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err != nil {
+		return defaultComponentNamespace
+	}
+	if namespace := strings.TrimSpace(string(data)); len(namespace) > 0 {
+		return namespace
+	}
+	return defaultComponentNamespace
+}
@@ -0,0 +1,189 @@
+package templateagent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+// signingDuration is the duration of the certificates signed by the custom signers of the templates.
+const signingDuration = 30 * 24 * time.Hour
+
+// csrConfigurations returns the registration configs of the template of the addon on the cluster.
+func (a *templateAgent) csrConfigurations(cluster *clusterv1.ManagedCluster) []addonapiv1alpha1.RegistrationConfig {
+	template, _, err := a.clusterTemplate(cluster.Name)
+	if err != nil {
+		klog.Warningf("Failed to get the addon template of addon %s/%s: %v", cluster.Name, a.addonName, err)
+		return nil
+	}
+
+	var configs []addonapiv1alpha1.RegistrationConfig
+	for _, registration := range template.Spec.Registration {
+		switch registration.Type {
+		case RegistrationTypeKubeClient:
+			configs = append(configs, agent.KubeClientSignerConfigurations(a.addonName, templateAgentName)(cluster)...)
+		case RegistrationTypeCustomSigner:
+			if registration.CustomSigner == nil {
+				continue
+			}
+			// the kubernetes signers are not signed by the signing CA of the template, and the kube client
+			// registration is used to get a kube client certificate.
+			if strings.HasPrefix(registration.CustomSigner.SignerName, "kubernetes.io/") {
+				klog.Warningf("The custom signer %s of addon %s/%s is not allowed",
+					registration.CustomSigner.SignerName, cluster.Name, a.addonName)
+				continue
+			}
+			subject := addonapiv1alpha1.Subject{
+				User:   agent.DefaultUser(cluster.Name, a.addonName, templateAgentName),
+				Groups: agent.DefaultGroups(cluster.Name, a.addonName),
+			}
+			if registration.CustomSigner.Subject != nil {
+				subject = *registration.CustomSigner.Subject
+			}
+			configs = append(configs, addonapiv1alpha1.RegistrationConfig{
+				SignerName: registration.CustomSigner.SignerName,
+				Subject:    subject,
+			})
+		}
+	}
+	return configs
+}
+
+// permissionConfig binds the hub permissions of the KubeClient registrations of the template to the agent.
+func (a *templateAgent) permissionConfig(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+	template, err := a.addonTemplate(addon)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, registration := range template.Spec.Registration {
+		if registration.Type != RegistrationTypeKubeClient || registration.KubeClient == nil {
+			continue
+		}
+		for _, permission := range registration.KubeClient.HubPermissions {
+			binding, err := a.hubPermissionBinding(cluster, addon, permission)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if _, _, err := utils.ApplyRoleBinding(context.TODO(), a.kubeClient.RbacV1(), binding); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// hubPermissionBinding returns the RoleBinding binding the hub permission to the default group of the agent.
+// Only the roles named with the HubPermissionRolePrefix of the addon are bound.
+func (a *templateAgent) hubPermissionBinding(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn,
+	permission HubPermissionConfig) (*rbacv1.RoleBinding, error) {
+	var roleRef rbacv1.RoleRef
+	switch {
+	case permission.Type == HubPermissionsBindingCurrentCluster && permission.CurrentCluster != nil:
+		roleRef = rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     permission.CurrentCluster.ClusterRoleName,
+		}
+	case permission.Type == HubPermissionsBindingSingleNamespace && permission.SingleNamespace != nil:
+		roleRef = permission.SingleNamespace.RoleRef
+	default:
+		return nil, fmt.Errorf("invalid hub permission of type %q in the addon template of addon %s", permission.Type, addon.Name)
+	}
+	if err := validateHubPermissionRoleRef(addon.Name, roleRef); err != nil {
+		return nil, err
+	}
+
+	subjects := []rbacv1.Subject{
+		{
+			Kind:     rbacv1.GroupKind,
+			APIGroup: rbacv1.GroupName,
+			Name:     agent.DefaultGroups(cluster.Name, addon.Name)[0],
+		},
+	}
+
+	if permission.Type == HubPermissionsBindingCurrentCluster {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("open-cluster-management:%s:agent", addon.Name),
+				Namespace: cluster.Name,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion:         addonapiv1alpha1.GroupVersion.String(),
+						Kind:               "ManagedClusterAddOn",
+						Name:               addon.Name,
+						UID:                addon.UID,
+						BlockOwnerDeletion: pointer.Bool(true),
+					},
+				},
+			},
+			RoleRef:  roleRef,
+			Subjects: subjects,
+		}, nil
+	}
+
+	// the binding is out of the cluster namespace, it is named by the cluster and not owned by the addon
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("open-cluster-management:%s:%s:agent", addon.Name, cluster.Name),
+			Namespace: permission.SingleNamespace.Namespace,
+		},
+		RoleRef:  roleRef,
+		Subjects: subjects,
+	}, nil
+}
+
+// validateHubPermissionRoleRef returns error if the role of a hub permission of the template of the addon is not a
+// Role or ClusterRole named with the HubPermissionRolePrefix of the addon.
+func validateHubPermissionRoleRef(addonName string, roleRef rbacv1.RoleRef) error {
+	if roleRef.APIGroup != rbacv1.GroupName || (roleRef.Kind != "Role" && roleRef.Kind != "ClusterRole") {
+		return fmt.Errorf("invalid role %s/%s in the hub permissions of addon %s", roleRef.Kind, roleRef.Name, addonName)
+	}
+	if !strings.HasPrefix(roleRef.Name, HubPermissionRolePrefix(addonName)) {
+		return fmt.Errorf("the role %s/%s in the hub permissions of addon %s is not named with the prefix %q",
+			roleRef.Kind, roleRef.Name, addonName, HubPermissionRolePrefix(addonName))
+	}
+	return nil
+}
+
+// csrSign signs the csr of a custom signer of the template by the signing CA of the signer.
+func (a *templateAgent) csrSign(csr *certificatesv1.CertificateSigningRequest) []byte {
+	clusterName := csr.Labels[clusterv1.ClusterNameLabelKey]
+	template, _, err := a.clusterTemplate(clusterName)
+	if err != nil {
+		klog.Errorf("Failed to get the addon template of addon %s/%s: %v", clusterName, a.addonName, err)
+		return nil
+	}
+
+	for _, registration := range template.Spec.Registration {
+		if registration.Type != RegistrationTypeCustomSigner || registration.CustomSigner == nil ||
+			registration.CustomSigner.SignerName != csr.Spec.SignerName {
+			continue
+		}
+		ca := registration.CustomSigner.SigningCA
+		secret, err := a.kubeClient.CoreV1().Secrets(ca.Namespace).Get(context.TODO(), ca.Name, metav1.GetOptions{})
+		if err != nil {
+			klog.Errorf("Failed to get the signing CA %s/%s of signer %s: %v", ca.Namespace, ca.Name, csr.Spec.SignerName, err)
+			return nil
+		}
+		return utils.DefaultSignerWithExpiry(secret.Data[corev1.TLSPrivateKeyKey], secret.Data[corev1.TLSCertKey],
+			signingDuration)(csr)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package templateagent
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+// templateAgentName is the agent name in the default user of the agents of the template addons.
+const templateAgentName = "agent"
+
+// The variables substituted in the manifests of the template, e.g. {{CLUSTER_NAME}}. The customized variables
+// of the AddOnDeploymentConfigs of the addon are substituted by their names as well.
+const (
+	ClusterNameVariable      = "CLUSTER_NAME"
+	InstallNamespaceVariable = "INSTALL_NAMESPACE"
+)
+
+// templateAgent is an agent.AgentAddon rendered entirely from the AddOnTemplate referenced by the config
+// references of each ManagedClusterAddOn, so an addon is driven without a custom manager binary.
+type templateAgent struct {
+	addonName      string
+	templateLister cache.GenericLister
+	addonLister    addonlisterv1alpha1.ManagedClusterAddOnLister
+	kubeClient     kubernetes.Interface
+	configGetter   utils.AddOnDeploymentConfigGetter
+}
+
+// NewTemplateAgentAddon returns the agent of the template addon. The templates are read by the templateLister
+// of the AddOnTemplateGVR.
+func NewTemplateAgentAddon(
+	addonName string,
+	templateLister cache.GenericLister,
+	addonLister addonlisterv1alpha1.ManagedClusterAddOnLister,
+	kubeClient kubernetes.Interface,
+	configGetter utils.AddOnDeploymentConfigGetter,
+) agent.AgentAddon {
+	return &templateAgent{
+		addonName:      addonName,
+		templateLister: templateLister,
+		addonLister:    addonLister,
+		kubeClient:     kubeClient,
+		configGetter:   configGetter,
+	}
+}
+
+func (a *templateAgent) GetAgentAddonOptions() agent.AgentAddonOptions {
+	return agent.AgentAddonOptions{
+		AddonName: a.addonName,
+		Registration: &agent.RegistrationOption{
+			CSRConfigurations: a.csrConfigurations,
+			CSRApproveCheck:   utils.DefaultCSRApprovePolicy(templateAgentName, a.csrConfigurations),
+			PermissionConfig:  a.permissionConfig,
+			CSRSign:           a.csrSign,
+		},
+		HealthProber:          &agent.HealthProber{Type: agent.HealthProberTypeWork},
+		SupportedConfigGVRs:   []schema.GroupVersionResource{AddOnTemplateGVR, utils.AddOnDeploymentConfigGVR},
+		AgentInstallNamespace: utils.AgentInstallNamespaceFromDeploymentConfigFunc(a.configGetter),
+	}
+}
+
+func (a *templateAgent) Manifests(cluster *clusterv1.ManagedCluster,
+	addon *addonapiv1alpha1.ManagedClusterAddOn) ([]runtime.Object, error) {
+	template, err := a.addonTemplate(addon)
+	if err != nil {
+		return nil, err
+	}
+
+	variables, err := a.variables(cluster, addon)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []runtime.Object
+	for _, manifest := range template.Spec.AgentSpec.Workload.Manifests {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+			return nil, fmt.Errorf("invalid manifest in addon template %s: %v", template.Name, err)
+		}
+		obj.Object = substitute(obj.Object, variables).(map[string]interface{})
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// addonTemplate returns the AddOnTemplate in the config references of the addon.
+func (a *templateAgent) addonTemplate(addon *addonapiv1alpha1.ManagedClusterAddOn) (*AddOnTemplate, error) {
+	for _, config := range addon.Status.ConfigReferences {
+		if config.ConfigGroupResource != AddOnTemplateGR {
+			continue
+		}
+		name := config.Name
+		if config.DesiredConfig != nil {
+			name = config.DesiredConfig.Name
+		}
+		obj, err := a.templateLister.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return templateFromObject(obj)
+	}
+	return nil, fmt.Errorf("no addon template is referenced by addon %s/%s", addon.Namespace, addon.Name)
+}
+
+// clusterTemplate returns the AddOnTemplate of the addon on the cluster.
+func (a *templateAgent) clusterTemplate(clusterName string) (*AddOnTemplate, *addonapiv1alpha1.ManagedClusterAddOn, error) {
+	addon, err := a.addonLister.ManagedClusterAddOns(clusterName).Get(a.addonName)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := a.addonTemplate(addon)
+	return template, addon, err
+}
+
+// variables returns the values of the variables substituted in the manifests of the addon.
+func (a *templateAgent) variables(cluster *clusterv1.ManagedCluster,
+	addon *addonapiv1alpha1.ManagedClusterAddOn) (map[string]string, error) {
+	variables := map[string]string{}
+	for _, config := range addon.Status.ConfigReferences {
+		if config.ConfigGroupResource.Group != utils.AddOnDeploymentConfigGVR.Group ||
+			config.ConfigGroupResource.Resource != utils.AddOnDeploymentConfigGVR.Resource {
+			continue
+		}
+		referent := config.ConfigReferent
+		if config.DesiredConfig != nil {
+			referent = config.DesiredConfig.ConfigReferent
+		}
+		deploymentConfig, err := a.configGetter.Get(context.Background(), referent.Namespace, referent.Name)
+		if err != nil {
+			return nil, err
+		}
+		values, err := addonfactory.ToAddOnCustomizedVariableValues(*deploymentConfig)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range values {
+			variables[name] = fmt.Sprint(value)
+		}
+	}
+
+	installNamespace, err := utils.AgentInstallNamespaceFromDeploymentConfigFunc(a.configGetter)(addon)
+	if err != nil {
+		return nil, err
+	}
+	if len(installNamespace) == 0 {
+		installNamespace = addon.Spec.InstallNamespace
+	}
+	if len(installNamespace) == 0 {
+		installNamespace = addonfactory.AddonDefaultInstallNamespace
+	}
+	variables[ClusterNameVariable] = cluster.Name
+	variables[InstallNamespaceVariable] = installNamespace
+	return variables, nil
+}
+
+// substitute replaces the {{NAME}} of the variables in the string values of the object.
+func substitute(obj interface{}, variables map[string]string) interface{} {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var oldnew []string
+	for _, name := range names {
+		oldnew = append(oldnew, fmt.Sprintf("{{%s}}", name), variables[name])
+	}
+	return substituteWith(obj, strings.NewReplacer(oldnew...))
+}
+
+func substituteWith(obj interface{}, replacer *strings.Replacer) interface{} {
+	switch value := obj.(type) {
+	case string:
+		return replacer.Replace(value)
+	case map[string]interface{}:
+		for k, v := range value {
+			value[k] = substituteWith(v, replacer)
+		}
+		return value
+	case []interface{}:
+		for i, v := range value {
+			value[i] = substituteWith(v, replacer)
+		}
+		return value
+	default:
+		return value
+	}
+}
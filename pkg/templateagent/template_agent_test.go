@@ -0,0 +1,241 @@
+package templateagent
+
+import (
+	"context"
+	"testing"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+)
+
+func newTemplate(t *testing.T) *unstructured.Unstructured {
+	deployment := addontesting.NewUnstructured("apps/v1", "Deployment", "{{INSTALL_NAMESPACE}}", "test-agent")
+	if err := unstructured.SetNestedField(deployment.Object, "{{IMAGE}}", "spec", "image"); err != nil {
+		t.Fatal(err)
+	}
+	if err := unstructured.SetNestedStringSlice(deployment.Object, []string{"--cluster-name={{CLUSTER_NAME}}"}, "spec", "args"); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := deployment.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &AddOnTemplate{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "addon.open-cluster-management.io/v1alpha1", Kind: "AddOnTemplate"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test-template"},
+		Spec: AddOnTemplateSpec{
+			AddonName: "test",
+			AgentSpec: workapiv1.ManifestWorkSpec{
+				Workload: workapiv1.ManifestsTemplate{Manifests: []workapiv1.Manifest{{RawExtension: runtime.RawExtension{Raw: raw}}}},
+			},
+			Registration: []RegistrationSpec{
+				{
+					Type: RegistrationTypeKubeClient,
+					KubeClient: &KubeClientRegistrationConfig{
+						HubPermissions: []HubPermissionConfig{
+							{
+								Type:           HubPermissionsBindingCurrentCluster,
+								CurrentCluster: &CurrentClusterBindingConfig{ClusterRoleName: "open-cluster-management:addon:test:role"},
+							},
+							{
+								Type: HubPermissionsBindingSingleNamespace,
+								SingleNamespace: &SingleNamespaceBindingConfig{
+									Namespace: "test-ns",
+									RoleRef: rbacv1.RoleRef{
+										APIGroup: rbacv1.GroupName, Kind: "Role", Name: "open-cluster-management:addon:test:role"},
+								},
+							},
+						},
+					},
+				},
+				{
+					Type: RegistrationTypeCustomSigner,
+					CustomSigner: &CustomSignerRegistrationConfig{
+						SignerName: "example.com/signer",
+						SigningCA:  SigningCARef{Namespace: "test-ns", Name: "test-ca"},
+					},
+				},
+			},
+		},
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &unstructured.Unstructured{Object: obj}
+}
+
+func newTestAgent(t *testing.T, kubeClient *kubefake.Clientset) (*templateAgent, *addonapiv1alpha1.ManagedClusterAddOn) {
+	addon := addontesting.NewAddon("test", "cluster1")
+	addon.Status.ConfigReferences = []addonapiv1alpha1.ConfigReference{
+		{
+			ConfigGroupResource: AddOnTemplateGR,
+			DesiredConfig:       &addonapiv1alpha1.ConfigSpecHash{ConfigReferent: addonapiv1alpha1.ConfigReferent{Name: "test-template"}},
+		},
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Group:    utils.AddOnDeploymentConfigGVR.Group,
+				Resource: utils.AddOnDeploymentConfigGVR.Resource,
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "test-config"},
+		},
+	}
+	deploymentConfig := &addonapiv1alpha1.AddOnDeploymentConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cluster1", Name: "test-config"},
+		Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+			CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{{Name: "IMAGE", Value: "quay.io/test:v1"}},
+		},
+	}
+
+	templateIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := templateIndexer.Add(newTemplate(t)); err != nil {
+		t.Fatal(err)
+	}
+	fakeAddonClient := fakeaddon.NewSimpleClientset(addon, deploymentConfig)
+	addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 0)
+	if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(addon); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewTemplateAgentAddon(
+		"test",
+		cache.NewGenericLister(templateIndexer, AddOnTemplateGVR.GroupResource()),
+		addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+		kubeClient,
+		utils.NewAddOnDeploymentConfigGetter(fakeAddonClient),
+	).(*templateAgent), addon
+}
+
+func TestManifests(t *testing.T) {
+	agentAddon, addon := newTestAgent(t, kubefake.NewSimpleClientset())
+
+	objects, err := agentAddon.Manifests(addontesting.NewManagedCluster("cluster1"), addon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Fatalf("expected 1 manifest, but got %d", len(objects))
+	}
+	deployment := objects[0].(*unstructured.Unstructured)
+	if deployment.GetNamespace() != "open-cluster-management-agent-addon" {
+		t.Errorf("expected the default install namespace, but got %q", deployment.GetNamespace())
+	}
+	image, _, _ := unstructured.NestedString(deployment.Object, "spec", "image")
+	if image != "quay.io/test:v1" {
+		t.Errorf("expected the image of the deployment config, but got %q", image)
+	}
+	args, _, _ := unstructured.NestedStringSlice(deployment.Object, "spec", "args")
+	if len(args) != 1 || args[0] != "--cluster-name=cluster1" {
+		t.Errorf("expected the cluster name substituted, but got %v", args)
+	}
+
+	addon.Status.ConfigReferences = nil
+	if _, err := agentAddon.Manifests(addontesting.NewManagedCluster("cluster1"), addon); err == nil {
+		t.Errorf("expected error when no addon template is referenced")
+	}
+}
+
+func TestRegistration(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset()
+	agentAddon, addon := newTestAgent(t, kubeClient)
+	cluster := addontesting.NewManagedCluster("cluster1")
+
+	configs := agentAddon.GetAgentAddonOptions().Registration.CSRConfigurations(cluster)
+	if len(configs) != 2 {
+		t.Fatalf("expected 2 registration configs, but got %v", configs)
+	}
+	if configs[0].SignerName != certificatesv1.KubeAPIServerClientSignerName || configs[1].SignerName != "example.com/signer" {
+		t.Errorf("unexpected registration configs %v", configs)
+	}
+
+	if err := agentAddon.GetAgentAddonOptions().Registration.PermissionConfig(cluster, addon); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kubeClient.RbacV1().RoleBindings("cluster1").Get(
+		context.TODO(), "open-cluster-management:test:agent", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the current cluster binding, but got %v", err)
+	}
+	binding, err := kubeClient.RbacV1().RoleBindings("test-ns").Get(
+		context.TODO(), "open-cluster-management:test:cluster1:agent", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the single namespace binding, but got %v", err)
+	}
+	if binding.RoleRef.Kind != "Role" || binding.Subjects[0].Name != "system:open-cluster-management:cluster:cluster1:addon:test" {
+		t.Errorf("unexpected binding %v", binding)
+	}
+}
+
+func TestHubPermissionBindingRoleRef(t *testing.T) {
+	agentAddon, addon := newTestAgent(t, kubefake.NewSimpleClientset())
+	cluster := addontesting.NewManagedCluster("cluster1")
+
+	cases := []struct {
+		name        string
+		permission  HubPermissionConfig
+		expectedErr bool
+	}{
+		{
+			name: "the cluster role of the addon",
+			permission: HubPermissionConfig{
+				Type:           HubPermissionsBindingCurrentCluster,
+				CurrentCluster: &CurrentClusterBindingConfig{ClusterRoleName: "open-cluster-management:addon:test:role"},
+			},
+		},
+		{
+			name: "cluster-admin",
+			permission: HubPermissionConfig{
+				Type:           HubPermissionsBindingCurrentCluster,
+				CurrentCluster: &CurrentClusterBindingConfig{ClusterRoleName: "cluster-admin"},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "the role of another addon",
+			permission: HubPermissionConfig{
+				Type: HubPermissionsBindingSingleNamespace,
+				SingleNamespace: &SingleNamespaceBindingConfig{
+					Namespace: "test-ns",
+					RoleRef: rbacv1.RoleRef{
+						APIGroup: rbacv1.GroupName, Kind: "Role", Name: "open-cluster-management:addon:other:role"},
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "not a role",
+			permission: HubPermissionConfig{
+				Type: HubPermissionsBindingSingleNamespace,
+				SingleNamespace: &SingleNamespaceBindingConfig{
+					Namespace: "test-ns",
+					RoleRef:   rbacv1.RoleRef{Kind: "ServiceAccount", Name: "open-cluster-management:addon:test:role"},
+				},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := agentAddon.hubPermissionBinding(cluster, addon, c.permission)
+			if c.expectedErr && err == nil {
+				t.Errorf("expected error, but got nil")
+			}
+			if !c.expectedErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package templateagent
+
+import (
+	"fmt"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+// AddOnTemplateGVR is the GroupVersionResource of the AddOnTemplate, the cluster scoped config of an addon
+// rendered entirely from the template.
+var AddOnTemplateGVR = schema.GroupVersionResource{
+	Group:    "addon.open-cluster-management.io",
+	Version:  "v1alpha1",
+	Resource: "addontemplates",
+}
+
+// AddOnTemplateGR is the config group resource of the AddOnTemplate in the supportedConfigs of the
+// ClusterManagementAddOn and the configReferences of the ManagedClusterAddOn.
+var AddOnTemplateGR = addonapiv1alpha1.ConfigGroupResource{
+	Group:    AddOnTemplateGVR.Group,
+	Resource: AddOnTemplateGVR.Resource,
+}
+
+// AddOnTemplate is the template of an addon. The AddOnTemplate API is not in the vendored addon API, so the
+// template is read by the dynamic client and converted to this type.
+type AddOnTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AddOnTemplateSpec `json:"spec"`
+}
+
+// AddOnTemplateSpec defines the manifests and the registration of the addon agent.
+type AddOnTemplateSpec struct {
+	// AddonName is the name of the addon of the template.
+	AddonName string `json:"addonName"`
+
+	// AgentSpec is the ManifestWork spec of the addon agent, the manifests of the workload are deployed to the
+	// managed clusters after the variables are substituted.
+	AgentSpec workapiv1.ManifestWorkSpec `json:"agentSpec"`
+
+	// Registration is the registration configs of the addon agent.
+	// +optional
+	Registration []RegistrationSpec `json:"registration,omitempty"`
+}
+
+// RegistrationType is the type of the registration config.
+type RegistrationType string
+
+const (
+	// RegistrationTypeKubeClient registers the agent with a kube client certificate of the hub.
+	RegistrationTypeKubeClient RegistrationType = "KubeClient"
+	// RegistrationTypeCustomSigner registers the agent with a certificate signed by a custom signer.
+	RegistrationTypeCustomSigner RegistrationType = "CustomSigner"
+)
+
+// RegistrationSpec is a registration config of the addon agent.
+type RegistrationSpec struct {
+	// Type is the type of the registration, KubeClient or CustomSigner.
+	Type RegistrationType `json:"type"`
+
+	// KubeClient is the config of the KubeClient registration.
+	// +optional
+	KubeClient *KubeClientRegistrationConfig `json:"kubeClient,omitempty"`
+
+	// CustomSigner is the config of the CustomSigner registration.
+	// +optional
+	CustomSigner *CustomSignerRegistrationConfig `json:"customSigner,omitempty"`
+}
+
+// KubeClientRegistrationConfig is the config of the KubeClient registration.
+type KubeClientRegistrationConfig struct {
+	// HubPermissions are the permissions granted to the agent on the hub.
+	// +optional
+	HubPermissions []HubPermissionConfig `json:"hubPermissions,omitempty"`
+}
+
+// HubPermissionsBindingType is the type of the binding of a hub permission.
+type HubPermissionsBindingType string
+
+const (
+	// HubPermissionsBindingCurrentCluster binds a ClusterRole to the agent in the namespace of its cluster.
+	HubPermissionsBindingCurrentCluster HubPermissionsBindingType = "CurrentCluster"
+	// HubPermissionsBindingSingleNamespace binds a Role or ClusterRole to the agent in a given namespace.
+	HubPermissionsBindingSingleNamespace HubPermissionsBindingType = "SingleNamespace"
+)
+
+// HubPermissionRolePrefix returns the prefix of the names of the roles the hub permissions of the template of the
+// addon can bind, e.g. "open-cluster-management:addon:test:" of addon test, so a template can only grant the
+// roles created for the addon by the hub admin, but not the other roles like cluster-admin.
+func HubPermissionRolePrefix(addonName string) string {
+	return fmt.Sprintf("open-cluster-management:addon:%s:", addonName)
+}
+
+// HubPermissionConfig is a permission granted to the agent on the hub. The role must be named with the
+// HubPermissionRolePrefix of the addon.
+type HubPermissionConfig struct {
+	// Type is the type of the binding, CurrentCluster or SingleNamespace.
+	Type HubPermissionsBindingType `json:"type"`
+
+	// CurrentCluster is the config of the CurrentCluster binding.
+	// +optional
+	CurrentCluster *CurrentClusterBindingConfig `json:"currentCluster,omitempty"`
+
+	// SingleNamespace is the config of the SingleNamespace binding.
+	// +optional
+	SingleNamespace *SingleNamespaceBindingConfig `json:"singleNamespace,omitempty"`
+}
+
+// CurrentClusterBindingConfig binds the ClusterRole in the namespace of the cluster of the agent.
+type CurrentClusterBindingConfig struct {
+	ClusterRoleName string `json:"clusterRoleName"`
+}
+
+// SingleNamespaceBindingConfig binds the role in the namespace.
+type SingleNamespaceBindingConfig struct {
+	Namespace string         `json:"namespace"`
+	RoleRef   rbacv1.RoleRef `json:"roleRef"`
+}
+
+// CustomSignerRegistrationConfig is the config of the CustomSigner registration.
+type CustomSignerRegistrationConfig struct {
+	// SignerName is the name of the custom signer.
+	SignerName string `json:"signerName"`
+
+	// Subject is the subject of the certificate. If it is not set, the default user and groups of the agent
+	// are used.
+	// +optional
+	Subject *addonapiv1alpha1.Subject `json:"subject,omitempty"`
+
+	// SigningCA is the kubernetes.io/tls Secret of the CA signing the certificates.
+	SigningCA SigningCARef `json:"signingCA"`
+}
+
+// SigningCARef is the reference of the Secret of a signing CA.
+type SigningCARef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// templateFromObject converts the AddOnTemplate read by the dynamic client.
+func templateFromObject(obj runtime.Object) (*AddOnTemplate, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected addon template object %T", obj)
+	}
+	template := &AddOnTemplate{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"strings"
+	"time"
+
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+// DefaultManifestsCleanupTimeout is how long the uninstall of a deleting addon waits for its deploy manifestworks
+// to be removed from the managed cluster by default, e.g. when the work agent is offline, before the pre-delete
+// hook runs and the registration is removed.
+const DefaultManifestsCleanupTimeout = 5 * time.Minute
+
+// ManifestsCleanupWait returns how long the uninstall of the deleting addon still waits for the deploy
+// manifestworks to be removed, it is 0 if the manifestworks are removed or the timeout is exceeded. The
+// DefaultManifestsCleanupTimeout is used if the timeout is not positive.
+func ManifestsCleanupWait(addon *addonapiv1alpha1.ManagedClusterAddOn, works []*workapiv1.ManifestWork,
+	timeout time.Duration) time.Duration {
+	if addon.DeletionTimestamp.IsZero() || len(DeployWorks(addon.Name, works)) == 0 {
+		return 0
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultManifestsCleanupTimeout
+	}
+	if wait := time.Until(addon.DeletionTimestamp.Add(timeout)); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// DeployWorks returns the deploy manifestworks of the addon in the works, the pre-delete hook works and the works
+// of the addons of other clusters hosted in the cluster namespace are excluded.
+func DeployWorks(addonName string, works []*workapiv1.ManifestWork) []*workapiv1.ManifestWork {
+	var deployWorks []*workapiv1.ManifestWork
+	for _, work := range works {
+		if _, hosted := work.Labels[addonapiv1alpha1.AddonNamespaceLabelKey]; hosted {
+			continue
+		}
+		if strings.HasPrefix(work.Name, constants.DeployWorkNamePrefix(addonName)) {
+			deployWorks = append(deployWorks, work)
+		}
+	}
+	return deployWorks
+}
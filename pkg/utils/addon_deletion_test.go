@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+)
+
+func TestManifestsCleanupWait(t *testing.T) {
+	deployWork := addontesting.NewManifestWork("addon-test-deploy-0", "cluster1")
+	hookWork := addontesting.NewManifestWork("addon-test-pre-delete", "cluster1")
+
+	cases := []struct {
+		name         string
+		addon        *addonapiv1alpha1.ManagedClusterAddOn
+		works        []*workapiv1.ManifestWork
+		expectedWait bool
+	}{
+		{
+			name:  "addon is not deleting",
+			addon: addontesting.NewAddon("test", "cluster1"),
+			works: []*workapiv1.ManifestWork{deployWork},
+		},
+		{
+			name:  "deploy works are removed",
+			addon: addontesting.SetAddonDeletionTimestamp(addontesting.NewAddon("test", "cluster1"), time.Now()),
+			works: []*workapiv1.ManifestWork{hookWork},
+		},
+		{
+			name:         "waiting for the deploy works",
+			addon:        addontesting.SetAddonDeletionTimestamp(addontesting.NewAddon("test", "cluster1"), time.Now()),
+			works:        []*workapiv1.ManifestWork{deployWork, hookWork},
+			expectedWait: true,
+		},
+		{
+			name: "waiting for the deploy works in the timeout",
+			addon: addontesting.SetAddonDeletionTimestamp(addontesting.NewAddon("test", "cluster1"),
+				time.Now().Add(-9*time.Minute)),
+			works:        []*workapiv1.ManifestWork{deployWork},
+			expectedWait: true,
+		},
+		{
+			name: "timeout",
+			addon: addontesting.SetAddonDeletionTimestamp(addontesting.NewAddon("test", "cluster1"),
+				time.Now().Add(-11*time.Minute)),
+			works: []*workapiv1.ManifestWork{deployWork},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			wait := ManifestsCleanupWait(c.addon, c.works, 10*time.Minute)
+			if (wait > 0) != c.expectedWait {
+				t.Errorf("expected wait %v, but got %v", c.expectedWait, wait)
+			}
+		})
+	}
+}
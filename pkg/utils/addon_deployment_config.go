@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+var AddOnDeploymentConfigGVR = schema.GroupVersionResource{
+	Group:    "addon.open-cluster-management.io",
+	Version:  "v1alpha1",
+	Resource: "addondeploymentconfigs",
+}
+
+// AddOnDeploymentConfigGroupResource is the config group resource of the AddOnDeploymentConfig in the addon status.
+var AddOnDeploymentConfigGroupResource = addonapiv1alpha1.ConfigGroupResource{
+	Group:    AddOnDeploymentConfigGVR.Group,
+	Resource: AddOnDeploymentConfigGVR.Resource,
+}
+
+// AddOnDeploymentConfigGetter has a method to return a AddOnDeploymentConfig object
+type AddOnDeploymentConfigGetter interface {
+	Get(ctx context.Context, namespace, name string) (*addonapiv1alpha1.AddOnDeploymentConfig, error)
+}
+
+type defaultAddOnDeploymentConfigGetter struct {
+	addonClient addonv1alpha1client.Interface
+}
+
+func (g *defaultAddOnDeploymentConfigGetter) Get(
+	ctx context.Context, namespace, name string) (*addonapiv1alpha1.AddOnDeploymentConfig, error) {
+	return g.addonClient.AddonV1alpha1().AddOnDeploymentConfigs(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// NewAddOnDeploymentConfigGetter returns a AddOnDeploymentConfigGetter with addon client
+func NewAddOnDeploymentConfigGetter(addonClient addonv1alpha1client.Interface) AddOnDeploymentConfigGetter {
+	return &defaultAddOnDeploymentConfigGetter{addonClient: addonClient}
+}
+
+type listerAddOnDeploymentConfigGetter struct {
+	lister addonlisterv1alpha1.AddOnDeploymentConfigLister
+}
+
+func (g *listerAddOnDeploymentConfigGetter) Get(
+	_ context.Context, namespace, name string) (*addonapiv1alpha1.AddOnDeploymentConfig, error) {
+	return g.lister.AddOnDeploymentConfigs(namespace).Get(name)
+}
+
+// NewAddOnDeploymentConfigGetterFromLister returns a AddOnDeploymentConfigGetter reading the
+// AddOnDeploymentConfigs from the cache of an informer instead of the hub.
+func NewAddOnDeploymentConfigGetterFromLister(
+	lister addonlisterv1alpha1.AddOnDeploymentConfigLister) AddOnDeploymentConfigGetter {
+	return &listerAddOnDeploymentConfigGetter{lister: lister}
+}
+
+// AgentInstallNamespaceFromDeploymentConfigFunc returns a func to get the agent install namespace of the addon
+// from the AddOnDeploymentConfigs in the addon status, it can be set as the AgentInstallNamespace of the
+// AgentAddonOptions. The namespace is set by the AgentInstallNamespaceAnnotationKey annotation of the
+// AddOnDeploymentConfig. If there are multiple AddOnDeploymentConfigs, the big index one overrides the one
+// from small index. The func returns an empty string if no AddOnDeploymentConfig sets the namespace.
+//
+// The func is called on every render of the addon, so the getter should read from the cache of an informer, e.g.
+// NewAddOnDeploymentConfigGetterFromLister. The annotation is part of the spec hash of the AddOnDeploymentConfig,
+// so changing it re-renders the addon.
+func AgentInstallNamespaceFromDeploymentConfigFunc(
+	getter AddOnDeploymentConfigGetter) func(addon *addonapiv1alpha1.ManagedClusterAddOn) (string, error) {
+	return func(addon *addonapiv1alpha1.ManagedClusterAddOn) (string, error) {
+		var namespace string
+		for _, config := range GetDesiredConfigsOf(addon, AddOnDeploymentConfigGroupResource) {
+			addOnDeploymentConfig, err := getter.Get(context.TODO(), config.Namespace, config.Name)
+			if err != nil {
+				return "", err
+			}
+
+			if ns := addOnDeploymentConfig.Annotations[constants.AgentInstallNamespaceAnnotationKey]; len(ns) > 0 {
+				namespace = ns
+			}
+		}
+
+		return namespace, nil
+	}
+}
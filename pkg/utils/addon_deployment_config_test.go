@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func TestAgentInstallNamespaceFromDeploymentConfigFunc(t *testing.T) {
+	adcGR := addonapiv1alpha1.ConfigGroupResource{
+		Group:    AddOnDeploymentConfigGVR.Group,
+		Resource: AddOnDeploymentConfigGVR.Resource,
+	}
+	newConfig := func(name, namespace string) *addonapiv1alpha1.AddOnDeploymentConfig {
+		config := &addonapiv1alpha1.AddOnDeploymentConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "cluster1"},
+		}
+		if len(namespace) > 0 {
+			config.Annotations = map[string]string{constants.AgentInstallNamespaceAnnotationKey: namespace}
+		}
+		return config
+	}
+
+	cases := []struct {
+		name              string
+		configReferences  []addonapiv1alpha1.ConfigReference
+		configs           []runtime.Object
+		expectedNamespace string
+		expectErr         bool
+	}{
+		{
+			name:              "no addon deployment config",
+			expectedNamespace: "",
+		},
+		{
+			name: "namespace is not set",
+			configReferences: []addonapiv1alpha1.ConfigReference{
+				{ConfigGroupResource: adcGR, ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config1"}},
+			},
+			configs:           []runtime.Object{newConfig("config1", "")},
+			expectedNamespace: "",
+		},
+		{
+			name: "namespace is set",
+			configReferences: []addonapiv1alpha1.ConfigReference{
+				{ConfigGroupResource: adcGR, ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config1"}},
+			},
+			configs:           []runtime.Object{newConfig("config1", "agent-ns")},
+			expectedNamespace: "agent-ns",
+		},
+		{
+			name: "desired config is used",
+			configReferences: []addonapiv1alpha1.ConfigReference{
+				{
+					ConfigGroupResource: adcGR,
+					ConfigReferent:      addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config1"},
+					DesiredConfig: &addonapiv1alpha1.ConfigSpecHash{
+						ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config2"},
+					},
+				},
+			},
+			configs:           []runtime.Object{newConfig("config1", "agent-ns1"), newConfig("config2", "agent-ns2")},
+			expectedNamespace: "agent-ns2",
+		},
+		{
+			name: "the big index config overrides",
+			configReferences: []addonapiv1alpha1.ConfigReference{
+				{ConfigGroupResource: adcGR, ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config1"}},
+				{ConfigGroupResource: adcGR, ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config2"}},
+			},
+			configs:           []runtime.Object{newConfig("config1", "agent-ns1"), newConfig("config2", "agent-ns2")},
+			expectedNamespace: "agent-ns2",
+		},
+		{
+			name: "config is not found",
+			configReferences: []addonapiv1alpha1.ConfigReference{
+				{ConfigGroupResource: adcGR, ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config1"}},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addon := addontesting.NewAddon("test", "cluster1")
+			addon.Status.ConfigReferences = c.configReferences
+
+			addonClient := fakeaddon.NewSimpleClientset(c.configs...)
+			addonInformers := addoninformers.NewSharedInformerFactory(addonClient, 10*time.Minute)
+			for _, config := range c.configs {
+				if err := addonInformers.Addon().V1alpha1().AddOnDeploymentConfigs().Informer().GetStore().Add(config); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			getters := map[string]AddOnDeploymentConfigGetter{
+				"client": NewAddOnDeploymentConfigGetter(addonClient),
+				"lister": NewAddOnDeploymentConfigGetterFromLister(addonInformers.Addon().V1alpha1().AddOnDeploymentConfigs().Lister()),
+			}
+			for getterName, getter := range getters {
+				namespace, err := AgentInstallNamespaceFromDeploymentConfigFunc(getter)(addon)
+				if c.expectErr != (err != nil) {
+					t.Fatalf("expected error %v of the %s getter, but got %v", c.expectErr, getterName, err)
+				}
+				if namespace != c.expectedNamespace {
+					t.Errorf("expected namespace %q of the %s getter, but got %q", c.expectedNamespace, getterName, namespace)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/basecontroller/events"
+)
+
+// AddonEventRecorder records the events of the addon lifecycle on the ManagedClusterAddOn, so the operators can
+// find why an addon is stuck by describing the ManagedClusterAddOn instead of reading the controller logs.
+type AddonEventRecorder interface {
+	// Event records an event of the eventType (Normal or Warning) on the addon.
+	Event(addon *addonapiv1alpha1.ManagedClusterAddOn, eventType, reason, message string)
+}
+
+type addonEventRecorder struct {
+	kubeClient kubernetes.Interface
+	component  string
+}
+
+// NewAddonEventRecorder returns an AddonEventRecorder creating the events in the namespace of the addon on the hub.
+func NewAddonEventRecorder(kubeClient kubernetes.Interface, component string) AddonEventRecorder {
+	return &addonEventRecorder{kubeClient: kubeClient, component: component}
+}
+
+func (r *addonEventRecorder) Event(addon *addonapiv1alpha1.ManagedClusterAddOn, eventType, reason, message string) {
+	recorder := events.NewRecorder(r.kubeClient.CoreV1().Events(addon.Namespace), r.component, &corev1.ObjectReference{
+		APIVersion:      addonapiv1alpha1.GroupVersion.String(),
+		Kind:            "ManagedClusterAddOn",
+		Namespace:       addon.Namespace,
+		Name:            addon.Name,
+		UID:             addon.UID,
+		ResourceVersion: addon.ResourceVersion,
+	})
+	if eventType == corev1.EventTypeWarning {
+		recorder.Warning(reason, message)
+		return
+	}
+	recorder.Event(reason, message)
+}
+
+// RecordAddonEvent records an event on the addon by the recorder, no event is recorded if the recorder is nil.
+func RecordAddonEvent(recorder AddonEventRecorder, addon *addonapiv1alpha1.ManagedClusterAddOn,
+	eventType, reason, messageFmt string, args ...interface{}) {
+	if recorder == nil {
+		return
+	}
+	recorder.Event(addon, eventType, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+// RecordAddonStatusEvents records the events of the key transitions of the addon status from old to new, i.e.
+// the manifests are applied or fail to be applied, the health state is changed, and the config rollout is
+// started or completed. No event is recorded if the recorder is nil.
+func RecordAddonStatusEvents(recorder AddonEventRecorder, old, new *addonapiv1alpha1.ManagedClusterAddOn) {
+	if recorder == nil {
+		return
+	}
+
+	for _, conditionType := range []string{
+		addonapiv1alpha1.ManagedClusterAddOnManifestApplied,
+		addonapiv1alpha1.ManagedClusterAddOnHostingManifestApplied,
+	} {
+		cond := meta.FindStatusCondition(new.Status.Conditions, conditionType)
+		if cond == nil || !conditionStatusChanged(old, cond) {
+			continue
+		}
+		if cond.Status == metav1.ConditionTrue {
+			RecordAddonEvent(recorder, new, corev1.EventTypeNormal, constants.EventReasonManifestWorkApplied, "%s: %s", conditionType, cond.Message)
+		} else {
+			RecordAddonEvent(recorder, new, corev1.EventTypeWarning, constants.EventReasonManifestWorkApplyFailed, "%s: %s", conditionType, cond.Message)
+		}
+	}
+
+	if cond := meta.FindStatusCondition(new.Status.Conditions, addonapiv1alpha1.ManagedClusterAddOnConditionAvailable); cond != nil &&
+		conditionStatusChanged(old, cond) {
+		eventType := corev1.EventTypeWarning
+		if cond.Status == metav1.ConditionTrue {
+			eventType = corev1.EventTypeNormal
+		}
+		RecordAddonEvent(recorder, new, eventType, constants.EventReasonHealthStateChanged,
+			"the addon availability is changed to %s (%s): %s", cond.Status, cond.Reason, cond.Message)
+	}
+
+	for _, config := range new.Status.ConfigReferences {
+		if config.DesiredConfig == nil || len(config.DesiredConfig.SpecHash) == 0 {
+			continue
+		}
+		oldConfig := findConfigReference(old.Status.ConfigReferences, config)
+		name := fmt.Sprintf("%s/%s", config.DesiredConfig.Namespace, config.DesiredConfig.Name)
+		if len(config.DesiredConfig.Namespace) == 0 {
+			name = config.DesiredConfig.Name
+		}
+
+		if !configApplied(config) && (oldConfig == nil || oldConfig.DesiredConfig == nil ||
+			oldConfig.DesiredConfig.SpecHash != config.DesiredConfig.SpecHash) {
+			RecordAddonEvent(recorder, new, corev1.EventTypeNormal, constants.EventReasonConfigRolloutStarted,
+				"rolling out %s %s with spec hash %s", config.Resource, name, config.DesiredConfig.SpecHash)
+		}
+		if configApplied(config) && (oldConfig == nil || !configApplied(*oldConfig)) {
+			RecordAddonEvent(recorder, new, corev1.EventTypeNormal, constants.EventReasonConfigRolloutCompleted,
+				"%s %s with spec hash %s is applied", config.Resource, name, config.DesiredConfig.SpecHash)
+		}
+	}
+}
+
+func conditionStatusChanged(old *addonapiv1alpha1.ManagedClusterAddOn, cond *metav1.Condition) bool {
+	oldCond := meta.FindStatusCondition(old.Status.Conditions, cond.Type)
+	return oldCond == nil || oldCond.Status != cond.Status
+}
+
+// findConfigReference returns the config reference of the same config resource and referent in the configs.
+func findConfigReference(configs []addonapiv1alpha1.ConfigReference,
+	config addonapiv1alpha1.ConfigReference) *addonapiv1alpha1.ConfigReference {
+	for i := range configs {
+		if configs[i].ConfigGroupResource != config.ConfigGroupResource || configs[i].DesiredConfig == nil {
+			continue
+		}
+		if configs[i].DesiredConfig.ConfigReferent == config.DesiredConfig.ConfigReferent {
+			return &configs[i]
+		}
+	}
+	return nil
+}
+
+func configApplied(config addonapiv1alpha1.ConfigReference) bool {
+	return config.DesiredConfig != nil && config.LastAppliedConfig != nil &&
+		config.DesiredConfig.SpecHash == config.LastAppliedConfig.SpecHash
+}
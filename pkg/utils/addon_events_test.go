@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+type fakeAddonEventRecorder struct {
+	reasons []string
+}
+
+func (r *fakeAddonEventRecorder) Event(_ *addonapiv1alpha1.ManagedClusterAddOn, eventType, reason, _ string) {
+	r.reasons = append(r.reasons, eventType+"/"+reason)
+}
+
+func TestRecordAddonStatusEvents(t *testing.T) {
+	newAddon := func(conditions []metav1.Condition, configs ...addonapiv1alpha1.ConfigReference) *addonapiv1alpha1.ManagedClusterAddOn {
+		addon := addontesting.NewAddon("test", "cluster1")
+		addon.Status.Conditions = conditions
+		addon.Status.ConfigReferences = configs
+		return addon
+	}
+	condition := func(conditionType string, status metav1.ConditionStatus) metav1.Condition {
+		return metav1.Condition{Type: conditionType, Status: status, Reason: "Test"}
+	}
+	config := func(desired, lastApplied string) addonapiv1alpha1.ConfigReference {
+		configReference := addonapiv1alpha1.ConfigReference{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Group: "addon.open-cluster-management.io", Resource: "addondeploymentconfigs"},
+			DesiredConfig: &addonapiv1alpha1.ConfigSpecHash{
+				ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config"},
+				SpecHash:       desired,
+			},
+		}
+		if len(lastApplied) > 0 {
+			configReference.LastAppliedConfig = &addonapiv1alpha1.ConfigSpecHash{
+				ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: "config"},
+				SpecHash:       lastApplied,
+			}
+		}
+		return configReference
+	}
+
+	cases := []struct {
+		name            string
+		old             *addonapiv1alpha1.ManagedClusterAddOn
+		new             *addonapiv1alpha1.ManagedClusterAddOn
+		expectedReasons []string
+	}{
+		{
+			name: "no transition",
+			old: newAddon([]metav1.Condition{condition(addonapiv1alpha1.ManagedClusterAddOnManifestApplied, metav1.ConditionTrue)},
+				config("hash1", "hash1")),
+			new: newAddon([]metav1.Condition{condition(addonapiv1alpha1.ManagedClusterAddOnManifestApplied, metav1.ConditionTrue)},
+				config("hash1", "hash1")),
+		},
+		{
+			name: "manifests applied and addon available",
+			old:  newAddon([]metav1.Condition{condition(addonapiv1alpha1.ManagedClusterAddOnConditionAvailable, metav1.ConditionUnknown)}),
+			new: newAddon([]metav1.Condition{
+				condition(addonapiv1alpha1.ManagedClusterAddOnManifestApplied, metav1.ConditionTrue),
+				condition(addonapiv1alpha1.ManagedClusterAddOnConditionAvailable, metav1.ConditionTrue),
+			}),
+			expectedReasons: []string{
+				"Normal/" + constants.EventReasonManifestWorkApplied,
+				"Normal/" + constants.EventReasonHealthStateChanged,
+			},
+		},
+		{
+			name: "manifests apply failed and addon unavailable",
+			old: newAddon([]metav1.Condition{
+				condition(addonapiv1alpha1.ManagedClusterAddOnHostingManifestApplied, metav1.ConditionTrue),
+				condition(addonapiv1alpha1.ManagedClusterAddOnConditionAvailable, metav1.ConditionTrue),
+			}),
+			new: newAddon([]metav1.Condition{
+				condition(addonapiv1alpha1.ManagedClusterAddOnHostingManifestApplied, metav1.ConditionFalse),
+				condition(addonapiv1alpha1.ManagedClusterAddOnConditionAvailable, metav1.ConditionFalse),
+			}),
+			expectedReasons: []string{
+				"Warning/" + constants.EventReasonManifestWorkApplyFailed,
+				"Warning/" + constants.EventReasonHealthStateChanged,
+			},
+		},
+		{
+			name:            "config rollout started",
+			old:             newAddon(nil, config("hash1", "hash1")),
+			new:             newAddon(nil, config("hash2", "hash1")),
+			expectedReasons: []string{"Normal/" + constants.EventReasonConfigRolloutStarted},
+		},
+		{
+			name:            "config rollout completed",
+			old:             newAddon(nil, config("hash2", "hash1")),
+			new:             newAddon(nil, config("hash2", "hash2")),
+			expectedReasons: []string{"Normal/" + constants.EventReasonConfigRolloutCompleted},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			recorder := &fakeAddonEventRecorder{}
+			RecordAddonStatusEvents(recorder, c.old, c.new)
+			if !reflect.DeepEqual(recorder.reasons, c.expectedReasons) {
+				t.Errorf("expected events %v, but got %v", c.expectedReasons, recorder.reasons)
+			}
+		})
+	}
+}
+
+func TestAddonEventRecorder(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset()
+	addon := addontesting.NewAddon("test", "cluster1")
+
+	recorder := NewAddonEventRecorder(kubeClient, "addon-manager")
+	recorder.Event(addon, corev1.EventTypeWarning, constants.EventReasonManifestWorkApplyFailed, "failed")
+
+	events, err := kubeClient.CoreV1().Events("cluster1").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events.Items) != 1 {
+		t.Fatalf("expected 1 event, but got %d", len(events.Items))
+	}
+	event := events.Items[0]
+	if event.Type != corev1.EventTypeWarning || event.Reason != constants.EventReasonManifestWorkApplyFailed ||
+		event.InvolvedObject.Kind != "ManagedClusterAddOn" || event.InvolvedObject.Name != "test" ||
+		event.Source.Component != "addon-manager" {
+		t.Errorf("unexpected event %v", event)
+	}
+}
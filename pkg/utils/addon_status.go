@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+)
+
+// addonStatusBackoff is the exponential backoff of the retries of the addon status patch on conflicts.
+var addonStatusBackoff = retry.DefaultBackoff
+
+// addonStatusFlushTimeout bounds the status patch of a sync which is cancelled, e.g. when the in-flight syncs are
+// not drained in time on shutdown.
+const addonStatusFlushTimeout = 5 * time.Second
+
+// PatchAddonStatus patches all the status changes of the addon from old to new in one JSON merge patch, the
+// patch is skipped if nothing is changed. The patch is guarded by the resource version of the addon, on a
+// conflict the changes are applied to the latest addon and the patch is retried with an exponential backoff,
+// so the status set by the others in the meantime is kept. The events of the status transitions are recorded by the
+// recorder after the patch, it can be nil if no event is recorded.
+//
+// The patch is not cancelled with the context, so the status changes of a sync cancelled on shutdown are still
+// flushed instead of leaving the addon with stale conditions, it is bounded by a 5s timeout instead.
+func PatchAddonStatus(ctx context.Context, addonClient addonv1alpha1client.Interface, recorder AddonEventRecorder,
+	new, old *addonapiv1alpha1.ManagedClusterAddOn) error {
+	if equality.Semantic.DeepEqual(new.Status, old.Status) {
+		return nil
+	}
+
+	ctx, cancel := flushContext(ctx, addonStatusFlushTimeout)
+	defer cancel()
+
+	required, existing := new, old
+	err := retry.RetryOnConflict(addonStatusBackoff, func() error {
+		if equality.Semantic.DeepEqual(required.Status, existing.Status) {
+			return nil
+		}
+
+		patchBytes, err := addonStatusPatch(required, existing)
+		if err != nil {
+			return err
+		}
+
+		klog.V(2).Infof("Patching addon %s/%s status with %s", new.Namespace, new.Name, string(patchBytes))
+		_, err = addonClient.AddonV1alpha1().ManagedClusterAddOns(new.Namespace).Patch(
+			ctx, new.Name, types.MergePatchType, patchBytes, metav1.PatchOptions{}, "status")
+		if !apierrors.IsConflict(err) {
+			return err
+		}
+
+		// apply the changes to the latest addon for the retry
+		latest, getErr := addonClient.AddonV1alpha1().ManagedClusterAddOns(new.Namespace).Get(
+			ctx, new.Name, metav1.GetOptions{})
+		if getErr != nil {
+			return getErr
+		}
+		required, getErr = applyAddonStatusChanges(latest, new, old)
+		if getErr != nil {
+			return getErr
+		}
+		existing = latest
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	RecordAddonStatusEvents(recorder, old, new)
+	return nil
+}
+
+// addonStatusPatch returns the merge patch of the status from existing to required, with the uid and resource
+// version of the existing addon as the preconditions.
+func addonStatusPatch(required, existing *addonapiv1alpha1.ManagedClusterAddOn) ([]byte, error) {
+	oldData, err := json.Marshal(&addonapiv1alpha1.ManagedClusterAddOn{
+		Status: existing.Status,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	newData, err := json.Marshal(&addonapiv1alpha1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:             existing.UID,
+			ResourceVersion: existing.ResourceVersion,
+		},
+		Status: required.Status,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	patchBytes, err := jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create patch for addon %s: %w", existing.Name, err)
+	}
+	return patchBytes, nil
+}
+
+// applyAddonStatusChanges returns a copy of the latest addon with the status changes from old to new. The
+// conditions are changed by their types, and the other status fields by the merge patch of them.
+func applyAddonStatusChanges(latest, new, old *addonapiv1alpha1.ManagedClusterAddOn) (*addonapiv1alpha1.ManagedClusterAddOn, error) {
+	required := latest.DeepCopy()
+
+	withoutConditions := func(status addonapiv1alpha1.ManagedClusterAddOnStatus) ([]byte, error) {
+		status.Conditions = nil
+		return json.Marshal(status)
+	}
+	oldData, err := withoutConditions(old.Status)
+	if err != nil {
+		return nil, err
+	}
+	newData, err := withoutConditions(new.Status)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := jsonpatch.CreateMergePatch(oldData, newData)
+	if err != nil {
+		return nil, err
+	}
+	latestData, err := json.Marshal(latest.Status)
+	if err != nil {
+		return nil, err
+	}
+	requiredData, err := jsonpatch.MergePatch(latestData, patch)
+	if err != nil {
+		return nil, err
+	}
+	required.Status = addonapiv1alpha1.ManagedClusterAddOnStatus{}
+	if err := json.Unmarshal(requiredData, &required.Status); err != nil {
+		return nil, err
+	}
+
+	for _, cond := range new.Status.Conditions {
+		if oldCond := meta.FindStatusCondition(old.Status.Conditions, cond.Type); oldCond != nil &&
+			equality.Semantic.DeepEqual(*oldCond, cond) {
+			continue
+		}
+		meta.SetStatusCondition(&required.Status.Conditions, cond)
+	}
+	for _, cond := range old.Status.Conditions {
+		if meta.FindStatusCondition(new.Status.Conditions, cond.Type) == nil {
+			meta.RemoveStatusCondition(&required.Status.Conditions, cond.Type)
+		}
+	}
+	return required, nil
+}
+
+// flushContext returns a context with the values of the parent which is not cancelled with the parent, it is
+// cancelled after the timeout instead.
+func flushContext(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(detachedContext{parent}, timeout)
+}
+
+// detachedContext is a context which is never cancelled and keeps the values of the parent.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clienttesting "k8s.io/client-go/testing"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+)
+
+func TestPatchAddonStatus(t *testing.T) {
+	defer func(backoff wait.Backoff) { addonStatusBackoff = backoff }(addonStatusBackoff)
+	addonStatusBackoff = wait.Backoff{Steps: 3, Duration: 0}
+
+	newCondition := func(condType string, status metav1.ConditionStatus) metav1.Condition {
+		return metav1.Condition{Type: condType, Status: status, Reason: "Test"}
+	}
+	newAddon := func(resourceVersion string, mode addonapiv1alpha1.HealthCheckMode,
+		conditions ...metav1.Condition) *addonapiv1alpha1.ManagedClusterAddOn {
+		addon := addontesting.NewAddon("test", "cluster1")
+		addon.ResourceVersion = resourceVersion
+		addon.Status.HealthCheck.Mode = mode
+		addon.Status.Conditions = conditions
+		return addon
+	}
+
+	cases := []struct {
+		name              string
+		old, new          *addonapiv1alpha1.ManagedClusterAddOn
+		latest            *addonapiv1alpha1.ManagedClusterAddOn
+		conflicts         int
+		expectedErr       bool
+		expectedPatches   int
+		expectedGets      int
+		expectedMode      addonapiv1alpha1.HealthCheckMode
+		expectedCondTypes []string
+	}{
+		{
+			name: "no-op",
+			old:  newAddon("1", "", newCondition("Available", metav1.ConditionTrue)),
+			new:  newAddon("1", "", newCondition("Available", metav1.ConditionTrue)),
+		},
+		{
+			name:              "patch the status changes together",
+			old:               newAddon("1", "", newCondition("Available", metav1.ConditionTrue)),
+			new:               newAddon("1", addonapiv1alpha1.HealthCheckModeCustomized, newCondition("Available", metav1.ConditionFalse)),
+			expectedPatches:   1,
+			expectedMode:      addonapiv1alpha1.HealthCheckModeCustomized,
+			expectedCondTypes: []string{"Available"},
+		},
+		{
+			name: "retry on conflict with the latest addon",
+			old:  newAddon("1", "", newCondition("Available", metav1.ConditionTrue), newCondition("Removed", metav1.ConditionTrue)),
+			new:  newAddon("1", addonapiv1alpha1.HealthCheckModeCustomized, newCondition("Available", metav1.ConditionFalse)),
+			latest: newAddon("2", "", newCondition("Available", metav1.ConditionTrue),
+				newCondition("Removed", metav1.ConditionTrue), newCondition("Other", metav1.ConditionTrue)),
+			conflicts:         1,
+			expectedPatches:   2,
+			expectedGets:      1,
+			expectedMode:      addonapiv1alpha1.HealthCheckModeCustomized,
+			expectedCondTypes: []string{"Available", "Other"},
+		},
+		{
+			name:            "skip the retry if the latest addon has the changes",
+			old:             newAddon("1", "", newCondition("Available", metav1.ConditionTrue)),
+			new:             newAddon("1", "", newCondition("Available", metav1.ConditionFalse)),
+			latest:          newAddon("2", "", newCondition("Available", metav1.ConditionFalse)),
+			conflicts:       1,
+			expectedPatches: 1,
+			expectedGets:    1,
+		},
+		{
+			name:            "conflicts exceed the retries",
+			old:             newAddon("1", "", newCondition("Available", metav1.ConditionTrue)),
+			new:             newAddon("1", "", newCondition("Available", metav1.ConditionFalse)),
+			latest:          newAddon("2", "", newCondition("Available", metav1.ConditionTrue)),
+			conflicts:       3,
+			expectedErr:     true,
+			expectedPatches: 3,
+			expectedGets:    3,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addonClient := fakeaddon.NewSimpleClientset()
+			conflicts := 0
+			var lastPatch []byte
+			addonClient.PrependReactor("patch", "managedclusteraddons",
+				func(action clienttesting.Action) (bool, runtime.Object, error) {
+					lastPatch = action.(clienttesting.PatchActionImpl).Patch
+					if conflicts < c.conflicts {
+						conflicts++
+						return true, nil, apierrors.NewConflict(
+							schema.GroupResource{Resource: "managedclusteraddons"}, "test", nil)
+					}
+					return true, c.new, nil
+				})
+			addonClient.PrependReactor("get", "managedclusteraddons",
+				func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, c.latest, nil
+				})
+
+			err := PatchAddonStatus(context.TODO(), addonClient, nil, c.new, c.old)
+			if c.expectedErr && err == nil {
+				t.Errorf("expected error, but got nil")
+			}
+			if !c.expectedErr && err != nil {
+				t.Errorf("expected no error, but got %v", err)
+			}
+
+			patches, gets := 0, 0
+			for _, action := range addonClient.Actions() {
+				switch action.GetVerb() {
+				case "patch":
+					patches++
+				case "get":
+					gets++
+				}
+			}
+			if patches != c.expectedPatches || gets != c.expectedGets {
+				t.Errorf("expected %d patches and %d gets, but got %d patches and %d gets",
+					c.expectedPatches, c.expectedGets, patches, gets)
+			}
+			if c.expectedPatches == 0 || c.expectedErr || c.expectedCondTypes == nil {
+				return
+			}
+
+			patched := &addonapiv1alpha1.ManagedClusterAddOn{}
+			if err := json.Unmarshal(lastPatch, patched); err != nil {
+				t.Fatal(err)
+			}
+			expectedVersion := c.old.ResourceVersion
+			if c.latest != nil {
+				expectedVersion = c.latest.ResourceVersion
+			}
+			if patched.ResourceVersion != expectedVersion {
+				t.Errorf("expected the patch guarded by resource version %s, but got %s",
+					expectedVersion, patched.ResourceVersion)
+			}
+			if patched.Status.HealthCheck.Mode != c.expectedMode {
+				t.Errorf("expected health check mode %q, but got %q", c.expectedMode, patched.Status.HealthCheck.Mode)
+			}
+			if len(patched.Status.Conditions) != len(c.expectedCondTypes) {
+				t.Fatalf("expected conditions %v, but got %v", c.expectedCondTypes, patched.Status.Conditions)
+			}
+			for _, condType := range c.expectedCondTypes {
+				if meta.FindStatusCondition(patched.Status.Conditions, condType) == nil {
+					t.Errorf("expected condition %s, but got %v", condType, patched.Status.Conditions)
+				}
+			}
+			if cond := meta.FindStatusCondition(patched.Status.Conditions, "Available"); cond.Status != metav1.ConditionFalse {
+				t.Errorf("expected the available condition is changed, but got %v", cond)
+			}
+		})
+	}
+}
+
+func TestFlushContext(t *testing.T) {
+	type key struct{}
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	cancel()
+
+	ctx, cancelFlush := flushContext(parent, time.Minute)
+	defer cancelFlush()
+	if ctx.Err() != nil {
+		t.Errorf("expected the flush context is not cancelled with the parent, but got %v", ctx.Err())
+	}
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("expected the flush context is bounded by the timeout, but got %v", deadline)
+	}
+	if ctx.Value(key{}) != "value" {
+		t.Errorf("expected the values of the parent are kept")
+	}
+}
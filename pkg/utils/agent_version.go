@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+	"k8s.io/apimachinery/pkg/labels"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	worklisterv1 "open-cluster-management.io/api/client/work/listers/work/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+// AgentVersionFunc returns the version currently reported by the agent of the addon, it returns an empty
+// string if the version is not reported yet.
+type AgentVersionFunc func(addon *addonapiv1alpha1.ManagedClusterAddOn) (string, error)
+
+// AgentVersionFromWorkFeedbackFunc returns an AgentVersionFunc reading the agent version from the status feedback
+// of the addon ManifestWorks. The feedbackName is the name of a string feedback value of the resource, it has to be
+// probed by a JSONPaths feedback rule, e.g. by the ProbeFields of the work health prober of the addon. The func is
+// called on every render of the addon, so the ManifestWorks are read from the workLister of an informer.
+func AgentVersionFromWorkFeedbackFunc(workLister worklisterv1.ManifestWorkLister,
+	resource workapiv1.ResourceIdentifier, feedbackName string) AgentVersionFunc {
+	return func(addon *addonapiv1alpha1.ManagedClusterAddOn) (string, error) {
+		works, err := AddonManifestWorks(workLister, addon)
+		if err != nil {
+			return "", err
+		}
+
+		for _, work := range works {
+			for _, manifest := range work.Status.ResourceStatus.Manifests {
+				if manifest.ResourceMeta.Group != resource.Group ||
+					manifest.ResourceMeta.Resource != resource.Resource ||
+					manifest.ResourceMeta.Name != resource.Name ||
+					manifest.ResourceMeta.Namespace != resource.Namespace {
+					continue
+				}
+
+				for _, value := range manifest.StatusFeedbacks.Values {
+					if value.Name == feedbackName && value.Value.String != nil {
+						return *value.Value.String, nil
+					}
+				}
+			}
+		}
+
+		return "", nil
+	}
+}
+
+// AddonManifestWorks returns the ManifestWorks of the addon in the cluster namespace from the workLister.
+func AddonManifestWorks(workLister worklisterv1.ManifestWorkLister,
+	addon *addonapiv1alpha1.ManagedClusterAddOn) ([]*workapiv1.ManifestWork, error) {
+	return workLister.ManifestWorks(addon.Namespace).List(labels.SelectorFromSet(labels.Set{
+		addonapiv1alpha1.AddonLabelKey: addon.Name,
+	}))
+}
+
+// AgentVersionAtLeast returns true if the agent version is the minVersion or newer. The versions are semantic
+// versions, a leading "v" is allowed. An empty or invalid agent version is considered as older than any version.
+func AgentVersionAtLeast(agentVersion, minVersion string) (bool, error) {
+	min, err := semver.NewVersion(minVersion)
+	if err != nil {
+		return false, fmt.Errorf("invalid min agent version %q: %v", minVersion, err)
+	}
+
+	if len(agentVersion) == 0 {
+		return false, nil
+	}
+	current, err := semver.NewVersion(agentVersion)
+	if err != nil {
+		return false, nil
+	}
+
+	return !current.LessThan(min), nil
+}
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakework "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+)
+
+func TestAgentVersionFromWorkFeedbackFunc(t *testing.T) {
+	identifier := workapiv1.ResourceIdentifier{Group: "apps", Resource: "deployments", Name: "agent", Namespace: "addon-ns"}
+	newWork := func(addonName string, resource workapiv1.ResourceIdentifier, values ...workapiv1.FeedbackValue) *workapiv1.ManifestWork {
+		work := addontesting.NewManifestWork("addon-"+addonName+"-deploy-0", "cluster1")
+		work.Labels = map[string]string{addonapiv1alpha1.AddonLabelKey: addonName}
+		work.Status.ResourceStatus.Manifests = []workapiv1.ManifestCondition{{
+			ResourceMeta: workapiv1.ManifestResourceMeta{
+				Group: resource.Group, Resource: resource.Resource, Name: resource.Name, Namespace: resource.Namespace,
+			},
+			StatusFeedbacks: workapiv1.StatusFeedbackResult{Values: values},
+		}}
+		return work
+	}
+	version := func(v string) workapiv1.FeedbackValue {
+		return workapiv1.FeedbackValue{
+			Name:  "version",
+			Value: workapiv1.FieldValue{Type: workapiv1.String, String: &v},
+		}
+	}
+
+	cases := []struct {
+		name            string
+		works           []runtime.Object
+		expectedVersion string
+	}{
+		{
+			name:            "no work",
+			expectedVersion: "",
+		},
+		{
+			name:            "version is not reported",
+			works:           []runtime.Object{newWork("test", identifier)},
+			expectedVersion: "",
+		},
+		{
+			name: "version is reported by other resource or addon",
+			works: []runtime.Object{
+				newWork("test", workapiv1.ResourceIdentifier{Group: "apps", Resource: "deployments", Name: "other", Namespace: "addon-ns"}, version("v1.0.0")),
+				newWork("other", identifier, version("v1.0.0")),
+			},
+			expectedVersion: "",
+		},
+		{
+			name:            "version is reported",
+			works:           []runtime.Object{newWork("test", identifier, version("v1.2.0"))},
+			expectedVersion: "v1.2.0",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			workInformers := workinformers.NewSharedInformerFactory(fakework.NewSimpleClientset(), 10*time.Minute)
+			for _, work := range c.works {
+				if err := workInformers.Work().V1().ManifestWorks().Informer().GetStore().Add(work); err != nil {
+					t.Fatal(err)
+				}
+			}
+			versionFunc := AgentVersionFromWorkFeedbackFunc(workInformers.Work().V1().ManifestWorks().Lister(), identifier, "version")
+			actual, err := versionFunc(addontesting.NewAddon("test", "cluster1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != c.expectedVersion {
+				t.Errorf("expected version %q, but got %q", c.expectedVersion, actual)
+			}
+		})
+	}
+}
+
+func TestAgentVersionAtLeast(t *testing.T) {
+	cases := []struct {
+		name         string
+		agentVersion string
+		minVersion   string
+		expected     bool
+		expectErr    bool
+	}{
+		{name: "invalid min version", agentVersion: "v1.0.0", minVersion: "foo", expectErr: true},
+		{name: "version is not reported", agentVersion: "", minVersion: "v1.0.0", expected: false},
+		{name: "invalid agent version", agentVersion: "latest", minVersion: "v1.0.0", expected: false},
+		{name: "older version", agentVersion: "v0.9.1", minVersion: "v1.0.0", expected: false},
+		{name: "same version", agentVersion: "1.0.0", minVersion: "v1.0.0", expected: true},
+		{name: "newer version", agentVersion: "v1.1.0", minVersion: "v1.0.0", expected: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := AgentVersionAtLeast(c.agentVersion, c.minVersion)
+			if c.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectErr, err)
+			}
+			if actual != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
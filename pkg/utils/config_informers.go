@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// SharedConfigInformers shares one informer per config GVR across all the addons and controllers of a
+// manager. Multiple addons usually declare the same config GVR (e.g. AddOnDeploymentConfig) as supported
+// config, the informer of the GVR is created once with its indexers no matter how many addons and
+// controllers acquire it, so only one watch connection and one cache is kept on the hub for the GVR.
+type SharedConfigInformers struct {
+	lock       sync.Mutex
+	factory    dynamicinformer.DynamicSharedInformerFactory
+	references map[schema.GroupVersionResource]int
+}
+
+// NewSharedConfigInformers returns a SharedConfigInformers building the informers with the factory.
+func NewSharedConfigInformers(factory dynamicinformer.DynamicSharedInformerFactory) *SharedConfigInformers {
+	return &SharedConfigInformers{
+		factory:    factory,
+		references: map[schema.GroupVersionResource]int{},
+	}
+}
+
+// Acquire returns the shared informer of the gvr and increases its reference count. The indexers are
+// added to the informer only if an indexer with the same name has not been added by another acquirer,
+// so the acquirers requiring the same index share it. Like the other informers, the indexers can only
+// be added before the informer is started.
+func (s *SharedConfigInformers) Acquire(gvr schema.GroupVersionResource, indexers cache.Indexers) (cache.SharedIndexInformer, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	informer := s.factory.ForResource(gvr).Informer()
+
+	existing := informer.GetIndexer().GetIndexers()
+	toAdd := cache.Indexers{}
+	for name, indexFunc := range indexers {
+		if _, ok := existing[name]; ok {
+			continue
+		}
+		toAdd[name] = indexFunc
+	}
+	if len(toAdd) > 0 {
+		if err := informer.AddIndexers(toAdd); err != nil {
+			return nil, err
+		}
+	}
+
+	s.references[gvr]++
+	return informer, nil
+}
+
+// Release decreases the reference count of the gvr.
+func (s *SharedConfigInformers) Release(gvr schema.GroupVersionResource) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.references[gvr] <= 1 {
+		delete(s.references, gvr)
+		return
+	}
+	s.references[gvr]--
+}
+
+// References returns the reference count of the gvr.
+func (s *SharedConfigInformers) References(gvr schema.GroupVersionResource) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.references[gvr]
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSharedConfigInformers(t *testing.T) {
+	fooGVR := schema.GroupVersionResource{Group: "test", Version: "v1", Resource: "foos"}
+	barGVR := schema.GroupVersionResource{Group: "test", Version: "v1", Resource: "bars"}
+	byName := func(obj interface{}) ([]string, error) { return []string{}, nil }
+
+	fakeDynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	informers := NewSharedConfigInformers(dynamicinformer.NewDynamicSharedInformerFactory(fakeDynamicClient, 0))
+
+	informer1, err := informers.Acquire(fooGVR, cache.Indexers{"by-name": byName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same index is shared instead of being added again
+	informer2, err := informers.Acquire(fooGVR, cache.Indexers{"by-name": byName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if informer1 != informer2 {
+		t.Errorf("expected the informer of %v is shared", fooGVR)
+	}
+	if _, ok := informer1.GetIndexer().GetIndexers()["by-name"]; !ok {
+		t.Errorf("expected the by-name index is added")
+	}
+
+	informer3, err := informers.Acquire(barGVR, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if informer1 == informer3 {
+		t.Errorf("expected different informers for different gvrs")
+	}
+
+	if refs := informers.References(fooGVR); refs != 2 {
+		t.Errorf("expected 2 references of %v, but got %d", fooGVR, refs)
+	}
+	informers.Release(fooGVR)
+	if refs := informers.References(fooGVR); refs != 1 {
+		t.Errorf("expected 1 reference of %v, but got %d", fooGVR, refs)
+	}
+	informers.Release(fooGVR)
+	informers.Release(fooGVR)
+	if refs := informers.References(fooGVR); refs != 0 {
+		t.Errorf("expected no reference of %v, but got %d", fooGVR, refs)
+	}
+	if refs := informers.References(barGVR); refs != 1 {
+		t.Errorf("expected 1 reference of %v, but got %d", barGVR, refs)
+	}
+}
@@ -136,6 +136,114 @@ func TestDefaultCSRApprover(t *testing.T) {
 	}
 }
 
+func TestDefaultCSRApprovePolicy(t *testing.T) {
+	acceptedCluster := newCluster("cluster1")
+	acceptedCluster.Spec.HubAcceptsClient = true
+
+	customRegistrations := []addonapiv1alpha1.RegistrationConfig{
+		{
+			SignerName: certificatesv1.KubeAPIServerClientSignerName,
+			Subject: addonapiv1alpha1.Subject{
+				User:   "user1",
+				Groups: []string{"group1", "group2"},
+			},
+		},
+	}
+	customCSRConfigurations := func(cluster *clusterv1.ManagedCluster) []addonapiv1alpha1.RegistrationConfig {
+		return customRegistrations
+	}
+	// the managed cluster can update the status of the addon, so the subject in the status is not trusted.
+	statusCustomAddon := newAddon("addon1", "cluster1")
+	statusCustomAddon.Status.Registrations = customRegistrations
+
+	klusterletCSR := newCSR(agent.DefaultUser("cluster1", "addon1", "test"), "cluster1", agent.DefaultGroups("cluster1", "addon1")...)
+	klusterletCSR.Spec.Username = "system:open-cluster-management:cluster1:agent1"
+	klusterletCSR.Spec.SignerName = certificatesv1.KubeAPIServerClientSignerName
+
+	similarClusterCSR := newCSR(agent.DefaultUser("cluster1", "addon1", "test"), "cluster1", agent.DefaultGroups("cluster1", "addon1")...)
+	similarClusterCSR.Spec.Username = "system:open-cluster-management:cluster10:agent1"
+
+	customCSR := newCSR("user1", "cluster1", "group2", "group1")
+	customCSR.Spec.SignerName = certificatesv1.KubeAPIServerClientSignerName
+
+	cases := []struct {
+		name              string
+		csr               *certificatesv1.CertificateSigningRequest
+		cluster           *clusterv1.ManagedCluster
+		addon             *addonapiv1alpha1.ManagedClusterAddOn
+		csrConfigurations func(cluster *clusterv1.ManagedCluster) []addonapiv1alpha1.RegistrationConfig
+		approvers         []agent.CSRApproveFunc
+		approved          bool
+	}{
+		{
+			name:     "approve csr",
+			csr:      klusterletCSR,
+			cluster:  acceptedCluster,
+			addon:    newAddon("addon1", "cluster1"),
+			approved: true,
+		},
+		{
+			name:     "cluster is not accepted",
+			csr:      klusterletCSR,
+			cluster:  newCluster("cluster1"),
+			addon:    newAddon("addon1", "cluster1"),
+			approved: false,
+		},
+		{
+			name:     "requester is agent of another cluster",
+			csr:      similarClusterCSR,
+			cluster:  acceptedCluster,
+			addon:    newAddon("addon1", "cluster1"),
+			approved: false,
+		},
+		{
+			name:              "subject matches registration config",
+			csr:               customCSR,
+			cluster:           acceptedCluster,
+			addon:             newAddon("addon1", "cluster1"),
+			csrConfigurations: customCSRConfigurations,
+			approved:          true,
+		},
+		{
+			name:              "subject does not match registration config",
+			csr:               klusterletCSR,
+			cluster:           acceptedCluster,
+			addon:             newAddon("addon1", "cluster1"),
+			csrConfigurations: customCSRConfigurations,
+			approved:          false,
+		},
+		{
+			name:     "subject in the addon status is ignored",
+			csr:      customCSR,
+			cluster:  acceptedCluster,
+			addon:    statusCustomAddon,
+			approved: false,
+		},
+		{
+			name:    "custom approver denies",
+			csr:     klusterletCSR,
+			cluster: acceptedCluster,
+			addon:   newAddon("addon1", "cluster1"),
+			approvers: []agent.CSRApproveFunc{
+				func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn, csr *certificatesv1.CertificateSigningRequest) bool {
+					return false
+				},
+			},
+			approved: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			approver := DefaultCSRApprovePolicy("test", c.csrConfigurations, c.approvers...)
+			approved := approver(c.cluster, c.addon, c.csr)
+			if approved != c.approved {
+				t.Errorf("Expected approve is %t, but got %t", c.approved, approved)
+			}
+		})
+	}
+}
+
 func TestUnionApprover(t *testing.T) {
 	approveAll := func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn, csr *certificatesv1.CertificateSigningRequest) bool {
 		return true
@@ -177,6 +285,51 @@ func TestUnionApprover(t *testing.T) {
 	}
 }
 
+func TestAnyApprover(t *testing.T) {
+	approveAll := func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn, csr *certificatesv1.CertificateSigningRequest) bool {
+		return true
+	}
+
+	approveNone := func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn, csr *certificatesv1.CertificateSigningRequest) bool {
+		return false
+	}
+
+	cases := []struct {
+		name        string
+		approveFunc []agent.CSRApproveFunc
+		approved    bool
+	}{
+		{
+			name:        "approve any",
+			approveFunc: []agent.CSRApproveFunc{approveNone, approveAll},
+			approved:    true,
+		},
+		{
+			name:        "approve none",
+			approveFunc: []agent.CSRApproveFunc{approveNone, approveNone},
+			approved:    false,
+		},
+		{
+			name:     "no approvers",
+			approved: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			approver := AnyCSRApprover(c.approveFunc...)
+			approved := approver(
+				newCluster("cluster1"),
+				newAddon("addon1", "cluster1"),
+				newCSR(agent.DefaultUser("cluster1", "addon1", "test"), "cluster1", "group1"),
+			)
+			if approved != c.approved {
+				t.Errorf("Expected approve is %t, but got %t", c.approved, approved)
+			}
+		})
+	}
+}
+
 func TestIsCSRSupported(t *testing.T) {
 	cases := []struct {
 		apiResources    []*metav1.APIResourceList
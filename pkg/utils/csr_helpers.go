@@ -123,55 +123,105 @@ func parseCSR(pemBytes []byte) (*x509.CertificateRequest, error) {
 
 // DefaultCSRApprover approve the csr when addon agent uses default group and default user to sign csr.
 func DefaultCSRApprover(agentName string) agent.CSRApproveFunc {
+	return UnionCSRApprover(
+		KlusterletRequesterCSRApprover(),
+		RegistrationSubjectCSRApprover(agentName, nil),
+	)
+}
+
+// DefaultCSRApprovePolicy is the approval policy fitting most of the addons. It approves the csr when the
+// cluster is accepted by the hub, the csr is requested by the klusterlet agent of the cluster, and the subject
+// of the csr matches the registration configs returned by csrConfigurations, which is usually the
+// CSRConfigurations of the registration option of the addon. The custom approvers are checked after the
+// built-in ones, and all of them must approve the csr.
+func DefaultCSRApprovePolicy(agentName string,
+	csrConfigurations func(cluster *clusterv1.ManagedCluster) []addonapiv1alpha1.RegistrationConfig,
+	approvers ...agent.CSRApproveFunc) agent.CSRApproveFunc {
+	return UnionCSRApprover(append([]agent.CSRApproveFunc{
+		ClusterAcceptedCSRApprover(),
+		KlusterletRequesterCSRApprover(),
+		RegistrationSubjectCSRApprover(agentName, csrConfigurations),
+	}, approvers...)...)
+}
+
+// ClusterAcceptedCSRApprover approves the csr when the managed cluster is accepted by the hub.
+func ClusterAcceptedCSRApprover() agent.CSRApproveFunc {
 	return func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn, csr *certificatesv1.CertificateSigningRequest) bool {
-		defaultGroups := agent.DefaultGroups(cluster.Name, addon.Name)
+		if !cluster.Spec.HubAcceptsClient {
+			klog.Infof("CSR Approve Check Failed csr %q cluster %s is not accepted", csr.Name, cluster.Name)
+			return false
+		}
+		return true
+	}
+}
 
-		defaultUser := agent.DefaultUser(cluster.Name, addon.Name, agentName)
+// KlusterletRequesterCSRApprover approves the csr when it is requested by the klusterlet agent of the managed
+// cluster, whose user is "system:open-cluster-management:{clusterName}" or
+// "system:open-cluster-management:{clusterName}:{agentID}".
+func KlusterletRequesterCSRApprover() agent.CSRApproveFunc {
+	return func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn, csr *certificatesv1.CertificateSigningRequest) bool {
+		requester := "system:open-cluster-management:" + cluster.Name
+		if csr.Spec.Username != requester && !strings.HasPrefix(csr.Spec.Username, requester+":") {
+			klog.Infof("CSR Approve Check Failed csr %q illegal requester %s", csr.Name, csr.Spec.Username)
+			return false
+		}
+		return true
+	}
+}
+
+// RegistrationSubjectCSRApprover approves the csr when its subject matches the subject of the registration
+// config with the same signer returned by csrConfigurations. The default user and groups are expected if
+// csrConfigurations is nil, or the subject is not set in the registration config. The registration configs are
+// computed on the hub rather than read from the status of the addon, which the managed cluster can update.
+func RegistrationSubjectCSRApprover(agentName string,
+	csrConfigurations func(cluster *clusterv1.ManagedCluster) []addonapiv1alpha1.RegistrationConfig) agent.CSRApproveFunc {
+	return func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn, csr *certificatesv1.CertificateSigningRequest) bool {
+		user := agent.DefaultUser(cluster.Name, addon.Name, agentName)
+		groups := agent.DefaultGroups(cluster.Name, addon.Name)
+		var registrations []addonapiv1alpha1.RegistrationConfig
+		if csrConfigurations != nil {
+			registrations = csrConfigurations(cluster)
+		}
+		for _, registration := range registrations {
+			if registration.SignerName != csr.Spec.SignerName {
+				continue
+			}
+			if len(registration.Subject.User) > 0 {
+				user = registration.Subject.User
+				groups = registration.Subject.Groups
+			}
+			break
+		}
 
 		// check org field and commonName field
 		block, _ := pem.Decode(csr.Spec.Request)
 		if block == nil || block.Type != "CERTIFICATE REQUEST" {
-			klog.Infof("CSR Approve Check Falied csr %q was not recognized: PEM block type is not CERTIFICATE REQUEST", csr.Name)
+			klog.Infof("CSR Approve Check Failed csr %q was not recognized: PEM block type is not CERTIFICATE REQUEST", csr.Name)
 			return false
 		}
 
 		x509cr, err := x509.ParseCertificateRequest(block.Bytes)
 		if err != nil {
-			klog.Infof("CSR Approve Check Falied csr %q was not recognized: %v", csr.Name, err)
-			return false
-		}
-
-		requestingOrgs := sets.NewString(x509cr.Subject.Organization...)
-		if requestingOrgs.Len() != 3 {
-			klog.Infof("CSR Approve Check Falied csr %q org is not equal to 3", csr.Name)
+			klog.Infof("CSR Approve Check Failed csr %q was not recognized: %v", csr.Name, err)
 			return false
 		}
 
-		for _, group := range defaultGroups {
-			if !requestingOrgs.Has(group) {
-				klog.Infof("CSR Approve Check Falied csr requesting orgs doesn't contain %s", group)
-				return false
-			}
-		}
-
-		// check commonName field
-		if defaultUser != x509cr.Subject.CommonName {
-			klog.Infof("CSR Approve Check Falied commonName not right; request %s get %s", x509cr.Subject.CommonName, defaultUser)
+		if !sets.NewString(x509cr.Subject.Organization...).Equal(sets.NewString(groups...)) {
+			klog.Infof("CSR Approve Check Failed csr %q requesting orgs %v are not equal to %v",
+				csr.Name, x509cr.Subject.Organization, groups)
 			return false
 		}
 
-		// check user name
-		if strings.HasPrefix(csr.Spec.Username, "system:open-cluster-management:"+cluster.Name) {
-			klog.Info("CSR approved")
-			return true
-		} else {
-			klog.Info("CSR not approved due to illegal requester", "requester", csr.Spec.Username)
+		if user != x509cr.Subject.CommonName {
+			klog.Infof("CSR Approve Check Failed commonName not right; request %s get %s", x509cr.Subject.CommonName, user)
 			return false
 		}
+		return true
 	}
 }
 
-// UnionCSRApprover is a union func for multiple approvers
+// UnionCSRApprover is a union func for multiple approvers, the csr is approved only when all the approvers
+// approve it.
 func UnionCSRApprover(approvers ...agent.CSRApproveFunc) agent.CSRApproveFunc {
 	return func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn, csr *certificatesv1.CertificateSigningRequest) bool {
 		for _, approver := range approvers {
@@ -184,6 +234,19 @@ func UnionCSRApprover(approvers ...agent.CSRApproveFunc) agent.CSRApproveFunc {
 	}
 }
 
+// AnyCSRApprover approves the csr when any of the approvers approves it.
+func AnyCSRApprover(approvers ...agent.CSRApproveFunc) agent.CSRApproveFunc {
+	return func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn, csr *certificatesv1.CertificateSigningRequest) bool {
+		for _, approver := range approvers {
+			if approver(cluster, addon, csr) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
 // IsCSRSupported checks whether the cluster supports v1 or v1beta1 csr api.
 func IsCSRSupported(nativeClient kubernetes.Interface) (bool, bool, error) {
 	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(nativeClient.Discovery()))
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+)
+
+// DesiredConfig is a config the addon agent is expected to be deployed with, the SpecHash is empty until the
+// spec hash of the config is calculated by the addon manager.
+type DesiredConfig struct {
+	addonapiv1alpha1.ConfigGroupResource
+	addonapiv1alpha1.ConfigSpecHash
+}
+
+// GetDesiredConfigs returns the desired configs of the addon in the addon status. The configs are resolved by the
+// addon configuration controller of the addon manager, the configs in the ManagedClusterAddOn spec override the
+// configs of the install strategy placements, which override the default configs of the ClusterManagementAddOn,
+// so the AgentAddon implementations should read the configs with this func instead of resolving the precedence.
+// The configs are returned in the order of the addon status. The config referent of the legacy status without
+// a desired config is returned for compatibility.
+func GetDesiredConfigs(addon *addonapiv1alpha1.ManagedClusterAddOn) []DesiredConfig {
+	var configs []DesiredConfig
+	for _, ref := range addon.Status.ConfigReferences {
+		config := DesiredConfig{
+			ConfigGroupResource: ref.ConfigGroupResource,
+			ConfigSpecHash:      addonapiv1alpha1.ConfigSpecHash{ConfigReferent: ref.ConfigReferent},
+		}
+		if ref.DesiredConfig != nil {
+			config.ConfigSpecHash = *ref.DesiredConfig
+		}
+		if len(config.Name) == 0 {
+			continue
+		}
+		configs = append(configs, config)
+	}
+	return configs
+}
+
+// GetDesiredConfigsOf returns the desired configs of the addon with the given group and resource, see
+// GetDesiredConfigs.
+func GetDesiredConfigsOf(addon *addonapiv1alpha1.ManagedClusterAddOn,
+	groupResource addonapiv1alpha1.ConfigGroupResource) []DesiredConfig {
+	var configs []DesiredConfig
+	for _, config := range GetDesiredConfigs(addon) {
+		if config.ConfigGroupResource == groupResource {
+			configs = append(configs, config)
+		}
+	}
+	return configs
+}
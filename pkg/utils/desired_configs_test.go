@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+)
+
+func TestGetDesiredConfigs(t *testing.T) {
+	deploymentConfig := addonapiv1alpha1.ConfigGroupResource{
+		Group:    "addon.open-cluster-management.io",
+		Resource: "addondeploymentconfigs",
+	}
+	otherConfig := addonapiv1alpha1.ConfigGroupResource{Group: "test", Resource: "tests"}
+
+	cases := []struct {
+		name            string
+		configRefs      []addonapiv1alpha1.ConfigReference
+		groupResource   *addonapiv1alpha1.ConfigGroupResource
+		expectedConfigs []DesiredConfig
+	}{
+		{
+			name: "no configs",
+		},
+		{
+			name: "desired configs",
+			configRefs: []addonapiv1alpha1.ConfigReference{
+				{
+					ConfigGroupResource: deploymentConfig,
+					ConfigReferent:      addonapiv1alpha1.ConfigReferent{Namespace: "ns1", Name: "old"},
+					DesiredConfig: &addonapiv1alpha1.ConfigSpecHash{
+						ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "ns1", Name: "config"},
+						SpecHash:       "hash1",
+					},
+				},
+				{
+					ConfigGroupResource: otherConfig,
+					DesiredConfig: &addonapiv1alpha1.ConfigSpecHash{
+						ConfigReferent: addonapiv1alpha1.ConfigReferent{Name: "test"},
+						SpecHash:       "hash2",
+					},
+				},
+			},
+			expectedConfigs: []DesiredConfig{
+				{
+					ConfigGroupResource: deploymentConfig,
+					ConfigSpecHash: addonapiv1alpha1.ConfigSpecHash{
+						ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "ns1", Name: "config"},
+						SpecHash:       "hash1",
+					},
+				},
+				{
+					ConfigGroupResource: otherConfig,
+					ConfigSpecHash: addonapiv1alpha1.ConfigSpecHash{
+						ConfigReferent: addonapiv1alpha1.ConfigReferent{Name: "test"},
+						SpecHash:       "hash2",
+					},
+				},
+			},
+		},
+		{
+			name: "legacy configs",
+			configRefs: []addonapiv1alpha1.ConfigReference{
+				{
+					ConfigGroupResource: deploymentConfig,
+					ConfigReferent:      addonapiv1alpha1.ConfigReferent{Namespace: "ns1", Name: "config"},
+				},
+				{
+					ConfigGroupResource: otherConfig,
+				},
+			},
+			expectedConfigs: []DesiredConfig{
+				{
+					ConfigGroupResource: deploymentConfig,
+					ConfigSpecHash: addonapiv1alpha1.ConfigSpecHash{
+						ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "ns1", Name: "config"},
+					},
+				},
+			},
+		},
+		{
+			name: "configs of group resource",
+			configRefs: []addonapiv1alpha1.ConfigReference{
+				{
+					ConfigGroupResource: otherConfig,
+					ConfigReferent:      addonapiv1alpha1.ConfigReferent{Name: "test"},
+				},
+				{
+					ConfigGroupResource: deploymentConfig,
+					ConfigReferent:      addonapiv1alpha1.ConfigReferent{Namespace: "ns1", Name: "config1"},
+				},
+				{
+					ConfigGroupResource: deploymentConfig,
+					ConfigReferent:      addonapiv1alpha1.ConfigReferent{Namespace: "ns1", Name: "config2"},
+				},
+			},
+			groupResource: &AddOnDeploymentConfigGroupResource,
+			expectedConfigs: []DesiredConfig{
+				{
+					ConfigGroupResource: deploymentConfig,
+					ConfigSpecHash: addonapiv1alpha1.ConfigSpecHash{
+						ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "ns1", Name: "config1"},
+					},
+				},
+				{
+					ConfigGroupResource: deploymentConfig,
+					ConfigSpecHash: addonapiv1alpha1.ConfigSpecHash{
+						ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: "ns1", Name: "config2"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addon := addontesting.NewAddon("test", "cluster1")
+			addon.Status.ConfigReferences = c.configRefs
+
+			var configs []DesiredConfig
+			if c.groupResource != nil {
+				configs = GetDesiredConfigsOf(addon, *c.groupResource)
+			} else {
+				configs = GetDesiredConfigs(addon)
+			}
+			if !equality.Semantic.DeepEqual(configs, c.expectedConfigs) {
+				t.Errorf("expected configs %v, but got %v", c.expectedConfigs, configs)
+			}
+		})
+	}
+}
@@ -3,22 +3,27 @@ package utils
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
 
-	jsonpatch "github.com/evanphx/json-patch"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/types"
 	coreclientv1 "k8s.io/client-go/kubernetes/typed/core/v1"
-	"k8s.io/klog/v2"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/agent"
 )
 
 func MergeRelatedObjects(modified *bool, objs *[]addonapiv1alpha1.ObjectReference, obj addonapiv1alpha1.ObjectReference) {
@@ -245,42 +250,12 @@ func ownerRefMatched(existing, required metav1.OwnerReference) bool {
 	return true
 }
 
+// PatchAddonCondition patches the conditions of the addon from old to new, the other status changes are not
+// patched. See PatchAddonStatus for the no-op skip and the retries on conflicts.
 func PatchAddonCondition(ctx context.Context, addonClient addonv1alpha1client.Interface, new, old *addonapiv1alpha1.ManagedClusterAddOn) error {
-	if equality.Semantic.DeepEqual(new.Status.Conditions, old.Status.Conditions) {
-		return nil
-	}
-
-	oldData, err := json.Marshal(&addonapiv1alpha1.ManagedClusterAddOn{
-		Status: addonapiv1alpha1.ManagedClusterAddOnStatus{
-			Conditions: old.Status.Conditions,
-		},
-	})
-	if err != nil {
-		return err
-	}
-
-	newData, err := json.Marshal(&addonapiv1alpha1.ManagedClusterAddOn{
-		ObjectMeta: metav1.ObjectMeta{
-			UID:             new.UID,
-			ResourceVersion: new.ResourceVersion,
-		},
-		Status: addonapiv1alpha1.ManagedClusterAddOnStatus{
-			Conditions: new.Status.Conditions,
-		},
-	})
-	if err != nil {
-		return err
-	}
-
-	patchBytes, err := jsonpatch.CreateMergePatch(oldData, newData)
-	if err != nil {
-		return fmt.Errorf("failed to create patch for addon %s: %w", new.Name, err)
-	}
-
-	klog.V(2).Infof("Patching addon %s/%s condition with %s", new.Namespace, new.Name, string(patchBytes))
-	_, err = addonClient.AddonV1alpha1().ManagedClusterAddOns(new.Namespace).Patch(
-		ctx, new.Name, types.MergePatchType, patchBytes, metav1.PatchOptions{}, "status")
-	return err
+	required := old.DeepCopy()
+	required.Status.Conditions = new.Status.Conditions
+	return PatchAddonStatus(ctx, addonClient, nil, required, old)
 }
 
 // AddonManagementFilterFunc is to check if the addon should be managed by addon manager or self-managed
@@ -324,3 +299,100 @@ func IsOwnedByCMA(addon *addonapiv1alpha1.ManagedClusterAddOn) bool {
 	}
 	return false
 }
+
+// GetSpecHash returns the sha256 hash of the spec field of an addon configuration.
+func GetSpecHash(obj *unstructured.Unstructured) (string, error) {
+	spec, ok := obj.Object["spec"]
+	if !ok {
+		return "", fmt.Errorf("object has no spec field")
+	}
+
+	specBytes, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(specBytes)
+
+	return fmt.Sprintf("%x", hash), nil
+}
+
+// GetAddOnDeploymentConfigSpecHash returns the spec hash of an AddOnDeploymentConfig. The agent install namespace
+// set by the AgentInstallNamespaceAnnotationKey annotation changes the rendered manifests as the spec does, so it
+// is hashed with the spec if it is set.
+func GetAddOnDeploymentConfigSpecHash(config *unstructured.Unstructured) (string, error) {
+	namespace := config.GetAnnotations()[constants.AgentInstallNamespaceAnnotationKey]
+	if len(namespace) == 0 {
+		return GetSpecHash(config)
+	}
+
+	spec, ok := config.Object["spec"]
+	if !ok {
+		return "", fmt.Errorf("object has no spec field")
+	}
+	specBytes, err := json.Marshal(map[string]interface{}{
+		"spec":                  spec,
+		"agentInstallNamespace": namespace,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(specBytes)
+
+	return fmt.Sprintf("%x", hash), nil
+}
+
+// ConfigSpecHash computes the spec hash of an addon configuration with the ConfigSpecHashFunc
+// registered for its GroupResource, GetAddOnDeploymentConfigSpecHash is used for the AddOnDeploymentConfigs and
+// GetSpecHash for the others if no func is registered.
+func ConfigSpecHash(specHashFuncs map[schema.GroupResource]agent.ConfigSpecHashFunc,
+	gr schema.GroupResource, config *unstructured.Unstructured) (string, error) {
+	if specHashFunc, ok := specHashFuncs[gr]; ok && specHashFunc != nil {
+		return specHashFunc(config)
+	}
+	if gr == AddOnDeploymentConfigGVR.GroupResource() {
+		return GetAddOnDeploymentConfigSpecHash(config)
+	}
+	return GetSpecHash(config)
+}
+
+// IsAddonPaused returns true if the addon or its ClusterManagementAddOn is paused by the AddonPausedAnnotationKey
+// annotation, either of them can be nil.
+func IsAddonPaused(cma *addonapiv1alpha1.ClusterManagementAddOn, addon *addonapiv1alpha1.ManagedClusterAddOn) bool {
+	if cma != nil && cma.Annotations[constants.AddonPausedAnnotationKey] == "true" {
+		return true
+	}
+	return addon != nil && addon.Annotations[constants.AddonPausedAnnotationKey] == "true"
+}
+
+// IsAddonDisabled returns true if the addon is disabled by the AddonDisabledAnnotationKey annotation.
+func IsAddonDisabled(addon *addonapiv1alpha1.ManagedClusterAddOn) bool {
+	return addon.Annotations[constants.AddonDisabledAnnotationKey] == "true"
+}
+
+// AddonHealthDegraded is a factory.PriorityFunc that returns true if the update degrades the health of an addon,
+// i.e. the addon or its ManifestWork turns unavailable, or the ManifestWork turns degraded.
+func AddonHealthDegraded(old, new runtime.Object) bool {
+	switch newObj := new.(type) {
+	case *addonapiv1alpha1.ManagedClusterAddOn:
+		oldObj, ok := old.(*addonapiv1alpha1.ManagedClusterAddOn)
+		if !ok {
+			return false
+		}
+		return meta.IsStatusConditionTrue(oldObj.Status.Conditions, addonapiv1alpha1.ManagedClusterAddOnConditionAvailable) &&
+			!meta.IsStatusConditionTrue(newObj.Status.Conditions, addonapiv1alpha1.ManagedClusterAddOnConditionAvailable)
+	case *workapiv1.ManifestWork:
+		oldObj, ok := old.(*workapiv1.ManifestWork)
+		if !ok {
+			return false
+		}
+		if meta.IsStatusConditionTrue(oldObj.Status.Conditions, workapiv1.WorkAvailable) &&
+			!meta.IsStatusConditionTrue(newObj.Status.Conditions, workapiv1.WorkAvailable) {
+			return true
+		}
+		return !meta.IsStatusConditionTrue(oldObj.Status.Conditions, workapiv1.WorkDegraded) &&
+			meta.IsStatusConditionTrue(newObj.Status.Conditions, workapiv1.WorkDegraded)
+	}
+	return false
+}
@@ -4,7 +4,15 @@ import (
 	"testing"
 
 	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/agent"
 )
 
 func TestMergeRelatedObject(t *testing.T) {
@@ -70,3 +78,129 @@ func relatedObject(name, namespace, resource string) addonapiv1alpha1.ObjectRefe
 		Resource:  resource,
 	}
 }
+
+func TestConfigSpecHash(t *testing.T) {
+	config := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "test.io/v1",
+		"kind":       "Foo",
+		"spec":       map[string]interface{}{"key": "value"},
+	}}
+	fooGR := schema.GroupResource{Group: "test.io", Resource: "foos"}
+	barGR := schema.GroupResource{Group: "test.io", Resource: "bars"}
+
+	specHashFuncs := map[schema.GroupResource]agent.ConfigSpecHashFunc{
+		fooGR: func(config *unstructured.Unstructured) (string, error) {
+			return "custom-hash", nil
+		},
+	}
+
+	hash, err := ConfigSpecHash(specHashFuncs, fooGR, config)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if hash != "custom-hash" {
+		t.Errorf("expected the custom hash, but got %s", hash)
+	}
+
+	hash, err = ConfigSpecHash(specHashFuncs, barGR, config)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected, _ := GetSpecHash(config)
+	if hash != expected {
+		t.Errorf("expected the default hash %s, but got %s", expected, hash)
+	}
+
+	// the agent install namespace of the AddOnDeploymentConfig is hashed with the spec
+	hash, err = ConfigSpecHash(specHashFuncs, AddOnDeploymentConfigGVR.GroupResource(), config)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if hash != expected {
+		t.Errorf("expected the default hash %s of the config without the namespace, but got %s", expected, hash)
+	}
+	config.SetAnnotations(map[string]string{constants.AgentInstallNamespaceAnnotationKey: "agent-ns"})
+	hash, err = ConfigSpecHash(specHashFuncs, AddOnDeploymentConfigGVR.GroupResource(), config)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if hash == expected {
+		t.Errorf("expected the hash changed by the agent install namespace")
+	}
+
+	_, err = GetSpecHash(&unstructured.Unstructured{Object: map[string]interface{}{}})
+	if err == nil {
+		t.Errorf("expected error for the config without spec")
+	}
+}
+
+func TestAddonHealthDegraded(t *testing.T) {
+	newAddon := func(status metav1.ConditionStatus) *addonapiv1alpha1.ManagedClusterAddOn {
+		addon := &addonapiv1alpha1.ManagedClusterAddOn{}
+		if len(status) > 0 {
+			addon.Status.Conditions = []metav1.Condition{
+				{Type: addonapiv1alpha1.ManagedClusterAddOnConditionAvailable, Status: status},
+			}
+		}
+		return addon
+	}
+	newWork := func(conditions ...metav1.Condition) *workapiv1.ManifestWork {
+		work := &workapiv1.ManifestWork{}
+		work.Status.Conditions = conditions
+		return work
+	}
+	available := metav1.Condition{Type: workapiv1.WorkAvailable, Status: metav1.ConditionTrue}
+	unavailable := metav1.Condition{Type: workapiv1.WorkAvailable, Status: metav1.ConditionFalse}
+	degraded := metav1.Condition{Type: workapiv1.WorkDegraded, Status: metav1.ConditionTrue}
+
+	cases := []struct {
+		name     string
+		old, new runtime.Object
+		expected bool
+	}{
+		{
+			name: "addon turns unavailable",
+			old:  newAddon(metav1.ConditionTrue), new: newAddon(metav1.ConditionFalse),
+			expected: true,
+		},
+		{
+			name: "addon turns unknown",
+			old:  newAddon(metav1.ConditionTrue), new: newAddon(metav1.ConditionUnknown),
+			expected: true,
+		},
+		{
+			name: "addon turns available",
+			old:  newAddon(metav1.ConditionFalse), new: newAddon(metav1.ConditionTrue),
+		},
+		{
+			name: "addon without health status",
+			old:  newAddon(""), new: newAddon(""),
+		},
+		{
+			name: "work turns unavailable",
+			old:  newWork(available), new: newWork(unavailable),
+			expected: true,
+		},
+		{
+			name: "work turns degraded",
+			old:  newWork(available), new: newWork(available, degraded),
+			expected: true,
+		},
+		{
+			name: "work stays degraded",
+			old:  newWork(available, degraded), new: newWork(available, degraded),
+		},
+		{
+			name: "unknown object",
+			old:  &unstructured.Unstructured{}, new: &unstructured.Unstructured{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if actual := AddonHealthDegraded(c.old, c.new); actual != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+const (
+	// HubKubeConfigVolumeName is the name of the volume of the hub kubeconfig secret mounted by
+	// MountHubKubeConfigSecret.
+	HubKubeConfigVolumeName = "hub-kubeconfig"
+
+	// DefaultHubKubeConfigMountPath is the default path the hub kubeconfig secret is mounted at, the hub
+	// kubeconfig file is "/var/run/hub/kubeconfig".
+	DefaultHubKubeConfigMountPath = "/var/run/hub"
+)
+
+// MountHubKubeConfigSecret mounts the hub kubeconfig secret of the addon into the containers of the Deployments
+// in the manifests, so that the addon agent does not hardcode the name of the secret created by the
+// registration. The secret is mounted at mountPath, or DefaultHubKubeConfigMountPath if it is empty. If
+// containerNames are set, only the containers with the names are mounted, otherwise all the containers are.
+// Both the typed and the unstructured Deployments are supported, the other manifests are returned as they are.
+func MountHubKubeConfigSecret(addonName, mountPath string, objects []runtime.Object,
+	containerNames ...string) ([]runtime.Object, error) {
+	if len(mountPath) == 0 {
+		mountPath = DefaultHubKubeConfigMountPath
+	}
+	secretName := constants.HubKubeConfigSecretName(addonName)
+
+	mounted := make([]runtime.Object, 0, len(objects))
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			deployment := o.DeepCopy()
+			mountSecret(&deployment.Spec.Template.Spec, secretName, mountPath, containerNames)
+			mounted = append(mounted, deployment)
+		case *unstructured.Unstructured:
+			if o.GroupVersionKind() != appsv1.SchemeGroupVersion.WithKind("Deployment") {
+				mounted = append(mounted, obj)
+				continue
+			}
+
+			deployment := &appsv1.Deployment{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, deployment); err != nil {
+				return nil, fmt.Errorf("failed to convert deployment %s/%s: %v", o.GetNamespace(), o.GetName(), err)
+			}
+			mountSecret(&deployment.Spec.Template.Spec, secretName, mountPath, containerNames)
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(deployment)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert deployment %s/%s: %v", o.GetNamespace(), o.GetName(), err)
+			}
+			mounted = append(mounted, &unstructured.Unstructured{Object: content})
+		default:
+			mounted = append(mounted, obj)
+		}
+	}
+	return mounted, nil
+}
+
+func mountSecret(podSpec *corev1.PodSpec, secretName, mountPath string, containerNames []string) {
+	volumeExists := false
+	for i := range podSpec.Volumes {
+		if podSpec.Volumes[i].Name == HubKubeConfigVolumeName {
+			podSpec.Volumes[i].VolumeSource = corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: secretName},
+			}
+			volumeExists = true
+		}
+	}
+	if !volumeExists {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: HubKubeConfigVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: secretName},
+			},
+		})
+	}
+
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if len(containerNames) > 0 && !contains(containerNames, container.Name) {
+			continue
+		}
+
+		mountExists := false
+		for j := range container.VolumeMounts {
+			if container.VolumeMounts[j].Name == HubKubeConfigVolumeName {
+				container.VolumeMounts[j].MountPath = mountPath
+				mountExists = true
+			}
+		}
+		if !mountExists {
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      HubKubeConfigVolumeName,
+				MountPath: mountPath,
+				ReadOnly:  true,
+			})
+		}
+	}
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
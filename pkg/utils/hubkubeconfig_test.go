@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newDeployment(containerNames ...string) *appsv1.Deployment {
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "agent",
+			Namespace: "default",
+		},
+	}
+	for _, name := range containerNames {
+		deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers, corev1.Container{Name: name})
+	}
+	return deployment
+}
+
+func TestMountHubKubeConfigSecret(t *testing.T) {
+	unstructuredDeployment := func() runtime.Object {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(newDeployment("agent"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &unstructured.Unstructured{Object: content}
+	}
+
+	cases := []struct {
+		name           string
+		objects        []runtime.Object
+		mountPath      string
+		containerNames []string
+		expectedMounts map[string]string
+	}{
+		{
+			name:           "typed deployment",
+			objects:        []runtime.Object{newDeployment("agent", "sidecar")},
+			expectedMounts: map[string]string{"agent": DefaultHubKubeConfigMountPath, "sidecar": DefaultHubKubeConfigMountPath},
+		},
+		{
+			name:           "selected containers",
+			objects:        []runtime.Object{newDeployment("agent", "sidecar")},
+			mountPath:      "/hub",
+			containerNames: []string{"agent"},
+			expectedMounts: map[string]string{"agent": "/hub", "sidecar": ""},
+		},
+		{
+			name:           "unstructured deployment",
+			objects:        []runtime.Object{unstructuredDeployment()},
+			expectedMounts: map[string]string{"agent": DefaultHubKubeConfigMountPath},
+		},
+		{
+			name:           "other objects",
+			objects:        []runtime.Object{&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test"}}},
+			expectedMounts: map[string]string{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			objects, err := MountHubKubeConfigSecret("test", c.mountPath, c.objects, c.containerNames...)
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if len(objects) != len(c.objects) {
+				t.Fatalf("expected %d objects, but got %d", len(c.objects), len(objects))
+			}
+
+			var deployment *appsv1.Deployment
+			switch o := objects[0].(type) {
+			case *appsv1.Deployment:
+				deployment = o
+			case *unstructured.Unstructured:
+				deployment = &appsv1.Deployment{}
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, deployment); err != nil {
+					t.Fatal(err)
+				}
+			default:
+				return
+			}
+
+			volumes := deployment.Spec.Template.Spec.Volumes
+			if len(volumes) != 1 || volumes[0].Secret == nil || volumes[0].Secret.SecretName != "test-hub-kubeconfig" {
+				t.Errorf("expected hub kubeconfig secret volume, but got %v", volumes)
+			}
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				mountPath := ""
+				for _, mount := range container.VolumeMounts {
+					if mount.Name == HubKubeConfigVolumeName {
+						mountPath = mount.MountPath
+					}
+				}
+				if mountPath != c.expectedMounts[container.Name] {
+					t.Errorf("expected container %s mounted at %q, but got %q", container.Name, c.expectedMounts[container.Name], mountPath)
+				}
+			}
+		})
+	}
+}
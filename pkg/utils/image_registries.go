@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+// ImageRegistries is the image registries of a managed cluster set by the ClusterImageRegistriesAnnotationKey
+// annotation of the ManagedCluster.
+type ImageRegistries struct {
+	// PullSecret is the pull secret of the mirror registries on the hub in the format of "<namespace>.<name>",
+	// it is not propagated to the managed cluster by the framework.
+	PullSecret string `json:"pullSecret,omitempty"`
+	// Registries are the mirror registries of the images.
+	Registries []ImageRegistry `json:"registries,omitempty"`
+}
+
+// ImageRegistry is a mirror of the images of a source registry.
+type ImageRegistry struct {
+	// Mirror is the registry the images are pulled from, ex: mirror.io/ocm.
+	Mirror string `json:"mirror"`
+	// Source is the registry of the images replaced by the mirror, ex: quay.io/ocm. If it is empty, the
+	// repositories of all the images are replaced by the mirror.
+	Source string `json:"source,omitempty"`
+}
+
+// GetImageRegistries returns the image registries of the managed cluster, it returns nil if the annotation is
+// not set.
+func GetImageRegistries(cluster *clusterv1.ManagedCluster) (*ImageRegistries, error) {
+	if cluster == nil {
+		return nil, nil
+	}
+	value, ok := cluster.Annotations[constants.ClusterImageRegistriesAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	registries := &ImageRegistries{}
+	if err := json.Unmarshal([]byte(value), registries); err != nil {
+		return nil, fmt.Errorf("invalid image registries of cluster %s: %v", cluster.Name, err)
+	}
+	for _, registry := range registries.Registries {
+		if len(registry.Mirror) == 0 {
+			return nil, fmt.Errorf("invalid image registries of cluster %s: the mirror of source %q is empty",
+				cluster.Name, registry.Source)
+		}
+	}
+	return registries, nil
+}
+
+// OverrideImage returns the image pulled from the mirror registry. The registry of the longest source matching
+// the image wins, a registry without source matches all the images and replaces the repository of the image,
+// ex: quay.io/ocm/agent:v1 is pulled as mirror.io/agent:v1 from the mirror.io mirror without source. The image
+// is returned as is if no registry matches.
+func OverrideImage(registries []ImageRegistry, image string) string {
+	var matched *ImageRegistry
+	for i := range registries {
+		registry := &registries[i]
+		if len(registry.Source) > 0 && !imageFromSource(image, registry.Source) {
+			continue
+		}
+		if matched == nil || len(registry.Source) > len(matched.Source) {
+			matched = registry
+		}
+	}
+
+	switch {
+	case matched == nil:
+		return image
+	case len(matched.Source) == 0:
+		return fmt.Sprintf("%s/%s", strings.TrimSuffix(matched.Mirror, "/"), image[strings.LastIndex(image, "/")+1:])
+	default:
+		return strings.TrimSuffix(matched.Mirror, "/") + strings.TrimPrefix(image, strings.TrimSuffix(matched.Source, "/"))
+	}
+}
+
+// imageFromSource returns true if the image is in the source registry or repository.
+func imageFromSource(image, source string) bool {
+	source = strings.TrimSuffix(source, "/")
+	if !strings.HasPrefix(image, source) {
+		return false
+	}
+	rest := image[len(source):]
+	return len(rest) == 0 || strings.ContainsAny(rest[:1], "/:@")
+}
+
+// OverrideImages returns the images pulled from the mirror registries of the managed cluster, in the same order
+// of the given images. The images are returned as is if the cluster has no image registries.
+func OverrideImages(cluster *clusterv1.ManagedCluster, images []string) ([]string, error) {
+	registries, err := GetImageRegistries(cluster)
+	if err != nil || registries == nil {
+		return images, err
+	}
+
+	overridden := make([]string, 0, len(images))
+	for _, image := range images {
+		overridden = append(overridden, OverrideImage(registries.Registries, image))
+	}
+	return overridden, nil
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func newImageRegistriesCluster(registries string) *clusterv1.ManagedCluster {
+	cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+	if len(registries) > 0 {
+		cluster.Annotations = map[string]string{constants.ClusterImageRegistriesAnnotationKey: registries}
+	}
+	return cluster
+}
+
+func TestOverrideImage(t *testing.T) {
+	cases := []struct {
+		name          string
+		registries    []ImageRegistry
+		image         string
+		expectedImage string
+	}{
+		{
+			name:          "no registries",
+			image:         "quay.io/ocm/agent:v1",
+			expectedImage: "quay.io/ocm/agent:v1",
+		},
+		{
+			name:          "source not matched",
+			registries:    []ImageRegistry{{Mirror: "mirror.io/ocm", Source: "quay.io/other"}},
+			image:         "quay.io/ocm/agent:v1",
+			expectedImage: "quay.io/ocm/agent:v1",
+		},
+		{
+			name:          "source is a prefix of another repository",
+			registries:    []ImageRegistry{{Mirror: "mirror.io/ocm", Source: "quay.io/oc"}},
+			image:         "quay.io/ocm/agent:v1",
+			expectedImage: "quay.io/ocm/agent:v1",
+		},
+		{
+			name:          "source matched",
+			registries:    []ImageRegistry{{Mirror: "mirror.io/ocm/", Source: "quay.io/ocm/"}},
+			image:         "quay.io/ocm/agent:v1",
+			expectedImage: "mirror.io/ocm/agent:v1",
+		},
+		{
+			name:          "image matched",
+			registries:    []ImageRegistry{{Mirror: "mirror.io/ocm/agent", Source: "quay.io/ocm/agent"}},
+			image:         "quay.io/ocm/agent@sha256:abc",
+			expectedImage: "mirror.io/ocm/agent@sha256:abc",
+		},
+		{
+			name: "longest source wins",
+			registries: []ImageRegistry{
+				{Mirror: "mirror.io"},
+				{Mirror: "mirror.io/quay", Source: "quay.io"},
+				{Mirror: "mirror.io/ocm", Source: "quay.io/ocm"},
+			},
+			image:         "quay.io/ocm/agent:v1",
+			expectedImage: "mirror.io/ocm/agent:v1",
+		},
+		{
+			name:          "mirror without source",
+			registries:    []ImageRegistry{{Mirror: "mirror.io/ocm"}},
+			image:         "quay.io/ocm/agent:v1",
+			expectedImage: "mirror.io/ocm/agent:v1",
+		},
+		{
+			name:          "mirror without source for image without repository",
+			registries:    []ImageRegistry{{Mirror: "mirror.io/library"}},
+			image:         "busybox",
+			expectedImage: "mirror.io/library/busybox",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			image := OverrideImage(c.registries, c.image)
+			if image != c.expectedImage {
+				t.Errorf("expected image %q, but got %q", c.expectedImage, image)
+			}
+		})
+	}
+}
+
+func TestOverrideImages(t *testing.T) {
+	cases := []struct {
+		name           string
+		cluster        *clusterv1.ManagedCluster
+		images         []string
+		expectedImages []string
+		expectErr      bool
+	}{
+		{
+			name:           "no annotation",
+			cluster:        newImageRegistriesCluster(""),
+			images:         []string{"quay.io/ocm/agent:v1"},
+			expectedImages: []string{"quay.io/ocm/agent:v1"},
+		},
+		{
+			name:      "invalid annotation",
+			cluster:   newImageRegistriesCluster("{"),
+			images:    []string{"quay.io/ocm/agent:v1"},
+			expectErr: true,
+		},
+		{
+			name:      "empty mirror",
+			cluster:   newImageRegistriesCluster(`{"registries":[{"source":"quay.io/ocm"}]}`),
+			images:    []string{"quay.io/ocm/agent:v1"},
+			expectErr: true,
+		},
+		{
+			name: "images overridden",
+			cluster: newImageRegistriesCluster(
+				`{"pullSecret":"open-cluster-management.pull-secret","registries":[{"mirror":"mirror.io/ocm","source":"quay.io/ocm"}]}`),
+			images:         []string{"quay.io/ocm/agent:v1", "docker.io/library/busybox", "quay.io/ocm/operator:v1"},
+			expectedImages: []string{"mirror.io/ocm/agent:v1", "docker.io/library/busybox", "mirror.io/ocm/operator:v1"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			images, err := OverrideImages(c.cluster, c.images)
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("expected error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(images, c.expectedImages) {
+				t.Errorf("expected images %v, but got %v", c.expectedImages, images)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+// ManifestWorkDigest returns the content digest of the manifests of the work in the format of "sha256:<hex>".
+// Each manifest is normalized before it is hashed, so the digest does not change when the manifests are
+// re-encoded by the API server, ex: with the fields in another order.
+func ManifestWorkDigest(work *workapiv1.ManifestWork) (string, error) {
+	manifests := make([]interface{}, 0, len(work.Spec.Workload.Manifests))
+	for i, manifest := range work.Spec.Workload.Manifests {
+		raw := manifest.Raw
+		if raw == nil && manifest.Object != nil {
+			var err error
+			if raw, err = json.Marshal(manifest.Object); err != nil {
+				return "", fmt.Errorf("failed to encode manifest %d of work %s/%s: %v", i, work.Namespace, work.Name, err)
+			}
+		}
+
+		var content interface{}
+		if err := json.Unmarshal(raw, &content); err != nil {
+			return "", fmt.Errorf("failed to decode manifest %d of work %s/%s: %v", i, work.Namespace, work.Name, err)
+		}
+		manifests = append(manifests, content)
+	}
+
+	// the keys of the maps are sorted by json.Marshal
+	data, err := json.Marshal(manifests)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data)), nil
+}
+
+// ManifestWorkDrifted returns whether the manifests of the work are modified since the work is stamped with the
+// ManifestDigestAnnotationKey annotation. It returns false if the work is not stamped.
+func ManifestWorkDrifted(work *workapiv1.ManifestWork) (bool, error) {
+	stamped, ok := work.Annotations[constants.ManifestDigestAnnotationKey]
+	if !ok {
+		return false, nil
+	}
+	digest, err := ManifestWorkDigest(work)
+	if err != nil {
+		return false, err
+	}
+	return digest != stamped, nil
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func TestManifestWorkDigest(t *testing.T) {
+	obj := addontesting.NewUnstructured("v1", "ConfigMap", "default", "test")
+	work := addontesting.NewManifestWork("test", "cluster1", obj)
+	digest, err := ManifestWorkDigest(work)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the manifests re-encoded with the fields in another order have the same digest
+	reordered := addontesting.NewManifestWork("test", "cluster1")
+	reordered.Spec.Workload.Manifests = []workapiv1.Manifest{{RawExtension: runtime.RawExtension{
+		Raw: []byte(`{"metadata":{"name":"test","namespace":"default"},"kind":"ConfigMap","apiVersion":"v1"}`),
+	}}}
+	if actual, err := ManifestWorkDigest(reordered); err != nil || actual != digest {
+		t.Errorf("expected digest %s, but got %s, %v", digest, actual, err)
+	}
+
+	// the manifests set by objects have the same digest
+	objectWork := addontesting.NewManifestWork("test", "cluster1")
+	objectWork.Spec.Workload.Manifests = []workapiv1.Manifest{{RawExtension: runtime.RawExtension{Object: obj}}}
+	if actual, err := ManifestWorkDigest(objectWork); err != nil || actual != digest {
+		t.Errorf("expected digest %s, but got %s, %v", digest, actual, err)
+	}
+
+	obj.SetLabels(map[string]string{"foo": "bar"})
+	if actual, err := ManifestWorkDigest(addontesting.NewManifestWork("test", "cluster1", obj)); err != nil || actual == digest {
+		t.Errorf("expected digest changed from %s, but got %s, %v", digest, actual, err)
+	}
+
+	invalid := addontesting.NewManifestWork("test", "cluster1")
+	invalid.Spec.Workload.Manifests = []workapiv1.Manifest{{RawExtension: runtime.RawExtension{Raw: []byte("{")}}}
+	if _, err := ManifestWorkDigest(invalid); err == nil {
+		t.Errorf("expected error, but got nil")
+	}
+}
+
+func TestManifestWorkDrifted(t *testing.T) {
+	newWork := func(name string) *workapiv1.ManifestWork {
+		return addontesting.NewManifestWork("test", "cluster1",
+			addontesting.NewUnstructured("v1", "ConfigMap", "default", name))
+	}
+	digest, err := ManifestWorkDigest(newWork("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name            string
+		work            *workapiv1.ManifestWork
+		digest          string
+		expectedDrifted bool
+	}{
+		{
+			name: "not stamped",
+			work: newWork("test"),
+		},
+		{
+			name:   "not drifted",
+			work:   newWork("test"),
+			digest: digest,
+		},
+		{
+			name:            "drifted",
+			work:            newWork("modified"),
+			digest:          digest,
+			expectedDrifted: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if len(c.digest) > 0 {
+				c.work.Annotations = map[string]string{constants.ManifestDigestAnnotationKey: c.digest}
+			}
+			drifted, err := ManifestWorkDrifted(c.work)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if drifted != c.expectedDrifted {
+				t.Errorf("expected drifted %v, but got %v", c.expectedDrifted, drifted)
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 )
 
@@ -31,6 +32,16 @@ type RBACPermissionBuilder interface {
 	BindRoleToUser(clusterRole *rbacv1.Role, username string) RBACPermissionBuilder
 	// BindRoleToGroup is a shortcut that ensures a role binding and binds to a hub user.
 	BindRoleToGroup(clusterRole *rbacv1.Role, userGroup string) RBACPermissionBuilder
+	// BindRoleToAgent is a shortcut that ensures a role in the cluster namespace and binds it to the default
+	// group of the addon agent on the cluster.
+	BindRoleToAgent(role *rbacv1.Role) RBACPermissionBuilder
+	// BindClusterRoleToAgent is a shortcut that binds an existing cluster role to the default group of the
+	// addon agent on the cluster with a role binding in the cluster namespace.
+	BindClusterRoleToAgent(clusterRoleName string) RBACPermissionBuilder
+	// WithRegistrationOption binds the roles of BindRoleToAgent and BindClusterRoleToAgent to the ServiceAccount of
+	// the addon agent as well if the agent is registered with a ServiceAccount token by the registration option.
+	// The option is read when the permissions are applied, so the builder can build its PermissionConfig.
+	WithRegistrationOption(registrationOption *agent.RegistrationOption) RBACPermissionBuilder
 
 	// WithStaticClusterRole ensures a cluster role to the hub cluster.
 	WithStaticClusterRole(clusterRole *rbacv1.ClusterRole) RBACPermissionBuilder
@@ -48,8 +59,9 @@ type RBACPermissionBuilder interface {
 var _ RBACPermissionBuilder = &permissionBuilder{}
 
 type permissionBuilder struct {
-	kubeClient kubernetes.Interface
-	u          *unionPermissionBuilder
+	kubeClient         kubernetes.Interface
+	u                  *unionPermissionBuilder
+	registrationOption *agent.RegistrationOption
 }
 
 // NewRBACPermissionConfigBuilder instantiates a default RBACPermissionBuilder.
@@ -136,6 +148,66 @@ func (p *permissionBuilder) BindRoleToGroup(role *rbacv1.Role, userGroup string)
 		})
 }
 
+func (p *permissionBuilder) BindRoleToAgent(role *rbacv1.Role) RBACPermissionBuilder {
+	p.WithStaticRole(role)
+	p.u.fns = append(p.u.fns, func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+		return p.applyAgentRoleBinding(cluster, addon, role.Name, "Role")
+	})
+	return p
+}
+
+func (p *permissionBuilder) BindClusterRoleToAgent(clusterRoleName string) RBACPermissionBuilder {
+	p.u.fns = append(p.u.fns, func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+		return p.applyAgentRoleBinding(cluster, addon, clusterRoleName, "ClusterRole")
+	})
+	return p
+}
+
+func (p *permissionBuilder) WithRegistrationOption(registrationOption *agent.RegistrationOption) RBACPermissionBuilder {
+	p.registrationOption = registrationOption
+	return p
+}
+
+// applyAgentRoleBinding ensures a role binding of the addon and the role in the cluster namespace, which binds
+// the role to the default group of the addon agent, and to the ServiceAccount of the agent registered with
+// a token. The ServiceAccount is not bound for the agents registered with CSRs, since it is not created for them
+// and anyone creating it in the cluster namespace would get the permissions of the agent.
+func (p *permissionBuilder) applyAgentRoleBinding(cluster *clusterv1.ManagedCluster,
+	addon *addonapiv1alpha1.ManagedClusterAddOn, roleName, roleKind string) error {
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      agentRoleBindingName(addon.Name, roleName),
+			Namespace: cluster.Name,
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind: roleKind,
+			Name: roleName,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind: rbacv1.GroupKind,
+				Name: agent.DefaultGroups(cluster.Name, addon.Name)[0],
+			},
+		},
+	}
+	if p.registrationOption != nil && p.registrationOption.TokenRegistration != nil {
+		binding.Subjects = append(binding.Subjects, rbacv1.Subject{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      constants.TokenServiceAccountName(addon.Name),
+			Namespace: cluster.Name,
+		})
+	}
+	ensureAddonOwnerReference(&binding.ObjectMeta, addon)
+	_, _, err := ApplyRoleBinding(context.TODO(), p.kubeClient.RbacV1(), binding)
+	return err
+}
+
+// agentRoleBindingName returns the name of the role binding of the addon agent, which includes the addon name
+// so the addons binding the same role do not overwrite the bindings of each other.
+func agentRoleBindingName(addonName, roleName string) string {
+	return fmt.Sprintf("open-cluster-management:%s:%s", addonName, roleName)
+}
+
 func (p *permissionBuilder) WithStaticClusterRole(clusterRole *rbacv1.ClusterRole) RBACPermissionBuilder {
 	p.u.fns = append(p.u.fns, func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
 		_, _, err := ApplyClusterRole(context.TODO(), p.kubeClient.RbacV1(), clusterRole)
@@ -154,6 +226,8 @@ func (p *permissionBuilder) WithStaticClusterRoleBinding(binding *rbacv1.Cluster
 
 func (p *permissionBuilder) WithStaticRole(role *rbacv1.Role) RBACPermissionBuilder {
 	p.u.fns = append(p.u.fns, func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+		// copy the role since the func is called for each cluster
+		role := role.DeepCopy()
 		role.Namespace = cluster.Name
 		ensureAddonOwnerReference(&role.ObjectMeta, addon)
 		_, _, err := ApplyRole(context.TODO(), p.kubeClient.RbacV1(), role)
@@ -164,6 +238,8 @@ func (p *permissionBuilder) WithStaticRole(role *rbacv1.Role) RBACPermissionBuil
 
 func (p *permissionBuilder) WithStaticRoleBinding(binding *rbacv1.RoleBinding) RBACPermissionBuilder {
 	p.u.fns = append(p.u.fns, func(cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+		// copy the binding since the func is called for each cluster
+		binding := binding.DeepCopy()
 		binding.Namespace = cluster.Name
 		ensureAddonOwnerReference(&binding.ObjectMeta, addon)
 		_, _, err := ApplyRoleBinding(context.TODO(), p.kubeClient.RbacV1(), binding)
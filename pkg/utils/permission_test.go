@@ -7,9 +7,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
 	"open-cluster-management.io/api/addon/v1alpha1"
 	v1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/agent"
 )
 
 func TestPermissionBuilder(t *testing.T) {
@@ -60,3 +63,97 @@ func TestPermissionBuilder(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, updatingRole2.UID, actualRole2.UID)
 }
+
+func TestPermissionBuilderBindToAgent(t *testing.T) {
+	testAddon := &v1alpha1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-addon"},
+	}
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent-role"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get"}, Resources: []string{"configmaps"}, APIGroups: []string{""}},
+		},
+	}
+	fakeKubeClient := fake.NewSimpleClientset()
+	permissionConfigFn := NewRBACPermissionConfigBuilder(fakeKubeClient).
+		BindRoleToAgent(role).
+		BindClusterRoleToAgent("view").
+		Build()
+
+	for _, clusterName := range []string{"cluster1", "cluster2"} {
+		cluster := &v1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: clusterName}}
+		assert.NoError(t, permissionConfigFn(cluster, testAddon))
+
+		actualRole, err := fakeKubeClient.RbacV1().Roles(clusterName).Get(context.TODO(), "agent-role", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, role.Rules, actualRole.Rules)
+
+		roleBinding, err := fakeKubeClient.RbacV1().RoleBindings(clusterName).Get(context.TODO(),
+			"open-cluster-management:test-addon:agent-role", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, rbacv1.RoleRef{Kind: "Role", Name: "agent-role"}, roleBinding.RoleRef)
+		assert.Equal(t, "system:open-cluster-management:cluster:"+clusterName+":addon:test-addon", roleBinding.Subjects[0].Name)
+		// the ServiceAccount of the agent is not bound without the token registration
+		assert.Len(t, roleBinding.Subjects, 1)
+
+		clusterRoleBinding, err := fakeKubeClient.RbacV1().RoleBindings(clusterName).Get(context.TODO(),
+			"open-cluster-management:test-addon:view", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"}, clusterRoleBinding.RoleRef)
+	}
+
+	// the role is not mutated by the builder
+	assert.Empty(t, role.Namespace)
+}
+
+func TestPermissionBuilderBindToTokenAgent(t *testing.T) {
+	testAddon := &v1alpha1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-addon"},
+	}
+	cluster := &v1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+	fakeKubeClient := fake.NewSimpleClientset()
+	registrationOption := &agent.RegistrationOption{
+		TokenRegistration: &agent.TokenRegistration{Server: "https://hub:6443"},
+	}
+	registrationOption.PermissionConfig = NewRBACPermissionConfigBuilder(fakeKubeClient).
+		WithRegistrationOption(registrationOption).
+		BindClusterRoleToAgent("view").
+		Build()
+	assert.NoError(t, registrationOption.PermissionConfig(cluster, testAddon))
+
+	roleBinding, err := fakeKubeClient.RbacV1().RoleBindings("cluster1").Get(context.TODO(),
+		"open-cluster-management:test-addon:view", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []rbacv1.Subject{
+		{Kind: rbacv1.GroupKind, Name: "system:open-cluster-management:cluster:cluster1:addon:test-addon"},
+		{Kind: rbacv1.ServiceAccountKind, Name: "addon-test-addon-agent", Namespace: "cluster1"},
+	}, roleBinding.Subjects)
+}
+
+func TestPermissionBuilderBindSameRoleToAgents(t *testing.T) {
+	cluster := &v1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+	fakeKubeClient := fake.NewSimpleClientset()
+	permissionConfigFn := NewRBACPermissionConfigBuilder(fakeKubeClient).
+		BindClusterRoleToAgent("view").
+		Build()
+
+	for _, addonName := range []string{"addon1", "addon2"} {
+		addon := &v1alpha1.ManagedClusterAddOn{
+			ObjectMeta: metav1.ObjectMeta{Name: addonName, Namespace: cluster.Name, UID: types.UID(addonName)},
+		}
+		assert.NoError(t, permissionConfigFn(cluster, addon))
+	}
+
+	// each addon has its own binding of the role, with its own subject and owner
+	for _, addonName := range []string{"addon1", "addon2"} {
+		roleBinding, err := fakeKubeClient.RbacV1().RoleBindings("cluster1").Get(context.TODO(),
+			"open-cluster-management:"+addonName+":view", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"}, roleBinding.RoleRef)
+		assert.Equal(t, []rbacv1.Subject{
+			{Kind: rbacv1.GroupKind, Name: "system:open-cluster-management:cluster:cluster1:addon:" + addonName},
+		}, roleBinding.Subjects)
+		assert.Len(t, roleBinding.OwnerReferences, 1)
+		assert.Equal(t, types.UID(addonName), roleBinding.OwnerReferences[0].UID)
+	}
+}
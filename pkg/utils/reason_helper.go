@@ -0,0 +1,128 @@
+package utils
+
+import (
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+// ReasonActionNone is the action of the condition reasons that represent a healthy state.
+const ReasonActionNone = "No action is required."
+
+// reasonActions maps the condition reasons emitted by the framework to the actions a user can take.
+var reasonActions = map[string]string{
+	// Available
+	addonapiv1alpha1.AddonAvailableReasonWorkNotFound: "Check the addon manager is running and the ManagedClusterAddOn " +
+		"is owned by the ClusterManagementAddOn.",
+	addonapiv1alpha1.AddonAvailableReasonWorkApplyFailed: "Check the status of the addon ManifestWorks and the work agent " +
+		"logs on the managed cluster.",
+	addonapiv1alpha1.AddonAvailableReasonWorkNotApply: "Wait for the work agent to apply the addon ManifestWorks, " +
+		"check the managed cluster is available if it lasts.",
+	addonapiv1alpha1.AddonAvailableReasonWorkApply:     ReasonActionNone,
+	addonapiv1alpha1.AddonAvailableReasonNoProbeResult: "Check the probe fields of the addon agent resources are reported in the ManifestWorks.",
+	addonapiv1alpha1.AddonAvailableReasonProbeUnavailable: "Check the addon agent workloads on the managed cluster, " +
+		"the condition message has the details of the failed probe.",
+	addonapiv1alpha1.AddonAvailableReasonProbeAvailable:     ReasonActionNone,
+	addonapiv1alpha1.AddonAvailableReasonLeaseUpdateStopped: "Check the addon agent is running on the managed cluster.",
+	addonapiv1alpha1.AddonAvailableReasonLeaseLeaseNotFound: "Check the addon agent is running on the managed cluster " +
+		"and is able to create its lease.",
+	addonapiv1alpha1.AddonAvailableReasonLeaseLeaseUpdated: ReasonActionNone,
+	constants.AddonAvailableReasonProxyUnavailable: "Check the cluster-proxy addon is available on the managed " +
+		"cluster.",
+
+	// ManifestApplied
+	addonapiv1alpha1.AddonManifestAppliedReasonWorkApplyFailed: "Check the addon manager logs and the permission of the " +
+		"addon manager to manage ManifestWorks.",
+	addonapiv1alpha1.AddonManifestAppliedReasonManifestsApplied: ReasonActionNone,
+	addonapiv1alpha1.AddonManifestAppliedReasonManifestsApplyFailed: "Fix the addon manifests or configurations, the " +
+		"condition message has the details of the rendering failure.",
+	constants.ManifestAppliedReasonAddonDisabled: "Remove the disable annotation of the ManagedClusterAddOn to " +
+		"install the addon again.",
+	constants.ManifestAppliedReasonInstallThrottled: "Wait for the initial installs of the addons to be allowed " +
+		"by the install rate limit of the addon manager.",
+
+	// ManifestApplyConflict
+	constants.ManifestApplyConflictReasonConflict: "Remove the conflicting fields from the other field manager, or " +
+		"drop them from the addon manifests, the condition message has the conflicting manifests.",
+	constants.ManifestApplyConflictReasonNoConflict: ReasonActionNone,
+
+	// ValuesInvalid
+	constants.ValuesInvalidReasonSchemaValidationFailed: "Fix the values of the addon in its configs against the " +
+		"values schema, the condition message has the validation errors.",
+	constants.ValuesInvalidReasonValuesValid: ReasonActionNone,
+
+	// ManifestDrifted
+	constants.ManifestDriftedReasonDrifted: "Revert the out-of-band changes of the addon ManifestWorks, or change " +
+		"the addon configs instead.",
+	constants.ManifestDriftedReasonNoDrift: ReasonActionNone,
+
+	// AgentVersionUpToDate
+	constants.AgentVersionReasonUpToDate: ReasonActionNone,
+	constants.AgentVersionReasonVersionUnknown: "Check the deploy ManifestWorks of the addon are created and " +
+		"stamped with the agent version.",
+
+	// HostingClusterValidity
+	addonapiv1alpha1.HostingClusterValidityReasonValid: ReasonActionNone,
+	addonapiv1alpha1.HostingClusterValidityReasonInvalid: "Set the hosting cluster annotation of the ManagedClusterAddOn " +
+		"to a managed cluster registered on the hub.",
+
+	// UnsupportedConfiguration
+	addonapiv1alpha1.AddonReasonConfigurationSupported: ReasonActionNone,
+	addonapiv1alpha1.AddonReasonConfigurationUnsupported: "Remove the configs that are not in the supported configs of " +
+		"the ClusterManagementAddOn.",
+
+	// RegistrationApplied
+	constants.RegistrationReasonNilRegistration: ReasonActionNone,
+	constants.RegistrationReasonSetPermissionFailed: "Check the addon manager logs and the permission of the addon " +
+		"manager to manage RBAC resources on the hub.",
+	constants.RegistrationReasonGetInstallNamespaceFailed: "Fix the install namespace config of the addon, the " +
+		"condition message has the details.",
+
+	// HookManifestCompleted
+	constants.HookManifestReasonCompleted: ReasonActionNone,
+	constants.HookManifestReasonNotCompleted: "Wait for the pre-delete hook to complete, check the hook job on " +
+		"the managed cluster if it lasts.",
+	constants.HookManifestReasonWaitingForManifestsCleanup: "Wait for the addon ManifestWorks to be removed from " +
+		"the managed cluster, check the managed cluster is available if it lasts.",
+
+	// Progressing of ManagedClusterAddOns
+	constants.AddonProgressingReasonApplying: "Wait for the addon ManifestWorks and configs to be applied.",
+	constants.AddonProgressingReasonFailed: "Check the status of the addon ManifestWorks, the condition message has " +
+		"the failed resources.",
+	constants.AddonProgressingReasonCompleted: ReasonActionNone,
+
+	// Progressing of install progressions
+	// Upgrading is also the reason of AgentVersionUpToDate while the agent version rolls out
+	constants.ProgressingReasonUpgrading:              "Wait for the rollout to complete.",
+	constants.ProgressingReasonUpgradeSucceed:         ReasonActionNone,
+	constants.ProgressingReasonInvalidRolloutStrategy: "Fix the rollout strategy of the placement in the ClusterManagementAddOn.",
+	constants.ProgressingReasonWaitingForCanary: "Roll out the desired configs to the addons on the canary placement, " +
+		"the rollout continues once they are applied and available.",
+	constants.ProgressingReasonCanaryRegressed: "Check the addons on the canary placement, the rollout resumes once " +
+		"they are available.",
+	constants.ProgressingReasonWaitingForMaintenanceWindow: "Wait for the maintenance window of the addon to open, " +
+		"or change the maintenance window in the addon configs.",
+	constants.ProgressingReasonRollingBack: "Wait for the addons to roll back to the lastKnownGoodConfig, and fix the " +
+		"desired configs.",
+	constants.ProgressingReasonRolledBack: "Fix the desired configs of the addon, the rollout restarts once they " +
+		"change.",
+
+	// RolloutSegment of install progressions
+	constants.RolloutSegmentReasonRollingOut: "Wait for the addons of the current segment to roll out.",
+	constants.RolloutSegmentReasonSoaking: "Wait for the previous segment to soak for the soak time of the rollout " +
+		"strategy.",
+
+	// RolloutFailed of install progressions
+	constants.RolloutFailedReasonFailureThresholdExceeded: "Fix the configs of the addon, the rollout restarts " +
+		"once the desired configs change.",
+
+	// CSR Approved
+	constants.CSRReasonAutoApproved: ReasonActionNone,
+}
+
+// ReasonToAction returns the action a user can take for a condition reason emitted by the framework.
+// It returns false if the reason is unknown to the framework.
+func ReasonToAction(reason string) (string, bool) {
+	action, ok := reasonActions[reason]
+	return action, ok
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+func TestReasonToAction(t *testing.T) {
+	cases := []struct {
+		name           string
+		reason         string
+		expectedFound  bool
+		expectedAction string
+	}{
+		{
+			name:          "unknown reason",
+			reason:        "Unknown",
+			expectedFound: false,
+		},
+		{
+			name:           "healthy reason",
+			reason:         addonapiv1alpha1.AddonAvailableReasonProbeAvailable,
+			expectedFound:  true,
+			expectedAction: ReasonActionNone,
+		},
+		{
+			name:           "framework reason",
+			reason:         constants.ProgressingReasonInvalidRolloutStrategy,
+			expectedFound:  true,
+			expectedAction: "Fix the rollout strategy of the placement in the ClusterManagementAddOn.",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			action, found := ReasonToAction(c.reason)
+			if found != c.expectedFound {
+				t.Errorf("expected found %v, but got %v", c.expectedFound, found)
+			}
+			if action != c.expectedAction {
+				t.Errorf("expected action %q, but got %q", c.expectedAction, action)
+			}
+		})
+	}
+}
+
+// TestReasonActionsCoverConditionReasons fails once a condition reason is added to the constants package without
+// an action.
+func TestReasonActionsCoverConditionReasons(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "../addonmanager/constants/reasons.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reasons := 0
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			for i, name := range valueSpec.Names {
+				// the events are not conditions, and the version is not a reason
+				if !strings.Contains(name.Name, "Reason") || strings.HasPrefix(name.Name, "EventReason") ||
+					name.Name == "ConditionReasonsVersion" {
+					continue
+				}
+				reason, err := strconv.Unquote(valueSpec.Values[i].(*ast.BasicLit).Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				reasons++
+				if _, found := ReasonToAction(reason); !found {
+					t.Errorf("no action of the condition reason %s %q", name.Name, reason)
+				}
+			}
+		}
+	}
+	if reasons == 0 {
+		t.Errorf("expected the condition reasons in the constants package")
+	}
+}
@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	workv1client "open-cluster-management.io/api/client/work/clientset/versioned"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+)
+
+// RemovalResource is a resource removed or orphaned when an addon is deleted.
+type RemovalResource struct {
+	Group     string `json:"group,omitempty"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// WorkRemovalReport reports the resources of a ManifestWork of the addon. When the work is deleted, the work agent
+// removes the Removed resources from the cluster, and the Orphaned resources are kept by the delete option of
+// the work.
+type WorkRemovalReport struct {
+	Work     RemovalResource   `json:"work"`
+	Removed  []RemovalResource `json:"removed,omitempty"`
+	Orphaned []RemovalResource `json:"orphaned,omitempty"`
+}
+
+// ClusterRemovalReport reports what will be removed when the addon is deleted from a cluster.
+type ClusterRemovalReport struct {
+	ClusterName string `json:"clusterName"`
+	// PreDeleteHook is true if the pre-delete hook of the addon runs on the managed (or hosting) cluster when
+	// the addon is deleted.
+	PreDeleteHook bool `json:"preDeleteHook,omitempty"`
+	// HubResources are removed from the hub, including the ManagedClusterAddOn and the ManifestWorks.
+	HubResources []RemovalResource `json:"hubResources"`
+	// ManifestWorks are the works of the addon in the managed cluster namespace, or in the hosting cluster
+	// namespace in Hosted mode.
+	ManifestWorks []WorkRemovalReport `json:"manifestWorks,omitempty"`
+	// ManagedClusterResources are removed from the managed cluster by the klusterlet, e.g. the hub kubeconfig
+	// secret of the addon agent.
+	ManagedClusterResources []RemovalResource `json:"managedClusterResources,omitempty"`
+}
+
+// AddonRemovalReport reports what will be removed when the addon is deleted from the clusters, or when the
+// ClusterManagementAddOn is deleted.
+type AddonRemovalReport struct {
+	AddonName string `json:"addonName"`
+	// HubResources are the cluster scoped resources removed from the hub, i.e. the ClusterManagementAddOn.
+	HubResources []RemovalResource      `json:"hubResources,omitempty"`
+	Clusters     []ClusterRemovalReport `json:"clusters"`
+}
+
+// NewAddonRemovalReport reports what will be removed, and where, when the addon is deleted from the clusters, so
+// the destructive action can be reviewed up front. It is a dry run, nothing is changed. If no cluster is given,
+// it reports the deletion of the ClusterManagementAddOn, which removes the addon from all the clusters.
+func NewAddonRemovalReport(ctx context.Context,
+	kubeClient kubernetes.Interface,
+	addonClient addonv1alpha1client.Interface,
+	workClient workv1client.Interface,
+	addonName string, clusterNames ...string) (*AddonRemovalReport, error) {
+	report := &AddonRemovalReport{AddonName: addonName, Clusters: []ClusterRemovalReport{}}
+
+	var addons []addonapiv1alpha1.ManagedClusterAddOn
+	if len(clusterNames) == 0 {
+		_, err := addonClient.AddonV1alpha1().ClusterManagementAddOns().Get(ctx, addonName, metav1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+		case err != nil:
+			return nil, err
+		default:
+			report.HubResources = append(report.HubResources, RemovalResource{
+				Group:    addonapiv1alpha1.GroupName,
+				Resource: "clustermanagementaddons",
+				Name:     addonName,
+			})
+		}
+
+		addonList, err := addonClient.AddonV1alpha1().ManagedClusterAddOns(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, addon := range addonList.Items {
+			if addon.Name == addonName {
+				addons = append(addons, addon)
+			}
+		}
+	}
+	for _, clusterName := range clusterNames {
+		addon, err := addonClient.AddonV1alpha1().ManagedClusterAddOns(clusterName).Get(ctx, addonName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		addons = append(addons, *addon)
+	}
+
+	works, err := workClient.WorkV1().ManifestWorks(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", addonapiv1alpha1.AddonLabelKey, addonName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range addons {
+		clusterReport, err := newClusterRemovalReport(ctx, kubeClient, &addons[i], works.Items)
+		if err != nil {
+			return nil, err
+		}
+		report.Clusters = append(report.Clusters, *clusterReport)
+	}
+	sort.Slice(report.Clusters, func(i, j int) bool {
+		return report.Clusters[i].ClusterName < report.Clusters[j].ClusterName
+	})
+	return report, nil
+}
+
+func newClusterRemovalReport(ctx context.Context, kubeClient kubernetes.Interface,
+	addon *addonapiv1alpha1.ManagedClusterAddOn, works []workapiv1.ManifestWork) (*ClusterRemovalReport, error) {
+	report := &ClusterRemovalReport{
+		ClusterName: addon.Namespace,
+		HubResources: []RemovalResource{{
+			Group:     addonapiv1alpha1.GroupName,
+			Resource:  "managedclusteraddons",
+			Namespace: addon.Namespace,
+			Name:      addon.Name,
+		}},
+	}
+
+	for _, finalizer := range addon.Finalizers {
+		if finalizer == addonapiv1alpha1.AddonPreDeleteHookFinalizer ||
+			finalizer == addonapiv1alpha1.AddonHostingPreDeleteHookFinalizer ||
+			finalizer == addonapiv1alpha1.AddonDeprecatedPreDeleteHookFinalizer ||
+			finalizer == addonapiv1alpha1.AddonDeprecatedHostingPreDeleteHookFinalizer {
+			report.PreDeleteHook = true
+		}
+	}
+
+	for i := range works {
+		work := &works[i]
+		// the works in Hosted mode are in the hosting cluster namespace, labeled with the addon namespace.
+		addonNamespace, hosted := work.Labels[addonapiv1alpha1.AddonNamespaceLabelKey]
+		if (hosted && addonNamespace != addon.Namespace) || (!hosted && work.Namespace != addon.Namespace) {
+			continue
+		}
+		// the pre-delete hook work is removed after the hook completes.
+		if strings.HasPrefix(work.Name, constants.PreDeleteHookWorkName(addon.Name)) {
+			report.PreDeleteHook = true
+		}
+
+		workReport, err := newWorkRemovalReport(work)
+		if err != nil {
+			return nil, err
+		}
+		report.ManifestWorks = append(report.ManifestWorks, *workReport)
+		report.HubResources = append(report.HubResources, workReport.Work)
+	}
+
+	// the client certificates issued by the hub are owned by the addon.
+	secrets, err := kubeClient.CoreV1().Secrets(addon.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", addonapiv1alpha1.AddonLabelKey, addon.Name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets.Items {
+		if !IsOwnedByAddon(secret.OwnerReferences, addon) {
+			continue
+		}
+		report.HubResources = append(report.HubResources, RemovalResource{
+			Resource:  "secrets",
+			Namespace: secret.Namespace,
+			Name:      secret.Name,
+		})
+	}
+
+	for _, registration := range addon.Status.Registrations {
+		if registration.SignerName != certificatesv1.KubeAPIServerClientSignerName || len(addon.Status.Namespace) == 0 {
+			continue
+		}
+		report.ManagedClusterResources = append(report.ManagedClusterResources, RemovalResource{
+			Resource:  "secrets",
+			Namespace: addon.Status.Namespace,
+			Name:      constants.HubKubeConfigSecretName(addon.Name),
+		})
+	}
+
+	return report, nil
+}
+
+func newWorkRemovalReport(work *workapiv1.ManifestWork) (*WorkRemovalReport, error) {
+	report := &WorkRemovalReport{
+		Work: RemovalResource{
+			Group:     workapiv1.GroupName,
+			Resource:  "manifestworks",
+			Namespace: work.Namespace,
+			Name:      work.Name,
+		},
+	}
+
+	for _, manifest := range work.Spec.Workload.Manifests {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+			return nil, fmt.Errorf("failed to decode the manifest of work %s/%s: %v", work.Namespace, work.Name, err)
+		}
+		plural, _ := meta.UnsafeGuessKindToResource(obj.GroupVersionKind())
+		resource := RemovalResource{
+			Group:     plural.Group,
+			Resource:  plural.Resource,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+		}
+
+		if isOrphaned(work.Spec.DeleteOption, resource) {
+			report.Orphaned = append(report.Orphaned, resource)
+		} else {
+			report.Removed = append(report.Removed, resource)
+		}
+	}
+	return report, nil
+}
+
+// isOrphaned returns true if the resource is kept on the cluster by the delete option when the work is deleted.
+func isOrphaned(deleteOption *workapiv1.DeleteOption, resource RemovalResource) bool {
+	if deleteOption == nil {
+		return false
+	}
+
+	switch deleteOption.PropagationPolicy {
+	case workapiv1.DeletePropagationPolicyTypeOrphan:
+		return true
+	case workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan:
+		if deleteOption.SelectivelyOrphan == nil {
+			return false
+		}
+		for _, rule := range deleteOption.SelectivelyOrphan.OrphaningRules {
+			if rule.Group == resource.Group && rule.Resource == resource.Resource &&
+				rule.Namespace == resource.Namespace && rule.Name == resource.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsOwnedByAddon returns true if the owner references have the addon, the uid is not compared if the addon has none.
+func IsOwnedByAddon(owners []metav1.OwnerReference, addon *addonapiv1alpha1.ManagedClusterAddOn) bool {
+	for _, owner := range owners {
+		if owner.Kind == "ManagedClusterAddOn" && owner.Name == addon.Name && (len(addon.UID) == 0 || owner.UID == addon.UID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	fakework "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+)
+
+func TestNewAddonRemovalReport(t *testing.T) {
+	newAddon := func(cluster string) *addonapiv1alpha1.ManagedClusterAddOn {
+		addon := addontesting.NewAddon("test", cluster)
+		addon.UID = types.UID("uid-" + cluster)
+		return addon
+	}
+	addon1 := newAddon("cluster1")
+	addon1.Finalizers = []string{addonapiv1alpha1.AddonPreDeleteHookFinalizer}
+	addon1.Status.Namespace = "addon-ns"
+	addon1.Status.Registrations = []addonapiv1alpha1.RegistrationConfig{
+		{SignerName: certificatesv1.KubeAPIServerClientSignerName},
+	}
+	addon2 := newAddon("cluster2")
+
+	work1 := addontesting.NewManifestWork("addon-test-deploy-0", "cluster1",
+		addontesting.NewUnstructured("v1", "ConfigMap", "addon-ns", "cm1"),
+		addontesting.NewUnstructured("apps/v1", "Deployment", "addon-ns", "agent"))
+	work1.Labels = map[string]string{addonapiv1alpha1.AddonLabelKey: "test"}
+	work1.Spec.DeleteOption = &workapiv1.DeleteOption{
+		PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+		SelectivelyOrphan: &workapiv1.SelectivelyOrphan{OrphaningRules: []workapiv1.OrphaningRule{
+			{Resource: "configmaps", Namespace: "addon-ns", Name: "cm1"},
+		}},
+	}
+	// the hosted work of cluster2 in the hosting cluster namespace
+	work2 := addontesting.NewManifestWork("addon-test-deploy-hosting-cluster2-0", "hosting",
+		addontesting.NewUnstructured("v1", "ConfigMap", "addon-ns", "cm2"))
+	work2.Labels = map[string]string{
+		addonapiv1alpha1.AddonLabelKey:          "test",
+		addonapiv1alpha1.AddonNamespaceLabelKey: "cluster2",
+	}
+	work2.Spec.DeleteOption = &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeOrphan}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "addon-test-signer-client-cert",
+			Namespace: "cluster1",
+			Labels:    map[string]string{addonapiv1alpha1.AddonLabelKey: "test"},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(addon1, addonapiv1alpha1.GroupVersion.WithKind("ManagedClusterAddOn")),
+			},
+		},
+	}
+
+	mcaResource := func(cluster string) RemovalResource {
+		return RemovalResource{Group: addonapiv1alpha1.GroupName, Resource: "managedclusteraddons", Namespace: cluster, Name: "test"}
+	}
+	work1Resource := RemovalResource{Group: workapiv1.GroupName, Resource: "manifestworks", Namespace: "cluster1", Name: "addon-test-deploy-0"}
+	work2Resource := RemovalResource{Group: workapiv1.GroupName, Resource: "manifestworks", Namespace: "hosting", Name: "addon-test-deploy-hosting-cluster2-0"}
+	cluster1Report := ClusterRemovalReport{
+		ClusterName:   "cluster1",
+		PreDeleteHook: true,
+		HubResources: []RemovalResource{
+			mcaResource("cluster1"),
+			work1Resource,
+			{Resource: "secrets", Namespace: "cluster1", Name: "addon-test-signer-client-cert"},
+		},
+		ManifestWorks: []WorkRemovalReport{{
+			Work:     work1Resource,
+			Removed:  []RemovalResource{{Group: "apps", Resource: "deployments", Namespace: "addon-ns", Name: "agent"}},
+			Orphaned: []RemovalResource{{Resource: "configmaps", Namespace: "addon-ns", Name: "cm1"}},
+		}},
+		ManagedClusterResources: []RemovalResource{{Resource: "secrets", Namespace: "addon-ns", Name: "test-hub-kubeconfig"}},
+	}
+	cluster2Report := ClusterRemovalReport{
+		ClusterName:  "cluster2",
+		HubResources: []RemovalResource{mcaResource("cluster2"), work2Resource},
+		ManifestWorks: []WorkRemovalReport{{
+			Work:     work2Resource,
+			Orphaned: []RemovalResource{{Resource: "configmaps", Namespace: "addon-ns", Name: "cm2"}},
+		}},
+	}
+
+	cases := []struct {
+		name           string
+		clusterNames   []string
+		expectedReport *AddonRemovalReport
+	}{
+		{
+			name:         "delete the addon from a cluster",
+			clusterNames: []string{"cluster1", "cluster3"},
+			expectedReport: &AddonRemovalReport{
+				AddonName: "test",
+				Clusters:  []ClusterRemovalReport{cluster1Report},
+			},
+		},
+		{
+			name: "delete the cluster management addon",
+			expectedReport: &AddonRemovalReport{
+				AddonName: "test",
+				HubResources: []RemovalResource{
+					{Group: addonapiv1alpha1.GroupName, Resource: "clustermanagementaddons", Name: "test"},
+				},
+				Clusters: []ClusterRemovalReport{cluster1Report, cluster2Report},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeClient := kubefake.NewSimpleClientset(secret)
+			addonClient := fakeaddon.NewSimpleClientset([]runtime.Object{
+				addontesting.NewClusterManagementAddon("test", "", "").Build(), addon1, addon2}...)
+			workClient := fakework.NewSimpleClientset(work1, work2)
+
+			report, err := NewAddonRemovalReport(context.TODO(), kubeClient, addonClient, workClient, "test", c.clusterNames...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(report, c.expectedReport) {
+				t.Errorf("expected report %+v, but got %+v", c.expectedReport, report)
+			}
+		})
+	}
+}
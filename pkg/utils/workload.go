@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// PodSpecMutateFunc mutates the pod spec of a workload, the kind is the lowercase plural kind of the
+// workload, e.g. "deployments" or "daemonsets".
+type PodSpecMutateFunc func(kind string, workload metav1.Object, podSpec *corev1.PodSpec)
+
+// MutateWorkload calls the mutate func on the pod spec of the object if it is a Deployment, DaemonSet,
+// StatefulSet or Job, either typed or unstructured. The object is copied before mutating, other objects are
+// returned as is.
+func MutateWorkload(obj runtime.Object, mutate PodSpecMutateFunc) (runtime.Object, error) {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		deployment := o.DeepCopy()
+		mutate("deployments", deployment, &deployment.Spec.Template.Spec)
+		return deployment, nil
+	case *appsv1.DaemonSet:
+		daemonSet := o.DeepCopy()
+		mutate("daemonsets", daemonSet, &daemonSet.Spec.Template.Spec)
+		return daemonSet, nil
+	case *appsv1.StatefulSet:
+		statefulSet := o.DeepCopy()
+		mutate("statefulsets", statefulSet, &statefulSet.Spec.Template.Spec)
+		return statefulSet, nil
+	case *batchv1.Job:
+		job := o.DeepCopy()
+		mutate("jobs", job, &job.Spec.Template.Spec)
+		return job, nil
+	case *unstructured.Unstructured:
+		var typed runtime.Object
+		switch o.GroupVersionKind() {
+		case appsv1.SchemeGroupVersion.WithKind("Deployment"):
+			typed = &appsv1.Deployment{}
+		case appsv1.SchemeGroupVersion.WithKind("DaemonSet"):
+			typed = &appsv1.DaemonSet{}
+		case appsv1.SchemeGroupVersion.WithKind("StatefulSet"):
+			typed = &appsv1.StatefulSet{}
+		case batchv1.SchemeGroupVersion.WithKind("Job"):
+			typed = &batchv1.Job{}
+		default:
+			return obj, nil
+		}
+
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, typed); err != nil {
+			return nil, fmt.Errorf("failed to convert %s %s/%s: %v", o.GetKind(), o.GetNamespace(), o.GetName(), err)
+		}
+		mutated, err := MutateWorkload(typed, mutate)
+		if err != nil {
+			return nil, err
+		}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(mutated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s %s/%s: %v", o.GetKind(), o.GetNamespace(), o.GetName(), err)
+		}
+		return &unstructured.Unstructured{Object: content}, nil
+	default:
+		return obj, nil
+	}
+}
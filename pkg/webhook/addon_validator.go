@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+)
+
+// ManagedClusterAddOnValidatingPath is the path the ManagedClusterAddOnValidator is usually registered on.
+const ManagedClusterAddOnValidatingPath = "/validate-managedclusteraddon"
+
+// ManagedClusterAddOnValidator validates the creates and updates of the ManagedClusterAddOns. The installNamespace
+// must be a valid namespace name, and the configs must have names and must not be referenced more than once.
+// The zero value only validates these framework owned fields.
+type ManagedClusterAddOnValidator struct {
+	// AddonNames are the names of the addons validated, the other addons are allowed. All the addons are
+	// validated if it is empty.
+	AddonNames []string
+
+	// AllowedInstallNamespaces restricts the installNamespace of the addons. Any namespace is allowed if it
+	// is empty.
+	AllowedInstallNamespaces []string
+
+	// RequiredConfigs are the config resources that must be set in the configs of the addons.
+	RequiredConfigs []addonapiv1alpha1.ConfigGroupResource
+
+	// ValidateFunc is an extra validation of the addons, the error is the reason of the denial.
+	ValidateFunc func(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn) error
+}
+
+var _ Handler = &ManagedClusterAddOnValidator{}
+
+func (v *ManagedClusterAddOnValidator) Handle(ctx context.Context,
+	request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return Allowed()
+	}
+
+	addon := &addonapiv1alpha1.ManagedClusterAddOn{}
+	if err := json.Unmarshal(request.Object.Raw, addon); err != nil {
+		return Denied(http.StatusBadRequest, fmt.Sprintf("invalid ManagedClusterAddOn: %v", err))
+	}
+	if len(v.AddonNames) > 0 && !sets.New(v.AddonNames...).Has(addon.Name) {
+		return Allowed()
+	}
+
+	if errs := v.validate(ctx, addon); len(errs) > 0 {
+		return Denied(http.StatusUnprocessableEntity, fmt.Sprintf("ManagedClusterAddOn %s/%s is invalid: %s",
+			addon.Namespace, addon.Name, strings.Join(errs, "; ")))
+	}
+	return Allowed()
+}
+
+func (v *ManagedClusterAddOnValidator) validate(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn) []string {
+	var errs []string
+
+	if installNamespace := addon.Spec.InstallNamespace; len(installNamespace) > 0 {
+		for _, msg := range validation.IsDNS1123Label(installNamespace) {
+			errs = append(errs, fmt.Sprintf("spec.installNamespace %q: %s", installNamespace, msg))
+		}
+		if len(v.AllowedInstallNamespaces) > 0 && !sets.New(v.AllowedInstallNamespaces...).Has(installNamespace) {
+			errs = append(errs, fmt.Sprintf("spec.installNamespace %q is not in the allowed namespaces %v",
+				installNamespace, v.AllowedInstallNamespaces))
+		}
+	}
+
+	configs := sets.New[addonapiv1alpha1.AddOnConfig]()
+	resources := sets.New[addonapiv1alpha1.ConfigGroupResource]()
+	for i, config := range addon.Spec.Configs {
+		if len(config.Resource) == 0 || len(config.Name) == 0 {
+			errs = append(errs, fmt.Sprintf("spec.configs[%d]: the resource and the name are required", i))
+		}
+		if configs.Has(config) {
+			errs = append(errs, fmt.Sprintf("spec.configs[%d]: config %s.%s %s/%s is duplicated", i,
+				config.Resource, config.Group, config.Namespace, config.Name))
+		}
+		configs.Insert(config)
+		resources.Insert(config.ConfigGroupResource)
+	}
+	for _, required := range v.RequiredConfigs {
+		if !resources.Has(required) {
+			errs = append(errs, fmt.Sprintf("spec.configs: config %s.%s is required", required.Resource, required.Group))
+		}
+	}
+
+	if v.ValidateFunc != nil {
+		if err := v.ValidateFunc(ctx, addon); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return errs
+}
@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+)
+
+var deploymentConfigGR = addonapiv1alpha1.ConfigGroupResource{
+	Group:    "addon.open-cluster-management.io",
+	Resource: "addondeploymentconfigs",
+}
+
+func newAddonRequest(t *testing.T, operation admissionv1.Operation, name, installNamespace string,
+	configs ...addonapiv1alpha1.AddOnConfig) *admissionv1.AdmissionRequest {
+	addon := addontesting.NewAddon(name, "cluster1")
+	addon.Spec.InstallNamespace = installNamespace
+	addon.Spec.Configs = configs
+	raw, err := json.Marshal(addon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &admissionv1.AdmissionRequest{Operation: operation, Object: runtime.RawExtension{Raw: raw}}
+}
+
+func newConfig(name string) addonapiv1alpha1.AddOnConfig {
+	return addonapiv1alpha1.AddOnConfig{
+		ConfigGroupResource: deploymentConfigGR,
+		ConfigReferent:      addonapiv1alpha1.ConfigReferent{Namespace: "cluster1", Name: name},
+	}
+}
+
+func TestManagedClusterAddOnValidator(t *testing.T) {
+	cases := []struct {
+		name            string
+		validator       *ManagedClusterAddOnValidator
+		request         *admissionv1.AdmissionRequest
+		expectedAllowed bool
+	}{
+		{
+			name:            "delete is allowed",
+			validator:       &ManagedClusterAddOnValidator{},
+			request:         &admissionv1.AdmissionRequest{Operation: admissionv1.Delete},
+			expectedAllowed: true,
+		},
+		{
+			name:            "valid addon",
+			validator:       &ManagedClusterAddOnValidator{},
+			request:         newAddonRequest(t, admissionv1.Create, "test", "test-ns", newConfig("config")),
+			expectedAllowed: true,
+		},
+		{
+			name:      "invalid install namespace",
+			validator: &ManagedClusterAddOnValidator{},
+			request:   newAddonRequest(t, admissionv1.Create, "test", "Test_NS"),
+		},
+		{
+			name:      "config without name",
+			validator: &ManagedClusterAddOnValidator{},
+			request:   newAddonRequest(t, admissionv1.Update, "test", "", newConfig("")),
+		},
+		{
+			name:      "duplicated configs",
+			validator: &ManagedClusterAddOnValidator{},
+			request:   newAddonRequest(t, admissionv1.Update, "test", "", newConfig("config"), newConfig("config")),
+		},
+		{
+			name:      "install namespace not allowed",
+			validator: &ManagedClusterAddOnValidator{AllowedInstallNamespaces: []string{"addon-ns"}},
+			request:   newAddonRequest(t, admissionv1.Create, "test", "test-ns"),
+		},
+		{
+			name:            "install namespace allowed",
+			validator:       &ManagedClusterAddOnValidator{AllowedInstallNamespaces: []string{"addon-ns"}},
+			request:         newAddonRequest(t, admissionv1.Create, "test", "addon-ns"),
+			expectedAllowed: true,
+		},
+		{
+			name:      "required config missing",
+			validator: &ManagedClusterAddOnValidator{RequiredConfigs: []addonapiv1alpha1.ConfigGroupResource{deploymentConfigGR}},
+			request:   newAddonRequest(t, admissionv1.Create, "test", ""),
+		},
+		{
+			name: "other addon is not validated",
+			validator: &ManagedClusterAddOnValidator{
+				AddonNames:      []string{"test"},
+				RequiredConfigs: []addonapiv1alpha1.ConfigGroupResource{deploymentConfigGR},
+			},
+			request:         newAddonRequest(t, admissionv1.Create, "other", ""),
+			expectedAllowed: true,
+		},
+		{
+			name: "validate func",
+			validator: &ManagedClusterAddOnValidator{
+				ValidateFunc: func(_ context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+					return fmt.Errorf("addon %s is not allowed", addon.Name)
+				},
+			},
+			request: newAddonRequest(t, admissionv1.Create, "test", ""),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			response := c.validator.Handle(context.TODO(), c.request)
+			if response.Allowed != c.expectedAllowed {
+				t.Errorf("expected allowed %v, but got %v", c.expectedAllowed, response.Result)
+			}
+		})
+	}
+}
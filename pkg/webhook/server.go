@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/server/dynamiccertificates"
+	"k8s.io/klog/v2"
+)
+
+// maxRequestBytes is the max size of the admission reviews accepted by the server.
+const maxRequestBytes = 3 * 1024 * 1024
+
+// Handler handles the admission requests of a webhook.
+type Handler interface {
+	Handle(ctx context.Context, request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+}
+
+// HandlerFunc is a Handler of a func.
+type HandlerFunc func(ctx context.Context, request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+
+func (f HandlerFunc) Handle(ctx context.Context, request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	return f(ctx, request)
+}
+
+// Server serves the admission webhooks registered on it over TLS, so an addon manager can embed the webhooks
+// of its addons instead of running a webhook server of its own.
+type Server struct {
+	bindAddress string
+	certFile    string
+	keyFile     string
+	mux         *http.ServeMux
+}
+
+// NewServer returns a webhook server serving on the bind address, e.g. ":9443". The serving certificate and key
+// are read from the tls.crt and tls.key in the certDir, which is usually a mounted kubernetes.io/tls Secret,
+// and they are reloaded once the files are changed, e.g. the Secret is rotated.
+func NewServer(bindAddress, certDir string) *Server {
+	return &Server{
+		bindAddress: bindAddress,
+		certFile:    filepath.Join(certDir, corev1.TLSCertKey),
+		keyFile:     filepath.Join(certDir, corev1.TLSPrivateKeyKey),
+		mux:         http.NewServeMux(),
+	}
+}
+
+// Register registers the handler of the webhook on the path, e.g. "/validate-managedclusteraddon", which is the
+// path of the service in the webhook configuration. It must be called before the server is started.
+func (s *Server) Register(path string, handler Handler) {
+	s.mux.Handle(path, admissionHandler(handler))
+}
+
+// Start serves the webhooks until the context is done.
+func (s *Server) Start(ctx context.Context) error {
+	servingCert, err := dynamiccertificates.NewDynamicServingContentFromFiles("webhook-serving-cert", s.certFile, s.keyFile)
+	if err != nil {
+		return err
+	}
+	go servingCert.Run(ctx, 1)
+
+	server := &http.Server{
+		Addr:              s.bindAddress,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				cert, err := tls.X509KeyPair(servingCert.CurrentCertKeyContent())
+				if err != nil {
+					return nil, err
+				}
+				return &cert, nil
+			},
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			klog.Errorf("failed to shutdown the webhook server: %v", err)
+		}
+	}()
+
+	klog.Infof("serving the webhooks on %s", s.bindAddress)
+	if err := server.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// admissionHandler decodes the AdmissionReview of the request and encodes the response of the handler.
+func admissionHandler(handler Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, fmt.Sprintf("unsupported method %s", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBytes))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		review := &admissionv1.AdmissionReview{}
+		if err := json.Unmarshal(body, review); err != nil || review.Request == nil {
+			http.Error(w, fmt.Sprintf("invalid admission review: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		response := handler.Handle(r.Context(), review.Request)
+		if response == nil {
+			response = Allowed()
+		}
+		response.UID = review.Request.UID
+		review.Response = response
+		review.Request = nil
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(review); err != nil {
+			klog.Errorf("failed to write the admission response: %v", err)
+		}
+	})
+}
+
+// Allowed returns the response allowing the request.
+func Allowed() *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+// Denied returns the response denying the request with the reason.
+func Denied(code int32, reason string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    code,
+			Reason:  metav1.StatusReasonInvalid,
+			Message: reason,
+		},
+	}
+}
@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestAdmissionHandler(t *testing.T) {
+	handler := admissionHandler(HandlerFunc(
+		func(_ context.Context, request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+			if request.Name == "denied" {
+				return Denied(http.StatusForbidden, "denied")
+			}
+			return nil
+		}))
+
+	cases := []struct {
+		name            string
+		method          string
+		body            []byte
+		expectedCode    int
+		expectedAllowed bool
+	}{
+		{
+			name:         "unsupported method",
+			method:       http.MethodGet,
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:         "invalid review",
+			method:       http.MethodPost,
+			body:         []byte("{}"),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:            "allowed",
+			method:          http.MethodPost,
+			body:            newReview(t, "allowed"),
+			expectedCode:    http.StatusOK,
+			expectedAllowed: true,
+		},
+		{
+			name:         "denied",
+			method:       http.MethodPost,
+			body:         newReview(t, "denied"),
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(c.method, "/validate", bytes.NewReader(c.body)))
+			if recorder.Code != c.expectedCode {
+				t.Fatalf("expected code %d, but got %d", c.expectedCode, recorder.Code)
+			}
+			if recorder.Code != http.StatusOK {
+				return
+			}
+
+			review := &admissionv1.AdmissionReview{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), review); err != nil {
+				t.Fatal(err)
+			}
+			if review.Response.UID != "uid" {
+				t.Errorf("expected the uid of the request, but got %q", review.Response.UID)
+			}
+			if review.Response.Allowed != c.expectedAllowed {
+				t.Errorf("expected allowed %v, but got %v", c.expectedAllowed, review.Response.Allowed)
+			}
+		})
+	}
+}
+
+func TestStartWithoutCert(t *testing.T) {
+	if err := NewServer("127.0.0.1:0", t.TempDir()).Start(context.TODO()); err == nil {
+		t.Errorf("expected error when the serving cert is missing")
+	}
+}
+
+func newReview(t *testing.T, name string) []byte {
+	data, err := json.Marshal(&admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{UID: types.UID("uid"), Name: name},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -18,6 +18,7 @@ import (
 	certificatesv1 "k8s.io/api/certificates/v1"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager"
 	"open-cluster-management.io/addon-framework/pkg/manager"
+	"open-cluster-management.io/addon-framework/test/integration/util"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
 	clusterv1client "open-cluster-management.io/api/client/cluster/clientset/versioned"
@@ -61,12 +62,7 @@ var _ = ginkgo.BeforeSuite(func(done ginkgo.Done) {
 	// start a kube-apiserver
 	testEnv = &envtest.Environment{
 		ErrorIfCRDPathMissing: true,
-		CRDDirectoryPaths: []string{
-			filepath.Join(".", "vendor", "open-cluster-management.io", "api", "work", "v1", "0000_00_work.open-cluster-management.io_manifestworks.crd.yaml"),
-			filepath.Join(".", "vendor", "open-cluster-management.io", "api", "cluster", "v1"),
-			filepath.Join(".", "vendor", "open-cluster-management.io", "api", "cluster", "v1beta1"),
-			filepath.Join(".", "vendor", "open-cluster-management.io", "api", "addon", "v1alpha1"),
-		},
+		CRDDirectoryPaths:     util.CRDPaths(filepath.Join(".", "vendor", "open-cluster-management.io", "api")),
 	}
 
 	cfg, err := testEnv.Start()
@@ -0,0 +1,108 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	EventuallyTimeout  = 30 * time.Second
+	EventuallyInterval = 1 * time.Second
+)
+
+// CreateManagedCluster creates an accepted ManagedCluster and its namespace on the hub.
+func (h *Hub) CreateManagedCluster(ctx context.Context, name string) (*clusterv1.ManagedCluster, error) {
+	cluster, err := h.ClusterClient.ClusterV1().ManagedClusters().Create(ctx, &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = h.KubeClient.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+	}, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+// EventuallyManifestWork polls the ManifestWork until the check returns no error, and returns the last error of
+// the check if it does not pass in the EventuallyTimeout.
+func (h *Hub) EventuallyManifestWork(ctx context.Context, namespace, name string,
+	check func(work *workapiv1.ManifestWork) error) (*workapiv1.ManifestWork, error) {
+	var work *workapiv1.ManifestWork
+	var lastErr error
+	err := wait.PollImmediate(EventuallyInterval, EventuallyTimeout, func() (bool, error) {
+		work, lastErr = h.WorkClient.WorkV1().ManifestWorks(namespace).Get(ctx, name, metav1.GetOptions{})
+		if lastErr != nil {
+			return false, nil
+		}
+		if check != nil {
+			lastErr = check(work)
+		}
+		return lastErr == nil, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("manifestwork %s/%s is not expected: %v", namespace, name, lastErr)
+	}
+	return work, nil
+}
+
+// EventuallyManagedClusterAddOn polls the ManagedClusterAddOn until the check returns no error, and returns the
+// last error of the check if it does not pass in the EventuallyTimeout.
+func (h *Hub) EventuallyManagedClusterAddOn(ctx context.Context, namespace, name string,
+	check func(addon *addonapiv1alpha1.ManagedClusterAddOn) error) (*addonapiv1alpha1.ManagedClusterAddOn, error) {
+	var addon *addonapiv1alpha1.ManagedClusterAddOn
+	var lastErr error
+	err := wait.PollImmediate(EventuallyInterval, EventuallyTimeout, func() (bool, error) {
+		addon, lastErr = h.AddonClient.AddonV1alpha1().ManagedClusterAddOns(namespace).Get(ctx, name, metav1.GetOptions{})
+		if lastErr != nil {
+			return false, nil
+		}
+		if check != nil {
+			lastErr = check(addon)
+		}
+		return lastErr == nil, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("managedclusteraddon %s/%s is not expected: %v", namespace, name, lastErr)
+	}
+	return addon, nil
+}
+
+// EventuallyAddonCondition polls the ManagedClusterAddOn until it has the condition with the status.
+func (h *Hub) EventuallyAddonCondition(ctx context.Context, namespace, name, conditionType string,
+	status metav1.ConditionStatus) error {
+	_, err := h.EventuallyManagedClusterAddOn(ctx, namespace, name, func(addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+		if !meta.IsStatusConditionPresentAndEqual(addon.Status.Conditions, conditionType, status) {
+			return fmt.Errorf("condition %s is not %s: %v", conditionType, status, addon.Status.Conditions)
+		}
+		return nil
+	})
+	return err
+}
+
+// EventuallyManifestWorkCondition polls the ManifestWork until it has the condition with the status.
+func (h *Hub) EventuallyManifestWorkCondition(ctx context.Context, namespace, name, conditionType string,
+	status metav1.ConditionStatus) error {
+	_, err := h.EventuallyManifestWork(ctx, namespace, name, func(work *workapiv1.ManifestWork) error {
+		if !meta.IsStatusConditionPresentAndEqual(work.Status.Conditions, conditionType, status) {
+			return fmt.Errorf("condition %s is not %s: %v", conditionType, status, work.Status.Conditions)
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,129 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	clusterv1client "open-cluster-management.io/api/client/cluster/clientset/versioned"
+	workclientset "open-cluster-management.io/api/client/work/clientset/versioned"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/manager"
+)
+
+// CRDPaths returns the paths of the CRDs of the hub in the apiDir, which is the directory of the
+// open-cluster-management.io/api module, e.g. vendor/open-cluster-management.io/api.
+func CRDPaths(apiDir string) []string {
+	return []string{
+		filepath.Join(apiDir, "work", "v1", "0000_00_work.open-cluster-management.io_manifestworks.crd.yaml"),
+		filepath.Join(apiDir, "cluster", "v1"),
+		filepath.Join(apiDir, "cluster", "v1beta1"),
+		filepath.Join(apiDir, "addon", "v1alpha1"),
+	}
+}
+
+// FindAPIDir finds the vendored open-cluster-management.io/api module in the current directory or its parents,
+// so the tests can run from the root of the repository or from the directory of the test package.
+func FindAPIDir() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		apiDir := filepath.Join(dir, "vendor", "open-cluster-management.io", "api")
+		if _, err := os.Stat(apiDir); err == nil {
+			return apiDir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("vendor/open-cluster-management.io/api is not found, run go mod vendor first")
+		}
+		dir = parent
+	}
+}
+
+// Hub is a kube-apiserver started by envtest with the CRDs of the hub installed, and the clients of it.
+type Hub struct {
+	Env    *envtest.Environment
+	Config *rest.Config
+
+	KubeClient    kubernetes.Interface
+	ClusterClient clusterv1client.Interface
+	AddonClient   addonv1alpha1client.Interface
+	WorkClient    workclientset.Interface
+}
+
+// StartHub starts a kube-apiserver with the CRDs in the apiDir installed, the apiDir is found by FindAPIDir if
+// it is empty. The binaries of the kube-apiserver and etcd are found by the KUBEBUILDER_ASSETS env.
+func StartHub(apiDir string) (*Hub, error) {
+	if len(apiDir) == 0 {
+		var err error
+		if apiDir, err = FindAPIDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	hub := &Hub{
+		Env: &envtest.Environment{
+			ErrorIfCRDPathMissing: true,
+			CRDDirectoryPaths:     CRDPaths(apiDir),
+		},
+	}
+	cfg, err := hub.Env.Start()
+	if err != nil {
+		return nil, err
+	}
+	hub.Config = cfg
+
+	if hub.KubeClient, err = kubernetes.NewForConfig(cfg); err != nil {
+		return nil, err
+	}
+	if hub.ClusterClient, err = clusterv1client.NewForConfig(cfg); err != nil {
+		return nil, err
+	}
+	if hub.AddonClient, err = addonv1alpha1client.NewForConfig(cfg); err != nil {
+		return nil, err
+	}
+	if hub.WorkClient, err = workclientset.NewForConfig(cfg); err != nil {
+		return nil, err
+	}
+	return hub, nil
+}
+
+// Stop stops the kube-apiserver of the hub.
+func (h *Hub) Stop() error {
+	return h.Env.Stop()
+}
+
+// StartAddonManager starts an AddonManager of the agents against the hub, and the hub controllers of the
+// framework as the addon-manager of the cluster manager does. They are stopped once the ctx is done.
+func (h *Hub) StartAddonManager(ctx context.Context, agents []agent.AgentAddon,
+	opts ...addonmanager.Option) (addonmanager.AddonManager, error) {
+	mgr, err := addonmanager.New(h.Config, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, agentAddon := range agents {
+		if err := mgr.AddAgent(agentAddon); err != nil {
+			return nil, err
+		}
+	}
+	if err := mgr.Start(ctx); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := manager.RunManager(ctx, h.Config); err != nil {
+			klog.Errorf("failed to run the hub controllers: %v", err)
+		}
+	}()
+	return mgr, nil
+}
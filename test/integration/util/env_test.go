@@ -0,0 +1,19 @@
+package util
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCRDPaths(t *testing.T) {
+	apiDir, err := FindAPIDir()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, path := range CRDPaths(apiDir) {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected crd path %s exists: %v", path, err)
+		}
+	}
+}